@@ -13,10 +13,31 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
 )
 
 type config struct {
-	Types []typeSpec `yaml:"types"`
+	Types      []typeSpec      `yaml:"types"`
+	Migrations []migrationSpec `yaml:"migrations,omitempty"`
+}
+
+// migrationSpec declares how documents of one type are upgraded to another.
+// Steps are validated again by the service when the migration is used.
+type migrationSpec struct {
+	From        string              `yaml:"from"`
+	To          string              `yaml:"to"`
+	Description string              `yaml:"description,omitempty"`
+	Steps       []migrationStepSpec `yaml:"steps"`
+}
+
+type migrationStepSpec struct {
+	Op         string `yaml:"op"`
+	Path       string `yaml:"path"`
+	To         string `yaml:"to,omitempty"`
+	Value      string `yaml:"value,omitempty"`
+	KeyField   string `yaml:"key_field,omitempty"`
+	ValueField string `yaml:"value_field,omitempty"`
 }
 
 type typeSpec struct {
@@ -24,6 +45,7 @@ type typeSpec struct {
 	Label         string       `yaml:"label"`
 	ContentFormat string       `yaml:"content_format"`
 	Template      string       `yaml:"template"`
+	Schema        string       `yaml:"schema,omitempty"`
 	Backend       hookSpec     `yaml:"backend,omitempty"`
 	Frontend      frontendSpec `yaml:"frontend,omitempty"`
 }
@@ -44,6 +66,7 @@ type documentTypeDefinition struct {
 	typeSpec
 	TemplatePath    string
 	TemplateContent string
+	SchemaContent   string
 	Themes          []themeDefinition
 }
 
@@ -91,7 +114,11 @@ func main() {
 		fail(fmt.Errorf("no document types found in %s", configPath))
 	}
 
-	if err := generateBackend(definitions, filepath.Join(backendDir, "internal", "service", "document_types_gen.go")); err != nil {
+	if err := validateMigrations(cfg.Migrations, definitions); err != nil {
+		fail(err)
+	}
+
+	if err := generateBackend(definitions, cfg.Migrations, filepath.Join(backendDir, "internal", "service", "document_types_gen.go")); err != nil {
 		fail(err)
 	}
 
@@ -153,6 +180,11 @@ func loadDefinitions(cfg config, docTypesDir string) ([]documentTypeDefinition,
 			return nil, fmt.Errorf("read template for %q: %w", spec.ID, err)
 		}
 
+		schemaContent, err := loadSchema(spec, docTypesDir)
+		if err != nil {
+			return nil, err
+		}
+
 		themeDefs, err := resolveThemeDefinitions(spec, docTypesDir)
 		if err != nil {
 			return nil, err
@@ -162,6 +194,7 @@ func loadDefinitions(cfg config, docTypesDir string) ([]documentTypeDefinition,
 			typeSpec:        spec,
 			TemplatePath:    templatePath,
 			TemplateContent: string(templateBytes),
+			SchemaContent:   schemaContent,
 			Themes:          themeDefs,
 		})
 	}
@@ -169,6 +202,28 @@ func loadDefinitions(cfg config, docTypesDir string) ([]documentTypeDefinition,
 	return defs, nil
 }
 
+func loadSchema(spec typeSpec, docTypesDir string) (string, error) {
+	if spec.Schema == "" {
+		return "", nil
+	}
+	if spec.ContentFormat != "yaml" && spec.ContentFormat != "json" {
+		return "", fmt.Errorf("document type %q: schema is only supported for yaml and json content", spec.ID)
+	}
+	schemaPath := filepath.Join(docTypesDir, spec.ID, spec.Schema)
+	raw, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return "", fmt.Errorf("read schema for %q: %w", spec.ID, err)
+	}
+	if _, err := jsonschema.Compile(raw); err != nil {
+		return "", fmt.Errorf("schema for %q: %w", spec.ID, err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return "", fmt.Errorf("compact schema for %q: %w", spec.ID, err)
+	}
+	return compact.String(), nil
+}
+
 func resolveThemeDefinitions(spec typeSpec, docTypesDir string) ([]themeDefinition, error) {
 	if len(spec.Frontend.Themes) == 0 {
 		return nil, nil
@@ -205,7 +260,45 @@ func resolveThemeDefinitions(spec typeSpec, docTypesDir string) ([]themeDefiniti
 	return out, nil
 }
 
-func generateBackend(defs []documentTypeDefinition, dest string) error {
+func validateMigrations(migrations []migrationSpec, defs []documentTypeDefinition) error {
+	formats := make(map[string]string, len(defs))
+	for _, def := range defs {
+		formats[def.ID] = def.ContentFormat
+	}
+	seen := make(map[string]struct{}, len(migrations))
+	for _, m := range migrations {
+		name := m.From + " -> " + m.To
+		fromFormat, ok := formats[m.From]
+		if !ok {
+			return fmt.Errorf("migration %s: unknown source type %q", name, m.From)
+		}
+		toFormat, ok := formats[m.To]
+		if !ok {
+			return fmt.Errorf("migration %s: unknown target type %q", name, m.To)
+		}
+		if m.From == m.To {
+			return fmt.Errorf("migration %s: source and target types must differ", name)
+		}
+		if fromFormat != toFormat || (fromFormat != "yaml" && fromFormat != "json") {
+			return fmt.Errorf("migration %s: both types must share yaml or json content", name)
+		}
+		if _, exists := seen[name]; exists {
+			return fmt.Errorf("duplicate migration %s", name)
+		}
+		seen[name] = struct{}{}
+		if len(m.Steps) == 0 {
+			return fmt.Errorf("migration %s: no steps", name)
+		}
+		for i, step := range m.Steps {
+			if step.Op == "" || step.Path == "" {
+				return fmt.Errorf("migration %s: step %d requires op and path", name, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+func generateBackend(defs []documentTypeDefinition, migrations []migrationSpec, dest string) error {
 	var buf bytes.Buffer
 	buf.WriteString("// Code generated by docgen; DO NOT EDIT.\n")
 	buf.WriteString("package service\n\n")
@@ -232,6 +325,26 @@ func generateBackend(defs []documentTypeDefinition, dest string) error {
 		buf.WriteString(fmt.Sprintf("\t\t\tLabel: %s,\n", quoteGoString(def.Label)))
 		buf.WriteString(fmt.Sprintf("\t\t\tContentFormat: %s,\n", formatConst))
 		buf.WriteString(fmt.Sprintf("\t\t\tTemplatePath: %s,\n", quoteGoString(path)))
+		buf.WriteString(fmt.Sprintf("\t\t\tTemplate: %s,\n", quoteGoString(def.TemplateContent)))
+		if def.SchemaContent != "" {
+			buf.WriteString(fmt.Sprintf("\t\t\tSchema: %s,\n", quoteGoString(def.SchemaContent)))
+		}
+		if len(def.Themes) > 0 {
+			buf.WriteString("\t\t\tThemes: []DocumentTypeTheme{\n")
+			for _, theme := range def.Themes {
+				css, err := os.ReadFile(theme.CSSPath)
+				if err != nil {
+					return fmt.Errorf("read theme %s for %q: %w", theme.ID, def.ID, err)
+				}
+				label := theme.Label
+				if label == "" {
+					label = theme.ID
+				}
+				buf.WriteString(fmt.Sprintf("\t\t\t\t{ID: %s, Label: %s, Description: %s, CSS: %s},\n",
+					quoteGoString(theme.ID), quoteGoString(label), quoteGoString(theme.Description), quoteGoString(string(css))))
+			}
+			buf.WriteString("\t\t\t},\n")
+		}
 		buf.WriteString("\t\t},\n")
 	}
 	buf.WriteString("\t}\n")
@@ -241,6 +354,27 @@ func generateBackend(defs []documentTypeDefinition, dest string) error {
 		buf.WriteString(fmt.Sprintf("\t\tDocumentType(%q),\n", def.ID))
 	}
 	buf.WriteString("\t}\n")
+
+	if len(migrations) > 0 {
+		buf.WriteString("\tdocumentTypeMigrations = []DocumentTypeMigration{\n")
+		for _, m := range migrations {
+			buf.WriteString("\t\t{\n")
+			buf.WriteString(fmt.Sprintf("\t\t\tFrom: DocumentType(%q),\n", m.From))
+			buf.WriteString(fmt.Sprintf("\t\t\tTo: DocumentType(%q),\n", m.To))
+			if m.Description != "" {
+				buf.WriteString(fmt.Sprintf("\t\t\tDescription: %s,\n", quoteGoString(m.Description)))
+			}
+			buf.WriteString("\t\t\tSteps: []MigrationStep{\n")
+			for _, step := range m.Steps {
+				buf.WriteString(fmt.Sprintf("\t\t\t\t{Op: %s, Path: %s, To: %s, Value: %s, KeyField: %s, ValueField: %s},\n",
+					quoteGoString(step.Op), quoteGoString(step.Path), quoteGoString(step.To),
+					quoteGoString(step.Value), quoteGoString(step.KeyField), quoteGoString(step.ValueField)))
+			}
+			buf.WriteString("\t\t\t},\n")
+			buf.WriteString("\t\t},\n")
+		}
+		buf.WriteString("\t}\n")
+	}
 	buf.WriteString("}\n")
 
 	return writeFileIfChanged(dest, buf.Bytes(), 0o644)
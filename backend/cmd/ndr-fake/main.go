@@ -0,0 +1,64 @@
+// Command ndr-fake 启动内存版 NDR，供本地开发与联调使用（数据不落盘，重启即清空）。
+//
+//	go run ./cmd/ndr-fake -addr :9001 -seed cmd/ndr-fake/seed.example.json
+//
+// 然后把 YDMS_NDR_BASE_URL 指向 http://localhost:9001 启动后端。
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":9001", "listen address")
+	apiKey := flag.String("api-key", "", "require this x-api-key on API requests (empty disables the check)")
+	seedPath := flag.String("seed", "", "JSON file with initial nodes and documents")
+	publicURL := flag.String("public-url", "", "base URL used in presigned asset URLs (defaults to the request host)")
+	maxPageSize := flag.Int("max-page-size", 100, "largest page size returned by list endpoints")
+	flag.Parse()
+
+	server := ndrfake.New(ndrfake.Options{
+		APIKey:      *apiKey,
+		MaxPageSize: *maxPageSize,
+		PublicURL:   *publicURL,
+	})
+	if *seedPath != "" {
+		seed, err := ndrfake.LoadSeedFile(*seedPath)
+		if err != nil {
+			log.Fatalf("load seed: %v", err)
+		}
+		if err := server.Load(seed); err != nil {
+			log.Fatalf("apply seed: %v", err)
+		}
+		log.Printf("seed loaded: nodes=%d documents=%d", len(seed.Nodes), len(seed.Documents))
+	}
+
+	log.Printf("ndr-fake listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, logRequests(server)); err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("[ndr-fake] %s %s status=%d duration=%s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start))
+	})
+}
@@ -0,0 +1,94 @@
+// Command prefect-fake 启动内存版 Prefect，供本地联调工作流与 MySQL 同步使用（数据不落盘，重启即清空）。
+//
+//	go run ./cmd/prefect-fake -addr :4200 -webhook-secret dev-secret -deployments cmd/prefect-fake/deployments.example.json
+//
+// 然后设置 YDMS_PREFECT_BASE_URL=http://localhost:4200、YDMS_PREFECT_WEBHOOK_SECRET=dev-secret 启动后端。
+// flow run 按脚本推进状态，并向参数中的 callback_url 发送签名回调。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+	"github.com/yjxt/ydms/backend/internal/prefectfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":4200", "listen address")
+	secret := flag.String("webhook-secret", os.Getenv("YDMS_PREFECT_WEBHOOK_SECRET"), "secret used to sign callbacks (defaults to YDMS_PREFECT_WEBHOOK_SECRET)")
+	deploymentsPath := flag.String("deployments", "", "JSON file with an array of deployments (name, tags, description, version, parameter_openapi_schema)")
+	startDelay := flag.Duration("start-delay", time.Second, "time a flow run stays SCHEDULED before RUNNING")
+	runDuration := flag.Duration("run-duration", 3*time.Second, "time a flow run stays RUNNING before it finishes")
+	outcome := flag.String("outcome", prefectfake.StateCompleted, "final state: COMPLETED, FAILED or CRASHED")
+	errorMessage := flag.String("error", "", "error message reported by failed flow runs")
+	fail := flag.String("fail", "", "comma-separated deployment names whose flow runs always fail")
+	dropCallbacks := flag.Bool("drop-callbacks", false, "advance flow run states without calling back (exercises reconciliation)")
+	flag.Parse()
+
+	script := prefectfake.Script{
+		StartDelay:    *startDelay,
+		RunDuration:   *runDuration,
+		Outcome:       strings.ToUpper(*outcome),
+		ErrorMessage:  *errorMessage,
+		DropCallbacks: *dropCallbacks,
+	}
+	server := prefectfake.New(prefectfake.Options{
+		WebhookSecret: *secret,
+		DefaultScript: script,
+	})
+
+	if *deploymentsPath != "" {
+		data, err := os.ReadFile(*deploymentsPath)
+		if err != nil {
+			log.Fatalf("read deployments: %v", err)
+		}
+		var deployments []prefectclient.DeploymentDetails
+		if err := json.Unmarshal(data, &deployments); err != nil {
+			log.Fatalf("parse deployments %s: %v", *deploymentsPath, err)
+		}
+		for _, dep := range deployments {
+			dep = server.AddDeployment(dep)
+			log.Printf("deployment %s id=%s tags=%v", dep.Name, dep.ID, dep.Tags)
+		}
+	}
+	for _, name := range strings.Split(*fail, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			failing := script
+			failing.Outcome = prefectfake.StateFailed
+			server.SetScript(name, failing)
+		}
+	}
+	if *secret == "" {
+		log.Printf("warning: no webhook secret, callbacks are sent unsigned and YDMS will reject them")
+	}
+
+	log.Printf("prefect-fake listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, logRequests(server)); err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("[prefect-fake] %s %s status=%d duration=%s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start))
+	})
+}
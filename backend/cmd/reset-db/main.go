@@ -62,7 +62,7 @@ func main() {
 
 	// 1. 删除所有表
 	log.Println("\n步骤 1/3: 删除现有表...")
-	err = db.Migrator().DropTable(&database.User{}, &database.CoursePermission{})
+	err = db.Migrator().DropTable(&database.User{}, &database.CoursePermission{}, &database.UserSession{}, &database.RefreshToken{})
 	if err != nil {
 		log.Fatalf("删除表失败: %v", err)
 	}
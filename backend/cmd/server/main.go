@@ -20,6 +20,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/joho/godotenv"
 	"github.com/yjxt/ydms/backend/internal/api"
+	"github.com/yjxt/ydms/backend/internal/auth"
 	"github.com/yjxt/ydms/backend/internal/cache"
 	"github.com/yjxt/ydms/backend/internal/config"
 	"github.com/yjxt/ydms/backend/internal/database"
@@ -78,23 +79,62 @@ func runServer() error {
 	// 解析 JWT 过期时间
 	jwtExpiry, err := time.ParseDuration(cfg.JWT.Expiry)
 	if err != nil {
-		log.Printf("warning: invalid JWT expiry duration '%s', using default 24h", cfg.JWT.Expiry)
-		jwtExpiry = 24 * time.Hour
+		log.Printf("warning: invalid JWT expiry duration '%s', using default 15m", cfg.JWT.Expiry)
+		jwtExpiry = 15 * time.Minute
+	}
+	refreshExpiry, err := time.ParseDuration(cfg.JWT.RefreshExpiry)
+	if err != nil {
+		log.Printf("warning: invalid JWT refresh expiry duration '%s', using default 168h", cfg.JWT.RefreshExpiry)
+		refreshExpiry = 168 * time.Hour
+	}
+	refreshGrace, err := time.ParseDuration(cfg.JWT.RefreshGrace)
+	if err != nil {
+		log.Printf("warning: invalid JWT refresh grace duration '%s', using default %s", cfg.JWT.RefreshGrace, service.DefaultRefreshGracePeriod)
+		refreshGrace = service.DefaultRefreshGracePeriod
 	}
 
 	// 创建服务
-	cacheProvider := cache.NewNoop()
+	cacheProvider, err := newCacheProvider(cfg.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to init cache: %w", err)
+	}
+	ndrOperationTimeouts := make(map[string]time.Duration, len(cfg.NDR.OperationTimeouts))
+	for op, seconds := range cfg.NDR.OperationTimeouts {
+		ndrOperationTimeouts[op] = time.Duration(seconds) * time.Second
+	}
 	ndr := ndrclient.NewClient(ndrclient.NDRConfig{
-		BaseURL: cfg.NDR.BaseURL,
-		APIKey:  cfg.NDR.APIKey,
-		Debug:   cfg.Debug.Traffic,
+		BaseURL:           cfg.NDR.BaseURL,
+		APIKey:            cfg.NDR.APIKey,
+		Debug:             cfg.Debug.Traffic,
+		Timeout:           time.Duration(cfg.NDR.Timeout) * time.Second,
+		OperationTimeouts: ndrOperationTimeouts,
+		Retry: ndrclient.RetryConfig{
+			MaxRetries: cfg.NDR.MaxRetries,
+			BaseDelay:  time.Duration(cfg.NDR.RetryBaseDelayMS) * time.Millisecond,
+			MaxDelay:   time.Duration(cfg.NDR.RetryMaxDelayMS) * time.Millisecond,
+		},
+		// 熔断状态通过 /healthz 报告
+		Breaker: ndrclient.BreakerConfig{
+			FailureThreshold: cfg.NDR.BreakerThreshold,
+			Cooldown:         time.Duration(cfg.NDR.BreakerCooldown) * time.Second,
+		},
 	})
 
 	// 创建认证相关服务
 	userService := service.NewUserService(db)
+	userService.SetRefreshGracePeriod(refreshGrace)
 	svc := service.NewService(cacheProvider, ndr, userService)
-	courseService := service.NewCourseService(db, ndr, userService)
-	permissionService := service.NewPermissionService(db, userService, ndr)
+	// 审计日志：分类/文档/资源的变更由 Service 记录
+	auditService := service.NewAuditService(db)
+	svc.SetAuditService(auditService)
+	// 分类批量移动/复制的操作日志，失败或中断后可继续或撤销
+	svc.SetCategoryBulkJournal(service.NewCategoryBulkJournal(db))
+	// 文档/节点锁：校对签出期间阻止他人更新文档或在节点上触发工作流
+	lockService := service.NewLockService(db)
+	svc.SetLockService(lockService)
+	courseService := service.NewCourseService(db, ndr, userService, cacheProvider)
+	permissionService := service.NewPermissionService(db, userService, ndr, cacheProvider)
+	auditService.SetCourseResolver(permissionService.AuditCourse)
 
 	// 创建服务层
 	apiKeyService := service.NewAPIKeyService(db)
@@ -118,10 +158,45 @@ func runServer() error {
 
 	// 创建 Workflow 服务
 	workflowService := service.NewWorkflowService(db, prefect, ndr, pdmsBaseURL)
+	workflowService.SetLockService(lockService)
+	workflowService.SetCache(cacheProvider)
 	// 确保默认工作流定义存在
 	if err := workflowService.EnsureDefaultWorkflows(context.Background()); err != nil {
 		log.Printf("warning: failed to ensure default workflows: %v", err)
 	}
+	// 后台对账：回调丢失时按 Prefect 的真实状态结束任务
+	reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+	defer stopReconcile()
+	workflowService.StartReconciler(reconcileCtx, time.Duration(cfg.Prefect.ReconcileInterval)*time.Second)
+
+	// 文档类型注册表：加载运行时注册的类型，并定期刷新以同步其他实例的修改
+	documentTypeService := service.NewDocumentTypeService(db, ndr)
+	if err := documentTypeService.Load(context.Background()); err != nil {
+		log.Printf("warning: failed to load document types: %v", err)
+	}
+	documentTypeService.StartRefresh(reconcileCtx, time.Minute)
+
+	// 全文检索：文档写操作后增量刷新索引，并定期与 NDR 对账
+	searchService := service.NewSearchService(db, ndr, permissionService)
+	svc.SetDocumentIndexer(searchService)
+	courseService.SetDocumentIndexer(searchService)
+	searchService.Start(reconcileCtx, time.Duration(cfg.Search.ReconcileInterval)*time.Second)
+
+	// 文档引用索引：写操作增量维护，启动时从 NDR 全量重建一次
+	referenceIndex := service.NewReferenceIndex(db, ndr, permissionService)
+	svc.SetReferenceIndex(referenceIndex)
+	courseService.SetReferenceIndex(referenceIndex)
+	courseService.SetDocumentService(svc)
+	// 改名或彻底删除后未能改写的引用方定期重试
+	svc.StartReferenceRepair(reconcileCtx, time.Minute)
+	go func() {
+		result, err := referenceIndex.Rebuild(reconcileCtx)
+		if err != nil {
+			log.Printf("warning: failed to rebuild reference index: %v", err)
+			return
+		}
+		log.Printf("reference index rebuilt: references=%d dangling=%d", result.References, result.Dangling)
+	}()
 
 	// 创建 Workflow Sync 服务（用于管理 API）
 	workflowSyncService := service.NewWorkflowSyncService(db, prefect, prefect != nil)
@@ -129,6 +204,11 @@ func runServer() error {
 	// 创建批量操作服务
 	batchWorkflowService := service.NewBatchWorkflowService(db, ndr, workflowService)
 	batchSyncService := service.NewBatchSyncService(db, ndr, syncService)
+	batchMigrationService := service.NewBatchMigrationService(db, ndr, svc)
+	// 恢复服务重启前未执行完的批次
+	batchWorkflowService.ResumeUnfinishedBatches(context.Background())
+	batchSyncService.ResumeUnfinishedBatches(context.Background())
+	batchMigrationService.ResumeUnfinishedBatches(context.Background())
 
 	// 创建 handlers
 	headerDefaults := api.HeaderDefaults{
@@ -137,15 +217,23 @@ func runServer() error {
 		AdminKey: cfg.Auth.AdminKey,
 	}
 	handler := api.NewHandler(svc, permissionService, headerDefaults)
-	authHandler := api.NewAuthHandler(userService, cfg.JWT.Secret, jwtExpiry)
+	authHandler := api.NewAuthHandler(userService, cfg.JWT.Secret, jwtExpiry, refreshExpiry)
 	userHandler := api.NewUserHandler(userService)
+	roleHandler := api.NewRoleHandler(service.NewRoleService(db))
+	documentTypeHandler := api.NewDocumentTypeHandler(documentTypeService)
 	courseHandler := api.NewCourseHandler(courseService)
 	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService)
 	assetsHandler := api.NewAssetsHandler(svc, headerDefaults)
-	syncHandler := api.NewSyncHandler(syncService, cfg.Prefect.WebhookSecret, cfg.NDR.APIKey)
+	syncHandler := api.NewSyncHandler(syncService, cfg.NDR.APIKey)
 	workflowHandler := api.NewWorkflowHandler(workflowService, handler)
 	adminWorkflowHandler := api.NewAdminWorkflowHandler(workflowSyncService)
 	batchHandler := api.NewBatchHandler(batchWorkflowService, batchSyncService)
+	migrationHandler := api.NewMigrationHandler(batchMigrationService, permissionService)
+	searchHandler := api.NewSearchHandler(searchService)
+	referenceHandler := api.NewReferenceHandler(referenceIndex)
+	bulkOperationHandler := api.NewBulkOperationHandler(svc)
+	lockHandler := api.NewLockHandler(lockService, permissionService)
+	auditHandler := api.NewAuditHandler(auditService, permissionService)
 
 	// 创建静态资源代理（如果配置了 MinIO URL）
 	var staticProxyHandler *api.StaticProxyHandler
@@ -159,21 +247,44 @@ func runServer() error {
 		}
 	}
 
+	webhookVerifier := auth.NewWebhookVerifier(
+		time.Duration(cfg.Prefect.WebhookMaxSkew)*time.Second,
+		cfg.Prefect.WebhookSecret,
+		cfg.Prefect.WebhookPreviousSecret,
+	)
+	if cfg.Prefect.WebhookLegacySecret {
+		log.Printf("warning: YDMS_PREFECT_WEBHOOK_ALLOW_LEGACY_SECRET is deprecated; unsigned X-Webhook-Secret callbacks are accepted")
+		webhookVerifier.AllowLegacySecret(true)
+	}
+	// 回调去重记录只有在 Redis 中才能跨实例共享
+	if cfg.Cache.Backend == "redis" {
+		webhookVerifier.SetReplayCache(cacheProvider)
+	}
+
 	// 创建路由器（使用新的配置方式）
 	router := api.NewRouterWithConfig(api.RouterConfig{
 		Handler:              handler,
 		AuthHandler:          authHandler,
 		UserHandler:          userHandler,
+		RoleHandler:          roleHandler,
+		DocumentTypeHandler:  documentTypeHandler,
 		CourseHandler:        courseHandler,
 		APIKeyHandler:        apiKeyHandler,
 		AssetsHandler:        assetsHandler,
 		SyncHandler:          syncHandler,
 		WorkflowHandler:      workflowHandler,
 		AdminWorkflowHandler: adminWorkflowHandler,
+		AuditHandler:         auditHandler,
 		BatchHandler:         batchHandler,
+		MigrationHandler:     migrationHandler,
+		SearchHandler:        searchHandler,
+		ReferenceHandler:     referenceHandler,
+		BulkOperationHandler: bulkOperationHandler,
+		LockHandler:          lockHandler,
 		StaticProxyHandler:   staticProxyHandler,
 		JWTSecret:            cfg.JWT.Secret,
 		DB:                   db, // 传递 DB 用于 API Key 验证
+		WebhookVerifier:      webhookVerifier,
 	})
 
 	server := &http.Server{
@@ -193,6 +304,36 @@ func runServer() error {
 	return nil
 }
 
+// newCacheProvider 根据配置创建 NDR 读缓存
+func newCacheProvider(cfg config.CacheConfig) (cache.Provider, error) {
+	ttl := time.Duration(cfg.TTL) * time.Second
+	switch cfg.Backend {
+	case "noop", "none", "off":
+		log.Printf("cache disabled")
+		return cache.NewNoop(), nil
+	case "memory", "":
+		log.Printf("cache backend: memory max_entries=%d ttl=%s", cfg.MaxEntries, ttl)
+		return cache.NewMemory(cfg.MaxEntries, ttl), nil
+	case "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		provider, err := cache.NewRedis(ctx, cache.RedisConfig{
+			Addr:       cfg.RedisAddr,
+			Password:   cfg.RedisPassword,
+			DB:         cfg.RedisDB,
+			KeyPrefix:  cfg.RedisPrefix,
+			DefaultTTL: ttl,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("connect redis %s: %w", cfg.RedisAddr, err)
+		}
+		log.Printf("cache backend: redis addr=%s db=%d ttl=%s", cfg.RedisAddr, cfg.RedisDB, ttl)
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+}
+
 func loadDotEnv() {
 	if err := godotenv.Load(".env"); err != nil {
 		_ = godotenv.Load()
@@ -32,9 +32,9 @@ func (h *AdminWorkflowHandler) getCurrentUser(r *http.Request) (*database.User,
 	return user, nil
 }
 
-// isAdmin checks if the user has admin role.
-func (h *AdminWorkflowHandler) isAdmin(user *database.User) bool {
-	return user.Role == "super_admin" || user.Role == "course_admin"
+// isAdmin checks if the user's role may manage workflow definitions.
+func (h *AdminWorkflowHandler) isAdmin(r *http.Request, user *database.User) bool {
+	return h.syncService.Roles().HasPermission(r.Context(), user.Role, database.PermWorkflowsManage)
 }
 
 // TriggerSync triggers a sync from Prefect.
@@ -46,7 +46,7 @@ func (h *AdminWorkflowHandler) TriggerSync(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if !h.isAdmin(user) {
+	if !h.isAdmin(r, user) {
 		respondError(w, http.StatusForbidden, errors.New("管理员权限不足"))
 		return
 	}
@@ -69,7 +69,7 @@ func (h *AdminWorkflowHandler) GetSyncStatus(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if !h.isAdmin(user) {
+	if !h.isAdmin(r, user) {
 		respondError(w, http.StatusForbidden, errors.New("管理员权限不足"))
 		return
 	}
@@ -87,7 +87,7 @@ func (h *AdminWorkflowHandler) ListWorkflowDefinitions(w http.ResponseWriter, r
 		return
 	}
 
-	if !h.isAdmin(user) {
+	if !h.isAdmin(r, user) {
 		respondError(w, http.StatusForbidden, errors.New("管理员权限不足"))
 		return
 	}
@@ -127,7 +127,7 @@ func (h *AdminWorkflowHandler) UpdateWorkflowDefinition(w http.ResponseWriter, r
 		return
 	}
 
-	if !h.isAdmin(user) {
+	if !h.isAdmin(r, user) {
 		respondError(w, http.StatusForbidden, errors.New("管理员权限不足"))
 		return
 	}
@@ -95,9 +95,9 @@ func (h *APIKeyHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以创建 API Key
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super admin can create API keys"))
+	// 只有拥有 users:manage 权限的角色可以创建 API Key
+	if !h.canManageKeys(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to create API keys"))
 		return
 	}
 
@@ -111,7 +111,7 @@ func (h *APIKeyHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
 	req.CreatedByID = currentUser.ID
 
 	// 创建 API Key
-	resp, err := h.service.CreateAPIKey(req)
+	resp, err := h.service.CreateAPIKey(r.Context(), metaFromRequestContext(r), req)
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err)
 		return
@@ -133,12 +133,12 @@ func (h *APIKeyHandler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	includeDeleted := query.Get("include_deleted") == "true"
 
-	// 非超级管理员只能查看自己的 API Keys
+	// 没有 users:manage 权限时只能查看自己的 API Keys
 	var userID uint
-	if currentUser.Role != "super_admin" {
+	if !h.canManageKeys(r, currentUser) {
 		userID = currentUser.ID
 	} else {
-		// 超级管理员可以通过 user_id 参数过滤
+		// 用户管理员可以通过 user_id 参数过滤
 		if userIDStr := query.Get("user_id"); userIDStr != "" {
 			id, err := strconv.ParseUint(userIDStr, 10, 32)
 			if err == nil {
@@ -174,8 +174,8 @@ func (h *APIKeyHandler) getAPIKey(w http.ResponseWriter, r *http.Request, id uin
 		return
 	}
 
-	// 权限检查：非超级管理员只能查看自己的 API Key
-	if currentUser.Role != "super_admin" && key.UserID != currentUser.ID {
+	// 权限检查：没有 users:manage 权限时只能查看自己的 API Key
+	if !h.canManageKeys(r, currentUser) && key.UserID != currentUser.ID {
 		respondError(w, http.StatusForbidden, errors.New("access denied"))
 		return
 	}
@@ -200,7 +200,7 @@ func (h *APIKeyHandler) updateAPIKey(w http.ResponseWriter, r *http.Request, id
 	}
 
 	// 权限检查
-	if currentUser.Role != "super_admin" && key.UserID != currentUser.ID {
+	if !h.canManageKeys(r, currentUser) && key.UserID != currentUser.ID {
 		respondError(w, http.StatusForbidden, errors.New("access denied"))
 		return
 	}
@@ -224,7 +224,7 @@ func (h *APIKeyHandler) updateAPIKey(w http.ResponseWriter, r *http.Request, id
 		}
 	}
 
-	updatedKey, err := h.service.UpdateAPIKey(id, updates)
+	updatedKey, err := h.service.UpdateAPIKey(r.Context(), metaFromRequestContext(r), id, updates)
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err)
 		return
@@ -255,12 +255,12 @@ func (h *APIKeyHandler) revokeAPIKey(w http.ResponseWriter, r *http.Request, id
 	}
 
 	// 权限检查
-	if currentUser.Role != "super_admin" && key.UserID != currentUser.ID {
+	if !h.canManageKeys(r, currentUser) && key.UserID != currentUser.ID {
 		respondError(w, http.StatusForbidden, errors.New("access denied"))
 		return
 	}
 
-	if err := h.service.RevokeAPIKey(id); err != nil {
+	if err := h.service.RevokeAPIKey(r.Context(), metaFromRequestContext(r), id); err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -279,13 +279,13 @@ func (h *APIKeyHandler) deleteAPIKey(w http.ResponseWriter, r *http.Request, id
 		return
 	}
 
-	// 只有超级管理员可以永久删除
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super admin can permanently delete API keys"))
+	// 只有拥有 users:manage 权限的角色可以永久删除
+	if !h.canManageKeys(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to permanently delete API keys"))
 		return
 	}
 
-	if err := h.service.DeleteAPIKey(id); err != nil {
+	if err := h.service.DeleteAPIKey(r.Context(), metaFromRequestContext(r), id); err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -302,12 +302,12 @@ func (h *APIKeyHandler) getAPIKeyStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 非超级管理员只能查看自己的统计
+	// 没有 users:manage 权限时只能查看自己的统计
 	var userID uint
-	if currentUser.Role != "super_admin" {
+	if !h.canManageKeys(r, currentUser) {
 		userID = currentUser.ID
 	} else {
-		// 超级管理员可以通过 user_id 参数过滤
+		// 用户管理员可以通过 user_id 参数过滤
 		if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
 			id, err := strconv.ParseUint(userIDStr, 10, 32)
 			if err == nil {
@@ -324,3 +324,8 @@ func (h *APIKeyHandler) getAPIKeyStats(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, stats)
 }
+
+// canManageKeys 当前用户能否管理所有用户的 API Key（否则只能访问自己的）
+func (h *APIKeyHandler) canManageKeys(r *http.Request, user *database.User) bool {
+	return h.service.Roles().HasPermission(r.Context(), user.Role, database.PermUsersManage)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// courseAdminAuditResourceTypes 课程管理员可查看的审计目标类型（不含用户与 API Key）
+var courseAdminAuditResourceTypes = []string{
+	service.AuditResourceCategory,
+	service.AuditResourceDocument,
+	service.AuditResourceAsset,
+	service.AuditResourceCourse,
+	service.AuditResourceWorkflowRun,
+	service.AuditResourceWorkflowBatch,
+	service.AuditResourceSyncBatch,
+}
+
+// AuditHandler 审计日志查询处理器
+type AuditHandler struct {
+	auditService      *service.AuditService
+	permissionService *service.PermissionService
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(auditService *service.AuditService, permissionService *service.PermissionService) *AuditHandler {
+	return &AuditHandler{auditService: auditService, permissionService: permissionService}
+}
+
+// ListAuditEvents 查询审计事件
+// GET /api/v1/admin/audit
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	filter, ok := h.parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.auditService.List(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ExportAuditEvents 以 CSV 导出审计事件
+// GET /api/v1/admin/audit/export
+func (h *AuditHandler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	filter, ok := h.parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := h.auditService.ExportCSV(r.Context(), filter, w); err != nil {
+		// 响应头已发送，只能记录错误
+		log.Printf("[audit] export failed: %v", err)
+	}
+}
+
+// parseFilter 校验权限并解析查询参数，失败时已写入响应
+func (h *AuditHandler) parseFilter(w http.ResponseWriter, r *http.Request) (service.AuditFilter, bool) {
+	var filter service.AuditFilter
+
+	user, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return filter, false
+	}
+	// 全局拥有 audit:view 与 courses:all 可查看全部；否则只能查看按课程授权拥有 audit:view 的课程
+	roles := h.auditService.Roles()
+	if !roles.HasPermission(r.Context(), user.Role, database.PermAuditView) ||
+		!roles.HasPermission(r.Context(), user.Role, database.PermCoursesAll) {
+		courses, err := h.permissionService.CoursesWithPermission(r.Context(), user.ID, database.PermAuditView)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return filter, false
+		}
+		if len(courses) == 0 {
+			respondError(w, http.StatusForbidden, errors.New("requires audit:view"))
+			return filter, false
+		}
+		filter.ResourceTypes = courseAdminAuditResourceTypes
+		filter.RootNodeIDs = courses
+	}
+
+	query := r.URL.Query()
+	if v := query.Get("actor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "无效的参数", "actor_id 应为正整数"))
+			return filter, false
+		}
+		actorID := uint(id)
+		filter.ActorID = &actorID
+	}
+	filter.Action = query.Get("action")
+	filter.ResourceType = query.Get("resource_type")
+	filter.ResourceID = query.Get("resource_id")
+	filter.RequestID = query.Get("request_id")
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseAuditTime(raw)
+		if err != nil {
+			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "无效的日期格式", p.name+" 应为 ISO 8601 格式或 YYYY-MM-DD"))
+			return filter, false
+		}
+		*p.dest = &t
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			filter.Limit = n
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			filter.Offset = n
+		}
+	}
+	return filter, true
+}
+
+// parseAuditTime 解析 RFC3339 或 YYYY-MM-DD 格式的时间
+func parseAuditTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
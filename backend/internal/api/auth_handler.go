@@ -13,17 +13,19 @@ import (
 
 // AuthHandler 认证相关 handler
 type AuthHandler struct {
-	userService *service.UserService
-	jwtSecret   string
-	jwtExpiry   time.Duration
+	userService   *service.UserService
+	jwtSecret     string
+	jwtExpiry     time.Duration // access token 有效期
+	refreshExpiry time.Duration // refresh token 有效期
 }
 
 // NewAuthHandler 创建认证 handler
-func NewAuthHandler(userService *service.UserService, jwtSecret string, jwtExpiry time.Duration) *AuthHandler {
+func NewAuthHandler(userService *service.UserService, jwtSecret string, jwtExpiry, refreshExpiry time.Duration) *AuthHandler {
 	return &AuthHandler{
-		userService: userService,
-		jwtSecret:   jwtSecret,
-		jwtExpiry:   jwtExpiry,
+		userService:   userService,
+		jwtSecret:     jwtSecret,
+		jwtExpiry:     jwtExpiry,
+		refreshExpiry: refreshExpiry,
 	}
 }
 
@@ -59,21 +61,64 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 生成 token
-	token, err := h.userService.GenerateToken(user, h.jwtSecret, h.jwtExpiry)
+	// 生成 access token 和 refresh token
+	pair, err := h.userService.IssueTokenPair(user, h.jwtSecret, h.jwtExpiry, h.refreshExpiry)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"token": token,
+	writeJSON(w, http.StatusOK, tokenPairResponse(pair, user))
+}
+
+// Refresh 使用 refresh token 换取新的 access token（refresh token 同时轮换）
+// POST /api/v1/auth/refresh
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		respondError(w, http.StatusBadRequest, errors.New("refresh_token is required"))
+		return
+	}
+
+	pair, user, err := h.userService.RefreshTokenPair(req.RefreshToken, h.jwtSecret, h.jwtExpiry, h.refreshExpiry)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			respondError(w, http.StatusUnauthorized, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenPairResponse(pair, user))
+}
+
+// tokenPairResponse 构造登录/刷新响应
+func tokenPairResponse(pair *service.TokenPair, user *database.User) map[string]interface{} {
+	return map[string]interface{}{
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_at": pair.RefreshExpiresAt,
 		"user": map[string]interface{}{
 			"id":       user.ID,
 			"username": user.Username,
 			"role":     user.Role,
 		},
-	})
+	}
 }
 
 // Logout 用户登出
@@ -84,8 +129,14 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// JWT 是无状态的，logout 主要由前端处理（删除 token）
-	// 这里返回成功即可
+	// 吊销当前 token 对应的会话（API Key 请求没有 claims，直接返回成功）
+	if claims, ok := r.Context().Value(auth.ClaimsContextKey).(*auth.Claims); ok {
+		if err := h.userService.RevokeSession(claims.ID, database.SessionRevokedLogout); err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "logged out successfully",
 	})
@@ -162,12 +213,22 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新密码
-	err = h.userService.UpdatePassword(user.ID, req.NewPassword)
+	err = h.userService.UpdatePassword(r.Context(), metaFromRequestContext(r), user.ID, req.NewPassword)
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
 
+	// 吊销其他会话，保留当前登录
+	currentTokenID := ""
+	if claims, ok := r.Context().Value(auth.ClaimsContextKey).(*auth.Claims); ok {
+		currentTokenID = claims.ID
+	}
+	if _, err := h.userService.RevokeUserSessions(user.ID, currentTokenID, database.SessionRevokedPasswordChanged); err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "password changed successfully",
 	})
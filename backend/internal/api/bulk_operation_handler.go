@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// BulkOperationHandler 分类批量移动/复制操作日志的管理接口
+type BulkOperationHandler struct {
+	service *service.Service
+}
+
+// NewBulkOperationHandler 创建批量操作日志处理器
+func NewBulkOperationHandler(svc *service.Service) *BulkOperationHandler {
+	return &BulkOperationHandler{service: svc}
+}
+
+// ListOperations 列出批量操作（超级管理员可见全部，其他用户仅可见自己发起的操作）
+// GET /api/v1/admin/bulk-operations?kind=&status=&limit=&offset=
+func (h *BulkOperationHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if user, ok := r.Context().Value(auth.UserContextKey).(*database.User); !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return
+	}
+
+	query := r.URL.Query()
+	filter := service.CategoryBulkOperationFilter{Kind: query.Get("kind"), Status: query.Get("status")}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			respondError(w, http.StatusBadRequest, errors.New("invalid offset"))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	ops, total, err := h.service.ListCategoryBulkOperations(r.Context(), metaFromRequestContext(r), filter)
+	if err != nil {
+		respondBulkOperationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"operations": ops,
+		"total":      total,
+	})
+}
+
+// OperationRoutes 处理单个批量操作
+// GET  /api/v1/admin/bulk-operations/:id
+// POST /api/v1/admin/bulk-operations/:id/resume
+// POST /api/v1/admin/bulk-operations/:id/undo
+func (h *BulkOperationHandler) OperationRoutes(w http.ResponseWriter, r *http.Request) {
+	if user, ok := r.Context().Value(auth.UserContextKey).(*database.User); !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/bulk-operations/"), "/"), "/")
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || id == 0 {
+		respondError(w, http.StatusBadRequest, errors.New("invalid bulk operation id"))
+		return
+	}
+	meta := metaFromRequestContext(r)
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		detail, err := h.service.GetCategoryBulkOperation(r.Context(), meta, uint(id))
+		if err != nil {
+			respondBulkOperationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, detail)
+	case len(parts) == 2 && parts[1] == "resume" && r.Method == http.MethodPost:
+		detail, err := h.service.ResumeCategoryBulkOperation(r.Context(), meta, uint(id))
+		respondBulkOperationResult(w, detail, err)
+	case len(parts) == 2 && parts[1] == "undo" && r.Method == http.MethodPost:
+		detail, err := h.service.UndoCategoryBulkOperation(r.Context(), meta, uint(id))
+		respondBulkOperationResult(w, detail, err)
+	default:
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// respondBulkOperationResult 继续/撤销执行失败时仍返回操作的最新状态
+func respondBulkOperationResult(w http.ResponseWriter, detail *service.CategoryBulkOperationDetail, err error) {
+	if err != nil && detail == nil {
+		respondBulkOperationError(w, err)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"error":     err.Error(),
+			"operation": detail,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"operation": detail})
+}
+
+func respondBulkOperationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryBulkOperationNotFound):
+		respondError(w, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrCategoryBulkOperationForbidden):
+		respondError(w, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrCategoryBulkOperationNotRecoverable):
+		respondError(w, http.StatusConflict, err)
+	case errors.Is(err, service.ErrCategoryBulkJournalDisabled):
+		respondError(w, http.StatusServiceUnavailable, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
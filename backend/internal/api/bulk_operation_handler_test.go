@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// flakyNDR 在指定节点移动、排序、创建或删除时注入失败
+type flakyNDR struct {
+	*inMemoryNDR
+	failMoveID     int64
+	failReorderCnt int
+	failCreateName string
+	failDelete     bool
+}
+
+func (f *flakyNDR) CreateNode(ctx context.Context, meta ndrclient.RequestMeta, body ndrclient.NodeCreate) (ndrclient.Node, error) {
+	if f.failCreateName != "" && body.Name == f.failCreateName {
+		return ndrclient.Node{}, fmt.Errorf("injected create failure for %s", body.Name)
+	}
+	return f.inMemoryNDR.CreateNode(ctx, meta, body)
+}
+
+func (f *flakyNDR) DeleteNode(ctx context.Context, meta ndrclient.RequestMeta, id int64) error {
+	if f.failDelete {
+		return fmt.Errorf("injected delete failure for node %d", id)
+	}
+	return f.inMemoryNDR.DeleteNode(ctx, meta, id)
+}
+
+func (f *flakyNDR) UpdateNode(ctx context.Context, meta ndrclient.RequestMeta, id int64, body ndrclient.NodeUpdate) (ndrclient.Node, error) {
+	if id == f.failMoveID && body.ParentPath != nil {
+		return ndrclient.Node{}, fmt.Errorf("injected move failure for node %d", id)
+	}
+	return f.inMemoryNDR.UpdateNode(ctx, meta, id, body)
+}
+
+func (f *flakyNDR) ReorderNodes(ctx context.Context, meta ndrclient.RequestMeta, payload ndrclient.NodeReorderPayload) ([]ndrclient.Node, error) {
+	if f.failReorderCnt > 0 {
+		f.failReorderCnt--
+		return nil, fmt.Errorf("injected reorder failure")
+	}
+	return f.inMemoryNDR.ReorderNodes(ctx, meta, payload)
+}
+
+func TestBulkMoveJournalDryRunResumeAndUndo(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.CategoryBulkOperation{}, &database.BatchItem{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	ndr := &flakyNDR{inMemoryNDR: newInMemoryNDR()}
+	svc := service.NewService(cache.NewNoop(), ndr, nil)
+	svc.SetCategoryBulkJournal(service.NewCategoryBulkJournal(db))
+	router := NewRouter(NewHandler(svc, nil, HeaderDefaults{}))
+	ops := NewBulkOperationHandler(svc)
+
+	source := createCategory(t, router, `{"name":"Source"}`)
+	moveA := createCategory(t, router, fmt.Sprintf(`{"name":"MoveA","parent_id":%d}`, source.ID))
+	moveB := createCategory(t, router, fmt.Sprintf(`{"name":"MoveB","parent_id":%d}`, source.ID))
+	target := createCategory(t, router, `{"name":"Target"}`)
+	keep := createCategory(t, router, fmt.Sprintf(`{"name":"Keep","parent_id":%d}`, target.ID))
+
+	payload := fmt.Sprintf(`{"source_ids":[%d,%d],"target_parent_id":%d,"insert_before_id":%d}`, moveA.ID, moveB.ID, target.ID, keep.ID)
+	bulkMove := func(body string) *httptest.ResponseRecorder {
+		req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/categories/bulk/move", strings.NewReader(body)), nil)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	operation := func(method, path string) (*httptest.ResponseRecorder, service.CategoryBulkOperationDetail) {
+		req := withTestUser(httptest.NewRequest(method, "/api/v1/admin/bulk-operations/"+path, nil), nil)
+		rec := httptest.NewRecorder()
+		ops.OperationRoutes(rec, req)
+		var resp struct {
+			Operation service.CategoryBulkOperationDetail `json:"operation"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp.Operation
+	}
+	childIDs := func(parentID int64) []int64 {
+		return append([]int64(nil), ndr.childOrder[parentID]...)
+	}
+
+	// dry_run 只返回计划，不改动树
+	rec := bulkMove(strings.Replace(payload, "{", `{"dry_run":true,`, 1))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for dry run, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	var dry struct {
+		DryRun bool                     `json:"dry_run"`
+		Plan   service.CategoryBulkPlan `json:"plan"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&dry); err != nil {
+		t.Fatalf("decode dry run error: %v", err)
+	}
+	if !dry.DryRun || len(dry.Plan.Changes) != 2 || len(dry.Plan.After) != 3 {
+		t.Fatalf("unexpected plan: %+v", dry)
+	}
+	if dry.Plan.After[0].ID != moveA.ID || dry.Plan.After[1].ID != moveB.ID || dry.Plan.After[2].ID != keep.ID {
+		t.Fatalf("unexpected plan order: %+v", dry.Plan.After)
+	}
+	if subtree := dry.Plan.Changes[0].Subtree; len(subtree) != 1 || subtree[0].ID != moveA.ID || dry.Plan.Changes[0].Nodes != 1 {
+		t.Fatalf("unexpected plan subtree: %+v", dry.Plan.Changes[0])
+	}
+	if got := childIDs(source.ID); len(got) != 2 {
+		t.Fatalf("dry run must not move nodes, source children=%v", got)
+	}
+
+	// 移动失败：自动回滚，操作记为 rolled_back，不可再继续
+	ndr.failMoveID = moveB.ID
+	if rec := bulkMove(payload); rec.Code == http.StatusOK {
+		t.Fatalf("expected bulk move to fail")
+	}
+	ndr.failMoveID = 0
+	var rolledBack database.CategoryBulkOperation
+	if err := db.Order("id DESC").First(&rolledBack).Error; err != nil {
+		t.Fatalf("load operation: %v", err)
+	}
+	if rolledBack.Status != database.BatchStatusRolledBack {
+		t.Fatalf("expected rolled_back, got %s", rolledBack.Status)
+	}
+	if got := childIDs(source.ID); fmt.Sprint(got) != fmt.Sprint([]int64{moveA.ID, moveB.ID}) {
+		t.Fatalf("unexpected source order after rollback: %v", got)
+	}
+	if rec, _ := operation(http.MethodPost, fmt.Sprint(rolledBack.ID)+"/resume"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 resuming a rolled back operation, got %d", rec.Code)
+	}
+
+	// 排序失败：节点已移动，操作记为 failed
+	failedOperation := func() string {
+		t.Helper()
+		ndr.failReorderCnt = 1
+		if rec := bulkMove(payload); rec.Code == http.StatusOK {
+			t.Fatalf("expected bulk move to fail")
+		}
+		listRec := httptest.NewRecorder()
+		ops.ListOperations(listRec, withTestUser(httptest.NewRequest(http.MethodGet, "/api/v1/admin/bulk-operations?status=failed", nil), nil))
+		var list struct {
+			Operations []database.CategoryBulkOperation `json:"operations"`
+			Total      int64                            `json:"total"`
+		}
+		if err := json.NewDecoder(listRec.Body).Decode(&list); err != nil {
+			t.Fatalf("decode list error: %v", err)
+		}
+		if list.Total != 1 || list.Operations[0].Kind != service.CategoryBulkMove {
+			t.Fatalf("expected one failed move operation, got %+v", list)
+		}
+		return fmt.Sprint(list.Operations[0].ID)
+	}
+
+	// 撤销：节点回到原父节点并恢复原顺序
+	rec, detail := operation(http.MethodPost, failedOperation()+"/undo")
+	if rec.Code != http.StatusOK || detail.Status != database.BatchStatusRolledBack {
+		t.Fatalf("expected undo to roll back, got %d status=%s", rec.Code, detail.Status)
+	}
+	for _, item := range detail.Items {
+		if item.Status != database.BatchItemStatusReverted {
+			t.Fatalf("expected item %d reverted, got %s", item.NodeID, item.Status)
+		}
+	}
+	if got := childIDs(source.ID); fmt.Sprint(got) != fmt.Sprint([]int64{moveA.ID, moveB.ID}) {
+		t.Fatalf("unexpected source order after undo: %v", got)
+	}
+	if got := childIDs(target.ID); fmt.Sprint(got) != fmt.Sprint([]int64{keep.ID}) {
+		t.Fatalf("unexpected target children after undo: %v", got)
+	}
+
+	// 继续：跳过已移动的项并完成排序
+	opID := failedOperation()
+	rec, detail = operation(http.MethodPost, opID+"/resume")
+	if rec.Code != http.StatusOK || detail.Status != database.BatchStatusCompleted {
+		t.Fatalf("expected resume to complete, got %d status=%s", rec.Code, detail.Status)
+	}
+	if got := childIDs(target.ID); fmt.Sprint(got) != fmt.Sprint([]int64{moveA.ID, moveB.ID, keep.ID}) {
+		t.Fatalf("unexpected target order after resume: %v", got)
+	}
+	if rec, _ := operation(http.MethodPost, opID+"/undo"); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 undoing a completed operation, got %d", rec.Code)
+	}
+	if rec, _ := operation(http.MethodGet, "999"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown operation, got %d", rec.Code)
+	}
+}
+
+func TestBulkCopyJournalsRootBeforeSubtree(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.CategoryBulkOperation{}, &database.BatchItem{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	ndr := &flakyNDR{inMemoryNDR: newInMemoryNDR()}
+	svc := service.NewService(cache.NewNoop(), ndr, nil)
+	svc.SetCategoryBulkJournal(service.NewCategoryBulkJournal(db))
+	router := NewRouter(NewHandler(svc, nil, HeaderDefaults{}))
+	ops := NewBulkOperationHandler(svc)
+
+	source := createCategory(t, router, `{"name":"Source"}`)
+	first := createCategory(t, router, fmt.Sprintf(`{"name":"First","parent_id":%d}`, source.ID))
+	createCategory(t, router, fmt.Sprintf(`{"name":"Leaf","parent_id":%d}`, first.ID))
+	createCategory(t, router, fmt.Sprintf(`{"name":"Second","parent_id":%d}`, source.ID))
+	target := createCategory(t, router, `{"name":"Target"}`)
+	payload := fmt.Sprintf(`{"source_ids":[%d],"target_parent_id":%d}`, source.ID, target.ID)
+	bulkCopy := func(body string) *httptest.ResponseRecorder {
+		req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/categories/bulk/copy", strings.NewReader(body)), nil)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	liveChildren := func(parentID int64) []string {
+		var names []string
+		for _, id := range ndr.childOrder[parentID] {
+			if node := ndr.nodes[id]; node.DeletedAt == nil {
+				names = append(names, node.Name)
+			}
+		}
+		return names
+	}
+
+	// dry_run 列出整个子树
+	rec := bulkCopy(strings.Replace(payload, "{", `{"dry_run":true,`, 1))
+	var dry struct {
+		Plan service.CategoryBulkPlan `json:"plan"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&dry); err != nil {
+		t.Fatalf("decode dry run error: %v", err)
+	}
+	var names []string
+	for _, node := range dry.Plan.Changes[0].Subtree {
+		names = append(names, fmt.Sprintf("%s@%d", node.Name, node.Depth))
+	}
+	if fmt.Sprint(names) != "[Source@0 First@1 Leaf@2 Second@1]" || dry.Plan.Changes[0].Nodes != 4 {
+		t.Fatalf("unexpected plan subtree: %v", names)
+	}
+
+	// 复制子树中途失败且回滚也失败：根节点已记录在 created_id 中
+	ndr.failCreateName = "Second"
+	ndr.failDelete = true
+	if rec := bulkCopy(payload); rec.Code == http.StatusOK {
+		t.Fatalf("expected bulk copy to fail")
+	}
+	ndr.failCreateName = ""
+	ndr.failDelete = false
+	var item database.BatchItem
+	if err := db.First(&item).Error; err != nil {
+		t.Fatalf("load item: %v", err)
+	}
+	rootID := int64(item.Result["created_id"].(float64))
+	if item.Status != database.BatchItemStatusFailed || rootID == 0 {
+		t.Fatalf("expected failed item with created_id, got %+v", item)
+	}
+	if got := liveChildren(rootID); fmt.Sprint(got) != "[First]" {
+		t.Fatalf("expected partial copy, got %v", got)
+	}
+
+	// 继续：复用已创建的根节点，不在目标下再建一份
+	req := withTestUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/admin/bulk-operations/%d/resume", item.BatchRefID), nil), nil)
+	rec = httptest.NewRecorder()
+	ops.OperationRoutes(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected resume to succeed, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := ndr.childOrder[target.ID]; fmt.Sprint(got) != fmt.Sprint([]int64{rootID}) {
+		t.Fatalf("expected target to contain only the reused root %d, got %v", rootID, got)
+	}
+	if got := liveChildren(rootID); fmt.Sprint(got) != "[First Second]" {
+		t.Fatalf("unexpected copied children after resume: %v", got)
+	}
+}
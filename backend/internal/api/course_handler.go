@@ -3,15 +3,24 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/yjxt/ydms/backend/internal/auth"
 	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
 	"github.com/yjxt/ydms/backend/internal/service"
 )
 
+// courseImportMemoryLimit 解析导入表单时保留在内存中的上限，超出部分写入临时文件
+const courseImportMemoryLimit = 32 << 20
+
+// courseImportMaxBytes 导入请求体的大小上限
+const courseImportMaxBytes = 1 << 30
+
 // CourseHandler 课程管理 handler
 type CourseHandler struct {
 	courseService *service.CourseService
@@ -39,9 +48,11 @@ func (h *CourseHandler) ListCourses(w http.ResponseWriter, r *http.Request) {
 
 	// 获取请求元数据
 	meta := service.RequestMeta{
-		APIKey:    r.Header.Get("x-api-key"),
-		UserID:    user.Username,
-		RequestID: r.Header.Get("x-request-id"),
+		APIKey:        r.Header.Get("x-api-key"),
+		UserID:        user.Username,
+		RequestID:     r.Header.Get("x-request-id"),
+		UserRole:      user.Role,
+		UserIDNumeric: user.ID,
 	}
 
 	// 列出课程（根据用户权限过滤）
@@ -71,9 +82,9 @@ func (h *CourseHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以创建课程
-	if user.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super admin can create courses"))
+	// 只有拥有 courses:manage 权限的角色可以创建课程
+	if !h.courseService.Roles().HasPermission(r.Context(), user.Role, database.PermCoursesManage) {
+		respondError(w, http.StatusForbidden, errors.New("requires courses:manage to create courses"))
 		return
 	}
 
@@ -86,9 +97,11 @@ func (h *CourseHandler) CreateCourse(w http.ResponseWriter, r *http.Request) {
 
 	// 获取请求元数据
 	meta := service.RequestMeta{
-		APIKey:    r.Header.Get("x-api-key"),
-		UserID:    user.Username,
-		RequestID: r.Header.Get("x-request-id"),
+		APIKey:        r.Header.Get("x-api-key"),
+		UserID:        user.Username,
+		RequestID:     r.Header.Get("x-request-id"),
+		UserRole:      user.Role,
+		UserIDNumeric: user.ID,
 	}
 
 	// 创建课程
@@ -118,9 +131,9 @@ func (h *CourseHandler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以删除课程
-	if user.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super admin can delete courses"))
+	// 只有拥有 courses:manage 权限的角色可以删除课程
+	if !h.courseService.Roles().HasPermission(r.Context(), user.Role, database.PermCoursesManage) {
+		respondError(w, http.StatusForbidden, errors.New("requires courses:manage to delete courses"))
 		return
 	}
 
@@ -134,9 +147,11 @@ func (h *CourseHandler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 
 	// 获取请求元数据
 	meta := service.RequestMeta{
-		APIKey:    r.Header.Get("x-api-key"),
-		UserID:    user.Username,
-		RequestID: r.Header.Get("x-request-id"),
+		APIKey:        r.Header.Get("x-api-key"),
+		UserID:        user.Username,
+		RequestID:     r.Header.Get("x-request-id"),
+		UserRole:      user.Role,
+		UserIDNumeric: user.ID,
 	}
 
 	// 删除课程
@@ -150,3 +165,96 @@ func (h *CourseHandler) DeleteCourse(w http.ResponseWriter, r *http.Request) {
 		"message": "course deleted successfully",
 	})
 }
+
+// ExportCourse 将课程导出为 zip 归档
+// GET /api/v1/courses/:id/export
+func (h *CourseHandler) ExportCourse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !requireRolePermissions(w, r, h.courseService.Roles(), "export courses", database.PermCoursesManage, database.PermCoursesAll) {
+		return
+	}
+
+	courseIDStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/courses/"), "/export")
+	courseID, err := strconv.ParseInt(courseIDStr, 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errors.New("invalid course id"))
+		return
+	}
+
+	// 先收集分类树与文档，这一步失败时仍可返回 JSON 错误；资产随后直接流式写入响应
+	meta := metaFromRequestContext(r)
+	manifest, err := h.courseService.PrepareCourseExport(r.Context(), meta, courseID)
+	if err != nil {
+		respondCourseArchiveError(w, err)
+		return
+	}
+
+	filename := manifest.Root.Slug
+	if filename == "" {
+		filename = strconv.FormatInt(courseID, 10)
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "course-"+filename+".zip"))
+	w.WriteHeader(http.StatusOK)
+	if err := h.courseService.WriteCourseArchive(r.Context(), meta, manifest, w); err != nil {
+		// 响应头已发送，只能记录错误；客户端收到的归档不完整
+		log.Printf("[course] export of course %d failed: %v", courseID, err)
+	}
+}
+
+// ImportCourse 从 zip 归档导入课程，归档通过 multipart 表单的 file 字段上传
+// POST /api/v1/courses/import
+func (h *CourseHandler) ImportCourse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !requireRolePermissions(w, r, h.courseService.Roles(), "import courses", database.PermCoursesManage, database.PermCoursesAll) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, courseImportMaxBytes)
+	if err := r.ParseMultipartForm(courseImportMemoryLimit); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("course archive exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		respondError(w, http.StatusBadRequest, fmt.Errorf("invalid multipart form: %w", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errors.New("file is required"))
+		return
+	}
+	defer file.Close()
+
+	result, err := h.courseService.ImportCourse(r.Context(), metaFromRequestContext(r), file, header.Size, service.CourseImportRequest{
+		Name: r.FormValue("name"),
+		Slug: r.FormValue("slug"),
+	})
+	if err != nil {
+		respondCourseArchiveError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func respondCourseArchiveError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	var ndrErr *ndrclient.Error
+	switch {
+	case errors.As(err, &vErr):
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "课程归档校验失败", vErr.Error()))
+	case errors.As(err, &ndrErr):
+		// 上游 NDR 的错误不直接透传状态码（其 401/404 等并非调用方的问题）
+		respondError(w, http.StatusBadGateway, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// DocumentTypeHandler 文档类型注册表处理器
+type DocumentTypeHandler struct {
+	documentTypes *service.DocumentTypeService
+}
+
+// NewDocumentTypeHandler 创建文档类型处理器
+func NewDocumentTypeHandler(documentTypes *service.DocumentTypeService) *DocumentTypeHandler {
+	return &DocumentTypeHandler{documentTypes: documentTypes}
+}
+
+// DocumentTypes 处理 /api/v1/document-types
+// GET 列出所有类型（含模板与主题）；POST 注册新类型（需要 roles:manage）
+func (h *DocumentTypeHandler) DocumentTypes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"document_types": h.documentTypes.List(r.Context()),
+		})
+	case http.MethodPost:
+		if !requireRolePermissions(w, r, h.documentTypes.Roles(), "register document types", database.PermRolesManage) {
+			return
+		}
+		var req service.DocumentTypeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		docType, err := h.documentTypes.Register(r.Context(), metaFromRequestContext(r), req)
+		if err != nil {
+			respondDocumentTypeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"document_type": docType})
+	default:
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// DocumentTypeRoutes 处理 /api/v1/document-types/{id}
+func (h *DocumentTypeHandler) DocumentTypeRoutes(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/document-types/"), "/")
+	if id == "" {
+		h.DocumentTypes(w, r)
+		return
+	}
+	if strings.Contains(id, "/") {
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		docType, err := h.documentTypes.Get(r.Context(), id)
+		if err != nil {
+			respondDocumentTypeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"document_type": docType})
+	case http.MethodPut:
+		if !requireRolePermissions(w, r, h.documentTypes.Roles(), "update document types", database.PermRolesManage) {
+			return
+		}
+		var req service.DocumentTypeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		docType, err := h.documentTypes.Update(r.Context(), metaFromRequestContext(r), id, req)
+		if err != nil {
+			respondDocumentTypeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"document_type": docType})
+	case http.MethodDelete:
+		if !requireRolePermissions(w, r, h.documentTypes.Roles(), "delete document types", database.PermRolesManage) {
+			return
+		}
+		if err := h.documentTypes.Delete(r.Context(), metaFromRequestContext(r), id); err != nil {
+			respondDocumentTypeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "document type deleted successfully"})
+	default:
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func respondDocumentTypeError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	switch {
+	case errors.As(err, &vErr):
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "文档类型校验失败", vErr.Error()))
+	case errors.Is(err, service.ErrDocumentTypeNotFound):
+		respondError(w, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrDocumentTypeBuiltIn):
+		respondError(w, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrDocumentTypeExists), errors.Is(err, service.ErrDocumentTypeInUse):
+		respondError(w, http.StatusConflict, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
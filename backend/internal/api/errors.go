@@ -4,6 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/service"
 )
 
 // ErrorCode 定义错误代码，用于前端识别和处理
@@ -34,10 +40,11 @@ const (
 
 // APIError 统一的 API 错误结构
 type APIError struct {
-	Code       ErrorCode `json:"code"`
-	Message    string    `json:"message"`
-	Details    string    `json:"details,omitempty"`
-	StatusCode int       `json:"-"` // 不序列化到 JSON
+	Code       ErrorCode          `json:"code"`
+	Message    string             `json:"message"`
+	Details    string             `json:"details,omitempty"`
+	Fields     []jsonschema.Error `json:"fields,omitempty"` // 字段级错误（含行号）
+	StatusCode int                `json:"-"`                // 不序列化到 JSON
 }
 
 func (e *APIError) Error() string {
@@ -126,6 +133,54 @@ func ErrInvalidDocumentContent(reason string) *APIError {
 	)
 }
 
+// ErrDocumentContentSchema 文档内容未通过类型 Schema 校验，fields 中列出各字段错误
+func ErrDocumentContentSchema(err *service.DocumentContentError) *APIError {
+	apiErr := ErrInvalidDocumentContent(fmt.Sprintf("内容不符合文档类型 %s 的结构要求", err.DocType))
+	apiErr.Fields = err.Issues
+	return apiErr
+}
+
+// ErrRequestValidation 请求参数未通过校验；来自 JSON Schema 时 fields 中列出各字段错误
+func ErrRequestValidation(err *service.ValidationError) *APIError {
+	apiErr := NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求参数错误", err.Error())
+	apiErr.Fields = err.Fields
+	return apiErr
+}
+
+// ErrDocumentStillReferenced 文档仍被其他文档引用，调用方要求阻止删除
+func ErrDocumentStillReferenced(err *service.DocumentReferencedError) *APIError {
+	ids := make([]string, len(err.ReferencedBy))
+	for i, referrer := range err.ReferencedBy {
+		ids[i] = strconv.FormatInt(referrer.DocumentID, 10)
+	}
+	return NewAPIError(
+		ErrCodeConflict,
+		http.StatusConflict,
+		"文档仍被其他文档引用",
+		fmt.Sprintf("引用方文档: %s", strings.Join(ids, ", ")),
+	)
+}
+
+// ErrDocumentVersionConflict 文档已被他人更新，更新所基于的版本已过期
+func ErrDocumentVersionConflict(err *service.DocumentVersionConflictError) *APIError {
+	return NewAPIError(
+		ErrCodeConflict,
+		http.StatusConflict,
+		"文档已被其他人修改",
+		fmt.Sprintf("基于版本 %d 的修改与当前版本 %d 冲突，请合并后重试", err.BaseVersion, err.CurrentVersion),
+	)
+}
+
+// ErrResourceLocked 文档或节点已被他人锁定
+func ErrResourceLocked(err *service.ResourceLockedError) *APIError {
+	return NewAPIError(
+		ErrCodeConflict,
+		http.StatusConflict,
+		"资源已被他人锁定",
+		err.Error(),
+	)
+}
+
 // ErrInvalidMetadata 创建无效元数据错误
 func ErrInvalidMetadata(reason string) *APIError {
 	return NewAPIError(
@@ -167,17 +222,20 @@ var (
 )
 
 // ErrInsufficientPermission 创建权限不足错误
-func ErrInsufficientPermission(requiredRoles []string, currentRole string) *APIError {
+func ErrInsufficientPermission(permission string, currentRole string) *APIError {
 	return NewAPIError(
 		ErrCodeForbidden,
 		http.StatusForbidden,
 		"权限不足",
-		fmt.Sprintf("当前角色 '%s' 无权执行此操作，需要角色: %v", currentRole, requiredRoles),
+		fmt.Sprintf("当前角色 '%s' 无权执行此操作，需要权限: %s", currentRole, permission),
 	)
 }
 
-// 上游服务错误
+// 上游服务错误；NDR 熔断期间返回 503
 func WrapUpstreamError(err error) *APIError {
+	if errors.Is(err, ndrclient.ErrCircuitOpen) {
+		return NewAPIError(ErrCodeUpstream, http.StatusServiceUnavailable, "上游服务暂不可用", "NDR 连续请求失败，已暂停访问，请稍后重试")
+	}
 	return NewAPIError(
 		ErrCodeUpstream,
 		http.StatusBadGateway,
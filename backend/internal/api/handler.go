@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -42,9 +43,18 @@ func NewHandler(svc *service.Service, permSvc *service.PermissionService, defaul
 	}
 }
 
-// Health reports basic liveness.
+// Health reports basic liveness and the NDR circuit breaker state.
+// While the breaker is not closed the status is "degraded", but the code stays 200
+// so an upstream outage does not get this service restarted.
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	resp := map[string]interface{}{"status": "ok"}
+	if upstream, ok := h.service.UpstreamStatus(); ok {
+		resp["ndr"] = upstream
+		if upstream.State != ndrclient.BreakerClosed {
+			resp["status"] = "degraded"
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Ping returns a hello world message.
@@ -161,25 +171,30 @@ func (h *Handler) Documents(w http.ResponseWriter, r *http.Request) {
 		}
 		writeJSON(w, http.StatusOK, page)
 	case http.MethodPost:
-		// 权限检查：校对员不能创建文档
-		_, httpErr := h.requireNotProofreader(r, "create documents")
-		if httpErr != nil {
-			respondError(w, httpErr.code, httpErr.message)
-			return
-		}
-
 		var payload service.DocumentCreateRequest
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
 			return
 		}
+
+		// 权限检查：按目标节点所属课程判断；不绑定节点的文档不属于任何课程，需全局角色拥有创建权限
+		var httpErr *httpError
+		if payload.NodeID != nil {
+			_, httpErr = h.requireNodePermission(r, *payload.NodeID, database.PermDocumentCreate, "create documents")
+		} else {
+			_, httpErr = h.requireGlobalPermission(r, database.PermDocumentCreate, "create documents")
+		}
+		if httpErr != nil {
+			respondError(w, httpErr.code, httpErr.message)
+			return
+		}
 		if strings.TrimSpace(payload.Title) == "" {
 			respondAPIError(w, ErrDocumentTitleRequired)
 			return
 		}
 		doc, err := h.service.CreateDocument(r.Context(), meta, payload)
 		if err != nil {
-			respondAPIError(w, WrapUpstreamError(err))
+			respondDocumentWriteError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusCreated, doc)
@@ -240,6 +255,11 @@ func (h *Handler) DocumentRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if parts[1] == "merge" {
+		h.mergeDocument(w, r, meta, id)
+		return
+	}
+
 	if parts[1] == "copy" {
 		// POST /api/v1/documents/{id}/copy - copy document
 		h.copyDocument(w, r, meta, id)
@@ -323,41 +343,143 @@ func (h *Handler) handleDocumentItem(w http.ResponseWriter, r *http.Request, met
 }
 
 func (h *Handler) updateDocument(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
+	// 权限检查：需要在文档所属课程内拥有编辑权限
+	if _, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentEdit, "edit documents"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	var payload service.DocumentUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
 		return
 	}
+	// If-Match 与 base_version 等价，请求体中的 base_version 优先
+	if payload.BaseVersion == nil {
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			version, ok := parseVersionETag(ifMatch)
+			if !ok {
+				respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", "invalid If-Match header"))
+				return
+			}
+			payload.BaseVersion = &version
+		}
+	}
 	doc, err := h.service.UpdateDocument(r.Context(), meta, id, payload)
 	if err != nil {
-		respondAPIError(w, WrapUpstreamError(err))
+		respondDocumentWriteError(w, err)
 		return
 	}
+	setVersionETag(w, doc)
 	writeJSON(w, http.StatusOK, doc)
 }
 
+// respondDocumentWriteError 内容结构校验失败返回 400 及字段错误，版本冲突或被他人锁定返回 409，其余按上游错误处理
+func respondDocumentWriteError(w http.ResponseWriter, err error) {
+	var contentErr *service.DocumentContentError
+	if errors.As(err, &contentErr) {
+		respondAPIError(w, ErrDocumentContentSchema(contentErr))
+		return
+	}
+	var conflictErr *service.DocumentVersionConflictError
+	if errors.As(err, &conflictErr) {
+		respondDocumentVersionConflict(w, conflictErr)
+		return
+	}
+	var lockedErr *service.ResourceLockedError
+	if errors.As(err, &lockedErr) {
+		respondResourceLocked(w, lockedErr)
+		return
+	}
+	var vErr *service.ValidationError
+	if errors.As(err, &vErr) {
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "文档校验失败", vErr.Error()))
+		return
+	}
+	respondAPIError(w, WrapUpstreamError(err))
+}
+
+// respondDocumentVersionConflict 返回 409，附带当前版本号与当前文档，便于客户端合并
+func respondDocumentVersionConflict(w http.ResponseWriter, err *service.DocumentVersionConflictError) {
+	setVersionETag(w, err.Current)
+	writeJSON(w, http.StatusConflict, struct {
+		*APIError
+		BaseVersion    int                `json:"base_version"`
+		CurrentVersion int                `json:"current_version"`
+		Current        ndrclient.Document `json:"current"`
+	}{
+		APIError:       ErrDocumentVersionConflict(err),
+		BaseVersion:    err.BaseVersion,
+		CurrentVersion: err.CurrentVersion,
+		Current:        err.Current,
+	})
+}
+
+// setVersionETag 以文档版本号作为 ETag，供后续更新的 If-Match 使用
+func setVersionETag(w http.ResponseWriter, doc ndrclient.Document) {
+	if doc.Version != nil {
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(*doc.Version)))
+	}
+}
+
+// parseVersionETag 解析 If-Match 中的版本号，接受 "5"、W/"5" 与 5
+func parseVersionETag(value string) (int, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
 func (h *Handler) getDocument(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
 	doc, err := h.service.GetDocument(r.Context(), meta, id)
 	if err != nil {
 		respondAPIError(w, WrapUpstreamError(err))
 		return
 	}
+	setVersionETag(w, doc)
 	writeJSON(w, http.StatusOK, doc)
 }
 
+// mergeDocument 三方合并：以 base_version 为共同祖先，合并调用方的内容与文档当前内容
+// POST /api/v1/documents/{id}/merge
+func (h *Handler) mergeDocument(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	// 合并可能直接保存结果，需要文档的编辑权限
+	if _, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentEdit, "merge documents"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
+	var payload service.DocumentMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
+		return
+	}
+	result, err := h.service.MergeDocument(r.Context(), meta, id, payload)
+	if err != nil {
+		respondDocumentWriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (h *Handler) deleteDocument(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
-	// 权限检查：校对员不能删除文档
-	_, httpErr := h.requireNotProofreader(r, "delete documents")
+	// 权限检查：需要在文档所属课程内拥有删除权限
+	_, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentDelete, "delete documents")
 	if httpErr != nil {
 		respondError(w, httpErr.code, httpErr.message)
 		return
 	}
 
-	if err := h.service.DeleteDocument(r.Context(), meta, id); err != nil {
-		respondAPIError(w, WrapUpstreamError(err))
+	result, err := h.service.DeleteDocumentWithOptions(r.Context(), meta, id, documentRemovalOptions(r))
+	if err != nil {
+		respondDocumentRemovalError(w, err)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	respondDocumentRemoval(w, result)
 }
 
 func (h *Handler) restoreDocument(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
@@ -365,6 +487,11 @@ func (h *Handler) restoreDocument(w http.ResponseWriter, r *http.Request, meta s
 		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 		return
 	}
+	// 从回收站恢复与删除使用同一权限
+	if _, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentDelete, "restore documents"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	doc, err := h.service.RestoreDocument(r.Context(), meta, id)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -378,11 +505,49 @@ func (h *Handler) purgeDocument(w http.ResponseWriter, r *http.Request, meta ser
 		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 		return
 	}
-	if err := h.service.PurgeDocument(r.Context(), meta, id); err != nil {
-		respondError(w, http.StatusBadGateway, err)
+	if _, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentPurge, "purge documents"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	result, err := h.service.PurgeDocumentWithOptions(r.Context(), meta, id, documentRemovalOptions(r))
+	if err != nil {
+		respondDocumentRemovalError(w, err)
+		return
+	}
+	respondDocumentRemoval(w, result)
+}
+
+// documentRemovalOptions 读取删除选项：?block_if_referenced=true 时文档仍被引用则拒绝删除
+func documentRemovalOptions(r *http.Request) service.DocumentRemovalOptions {
+	return service.DocumentRemovalOptions{
+		BlockIfReferenced: r.URL.Query().Get("block_if_referenced") == "true",
+	}
+}
+
+// respondDocumentRemoval 没有引用时返回 204；仍被引用时返回 200 并附带警告与引用方列表
+func respondDocumentRemoval(w http.ResponseWriter, result *service.DocumentRemovalResult) {
+	if len(result.ReferencedBy) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	body := map[string]interface{}{
+		"warning":       fmt.Sprintf("document is still referenced by %d document(s)", len(result.ReferencedBy)),
+		"referenced_by": result.ReferencedBy,
+	}
+	// 彻底删除时未能从引用方移除的引用已排队重试
+	if len(result.PendingReferenceUpdates) > 0 {
+		body["pending_reference_updates"] = result.PendingReferenceUpdates
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func respondDocumentRemovalError(w http.ResponseWriter, err error) {
+	var refErr *service.DocumentReferencedError
+	if errors.As(err, &refErr) {
+		respondAPIError(w, ErrDocumentStillReferenced(refErr))
+		return
+	}
+	respondAPIError(w, WrapUpstreamError(err))
 }
 
 func (h *Handler) getDocumentBindingStatus(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
@@ -417,19 +582,26 @@ func (h *Handler) copyDocument(w http.ResponseWriter, r *http.Request, meta serv
 		return
 	}
 
-	// 权限检查：校对员不能复制文档
-	_, httpErr := h.requireNotProofreader(r, "copy documents")
-	if httpErr != nil {
-		respondError(w, httpErr.code, httpErr.message)
-		return
-	}
-
 	var req service.DocumentCopyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
 		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
 		return
 	}
 
+	// 权限检查：需要能查看源文档，并在目标节点（默认源文档所在课程）内拥有创建权限
+	_, httpErr := h.requireDocumentPermission(r, id, database.PermDocumentView, "copy documents")
+	if httpErr == nil {
+		if req.NodeID != nil {
+			_, httpErr = h.requireNodePermission(r, *req.NodeID, database.PermDocumentCreate, "copy documents")
+		} else {
+			_, httpErr = h.requireDocumentPermission(r, id, database.PermDocumentCreate, "copy documents")
+		}
+	}
+	if httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
+
 	resp, err := h.service.CopyDocument(r.Context(), meta, id, req)
 	if err != nil {
 		// 使用 errors.Is 检查哨兵错误（业务校验错误）
@@ -483,6 +655,13 @@ func (h *Handler) reorderDocuments(w http.ResponseWriter, r *http.Request, meta
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	// 参与排序的每个文档都需要在其所属课程内拥有编辑权限
+	for _, docID := range payload.OrderedIDs {
+		if _, httpErr := h.requireDocumentPermission(r, docID, database.PermDocumentEdit, "reorder documents"); httpErr != nil {
+			respondError(w, httpErr.code, httpErr.message)
+			return
+		}
+	}
 	docs, err := h.service.ReorderDocuments(r.Context(), meta, payload)
 	if err != nil {
 		if errors.Is(err, service.ErrInvalidDocumentReorder) {
@@ -579,7 +758,17 @@ func (h *Handler) getDocumentVersionDiff(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	diff, err := h.service.GetDocumentVersionDiff(r.Context(), meta, docID, fromVersion, toVersion)
+	var diff any
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", service.DiffModeText:
+		diff, err = h.service.GetDocumentVersionDiff(r.Context(), meta, docID, fromVersion, toVersion)
+	case service.DiffModeStructured:
+		// 按内容格式解析后比较（YAML 树、HTML DOM、Markdown 块）
+		diff, err = h.service.GetDocumentStructuredDiff(r.Context(), meta, docID, fromVersion, toVersion)
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Errorf("invalid diff mode %q", mode))
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
 		return
@@ -593,8 +782,21 @@ func (h *Handler) restoreDocumentVersion(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	_, httpErr := h.requireScopedPermission(r, database.PermDocumentRestoreVersion, "restore document versions", func(user *database.User) (bool, error) {
+		return h.permissionService.CanRestoreDocumentVersion(r.Context(), user.ID, user.Role, docID)
+	})
+	if httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
+
 	doc, err := h.service.RestoreDocumentVersion(r.Context(), meta, docID, versionNum)
 	if err != nil {
+		var lockedErr *service.ResourceLockedError
+		if errors.As(err, &lockedErr) {
+			respondResourceLocked(w, lockedErr)
+			return
+		}
 		respondError(w, http.StatusBadGateway, err)
 		return
 	}
@@ -608,8 +810,8 @@ func (h *Handler) addDocumentReference(w http.ResponseWriter, r *http.Request, m
 		return
 	}
 
-	// 权限检查：校对员不能添加引用（因为会修改 metadata）
-	_, httpErr := h.requireNotProofreader(r, "add document references")
+	// 权限检查：添加引用会修改 metadata，需要在文档所属课程内拥有引用权限
+	_, httpErr := h.requireDocumentPermission(r, docID, database.PermDocumentReference, "add document references")
 	if httpErr != nil {
 		respondError(w, httpErr.code, httpErr.message)
 		return
@@ -644,8 +846,8 @@ func (h *Handler) removeDocumentReference(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 权限检查：校对员不能删除引用
-	_, httpErr := h.requireNotProofreader(r, "remove document references")
+	// 权限检查：需要在文档所属课程内拥有引用权限
+	_, httpErr := h.requireDocumentPermission(r, docID, database.PermDocumentReference, "remove document references")
 	if httpErr != nil {
 		respondError(w, httpErr.code, httpErr.message)
 		return
@@ -727,6 +929,15 @@ func (h *Handler) NodeRoutes(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 			return
 		}
+		// 绑定相当于把文档加入目标课程：需要能编辑该文档，并在目标节点所属课程内拥有创建权限
+		_, httpErr := h.requireDocumentPermission(r, docID, database.PermDocumentEdit, "bind documents")
+		if httpErr == nil {
+			_, httpErr = h.requireNodePermission(r, id, database.PermDocumentCreate, "bind documents")
+		}
+		if httpErr != nil {
+			respondError(w, httpErr.code, httpErr.message)
+			return
+		}
 		if err := h.service.BindDocument(r.Context(), meta, id, docID); err != nil {
 			respondError(w, http.StatusBadGateway, err)
 			return
@@ -746,6 +957,10 @@ func (h *Handler) NodeRoutes(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 			return
 		}
+		if _, httpErr := h.requireNodePermission(r, id, database.PermDocumentEdit, "unbind documents"); httpErr != nil {
+			respondError(w, httpErr.code, httpErr.message)
+			return
+		}
 		if err := h.service.UnbindDocument(r.Context(), meta, id, docID); err != nil {
 			respondError(w, http.StatusBadGateway, err)
 			return
@@ -829,18 +1044,23 @@ func (h *Handler) handleCategoryItem(w http.ResponseWriter, r *http.Request, met
 }
 
 func (h *Handler) createCategory(w http.ResponseWriter, r *http.Request, meta service.RequestMeta) {
-	// 权限检查：校对员不能创建分类（节点）
-	_, httpErr := h.requireNotProofreader(r, "create categories")
-	if httpErr != nil {
-		respondError(w, httpErr.code, httpErr.message)
-		return
-	}
-
 	var payload service.CategoryCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
 		return
 	}
+
+	// 权限检查：按父节点所属课程判断；根节点不属于已有课程，按全局角色判断
+	var httpErr *httpError
+	if payload.ParentID != nil {
+		_, httpErr = h.requireNodePermission(r, *payload.ParentID, database.PermNodeCreate, "create categories")
+	} else {
+		_, httpErr = h.requireGlobalPermission(r, database.PermNodeCreate, "create categories")
+	}
+	if httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	category, err := h.service.CreateCategory(r.Context(), meta, payload)
 	if err != nil {
 		respondAPIError(w, WrapUpstreamError(err))
@@ -860,8 +1080,8 @@ func (h *Handler) getCategory(w http.ResponseWriter, r *http.Request, meta servi
 }
 
 func (h *Handler) updateCategory(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
-	// 权限检查：校对员不能编辑分类（节点）
-	_, httpErr := h.requireNotProofreader(r, "edit categories")
+	// 权限检查：需要在节点所属课程内拥有编辑权限
+	_, httpErr := h.requireNodePermission(r, id, database.PermNodeEdit, "edit categories")
 	if httpErr != nil {
 		respondError(w, httpErr.code, httpErr.message)
 		return
@@ -881,8 +1101,8 @@ func (h *Handler) updateCategory(w http.ResponseWriter, r *http.Request, meta se
 }
 
 func (h *Handler) deleteCategory(w http.ResponseWriter, r *http.Request, meta service.RequestMeta, id int64) {
-	// 权限检查：校对员不能删除分类（节点）
-	_, httpErr := h.requireNotProofreader(r, "delete categories")
+	// 权限检查：需要在节点所属课程内拥有删除权限
+	_, httpErr := h.requireNodePermission(r, id, database.PermNodeDelete, "delete categories")
 	if httpErr != nil {
 		respondError(w, httpErr.code, httpErr.message)
 		return
@@ -901,7 +1121,7 @@ func (h *Handler) deleteCategory(w http.ResponseWriter, r *http.Request, meta se
 		case errors.Is(err, service.ErrInvalidAdminPassword):
 			respondAPIError(w, ErrInvalidAdminPassword)
 		case errors.Is(err, service.ErrForceDeleteForbidden):
-			respondAPIError(w, ErrInsufficientPermission([]string{"super_admin"}, meta.UserRole))
+			respondAPIError(w, ErrInsufficientPermission(database.PermNodePurge, meta.UserRole))
 		default:
 			respondAPIError(w, WrapUpstreamError(err))
 		}
@@ -915,6 +1135,11 @@ func (h *Handler) restoreCategory(w http.ResponseWriter, r *http.Request, meta s
 		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 		return
 	}
+	// 从回收站恢复与删除使用同一权限
+	if _, httpErr := h.requireNodePermission(r, id, database.PermNodeDelete, "restore categories"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	category, err := h.service.RestoreCategory(r.Context(), meta, id)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -933,6 +1158,10 @@ func (h *Handler) moveCategory(w http.ResponseWriter, r *http.Request, meta serv
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	if httpErr := h.requireCategoryMove(r, id, payload.ParentSpecified, payload.NewParentID); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	category, err := h.service.MoveCategory(r.Context(), meta, id, payload)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -965,6 +1194,17 @@ func (h *Handler) reorderCategories(w http.ResponseWriter, r *http.Request, meta
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	// 同级排序按父节点所属课程检查；根层级（课程之间）的排序需要 courses:all
+	var httpErr *httpError
+	if payload.ParentID != nil {
+		_, httpErr = h.requireNodePermission(r, *payload.ParentID, database.PermNodeMove, "reorder categories")
+	} else {
+		_, httpErr = h.requireGlobalPermission(r, database.PermCoursesAll, "reorder courses")
+	}
+	if httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	categories, err := h.service.ReorderCategories(r.Context(), meta, payload)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -991,6 +1231,10 @@ func (h *Handler) purgeCategory(w http.ResponseWriter, r *http.Request, meta ser
 		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
 		return
 	}
+	if _, httpErr := h.requireNodePermission(r, id, database.PermNodePurge, "purge categories"); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	if err := h.service.PurgeCategory(r.Context(), meta, id); err != nil {
 		respondError(w, http.StatusBadGateway, err)
 		return
@@ -1008,6 +1252,10 @@ func (h *Handler) repositionCategory(w http.ResponseWriter, r *http.Request, met
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	if httpErr := h.requireCategoryMove(r, id, payload.ParentSpecified, payload.NewParentID); httpErr != nil {
+		respondError(w, httpErr.code, httpErr.message)
+		return
+	}
 	result, err := h.service.RepositionCategory(r.Context(), meta, id, payload)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -1112,6 +1360,15 @@ func (h *Handler) bulkCopyCategories(w http.ResponseWriter, r *http.Request, met
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	if payload.DryRun || r.URL.Query().Get("dry_run") == "true" {
+		plan, err := h.service.PlanBulkCopyCategories(r.Context(), meta, payload)
+		if err != nil {
+			respondBulkPlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dry_run": true, "plan": plan})
+		return
+	}
 	items, err := h.service.BulkCopyCategories(r.Context(), meta, payload)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -1130,6 +1387,15 @@ func (h *Handler) bulkMoveCategories(w http.ResponseWriter, r *http.Request, met
 		respondError(w, http.StatusBadRequest, err)
 		return
 	}
+	if payload.DryRun || r.URL.Query().Get("dry_run") == "true" {
+		plan, err := h.service.PlanBulkMoveCategories(r.Context(), meta, payload)
+		if err != nil {
+			respondBulkPlanError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"dry_run": true, "plan": plan})
+		return
+	}
 	items, err := h.service.BulkMoveCategories(r.Context(), meta, payload)
 	if err != nil {
 		respondError(w, http.StatusBadGateway, err)
@@ -1138,6 +1404,15 @@ func (h *Handler) bulkMoveCategories(w http.ResponseWriter, r *http.Request, met
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
+func respondBulkPlanError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	if errors.As(err, &vErr) {
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "批量操作校验失败", vErr.Error()))
+		return
+	}
+	respondError(w, http.StatusBadGateway, err)
+}
+
 func cloneQuery(values url.Values) url.Values {
 	if values == nil {
 		return nil
@@ -30,6 +30,7 @@ func NewBatchHandler(batchWorkflowSvc *service.BatchWorkflowService, batchSyncSv
 // /api/v1/nodes/{nodeId}/workflows/batch/preview - POST 预览
 // /api/v1/nodes/{nodeId}/workflows/batch/execute - POST 执行
 // /api/v1/workflows/batches/{batchId} - GET 查询状态
+// /api/v1/workflows/batches/{batchId}/cancel - POST 取消
 // /api/v1/workflows/batches - GET 列表
 func (h *BatchHandler) BatchWorkflowRoutes(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
@@ -49,6 +50,16 @@ func (h *BatchHandler) BatchWorkflowRoutes(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
+		// /api/v1/workflows/batches/{batchId}/cancel - 取消
+		if batchID, ok := strings.CutSuffix(relPath, "/cancel"); ok {
+			if r.Method == http.MethodPost {
+				h.cancelBatchWorkflow(w, r, batchID)
+				return
+			}
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
 		// /api/v1/workflows/batches/{batchId} - 查询状态
 		if r.Method == http.MethodGet {
 			h.getBatchWorkflowStatus(w, r, relPath)
@@ -142,6 +153,11 @@ func (h *BatchHandler) executeBatchWorkflow(w http.ResponseWriter, r *http.Reque
 
 	result, err := h.batchWorkflowService.ExecuteBatchWorkflow(r.Context(), meta, nodeID, req)
 	if err != nil {
+		var vErr *service.ValidationError
+		if errors.As(err, &vErr) {
+			respondAPIError(w, ErrRequestValidation(vErr))
+			return
+		}
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -164,6 +180,21 @@ func (h *BatchHandler) getBatchWorkflowStatus(w http.ResponseWriter, r *http.Req
 	writeJSON(w, http.StatusOK, result)
 }
 
+// cancelBatchWorkflow 取消批量工作流
+func (h *BatchHandler) cancelBatchWorkflow(w http.ResponseWriter, r *http.Request, batchID string) {
+	meta := metaFromRequestContext(r)
+	if err := h.batchWorkflowService.CancelBatchWorkflow(r.Context(), meta, batchID); err != nil {
+		respondBatchCancelError(w, err)
+		return
+	}
+	result, err := h.batchWorkflowService.GetBatchWorkflowStatus(r.Context(), batchID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 // listBatchWorkflows 列出批量工作流
 func (h *BatchHandler) listBatchWorkflows(w http.ResponseWriter, r *http.Request) {
 	meta := metaFromRequestContext(r)
@@ -201,6 +232,7 @@ func (h *BatchHandler) listBatchWorkflows(w http.ResponseWriter, r *http.Request
 // /api/v1/nodes/{nodeId}/sync/batch/preview - POST 预览
 // /api/v1/nodes/{nodeId}/sync/batch/execute - POST 执行
 // /api/v1/sync/batches/{batchId} - GET 查询状态
+// /api/v1/sync/batches/{batchId}/cancel - POST 取消
 // /api/v1/sync/batches - GET 列表
 func (h *BatchHandler) BatchSyncRoutes(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
@@ -220,6 +252,16 @@ func (h *BatchHandler) BatchSyncRoutes(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// /api/v1/sync/batches/{batchId}/cancel - 取消
+		if batchID, ok := strings.CutSuffix(relPath, "/cancel"); ok {
+			if r.Method == http.MethodPost {
+				h.cancelBatchSync(w, r, batchID)
+				return
+			}
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
 		// /api/v1/sync/batches/{batchId} - 查询状态
 		if r.Method == http.MethodGet {
 			h.getBatchSyncStatus(w, r, relPath)
@@ -325,6 +367,21 @@ func (h *BatchHandler) getBatchSyncStatus(w http.ResponseWriter, r *http.Request
 	writeJSON(w, http.StatusOK, result)
 }
 
+// cancelBatchSync 取消批量同步
+func (h *BatchHandler) cancelBatchSync(w http.ResponseWriter, r *http.Request, batchID string) {
+	meta := metaFromRequestContext(r)
+	if err := h.batchSyncService.CancelBatchSync(r.Context(), meta, batchID); err != nil {
+		respondBatchCancelError(w, err)
+		return
+	}
+	result, err := h.batchSyncService.GetBatchSyncStatus(r.Context(), batchID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 // listBatchSyncs 列出批量同步
 func (h *BatchHandler) listBatchSyncs(w http.ResponseWriter, r *http.Request) {
 	meta := metaFromRequestContext(r)
@@ -358,6 +415,20 @@ func (h *BatchHandler) listBatchSyncs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// respondBatchCancelError 将取消批次的错误映射为 HTTP 状态码
+func respondBatchCancelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBatchNotFound):
+		respondError(w, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrBatchForbidden):
+		respondError(w, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrBatchFinished):
+		respondError(w, http.StatusConflict, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
+
 // metaFromRequestContext 从请求上下文中提取 RequestMeta
 // 这个函数假设认证中间件已经设置了上下文值
 func metaFromRequestContext(r *http.Request) service.RequestMeta {
@@ -377,6 +448,7 @@ func metaFromRequestContext(r *http.Request) service.RequestMeta {
 	if adminKey := r.Header.Get("X-Admin-Key"); adminKey != "" {
 		meta.AdminKey = adminKey
 	}
+	meta.RequestID = r.Header.Get("x-request-id")
 
 	return meta
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -68,7 +69,7 @@ func TestCategoriesEndpoints(t *testing.T) {
 	}
 
 	reorderPayload := fmt.Sprintf(`{"parent_id":%d,"ordered_ids":[%d]}`, root.ID, child.ID)
-	reorderReq := httptest.NewRequest(http.MethodPost, "/api/v1/categories/reorder", strings.NewReader(reorderPayload))
+	reorderReq := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/categories/reorder", strings.NewReader(reorderPayload)), nil)
 	reorderReq.Header.Set("Content-Type", "application/json")
 	reorderRec := httptest.NewRecorder()
 	router.ServeHTTP(reorderRec, reorderReq)
@@ -105,7 +106,7 @@ func TestCategoriesEndpoints(t *testing.T) {
 		t.Fatalf("unexpected trash response %+v", trashed)
 	}
 
-	restoreReq := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/categories/%d/restore", child.ID), nil)
+	restoreReq := withTestUser(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/categories/%d/restore", child.ID), nil), nil)
 	restoreRec := httptest.NewRecorder()
 	router.ServeHTTP(restoreRec, restoreReq)
 	if restoreRec.Code != http.StatusOK {
@@ -141,7 +142,7 @@ func TestCategoriesEndpoints(t *testing.T) {
 		t.Fatalf("expected status 204 for second delete, got %d", deleteAgainRec.Code)
 	}
 
-	purgeReq := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d/purge", child.ID), nil)
+	purgeReq := withTestUser(httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d/purge", child.ID), nil), nil)
 	purgeRec := httptest.NewRecorder()
 	router.ServeHTTP(purgeRec, purgeReq)
 	if purgeRec.Code != http.StatusNoContent {
@@ -391,7 +392,7 @@ func TestCategoryRepositionEndpoint(t *testing.T) {
 	child := createCategory(t, router, fmt.Sprintf(`{"name":"Child","parent_id":%d}`, root.ID))
 
 	payload := fmt.Sprintf(`{"new_parent_id":%d,"ordered_ids":[%d,%d]}`, targetParent.ID, existing.ID, child.ID)
-	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/reposition", child.ID), strings.NewReader(payload))
+	req := withTestUser(httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/reposition", child.ID), strings.NewReader(payload)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1198,6 +1199,30 @@ func (f *inMemoryNDR) GetNodeByPath(_ context.Context, _ ndrclient.RequestMeta,
 	return ndrclient.Node{}, fmt.Errorf("node not found by path: %s", path)
 }
 
+func (f *inMemoryNDR) ListNodesAll(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) ([]ndrclient.Node, error) {
+	return ndrclient.Collect(ndrclient.StreamNodes(ctx, f, meta, params))
+}
+
+func (f *inMemoryNDR) ListDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamDocuments(ctx, f, meta, query))
+}
+
+func (f *inMemoryNDR) ListNodeDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamNodeDocuments(ctx, f, meta, id, query))
+}
+
+func (f *inMemoryNDR) StreamNodes(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) iter.Seq2[ndrclient.Node, error] {
+	return ndrclient.StreamNodes(ctx, f, meta, params)
+}
+
+func (f *inMemoryNDR) StreamDocuments(ctx context.Context, meta ndrclient.RequestMeta, query url.Values) iter.Seq2[ndrclient.Document, error] {
+	return ndrclient.StreamDocuments(ctx, f, meta, query)
+}
+
+func (f *inMemoryNDR) StreamNodeDocuments(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) iter.Seq2[ndrclient.Document, error] {
+	return ndrclient.StreamNodeDocuments(ctx, f, meta, id, query)
+}
+
 func (f *inMemoryNDR) ListNodeDocumentsByPath(ctx context.Context, meta ndrclient.RequestMeta, path string, query url.Values) (ndrclient.DocumentsPage, error) {
 	// 先通过路径找到节点
 	node, err := f.GetNodeByPath(ctx, meta, path, ndrclient.GetNodeOptions{})
@@ -1440,7 +1465,7 @@ func TestDocumentReorderEndpoint(t *testing.T) {
 
 	// Test reordering documents
 	payload := fmt.Sprintf(`{"ordered_ids":[%d,%d]}`, doc2.ID, doc1.ID)
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload))
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1481,7 +1506,7 @@ func TestDocumentReorderEndpoint_EmptyOrderedIDs(t *testing.T) {
 	router := NewRouter(handler)
 
 	payload := `{"ordered_ids":[]}`
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload))
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1497,7 +1522,7 @@ func TestDocumentReorderEndpoint_InvalidJSON(t *testing.T) {
 	handler := NewHandler(svc, nil, HeaderDefaults{})
 	router := NewRouter(handler)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader("{"))
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader("{")), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1513,7 +1538,7 @@ func TestDocumentReorderEndpoint_NotFound(t *testing.T) {
 	handler := NewHandler(svc, nil, HeaderDefaults{})
 	router := NewRouter(handler)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(`{"ordered_ids":[999]}`))
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(`{"ordered_ids":[999]}`)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1536,7 +1561,7 @@ func TestDocumentReorderEndpoint_BadRequest(t *testing.T) {
 	}
 
 	payload := fmt.Sprintf(`{"ordered_ids":[%d,%d]}`, doc.ID, doc.ID)
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload))
+	req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/documents/reorder", strings.NewReader(payload)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -1575,6 +1600,27 @@ func TestDeleteDocumentEndpoint(t *testing.T) {
 	}
 }
 
+func TestMergeDocumentEndpointRequiresEdit(t *testing.T) {
+	ndr := newInMemoryNDR()
+	svc := service.NewService(cache.NewNoop(), ndr, nil)
+	router := NewRouter(NewHandler(svc, nil, HeaderDefaults{}))
+
+	doc, err := ndr.CreateDocument(context.Background(), ndrclient.RequestMeta{}, ndrclient.DocumentCreate{Title: "Doc"})
+	if err != nil {
+		t.Fatalf("create document error: %v", err)
+	}
+
+	url := fmt.Sprintf("/api/v1/documents/%d/merge", doc.ID)
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"base_version":1,"content":{"format":"markdown","data":"x"},"apply":true}`))
+	req = withTestUser(req, &database.User{ID: 2, Username: "viewer", Role: "viewer"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without document:edit, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
 func TestRestoreDocumentEndpoint(t *testing.T) {
 	ndr := newInMemoryNDR()
 	svc := service.NewService(cache.NewNoop(), ndr, nil)
@@ -1590,7 +1636,7 @@ func TestRestoreDocumentEndpoint(t *testing.T) {
 	}
 
 	url := fmt.Sprintf("/api/v1/documents/%d/restore", doc.ID)
-	req := httptest.NewRequest(http.MethodPost, url, nil)
+	req := withTestUser(httptest.NewRequest(http.MethodPost, url, nil), nil)
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 
@@ -1625,7 +1671,7 @@ func TestPurgeDocumentEndpoint(t *testing.T) {
 	}
 
 	url := fmt.Sprintf("/api/v1/documents/%d/purge", doc.ID)
-	req := httptest.NewRequest(http.MethodDelete, url, nil)
+	req := withTestUser(httptest.NewRequest(http.MethodDelete, url, nil), nil)
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 
@@ -1750,7 +1796,7 @@ func TestDocumentUpdateWithTypeAndPosition(t *testing.T) {
 
 	// Update the document
 	payload := `{"title":"Updated Title","type":"dictation_v1","position":3,"content":{"format":"yaml","data":"word: 单词"}}`
-	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/documents/%d", doc.ID), strings.NewReader(payload))
+	req := withTestUser(httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/documents/%d", doc.ID), strings.NewReader(payload)), nil)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
@@ -172,6 +172,7 @@ func (h *WorkflowHandler) triggerWorkflow(w http.ResponseWriter, r *http.Request
 	var params struct {
 		Parameters map[string]interface{} `json:"parameters"`
 		RetryOfID  *uint                  `json:"retry_of_id"`
+		Force      bool                   `json:"force"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err.Error() != "EOF" {
 		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
@@ -183,13 +184,23 @@ func (h *WorkflowHandler) triggerWorkflow(w http.ResponseWriter, r *http.Request
 		WorkflowKey: workflowKey,
 		Parameters:  params.Parameters,
 		RetryOfID:   params.RetryOfID,
+		Force:       params.Force,
 	}
 
 	resp, err := h.workflowService.TriggerWorkflow(r.Context(), meta, req)
 	if err != nil {
 		var vErr *service.ValidationError
 		if errors.As(err, &vErr) {
-			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求参数错误", vErr.Error()))
+			respondAPIError(w, ErrRequestValidation(vErr))
+			return
+		}
+		var lockedErr *service.ResourceLockedError
+		if errors.As(err, &lockedErr) {
+			respondResourceLocked(w, lockedErr)
+			return
+		}
+		if errors.Is(err, service.ErrLockForbidden) {
+			respondError(w, http.StatusForbidden, err)
 			return
 		}
 		respondAPIError(w, WrapUpstreamError(err))
@@ -282,7 +293,7 @@ func (h *WorkflowHandler) getWorkflowRun(w http.ResponseWriter, r *http.Request,
 
 // cancelWorkflowRun handles POST /api/v1/workflows/runs/{runId}/cancel
 func (h *WorkflowHandler) cancelWorkflowRun(w http.ResponseWriter, r *http.Request, runID uint) {
-	err := h.workflowService.CancelWorkflowRun(r.Context(), runID)
+	err := h.workflowService.CancelWorkflowRun(r.Context(), h.handler.metaFromRequest(r), runID)
 	if err != nil {
 		if errors.Is(err, service.ErrWorkflowRunNotFound) {
 			respondError(w, http.StatusNotFound, err)
@@ -305,7 +316,7 @@ func (h *WorkflowHandler) cancelWorkflowRun(w http.ResponseWriter, r *http.Reque
 
 // forceTerminateWorkflowRun handles POST /api/v1/workflows/runs/{runId}/force-terminate
 func (h *WorkflowHandler) forceTerminateWorkflowRun(w http.ResponseWriter, r *http.Request, runID uint) {
-	err := h.workflowService.ForceTerminateWorkflowRun(r.Context(), runID)
+	err := h.workflowService.ForceTerminateWorkflowRun(r.Context(), h.handler.metaFromRequest(r), runID)
 	if err != nil {
 		if errors.Is(err, service.ErrWorkflowRunNotFound) {
 			respondError(w, http.StatusNotFound, err)
@@ -390,6 +401,7 @@ func (h *WorkflowHandler) triggerDocumentWorkflow(w http.ResponseWriter, r *http
 	var params struct {
 		Parameters map[string]interface{} `json:"parameters"`
 		RetryOfID  *uint                  `json:"retry_of_id"`
+		Force      bool                   `json:"force"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && err.Error() != "EOF" {
 		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
@@ -401,13 +413,23 @@ func (h *WorkflowHandler) triggerDocumentWorkflow(w http.ResponseWriter, r *http
 		WorkflowKey: workflowKey,
 		Parameters:  params.Parameters,
 		RetryOfID:   params.RetryOfID,
+		Force:       params.Force,
 	}
 
 	resp, err := h.workflowService.TriggerDocumentWorkflow(r.Context(), meta, req)
 	if err != nil {
 		var vErr *service.ValidationError
 		if errors.As(err, &vErr) {
-			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求参数错误", vErr.Error()))
+			respondAPIError(w, ErrRequestValidation(vErr))
+			return
+		}
+		var lockedErr *service.ResourceLockedError
+		if errors.As(err, &lockedErr) {
+			respondResourceLocked(w, lockedErr)
+			return
+		}
+		if errors.Is(err, service.ErrLockForbidden) {
+			respondError(w, http.StatusForbidden, err)
 			return
 		}
 		respondAPIError(w, WrapUpstreamError(err))
@@ -495,7 +517,7 @@ func (h *WorkflowHandler) cleanupWorkflowRuns(w http.ResponseWriter, r *http.Req
 	// force_cleanup_active: 强制清理所有 pending/running 任务
 	params.ForceCleanupActive = r.URL.Query().Get("force_cleanup_active") == "true"
 
-	resp, err := h.workflowService.CleanupWorkflowRuns(r.Context(), params)
+	resp, err := h.workflowService.CleanupWorkflowRuns(r.Context(), h.handler.metaFromRequest(r), params)
 	if err != nil {
 		var vErr *service.ValidationError
 		if errors.As(err, &vErr) {
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// lockResourceTypes 路径中的资源名与锁资源类型的对应关系
+var lockResourceTypes = map[string]string{
+	"documents": database.LockResourceDocument,
+	"nodes":     database.LockResourceNode,
+}
+
+// LockHandler 文档/节点锁（校对签出）接口
+type LockHandler struct {
+	locks             *service.LockService
+	permissionService *service.PermissionService
+}
+
+// NewLockHandler 创建锁处理器
+func NewLockHandler(locks *service.LockService, permSvc *service.PermissionService) *LockHandler {
+	return &LockHandler{locks: locks, permissionService: permSvc}
+}
+
+// LockRoutes 签出、续期与释放
+// GET    /api/v1/locks/{documents|nodes}/:id
+// POST   /api/v1/locks/{documents|nodes}/:id            签出（已持有时续期）
+// POST   /api/v1/locks/{documents|nodes}/:id/heartbeat  心跳续期
+// DELETE /api/v1/locks/{documents|nodes}/:id            释放
+func (h *LockHandler) LockRoutes(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/locks/"), "/"), "/")
+	resourceType, ok := lockResourceTypes[parts[0]]
+	if !ok || len(parts) < 2 || len(parts) > 3 {
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, errors.New("invalid resource id"))
+		return
+	}
+	meta := metaFromRequestContext(r)
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		lock, err := h.locks.Get(r.Context(), resourceType, id)
+		if err != nil {
+			respondLockError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"lock": lock})
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		if !h.canLock(w, r, user, resourceType, id) {
+			return
+		}
+		var req service.LockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err.Error() != "EOF" {
+			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求格式错误", err.Error()))
+			return
+		}
+		lock, err := h.locks.Acquire(r.Context(), meta, resourceType, id, req)
+		if err != nil {
+			respondLockError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"lock": lock})
+	case len(parts) == 3 && parts[2] == "heartbeat" && r.Method == http.MethodPost:
+		lock, err := h.locks.Heartbeat(r.Context(), meta, resourceType, id)
+		if err != nil {
+			respondLockError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"lock": lock})
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		if err := h.locks.Release(r.Context(), meta, resourceType, id); err != nil {
+			respondLockError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// ListLocks 管理视图：列出锁及其心跳状态
+// GET /api/v1/admin/locks?resource_type=&owner_id=&stale=true&limit=&offset=
+func (h *LockHandler) ListLocks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if _, ok := requireUserPermission(w, r, h.permissionService.Roles(), database.PermLockBreak, "view locks"); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := service.LockFilter{ResourceType: query.Get("resource_type"), StaleOnly: query.Get("stale") == "true"}
+	if v := query.Get("owner_id"); v != "" {
+		ownerID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid owner_id"))
+			return
+		}
+		id := uint(ownerID)
+		filter.OwnerID = &id
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			respondError(w, http.StatusBadRequest, errors.New("invalid offset"))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	locks, total, err := h.locks.List(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"locks": locks,
+		"total": total,
+	})
+}
+
+// AdminLockRoutes 强制解除锁
+// DELETE /api/v1/admin/locks/:id
+// POST   /api/v1/admin/locks/break-stale  解除所有已过期或失去心跳的锁
+func (h *LockHandler) AdminLockRoutes(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUserPermission(w, r, h.permissionService.Roles(), database.PermLockBreak, "break locks"); !ok {
+		return
+	}
+	relPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/locks/"), "/")
+	meta := metaFromRequestContext(r)
+
+	if relPath == "break-stale" {
+		if r.Method != http.MethodPost {
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		broken, err := h.locks.BreakStale(r.Context(), meta)
+		if err != nil {
+			respondLockError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"broken": broken,
+			"total":  len(broken),
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(relPath, 10, 64)
+	if err != nil || id == 0 {
+		respondError(w, http.StatusBadRequest, errors.New("invalid lock id"))
+		return
+	}
+	if r.Method != http.MethodDelete {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	lock, err := h.locks.Break(r.Context(), meta, uint(id))
+	if err != nil {
+		respondLockError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lock": lock})
+}
+
+// canLock 签出需要在资源所属课程内拥有文档编辑权限；未配置权限服务时按全局角色判断
+func (h *LockHandler) canLock(w http.ResponseWriter, r *http.Request, user *database.User, resourceType string, id int64) bool {
+	allowed := h.permissionService.Roles().HasPermission(r.Context(), user.Role, database.PermDocumentEdit)
+	if h.permissionService != nil {
+		var err error
+		if resourceType == database.LockResourceDocument {
+			allowed, err = h.permissionService.HasDocumentPermission(r.Context(), user.ID, user.Role, id, database.PermDocumentEdit)
+		} else {
+			allowed, err = h.permissionService.HasNodePermission(r.Context(), user.ID, user.Role, id, database.PermDocumentEdit)
+		}
+		if err != nil {
+			respondAPIError(w, WrapUpstreamError(err))
+			return false
+		}
+	}
+	if !allowed {
+		respondError(w, http.StatusForbidden, fmt.Errorf("role '%s' cannot lock this %s", user.Role, resourceType))
+		return false
+	}
+	return true
+}
+
+// respondResourceLocked 返回 409，附带当前持有的锁，便于客户端提示持有者与到期时间
+func respondResourceLocked(w http.ResponseWriter, err *service.ResourceLockedError) {
+	writeJSON(w, http.StatusConflict, struct {
+		*APIError
+		Lock database.ResourceLock `json:"lock"`
+	}{
+		APIError: ErrResourceLocked(err),
+		Lock:     err.Lock,
+	})
+}
+
+func respondLockError(w http.ResponseWriter, err error) {
+	var lockedErr *service.ResourceLockedError
+	var vErr *service.ValidationError
+	switch {
+	case errors.As(err, &lockedErr):
+		respondResourceLocked(w, lockedErr)
+	case errors.As(err, &vErr):
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "请求参数错误", vErr.Error()))
+	case errors.Is(err, service.ErrLockNotHeld):
+		respondAPIError(w, NewAPIError(ErrCodeConflict, http.StatusConflict, "未持有该锁", "锁已过期或已被解除，请重新签出"))
+	case errors.Is(err, service.ErrLockNotFound):
+		respondError(w, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrLockForbidden):
+		respondError(w, http.StatusForbidden, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
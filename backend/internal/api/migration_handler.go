@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// MigrationHandler 处理文档类型迁移的 HTTP 请求
+type MigrationHandler struct {
+	migrationService  *service.BatchMigrationService
+	permissionService *service.PermissionService
+}
+
+// NewMigrationHandler 创建 MigrationHandler
+func NewMigrationHandler(migrationSvc *service.BatchMigrationService, permSvc *service.PermissionService) *MigrationHandler {
+	return &MigrationHandler{
+		migrationService:  migrationSvc,
+		permissionService: permSvc,
+	}
+}
+
+// MigrationRoutes 处理类型迁移路由
+// /api/v1/migrations - GET 已声明的迁移
+// /api/v1/migrations/batches - GET 批次列表
+// /api/v1/migrations/batches/{batchId} - GET 查询状态
+// /api/v1/migrations/batches/{batchId}/cancel - POST 取消
+func (h *MigrationHandler) MigrationRoutes(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/migrations"), "/")
+
+	if relPath == "" {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"migrations": service.DocumentTypeMigrations()})
+		return
+	}
+
+	if relPath == "batches" {
+		if r.Method != http.MethodGet {
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		h.listBatchMigrations(w, r)
+		return
+	}
+
+	batchPath, ok := strings.CutPrefix(relPath, "batches/")
+	if !ok {
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	// /api/v1/migrations/batches/{batchId}/cancel - 取消
+	if batchID, ok := strings.CutSuffix(batchPath, "/cancel"); ok {
+		if r.Method != http.MethodPost {
+			respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		meta := metaFromRequestContext(r)
+		if err := h.migrationService.CancelBatchMigration(r.Context(), meta, batchID); err != nil {
+			respondBatchCancelError(w, err)
+			return
+		}
+		h.getBatchMigrationStatus(w, r, batchID)
+		return
+	}
+
+	// /api/v1/migrations/batches/{batchId} - 查询状态
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	h.getBatchMigrationStatus(w, r, batchPath)
+}
+
+// handleNodeMigration 处理节点级别的类型迁移请求
+// /api/v1/nodes/{nodeId}/migrations/batch/(preview|execute) - POST
+func (h *MigrationHandler) handleNodeMigration(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/nodes/")
+	parts := strings.Split(path, "/")
+
+	if len(parts) < 4 || parts[1] != "migrations" || parts[2] != "batch" {
+		respondError(w, http.StatusNotFound, errors.New("invalid migration path"))
+		return
+	}
+
+	nodeID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errors.New("invalid node ID"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !h.requireDocumentEdit(w, r, nodeID) {
+		return
+	}
+
+	meta := metaFromRequestContext(r)
+	switch parts[3] {
+	case "preview":
+		var req service.BatchMigrationPreviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		result, err := h.migrationService.PreviewBatchMigration(r.Context(), meta, nodeID, req)
+		if err != nil {
+			respondMigrationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "execute":
+		var req service.BatchMigrationExecuteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		result, err := h.migrationService.ExecuteBatchMigration(r.Context(), meta, nodeID, req)
+		if err != nil {
+			respondMigrationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, result)
+	default:
+		respondError(w, http.StatusNotFound, errors.New("unknown action"))
+	}
+}
+
+// requireDocumentEdit 迁移会改写子树中的文档，要求用户在该节点所属课程内有文档编辑权限
+func (h *MigrationHandler) requireDocumentEdit(w http.ResponseWriter, r *http.Request, nodeID int64) bool {
+	user, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return false
+	}
+	if h.permissionService == nil {
+		return true
+	}
+	perm, err := h.permissionService.GetDocumentPermission(r.Context(), user.ID, user.Role, nodeID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if !perm.CanEdit {
+		respondError(w, http.StatusForbidden, errors.New("no permission to edit documents under this node"))
+		return false
+	}
+	return true
+}
+
+// getBatchMigrationStatus 获取类型迁移状态
+func (h *MigrationHandler) getBatchMigrationStatus(w http.ResponseWriter, r *http.Request, batchID string) {
+	result, err := h.migrationService.GetBatchMigrationStatus(r.Context(), metaFromRequestContext(r), batchID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBatchNotFound):
+			respondError(w, http.StatusNotFound, err)
+		case errors.Is(err, service.ErrBatchForbidden):
+			respondError(w, http.StatusForbidden, err)
+		default:
+			respondError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// listBatchMigrations 列出类型迁移批次
+func (h *MigrationHandler) listBatchMigrations(w http.ResponseWriter, r *http.Request) {
+	meta := metaFromRequestContext(r)
+
+	limit := 20
+	offset := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			offset = n
+		}
+	}
+
+	results, total, err := h.migrationService.ListBatchMigrations(r.Context(), meta, limit, offset)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":    results,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": int64(offset+len(results)) < total,
+	})
+}
+
+func respondMigrationError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	switch {
+	case errors.As(err, &vErr):
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "类型迁移校验失败", vErr.Error()))
+	case errors.Is(err, service.ErrDocumentTypeMigrationNotFound):
+		respondError(w, http.StatusNotFound, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"strings"
 	"testing"
 
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
 	"github.com/yjxt/ydms/backend/internal/cache"
 	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
@@ -272,6 +275,98 @@ func TestCourseAdminPermissions_DocumentAndCategoryOperations(t *testing.T) {
 	})
 }
 
+func TestCourseAdminPermissions_ScopedToGrantedCourse(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	ctx := context.Background()
+	users := service.NewUserService(db)
+	alice, err := users.CreateUser(ctx, service.RequestMeta{}, "alice", "password123", database.RoleCourseAdmin, nil)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	ndr := newInMemoryNDR()
+	svc := service.NewService(cache.NewNoop(), ndr, nil)
+	router := NewRouter(NewHandler(svc, service.NewPermissionService(db, users, ndr, nil), HeaderDefaults{}))
+
+	courseA := createCategory(t, router, `{"name":"Course A"}`)
+	courseB := createCategory(t, router, `{"name":"Course B"}`)
+	if err := users.GrantCoursePermission(ctx, service.RequestMeta{}, alice.ID, courseA.ID, ""); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	chapterA := createCategory(t, router, fmt.Sprintf(`{"name":"Chapter A","parent_id":%d}`, courseA.ID))
+	chapterB := createCategory(t, router, fmt.Sprintf(`{"name":"Chapter B","parent_id":%d}`, courseB.ID))
+	docB, _ := ndr.CreateDocument(ctx, ndrclient.RequestMeta{}, ndrclient.DocumentCreate{Title: "In B"})
+	_ = ndr.BindDocument(ctx, ndrclient.RequestMeta{}, courseB.ID, docB.ID)
+
+	doAs := func(user *database.User, method, path, body string) int {
+		req := withTestUser(httptest.NewRequest(method, path, strings.NewReader(body)), user)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	do := func(method, path, body string) int {
+		return doAs(alice, method, path, body)
+	}
+
+	cases := []struct {
+		name         string
+		method, path string
+		body         string
+		want         int
+	}{
+		{"在授权课程下创建分类", http.MethodPost, "/api/v1/categories", fmt.Sprintf(`{"name":"Chapter","parent_id":%d}`, courseA.ID), http.StatusCreated},
+		{"在授权课程下创建文档", http.MethodPost, "/api/v1/documents", fmt.Sprintf(`{"title":"Doc A","node_id":%d}`, courseA.ID), http.StatusCreated},
+		{"不能在其他课程下创建分类", http.MethodPost, "/api/v1/categories", fmt.Sprintf(`{"name":"Chapter","parent_id":%d}`, courseB.ID), http.StatusForbidden},
+		{"不能在其他课程下创建文档", http.MethodPost, "/api/v1/documents", fmt.Sprintf(`{"title":"Doc B","node_id":%d}`, courseB.ID), http.StatusForbidden},
+		{"不能编辑其他课程的分类", http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d", courseB.ID), `{"name":"Renamed"}`, http.StatusForbidden},
+		{"不能删除其他课程的分类", http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d", courseB.ID), "", http.StatusForbidden},
+		{"不能删除其他课程的文档", http.MethodDelete, fmt.Sprintf("/api/v1/documents/%d", docB.ID), "", http.StatusForbidden},
+		{"不能复制其他课程的文档", http.MethodPost, fmt.Sprintf("/api/v1/documents/%d/copy", docB.ID), fmt.Sprintf(`{"node_id":%d}`, courseA.ID), http.StatusForbidden},
+		{"不能修改其他课程文档的引用", http.MethodPost, fmt.Sprintf("/api/v1/documents/%d/references", docB.ID), `{"document_id":1}`, http.StatusForbidden},
+		{"不能回滚其他课程文档的版本", http.MethodPost, fmt.Sprintf("/api/v1/documents/%d/versions/1/restore", docB.ID), "", http.StatusForbidden},
+		{"不能把文档绑定到其他课程", http.MethodPost, fmt.Sprintf("/api/v1/nodes/%d/bind/%d", courseB.ID, docB.ID), "", http.StatusForbidden},
+		{"不能修改其他课程的文档", http.MethodPut, fmt.Sprintf("/api/v1/documents/%d", docB.ID), `{"title":"Overwritten"}`, http.StatusForbidden},
+		{"不能恢复其他课程的文档", http.MethodPost, fmt.Sprintf("/api/v1/documents/%d/restore", docB.ID), "", http.StatusForbidden},
+		{"不能彻底删除其他课程的文档", http.MethodDelete, fmt.Sprintf("/api/v1/documents/%d/purge", docB.ID), "", http.StatusForbidden},
+		{"不能排序其他课程的文档", http.MethodPost, "/api/v1/documents/reorder", fmt.Sprintf(`{"ordered_ids":[%d]}`, docB.ID), http.StatusForbidden},
+		{"不能恢复其他课程的分类", http.MethodPost, fmt.Sprintf("/api/v1/categories/%d/restore", chapterB.ID), "", http.StatusForbidden},
+		{"不能彻底删除其他课程的分类", http.MethodDelete, fmt.Sprintf("/api/v1/categories/%d/purge", chapterB.ID), "", http.StatusForbidden},
+		{"不能排序其他课程的分类", http.MethodPost, "/api/v1/categories/reorder", fmt.Sprintf(`{"parent_id":%d,"ordered_ids":[%d]}`, courseB.ID, chapterB.ID), http.StatusForbidden},
+		{"不能排序课程", http.MethodPost, "/api/v1/categories/reorder", fmt.Sprintf(`{"parent_id":null,"ordered_ids":[%d,%d]}`, courseB.ID, courseA.ID), http.StatusForbidden},
+		{"不能把其他课程的分类移入授权课程", http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/move", chapterB.ID), fmt.Sprintf(`{"new_parent_id":%d}`, courseA.ID), http.StatusForbidden},
+		{"不能把授权课程的分类移入其他课程", http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/move", chapterA.ID), fmt.Sprintf(`{"new_parent_id":%d}`, courseB.ID), http.StatusForbidden},
+		{"不能把分类移到根层级", http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/move", chapterA.ID), `{"new_parent_id":null}`, http.StatusForbidden},
+		{"在授权课程内移动分类", http.MethodPatch, fmt.Sprintf("/api/v1/categories/%d/move", chapterA.ID), fmt.Sprintf(`{"new_parent_id":%d}`, courseA.ID), http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := do(tc.method, tc.path, tc.body); got != tc.want {
+			t.Errorf("%s: %s %s status = %d, want %d", tc.name, tc.method, tc.path, got, tc.want)
+		}
+	}
+
+	// 在课程 B 只有校对员角色：可以编辑文档，但不能彻底删除
+	bob, err := users.CreateUser(ctx, service.RequestMeta{}, "bob", "password123", database.RoleProofreader, nil)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := users.GrantCoursePermission(ctx, service.RequestMeta{}, bob.ID, courseB.ID, ""); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	if got := doAs(bob, http.MethodDelete, fmt.Sprintf("/api/v1/documents/%d/purge", docB.ID), ""); got != http.StatusForbidden {
+		t.Errorf("proofreader purge status = %d, want 403", got)
+	}
+	if got := doAs(bob, http.MethodPut, fmt.Sprintf("/api/v1/documents/%d", docB.ID), `{"title":"Proofread"}`); got != http.StatusOK {
+		t.Errorf("proofreader edit status = %d, want 200", got)
+	}
+}
+
 func TestSuperAdminPermissions(t *testing.T) {
 	ndr := newInMemoryNDR()
 	svc := service.NewService(cache.NewNoop(), ndr, nil)
@@ -428,11 +523,11 @@ func TestPermissionHelpers(t *testing.T) {
 		UserID: "tester",
 	})
 
-	t.Run("requireNotProofreader 正确拒绝校对员", func(t *testing.T) {
+	t.Run("requirePermission 正确拒绝校对员", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req = withTestUser(req, testProofreader)
 
-		user, httpErr := handler.requireNotProofreader(req, "test action")
+		user, httpErr := handler.requirePermission(req, database.PermDocumentCreate, "test action")
 
 		if httpErr == nil {
 			t.Error("expected error for proofreader, got nil")
@@ -445,11 +540,11 @@ func TestPermissionHelpers(t *testing.T) {
 		}
 	})
 
-	t.Run("requireNotProofreader 允许课程管理员", func(t *testing.T) {
+	t.Run("requirePermission 允许课程管理员", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req = withTestUser(req, testCourseAdmin)
 
-		user, httpErr := handler.requireNotProofreader(req, "test action")
+		user, httpErr := handler.requirePermission(req, database.PermDocumentCreate, "test action")
 
 		if httpErr != nil {
 			t.Errorf("expected no error for course admin, got %v", httpErr.message)
@@ -462,11 +557,11 @@ func TestPermissionHelpers(t *testing.T) {
 		}
 	})
 
-	t.Run("requireNotProofreader 允许超级管理员", func(t *testing.T) {
+	t.Run("requirePermission 允许超级管理员", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req = withTestUser(req, testSuperAdmin)
 
-		user, httpErr := handler.requireNotProofreader(req, "test action")
+		user, httpErr := handler.requirePermission(req, database.PermDocumentCreate, "test action")
 
 		if httpErr != nil {
 			t.Errorf("expected no error for super admin, got %v", httpErr.message)
@@ -510,3 +605,40 @@ func TestPermissionHelpers(t *testing.T) {
 		}
 	})
 }
+
+func TestDocumentTypeHandler_RequiresRolesManage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.Role{}, &database.DocumentTypeRecord{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	// 自定义角色只有 roles:manage，不是 super_admin
+	if err := db.Create(&database.Role{Name: "schema_editor", Permissions: database.StringList{database.PermRolesManage}}).Error; err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+	handler := NewDocumentTypeHandler(service.NewDocumentTypeService(db, newInMemoryNDR()))
+	schemaEditor := &database.User{ID: 9, Username: "schema_editor", Role: "schema_editor"}
+
+	register := func(user *database.User) int {
+		payload := `{"id":"permission_probe_v1","label":"权限测试","content_format":"markdown","template":"# 标题"}`
+		req := withTestUser(httptest.NewRequest(http.MethodPost, "/api/v1/document-types", strings.NewReader(payload)), user)
+		rec := httptest.NewRecorder()
+		handler.DocumentTypes(rec, req)
+		return rec.Code
+	}
+	if code := register(testCourseAdmin); code != http.StatusForbidden {
+		t.Fatalf("course admin register status = %d, want 403", code)
+	}
+	if code := register(schemaEditor); code != http.StatusCreated {
+		t.Fatalf("roles:manage register status = %d, want 201", code)
+	}
+
+	req := withTestUser(httptest.NewRequest(http.MethodDelete, "/api/v1/document-types/permission_probe_v1", nil), schemaEditor)
+	rec := httptest.NewRecorder()
+	handler.DocumentTypeRoutes(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("roles:manage delete status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+	"github.com/yjxt/ydms/backend/internal/prefectfake"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+// prefectFlowEnv 串起 NDR fake、Prefect fake 与真实的服务和路由，回调经过签名校验落库
+type prefectFlowEnv struct {
+	db        *gorm.DB
+	prefect   *prefectfake.Server
+	ndr       ndrclient.Client
+	workflows *service.WorkflowService
+	syncs     *service.SyncService
+	defs      *service.WorkflowSyncService
+	pdmsURL   string
+}
+
+func newPrefectFlowEnv(t *testing.T) *prefectFlowEnv {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// 回调在其他 goroutine 中写库，内存库只能共用一个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(
+		&database.User{},
+		&database.Role{},
+		&database.WorkflowDefinition{},
+		&database.WorkflowRun{},
+		&database.DocSyncStatus{},
+		&database.AuditEvent{},
+		&database.ResourceLock{},
+	); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	_, ndrURL := ndrfake.NewTestServer(t, ndrfake.Options{})
+	ndr := ndrclient.NewClient(ndrclient.NDRConfig{BaseURL: ndrURL})
+	prefect, prefectURL := prefectfake.NewTestServer(t, prefectfake.Options{WebhookSecret: testWebhookSecret})
+	prefectClient := prefectclient.NewClient(prefectURL, 5*time.Second)
+
+	var router http.Handler
+	pdms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(pdms.Close)
+
+	env := &prefectFlowEnv{
+		db:        db,
+		prefect:   prefect,
+		ndr:       ndr,
+		workflows: service.NewWorkflowService(db, prefectClient, ndr, pdms.URL),
+		syncs:     service.NewSyncService(db, prefectClient, ndr, pdms.URL),
+		defs:      service.NewWorkflowSyncService(db, prefectClient, true),
+		pdmsURL:   pdms.URL,
+	}
+	handler := NewHandler(service.NewService(cache.NewNoop(), ndr, nil), nil, HeaderDefaults{})
+	router = NewRouterWithConfig(RouterConfig{
+		Handler:         handler,
+		WorkflowHandler: NewWorkflowHandler(env.workflows, handler),
+		SyncHandler:     NewSyncHandler(env.syncs, ""),
+		DB:              db,
+		WebhookVerifier: auth.NewWebhookVerifier(time.Minute, testWebhookSecret),
+	})
+	return env
+}
+
+func (env *prefectFlowEnv) createDocument(t *testing.T, metadata map[string]any) int64 {
+	t.Helper()
+	docType := "markdown_v1"
+	doc, err := env.ndr.CreateDocument(context.Background(), ndrclient.RequestMeta{UserID: "tester"}, ndrclient.DocumentCreate{
+		Title:    "Chapter 1",
+		Type:     &docType,
+		Content:  map[string]any{"format": "markdown", "data": "# Chapter 1"},
+		Metadata: metadata,
+	})
+	if err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	return doc.ID
+}
+
+func (env *prefectFlowEnv) run(t *testing.T, id uint) database.WorkflowRun {
+	t.Helper()
+	var run database.WorkflowRun
+	if err := env.db.First(&run, id).Error; err != nil {
+		t.Fatalf("failed to load workflow run %d: %v", id, err)
+	}
+	return run
+}
+
+func TestPrefectFake_DocumentWorkflowCallbacks(t *testing.T) {
+	env := newPrefectFlowEnv(t)
+	ctx := context.Background()
+	meta := service.RequestMeta{UserID: "tester", UserIDNumeric: 1}
+
+	env.prefect.AddDeployment(prefectclient.DeploymentDetails{
+		Name:        "summarize-deployment",
+		Description: "生成摘要",
+		Version:     "1.0.0",
+		Tags:        []string{"pdms:type=document", "pdms:key=summarize"},
+	})
+	env.prefect.AddDeployment(prefectclient.DeploymentDetails{Name: "untagged-deployment"})
+
+	synced, err := env.defs.SyncFromPrefect(ctx)
+	if err != nil {
+		t.Fatalf("SyncFromPrefect() error = %v", err)
+	}
+	if synced.Created != 1 || len(synced.Errors) != 0 {
+		t.Fatalf("SyncFromPrefect() = %+v, want one created definition", synced)
+	}
+	def, err := env.workflows.GetWorkflowDefinition(ctx, "summarize")
+	if err != nil || def.WorkflowType != "document" || def.PrefectDeploymentName != "summarize-deployment" {
+		t.Fatalf("definition = %+v, %v", def, err)
+	}
+
+	docID := env.createDocument(t, nil)
+	trigger := func() *service.TriggerWorkflowResponse {
+		t.Helper()
+		resp, err := env.workflows.TriggerDocumentWorkflow(ctx, meta, service.TriggerDocumentWorkflowRequest{
+			DocumentID:  docID,
+			WorkflowKey: "summarize",
+		})
+		if err != nil {
+			t.Fatalf("TriggerDocumentWorkflow() error = %v", err)
+		}
+		return resp
+	}
+
+	env.prefect.SetScript("summarize-deployment", prefectfake.Script{
+		StartDelay: 10 * time.Millisecond,
+		Result:     map[string]any{"summary_doc_id": float64(99)},
+	})
+	ok := trigger()
+	env.prefect.Wait()
+	run := env.run(t, ok.RunID)
+	if run.Status != service.WorkflowStatusSuccess || run.PrefectFlowRunID != ok.PrefectFlowRunID ||
+		run.StartedAt == nil || run.Result["summary_doc_id"] != float64(99) {
+		t.Fatalf("successful run = %+v", run)
+	}
+	flowRun, _ := env.prefect.FlowRun(ok.PrefectFlowRunID)
+	if flowRun.Parameters["callback_url"] != fmt.Sprintf("%s/api/v1/workflows/callback/%d", env.pdmsURL, ok.RunID) {
+		t.Fatalf("callback_url = %v", flowRun.Parameters["callback_url"])
+	}
+
+	env.prefect.SetScript("summarize-deployment", prefectfake.Script{Outcome: prefectfake.StateFailed, ErrorMessage: "model timeout"})
+	failed := trigger()
+	env.prefect.Wait()
+	if run := env.run(t, failed.RunID); run.Status != service.WorkflowStatusFailed || run.ErrorMessage != "model timeout" {
+		t.Fatalf("failed run = %+v", run)
+	}
+
+	env.prefect.SetScript("summarize-deployment", prefectfake.Script{StartDelay: time.Hour})
+	slow := trigger()
+	if err := env.workflows.CancelWorkflowRun(ctx, meta, slow.RunID); err != nil {
+		t.Fatalf("CancelWorkflowRun() error = %v", err)
+	}
+	env.prefect.Wait()
+	if run := env.run(t, slow.RunID); run.Status != service.WorkflowStatusCancelled {
+		t.Fatalf("cancelled run status = %s", run.Status)
+	}
+	if flowRun, _ := env.prefect.FlowRun(slow.PrefectFlowRunID); flowRun.StateType != prefectfake.StateCancelled {
+		t.Fatalf("prefect flow run state = %s, want CANCELLED", flowRun.StateType)
+	}
+
+	for _, cb := range env.prefect.Callbacks() {
+		if cb.StatusCode != http.StatusOK {
+			t.Fatalf("callback %s rejected: %d %s", cb.URL, cb.StatusCode, cb.Error)
+		}
+	}
+}
+
+func TestPrefectFake_SyncCallbacks(t *testing.T) {
+	env := newPrefectFlowEnv(t)
+	ctx := context.Background()
+	meta := service.RequestMeta{UserID: "tester", UserIDNumeric: 1}
+
+	env.prefect.AddDeployment(prefectclient.DeploymentDetails{Name: "sync_to_mysql-deployment"})
+	env.prefect.SetScript("sync_to_mysql-deployment", prefectfake.Script{
+		RunDuration:    10 * time.Millisecond,
+		AffectedTables: []prefectfake.AffectedTable{{Table: "questions", AffectedRows: 1, Operation: "update"}},
+	})
+	docID := env.createDocument(t, map[string]any{
+		"sync_target": map[string]any{"table": "questions", "record_id": 7, "field": "content"},
+	})
+
+	resp, err := env.syncs.TriggerSync(ctx, meta, docID)
+	if err != nil {
+		t.Fatalf("TriggerSync() error = %v", err)
+	}
+	env.prefect.Wait()
+
+	var status database.DocSyncStatus
+	if err := env.db.Where("document_id = ?", docID).First(&status).Error; err != nil {
+		t.Fatalf("failed to load sync status: %v", err)
+	}
+	if status.LastStatus != service.SyncStatusSuccess || status.LastEventID != resp.EventID ||
+		status.LastRunID != resp.PrefectFlowRunID || status.LastSyncedAt == nil {
+		t.Fatalf("sync status = %+v, want success for event %s", status, resp.EventID)
+	}
+	if run := env.run(t, *status.LastWorkflowRunID); run.Status != service.WorkflowStatusSuccess {
+		t.Fatalf("sync workflow run status = %s", run.Status)
+	}
+
+	// 未签名的回调被路由层拒绝
+	req, _ := http.NewRequest(http.MethodPost, env.pdmsURL+"/api/v1/sync/callback",
+		strings.NewReader(`{"event_id":"`+resp.EventID+`","doc_id":1,"status":"failed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	unsigned, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unsigned callback: %v", err)
+	}
+	unsigned.Body.Close()
+	if unsigned.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unsigned callback status = %d, want 401", unsigned.StatusCode)
+	}
+}
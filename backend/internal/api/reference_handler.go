@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// ReferenceHandler 文档引用索引的管理接口
+type ReferenceHandler struct {
+	refs *service.ReferenceIndex
+}
+
+// NewReferenceHandler 创建引用索引处理器
+func NewReferenceHandler(refs *service.ReferenceIndex) *ReferenceHandler {
+	return &ReferenceHandler{refs: refs}
+}
+
+// DanglingReferences 按课程列出悬空引用（被引用文档已删除或不存在）
+// GET /api/v1/admin/references/dangling?root_node_id=
+func (h *ReferenceHandler) DanglingReferences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if user, ok := r.Context().Value(auth.UserContextKey).(*database.User); !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return
+	}
+
+	var rootNodeID int64
+	if v := r.URL.Query().Get("root_node_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid root_node_id"))
+			return
+		}
+		rootNodeID = id
+	}
+
+	report, err := h.refs.DanglingReferences(r.Context(), metaFromRequestContext(r), rootNodeID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	total := 0
+	for _, course := range report {
+		total += course.Total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"courses": report,
+		"total":   total,
+	})
+}
+
+// Rebuild 从 NDR 全量重建引用索引（需要 courses:all）
+// POST /api/v1/admin/references/rebuild
+func (h *ReferenceHandler) Rebuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !requireRolePermissions(w, r, h.refs.Roles(), "rebuild the reference index", database.PermCoursesAll) {
+		return
+	}
+	result, err := h.refs.Rebuild(r.Context())
+	if err != nil {
+		respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// RoleHandler 角色管理处理器
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+// NewRoleHandler 创建角色管理处理器
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// Roles 处理 /api/v1/roles
+// GET 列出角色及可分配的权限；POST 创建自定义角色（需要 roles:manage）
+func (h *RoleHandler) Roles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		roles, err := h.roleService.ListRoles(r.Context())
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"roles":       roles,
+			"permissions": database.AllPermissions,
+		})
+	case http.MethodPost:
+		if !h.requireRolesManage(w, r) {
+			return
+		}
+		var req service.RoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		role, err := h.roleService.CreateRole(r.Context(), metaFromRequestContext(r), req)
+		if err != nil {
+			respondRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"role": role})
+	default:
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// RoleRoutes 处理 /api/v1/roles/{name}
+func (h *RoleHandler) RoleRoutes(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/roles/"), "/")
+	if name == "" {
+		h.Roles(w, r)
+		return
+	}
+	if strings.Contains(name, "/") {
+		respondError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		role, err := h.roleService.GetRole(r.Context(), name)
+		if err != nil {
+			respondRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"role": role})
+	case http.MethodPut:
+		if !h.requireRolesManage(w, r) {
+			return
+		}
+		var req service.RoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		role, err := h.roleService.UpdateRole(r.Context(), metaFromRequestContext(r), name, req)
+		if err != nil {
+			respondRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"role": role})
+	case http.MethodDelete:
+		if !h.requireRolesManage(w, r) {
+			return
+		}
+		if err := h.roleService.DeleteRole(r.Context(), metaFromRequestContext(r), name); err != nil {
+			respondRoleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "role deleted successfully"})
+	default:
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// requireRolesManage 角色定义的修改需要 roles:manage
+func (h *RoleHandler) requireRolesManage(w http.ResponseWriter, r *http.Request) bool {
+	return requireRolePermissions(w, r, h.roleService, "manage roles", database.PermRolesManage)
+}
+
+// requireRolePermissions 检查当前登录用户的角色拥有全部给定权限，不满足时写入错误响应
+func requireRolePermissions(w http.ResponseWriter, r *http.Request, roles *service.RoleService, action string, permissions ...string) bool {
+	user, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return false
+	}
+	for _, perm := range permissions {
+		if !roles.HasPermission(r.Context(), user.Role, perm) {
+			respondError(w, http.StatusForbidden, fmt.Errorf("role '%s' cannot %s", user.Role, action))
+			return false
+		}
+	}
+	return true
+}
+
+func respondRoleError(w http.ResponseWriter, err error) {
+	var vErr *service.ValidationError
+	switch {
+	case errors.As(err, &vErr):
+		respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "角色校验失败", vErr.Error()))
+	case errors.Is(err, service.ErrRoleNotFound):
+		respondError(w, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrRoleBuiltIn):
+		respondError(w, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrRoleExists), errors.Is(err, service.ErrRoleInUse):
+		respondError(w, http.StatusConflict, err)
+	default:
+		respondError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -19,16 +19,25 @@ type RouterConfig struct {
 	Handler              *Handler
 	AuthHandler          *AuthHandler
 	UserHandler          *UserHandler
+	RoleHandler          *RoleHandler
+	DocumentTypeHandler  *DocumentTypeHandler // 文档类型注册表
 	CourseHandler        *CourseHandler
 	APIKeyHandler        *APIKeyHandler
 	AssetsHandler        *AssetsHandler
 	SyncHandler          *SyncHandler
 	WorkflowHandler      *WorkflowHandler
 	AdminWorkflowHandler *AdminWorkflowHandler
-	BatchHandler         *BatchHandler // 批量操作处理器
+	AuditHandler         *AuditHandler         // 审计日志查询
+	BatchHandler         *BatchHandler         // 批量操作处理器
+	MigrationHandler     *MigrationHandler     // 文档类型迁移
+	SearchHandler        *SearchHandler        // 全文检索
+	ReferenceHandler     *ReferenceHandler     // 文档引用索引
+	BulkOperationHandler *BulkOperationHandler // 分类批量操作日志
+	LockHandler          *LockHandler          // 文档/节点锁
 	StaticProxyHandler   *StaticProxyHandler
 	JWTSecret            string
-	DB                   *gorm.DB // 用于 API Key 验证
+	DB                   *gorm.DB              // 用于 API Key 验证
+	WebhookVerifier      *auth.WebhookVerifier // 回调签名校验
 }
 
 // NewRouter creates the HTTP router and wires handler endpoints.
@@ -57,6 +66,14 @@ func NewRouterWithConfig(cfg RouterConfig) http.Handler {
 
 	wrap := cfg.Handler.applyMiddleware
 	authWrap := cfg.Handler.applyAuthMiddleware(cfg.JWTSecret, cfg.DB)
+	// scoped 在认证之后检查 API Key 的 scope（JWT 请求不受影响）
+	scoped := func(resolve auth.ScopeResolver, next http.Handler) http.Handler {
+		return authWrap(auth.RequireScope(resolve)(next))
+	}
+	readOr := func(writeScope string) auth.ScopeResolver {
+		return auth.MethodScope(auth.ScopeDocumentsRead, writeScope)
+	}
+	webhookAuth := auth.WebhookMiddleware(cfg.WebhookVerifier)
 
 	// 健康检查端点（公开）
 	mux.Handle("/health", wrap(http.HandlerFunc(cfg.Handler.Health)))
@@ -66,91 +83,141 @@ func NewRouterWithConfig(cfg RouterConfig) http.Handler {
 
 	// 认证端点
 	mux.Handle("/api/v1/auth/login", wrap(http.HandlerFunc(cfg.AuthHandler.Login)))
+	mux.Handle("/api/v1/auth/refresh", wrap(http.HandlerFunc(cfg.AuthHandler.Refresh)))
 	mux.Handle("/api/v1/auth/logout", authWrap(http.HandlerFunc(cfg.AuthHandler.Logout)))
 	mux.Handle("/api/v1/auth/me", authWrap(http.HandlerFunc(cfg.AuthHandler.Me)))
 	mux.Handle("/api/v1/auth/change-password", authWrap(http.HandlerFunc(cfg.AuthHandler.ChangePassword)))
 
 	// 用户管理端点（需要认证）
 	if cfg.UserHandler != nil {
-		mux.Handle("/api/v1/users", authWrap(http.HandlerFunc(handleUsersRoot(cfg.UserHandler))))
-		mux.Handle("/api/v1/users/", authWrap(http.HandlerFunc(handleUserRoutes(cfg.UserHandler))))
+		mux.Handle("/api/v1/users", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(handleUsersRoot(cfg.UserHandler))))
+		mux.Handle("/api/v1/users/", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(handleUserRoutes(cfg.UserHandler))))
+	}
+
+	// 角色管理端点（需要认证）
+	if cfg.RoleHandler != nil {
+		mux.Handle("/api/v1/roles", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.RoleHandler.Roles)))
+		mux.Handle("/api/v1/roles/", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.RoleHandler.RoleRoutes)))
+	}
+
+	// 文档类型注册表（读取需要认证，注册/修改需要 roles:manage）
+	if cfg.DocumentTypeHandler != nil {
+		mux.Handle("/api/v1/document-types", scoped(readOr(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.DocumentTypeHandler.DocumentTypes)))
+		mux.Handle("/api/v1/document-types/", scoped(readOr(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.DocumentTypeHandler.DocumentTypeRoutes)))
 	}
 
 	// 课程管理端点（需要认证）
 	if cfg.CourseHandler != nil {
-		mux.Handle("/api/v1/courses", authWrap(http.HandlerFunc(cfg.CourseHandler.ListCourses)))
-		mux.Handle("/api/v1/courses/", authWrap(http.HandlerFunc(handleCourseRoutes(cfg.CourseHandler))))
+		mux.Handle("/api/v1/courses", scoped(readOr(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.CourseHandler.ListCourses)))
+		mux.Handle("/api/v1/courses/", scoped(readOr(auth.ScopeUsersAdmin), http.HandlerFunc(handleCourseRoutes(cfg.CourseHandler))))
 	}
 
 	// API Key 管理端点（需要认证，仅限管理员）
 	if cfg.APIKeyHandler != nil {
-		mux.Handle("/api/v1/api-keys", authWrap(http.HandlerFunc(cfg.APIKeyHandler.APIKeys)))
-		mux.Handle("/api/v1/api-keys/", authWrap(http.HandlerFunc(cfg.APIKeyHandler.APIKeyRoutes)))
+		mux.Handle("/api/v1/api-keys", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.APIKeyHandler.APIKeys)))
+		mux.Handle("/api/v1/api-keys/", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.APIKeyHandler.APIKeyRoutes)))
 	}
 
 	// 业务端点（需要认证）
-	mux.Handle("/api/v1/categories", authWrap(http.HandlerFunc(cfg.Handler.Categories)))
-	mux.Handle("/api/v1/categories/", authWrap(http.HandlerFunc(cfg.Handler.CategoryRoutes)))
-	mux.Handle("/api/v1/documents", authWrap(http.HandlerFunc(cfg.Handler.Documents)))
+	mux.Handle("/api/v1/categories", scoped(readOr(auth.ScopeCategoriesAdmin), http.HandlerFunc(cfg.Handler.Categories)))
+	mux.Handle("/api/v1/categories/", scoped(readOr(auth.ScopeCategoriesAdmin), http.HandlerFunc(cfg.Handler.CategoryRoutes)))
+	mux.Handle("/api/v1/documents", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.Handler.Documents)))
 	// 文档路由：如果有 SyncHandler，使用组合处理器
 	if cfg.SyncHandler != nil || cfg.WorkflowHandler != nil {
-		mux.Handle("/api/v1/documents/", authWrap(http.HandlerFunc(
+		mux.Handle("/api/v1/documents/", scoped(documentRouteScope, http.HandlerFunc(
 			combineDocumentRoutes(cfg.Handler, cfg.SyncHandler, cfg.WorkflowHandler),
 		)))
 	} else {
-		mux.Handle("/api/v1/documents/", authWrap(http.HandlerFunc(cfg.Handler.DocumentRoutes)))
+		mux.Handle("/api/v1/documents/", scoped(documentRouteScope, http.HandlerFunc(cfg.Handler.DocumentRoutes)))
 	}
-	// 节点路由：如果有 WorkflowHandler 或 BatchHandler，使用组合处理器
-	if cfg.WorkflowHandler != nil || cfg.BatchHandler != nil {
-		mux.Handle("/api/v1/nodes/", authWrap(http.HandlerFunc(
-			combineNodeRoutes(cfg.Handler, cfg.WorkflowHandler, cfg.BatchHandler),
+	// 节点路由：如果有 WorkflowHandler、BatchHandler 或 MigrationHandler，使用组合处理器
+	if cfg.WorkflowHandler != nil || cfg.BatchHandler != nil || cfg.MigrationHandler != nil {
+		mux.Handle("/api/v1/nodes/", scoped(nodeRouteScope, http.HandlerFunc(
+			combineNodeRoutes(cfg.Handler, cfg.WorkflowHandler, cfg.BatchHandler, cfg.MigrationHandler),
 		)))
 	} else {
-		mux.Handle("/api/v1/nodes/", authWrap(http.HandlerFunc(cfg.Handler.NodeRoutes)))
+		mux.Handle("/api/v1/nodes/", scoped(nodeRouteScope, http.HandlerFunc(cfg.Handler.NodeRoutes)))
 	}
 
 	// 路径解析端点（需要认证）
-	mux.Handle("/api/v1/resolve/", authWrap(http.HandlerFunc(cfg.Handler.ResolveRoutes)))
+	mux.Handle("/api/v1/resolve/", scoped(auth.Scope(auth.ScopeDocumentsRead), http.HandlerFunc(cfg.Handler.ResolveRoutes)))
 
 	// Assets 端点（需要认证）
 	if cfg.AssetsHandler != nil {
-		mux.Handle("/api/v1/assets", authWrap(http.HandlerFunc(cfg.AssetsHandler.Assets)))
-		mux.Handle("/api/v1/assets/", authWrap(http.HandlerFunc(cfg.AssetsHandler.AssetRoutes)))
+		mux.Handle("/api/v1/assets", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.AssetsHandler.Assets)))
+		mux.Handle("/api/v1/assets/", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.AssetsHandler.AssetRoutes)))
 	}
 
 	// Sync 端点（MySQL 同步）
 	if cfg.SyncHandler != nil {
-		// 同步回调端点（不需要 JWT 认证，由 Webhook Secret 验证）
-		mux.Handle("/api/v1/sync/", wrap(http.HandlerFunc(cfg.SyncHandler.SyncRoutes)))
-		// 内部 API（供 IDPP 调用，使用 API Key 认证）
-		mux.Handle("/api/internal/documents/", wrap(http.HandlerFunc(cfg.SyncHandler.InternalDocumentRoutes)))
+		// 同步回调端点（不需要 JWT 认证，由 Webhook 签名验证）
+		mux.Handle("/api/v1/sync/", wrap(webhookAuth(http.HandlerFunc(cfg.SyncHandler.SyncRoutes))))
+		// 内部 API（供 IDPP 调用，使用 API Key 认证，需要 snapshots:read）
+		mux.Handle("/api/internal/documents/", scoped(auth.Scope(auth.ScopeSnapshotsRead), http.HandlerFunc(cfg.SyncHandler.InternalDocumentRoutes)))
 	}
 
 	// Workflow 端点（节点工作流）
 	if cfg.WorkflowHandler != nil {
-		// 回调端点（不需要 JWT 认证）
-		mux.Handle("/api/v1/workflows/callback/", wrap(http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes)))
+		// 回调端点（不需要 JWT 认证，由 Webhook 签名验证）
+		mux.Handle("/api/v1/workflows/callback/", wrap(webhookAuth(http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes))))
 		// 工作流定义和运行记录（需要认证）
-		mux.Handle("/api/v1/workflows", authWrap(http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes)))
-		mux.Handle("/api/v1/workflows/", authWrap(http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes)))
+		mux.Handle("/api/v1/workflows", scoped(readOr(auth.ScopeWorkflowsTrigger), http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes)))
+		mux.Handle("/api/v1/workflows/", scoped(readOr(auth.ScopeWorkflowsTrigger), http.HandlerFunc(cfg.WorkflowHandler.WorkflowRoutes)))
 	}
 
 	// Admin Workflow 管理端点（需要认证）
 	if cfg.AdminWorkflowHandler != nil {
-		mux.Handle("/api/v1/admin/workflows/sync/status", authWrap(http.HandlerFunc(cfg.AdminWorkflowHandler.GetSyncStatus)))
-		mux.Handle("/api/v1/admin/workflows/sync", authWrap(http.HandlerFunc(cfg.AdminWorkflowHandler.TriggerSync)))
-		mux.Handle("/api/v1/admin/workflows", authWrap(http.HandlerFunc(cfg.AdminWorkflowHandler.ListWorkflowDefinitions)))
-		mux.Handle("/api/v1/admin/workflows/", authWrap(http.HandlerFunc(handleAdminWorkflowRoutes(cfg.AdminWorkflowHandler))))
+		mux.Handle("/api/v1/admin/workflows/sync/status", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.AdminWorkflowHandler.GetSyncStatus)))
+		mux.Handle("/api/v1/admin/workflows/sync", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.AdminWorkflowHandler.TriggerSync)))
+		mux.Handle("/api/v1/admin/workflows", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.AdminWorkflowHandler.ListWorkflowDefinitions)))
+		mux.Handle("/api/v1/admin/workflows/", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(handleAdminWorkflowRoutes(cfg.AdminWorkflowHandler))))
+	}
+
+	// 审计日志端点（需要认证，仅限管理员）
+	if cfg.AuditHandler != nil {
+		mux.Handle("/api/v1/admin/audit", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.AuditHandler.ListAuditEvents)))
+		mux.Handle("/api/v1/admin/audit/export", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.AuditHandler.ExportAuditEvents)))
 	}
 
 	// 批量操作端点（需要认证）
 	if cfg.BatchHandler != nil {
 		// 批量工作流
-		mux.Handle("/api/v1/workflows/batches", authWrap(http.HandlerFunc(cfg.BatchHandler.BatchWorkflowRoutes)))
-		mux.Handle("/api/v1/workflows/batches/", authWrap(http.HandlerFunc(cfg.BatchHandler.BatchWorkflowRoutes)))
+		mux.Handle("/api/v1/workflows/batches", scoped(readOr(auth.ScopeWorkflowsTrigger), http.HandlerFunc(cfg.BatchHandler.BatchWorkflowRoutes)))
+		mux.Handle("/api/v1/workflows/batches/", scoped(readOr(auth.ScopeWorkflowsTrigger), http.HandlerFunc(cfg.BatchHandler.BatchWorkflowRoutes)))
 		// 批量同步
-		mux.Handle("/api/v1/sync/batches", authWrap(http.HandlerFunc(cfg.BatchHandler.BatchSyncRoutes)))
-		mux.Handle("/api/v1/sync/batches/", authWrap(http.HandlerFunc(cfg.BatchHandler.BatchSyncRoutes)))
+		mux.Handle("/api/v1/sync/batches", scoped(readOr(auth.ScopeSyncTrigger), http.HandlerFunc(cfg.BatchHandler.BatchSyncRoutes)))
+		mux.Handle("/api/v1/sync/batches/", scoped(readOr(auth.ScopeSyncTrigger), http.HandlerFunc(cfg.BatchHandler.BatchSyncRoutes)))
+	}
+
+	// 文档类型迁移端点（需要认证）
+	if cfg.MigrationHandler != nil {
+		mux.Handle("/api/v1/migrations", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.MigrationHandler.MigrationRoutes)))
+		mux.Handle("/api/v1/migrations/", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.MigrationHandler.MigrationRoutes)))
+	}
+
+	// 分类批量操作日志端点（需要认证，继续/撤销仅限发起者或超级管理员）
+	if cfg.BulkOperationHandler != nil {
+		mux.Handle("/api/v1/admin/bulk-operations", scoped(readOr(auth.ScopeCategoriesAdmin), http.HandlerFunc(cfg.BulkOperationHandler.ListOperations)))
+		mux.Handle("/api/v1/admin/bulk-operations/", scoped(readOr(auth.ScopeCategoriesAdmin), http.HandlerFunc(cfg.BulkOperationHandler.OperationRoutes)))
+	}
+
+	// 文档/节点锁端点（需要认证；管理视图与强制解除仅限超级管理员）
+	if cfg.LockHandler != nil {
+		mux.Handle("/api/v1/locks/", scoped(readOr(auth.ScopeDocumentsWrite), http.HandlerFunc(cfg.LockHandler.LockRoutes)))
+		mux.Handle("/api/v1/admin/locks", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.LockHandler.ListLocks)))
+		mux.Handle("/api/v1/admin/locks/", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.LockHandler.AdminLockRoutes)))
+	}
+
+	// 全文检索端点（需要认证，结果按用户的课程权限过滤）
+	if cfg.SearchHandler != nil {
+		mux.Handle("/api/v1/search", scoped(auth.Scope(auth.ScopeDocumentsRead), http.HandlerFunc(cfg.SearchHandler.Search)))
+		mux.Handle("/api/v1/admin/search/reindex", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.SearchHandler.Reindex)))
+	}
+
+	// 文档引用索引端点（需要认证；悬空引用报表按用户的课程权限过滤，重建需要 courses:all）
+	if cfg.ReferenceHandler != nil {
+		mux.Handle("/api/v1/admin/references/dangling", scoped(auth.Scope(auth.ScopeDocumentsRead), http.HandlerFunc(cfg.ReferenceHandler.DanglingReferences)))
+		mux.Handle("/api/v1/admin/references/rebuild", scoped(auth.Scope(auth.ScopeUsersAdmin), http.HandlerFunc(cfg.ReferenceHandler.Rebuild)))
 	}
 
 	// 静态资源代理（/ndr-assets/* -> MinIO）
@@ -183,6 +250,19 @@ func handleUserRoutes(h *UserHandler) http.HandlerFunc {
 		// GET /api/v1/users/:id
 		// DELETE /api/v1/users/:id
 		if len(path) > len("/api/v1/users/") {
+			// GET/DELETE /api/v1/users/:id/sessions
+			if strings.HasSuffix(path, "/sessions") {
+				switch r.Method {
+				case http.MethodGet:
+					h.ListUserSessions(w, r)
+				case http.MethodDelete:
+					h.RevokeUserSessions(w, r)
+				default:
+					respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+				}
+				return
+			}
+
 			// 检查是否是课程权限相关路由
 			if strings.Contains(path, "/courses") {
 				if strings.HasSuffix(path, "/courses") && r.Method == http.MethodGet {
@@ -226,6 +306,18 @@ func handleCourseRoutes(h *CourseHandler) http.HandlerFunc {
 			return
 		}
 
+		// POST /api/v1/courses/import
+		if path == "/api/v1/courses/import" {
+			h.ImportCourse(w, r)
+			return
+		}
+
+		// GET /api/v1/courses/:id/export
+		if strings.HasSuffix(path, "/export") {
+			h.ExportCourse(w, r)
+			return
+		}
+
 		// DELETE /api/v1/courses/:id
 		if len(path) > len("/api/v1/courses/") && r.Method == http.MethodDelete {
 			h.DeleteCourse(w, r)
@@ -256,6 +348,35 @@ func (h *Handler) applyAuthMiddleware(jwtSecret string, db *gorm.DB) func(http.H
 	}
 }
 
+// documentRouteScope 决定 /api/v1/documents/* 所需的 API Key scope
+// 同步与工作流子路由的写操作需要各自的触发 scope
+func documentRouteScope(r *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/documents/"), "/")
+	if len(parts) >= 2 && r.Method == http.MethodPost {
+		switch parts[1] {
+		case "sync":
+			return auth.ScopeSyncTrigger
+		case "workflows":
+			return auth.ScopeWorkflowsTrigger
+		}
+	}
+	return auth.MethodScope(auth.ScopeDocumentsRead, auth.ScopeDocumentsWrite)(r)
+}
+
+// nodeRouteScope 决定 /api/v1/nodes/* 所需的 API Key scope
+func nodeRouteScope(r *http.Request) string {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/nodes/"), "/")
+	if len(parts) >= 2 && r.Method == http.MethodPost {
+		switch parts[1] {
+		case "sync":
+			return auth.ScopeSyncTrigger
+		case "workflows":
+			return auth.ScopeWorkflowsTrigger
+		}
+	}
+	return auth.MethodScope(auth.ScopeDocumentsRead, auth.ScopeDocumentsWrite)(r)
+}
+
 // authMiddlewareWrapper 认证中间件包装器（支持 JWT 和 API Key）
 func authMiddlewareWrapper(jwtSecret string, db *gorm.DB) func(http.Handler) http.Handler {
 	if db != nil {
@@ -263,7 +384,7 @@ func authMiddlewareWrapper(jwtSecret string, db *gorm.DB) func(http.Handler) htt
 		return auth.FlexibleAuthMiddleware(db, jwtSecret)
 	}
 	// 降级为仅支持 JWT
-	return auth.AuthMiddleware(jwtSecret)
+	return auth.AuthMiddleware(jwtSecret, nil)
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
@@ -386,9 +507,9 @@ func handleAdminWorkflowRoutes(h *AdminWorkflowHandler) http.HandlerFunc {
 	}
 }
 
-// combineNodeRoutes 组合节点路由、工作流路由和批量操作路由
-// 根据路径判断是否是工作流或批量操作相关请求，分发到对应的处理器
-func combineNodeRoutes(h *Handler, wh *WorkflowHandler, bh *BatchHandler) http.HandlerFunc {
+// combineNodeRoutes 组合节点路由、工作流路由、批量操作路由和类型迁移路由
+// 根据路径判断是否是工作流、批量操作或类型迁移相关请求，分发到对应的处理器
+func combineNodeRoutes(h *Handler, wh *WorkflowHandler, bh *BatchHandler, mh *MigrationHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		relPath := strings.TrimPrefix(path, "/api/v1/nodes/")
@@ -420,6 +541,12 @@ func combineNodeRoutes(h *Handler, wh *WorkflowHandler, bh *BatchHandler) http.H
 			}
 		}
 
+		// /api/v1/nodes/{id}/migrations/batch/*
+		if mh != nil && len(parts) >= 4 && parts[1] == "migrations" && parts[2] == "batch" {
+			mh.handleNodeMigration(w, r)
+			return
+		}
+
 		// 检查是否是工作流相关路由
 		// /api/v1/nodes/{id}/workflows
 		// /api/v1/nodes/{id}/workflows/{workflowKey}/runs
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/service"
+)
+
+// SearchHandler 全文检索处理器
+type SearchHandler struct {
+	searchService *service.SearchService
+}
+
+// NewSearchHandler 创建全文检索处理器
+func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search 检索文档
+// GET /api/v1/search?q=关键词&type=文档类型&page=1&size=20
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if user, ok := r.Context().Value(auth.UserContextKey).(*database.User); !ok || user == nil {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found in context"))
+		return
+	}
+
+	query := r.URL.Query()
+	req := service.SearchRequest{
+		Query: query.Get("q"),
+		Type:  query.Get("type"),
+	}
+	if v := query.Get("page"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			req.Page = n
+		}
+	}
+	if v := query.Get("size"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			req.Size = n
+		}
+	}
+
+	resp, err := h.searchService.Search(r.Context(), metaFromRequestContext(r), req)
+	if err != nil {
+		var vErr *service.ValidationError
+		if errors.As(err, &vErr) {
+			respondAPIError(w, NewAPIError(ErrCodeValidation, http.StatusBadRequest, "检索参数无效", vErr.Error()))
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Reindex 与 NDR 全量对账并重建索引（需要 courses:all）
+// POST /api/v1/admin/search/reindex
+func (h *SearchHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if !requireRolePermissions(w, r, h.searchService.Roles(), "rebuild the search index", database.PermCoursesAll) {
+		return
+	}
+	result, err := h.searchService.Reconcile(r.Context(), true)
+	if err != nil {
+		respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
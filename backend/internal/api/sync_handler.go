@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,17 +15,15 @@ import (
 
 // SyncHandler handles document sync API endpoints.
 type SyncHandler struct {
-	service       *service.SyncService
-	webhookSecret string
-	ndrAPIKey     string // NDR API Key for internal calls
+	service   *service.SyncService
+	ndrAPIKey string // NDR API Key for internal calls
 }
 
 // NewSyncHandler creates a new SyncHandler.
-func NewSyncHandler(svc *service.SyncService, webhookSecret string, ndrAPIKey string) *SyncHandler {
+func NewSyncHandler(svc *service.SyncService, ndrAPIKey string) *SyncHandler {
 	return &SyncHandler{
-		service:       svc,
-		webhookSecret: webhookSecret,
-		ndrAPIKey:     ndrAPIKey,
+		service:   svc,
+		ndrAPIKey: ndrAPIKey,
 	}
 }
 
@@ -32,7 +31,7 @@ func NewSyncHandler(svc *service.SyncService, webhookSecret string, ndrAPIKey st
 func (h *SyncHandler) SyncRoutes(w http.ResponseWriter, r *http.Request) {
 	relPath := strings.TrimPrefix(r.URL.Path, "/api/v1/sync/")
 
-	// /api/v1/sync/callback - 回调端点（不需要用户认证，需要 webhook secret）
+	// /api/v1/sync/callback - 回调端点（不需要用户认证，由路由层校验 webhook 签名）
 	if relPath == "callback" {
 		h.handleCallback(w, r)
 		return
@@ -96,9 +95,9 @@ func (h *SyncHandler) triggerSync(w http.ResponseWriter, r *http.Request, docID
 		return
 	}
 
-	// 权限检查：校对员不能触发同步
-	if currentUser.Role == "proofreader" {
-		respondError(w, http.StatusForbidden, errors.New("proofreader cannot trigger sync"))
+	// 权限检查：角色需要 sync:trigger 权限
+	if !h.service.CanTriggerSync(r.Context(), currentUser.Role) {
+		respondError(w, http.StatusForbidden, fmt.Errorf("role '%s' cannot trigger sync", currentUser.Role))
 		return
 	}
 
@@ -154,18 +153,6 @@ func (h *SyncHandler) handleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify webhook secret (required for callback)
-	if h.webhookSecret == "" {
-		respondError(w, http.StatusInternalServerError, errors.New("webhook secret not configured"))
-		return
-	}
-
-	providedSecret := r.Header.Get("X-Webhook-Secret")
-	if providedSecret != h.webhookSecret {
-		respondError(w, http.StatusUnauthorized, errors.New("invalid webhook secret"))
-		return
-	}
-
 	var callback service.SyncCallbackRequest
 	if err := json.NewDecoder(r.Body).Decode(&callback); err != nil {
 		respondError(w, http.StatusBadRequest, errors.New("invalid request body"))
@@ -197,7 +184,7 @@ func (h *SyncHandler) getDocumentSnapshot(w http.ResponseWriter, r *http.Request
 	// 请求中的 API Key 是 PDMS API Key (ydms_*)，用于验证调用方身份
 	// 但调用 NDR 需要使用配置的 NDR API Key (ndr_*)
 	meta := service.RequestMeta{
-		APIKey:    h.ndrAPIKey, // 使用配置的 NDR API Key
+		APIKey:    h.ndrAPIKey,     // 使用配置的 NDR API Key
 		UserID:    "idpp-internal", // 内部调用标识
 		RequestID: r.Header.Get("x-request-id"),
 	}
@@ -37,9 +37,9 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以列出用户
-	if user.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super administrators can list users"))
+	// 只有拥有 users:manage 权限的角色可以列出用户
+	if !h.canManageUsers(r, user) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to list users"))
 		return
 	}
 
@@ -91,14 +91,14 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 权限检查：只有超级管理员可以创建用户
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super administrators can create users"))
+	// 权限检查：只有拥有 users:manage 权限的角色可以创建用户
+	if !h.canManageUsers(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to create users"))
 		return
 	}
 
 	// 创建用户
-	newUser, err := h.userService.CreateUser(req.Username, req.Password, req.Role, &currentUser.ID)
+	newUser, err := h.userService.CreateUser(r.Context(), metaFromRequestContext(r), req.Username, req.Password, req.Role, &currentUser.ID)
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err)
 		return
@@ -153,9 +153,9 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 只有超级管理员可以删除用户
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super administrators can delete users"))
+	// 只有拥有 users:manage 权限的角色可以删除用户
+	if !h.canManageUsers(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to delete users"))
 		return
 	}
 
@@ -175,7 +175,7 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 删除用户
-	err = h.userService.DeleteUser(uint(userID))
+	err = h.userService.DeleteUser(r.Context(), metaFromRequestContext(r), uint(userID))
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
@@ -201,9 +201,9 @@ func (h *UserHandler) GrantCoursePermission(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// 只有超级管理员可以授予权限
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super administrators can grant course permissions"))
+	// 只有拥有 users:manage 权限的角色可以授予权限
+	if !h.canManageUsers(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to grant course permissions"))
 		return
 	}
 
@@ -222,7 +222,8 @@ func (h *UserHandler) GrantCoursePermission(w http.ResponseWriter, r *http.Reque
 
 	// 解析请求
 	var req struct {
-		RootNodeID int64 `json:"root_node_id"`
+		RootNodeID int64  `json:"root_node_id"`
+		Role       string `json:"role"` // 课程内角色，为空时沿用用户的全局角色
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -231,8 +232,12 @@ func (h *UserHandler) GrantCoursePermission(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 授予权限
-	err = h.userService.GrantCoursePermission(uint(userID), req.RootNodeID)
+	err = h.userService.GrantCoursePermission(r.Context(), metaFromRequestContext(r), uint(userID), req.RootNodeID, req.Role)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidRole) {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -257,9 +262,9 @@ func (h *UserHandler) RevokeCoursePermission(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// 只有超级管理员可以撤销权限
-	if currentUser.Role != "super_admin" {
-		respondError(w, http.StatusForbidden, errors.New("only super administrators can revoke course permissions"))
+	// 只有拥有 users:manage 权限的角色可以撤销权限
+	if !h.canManageUsers(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to revoke course permissions"))
 		return
 	}
 
@@ -283,7 +288,7 @@ func (h *UserHandler) RevokeCoursePermission(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 撤销权限
-	err = h.userService.RevokeCoursePermission(uint(userID), nodeID)
+	err = h.userService.RevokeCoursePermission(r.Context(), metaFromRequestContext(r), uint(userID), nodeID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
@@ -315,14 +320,106 @@ func (h *UserHandler) GetUserCourses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取课程权限
-	courses, err := h.userService.GetUserCourses(uint(userID))
+	// 获取课程权限及各课程中的角色
+	courses, err := h.userService.GetUserCourseRoles(uint(userID))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	courseIDs := make([]int64, len(courses))
+	for i, c := range courses {
+		courseIDs[i] = c.RootNodeID
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"course_ids": courseIDs,
+		"courses":    courses,
+	})
+}
+
+// ListUserSessions 列出用户的有效会话
+// GET /api/v1/users/:id/sessions
+func (h *UserHandler) ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	// 获取当前用户
+	currentUser, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found"))
+		return
+	}
+
+	// 从 URL 解析用户 ID
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/")
+	userID, err := strconv.ParseUint(pathParts[0], 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errors.New("invalid user id"))
+		return
+	}
+
+	// 拥有 users:manage 权限时可以查看任意用户，其他用户只能查看自己
+	if !h.canManageUsers(r, currentUser) && currentUser.ID != uint(userID) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to view other users' sessions"))
+		return
+	}
+
+	sessions, err := h.userService.ListActiveSessions(uint(userID))
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"course_ids": courses,
+		"sessions": sessions,
 	})
 }
+
+// RevokeUserSessions 注销用户的所有会话
+// DELETE /api/v1/users/:id/sessions
+func (h *UserHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	// 获取当前用户
+	currentUser, ok := r.Context().Value(auth.UserContextKey).(*database.User)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, errors.New("user not found"))
+		return
+	}
+
+	// 只有拥有 users:manage 权限的角色可以注销用户的所有会话
+	if !h.canManageUsers(r, currentUser) {
+		respondError(w, http.StatusForbidden, errors.New("requires users:manage to revoke user sessions"))
+		return
+	}
+
+	// 从 URL 解析用户 ID
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/")
+	userID, err := strconv.ParseUint(pathParts[0], 10, 32)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errors.New("invalid user id"))
+		return
+	}
+
+	revoked, err := h.userService.RevokeUserSessions(uint(userID), "", database.SessionRevokedLogoutAll)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "sessions revoked successfully",
+		"revoked": revoked,
+	})
+}
+
+// canManageUsers 当前用户的角色是否拥有用户管理权限
+func (h *UserHandler) canManageUsers(r *http.Request, user *database.User) bool {
+	return h.userService.Roles().HasPermission(r.Context(), user.Role, database.PermUsersManage)
+}
@@ -39,7 +39,12 @@ func APIKeyAuthMiddleware(db *gorm.DB) func(http.Handler) http.Handler {
 			}
 
 			// 验证 API Key 并获取关联用户
-			user, err := ValidateAPIKey(db, apiKey)
+			dbKey, err := LookupAPIKey(db, apiKey)
+			if err != nil {
+				respondError(w, http.StatusUnauthorized, errors.New("invalid API key: "+err.Error()))
+				return
+			}
+			scopes, err := ParseScopes(dbKey.Scopes)
 			if err != nil {
 				respondError(w, http.StatusUnauthorized, errors.New("invalid API key: "+err.Error()))
 				return
@@ -48,8 +53,9 @@ func APIKeyAuthMiddleware(db *gorm.DB) func(http.Handler) http.Handler {
 			// 更新最后使用时间（异步，不阻塞请求）
 			go updateAPIKeyLastUsed(db, apiKey)
 
-			// 将用户信息存入 context
-			ctx := context.WithValue(r.Context(), UserContextKey, user)
+			// 将用户信息和 scopes 存入 context
+			ctx := context.WithValue(r.Context(), UserContextKey, &dbKey.User)
+			ctx = context.WithValue(ctx, APIKeyScopesContextKey, scopes)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -63,11 +69,17 @@ func FlexibleAuthMiddleware(db *gorm.DB, jwtSecret string) func(http.Handler) ht
 			// 优先尝试 API Key 认证
 			apiKey := extractAPIKey(r)
 			if apiKey != "" {
-				user, err := ValidateAPIKey(db, apiKey)
+				dbKey, err := LookupAPIKey(db, apiKey)
 				if err == nil {
-					// API Key 认证成功
+					scopes, scopeErr := ParseScopes(dbKey.Scopes)
+					if scopeErr != nil {
+						respondError(w, http.StatusUnauthorized, errors.New("invalid API key"))
+						return
+					}
+					// API Key 认证成功，scopes 交给 RequireScope 检查
 					go updateAPIKeyLastUsed(db, apiKey)
-					ctx := context.WithValue(r.Context(), UserContextKey, user)
+					ctx := context.WithValue(r.Context(), UserContextKey, &dbKey.User)
+					ctx = context.WithValue(ctx, APIKeyScopesContextKey, scopes)
 					next.ServeHTTP(w, r.WithContext(ctx))
 					return
 				}
@@ -95,6 +107,10 @@ func FlexibleAuthMiddleware(db *gorm.DB, jwtSecret string) func(http.Handler) ht
 				respondError(w, http.StatusUnauthorized, errors.New("invalid token: "+err.Error()))
 				return
 			}
+			if err := CheckSession(db, claims); err != nil {
+				respondError(w, http.StatusUnauthorized, errors.New("invalid token: "+err.Error()))
+				return
+			}
 
 			// JWT 认证成功
 			ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
@@ -133,6 +149,15 @@ func extractAPIKey(r *http.Request) string {
 
 // ValidateAPIKey 验证 API Key 并返回关联的用户
 func ValidateAPIKey(db *gorm.DB, apiKey string) (*database.User, error) {
+	dbKey, err := LookupAPIKey(db, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return &dbKey.User, nil
+}
+
+// LookupAPIKey 验证 API Key 并返回完整的 API Key 记录（含关联用户与 scopes）
+func LookupAPIKey(db *gorm.DB, apiKey string) (*database.APIKey, error) {
 	// 计算 API Key 的哈希值
 	keyHash := HashAPIKey(apiKey)
 
@@ -163,7 +188,7 @@ func ValidateAPIKey(db *gorm.DB, apiKey string) (*database.User, error) {
 		return nil, errors.New("associated user has been deleted")
 	}
 
-	return &dbKey.User, nil
+	return &dbKey, nil
 }
 
 // updateAPIKeyLastUsed 更新 API Key 的最后使用时间
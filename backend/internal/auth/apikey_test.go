@@ -17,7 +17,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// 自动迁移
-	if err := db.AutoMigrate(&database.User{}, &database.APIKey{}); err != nil {
+	if err := db.AutoMigrate(&database.User{}, &database.APIKey{}, &database.UserSession{}); err != nil {
 		t.Fatalf("failed to migrate test database: %v", err)
 	}
 
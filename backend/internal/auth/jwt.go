@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims JWT 声明结构
@@ -15,14 +16,21 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT token
+// GenerateToken 生成 JWT token（使用随机 jti）
 func GenerateToken(userID uint, username, role string, secret string, expiry time.Duration) (string, error) {
+	return GenerateTokenWithID(userID, username, role, uuid.NewString(), secret, expiry)
+}
+
+// GenerateTokenWithID 生成带指定 jti 的 JWT token
+// jti 与 user_sessions.token_id 对应，用于服务端吊销
+func GenerateTokenWithID(userID uint, username, role, tokenID string, secret string, expiry time.Duration) (string, error) {
 	now := time.Now()
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"gorm.io/gorm"
+
 	"github.com/yjxt/ydms/backend/internal/database"
 )
 
@@ -20,7 +22,8 @@ const (
 )
 
 // AuthMiddleware JWT 认证中间件
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// db 不为空时会检查 token 对应的会话是否已被吊销
+func AuthMiddleware(jwtSecret string, db *gorm.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// 从 Authorization header 获取 token
@@ -46,6 +49,14 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 				return
 			}
 
+			// 检查会话是否已吊销
+			if db != nil {
+				if err := CheckSession(db, claims); err != nil {
+					respondError(w, http.StatusUnauthorized, errors.New("invalid token: "+err.Error()))
+					return
+				}
+			}
+
 			// 将 claims 存入 context
 			ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
 
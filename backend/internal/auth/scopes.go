@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// API Key 权限范围（scope）词汇表
+const (
+	// ScopeAll 通配符，拥有关联用户的全部权限
+	ScopeAll = "*"
+	// ScopeDocumentsRead 读取分类树、文档、版本、节点与资源
+	ScopeDocumentsRead = "documents:read"
+	// ScopeDocumentsWrite 创建、修改、删除文档与资源
+	ScopeDocumentsWrite = "documents:write"
+	// ScopeSnapshotsRead 读取内部文档快照（供 IDPP 调用）
+	ScopeSnapshotsRead = "snapshots:read"
+	// ScopeWorkflowsTrigger 触发、取消工作流（含批量工作流）
+	ScopeWorkflowsTrigger = "workflows:trigger"
+	// ScopeSyncTrigger 触发 MySQL 同步（含批量同步）
+	ScopeSyncTrigger = "sync:trigger"
+	// ScopeCategoriesAdmin 创建、修改、移动、删除分类
+	ScopeCategoriesAdmin = "categories:admin"
+	// ScopeUsersAdmin 管理用户、课程权限、API Key 与工作流定义
+	ScopeUsersAdmin = "users:admin"
+)
+
+// KnownScopes 所有可分配给 API Key 的 scope
+var KnownScopes = []string{
+	ScopeAll,
+	ScopeDocumentsRead,
+	ScopeDocumentsWrite,
+	ScopeSnapshotsRead,
+	ScopeWorkflowsTrigger,
+	ScopeSyncTrigger,
+	ScopeCategoriesAdmin,
+	ScopeUsersAdmin,
+}
+
+// APIKeyScopesContextKey context 中存储 API Key scopes 的 key
+// 仅在通过 API Key 认证时存在；JWT 认证的请求不受 scope 限制
+const APIKeyScopesContextKey contextKey = "api_key_scopes"
+
+// IsKnownScope 判断 scope 是否在词汇表中
+func IsKnownScope(scope string) bool {
+	for _, s := range KnownScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScopes 校验 scopes 是否均为已知 scope
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if !IsKnownScope(s) {
+			return fmt.Errorf("unknown scope: %s", s)
+		}
+	}
+	return nil
+}
+
+// ParseScopes 解析数据库中存储的 scopes（JSON 数组字符串）
+// 为兼容引入 scope 之前创建的 API Key，空值视为 ScopeAll
+func ParseScopes(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []string{ScopeAll}, nil
+	}
+	var scopes []string
+	if err := json.Unmarshal([]byte(raw), &scopes); err != nil {
+		return nil, fmt.Errorf("invalid scopes: %w", err)
+	}
+	if len(scopes) == 0 {
+		return []string{ScopeAll}, nil
+	}
+	return scopes, nil
+}
+
+// HasScope 判断 scopes 中是否包含所需 scope
+func HasScope(scopes []string, required string) bool {
+	if required == "" {
+		return true
+	}
+	for _, s := range scopes {
+		if s == ScopeAll || s == required {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeResolver 根据请求决定所需的 scope，返回空字符串表示无需 scope
+type ScopeResolver func(r *http.Request) string
+
+// Scope 返回固定 scope 的 ScopeResolver
+func Scope(scope string) ScopeResolver {
+	return func(*http.Request) string {
+		return scope
+	}
+}
+
+// MethodScope 按 HTTP 方法区分读写 scope 的 ScopeResolver
+// GET/HEAD/OPTIONS 需要 readScope，其余方法需要 writeScope
+func MethodScope(readScope, writeScope string) ScopeResolver {
+	return func(r *http.Request) string {
+		if isReadMethod(r.Method) {
+			return readScope
+		}
+		return writeScope
+	}
+}
+
+// RequireScope scope 检查中间件
+// 必须放在认证中间件之后；仅对 API Key 认证的请求生效
+func RequireScope(resolve ScopeResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(APIKeyScopesContextKey).([]string)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			required := resolve(r)
+			if !HasScope(scopes, required) {
+				respondError(w, http.StatusForbidden, fmt.Errorf("API key is missing required scope: %s", required))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"empty means all", "", []string{ScopeAll}, false},
+		{"empty array means all", "[]", []string{ScopeAll}, false},
+		{"explicit scopes", `["documents:read","sync:trigger"]`, []string{ScopeDocumentsRead, ScopeSyncTrigger}, false},
+		{"invalid json", "documents:read", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ParseScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	if err := ValidateScopes([]string{ScopeDocumentsRead, ScopeWorkflowsTrigger}); err != nil {
+		t.Errorf("ValidateScopes() unexpected error: %v", err)
+	}
+	if err := ValidateScopes([]string{"documents:delete"}); err == nil {
+		t.Errorf("ValidateScopes() should reject unknown scope")
+	}
+}
+
+func TestFlexibleAuthMiddleware_Scopes(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := database.User{
+		Username:     "scripter",
+		PasswordHash: "hash",
+		Role:         "course_admin",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	createKey := func(scopes string) string {
+		apiKey, err := GenerateAPIKey("test")
+		if err != nil {
+			t.Fatalf("failed to generate API key: %v", err)
+		}
+		dbKey := database.APIKey{
+			Name:        "Scoped Key",
+			KeyHash:     HashAPIKey(apiKey),
+			KeyPrefix:   apiKey[:16],
+			UserID:      user.ID,
+			Scopes:      scopes,
+			CreatedByID: user.ID,
+		}
+		if err := db.Create(&dbKey).Error; err != nil {
+			t.Fatalf("failed to create API key: %v", err)
+		}
+		return apiKey
+	}
+
+	readOnlyKey := createKey(`["documents:read"]`)
+	syncKey := createKey(`["sync:trigger"]`)
+	legacyKey := createKey("")
+
+	handler := FlexibleAuthMiddleware(db, "secret")(
+		RequireScope(MethodScope(ScopeDocumentsRead, ScopeDocumentsWrite))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}),
+		),
+	)
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		method     string
+		wantStatus int
+	}{
+		{"read-only key can read", readOnlyKey, http.MethodGet, http.StatusOK},
+		{"read-only key cannot write", readOnlyKey, http.MethodPost, http.StatusForbidden},
+		{"sync key cannot read documents", syncKey, http.MethodGet, http.StatusForbidden},
+		{"legacy key without scopes keeps full access", legacyKey, http.MethodPost, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/documents", nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(rec.Body.String(), "documents:") {
+				t.Errorf("expected missing scope in body, got %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+// CheckSession 检查 JWT 对应的会话是否仍然有效
+// token 必须携带 jti，且 user_sessions 中存在未吊销、未过期的记录
+func CheckSession(db *gorm.DB, claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no session id")
+	}
+
+	var session database.UserSession
+	err := db.Where("token_id = ?", claims.ID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("session not found")
+		}
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	if session.UserID != claims.UserID {
+		return errors.New("session does not belong to token user")
+	}
+
+	if session.RevokedAt != nil {
+		return errors.New("token has been revoked")
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		return errors.New("session has expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+func TestAuthMiddleware_SessionRevocation(t *testing.T) {
+	db := setupTestDB(t)
+	const secret = "test-secret"
+
+	user := database.User{
+		Username:     "editor",
+		PasswordHash: "hash",
+		Role:         "course_admin",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	session := database.UserSession{
+		UserID:    user.ID,
+		TokenID:   "session-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	token, err := GenerateTokenWithID(user.ID, user.Username, user.Role, session.TokenID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	orphanToken, err := GenerateToken(user.ID, user.Username, user.Role, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middlewares := map[string]http.Handler{
+		"AuthMiddleware":         AuthMiddleware(secret, db)(ok),
+		"FlexibleAuthMiddleware": FlexibleAuthMiddleware(db, secret)(ok),
+	}
+
+	do := func(h http.Handler, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for name, h := range middlewares {
+		if code := do(h, token); code != http.StatusOK {
+			t.Errorf("%s: active session status = %d, want 200", name, code)
+		}
+		if code := do(h, orphanToken); code != http.StatusUnauthorized {
+			t.Errorf("%s: token without session status = %d, want 401", name, code)
+		}
+	}
+
+	now := time.Now()
+	if err := db.Model(&session).Updates(map[string]interface{}{
+		"revoked_at":     now,
+		"revoked_reason": database.SessionRevokedLogout,
+	}).Error; err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+
+	for name, h := range middlewares {
+		if code := do(h, token); code != http.StatusUnauthorized {
+			t.Errorf("%s: revoked session status = %d, want 401", name, code)
+		}
+	}
+}
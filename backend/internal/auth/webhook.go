@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+)
+
+// Webhook 签名相关的请求头
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp" // Unix 秒
+	WebhookNonceHeader     = "X-Webhook-Nonce"     // 可选，参与签名，用于去重
+	WebhookSignatureHeader = "X-Webhook-Signature" // sha256=<hex>
+	// WebhookLegacySecretHeader 旧版明文密钥头，仅在 AllowLegacySecret 开启时接受，下个版本移除
+	WebhookLegacySecretHeader = "X-Webhook-Secret"
+)
+
+// webhookReplayKeyPrefix 共享去重记录在缓存中的 key 前缀
+const webhookReplayKeyPrefix = "webhook:replay:"
+
+// DefaultWebhookMaxSkew 默认允许的时钟偏差
+const DefaultWebhookMaxSkew = 5 * time.Minute
+
+// maxWebhookBodyBytes 回调请求体上限
+const maxWebhookBodyBytes = 1 << 20
+
+// Webhook 校验错误
+var (
+	ErrWebhookNotConfigured    = errors.New("webhook secret not configured")
+	ErrWebhookMissingSignature = errors.New("missing webhook signature")
+	ErrWebhookInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrWebhookExpired          = errors.New("webhook timestamp outside allowed window")
+	ErrWebhookInvalidSignature = errors.New("invalid webhook signature")
+	ErrWebhookReplayed         = errors.New("webhook already processed")
+)
+
+// WebhookVerifier 校验回调请求的 HMAC-SHA256 签名
+// 签名内容为 "{timestamp}.{nonce}.{body}"；支持同时配置当前密钥与上一个密钥以便轮换；
+// 时间窗口内见过的 nonce（未提供 nonce 时为签名本身）会被拒绝，防止重放。
+//
+// 去重记录保存在进程内：条目在 timestamp + maxSkew 后过期并在下一次校验时清理，
+// 因此大小约为 2×maxSkew 内收到的回调数，没有固定上限。多实例部署时每个实例只认得
+// 自己见过的 nonce，需要通过 SetReplayCache 接入共享缓存（如 Redis）
+type WebhookVerifier struct {
+	secrets [][]byte
+	maxSkew time.Duration
+	now     func() time.Time
+
+	allowLegacy bool           // 接受 X-Webhook-Secret 明文密钥（已废弃）
+	replayCache cache.Provider // 可选，实例间共享的去重记录
+
+	mu   sync.Mutex
+	seen map[string]time.Time // 去重 key -> 过期时间
+}
+
+// NewWebhookVerifier 创建校验器，空密钥会被忽略；maxSkew <= 0 时使用默认值
+func NewWebhookVerifier(maxSkew time.Duration, secrets ...string) *WebhookVerifier {
+	if maxSkew <= 0 {
+		maxSkew = DefaultWebhookMaxSkew
+	}
+	v := &WebhookVerifier{
+		maxSkew: maxSkew,
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+	}
+	for _, s := range secrets {
+		if s != "" {
+			v.secrets = append(v.secrets, []byte(s))
+		}
+	}
+	return v
+}
+
+// AllowLegacySecret 在迁移期内接受旧版 X-Webhook-Secret 明文密钥头。
+// 这类请求没有时间戳和 nonce，无法防重放；仅保留一个版本，接受时会记录废弃日志
+func (v *WebhookVerifier) AllowLegacySecret(allow bool) {
+	v.allowLegacy = allow
+}
+
+// SetReplayCache 在进程内记录之外，把去重 key 写入共享缓存，使重放到其他实例的请求也被拒绝。
+// 缓存只支持先查后写，同一请求同时到达两个实例时仍可能都被接受；缓存出错时只依赖进程内记录
+func (v *WebhookVerifier) SetReplayCache(store cache.Provider) {
+	v.replayCache = store
+}
+
+// SignWebhook 计算签名头的值，供发送方和测试使用
+func SignWebhook(secret string, timestamp int64, nonce string, body []byte) string {
+	return "sha256=" + hex.EncodeToString(webhookMAC([]byte(secret), timestamp, nonce, body))
+}
+
+func webhookMAC(secret []byte, timestamp int64, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.%s.", timestamp, nonce)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Verify 校验请求签名，成功时返回请求体并把 r.Body 重置为可再次读取
+func (v *WebhookVerifier) Verify(r *http.Request) ([]byte, error) {
+	if v == nil || len(v.secrets) == 0 {
+		return nil, ErrWebhookNotConfigured
+	}
+
+	signature := strings.TrimPrefix(r.Header.Get(WebhookSignatureHeader), "sha256=")
+	tsHeader := r.Header.Get(WebhookTimestampHeader)
+	if signature == "" && tsHeader == "" && v.allowLegacy {
+		if legacy := r.Header.Get(WebhookLegacySecretHeader); legacy != "" {
+			return v.verifyLegacy(r, legacy)
+		}
+	}
+	if signature == "" || tsHeader == "" {
+		return nil, ErrWebhookMissingSignature
+	}
+	provided, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, ErrWebhookInvalidSignature
+	}
+
+	timestamp, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return nil, ErrWebhookInvalidTimestamp
+	}
+	now := v.now()
+	sentAt := time.Unix(timestamp, 0)
+	if sentAt.Before(now.Add(-v.maxSkew)) || sentAt.After(now.Add(v.maxSkew)) {
+		return nil, ErrWebhookExpired
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	nonce := r.Header.Get(WebhookNonceHeader)
+	matched := false
+	for _, secret := range v.secrets {
+		if hmac.Equal(provided, webhookMAC(secret, timestamp, nonce, body)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, ErrWebhookInvalidSignature
+	}
+
+	replayKey := "sig:" + signature
+	if nonce != "" {
+		replayKey = "nonce:" + nonce
+	}
+	// 超出时间窗口的请求会被时间戳校验拒绝，因此去重记录只需保留到 timestamp + maxSkew
+	if !v.markSeen(r.Context(), replayKey, sentAt.Add(v.maxSkew), now) {
+		return nil, ErrWebhookReplayed
+	}
+	return body, nil
+}
+
+// verifyLegacy 校验旧版明文密钥头
+func (v *WebhookVerifier) verifyLegacy(r *http.Request, provided string) ([]byte, error) {
+	matched := false
+	for _, secret := range v.secrets {
+		if subtle.ConstantTimeCompare([]byte(provided), secret) == 1 {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, ErrWebhookInvalidSignature
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	log.Printf("[webhook] deprecated %s header accepted for %s; switch the sender to signed callbacks", WebhookLegacySecretHeader, r.URL.Path)
+	return body, nil
+}
+
+// markSeen 记录去重 key，已存在时返回 false
+func (v *WebhookVerifier) markSeen(ctx context.Context, key string, expiresAt, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for k, exp := range v.seen {
+		if !exp.After(now) {
+			delete(v.seen, k)
+		}
+	}
+	if _, ok := v.seen[key]; ok {
+		return false
+	}
+	if v.replayCache != nil {
+		cacheKey := webhookReplayKeyPrefix + key
+		if _, found, err := v.replayCache.Get(ctx, cacheKey); err != nil {
+			log.Printf("[webhook] replay cache lookup failed: %v", err)
+		} else if found {
+			return false
+		}
+		ttl := int(expiresAt.Sub(now)/time.Second) + 1
+		if err := v.replayCache.Set(ctx, cacheKey, "1", ttl); err != nil {
+			log.Printf("[webhook] replay cache write failed: %v", err)
+		}
+	}
+	v.seen[key] = expiresAt
+	return true
+}
+
+// WebhookMiddleware 回调签名校验中间件
+func WebhookMiddleware(v *WebhookVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := v.Verify(r); err != nil {
+				status := http.StatusUnauthorized
+				switch {
+				case errors.Is(err, ErrWebhookNotConfigured):
+					status = http.StatusInternalServerError
+				case errors.Is(err, ErrWebhookReplayed):
+					status = http.StatusConflict
+				}
+				respondError(w, status, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
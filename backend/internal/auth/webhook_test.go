@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+)
+
+func newSignedRequest(secret string, ts time.Time, nonce, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sync/callback", strings.NewReader(body))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	if nonce != "" {
+		req.Header.Set(WebhookNonceHeader, nonce)
+	}
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, ts.Unix(), nonce, []byte(body)))
+	return req
+}
+
+func TestWebhookVerifier_Verify(t *testing.T) {
+	now := time.Now()
+	v := NewWebhookVerifier(time.Minute, "current", "previous")
+	v.now = func() time.Time { return now }
+
+	body, err := v.Verify(newSignedRequest("current", now, "n1", `{"status":"success"}`))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if string(body) != `{"status":"success"}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	cases := []struct {
+		name string
+		req  *http.Request
+		want error
+	}{
+		{"previous secret accepted", newSignedRequest("previous", now, "n2", `{}`), nil},
+		{"unknown secret", newSignedRequest("other", now, "n3", `{}`), ErrWebhookInvalidSignature},
+		{"stale timestamp", newSignedRequest("current", now.Add(-2*time.Minute), "n4", `{}`), ErrWebhookExpired},
+		{"future timestamp", newSignedRequest("current", now.Add(2*time.Minute), "n5", `{}`), ErrWebhookExpired},
+		{"replayed nonce", newSignedRequest("current", now, "n1", `{"status":"success"}`), ErrWebhookReplayed},
+		{"missing signature", httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)), ErrWebhookMissingSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.Verify(tc.req)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifier_TamperedBody(t *testing.T) {
+	now := time.Now()
+	v := NewWebhookVerifier(time.Minute, "current")
+
+	req := newSignedRequest("current", now, "", `{"status":"failed"}`)
+	req.Body = io.NopCloser(strings.NewReader(`{"status":"success"}`))
+	if _, err := v.Verify(req); !errors.Is(err, ErrWebhookInvalidSignature) {
+		t.Fatalf("expected tampered body to be rejected, got %v", err)
+	}
+}
+
+func TestWebhookVerifier_ReplayWithoutNonce(t *testing.T) {
+	now := time.Now()
+	v := NewWebhookVerifier(time.Minute, "current")
+
+	if _, err := v.Verify(newSignedRequest("current", now, "", `{"event_id":"e1"}`)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := v.Verify(newSignedRequest("current", now, "", `{"event_id":"e1"}`)); !errors.Is(err, ErrWebhookReplayed) {
+		t.Fatalf("expected identical request to be rejected, got %v", err)
+	}
+}
+
+func TestWebhookVerifier_LegacySecret(t *testing.T) {
+	legacyRequest := func(secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sync/callback", strings.NewReader(`{"event_id":"e1"}`))
+		req.Header.Set(WebhookLegacySecretHeader, secret)
+		return req
+	}
+	v := NewWebhookVerifier(time.Minute, "current", "previous")
+	if _, err := v.Verify(legacyRequest("current")); !errors.Is(err, ErrWebhookMissingSignature) {
+		t.Fatalf("legacy header must be rejected unless enabled, got %v", err)
+	}
+
+	v.AllowLegacySecret(true)
+	body, err := v.Verify(legacyRequest("previous"))
+	if err != nil || string(body) != `{"event_id":"e1"}` {
+		t.Fatalf("Verify() = %q, %v; want legacy secret accepted", body, err)
+	}
+	if _, err := v.Verify(legacyRequest("wrong")); !errors.Is(err, ErrWebhookInvalidSignature) {
+		t.Fatalf("expected wrong legacy secret to be rejected, got %v", err)
+	}
+}
+
+func TestWebhookVerifier_SharedReplayCache(t *testing.T) {
+	now := time.Now()
+	shared := cache.NewMemory(100, time.Minute)
+	first := NewWebhookVerifier(time.Minute, "current")
+	second := NewWebhookVerifier(time.Minute, "current")
+	first.SetReplayCache(shared)
+	second.SetReplayCache(shared)
+
+	if _, err := first.Verify(newSignedRequest("current", now, "n1", `{}`)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	// 重放到另一个实例
+	if _, err := second.Verify(newSignedRequest("current", now, "n1", `{}`)); !errors.Is(err, ErrWebhookReplayed) {
+		t.Fatalf("expected replay on another instance to be rejected, got %v", err)
+	}
+}
+
+func TestWebhookMiddleware(t *testing.T) {
+	now := time.Now()
+	var gotBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := WebhookMiddleware(NewWebhookVerifier(time.Minute, "current"))(next)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newSignedRequest("current", now, "n1", `{"ok":true}`))
+	if rec.Code != http.StatusOK || gotBody != `{"ok":true}` {
+		t.Fatalf("expected signed request to pass with body intact, got %d %q", rec.Code, gotBody)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newSignedRequest("wrong", now, "n2", `{}`))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad signature, got %d", rec.Code)
+	}
+
+	unconfigured := WebhookMiddleware(NewWebhookVerifier(time.Minute))(next)
+	rec = httptest.NewRecorder()
+	unconfigured.ServeHTTP(rec, newSignedRequest("current", now, "n3", `{}`))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when no secret is configured, got %d", rec.Code)
+	}
+}
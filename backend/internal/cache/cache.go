@@ -3,6 +3,7 @@ package cache
 import "context"
 
 // Provider defines the behaviour required from a cache implementation.
+// A ttlSeconds <= 0 passed to Set means the provider's default TTL.
 type Provider interface {
 	Get(ctx context.Context, key string) (string, bool, error)
 	Set(ctx context.Context, key, value string, ttlSeconds int) error
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryProvider is an in-process LRU cache with per-entry expiry.
+type memoryProvider struct {
+	mu         sync.Mutex
+	maxEntries int
+	defaultTTL time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewMemory returns an in-process LRU Provider.
+// maxEntries <= 0 disables the size limit; ttlSeconds <= 0 passed to Set falls back to defaultTTL.
+func NewMemory(maxEntries int, defaultTTL time.Duration) Provider {
+	return &memoryProvider{
+		maxEntries: maxEntries,
+		defaultTTL: defaultTTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (m *memoryProvider) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return "", false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !m.now().Before(entry.expiresAt) {
+		m.removeElement(elem)
+		return "", false, nil
+	}
+	m.ll.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (m *memoryProvider) Set(_ context.Context, key, value string, ttlSeconds int) error {
+	ttl := m.defaultTTL
+	if ttlSeconds > 0 {
+		ttl = time.Duration(ttlSeconds) * time.Second
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = m.now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		m.ll.MoveToFront(elem)
+		return nil
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	if m.maxEntries > 0 {
+		for m.ll.Len() > m.maxEntries {
+			m.removeElement(m.ll.Back())
+		}
+	}
+	return nil
+}
+
+func (m *memoryProvider) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[key]; ok {
+		m.removeElement(elem)
+	}
+	return nil
+}
+
+func (m *memoryProvider) removeElement(elem *list.Element) {
+	m.ll.Remove(elem)
+	delete(m.items, elem.Value.(*memoryEntry).key)
+}
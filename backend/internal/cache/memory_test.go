@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryProvider_GetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemory(10, time.Minute)
+
+	if _, ok, _ := p.Get(ctx, "missing"); ok {
+		t.Fatalf("expected miss for unknown key")
+	}
+
+	if err := p.Set(ctx, "a", "1", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if v, ok, _ := p.Get(ctx, "a"); !ok || v != "1" {
+		t.Fatalf("Get() = %q, %v; want 1, true", v, ok)
+	}
+
+	if err := p.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := p.Get(ctx, "a"); ok {
+		t.Fatalf("expected miss after delete")
+	}
+}
+
+func TestMemoryProvider_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemory(2, 0)
+
+	_ = p.Set(ctx, "a", "1", 0)
+	_ = p.Set(ctx, "b", "2", 0)
+	// 访问 a 使 b 成为最久未使用
+	_, _, _ = p.Get(ctx, "a")
+	_ = p.Set(ctx, "c", "3", 0)
+
+	if _, ok, _ := p.Get(ctx, "b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	if _, ok, _ := p.Get(ctx, "a"); !ok {
+		t.Errorf("expected a to survive eviction")
+	}
+	if _, ok, _ := p.Get(ctx, "c"); !ok {
+		t.Errorf("expected c to be present")
+	}
+}
+
+func TestMemoryProvider_Expiry(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemory(0, time.Minute).(*memoryProvider)
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	_ = p.Set(ctx, "default", "v", 0)
+	_ = p.Set(ctx, "short", "v", 5)
+
+	now = now.Add(10 * time.Second)
+	if _, ok, _ := p.Get(ctx, "short"); ok {
+		t.Errorf("expected short-lived entry to expire")
+	}
+	if _, ok, _ := p.Get(ctx, "default"); !ok {
+		t.Errorf("expected default TTL entry to remain")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := p.Get(ctx, "default"); ok {
+		t.Errorf("expected default TTL entry to expire")
+	}
+}
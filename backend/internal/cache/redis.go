@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig stores connection settings for the Redis provider.
+type RedisConfig struct {
+	Addr       string
+	Password   string
+	DB         int
+	KeyPrefix  string        // Prepended to every key, e.g. "ydms:"
+	DefaultTTL time.Duration // Used when Set is called with ttlSeconds <= 0
+}
+
+// redisProvider stores entries in Redis so that multiple backend instances share one cache.
+type redisProvider struct {
+	client     *redis.Client
+	prefix     string
+	defaultTTL time.Duration
+}
+
+// NewRedis connects to Redis and returns a Provider backed by it.
+func NewRedis(ctx context.Context, cfg RedisConfig) (Provider, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &redisProvider{
+		client:     client,
+		prefix:     cfg.KeyPrefix,
+		defaultTTL: cfg.DefaultTTL,
+	}, nil
+}
+
+func (r *redisProvider) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *redisProvider) Set(ctx context.Context, key, value string, ttlSeconds int) error {
+	ttl := r.defaultTTL
+	if ttlSeconds > 0 {
+		ttl = time.Duration(ttlSeconds) * time.Second
+	}
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+func (r *redisProvider) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
@@ -17,12 +17,22 @@ type Config struct {
 	Admin    AdminBootstrapConfig
 	Prefect  PrefectConfig
 	MinIO    MinIOConfig
+	Cache    CacheConfig
+	Search   SearchConfig
 }
 
 // NDRConfig stores settings for the upstream NDR service.
 type NDRConfig struct {
 	BaseURL string
 	APIKey  string
+	Timeout int // Per-attempt timeout in seconds
+	// OperationTimeouts overrides Timeout per operation, e.g. "POST /api/v1/nodes/{id}/purge" -> 60.
+	OperationTimeouts map[string]int
+	MaxRetries        int // Retries after the first attempt (0 disables)
+	RetryBaseDelayMS  int // Backoff before the first retry in milliseconds, doubled per retry with jitter
+	RetryMaxDelayMS   int // Upper bound of a single backoff in milliseconds
+	BreakerThreshold  int // Consecutive failures before the circuit opens (0 disables)
+	BreakerCooldown   int // Seconds the circuit stays open before a probe request is let through
 }
 
 // DebugConfig stores flags that affect logging and diagnostics.
@@ -55,16 +65,23 @@ type AdminBootstrapConfig struct {
 
 // JWTConfig stores JWT authentication settings.
 type JWTConfig struct {
-	Secret string
-	Expiry string // e.g., "24h", "7d"
+	Secret        string
+	Expiry        string // Access token lifetime, e.g., "15m", "24h"
+	RefreshExpiry string // Refresh token lifetime, e.g., "168h"
+	RefreshGrace  string // How long a rotated refresh token still returns the same successor, e.g., "30s"
 }
 
 // PrefectConfig stores Prefect integration settings.
 type PrefectConfig struct {
-	BaseURL       string // Prefect Server API URL (empty to disable Prefect integration)
-	WebhookSecret string // Secret for callback webhook verification
-	Timeout       int    // Request timeout in seconds
-	PublicBaseURL string // Public URL for callbacks (defaults to http://localhost:{port})
+	BaseURL               string // Prefect Server API URL (empty to disable Prefect integration)
+	WebhookSecret         string // Secret for callback webhook verification
+	WebhookPreviousSecret string // Previous secret, still accepted while senders rotate
+	WebhookMaxSkew        int    // Allowed clock skew for webhook timestamps in seconds
+	WebhookLegacySecret   bool   // Deprecated: also accept the unsigned X-Webhook-Secret header (removed next release)
+	Timeout               int    // Request timeout in seconds
+	PublicBaseURL         string // Public URL for callbacks (defaults to http://localhost:{port})
+	// ReconcileInterval is the interval in seconds between polls of Prefect for active runs (0 disables).
+	ReconcileInterval int
 }
 
 // MinIOConfig stores MinIO proxy settings for static assets.
@@ -72,13 +89,37 @@ type MinIOConfig struct {
 	URL string // MinIO server URL (empty to disable proxy)
 }
 
+// SearchConfig stores settings for the full-text search index.
+type SearchConfig struct {
+	// ReconcileInterval is the interval in seconds between reconciliations of the index against NDR (0 disables).
+	ReconcileInterval int
+}
+
+// CacheConfig stores settings for the NDR read cache.
+type CacheConfig struct {
+	Backend       string // "noop", "memory" or "redis"
+	TTL           int    // Default entry lifetime in seconds
+	MaxEntries    int    // Size limit of the in-memory LRU
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	RedisPrefix   string
+}
+
 // Load builds a Config object from environment variables, providing sane defaults.
 func Load() Config {
 	return Config{
 		HTTPPort: parseEnvInt("YDMS_HTTP_PORT", 9180),
 		NDR: NDRConfig{
-			BaseURL: firstNonEmpty(os.Getenv("YDMS_NDR_BASE_URL"), "not_set"),
-			APIKey:  firstNonEmpty(os.Getenv("YDMS_NDR_API_KEY"), "not_set"),
+			BaseURL:           firstNonEmpty(os.Getenv("YDMS_NDR_BASE_URL"), "not_set"),
+			APIKey:            firstNonEmpty(os.Getenv("YDMS_NDR_API_KEY"), "not_set"),
+			Timeout:           parseEnvInt("YDMS_NDR_TIMEOUT", 10),
+			OperationTimeouts: parseEnvIntMap("YDMS_NDR_OPERATION_TIMEOUTS"),
+			MaxRetries:        parseEnvInt("YDMS_NDR_MAX_RETRIES", 2),
+			RetryBaseDelayMS:  parseEnvInt("YDMS_NDR_RETRY_BASE_DELAY_MS", 200),
+			RetryMaxDelayMS:   parseEnvInt("YDMS_NDR_RETRY_MAX_DELAY_MS", 2000),
+			BreakerThreshold:  parseEnvInt("YDMS_NDR_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:   parseEnvInt("YDMS_NDR_BREAKER_COOLDOWN", 30),
 		},
 		Auth: AuthConfig{
 			DefaultUserID: firstNonEmpty(os.Getenv("YDMS_DEFAULT_USER_ID"), "dms"),
@@ -96,8 +137,10 @@ func Load() Config {
 			SSLMode:  firstNonEmpty(os.Getenv("YDMS_DB_SSLMODE"), "disable"),
 		},
 		JWT: JWTConfig{
-			Secret: firstNonEmpty(os.Getenv("YDMS_JWT_SECRET"), "change-me-in-production"),
-			Expiry: firstNonEmpty(os.Getenv("YDMS_JWT_EXPIRY"), "24h"),
+			Secret:        firstNonEmpty(os.Getenv("YDMS_JWT_SECRET"), "change-me-in-production"),
+			Expiry:        firstNonEmpty(os.Getenv("YDMS_JWT_EXPIRY"), "15m"),
+			RefreshExpiry: firstNonEmpty(os.Getenv("YDMS_JWT_REFRESH_EXPIRY"), "168h"),
+			RefreshGrace:  firstNonEmpty(os.Getenv("YDMS_JWT_REFRESH_GRACE"), "30s"),
 		},
 		Admin: AdminBootstrapConfig{
 			Username:    firstNonEmpty(os.Getenv("YDMS_DEFAULT_ADMIN_USERNAME"), "super_admin"),
@@ -105,14 +148,30 @@ func Load() Config {
 			DisplayName: firstNonEmpty(os.Getenv("YDMS_DEFAULT_ADMIN_DISPLAY_NAME"), "超级管理员"),
 		},
 		Prefect: PrefectConfig{
-			BaseURL:       os.Getenv("YDMS_PREFECT_BASE_URL"), // Empty by default (disabled)
-			WebhookSecret: os.Getenv("YDMS_PREFECT_WEBHOOK_SECRET"),
-			Timeout:       parseEnvInt("YDMS_PREFECT_TIMEOUT", 300),
-			PublicBaseURL: os.Getenv("YDMS_PUBLIC_BASE_URL"), // For callback URLs
+			BaseURL:               os.Getenv("YDMS_PREFECT_BASE_URL"), // Empty by default (disabled)
+			WebhookSecret:         os.Getenv("YDMS_PREFECT_WEBHOOK_SECRET"),
+			WebhookPreviousSecret: os.Getenv("YDMS_PREFECT_WEBHOOK_SECRET_PREVIOUS"),
+			WebhookMaxSkew:        parseEnvInt("YDMS_PREFECT_WEBHOOK_MAX_SKEW", 300),
+			WebhookLegacySecret:   parseEnvBool("YDMS_PREFECT_WEBHOOK_ALLOW_LEGACY_SECRET", false),
+			Timeout:               parseEnvInt("YDMS_PREFECT_TIMEOUT", 300),
+			PublicBaseURL:         os.Getenv("YDMS_PUBLIC_BASE_URL"), // For callback URLs
+			ReconcileInterval:     parseEnvInt("YDMS_PREFECT_RECONCILE_INTERVAL", 30),
 		},
 		MinIO: MinIOConfig{
 			URL: os.Getenv("YDMS_MINIO_URL"), // Empty by default (disabled)
 		},
+		Cache: CacheConfig{
+			Backend:       strings.ToLower(firstNonEmpty(os.Getenv("YDMS_CACHE_BACKEND"), "memory")),
+			TTL:           parseEnvInt("YDMS_CACHE_TTL", 60),
+			MaxEntries:    parseEnvInt("YDMS_CACHE_MAX_ENTRIES", 10000),
+			RedisAddr:     firstNonEmpty(os.Getenv("YDMS_REDIS_ADDR"), "localhost:6379"),
+			RedisPassword: os.Getenv("YDMS_REDIS_PASSWORD"),
+			RedisDB:       parseEnvInt("YDMS_REDIS_DB", 0),
+			RedisPrefix:   firstNonEmpty(os.Getenv("YDMS_REDIS_PREFIX"), "ydms:"),
+		},
+		Search: SearchConfig{
+			ReconcileInterval: parseEnvInt("YDMS_SEARCH_RECONCILE_INTERVAL", 600),
+		},
 	}
 }
 
@@ -134,6 +193,28 @@ func parseEnvInt(key string, defaultValue int) int {
 	return value
 }
 
+// parseEnvIntMap parses "key=value" pairs separated by commas, e.g.
+// "POST /api/v1/nodes/{id}/purge=60,GET /api/v1/documents=20". Malformed pairs are skipped.
+func parseEnvIntMap(key string) map[string]int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return nil
+	}
+	values := make(map[string]int)
+	for _, pair := range strings.Split(raw, ",") {
+		idx := strings.LastIndex(pair, "=")
+		if idx <= 0 {
+			continue
+		}
+		var value int
+		if _, err := fmt.Sscanf(strings.TrimSpace(pair[idx+1:]), "%d", &value); err != nil {
+			continue
+		}
+		values[strings.TrimSpace(pair[:idx])] = value
+	}
+	return values
+}
+
 func parseEnvBool(key string, defaultValue bool) bool {
 	raw := strings.TrimSpace(strings.ToLower(os.Getenv(key)))
 	if raw == "" {
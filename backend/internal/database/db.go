@@ -51,7 +51,7 @@ func AutoMigrateWithDefaults(db *gorm.DB, defaults AdminDefaults) error {
 
 	// 使用原始的 db（已在 Connect 时配置）迁移所有表
 	// 注意：我们在手动创建外键约束，所以不依赖 GORM 自动创建
-	err := db.AutoMigrate(&User{}, &CoursePermission{}, &APIKey{}, &DocSyncStatus{}, &WorkflowDefinition{}, &WorkflowRun{}, &WorkflowBatch{}, &SyncBatch{})
+	err := db.AutoMigrate(&User{}, &CoursePermission{}, &UserSession{}, &RefreshToken{}, &APIKey{}, &DocSyncStatus{}, &WorkflowDefinition{}, &WorkflowRun{}, &WorkflowBatch{}, &SyncBatch{}, &MigrationBatch{}, &BatchItem{}, &AuditEvent{}, &Role{}, &DocumentTypeRecord{}, &SearchDocument{}, &SearchTerm{}, &DocumentReferenceLink{}, &CategoryBulkOperation{}, &ResourceLock{})
 	if err != nil {
 		return fmt.Errorf("failed to migrate tables: %w", err)
 	}
@@ -74,6 +74,40 @@ func AutoMigrateWithDefaults(db *gorm.DB, defaults AdminDefaults) error {
 		log.Printf("Warning: failed to create user self-reference FK: %v", err)
 	}
 
+	// UserSession.User -> User.ID
+	err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.table_constraints
+				WHERE constraint_name = 'fk_user_sessions_user' AND table_name = 'user_sessions'
+			) THEN
+				ALTER TABLE user_sessions ADD CONSTRAINT fk_user_sessions_user
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+			END IF;
+		END$$;
+	`).Error
+	if err != nil {
+		log.Printf("Warning: failed to create user_sessions.user FK: %v", err)
+	}
+
+	// RefreshToken.User -> User.ID
+	err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.table_constraints
+				WHERE constraint_name = 'fk_refresh_tokens_user' AND table_name = 'refresh_tokens'
+			) THEN
+				ALTER TABLE refresh_tokens ADD CONSTRAINT fk_refresh_tokens_user
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+			END IF;
+		END$$;
+	`).Error
+	if err != nil {
+		log.Printf("Warning: failed to create refresh_tokens.user FK: %v", err)
+	}
+
 	// APIKey.User -> User.ID
 	err = db.Exec(`
 		DO $$
@@ -176,8 +210,30 @@ func AutoMigrateWithDefaults(db *gorm.DB, defaults AdminDefaults) error {
 		log.Printf("Warning: failed to create sync_batches.created_by FK: %v", err)
 	}
 
+	// MigrationBatch.CreatedBy -> User.ID
+	err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.table_constraints
+				WHERE constraint_name = 'fk_migration_batches_created_by' AND table_name = 'migration_batches'
+			) THEN
+				ALTER TABLE migration_batches ADD CONSTRAINT fk_migration_batches_created_by
+				FOREIGN KEY (created_by_id) REFERENCES users(id) ON DELETE SET NULL;
+			END IF;
+		END$$;
+	`).Error
+	if err != nil {
+		log.Printf("Warning: failed to create migration_batches.created_by FK: %v", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 
+	// 内置角色及已有用户的角色
+	if err := ensureRoles(db); err != nil {
+		return fmt.Errorf("failed to ensure roles: %w", err)
+	}
+
 	// 创建默认管理员账号（如果不存在）
 	if err := ensureDefaultAdmin(db, defaults); err != nil {
 		log.Printf("Warning: failed to create default admin: %v", err)
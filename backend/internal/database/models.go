@@ -17,7 +17,7 @@ type User struct {
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 	Username     string         `gorm:"uniqueIndex;not null" json:"username"`
 	PasswordHash string         `gorm:"not null" json:"-"` // 不在 JSON 中返回密码
-	Role         string         `gorm:"not null;index" json:"role"` // super_admin, course_admin, proofreader
+	Role         string         `gorm:"not null;index" json:"role"` // 角色名，对应 roles.name（内置 super_admin, course_admin, proofreader）
 	DisplayName  string         `json:"display_name"`
 	CreatedByID  *uint          `gorm:"index" json:"created_by_id,omitempty"` // 创建者 ID
 	CreatedBy    *User          `gorm:"foreignKey:CreatedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"created_by,omitempty"`
@@ -29,6 +29,7 @@ type CoursePermission struct {
 	CreatedAt  time.Time `json:"created_at"`
 	UserID     uint      `gorm:"not null;index" json:"user_id"`
 	RootNodeID int64     `gorm:"not null;index" json:"root_node_id"` // NDR 中的根节点 ID
+	Role       string    `gorm:"size:64;not null;default:''" json:"role"` // 用户在该课程中的角色，为空时沿用用户的全局角色
 	User       User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
@@ -42,6 +43,56 @@ func (CoursePermission) TableName() string {
 	return "course_permissions"
 }
 
+// Session 吊销原因常量
+const (
+	SessionRevokedLogout          = "logout"
+	SessionRevokedLogoutAll       = "logout_all"
+	SessionRevokedPasswordChanged = "password_changed"
+	SessionRevokedUserDeleted     = "user_deleted"
+	SessionRevokedTokenReuse      = "token_reuse"
+)
+
+// UserSession 用户登录会话模型
+// 每个签发的 JWT 对应一条记录（通过 jti 关联），用于服务端吊销
+type UserSession struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	TokenID       string     `gorm:"uniqueIndex;not null;size:64" json:"-"`  // JWT jti（不返回）
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`       // 与 token 过期时间一致
+	RevokedAt     *time.Time `gorm:"index" json:"revoked_at,omitempty"`      // 吊销时间
+	RevokedReason string     `gorm:"size:32" json:"revoked_reason,omitempty"` // logout, logout_all, password_changed, user_deleted
+}
+
+// TableName 指定表名
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// RefreshToken 刷新令牌模型
+// 每次刷新都会轮换为同一 family 中的新令牌；已使用的令牌在宽限期外再次出现视为泄露，整个 family 被吊销
+type RefreshToken struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash       string     `gorm:"uniqueIndex;not null;size:64" json:"-"`   // 刷新令牌的 SHA256 哈希（不返回）
+	FamilyID        string     `gorm:"not null;size:64;index" json:"family_id"` // 同一次登录派生的令牌共享 family
+	ParentID        *uint      `gorm:"index" json:"parent_id,omitempty"`        // 轮换前的令牌
+	SessionTokenID  string     `gorm:"not null;size:64;index" json:"-"`         // 同时签发的 access token jti
+	ExpiresAt       time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt          *time.Time `json:"used_at,omitempty"`  // 已轮换时间
+	SuccessorSealed string     `gorm:"type:text" json:"-"` // 轮换出的令牌对，以本令牌派生的密钥加密，宽限期内重复刷新时原样返回
+	RevokedAt       *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedReason   string     `gorm:"size:32" json:"revoked_reason,omitempty"`
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
 // APIKey API密钥模型
 type APIKey struct {
 	ID          uint           `gorm:"primarykey" json:"id"`
@@ -104,8 +155,13 @@ func (j *JSONMap) Scan(value interface{}) error {
 		*j = nil
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return errors.New("type assertion to []byte failed")
 	}
 	return json.Unmarshal(bytes, j)
@@ -200,11 +256,12 @@ func (WorkflowRun) TableName() string {
 
 // BatchStatus 批次执行状态常量
 const (
-	BatchStatusPending   = "pending"
-	BatchStatusRunning   = "running"
-	BatchStatusCompleted = "completed"
-	BatchStatusFailed    = "failed"
-	BatchStatusCancelled = "cancelled"
+	BatchStatusPending    = "pending"
+	BatchStatusRunning    = "running"
+	BatchStatusCompleted  = "completed"
+	BatchStatusFailed     = "failed"
+	BatchStatusCancelled  = "cancelled"
+	BatchStatusRolledBack = "rolled_back" // 分类批量操作已撤销
 )
 
 // WorkflowBatch 批量工作流批次模型
@@ -230,6 +287,9 @@ type WorkflowBatch struct {
 	FailedCount  int `gorm:"not null;default:0" json:"failed_count"`  // 失败数
 	SkippedCount int `gorm:"not null;default:0" json:"skipped_count"` // 跳过数
 
+	// 执行参数 - 保存原始请求，服务重启后据此恢复执行
+	Options JSONMap `gorm:"type:jsonb;default:'{}'" json:"options,omitempty"`
+
 	// 执行详情 - 记录每个节点的执行结果
 	Details JSONMap `gorm:"type:jsonb;default:'{}'" json:"details,omitempty"`
 
@@ -272,6 +332,9 @@ type SyncBatch struct {
 	FailedCount    int `gorm:"not null;default:0" json:"failed_count"`    // 失败数
 	SkippedCount   int `gorm:"not null;default:0" json:"skipped_count"`   // 跳过数
 
+	// 执行参数 - 保存原始请求，服务重启后据此恢复执行
+	Options JSONMap `gorm:"type:jsonb;default:'{}'" json:"options,omitempty"`
+
 	// 执行详情 - 记录每个文档的同步结果
 	Details JSONMap `gorm:"type:jsonb;default:'{}'" json:"details,omitempty"`
 
@@ -291,3 +354,368 @@ type SyncBatch struct {
 func (SyncBatch) TableName() string {
 	return "sync_batches"
 }
+
+// MigrationBatch 文档类型迁移批次模型
+// 记录把子树中某一类型的文档升级为另一类型（如 xxx_v1 -> xxx_v2）的批次信息
+type MigrationBatch struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 批次标识
+	BatchID string `gorm:"uniqueIndex;not null;size:64" json:"batch_id"` // UUID
+
+	// 迁移配置
+	RootNodeID int64  `gorm:"not null;index" json:"root_node_id"`      // 起始节点 ID
+	FromType   string `gorm:"not null;size:64;index" json:"from_type"` // 源文档类型
+	ToType     string `gorm:"not null;size:64" json:"to_type"`         // 目标文档类型
+
+	// 执行状态: pending, running, completed, failed, cancelled
+	Status string `gorm:"not null;size:32;default:'pending';index" json:"status"`
+
+	// 统计信息
+	TotalDocuments int `gorm:"not null;default:0" json:"total_documents"` // 总文档数
+	SuccessCount   int `gorm:"not null;default:0" json:"success_count"`   // 成功数
+	FailedCount    int `gorm:"not null;default:0" json:"failed_count"`    // 失败数
+	SkippedCount   int `gorm:"not null;default:0" json:"skipped_count"`   // 跳过数
+
+	// 执行参数 - 保存原始请求，服务重启后据此恢复执行
+	Options JSONMap `gorm:"type:jsonb;default:'{}'" json:"options,omitempty"`
+
+	// 错误信息
+	ErrorMessage string `gorm:"type:text" json:"error_message,omitempty"`
+
+	// 触发者
+	CreatedByID *uint `gorm:"index" json:"created_by_id,omitempty"`
+	CreatedBy   *User `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+
+	// 时间戳
+	StartedAt  *time.Time `json:"started_at,omitempty"`  // 开始时间
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // 完成时间
+}
+
+// TableName 指定表名
+func (MigrationBatch) TableName() string {
+	return "migration_batches"
+}
+
+// BatchItem 类型常量
+const (
+	BatchItemTypeWorkflow     = "workflow"      // 属于 WorkflowBatch，一项对应一个节点
+	BatchItemTypeSync         = "sync"          // 属于 SyncBatch，一项对应一个文档
+	BatchItemTypeMigration    = "migration"     // 属于 MigrationBatch，一项对应一个文档
+	BatchItemTypeCategoryMove = "category_move" // 属于 CategoryBulkOperation（移动），一项对应一个源节点
+	BatchItemTypeCategoryCopy = "category_copy" // 属于 CategoryBulkOperation（复制），一项对应一个源节点
+)
+
+// BatchItem 状态常量
+const (
+	BatchItemStatusPending   = "pending"
+	BatchItemStatusRunning   = "running"
+	BatchItemStatusSuccess   = "success"
+	BatchItemStatusFailed    = "failed"
+	BatchItemStatusSkipped   = "skipped"
+	BatchItemStatusCancelled = "cancelled"
+	BatchItemStatusReverted  = "reverted" // 分类批量操作撤销后的项
+)
+
+// BatchItem 批次执行项模型
+// 批次创建时即为每个节点/文档落库一行，逐项更新状态，服务重启后未完成的项可继续执行
+type BatchItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 所属批次: workflow -> workflow_batches.id, sync -> sync_batches.id, migration -> migration_batches.id
+	BatchType  string `gorm:"not null;size:16;index:idx_batch_items_batch,priority:1" json:"batch_type"`
+	BatchRefID uint   `gorm:"not null;index:idx_batch_items_batch,priority:2" json:"batch_ref_id"`
+	Sequence   int    `gorm:"not null;default:0" json:"sequence"` // 批次内执行顺序
+
+	// 目标节点
+	NodeID   int64  `gorm:"not null;index" json:"node_id"`
+	NodeName string `gorm:"size:255" json:"node_name,omitempty"`
+	NodePath string `gorm:"type:text" json:"node_path,omitempty"`
+
+	// 目标文档（仅批量同步 / 类型迁移）
+	DocumentID    *int64 `gorm:"index" json:"document_id,omitempty"`
+	DocumentTitle string `gorm:"type:text" json:"document_title,omitempty"`
+	DocumentType  string `gorm:"size:64" json:"document_type,omitempty"`
+
+	// 执行状态: pending, running, success, failed, skipped, cancelled
+	Status       string  `gorm:"not null;size:32;default:'pending';index" json:"status"`
+	Reason       string  `gorm:"type:text" json:"reason,omitempty"` // 跳过原因
+	ErrorMessage string  `gorm:"type:text" json:"error,omitempty"`
+	Result       JSONMap `gorm:"type:jsonb;default:'{}'" json:"result,omitempty"` // run_id / event_id 等
+	Attempts     int     `gorm:"not null;default:0" json:"attempts"`
+
+	// 执行租约：认领项的服务实例及其最近一次心跳，心跳过期的 running 项才会被重新执行
+	ClaimedBy   string     `gorm:"size:128;index" json:"claimed_by,omitempty"`
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty"`
+
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName 指定表名
+func (BatchItem) TableName() string {
+	return "batch_items"
+}
+
+// AuditEvent 审计事件模型
+// 记录所有写操作的执行者与目标；不对 users 建外键，用户删除后审计记录保持不变
+type AuditEvent struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	// 执行者（快照，用户改名/删除后仍可追溯）
+	ActorID   *uint  `gorm:"index" json:"actor_id,omitempty"`
+	ActorName string `gorm:"size:64" json:"actor_name,omitempty"`
+	ActorRole string `gorm:"size:32" json:"actor_role,omitempty"`
+
+	// 操作，如 category.delete / document.purge / api_key.revoke
+	Action string `gorm:"not null;size:64;index" json:"action"`
+
+	// 操作目标
+	ResourceType string `gorm:"not null;size:32;index:idx_audit_events_resource,priority:1" json:"resource_type"`
+	ResourceID   string `gorm:"size:64;index:idx_audit_events_resource,priority:2" json:"resource_id,omitempty"`
+	// 目标所属课程，用于限定课程管理员可见范围；无法归属课程时为空
+	RootNodeID *int64 `gorm:"index" json:"root_node_id,omitempty"`
+
+	RequestID string  `gorm:"size:64;index" json:"request_id,omitempty"`
+	Details   JSONMap `gorm:"type:jsonb;default:'{}'" json:"details,omitempty"`
+}
+
+// TableName 指定表名
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// Role 角色模型
+// 角色名即 users.role 的取值；权限以字符串列表保存，由 PermissionService 解释
+type Role struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `gorm:"uniqueIndex;not null;size:64" json:"name"`
+	DisplayName string     `gorm:"size:128" json:"display_name"`
+	Description string     `json:"description"`
+	Permissions StringList `gorm:"type:jsonb;default:'[]'" json:"permissions"`
+	BuiltIn     bool       `gorm:"not null;default:false" json:"built_in"` // 内置角色不可修改或删除
+}
+
+// TableName 指定表名
+func (Role) TableName() string {
+	return "roles"
+}
+
+// StringList 以 JSON 数组存储的字符串列表
+type StringList []string
+
+// Value implements driver.Valuer for StringList
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(l))
+}
+
+// Scan implements sql.Scanner for StringList
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// DocumentTypeRecord 运行时注册的文档类型
+// docgen 生成的内置类型不入库；这里只保存管理员在后台新增的类型
+type DocumentTypeRecord struct {
+	ID            uint               `gorm:"primarykey" json:"-"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	TypeID        string             `gorm:"uniqueIndex;not null;size:64" json:"id"` // 如 comprehensive_choice_v2
+	Label         string             `gorm:"not null;size:128" json:"label"`
+	ContentFormat string             `gorm:"not null;size:16" json:"content_format"` // html | yaml | markdown | json
+	Template      string             `gorm:"type:text;not null" json:"template"`     // 新建文档时的默认内容
+	Schema        string             `gorm:"type:text" json:"schema,omitempty"`      // 可选的 JSON Schema
+	Themes        DocumentTypeThemes `gorm:"type:jsonb;default:'[]'" json:"themes"`
+	CreatedBy     string             `gorm:"size:128" json:"created_by"`
+}
+
+// TableName 指定表名
+func (DocumentTypeRecord) TableName() string {
+	return "document_types"
+}
+
+// DocumentTypeTheme HTML 类文档的可选主题样式
+type DocumentTypeTheme struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	CSS         string `json:"css"`
+}
+
+// DocumentTypeThemes 以 JSON 数组存储的主题列表
+type DocumentTypeThemes []DocumentTypeTheme
+
+// Value implements driver.Valuer for DocumentTypeThemes
+func (t DocumentTypeThemes) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]DocumentTypeTheme(t))
+}
+
+// Scan implements sql.Scanner for DocumentTypeThemes
+func (t *DocumentTypeThemes) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+// SearchDocument 全文检索索引中的文档
+// 由写操作与定期对账从 NDR 同步而来，只保存检索与摘要所需的字段
+type SearchDocument struct {
+	ID         uint   `gorm:"primarykey" json:"-"`
+	DocumentID int64  `gorm:"not null;uniqueIndex" json:"document_id"`
+	Title      string `gorm:"size:512" json:"title"`
+	DocType    string `gorm:"size:64;index" json:"doc_type,omitempty"`
+	// 文档所属课程（首个绑定节点的根节点），未绑定节点时为 0，只对拥有全部课程权限的用户可见
+	RootNodeID int64  `gorm:"not null;default:0;index" json:"root_node_id"`
+	NodeID     int64  `gorm:"not null;default:0" json:"node_id,omitempty"`
+	NodePath   string `gorm:"size:1024" json:"node_path,omitempty"`
+	Tags       string `gorm:"type:text" json:"tags,omitempty"` // 以空格分隔的 metadata.tags
+	Content    string `gorm:"type:text" json:"-"`              // 抽取后的纯文本，用于生成摘要
+
+	SourceUpdatedAt time.Time `json:"source_updated_at"` // NDR 中文档的 updated_at，对账时据此判断是否需要重建
+	IndexedAt       time.Time `json:"indexed_at"`
+}
+
+// TableName specifies the table name for SearchDocument
+func (SearchDocument) TableName() string {
+	return "search_documents"
+}
+
+// SearchTerm 倒排索引：文档包含的词项及其权重（标题 > 标签 > 正文，按出现次数累加）
+type SearchTerm struct {
+	ID         uint   `gorm:"primarykey"`
+	Term       string `gorm:"not null;size:64;index:idx_search_terms_term,priority:1"`
+	DocumentID int64  `gorm:"not null;index;index:idx_search_terms_term,priority:2"`
+	Weight     int    `gorm:"not null;default:1"`
+}
+
+// TableName specifies the table name for SearchTerm
+func (SearchTerm) TableName() string {
+	return "search_terms"
+}
+
+// 引用索引中被引用文档的状态
+const (
+	ReferenceTargetActive  = "active"  // 被引用文档存在
+	ReferenceTargetDeleted = "deleted" // 被引用文档已软删除（可恢复）
+	ReferenceTargetMissing = "missing" // 被引用文档已彻底删除或从未存在
+)
+
+// DocumentReferenceLink 文档引用索引
+// 引用关系本身保存在引用方文档的 metadata.references 中，这里维护一份反向索引，
+// 用于查询被引用情况、删除前检查以及悬空引用报表
+type DocumentReferenceLink struct {
+	ID               uint      `gorm:"primarykey" json:"-"`
+	SourceDocumentID int64     `gorm:"not null;uniqueIndex:idx_document_references_pair,priority:1" json:"source_document_id"`
+	TargetDocumentID int64     `gorm:"not null;uniqueIndex:idx_document_references_pair,priority:2;index" json:"target_document_id"`
+	Title            string    `gorm:"size:512" json:"title"`                               // 引用方保存的被引用文档标题
+	SourceRootNodeID int64     `gorm:"not null;default:0;index" json:"source_root_node_id"` // 引用方所属课程，未绑定节点时为 0
+	TargetStatus     string    `gorm:"size:16;not null;default:'active';index" json:"target_status"`
+	PendingUpdate    bool      `gorm:"not null;default:false;index" json:"pending_update"` // 引用方 metadata 待改写（改名同步标题或彻底删除后移除引用）
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for DocumentReferenceLink
+func (DocumentReferenceLink) TableName() string {
+	return "document_references"
+}
+
+// CategoryBulkOperation 分类批量移动/复制的操作日志
+// 执行前落库，每个源节点对应一个 BatchItem（result 中记录原父节点或复制出的节点），
+// 进程中断或回滚失败后可据此继续执行或撤销
+type CategoryBulkOperation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 操作类型: move, copy
+	Kind string `gorm:"not null;size:16;index" json:"kind"`
+
+	// 执行状态: running, completed, failed, rolled_back
+	Status string `gorm:"not null;size:32;default:'running';index" json:"status"`
+
+	// 原始请求，继续执行时据此重新排序
+	Options JSONMap `gorm:"type:jsonb;default:'{}'" json:"options,omitempty"`
+
+	// 是否已完成目标父节点下的排序
+	Reordered bool `gorm:"not null;default:false" json:"reordered"`
+
+	ErrorMessage string `gorm:"type:text" json:"error_message,omitempty"`
+
+	CreatedByID *uint      `gorm:"index" json:"created_by_id,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName 指定表名
+func (CategoryBulkOperation) TableName() string {
+	return "category_bulk_operations"
+}
+
+// 资源锁的资源类型
+const (
+	LockResourceDocument = "document"
+	LockResourceNode     = "node"
+)
+
+// ResourceLock 文档/节点的协作锁（校对会话签出）
+// 锁是建议性的：持有者之外的用户更新文档、在节点上触发工作流时会被拒绝；
+// 持有者需在 ExpiresAt 之前发送心跳续期，过期的锁视为已释放
+type ResourceLock struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ResourceType string `gorm:"not null;size:16;uniqueIndex:idx_resource_locks_resource,priority:1" json:"resource_type"`
+	ResourceID   int64  `gorm:"not null;uniqueIndex:idx_resource_locks_resource,priority:2" json:"resource_id"`
+
+	// 持有者：OwnerID 为用户 ID，未登录的请求以 Owner（x-user-id）区分
+	OwnerID uint   `gorm:"not null;default:0;index" json:"owner_id"`
+	Owner   string `gorm:"size:128" json:"owner"`
+	Reason  string `gorm:"size:255" json:"reason,omitempty"`
+
+	TTLSeconds  int       `gorm:"not null" json:"ttl_seconds"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	// StaleAt 超过该时间未收到心跳即视为失去心跳（HeartbeatAt + 半个有效期），便于在 SQL 中筛选
+	StaleAt time.Time `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名
+func (ResourceLock) TableName() string {
+	return "resource_locks"
+}
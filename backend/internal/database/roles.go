@@ -0,0 +1,147 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 角色权限
+const (
+	PermDocumentView           = "document:view"
+	PermDocumentCreate         = "document:create"
+	PermDocumentEdit           = "document:edit"
+	PermDocumentDelete         = "document:delete"
+	PermDocumentPurge          = "document:purge"
+	PermDocumentRestoreVersion = "document:restore_version"
+	PermDocumentReference      = "document:reference" // 维护文档引用关系
+	PermNodeView               = "node:view"
+	PermNodeCreate             = "node:create"
+	PermNodeEdit               = "node:edit"
+	PermNodeDelete             = "node:delete"
+	PermNodePurge              = "node:purge" // 含强制删除
+	PermNodeMove               = "node:move"
+	PermSyncTrigger            = "sync:trigger"
+	PermCoursesAll             = "courses:all"      // 无需课程授权即可访问所有课程，并可调整根层级
+	PermCoursesManage          = "courses:manage"   // 创建、删除课程
+	PermUsersManage            = "users:manage"     // 管理用户及其 API Key
+	PermRolesManage            = "roles:manage"     // 管理角色与文档类型定义
+	PermAuditView              = "audit:view"       // 查询审计日志（无 courses:all 时限于所管理的课程）
+	PermWorkflowsManage        = "workflows:manage" // 同步与启停工作流定义
+	PermBatchesAll             = "batches:all"      // 查看和管理他人创建的批次与批量操作
+	PermLockBreak              = "lock:break"       // 强制解除他人持有的锁、无视锁触发工作流
+	PermAPIKeyOwn              = "api_keys:own"     // 可以被签发 API Key
+)
+
+// 内置角色名
+const (
+	RoleSuperAdmin  = "super_admin"
+	RoleCourseAdmin = "course_admin"
+	RoleProofreader = "proofreader"
+)
+
+// AllPermissions 所有可分配的权限
+var AllPermissions = []string{
+	PermDocumentView,
+	PermDocumentCreate,
+	PermDocumentEdit,
+	PermDocumentDelete,
+	PermDocumentPurge,
+	PermDocumentRestoreVersion,
+	PermDocumentReference,
+	PermNodeView,
+	PermNodeCreate,
+	PermNodeEdit,
+	PermNodeDelete,
+	PermNodePurge,
+	PermNodeMove,
+	PermSyncTrigger,
+	PermCoursesAll,
+	PermCoursesManage,
+	PermUsersManage,
+	PermRolesManage,
+	PermAuditView,
+	PermWorkflowsManage,
+	PermBatchesAll,
+	PermLockBreak,
+	PermAPIKeyOwn,
+}
+
+// BuiltinRoles 内置角色定义，与原先硬编码的权限矩阵一致
+var BuiltinRoles = []Role{
+	{
+		Name:        RoleSuperAdmin,
+		DisplayName: "超级管理员",
+		Description: "拥有全部权限",
+		Permissions: StringList(AllPermissions),
+		BuiltIn:     true,
+	},
+	{
+		Name:        RoleCourseAdmin,
+		DisplayName: "课程管理员",
+		Description: "管理被授权课程的内容，不能永久删除",
+		Permissions: StringList{
+			PermDocumentView, PermDocumentCreate, PermDocumentEdit, PermDocumentDelete,
+			PermDocumentRestoreVersion, PermDocumentReference,
+			PermNodeView, PermNodeCreate, PermNodeEdit, PermNodeDelete, PermNodeMove,
+			PermSyncTrigger,
+			PermAuditView, PermWorkflowsManage, PermAPIKeyOwn,
+		},
+		BuiltIn: true,
+	},
+	{
+		Name:        RoleProofreader,
+		DisplayName: "校对员",
+		Description: "查看并编辑被授权课程的文档内容",
+		Permissions: StringList{
+			PermDocumentView, PermDocumentEdit, PermDocumentRestoreVersion,
+			PermNodeView,
+		},
+		BuiltIn: true,
+	},
+}
+
+// BuiltinRole 按名称查找内置角色
+func BuiltinRole(name string) (Role, bool) {
+	for _, role := range BuiltinRoles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return Role{}, false
+}
+
+// ensureRoles 同步内置角色，并为已有用户引用但不存在的角色补建空权限角色
+func ensureRoles(db *gorm.DB) error {
+	for _, builtin := range BuiltinRoles {
+		var role Role
+		err := db.Where(Role{Name: builtin.Name}).
+			Assign(Role{
+				DisplayName: builtin.DisplayName,
+				Description: builtin.Description,
+				Permissions: builtin.Permissions,
+				BuiltIn:     true,
+			}).
+			FirstOrCreate(&role).Error
+		if err != nil {
+			return fmt.Errorf("ensure builtin role %s: %w", builtin.Name, err)
+		}
+	}
+
+	// 迁移已有用户：角色名不在 roles 表中的，补建一个无权限角色，由管理员分配权限
+	var orphans []string
+	if err := db.Model(&User{}).Unscoped().
+		Where("role NOT IN (?)", db.Model(&Role{}).Select("name")).
+		Distinct().Pluck("role", &orphans).Error; err != nil {
+		return fmt.Errorf("list orphan user roles: %w", err)
+	}
+	for _, name := range orphans {
+		role := Role{Name: name, DisplayName: name, Description: "迁移自已有用户", Permissions: StringList{}}
+		if err := db.Where(Role{Name: name}).FirstOrCreate(&role).Error; err != nil {
+			return fmt.Errorf("create role %s for existing users: %w", name, err)
+		}
+		log.Printf("Warning: created role '%s' without permissions for existing users", name)
+	}
+	return nil
+}
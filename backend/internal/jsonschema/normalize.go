@@ -0,0 +1,220 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Normalize 处理 encoding/json 解码得到的值（map[string]any、[]any、string、float64、bool、nil）：
+// 为缺失的属性补上非 null 的 default，把可无损转换的值转为 schema 声明的类型
+// （如 "5" -> 5、"true" -> true、3 -> "3"），然后校验。整数值的浮点数（如 5.0）视为整数。
+// 返回处理后的值；未通过时返回 *ValidationError，错误只有 Path 没有行列号
+func (s *Schema) Normalize(value any) (any, error) {
+	value = s.coerce(value)
+	node, err := valueNode(value)
+	if err != nil {
+		return value, err
+	}
+	return value, s.ValidateNode(node)
+}
+
+func (s *Schema) coerce(value any) any {
+	if s.ref != nil {
+		value = s.ref.coerce(value)
+	}
+	for _, sub := range s.AllOf {
+		value = sub.coerce(value)
+	}
+	if len(s.AnyOf) > 0 {
+		value = s.coerceAnyOf(value)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if len(s.Type) > 0 && !s.Type.accepts("object") {
+			return value
+		}
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = item
+		}
+		for name, prop := range s.Properties {
+			if item, ok := out[name]; ok {
+				out[name] = prop.coerce(item)
+			} else if def := prop.defaultValue(); def != nil {
+				out[name] = prop.coerce(def)
+			}
+		}
+		if s.AdditionalProperties != nil && s.AdditionalProperties.schema != nil {
+			for key, item := range out {
+				if _, ok := s.Properties[key]; !ok {
+					out[key] = s.AdditionalProperties.schema.coerce(item)
+				}
+			}
+		}
+		return out
+	case []any:
+		if s.Items == nil || (len(s.Type) > 0 && !s.Type.accepts("array")) {
+			return value
+		}
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = s.Items.coerce(item)
+		}
+		return out
+	}
+	if len(s.Type) == 0 || s.Type.accepts(kindOfValue(value)) {
+		return value
+	}
+	for _, want := range s.Type {
+		if converted, ok := convertScalar(value, want); ok {
+			return converted
+		}
+	}
+	return value
+}
+
+// coerceAnyOf 值已符合某个分支时按该分支处理，否则取第一个转换后能通过的分支
+func (s *Schema) coerceAnyOf(value any) any {
+	for _, sub := range s.AnyOf {
+		if sub.accepts(value) {
+			return sub.coerce(value)
+		}
+	}
+	for _, sub := range s.AnyOf {
+		if converted := sub.coerce(value); sub.accepts(converted) {
+			return converted
+		}
+	}
+	return value
+}
+
+func (s *Schema) accepts(value any) bool {
+	node, err := valueNode(value)
+	if err != nil {
+		return false
+	}
+	var errs []Error
+	s.validate(node, "", nil, &errs)
+	return len(errs) == 0
+}
+
+// defaultValue 返回 default 的副本，避免多次调用共享同一个 map/slice
+func (s *Schema) defaultValue() any {
+	def := s.Default
+	if def == nil && s.ref != nil {
+		def = s.ref.Default
+	}
+	if def == nil {
+		return nil
+	}
+	raw, err := json.Marshal(def)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// convertScalar 只做无损转换，无法转换时返回 false
+func convertScalar(value any, want string) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		text := strings.TrimSpace(v)
+		switch want {
+		case "integer":
+			if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return n, true
+			}
+		case "number":
+			if n, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+				return n, true
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b, true
+			}
+		case "null":
+			if text == "" {
+				return nil, true
+			}
+		}
+	case float64:
+		if want == "string" {
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+	case int64:
+		if want == "string" {
+			return strconv.FormatInt(v, 10), true
+		}
+	case bool:
+		if want == "string" {
+			return strconv.FormatBool(v), true
+		}
+	}
+	return nil, false
+}
+
+// kindOfValue 与 kindOf 一致，整数值的 float64 视为 integer
+func kindOfValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int32, int64, uint, uint32, uint64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return "integer"
+		}
+		return "number"
+	case float32:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "string"
+}
+
+// valueNode 把 JSON 值转为 yaml 节点以复用 ValidateNode；整数值的 float64 按整数编码
+func valueNode(value any) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(integralFloats(value)); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func integralFloats(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if kindOfValue(v) == "integer" {
+			return int64(v)
+		}
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = integralFloats(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = integralFloats(item)
+		}
+		return out
+	}
+	return value
+}
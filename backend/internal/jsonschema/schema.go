@@ -0,0 +1,204 @@
+// Package jsonschema 实现 JSON Schema 的常用子集，直接在 yaml.v3 节点上校验，
+// 以便错误信息能携带 YAML/JSON 源文本中的行列号。
+//
+// 支持的关键字：type、enum、properties、required、additionalProperties、items、
+// minItems、maxItems、minLength、maxLength、pattern、minimum、maximum、minProperties、
+// anyOf、allOf、oneOf（按 anyOf 处理）、default（见 Normalize）、指向 definitions/$defs 的 $ref，
+// 以及扩展关键字 x-sibling-key（见 Schema.SiblingKey）。其他关键字（如 $schema、title、
+// description）会被忽略。
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Schema 是编译后的 JSON Schema 节点
+type Schema struct {
+	Type                 typeList           `json:"type,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	// SiblingKey 扩展关键字，格式为 "<数组字段>[].<键字段>"：
+	// 值必须等于同一对象中该数组某个元素的键字段，如选择题答案必须是已有选项的 key
+	SiblingKey string `json:"x-sibling-key,omitempty"`
+
+	pattern     *regexp.Regexp
+	siblingList string
+	siblingKey  string
+	ref         *Schema
+}
+
+// Compile 解析并检查 JSON Schema 文本
+func Compile(raw []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	if err := s.prepare("#", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// MustCompile 与 Compile 相同，出错时 panic
+func MustCompile(raw string) *Schema {
+	s, err := Compile([]byte(raw))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Schema) prepare(at string, root *Schema) error {
+	if s.Ref != "" {
+		target, err := root.lookupRef(s.Ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		s.ref = target
+	}
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("%s: unknown type %q", at, t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", at, err)
+		}
+		s.pattern = re
+	}
+	if s.SiblingKey != "" {
+		list, key, ok := strings.Cut(s.SiblingKey, "[].")
+		if !ok || list == "" || key == "" {
+			return fmt.Errorf("%s: x-sibling-key must look like \"field[].key\"", at)
+		}
+		s.siblingList, s.siblingKey = list, key
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("%s/properties/%s: schema is null", at, name)
+		}
+		if err := prop.prepare(at+"/properties/"+name, root); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.prepare(at+"/items", root); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.schema != nil {
+		if err := s.AdditionalProperties.schema.prepare(at+"/additionalProperties", root); err != nil {
+			return err
+		}
+	}
+	// oneOf 不检查“恰好一个”，与 anyOf 合并处理
+	s.AnyOf = append(s.AnyOf, s.OneOf...)
+	s.OneOf = nil
+	for keyword, list := range map[string][]*Schema{"anyOf": s.AnyOf, "allOf": s.AllOf} {
+		for i, sub := range list {
+			if sub == nil {
+				return fmt.Errorf("%s/%s/%d: schema is null", at, keyword, i)
+			}
+			if err := sub.prepare(fmt.Sprintf("%s/%s/%d", at, keyword, i), root); err != nil {
+				return err
+			}
+		}
+	}
+	for keyword, defs := range map[string]map[string]*Schema{"definitions": s.Definitions, "$defs": s.Defs} {
+		for name, def := range defs {
+			if def == nil {
+				return fmt.Errorf("%s/%s/%s: schema is null", at, keyword, name)
+			}
+			if err := def.prepare(at+"/"+keyword+"/"+name, root); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupRef 只支持指向根节点 definitions/$defs 的本地引用，如 #/definitions/Mode
+func (s *Schema) lookupRef(ref string) (*Schema, error) {
+	var defs map[string]*Schema
+	var name string
+	switch {
+	case strings.HasPrefix(ref, "#/definitions/"):
+		defs, name = s.Definitions, strings.TrimPrefix(ref, "#/definitions/")
+	case strings.HasPrefix(ref, "#/$defs/"):
+		defs, name = s.Defs, strings.TrimPrefix(ref, "#/$defs/")
+	default:
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	target, ok := defs[name]
+	if !ok || target == nil {
+		return nil, fmt.Errorf("$ref %q not found", ref)
+	}
+	return target, nil
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "integer": true,
+	"number": true, "boolean": true, "null": true,
+}
+
+// typeList 兼容 "type": "string" 与 "type": ["string", "null"] 两种写法
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = multi
+	return nil
+}
+
+// additional 兼容 additionalProperties 的布尔与 schema 两种写法
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		a.allowed = b
+		return nil
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("additionalProperties must be a boolean or a schema")
+	}
+	a.allowed = true
+	a.schema = &s
+	return nil
+}
@@ -0,0 +1,347 @@
+package jsonschema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error 单个字段的校验错误
+type Error struct {
+	Path    string `json:"path"` // 如 body.sub_questions[0].answer，根节点为空
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e Error) String() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationError 校验未通过，包含全部字段错误
+type ValidationError struct {
+	Errors []Error
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, item := range e.Errors {
+		parts = append(parts, item.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ValidateNode 校验 yaml 节点（可以是 DocumentNode），未通过时返回 *ValidationError
+func (s *Schema) ValidateNode(node *yaml.Node) error {
+	var errs []Error
+	s.validate(node, "", nil, &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (s *Schema) validate(node *yaml.Node, path string, parent *yaml.Node, errs *[]Error) {
+	node = resolve(node)
+	kind := kindOf(node)
+
+	fail := func(n *yaml.Node, p, format string, args ...any) {
+		e := Error{Path: p, Message: fmt.Sprintf(format, args...)}
+		if n != nil {
+			e.Line, e.Column = n.Line, n.Column
+		}
+		*errs = append(*errs, e)
+	}
+
+	if s.ref != nil {
+		s.ref.validate(node, path, parent, errs)
+	}
+	for _, sub := range s.AllOf {
+		sub.validate(node, path, parent, errs)
+	}
+	if len(s.AnyOf) > 0 {
+		s.validateAnyOf(node, kind, path, parent, errs, fail)
+	}
+
+	if len(s.Type) > 0 && !s.Type.accepts(kind) {
+		fail(node, path, "类型应为 %s，实际为 %s", strings.Join(s.Type, " 或 "), kind)
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		var value any
+		if node != nil {
+			_ = node.Decode(&value)
+		}
+		if !containsValue(s.Enum, value) {
+			fail(node, path, "取值应为 %s 之一", formatValues(s.Enum))
+		}
+	}
+
+	switch kind {
+	case "object":
+		s.validateObject(node, path, errs, fail)
+	case "array":
+		if s.MinItems != nil && len(node.Content) < *s.MinItems {
+			fail(node, path, "至少需要 %d 项，实际为 %d 项", *s.MinItems, len(node.Content))
+		}
+		if s.MaxItems != nil && len(node.Content) > *s.MaxItems {
+			fail(node, path, "最多允许 %d 项，实际为 %d 项", *s.MaxItems, len(node.Content))
+		}
+		if s.Items != nil {
+			for i, item := range node.Content {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), nil, errs)
+			}
+		}
+	case "string":
+		length := utf8.RuneCountInString(node.Value)
+		if s.MinLength != nil && length < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail(node, path, "不能为空")
+			} else {
+				fail(node, path, "长度至少为 %d", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail(node, path, "长度不能超过 %d", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(node.Value) {
+			fail(node, path, "格式不正确（应匹配 %s）", s.Pattern)
+		}
+	case "integer", "number":
+		value, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			break
+		}
+		if s.Minimum != nil && value < *s.Minimum {
+			fail(node, path, "不能小于 %v", *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			fail(node, path, "不能大于 %v", *s.Maximum)
+		}
+	}
+
+	if s.siblingList != "" && parent != nil && node != nil && node.Kind == yaml.ScalarNode {
+		if keys, ok := siblingKeys(parent, s.siblingList, s.siblingKey); ok && !containsString(keys, node.Value) {
+			fail(node, path, "%q 不在 %s 的 %s 中（可选：%s）", node.Value, s.siblingList, s.siblingKey, strings.Join(keys, ", "))
+		}
+	}
+}
+
+// validateAnyOf 任一分支通过即可；都不通过时报告第一个类型匹配的分支的错误，
+// 没有类型匹配的分支时报告类型错误
+func (s *Schema) validateAnyOf(node *yaml.Node, kind, path string, parent *yaml.Node, errs *[]Error, fail func(*yaml.Node, string, string, ...any)) {
+	var firstMatch []Error
+	var types []string
+	for _, sub := range s.AnyOf {
+		var branch []Error
+		sub.validate(node, path, parent, &branch)
+		if len(branch) == 0 {
+			return
+		}
+		branchTypes := sub.types()
+		types = append(types, branchTypes...)
+		if firstMatch == nil && (len(branchTypes) == 0 || typeList(branchTypes).accepts(kind)) {
+			firstMatch = branch
+		}
+	}
+	if firstMatch != nil {
+		*errs = append(*errs, firstMatch...)
+		return
+	}
+	fail(node, path, "类型应为 %s，实际为 %s", strings.Join(types, " 或 "), kind)
+}
+
+// types 返回分支声明的类型（跟随 $ref）
+func (s *Schema) types() []string {
+	if len(s.Type) == 0 && s.ref != nil {
+		return s.ref.types()
+	}
+	return s.Type
+}
+
+func (s *Schema) validateObject(node *yaml.Node, path string, errs *[]Error, fail func(*yaml.Node, string, string, ...any)) {
+	present := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		present[node.Content[i].Value] = true
+	}
+
+	if s.MinProperties != nil && len(present) < *s.MinProperties {
+		fail(node, path, "至少需要 %d 个字段", *s.MinProperties)
+	}
+	for _, name := range s.Required {
+		if !present[name] {
+			fail(node, joinPath(path, name), "缺少必填字段")
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		childPath := joinPath(path, key.Value)
+		if prop, ok := s.Properties[key.Value]; ok {
+			prop.validate(value, childPath, node, errs)
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if !s.AdditionalProperties.allowed {
+			fail(key, childPath, "不允许的字段")
+			continue
+		}
+		if s.AdditionalProperties.schema != nil {
+			s.AdditionalProperties.schema.validate(value, childPath, node, errs)
+		}
+	}
+}
+
+func (t typeList) accepts(kind string) bool {
+	for _, want := range t {
+		if want == kind || (want == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 展开文档节点与别名，空文档视为 null
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+func kindOf(node *yaml.Node) string {
+	if node == nil {
+		return "null"
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// siblingKeys 收集 parent.<list>[].<key> 的值；数组不存在时返回 false
+func siblingKeys(parent *yaml.Node, list, key string) ([]string, bool) {
+	items := lookup(parent, list)
+	if items == nil || items.Kind != yaml.SequenceNode {
+		return nil, false
+	}
+	keys := make([]string, 0, len(items.Content))
+	for _, item := range items.Content {
+		if v := lookup(resolve(item), key); v != nil && v.Kind == yaml.ScalarNode {
+			keys = append(keys, v.Value)
+		}
+	}
+	return keys, true
+}
+
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return resolve(mapping.Content[i+1])
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue 比较时把整数统一为 float64，与 JSON 解码结果一致
+func containsValue(values []any, value any) bool {
+	value = normalize(value)
+	for _, v := range values {
+		if reflect.DeepEqual(normalize(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(v any) any {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case []any:
+		out := make([]any, len(x))
+		for i := range x {
+			out[i] = normalize(x[i])
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = normalize(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func formatValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%v", v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,175 @@
+package jsonschema
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const choiceSchema = `{
+  "type": "object",
+  "required": ["title", "questions"],
+  "properties": {
+    "title": {"type": "string", "minLength": 1},
+    "questions": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["options", "answer"],
+        "additionalProperties": false,
+        "properties": {
+          "options": {"type": "array", "items": {"type": "object", "properties": {"key": {"type": "string"}}}},
+          "answer": {"type": "string", "x-sibling-key": "options[].key"},
+          "score": {"type": "integer", "minimum": 1}
+        }
+      }
+    }
+  }
+}`
+
+func parse(t *testing.T, src string) *yaml.Node {
+	t.Helper()
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &node); err != nil {
+		t.Fatalf("failed to parse yaml: %v", err)
+	}
+	return &node
+}
+
+func TestValidateNode_ReportsPathsAndLines(t *testing.T) {
+	schema := MustCompile(choiceSchema)
+
+	valid := "title: t\nquestions:\n  - options:\n      - key: A\n      - key: B\n    answer: B\n"
+	if err := schema.ValidateNode(parse(t, valid)); err != nil {
+		t.Fatalf("expected valid document, got %v", err)
+	}
+
+	invalid := "title: \"\"\nquestions:\n  - options:\n      - key: A\n      - key: B\n    answer: E\n    score: 0\n    extra: 1\n"
+	err := schema.ValidateNode(parse(t, invalid))
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	want := []Error{
+		{Path: "title", Line: 1},
+		{Path: "questions[0].answer", Line: 6},
+		{Path: "questions[0].score", Line: 7},
+		{Path: "questions[0].extra", Line: 8},
+	}
+	if len(vErr.Errors) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), vErr.Errors)
+	}
+	for i, w := range want {
+		got := vErr.Errors[i]
+		if got.Path != w.Path || got.Line != w.Line {
+			t.Fatalf("error %d: expected %s at line %d, got %+v", i, w.Path, w.Line, got)
+		}
+	}
+}
+
+func TestValidateNode_TypesAndRequired(t *testing.T) {
+	schema := MustCompile(choiceSchema)
+	err := schema.ValidateNode(parse(t, "title: [1]\n"))
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || len(vErr.Errors) != 2 {
+		t.Fatalf("expected missing questions and wrong title type, got %v", err)
+	}
+	if vErr.Errors[0].Path != "questions" || vErr.Errors[1].Path != "title" {
+		t.Fatalf("unexpected errors %v", vErr.Errors)
+	}
+}
+
+func TestCompile_RejectsInvalidSchemas(t *testing.T) {
+	for _, raw := range []string{
+		`{"type": "text"}`,
+		`{"properties": {"a": {"pattern": "("}}}`,
+		`{"x-sibling-key": "options"}`,
+	} {
+		if _, err := Compile([]byte(raw)); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+// prefectSchema 仿照 Prefect 由 pydantic 生成的 parameter_openapi_schema
+const prefectSchema = `{
+  "title": "Parameters",
+  "type": "object",
+  "required": ["course_name"],
+  "properties": {
+    "course_name": {"title": "Course Name", "type": "string", "minLength": 1},
+    "count": {"title": "Count", "type": "integer", "minimum": 1, "maximum": 20, "default": 5},
+    "dry_run": {"title": "Dry Run", "type": "boolean", "default": false},
+    "mode": {"allOf": [{"$ref": "#/definitions/Mode"}], "default": "fast"},
+    "tags": {"anyOf": [{"type": "array", "items": {"type": "string"}}, {"type": "null"}], "default": null},
+    "limit": {"anyOf": [{"type": "integer"}, {"type": "null"}]}
+  },
+  "definitions": {
+    "Mode": {"title": "Mode", "enum": ["fast", "full"], "type": "string"}
+  }
+}`
+
+func TestNormalize(t *testing.T) {
+	schema := MustCompile(prefectSchema)
+
+	got, err := schema.Normalize(map[string]any{
+		"course_name": "系统架构",
+		"count":       "8",
+		"dry_run":     "true",
+		"limit":       "3",
+		"tags":        []any{"a", 1.0},
+	})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	params := got.(map[string]any)
+	if params["count"] != int64(8) || params["dry_run"] != true || params["limit"] != int64(3) || params["mode"] != "fast" {
+		t.Fatalf("unexpected coercion or defaults: %#v", params)
+	}
+	if tags := params["tags"].([]any); tags[1] != "1" {
+		t.Fatalf("expected tags to be coerced to strings, got %#v", tags)
+	}
+	if _, ok := params["tags"]; !ok {
+		t.Fatalf("provided values must be kept")
+	}
+
+	// 整数值的浮点数视为整数，null 的 default 不补
+	got, err = schema.Normalize(map[string]any{"course_name": "x", "count": 3.0})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if _, ok := got.(map[string]any)["tags"]; ok {
+		t.Fatalf("null defaults should not be filled in: %#v", got)
+	}
+
+	_, err = schema.Normalize(map[string]any{"count": "many", "mode": "slow", "limit": 1.5})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	want := map[string]bool{"course_name": false, "count": false, "mode": false, "limit": false}
+	for _, e := range verr.Errors {
+		if _, ok := want[e.Path]; ok {
+			want[e.Path] = true
+		}
+		if e.Line != 0 {
+			t.Fatalf("errors from values should not carry line numbers: %+v", e)
+		}
+	}
+	for path, seen := range want {
+		if !seen {
+			t.Fatalf("expected an error for %s, got %v", path, verr)
+		}
+	}
+}
+
+func TestCompileRejectsUnknownRef(t *testing.T) {
+	if _, err := Compile([]byte(`{"properties": {"a": {"$ref": "#/definitions/Missing"}}}`)); err == nil {
+		t.Fatalf("expected error for a missing $ref target")
+	}
+	if _, err := Compile([]byte(`{"properties": {"a": {"$ref": "http://example.com/s.json"}}}`)); err == nil {
+		t.Fatalf("expected error for a remote $ref")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"log"
 	"net/http"
 	"net/url"
@@ -49,6 +50,15 @@ type Client interface {
 	GetDocumentVersionDiff(ctx context.Context, meta RequestMeta, docID int64, fromVersion, toVersion int) (DocumentVersionDiff, error)
 	RestoreDocumentVersion(ctx context.Context, meta RequestMeta, docID int64, versionNumber int) (Document, error)
 
+	// Automatic pagination: fetch every page until exhausted (see paging.go).
+	// The Stream variants yield items as pages arrive and stop when ctx is cancelled.
+	ListNodesAll(ctx context.Context, meta RequestMeta, params ListNodesParams) ([]Node, error)
+	ListDocumentsAll(ctx context.Context, meta RequestMeta, query url.Values) ([]Document, error)
+	ListNodeDocumentsAll(ctx context.Context, meta RequestMeta, id int64, query url.Values) ([]Document, error)
+	StreamNodes(ctx context.Context, meta RequestMeta, params ListNodesParams) iter.Seq2[Node, error]
+	StreamDocuments(ctx context.Context, meta RequestMeta, query url.Values) iter.Seq2[Document, error]
+	StreamNodeDocuments(ctx context.Context, meta RequestMeta, id int64, query url.Values) iter.Seq2[Document, error]
+
 	// Source document methods (workflow input)
 	BindSourceDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) (SourceRelation, error)
 	UnbindSourceDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) error
@@ -69,6 +79,12 @@ type NDRConfig struct {
 	BaseURL string
 	APIKey  string
 	Debug   bool
+	// Timeout bounds a single attempt (default 10s); OperationTimeouts overrides it per
+	// operation, keyed like "GET /api/v1/nodes/{id}" with numeric path segments as {id}.
+	Timeout           time.Duration
+	OperationTimeouts map[string]time.Duration
+	Retry             RetryConfig
+	Breaker           BreakerConfig
 }
 
 // RequestMeta contains per-request metadata forwarded to NDR.
@@ -80,9 +96,12 @@ type RequestMeta struct {
 }
 
 // Error represents an HTTP error returned by the NDR service.
+// Code and Message are taken from the response body when NDR provides them.
 type Error struct {
 	StatusCode int
 	Status     string
+	Code       string
+	Message    string
 }
 
 // Error implements the error interface.
@@ -90,14 +109,21 @@ func (e *Error) Error() string {
 	if e == nil {
 		return ""
 	}
+	if e.Message != "" {
+		return fmt.Sprintf("ndr request failed: %s: %s", e.Status, e.Message)
+	}
 	return fmt.Sprintf("ndr request failed: %s", e.Status)
 }
 
 type httpClient struct {
-	baseURL    *url.URL
-	apiKey     string
-	httpClient *http.Client
-	debug      bool
+	baseURL           *url.URL
+	apiKey            string
+	httpClient        *http.Client
+	debug             bool
+	timeout           time.Duration
+	operationTimeouts map[string]time.Duration
+	retry             RetryConfig
+	breakers          *breakerSet
 }
 
 // NewClient returns an HTTP backed NDR client.
@@ -106,11 +132,27 @@ func NewClient(cfg NDRConfig) Client {
 	if cfg.BaseURL != "" {
 		parsed, _ = url.Parse(cfg.BaseURL)
 	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	retry := cfg.Retry
+	if retry.BaseDelay <= 0 {
+		retry.BaseDelay = defaultRetryBaseDelay
+	}
+	if retry.MaxDelay <= 0 {
+		retry.MaxDelay = defaultRetryMaxDelay
+	}
 	return &httpClient{
-		baseURL:    parsed,
-		apiKey:     cfg.APIKey,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-		debug:      cfg.Debug,
+		baseURL: parsed,
+		apiKey:  cfg.APIKey,
+		// 超时按单次尝试由 context 控制，见 timeoutFor
+		httpClient:        &http.Client{},
+		debug:             cfg.Debug,
+		timeout:           timeout,
+		operationTimeouts: cfg.OperationTimeouts,
+		retry:             retry,
+		breakers:          newBreakerSet(cfg.Breaker),
 	}
 }
 
@@ -330,6 +372,9 @@ func (c *httpClient) UpdateDocument(ctx context.Context, meta RequestMeta, docID
 	if err != nil {
 		return Document{}, err
 	}
+	if body.BaseVersion != nil {
+		req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, *body.BaseVersion))
+	}
 	var resp Document
 	_, err = c.do(req, &resp)
 	return resp, err
@@ -590,14 +635,39 @@ func (c *httpClient) newRequestWithQuery(ctx context.Context, method, endpoint s
 	return req, nil
 }
 
+// do 发送请求并解码响应：按 RetryConfig 重试，结果计入该操作的熔断器，状态码 >= 400 时返回 *Error
 func (c *httpClient) do(req *http.Request, out any) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	breaker := c.breakers.get(c.operationName(req))
+	if !breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var (
+		resp     *http.Response
+		respBody []byte
+		err      error
+	)
+	for attempt := 0; ; attempt++ {
+		resp, respBody, err = c.roundTrip(req)
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		if attempt >= c.retry.MaxRetries || !shouldRetry(req, statusCode, err) {
+			breaker.settle(req, resp, err)
+			break
+		}
+		wait := c.backoff(attempt, resp)
+		log.Printf("[ndr] retry %d for %s %s after %v (status=%d err=%v)", attempt+1, req.Method, req.URL.Path, wait, statusCode, err)
+		if sleepErr := sleepContext(req.Context(), wait); sleepErr != nil {
+			breaker.settle(req, resp, err)
+			break
+		}
+		if req, err = rewindRequest(req); err != nil {
+			breaker.release()
+			return nil, err
+		}
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return resp, err
 	}
@@ -607,7 +677,8 @@ func (c *httpClient) do(req *http.Request, out any) (*http.Response, error) {
 	}
 
 	if resp.StatusCode >= 400 {
-		return resp, &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+		code, message := parseErrorBody(respBody)
+		return resp, &Error{StatusCode: resp.StatusCode, Status: resp.Status, Code: code, Message: message}
 	}
 	if out != nil {
 		if len(respBody) == 0 {
@@ -620,6 +691,36 @@ func (c *httpClient) do(req *http.Request, out any) (*http.Response, error) {
 	return resp, nil
 }
 
+// roundTrip 执行一次尝试，超时只作用于本次尝试（含读取响应体）
+func (c *httpClient) roundTrip(req *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeoutFor(req))
+	defer cancel()
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, body, nil
+}
+
+// rewindRequest 为重试准备请求，请求体通过 GetBody 重新读取
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
+
 func truncateForLog(data []byte) string {
 	if len(data) == 0 {
 		return "<empty>"
@@ -101,6 +101,8 @@ type DocumentUpdate struct {
 	Metadata map[string]any `json:"metadata,omitempty"`
 	Type     *string        `json:"type,omitempty"`
 	Position *int           `json:"position,omitempty"`
+	// BaseVersion is sent as If-Match; NDR rejects the update with 412 if the document is at another version.
+	BaseVersion *int `json:"-"`
 }
 
 // DocumentReorderPayload represents a request to reorder documents.
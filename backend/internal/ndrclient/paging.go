@@ -0,0 +1,142 @@
+package ndrclient
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is the page size used by the automatic pagination helpers
+// unless the caller sets "size" in the query (or ListNodesParams.Size).
+const DefaultPageSize = 100
+
+// Pager is the subset of Client the pagination helpers are built on. Test doubles
+// can implement the *All/Stream* methods of Client by delegating to the helpers below.
+type Pager interface {
+	ListNodes(ctx context.Context, meta RequestMeta, params ListNodesParams) (NodesPage, error)
+	ListDocuments(ctx context.Context, meta RequestMeta, query url.Values) (DocumentsPage, error)
+	ListNodeDocuments(ctx context.Context, meta RequestMeta, id int64, query url.Values) (DocumentsPage, error)
+}
+
+// StreamNodes yields every node page by page. Iteration stops at the first error
+// (yielded with a zero Node), when ctx is cancelled, or when the consumer breaks out.
+func StreamNodes(ctx context.Context, p Pager, meta RequestMeta, params ListNodesParams) iter.Seq2[Node, error] {
+	size := params.Size
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return paginate(ctx, size, func(page int) ([]Node, pageInfo, error) {
+		params.Page, params.Size = page, size
+		resp, err := p.ListNodes(ctx, meta, params)
+		return resp.Items, pageInfo{size: resp.Size, total: resp.Total}, err
+	})
+}
+
+// StreamDocuments yields every document matching query.
+func StreamDocuments(ctx context.Context, p Pager, meta RequestMeta, query url.Values) iter.Seq2[Document, error] {
+	query, size := pagedQuery(query)
+	return paginate(ctx, size, func(page int) ([]Document, pageInfo, error) {
+		query.Set("page", strconv.Itoa(page))
+		resp, err := p.ListDocuments(ctx, meta, query)
+		return resp.Items, pageInfo{size: resp.Size, total: resp.Total}, err
+	})
+}
+
+// StreamNodeDocuments yields every document of a node (or its subtree, per query).
+func StreamNodeDocuments(ctx context.Context, p Pager, meta RequestMeta, id int64, query url.Values) iter.Seq2[Document, error] {
+	query, size := pagedQuery(query)
+	return paginate(ctx, size, func(page int) ([]Document, pageInfo, error) {
+		query.Set("page", strconv.Itoa(page))
+		resp, err := p.ListNodeDocuments(ctx, meta, id, query)
+		return resp.Items, pageInfo{size: resp.Size, total: resp.Total}, err
+	})
+}
+
+// Collect drains a stream into a slice, returning the first error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	items := make([]T, 0)
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c *httpClient) StreamNodes(ctx context.Context, meta RequestMeta, params ListNodesParams) iter.Seq2[Node, error] {
+	return StreamNodes(ctx, c, meta, params)
+}
+
+func (c *httpClient) StreamDocuments(ctx context.Context, meta RequestMeta, query url.Values) iter.Seq2[Document, error] {
+	return StreamDocuments(ctx, c, meta, query)
+}
+
+func (c *httpClient) StreamNodeDocuments(ctx context.Context, meta RequestMeta, id int64, query url.Values) iter.Seq2[Document, error] {
+	return StreamNodeDocuments(ctx, c, meta, id, query)
+}
+
+func (c *httpClient) ListNodesAll(ctx context.Context, meta RequestMeta, params ListNodesParams) ([]Node, error) {
+	return Collect(StreamNodes(ctx, c, meta, params))
+}
+
+func (c *httpClient) ListDocumentsAll(ctx context.Context, meta RequestMeta, query url.Values) ([]Document, error) {
+	return Collect(StreamDocuments(ctx, c, meta, query))
+}
+
+func (c *httpClient) ListNodeDocumentsAll(ctx context.Context, meta RequestMeta, id int64, query url.Values) ([]Document, error) {
+	return Collect(StreamNodeDocuments(ctx, c, meta, id, query))
+}
+
+type pageInfo struct {
+	size  int // page size reported by NDR, which may cap the requested size
+	total int
+}
+
+// pagedQuery copies query so the caller's values are not mutated and fills in the page size.
+func pagedQuery(query url.Values) (url.Values, int) {
+	cloned := url.Values{}
+	for key, values := range query {
+		cloned[key] = append([]string(nil), values...)
+	}
+	size, err := strconv.Atoi(cloned.Get("size"))
+	if err != nil || size <= 0 {
+		size = DefaultPageSize
+		cloned.Set("size", strconv.Itoa(size))
+	}
+	return cloned, size
+}
+
+// paginate fetches pages starting at 1 until a short or empty page, or until total is reached.
+func paginate[T any](ctx context.Context, size int, fetch func(page int) ([]T, pageInfo, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		fetched := 0
+		for page := 1; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			items, info, err := fetch(page)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			fetched += len(items)
+
+			pageSize := size
+			if info.size > 0 && info.size < pageSize {
+				pageSize = info.size
+			}
+			if len(items) == 0 || len(items) < pageSize || (info.total > 0 && fetched >= info.total) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ndrclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientPagination(t *testing.T) {
+	const totalDocs = 250
+	var pages atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		pages.Add(1)
+		if r.URL.Query().Get("include_descendants") != "false" {
+			t.Errorf("caller query should be forwarded, got %s", r.URL.RawQuery)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		size = min(size, 80) // NDR 限制了单页大小
+		resp := DocumentsPage{Page: page, Size: size, Total: totalDocs}
+		for id := (page-1)*size + 1; id <= min(page*size, totalDocs); id++ {
+			resp.Items = append(resp.Items, Document{ID: int64(id)})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}, NDRConfig{})
+	ctx := context.Background()
+	query := url.Values{"include_descendants": {"false"}}
+
+	docs, err := client.ListNodeDocumentsAll(ctx, RequestMeta{}, 1, query)
+	if err != nil {
+		t.Fatalf("list all: %v", err)
+	}
+	if len(docs) != totalDocs || docs[totalDocs-1].ID != totalDocs {
+		t.Fatalf("expected %d documents, got %d", totalDocs, len(docs))
+	}
+	if pages.Load() != 4 {
+		t.Fatalf("expected 4 pages, got %d", pages.Load())
+	}
+	if query.Get("page") != "" || query.Get("size") != "" {
+		t.Fatalf("caller query must not be mutated: %v", query)
+	}
+
+	// 提前结束迭代时不再请求后续页
+	pages.Store(0)
+	for doc, err := range client.StreamNodeDocuments(ctx, RequestMeta{}, 1, query) {
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		if doc.ID == 5 {
+			break
+		}
+	}
+	if pages.Load() != 1 {
+		t.Fatalf("expected a single page request, got %d", pages.Load())
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.ListNodeDocumentsAll(cancelled, RequestMeta{}, 1, query); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package ndrclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrCircuitOpen 熔断期间直接拒绝请求，不再访问 NDR
+var ErrCircuitOpen = errors.New("ndr circuit breaker is open")
+
+const (
+	defaultTimeout         = 10 * time.Second
+	defaultRetryBaseDelay  = 200 * time.Millisecond
+	defaultRetryMaxDelay   = 2 * time.Second
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// RetryConfig 重试策略：只读请求（GET/HEAD/OPTIONS）在网络错误与 429/502/503/504 时重试；
+// 写请求（POST/PUT/DELETE）只在请求确定未被处理时重试（连接失败、429、503）。
+// NDR 的 PUT 每次都会生成新版本、DELETE 重放会得到 404，502/504 时无法确定写入是否已生效
+type RetryConfig struct {
+	MaxRetries int           // 首次失败后的最大重试次数，0 不重试
+	BaseDelay  time.Duration // 首次重试前的等待，之后指数增长并加随机抖动，默认 200ms
+	MaxDelay   time.Duration // 单次等待上限（含 Retry-After），默认 2s
+}
+
+// BreakerConfig 熔断器：按操作（如 "GET /api/v1/nodes/{id}"）分别计数，
+// 某个操作连续失败（网络错误、5xx、429）达到阈值后只熔断该操作，
+// 冷却期内直接返回 ErrCircuitOpen，冷却结束后放行一个探测请求，成功即恢复
+type BreakerConfig struct {
+	FailureThreshold int           // 0 关闭熔断
+	Cooldown         time.Duration // 默认 30s
+}
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerStatus 熔断器当前状态，用于健康检查。
+// 客户端汇总时取最严重的操作作为整体状态，Operations 列出未闭合或有失败计数的操作
+type BreakerStatus struct {
+	State               BreakerState             `json:"state"`
+	ConsecutiveFailures int                      `json:"consecutive_failures"`
+	OpenedAt            *time.Time               `json:"opened_at,omitempty"`
+	RetryAt             *time.Time               `json:"retry_at,omitempty"`
+	LastError           string                   `json:"last_error,omitempty"`
+	Operations          map[string]BreakerStatus `json:"operations,omitempty"`
+}
+
+// HealthReporter 由带熔断器的客户端实现，/healthz 据此报告上游状态
+type HealthReporter interface {
+	BreakerStatus() BreakerStatus
+}
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+func newCircuitBreaker(cfg BreakerConfig, now func() time.Time) *circuitBreaker {
+	cooldown := cfg.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{threshold: cfg.FailureThreshold, cooldown: cooldown, now: now}
+}
+
+// breakerSet 按操作名懒创建熔断器，一个慢或故障的接口不会拖垮其他接口
+type breakerSet struct {
+	mu       sync.Mutex
+	cfg      BreakerConfig
+	now      func() time.Time
+	breakers map[string]*circuitBreaker
+}
+
+func newBreakerSet(cfg BreakerConfig) *breakerSet {
+	return &breakerSet{cfg: cfg, now: time.Now, breakers: make(map[string]*circuitBreaker)}
+}
+
+func (s *breakerSet) get(operation string) *circuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[operation]
+	if !ok {
+		b = newCircuitBreaker(s.cfg, func() time.Time { return s.clock() })
+		s.breakers[operation] = b
+	}
+	return b
+}
+
+func (s *breakerSet) clock() time.Time {
+	s.mu.Lock()
+	now := s.now
+	s.mu.Unlock()
+	return now()
+}
+
+var breakerSeverity = map[BreakerState]int{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}
+
+// status 汇总各操作的状态：整体取最严重的一个（同级取连续失败最多的）
+func (s *breakerSet) status() BreakerStatus {
+	s.mu.Lock()
+	breakers := make(map[string]*circuitBreaker, len(s.breakers))
+	for op, b := range s.breakers {
+		breakers[op] = b
+	}
+	s.mu.Unlock()
+
+	overall := BreakerStatus{State: BreakerClosed}
+	for op, b := range breakers {
+		st := b.status()
+		if st.State == BreakerClosed && st.ConsecutiveFailures == 0 {
+			continue
+		}
+		if overall.Operations == nil {
+			overall.Operations = make(map[string]BreakerStatus)
+		}
+		overall.Operations[op] = st
+		if breakerSeverity[st.State] > breakerSeverity[overall.State] ||
+			(st.State == overall.State && st.ConsecutiveFailures > overall.ConsecutiveFailures) {
+			operations := overall.Operations
+			overall = st
+			overall.Operations = operations
+		}
+	}
+	return overall
+}
+
+// allow 熔断期间拒绝；冷却结束后只放行一个探测请求
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) record(failure error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if failure == nil {
+		b.failures = 0
+		b.lastError = ""
+		return
+	}
+	b.failures++
+	b.lastError = failure.Error()
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// release 结束探测但不计入结果：调用方取消时请求结果不能说明 NDR 的状态
+func (b *circuitBreaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// settle 记录一次请求的结果；调用方的 context 已结束时只释放探测，不改变失败计数
+func (b *circuitBreaker) settle(req *http.Request, resp *http.Response, err error) {
+	if req.Context().Err() != nil {
+		b.release()
+		return
+	}
+	b.record(upstreamFailure(resp, err))
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{State: BreakerClosed, ConsecutiveFailures: b.failures, LastError: b.lastError}
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return status
+	}
+	openedAt := b.openedAt
+	retryAt := openedAt.Add(b.cooldown)
+	status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	status.State = BreakerOpen
+	if b.probing || !b.now().Before(retryAt) {
+		status.State = BreakerHalfOpen
+	}
+	return status
+}
+
+// BreakerStatus 实现 HealthReporter
+func (c *httpClient) BreakerStatus() BreakerStatus {
+	return c.breakers.status()
+}
+
+// operationName 把请求归一为 "GET /api/v1/nodes/{id}" 形式，用于按操作配置超时
+func (c *httpClient) operationName(req *http.Request) string {
+	endpoint := req.URL.Path
+	if c.baseURL != nil {
+		endpoint = strings.TrimPrefix(endpoint, strings.TrimSuffix(c.baseURL.Path, "/"))
+	}
+	segments := strings.Split(endpoint, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+func (c *httpClient) timeoutFor(req *http.Request) time.Duration {
+	if timeout, ok := c.operationTimeouts[c.operationName(req)]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.timeout
+}
+
+// isSafeMethod 只读请求可以在结果不确定时重放
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// shouldRetry 判断单次尝试的结果是否值得重试；调用方的 context 结束后不再重试
+func shouldRetry(req *http.Request, statusCode int, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isSafeMethod(req.Method) || isDialError(err)
+	}
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isSafeMethod(req.Method)
+	}
+	return false
+}
+
+// isDialError 连接未建立，请求一定没有发出
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// upstreamFailure 返回计入熔断的失败：网络错误、5xx 与 429
+func upstreamFailure(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// backoff 指数退避加抖动：在 [d/2, d) 中随机；服务端给出 Retry-After 时以其为准，均不超过 MaxDelay
+func (c *httpClient) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(wait, c.retry.MaxDelay)
+		}
+	}
+	delay := c.retry.BaseDelay << attempt
+	if delay <= 0 || delay > c.retry.MaxDelay {
+		delay = c.retry.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseErrorBody 从 NDR 的错误响应中提取错误码与信息，兼容
+// {"detail": "..."}、{"detail": [{"loc": [...], "msg": "..."}]}、{"message": "...", "code": "..."}、
+// {"error": "..."} 与 {"error": {"code": "...", "message": "..."}}；非 JSON 时取纯文本
+func parseErrorBody(body []byte) (code, message string) {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return "", ""
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		if strings.HasPrefix(text, "<") {
+			return "", "" // HTML 错误页（如网关）没有可读信息
+		}
+		return "", truncateMessage(text)
+	}
+
+	code = jsonString(payload["code"])
+	if message = jsonString(payload["message"]); message != "" {
+		return code, truncateMessage(message)
+	}
+	if raw, ok := payload["detail"]; ok {
+		if message = jsonString(raw); message != "" {
+			return code, truncateMessage(message)
+		}
+		var details []struct {
+			Loc []any  `json:"loc"`
+			Msg string `json:"msg"`
+		}
+		if json.Unmarshal(raw, &details) == nil {
+			parts := make([]string, 0, len(details))
+			for _, d := range details {
+				loc := make([]string, 0, len(d.Loc))
+				for _, item := range d.Loc {
+					loc = append(loc, strings.Trim(string(mustJSON(item)), `"`))
+				}
+				if len(loc) > 0 {
+					parts = append(parts, strings.Join(loc, ".")+": "+d.Msg)
+				} else {
+					parts = append(parts, d.Msg)
+				}
+			}
+			return code, truncateMessage(strings.Join(parts, "; "))
+		}
+	}
+	if raw, ok := payload["error"]; ok {
+		if message = jsonString(raw); message != "" {
+			return code, truncateMessage(message)
+		}
+		var nested struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(raw, &nested) == nil {
+			if code == "" {
+				code = nested.Code
+			}
+			return code, truncateMessage(nested.Message)
+		}
+	}
+	return code, ""
+}
+
+func jsonString(raw json.RawMessage) string {
+	var s string
+	if len(raw) == 0 || json.Unmarshal(raw, &s) != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func truncateMessage(s string) string {
+	const limit = 500
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit]) + "..."
+}
@@ -0,0 +1,250 @@
+package ndrclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, cfg NDRConfig) *httpClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	if cfg.Retry.BaseDelay == 0 {
+		cfg.Retry.BaseDelay = time.Millisecond
+	}
+	if cfg.Retry.MaxDelay == 0 {
+		cfg.Retry.MaxDelay = 5 * time.Millisecond
+	}
+	return NewClient(cfg).(*httpClient)
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 7, "title": "ok"}`))
+	}, NDRConfig{Retry: RetryConfig{MaxRetries: 2}})
+
+	title := "ok"
+	doc, err := client.UpdateDocument(context.Background(), RequestMeta{}, 7, DocumentUpdate{Title: &title})
+	if err != nil || doc.ID != 7 {
+		t.Fatalf("expected success after retries, got %+v %v", doc, err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+	for _, body := range bodies {
+		if body != bodies[0] || body == "" {
+			t.Fatalf("request body should be replayed on every attempt, got %q", bodies)
+		}
+	}
+
+	// 重试次数用尽后返回最后一次的错误
+	calls.Store(-10)
+	if _, err := client.GetDocument(context.Background(), RequestMeta{}, 7); err == nil {
+		t.Fatalf("expected error once retries are exhausted")
+	}
+	if calls.Load() != -7 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load()+10)
+	}
+}
+
+func TestClientDoesNotRetryNonIdempotentOnGatewayErrors(t *testing.T) {
+	var calls atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}, NDRConfig{Retry: RetryConfig{MaxRetries: 3}})
+
+	_, err := client.CreateNode(context.Background(), RequestMeta{}, NodeCreate{Name: "x"})
+	var ndrErr *Error
+	if !errors.As(err, &ndrErr) || ndrErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("POST should not be retried on 502, got %d attempts", calls.Load())
+	}
+
+	// PUT 生成新版本、DELETE 重放返回 404，网关错误时同样不重试
+	calls.Store(0)
+	title := "x"
+	if _, err := client.UpdateDocument(context.Background(), RequestMeta{}, 1, DocumentUpdate{Title: &title}); err == nil {
+		t.Fatalf("expected 502 error for PUT")
+	}
+	if err := client.DeleteDocument(context.Background(), RequestMeta{}, 1); err == nil {
+		t.Fatalf("expected 502 error for DELETE")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("PUT and DELETE should not be retried on 502, got %d attempts", calls.Load())
+	}
+}
+
+func TestClientParsesErrorBody(t *testing.T) {
+	cases := map[string]string{
+		`{"detail": "document not found"}`:                                   "document not found",
+		`{"detail": [{"loc": ["body", "title"], "msg": "field required"}]}`:  "body.title: field required",
+		`{"error": {"code": "DOC_LOCKED", "message": "document is locked"}}`: "document is locked",
+		`{"message": "invalid type", "code": "INVALID_TYPE"}`:                "invalid type",
+		"upstream exploded":                         "upstream exploded",
+		"<html><body>502 Bad Gateway</body></html>": "",
+	}
+	for body, want := range cases {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(body))
+		}, NDRConfig{})
+		_, err := client.GetDocument(context.Background(), RequestMeta{}, 1)
+		var ndrErr *Error
+		if !errors.As(err, &ndrErr) || ndrErr.StatusCode != http.StatusUnprocessableEntity || ndrErr.Message != want {
+			t.Fatalf("body %q: expected message %q, got %#v", body, want, err)
+		}
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}, NDRConfig{Breaker: BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	client.breakers.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetDocument(ctx, RequestMeta{}, 1); err == nil {
+			t.Fatalf("expected upstream error")
+		}
+	}
+	if s := client.BreakerStatus(); s.State != BreakerOpen || s.ConsecutiveFailures != 2 ||
+		s.Operations["GET /api/v1/documents/{id}"].State != BreakerOpen {
+		t.Fatalf("expected open breaker, got %+v", s)
+	}
+	if _, err := client.GetDocument(ctx, RequestMeta{}, 1); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected fast failure while open, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("open breaker should not reach NDR, got %d calls", calls.Load())
+	}
+	// 熔断只作用于失败的操作，其他接口仍然访问 NDR
+	if _, err := client.GetNode(ctx, RequestMeta{}, 1, GetNodeOptions{}); errors.Is(err, ErrCircuitOpen) || calls.Load() != 3 {
+		t.Fatalf("other operations should still reach NDR, got %v after %d calls", err, calls.Load())
+	}
+
+	// 冷却结束后放行探测请求，成功即恢复
+	now = now.Add(time.Minute)
+	status.Store(http.StatusOK)
+	if s := client.BreakerStatus(); s.State != BreakerHalfOpen {
+		t.Fatalf("expected half open breaker, got %+v", s)
+	}
+	if _, err := client.GetDocument(ctx, RequestMeta{}, 1); err != nil {
+		t.Fatalf("probe request: %v", err)
+	}
+	if _, err := client.GetNode(ctx, RequestMeta{}, 1, GetNodeOptions{}); err != nil {
+		t.Fatalf("node request: %v", err)
+	}
+	if s := client.BreakerStatus(); s.State != BreakerClosed || s.ConsecutiveFailures != 0 {
+		t.Fatalf("expected closed breaker, got %+v", s)
+	}
+
+	// 4xx 说明上游可用，不计入失败
+	status.Store(http.StatusNotFound)
+	for i := 0; i < 3; i++ {
+		_, _ = client.GetDocument(ctx, RequestMeta{}, 1)
+	}
+	if s := client.BreakerStatus(); s.State != BreakerClosed || s.ConsecutiveFailures != 0 {
+		t.Fatalf("client errors should not open the breaker, got %+v", s)
+	}
+}
+
+func TestClientCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	var status atomic.Int32
+	var hang atomic.Bool
+	status.Store(http.StatusInternalServerError)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}, NDRConfig{Breaker: BreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	client.breakers.now = func() time.Time { return now }
+	ctx := context.Background()
+	cancelled := func() error {
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := client.GetDocument(ctx, RequestMeta{}, 1)
+		return err
+	}
+
+	// 调用方取消不会清零已有的失败计数
+	if _, err := client.GetDocument(ctx, RequestMeta{}, 1); err == nil {
+		t.Fatal("expected upstream error")
+	}
+	hang.Store(true)
+	if err := cancelled(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected caller deadline, got %v", err)
+	}
+	if s := client.BreakerStatus(); s.ConsecutiveFailures != 1 {
+		t.Fatalf("cancelled request should not change the failure count, got %+v", s)
+	}
+	hang.Store(false)
+	_, _ = client.GetDocument(ctx, RequestMeta{}, 1)
+	if s := client.BreakerStatus(); s.State != BreakerOpen {
+		t.Fatalf("expected open breaker, got %+v", s)
+	}
+
+	// 探测请求被调用方取消：释放探测但不闭合熔断器，下一个请求仍可探测
+	now = now.Add(time.Minute)
+	hang.Store(true)
+	if err := cancelled(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected caller deadline on probe, got %v", err)
+	}
+	if s := client.BreakerStatus(); s.State != BreakerHalfOpen || s.ConsecutiveFailures != 2 {
+		t.Fatalf("cancelled probe should leave the breaker half open, got %+v", s)
+	}
+	hang.Store(false)
+	status.Store(http.StatusOK)
+	if _, err := client.GetDocument(ctx, RequestMeta{}, 1); err != nil {
+		t.Fatalf("next probe should be allowed: %v", err)
+	}
+	if s := client.BreakerStatus(); s.State != BreakerClosed {
+		t.Fatalf("expected closed breaker after a successful probe, got %+v", s)
+	}
+}
+
+func TestClientOperationTimeout(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"id": 2}`))
+	}, NDRConfig{
+		Timeout:           10 * time.Millisecond,
+		OperationTimeouts: map[string]time.Duration{"GET /api/v1/documents/{id}": time.Second},
+	})
+	if _, err := client.GetDocument(context.Background(), RequestMeta{}, 2); err != nil {
+		t.Fatalf("operation timeout should override the default: %v", err)
+	}
+	if _, err := client.UpdateDocument(context.Background(), RequestMeta{}, 2, DocumentUpdate{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the default timeout to apply, got %v", err)
+	}
+	if op := client.operationName(httptest.NewRequest(http.MethodPost, "/api/v1/nodes/12/purge", nil)); op != "POST /api/v1/nodes/{id}/purge" {
+		t.Fatalf("unexpected operation name %q", op)
+	}
+}
@@ -0,0 +1,280 @@
+package ndrfake
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+const (
+	assetBucket       = "ndr-fake"
+	presignExpiresIn  = 3600
+	assetUploading    = "uploading"
+	assetReady        = "ready"
+	assetAborted      = "aborted"
+	maxMultipartParts = 10000
+)
+
+// asset 在内存中保存分片与合并后的内容，预签名 URL 指向本服务的 /_storage 接口
+type asset struct {
+	meta     ndrclient.Asset
+	uploadID string
+	parts    map[int][]byte
+	content  []byte
+}
+
+func quotedETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *Server) publicURL(r *http.Request) string {
+	if s.opts.PublicURL != "" {
+		return strings.TrimRight(s.opts.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (s *Server) liveAsset(r *http.Request) (*asset, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+	a, ok := s.assets[id]
+	if !ok || a.meta.DeletedAt != nil {
+		return nil, errNotFound("asset %d not found", id)
+	}
+	return a, nil
+}
+
+func (s *Server) uploadingAsset(r *http.Request) (*asset, error) {
+	a, err := s.liveAsset(r)
+	if err != nil {
+		return nil, err
+	}
+	if a.meta.Status != assetUploading {
+		return nil, errStatus(http.StatusConflict, "asset %d is %s", a.meta.ID, a.meta.Status)
+	}
+	return a, nil
+}
+
+func (s *Server) initUpload(w http.ResponseWriter, r *http.Request, user string) error {
+	var body ndrclient.AssetInitRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if strings.TrimSpace(body.Filename) == "" {
+		return errInvalid("filename is required")
+	}
+	if body.SizeBytes < 0 {
+		return errInvalid("size_bytes must not be negative")
+	}
+	s.nextAssetID++
+	id := s.nextAssetID
+	ts := now()
+	a := &asset{
+		meta: ndrclient.Asset{
+			ID:        id,
+			Filename:  body.Filename,
+			SizeBytes: body.SizeBytes,
+			Status:    assetUploading,
+			Bucket:    assetBucket,
+			ObjectKey: fmt.Sprintf("assets/%d/%s", id, body.Filename),
+			CreatedBy: user,
+			UpdatedBy: user,
+			CreatedAt: ts,
+			UpdatedAt: ts,
+		},
+		uploadID: fmt.Sprintf("upload-%d-%d", id, ts.UnixNano()),
+		parts:    make(map[int][]byte),
+	}
+	if body.ContentType != "" {
+		contentType := body.ContentType
+		a.meta.ContentType = &contentType
+	}
+	s.assets[id] = a
+	writeJSON(w, http.StatusCreated, ndrclient.AssetInitResponse{
+		Asset:         a.meta,
+		UploadID:      a.uploadID,
+		PartSizeBytes: s.opts.PartSizeBytes,
+		ExpiresIn:     presignExpiresIn,
+	})
+	return nil
+}
+
+func (s *Server) partURLs(w http.ResponseWriter, r *http.Request, _ string) error {
+	a, err := s.uploadingAsset(r)
+	if err != nil {
+		return err
+	}
+	var body ndrclient.AssetPartURLsRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if len(body.PartNumbers) == 0 {
+		return errInvalid("part_numbers is required")
+	}
+	resp := ndrclient.AssetPartURLsResponse{UploadID: a.uploadID, ExpiresIn: presignExpiresIn}
+	for _, number := range body.PartNumbers {
+		if number < 1 || number > maxMultipartParts {
+			return errInvalid("invalid part number %d", number)
+		}
+		resp.URLs = append(resp.URLs, ndrclient.AssetPartURL{
+			PartNumber: number,
+			URL:        fmt.Sprintf("%s/_storage/uploads/%s/%d", s.publicURL(r), a.uploadID, number),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// uploadPart 模拟对象存储的分片 PUT，响应头返回带引号的 ETag
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("part"))
+	if err != nil || number < 1 || number > maxMultipartParts {
+		http.Error(w, "invalid part number", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.assets {
+		if a.uploadID == r.PathValue("upload") && a.meta.Status == assetUploading {
+			a.parts[number] = data
+			w.Header().Set("ETag", quotedETag(data))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	http.Error(w, "no such upload", http.StatusNotFound)
+}
+
+// completeUpload 按分片号合并内容，ETag 必须与上传时返回的一致
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, user string) error {
+	a, err := s.uploadingAsset(r)
+	if err != nil {
+		return err
+	}
+	var body ndrclient.AssetCompleteRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if len(body.Parts) == 0 {
+		return errInvalid("parts is required")
+	}
+	parts := append([]ndrclient.AssetCompletedPart(nil), body.Parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	var content bytes.Buffer
+	for _, part := range parts {
+		data, ok := a.parts[part.PartNumber]
+		if !ok {
+			return errStatus(http.StatusBadRequest, "part %d has not been uploaded", part.PartNumber)
+		}
+		if strings.Trim(part.ETag, `"`) != strings.Trim(quotedETag(data), `"`) {
+			return errStatus(http.StatusBadRequest, "etag mismatch for part %d", part.PartNumber)
+		}
+		content.Write(data)
+	}
+	etag := strings.Trim(quotedETag(content.Bytes()), `"`)
+	a.content = content.Bytes()
+	a.parts = nil
+	a.meta.SizeBytes = int64(len(a.content))
+	a.meta.Status = assetReady
+	a.meta.ETag = &etag
+	a.meta.UpdatedBy = user
+	a.meta.UpdatedAt = now()
+	writeJSON(w, http.StatusOK, a.meta)
+	return nil
+}
+
+func (s *Server) abortUpload(w http.ResponseWriter, r *http.Request, user string) error {
+	a, err := s.uploadingAsset(r)
+	if err != nil {
+		return err
+	}
+	a.parts = nil
+	a.meta.Status = assetAborted
+	a.meta.UpdatedBy = user
+	a.meta.UpdatedAt = now()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) getAsset(w http.ResponseWriter, r *http.Request, _ string) error {
+	a, err := s.liveAsset(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, a.meta)
+	return nil
+}
+
+func (s *Server) downloadURL(w http.ResponseWriter, r *http.Request, _ string) error {
+	a, err := s.liveAsset(r)
+	if err != nil {
+		return err
+	}
+	if a.meta.Status != assetReady {
+		return errStatus(http.StatusConflict, "asset %d is %s", a.meta.ID, a.meta.Status)
+	}
+	writeJSON(w, http.StatusOK, ndrclient.AssetDownloadURLResponse{
+		URL:       fmt.Sprintf("%s/_storage/assets/%d", s.publicURL(r), a.meta.ID),
+		ExpiresIn: presignExpiresIn,
+	})
+	return nil
+}
+
+func (s *Server) downloadObject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid asset id", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	a, ok := s.assets[id]
+	if !ok || a.meta.DeletedAt != nil || a.meta.Status != assetReady {
+		s.mu.Unlock()
+		http.Error(w, "no such object", http.StatusNotFound)
+		return
+	}
+	content, meta := a.content, a.meta
+	s.mu.Unlock()
+
+	if meta.ContentType != nil {
+		w.Header().Set("Content-Type", *meta.ContentType)
+	}
+	w.Header().Set("ETag", `"`+*meta.ETag+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	_, _ = w.Write(content)
+}
+
+func (s *Server) deleteAsset(w http.ResponseWriter, r *http.Request, user string) error {
+	a, err := s.liveAsset(r)
+	if err != nil {
+		return err
+	}
+	ts := now()
+	a.meta.DeletedAt = &ts
+	a.meta.UpdatedAt = ts
+	a.meta.UpdatedBy = user
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,655 @@
+package ndrfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+func (s *Server) liveDocument(id int64) (*ndrclient.Document, error) {
+	doc, ok := s.documents[id]
+	if !ok || doc.DeletedAt != nil {
+		return nil, errNotFound("document %d not found", id)
+	}
+	return doc, nil
+}
+
+func renderDocument(doc *ndrclient.Document) ndrclient.Document {
+	out := *doc
+	out.Content = cloneMap(doc.Content)
+	out.Metadata = cloneMap(doc.Metadata)
+	if doc.Version != nil {
+		version := *doc.Version
+		out.Version = &version
+	}
+	return out
+}
+
+func renderDocuments(docs []*ndrclient.Document) []ndrclient.Document {
+	out := make([]ndrclient.Document, 0, len(docs))
+	for _, doc := range docs {
+		out = append(out, renderDocument(doc))
+	}
+	return out
+}
+
+// recordVersion 把文档当前状态记为新版本
+func (s *Server) recordVersion(doc *ndrclient.Document, user string, message *string) {
+	number := len(s.versions[doc.ID]) + 1
+	doc.Version = &number
+	s.versions[doc.ID] = append(s.versions[doc.ID], ndrclient.DocumentVersion{
+		DocumentID:    doc.ID,
+		VersionNumber: number,
+		Title:         doc.Title,
+		Content:       cloneMap(doc.Content),
+		Metadata:      cloneMap(doc.Metadata),
+		Type:          doc.Type,
+		CreatedBy:     user,
+		CreatedAt:     doc.UpdatedAt,
+		ChangeMessage: message,
+	})
+}
+
+func (s *Server) insertDocument(body ndrclient.DocumentCreate, user string) (*ndrclient.Document, error) {
+	title := strings.TrimSpace(body.Title)
+	if title == "" {
+		return nil, errInvalid("title is required")
+	}
+	s.nextDocID++
+	position := 1
+	for _, doc := range s.documents {
+		position = max(position, doc.Position+1)
+	}
+	if body.Position != nil {
+		position = *body.Position
+	}
+	ts := now()
+	doc := &ndrclient.Document{
+		ID:        s.nextDocID,
+		Title:     title,
+		Content:   cloneMap(body.Content),
+		Metadata:  cloneMap(body.Metadata),
+		Type:      body.Type,
+		Position:  position,
+		CreatedBy: user,
+		UpdatedBy: user,
+		CreatedAt: ts,
+		UpdatedAt: ts,
+	}
+	s.documents[doc.ID] = doc
+	s.recordVersion(doc, user, nil)
+	return doc, nil
+}
+
+func (s *Server) createDocument(w http.ResponseWriter, r *http.Request, user string) error {
+	var body ndrclient.DocumentCreate
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	doc, err := s.insertDocument(body, user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, renderDocument(doc))
+	return nil
+}
+
+func (s *Server) getDocument(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	doc, ok := s.documents[id]
+	if !ok || (doc.DeletedAt != nil && !queryBool(r, "include_deleted", false)) {
+		return errNotFound("document %d not found", id)
+	}
+	writeJSON(w, http.StatusOK, renderDocument(doc))
+	return nil
+}
+
+// updateDocument 只更新传入的字段；title/content/metadata/type 有变化时生成新版本。
+// 带 If-Match 时版本不一致返回 412
+func (s *Server) updateDocument(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	doc, err := s.liveDocument(id)
+	if err != nil {
+		return err
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		base, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+		if err != nil {
+			return errInvalid("invalid If-Match %q", ifMatch)
+		}
+		if doc.Version != nil && *doc.Version != base {
+			return errStatus(http.StatusPreconditionFailed, "document %d is at version %d, not %d", id, *doc.Version, base)
+		}
+	}
+	var body ndrclient.DocumentUpdate
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+
+	changed := false
+	if body.Title != nil && *body.Title != doc.Title {
+		if strings.TrimSpace(*body.Title) == "" {
+			return errInvalid("title must not be empty")
+		}
+		doc.Title, changed = *body.Title, true
+	}
+	if body.Content != nil && !reflect.DeepEqual(cloneMap(body.Content), doc.Content) {
+		doc.Content, changed = cloneMap(body.Content), true
+	}
+	if body.Metadata != nil {
+		// metadata 按 JSON Merge Patch（RFC 7396）合并：null 删除键，嵌套对象逐层合并
+		if merged := mergePatch(doc.Metadata, cloneMap(body.Metadata)); !reflect.DeepEqual(merged, doc.Metadata) {
+			doc.Metadata, changed = merged, true
+		}
+	}
+	if body.Type != nil && (doc.Type == nil || *doc.Type != *body.Type) {
+		doc.Type, changed = body.Type, true
+	}
+	if body.Position != nil {
+		doc.Position = *body.Position
+	}
+	doc.UpdatedBy = user
+	doc.UpdatedAt = now()
+	if changed {
+		s.recordVersion(doc, user, nil)
+	}
+	writeJSON(w, http.StatusOK, renderDocument(doc))
+	return nil
+}
+
+func (s *Server) deleteDocument(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	doc, err := s.liveDocument(id)
+	if err != nil {
+		return err
+	}
+	ts := now()
+	doc.DeletedAt = &ts
+	doc.UpdatedAt = ts
+	doc.UpdatedBy = user
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) restoreDocument(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	doc, ok := s.documents[id]
+	if !ok {
+		return errNotFound("document %d not found", id)
+	}
+	if doc.DeletedAt != nil {
+		doc.DeletedAt = nil
+		doc.UpdatedAt = now()
+		doc.UpdatedBy = user
+	}
+	writeJSON(w, http.StatusOK, renderDocument(doc))
+	return nil
+}
+
+// purgeDocument 永久删除文档、版本以及绑定和来源关系
+func (s *Server) purgeDocument(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.documents[id]; !ok {
+		return errNotFound("document %d not found", id)
+	}
+	delete(s.documents, id)
+	delete(s.versions, id)
+	for _, docs := range s.bindings {
+		delete(docs, id)
+	}
+	for _, docs := range s.sources {
+		delete(docs, id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// documentFilter 支持的查询参数：include_deleted、type、id（可重复或逗号分隔）、
+// query（标题包含，不区分大小写）以及 metadata.<key>=<value>
+type documentFilter struct {
+	includeDeleted bool
+	types          []string
+	ids            map[int64]bool
+	query          string
+	metadata       map[string]string
+}
+
+func parseDocumentFilter(r *http.Request) (documentFilter, error) {
+	query := r.URL.Query()
+	filter := documentFilter{
+		includeDeleted: queryBool(r, "include_deleted", false),
+		types:          query["type"],
+		query:          strings.ToLower(strings.TrimSpace(query.Get("query"))),
+		metadata:       make(map[string]string),
+	}
+	for _, raw := range query["id"] {
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(part, 10, 64)
+			if err != nil {
+				return documentFilter{}, errInvalid("invalid id %q", part)
+			}
+			if filter.ids == nil {
+				filter.ids = make(map[int64]bool)
+			}
+			filter.ids[id] = true
+		}
+	}
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "metadata."); ok && len(values) > 0 {
+			filter.metadata[name] = values[0]
+		}
+	}
+	return filter, nil
+}
+
+func (f documentFilter) match(doc *ndrclient.Document) bool {
+	if doc.DeletedAt != nil && !f.includeDeleted {
+		return false
+	}
+	if f.ids != nil && !f.ids[doc.ID] {
+		return false
+	}
+	if len(f.types) > 0 {
+		docType := ""
+		if doc.Type != nil {
+			docType = *doc.Type
+		}
+		found := false
+		for _, t := range f.types {
+			found = found || t == docType
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.query != "" && !strings.Contains(strings.ToLower(doc.Title), f.query) {
+		return false
+	}
+	for key, want := range f.metadata {
+		value, ok := doc.Metadata[key]
+		if !ok || !metadataEquals(value, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataEquals 比较元数据值与查询字符串；数组值包含该元素即视为匹配
+func metadataEquals(value any, want string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == want
+	case []any:
+		for _, item := range v {
+			if metadataEquals(item, want) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return want == "null"
+	}
+	return fmt.Sprint(value) == want
+}
+
+func sortDocuments(docs []*ndrclient.Document) {
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Position != docs[j].Position {
+			return docs[i].Position < docs[j].Position
+		}
+		return docs[i].ID < docs[j].ID
+	})
+}
+
+func (s *Server) writeDocumentsPage(w http.ResponseWriter, r *http.Request, candidates []*ndrclient.Document) error {
+	filter, err := parseDocumentFilter(r)
+	if err != nil {
+		return err
+	}
+	items := make([]*ndrclient.Document, 0, len(candidates))
+	for _, doc := range candidates {
+		if filter.match(doc) {
+			items = append(items, doc)
+		}
+	}
+	sortDocuments(items)
+	page, size := s.pageParams(r)
+	writeJSON(w, http.StatusOK, ndrclient.DocumentsPage{
+		Page:  page,
+		Size:  size,
+		Total: len(items),
+		Items: renderDocuments(paginate(items, page, size)),
+	})
+	return nil
+}
+
+func (s *Server) listDocuments(w http.ResponseWriter, r *http.Request, _ string) error {
+	docs := make([]*ndrclient.Document, 0, len(s.documents))
+	for _, doc := range s.documents {
+		docs = append(docs, doc)
+	}
+	return s.writeDocumentsPage(w, r, docs)
+}
+
+// subtreeDocuments 返回绑定在节点（include_descendants 默认 true 时含未删除后代）上的文档
+func (s *Server) subtreeDocuments(w http.ResponseWriter, r *http.Request, node *ndrclient.Node) error {
+	nodeIDs := []int64{node.ID}
+	if queryBool(r, "include_descendants", true) {
+		nodeIDs = s.subtree(node.ID)
+	}
+	seen := make(map[int64]bool)
+	var docs []*ndrclient.Document
+	for _, nodeID := range nodeIDs {
+		if s.nodes[nodeID].DeletedAt != nil {
+			continue
+		}
+		for docID := range s.bindings[nodeID] {
+			if doc, ok := s.documents[docID]; ok && !seen[docID] {
+				seen[docID] = true
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return s.writeDocumentsPage(w, r, docs)
+}
+
+func (s *Server) listNodeDocuments(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	node, err := s.liveNode(id)
+	if err != nil {
+		return err
+	}
+	return s.subtreeDocuments(w, r, node)
+}
+
+func (s *Server) listNodeDocumentsByPath(w http.ResponseWriter, r *http.Request, _ string) error {
+	p := r.URL.Query().Get("path")
+	node := s.nodeByPath(p, false)
+	if node == nil {
+		return errNotFound("node %s not found", p)
+	}
+	return s.subtreeDocuments(w, r, node)
+}
+
+// reorderDocuments 在未删除文档中按 ordered_ids 重排；请求带 type 字段时只在该类型（null 表示无类型）内重排
+func (s *Server) reorderDocuments(w http.ResponseWriter, r *http.Request, user string) error {
+	var body map[string]json.RawMessage
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	var orderedIDs []int64
+	if err := json.Unmarshal(body["ordered_ids"], &orderedIDs); err != nil || len(orderedIDs) == 0 {
+		return errInvalid("ordered_ids is required")
+	}
+	rawType, filterByType := body["type"]
+	var docType *string
+	if filterByType {
+		if err := json.Unmarshal(rawType, &docType); err != nil {
+			return errInvalid("type must be a string or null")
+		}
+	}
+
+	var scope []*ndrclient.Document
+	for _, doc := range s.documents {
+		if doc.DeletedAt != nil {
+			continue
+		}
+		if filterByType && !sameType(doc.Type, docType) {
+			continue
+		}
+		scope = append(scope, doc)
+	}
+	sortDocuments(scope)
+
+	byID := make(map[int64]*ndrclient.Document, len(scope))
+	for _, doc := range scope {
+		byID[doc.ID] = doc
+	}
+	ordered := make([]*ndrclient.Document, 0, len(scope))
+	seen := make(map[int64]bool, len(orderedIDs))
+	for _, id := range orderedIDs {
+		doc, ok := byID[id]
+		if !ok {
+			return errNotFound("document %d not found", id)
+		}
+		if seen[id] {
+			return errStatus(http.StatusBadRequest, "duplicate document id %d", id)
+		}
+		seen[id] = true
+		ordered = append(ordered, doc)
+	}
+	for _, doc := range scope {
+		if !seen[doc.ID] {
+			ordered = append(ordered, doc)
+		}
+	}
+	ts := now()
+	for i, doc := range ordered {
+		if doc.Position != i+1 {
+			doc.Position = i + 1
+			doc.UpdatedAt = ts
+			doc.UpdatedBy = user
+		}
+	}
+	writeJSON(w, http.StatusOK, renderDocuments(ordered))
+	return nil
+}
+
+func sameType(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (s *Server) bindingStatus(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.documents[id]; !ok {
+		return errNotFound("document %d not found", id)
+	}
+	status := ndrclient.DocumentBindingStatus{NodeIDs: []int64{}}
+	for _, nodeID := range sortedKeys(s.bindings) {
+		if _, ok := s.bindings[nodeID][id]; ok {
+			status.NodeIDs = append(status.NodeIDs, nodeID)
+		}
+	}
+	status.TotalBindings = len(status.NodeIDs)
+	writeJSON(w, http.StatusOK, status)
+	return nil
+}
+
+func (s *Server) documentBindings(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.documents[id]; !ok {
+		return errNotFound("document %d not found", id)
+	}
+	items := []ndrclient.DocumentBinding{}
+	for _, nodeID := range sortedKeys(s.bindings) {
+		b, ok := s.bindings[nodeID][id]
+		if !ok {
+			continue
+		}
+		node := s.nodes[nodeID]
+		items = append(items, ndrclient.DocumentBinding{
+			NodeID:    nodeID,
+			NodeName:  node.Name,
+			NodePath:  node.Path,
+			CreatedAt: b.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			CreatedBy: b.CreatedBy,
+		})
+	}
+	writeJSON(w, http.StatusOK, items)
+	return nil
+}
+
+func (s *Server) findVersion(r *http.Request) (int64, ndrclient.DocumentVersion, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return 0, ndrclient.DocumentVersion{}, err
+	}
+	number, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		return 0, ndrclient.DocumentVersion{}, errInvalid("invalid version %q", r.PathValue("version"))
+	}
+	version, err := s.version(id, number)
+	return id, version, err
+}
+
+func (s *Server) version(docID int64, number int) (ndrclient.DocumentVersion, error) {
+	if _, ok := s.documents[docID]; !ok {
+		return ndrclient.DocumentVersion{}, errNotFound("document %d not found", docID)
+	}
+	versions := s.versions[docID]
+	if number < 1 || number > len(versions) {
+		return ndrclient.DocumentVersion{}, errNotFound("version %d of document %d not found", number, docID)
+	}
+	return versions[number-1], nil
+}
+
+// listVersions 按版本号倒序分页
+func (s *Server) listVersions(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.documents[id]; !ok {
+		return errNotFound("document %d not found", id)
+	}
+	versions := s.versions[id]
+	items := make([]ndrclient.DocumentVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		items = append(items, versions[i])
+	}
+	page, size := s.pageParams(r)
+	writeJSON(w, http.StatusOK, ndrclient.DocumentVersionsPage{
+		Page:     page,
+		Size:     size,
+		Total:    len(items),
+		Versions: paginate(items, page, size),
+	})
+	return nil
+}
+
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request, _ string) error {
+	_, version, err := s.findVersion(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, version)
+	return nil
+}
+
+// versionDiff 按顶层键比较 content 与 metadata，变化的键给出 {"old", "new"}
+func (s *Server) versionDiff(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, from, err := s.findVersion(r)
+	if err != nil {
+		return err
+	}
+	toNumber, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		return errInvalid("query parameter to is required")
+	}
+	to, err := s.version(id, toNumber)
+	if err != nil {
+		return err
+	}
+	diff := ndrclient.DocumentVersionDiff{
+		FromVersion: from.VersionNumber,
+		ToVersion:   to.VersionNumber,
+		ContentDiff: diffMaps(from.Content, to.Content),
+		MetaDiff:    diffMaps(from.Metadata, to.Metadata),
+	}
+	if from.Title != to.Title {
+		diff.TitleDiff = &ndrclient.DiffDetail{Old: from.Title, New: to.Title}
+	}
+	writeJSON(w, http.StatusOK, diff)
+	return nil
+}
+
+func diffMaps(from, to map[string]any) map[string]any {
+	diff := make(map[string]any)
+	for key, old := range from {
+		if value, ok := to[key]; !ok || !reflect.DeepEqual(old, value) {
+			diff[key] = ndrclient.DiffDetail{Old: old, New: to[key]}
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			diff[key] = ndrclient.DiffDetail{Old: nil, New: value}
+		}
+	}
+	return diff
+}
+
+// restoreVersion 把指定版本的内容写回文档并生成新版本
+func (s *Server) restoreVersion(w http.ResponseWriter, r *http.Request, user string) error {
+	id, version, err := s.findVersion(r)
+	if err != nil {
+		return err
+	}
+	doc, err := s.liveDocument(id)
+	if err != nil {
+		return err
+	}
+	doc.Title = version.Title
+	doc.Content = cloneMap(version.Content)
+	doc.Metadata = cloneMap(version.Metadata)
+	doc.Type = version.Type
+	doc.UpdatedBy = user
+	doc.UpdatedAt = now()
+	message := fmt.Sprintf("Restored from version %d", version.VersionNumber)
+	s.recordVersion(doc, user, &message)
+	writeJSON(w, http.StatusOK, renderDocument(doc))
+	return nil
+}
+
+// mergePatch 按 RFC 7396 把 patch 合并到 target 的副本上并返回结果
+func mergePatch(target, patch map[string]any) map[string]any {
+	out := cloneMap(target)
+	for key, value := range patch {
+		if value == nil {
+			delete(out, key)
+			continue
+		}
+		if sub, ok := value.(map[string]any); ok {
+			existing, _ := out[key].(map[string]any)
+			out[key] = mergePatch(existing, sub)
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
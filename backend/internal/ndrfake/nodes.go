@@ -0,0 +1,669 @@
+package ndrfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+var (
+	slugPattern    = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	slugInvalidSeq = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// normalizePath 节点路径统一存为 "/a/b"；查询时也接受 "a.b" 形式（slug 不含点号，两者可无歧义互转）
+func normalizePath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+	if !strings.Contains(p, "/") {
+		p = strings.ReplaceAll(p, ".", "/")
+	}
+	return "/" + strings.Trim(p, "/")
+}
+
+func parentKey(parentID *int64) int64 {
+	if parentID == nil {
+		return 0
+	}
+	return *parentID
+}
+
+func (s *Server) nodeByPath(p string, includeDeleted bool) *ndrclient.Node {
+	p = normalizePath(p)
+	for _, id := range sortedKeys(s.nodes) {
+		node := s.nodes[id]
+		if node.Path == p && (includeDeleted || node.DeletedAt == nil) {
+			return node
+		}
+	}
+	return nil
+}
+
+func (s *Server) liveNode(id int64) (*ndrclient.Node, error) {
+	node, ok := s.nodes[id]
+	if !ok || node.DeletedAt != nil {
+		return nil, errNotFound("node %d not found", id)
+	}
+	return node, nil
+}
+
+// children 按 position 返回直接子节点
+func (s *Server) children(parentID *int64, includeDeleted bool) []*ndrclient.Node {
+	var items []*ndrclient.Node
+	for _, node := range s.nodes {
+		if parentKey(node.ParentID) == parentKey(parentID) && (includeDeleted || node.DeletedAt == nil) {
+			items = append(items, node)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// subtree 返回节点及其全部后代（含已删除）的 ID，按层级顺序
+func (s *Server) subtree(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		current := ids[i]
+		for _, child := range s.children(&current, true) {
+			ids = append(ids, child.ID)
+		}
+	}
+	return ids
+}
+
+// renderNode 返回节点副本并计算子树下未删除文档的数量
+func (s *Server) renderNode(node *ndrclient.Node) ndrclient.Node {
+	out := *node
+	seen := make(map[int64]bool)
+	for _, id := range s.subtree(node.ID) {
+		if n := s.nodes[id]; n.DeletedAt != nil {
+			continue
+		}
+		for docID := range s.bindings[id] {
+			if doc, ok := s.documents[docID]; ok && doc.DeletedAt == nil {
+				seen[docID] = true
+			}
+		}
+	}
+	out.SubtreeDocCount = len(seen)
+	return out
+}
+
+func (s *Server) renderNodes(nodes []*ndrclient.Node) []ndrclient.Node {
+	out := make([]ndrclient.Node, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, s.renderNode(node))
+	}
+	return out
+}
+
+func composePath(parent *ndrclient.Node, slug string) string {
+	if parent == nil {
+		return "/" + slug
+	}
+	return parent.Path + "/" + slug
+}
+
+func (s *Server) checkPathFree(p string, self int64) error {
+	if existing := s.nodeByPath(p, false); existing != nil && existing.ID != self {
+		return errStatus(http.StatusConflict, "node path %s already exists", p)
+	}
+	return nil
+}
+
+func (s *Server) resolveParent(parentPath string) (*ndrclient.Node, error) {
+	if normalizePath(parentPath) == "" {
+		return nil, nil
+	}
+	parent := s.nodeByPath(parentPath, false)
+	if parent == nil {
+		return nil, errNotFound("parent node %s not found", parentPath)
+	}
+	return parent, nil
+}
+
+func deriveSlug(name string, id int64) string {
+	slug := strings.Trim(slugInvalidSeq.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-"), "-")
+	if slug == "" {
+		slug = fmt.Sprintf("node-%d", id)
+	}
+	return slug
+}
+
+func (s *Server) insertNode(body ndrclient.NodeCreate, user string) (*ndrclient.Node, error) {
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		return nil, errInvalid("name is required")
+	}
+	var parent *ndrclient.Node
+	if body.ParentPath != nil {
+		var err error
+		if parent, err = s.resolveParent(*body.ParentPath); err != nil {
+			return nil, err
+		}
+	}
+	s.nextNodeID++
+	id := s.nextNodeID
+	slug := deriveSlug(name, id)
+	if body.Slug != nil && strings.TrimSpace(*body.Slug) != "" {
+		slug = strings.TrimSpace(*body.Slug)
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, errInvalid("invalid slug %q", slug)
+	}
+	path := composePath(parent, slug)
+	if err := s.checkPathFree(path, 0); err != nil {
+		return nil, err
+	}
+
+	var parentID *int64
+	if parent != nil {
+		parentID = &parent.ID
+	}
+	position := 1
+	for _, sibling := range s.children(parentID, true) {
+		position = max(position, sibling.Position+1)
+	}
+	ts := now()
+	node := &ndrclient.Node{
+		ID:        id,
+		Name:      name,
+		Slug:      slug,
+		Type:      body.Type,
+		Path:      path,
+		ParentID:  parentID,
+		Position:  position,
+		CreatedBy: user,
+		UpdatedBy: user,
+		CreatedAt: ts,
+		UpdatedAt: ts,
+	}
+	s.nodes[id] = node
+	return node, nil
+}
+
+func (s *Server) createNode(w http.ResponseWriter, r *http.Request, user string) error {
+	var body ndrclient.NodeCreate
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	node, err := s.insertNode(body, user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, s.renderNode(node))
+	return nil
+}
+
+func (s *Server) getNode(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	node, ok := s.nodes[id]
+	if !ok || (node.DeletedAt != nil && !queryBool(r, "include_deleted", false)) {
+		return errNotFound("node %d not found", id)
+	}
+	writeJSON(w, http.StatusOK, s.renderNode(node))
+	return nil
+}
+
+func (s *Server) getNodeByPath(w http.ResponseWriter, r *http.Request, _ string) error {
+	p := r.URL.Query().Get("path")
+	node := s.nodeByPath(p, queryBool(r, "include_deleted", false))
+	if node == nil {
+		return errNotFound("node %s not found", p)
+	}
+	writeJSON(w, http.StatusOK, s.renderNode(node))
+	return nil
+}
+
+// updateNode 的 parent_path 与 type 需要区分未传与显式 null，因此按原始 JSON 解析
+func (s *Server) updateNode(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	node, err := s.liveNode(id)
+	if err != nil {
+		return err
+	}
+	var body map[string]json.RawMessage
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+
+	updated := *node
+	if raw, ok := body["name"]; ok {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil || strings.TrimSpace(name) == "" {
+			return errInvalid("name must be a non-empty string")
+		}
+		updated.Name = strings.TrimSpace(name)
+	}
+	if raw, ok := body["slug"]; ok {
+		var slug string
+		if err := json.Unmarshal(raw, &slug); err != nil || !slugPattern.MatchString(slug) {
+			return errInvalid("invalid slug %s", raw)
+		}
+		updated.Slug = slug
+	}
+	if raw, ok := body["type"]; ok {
+		var nodeType *string
+		if err := json.Unmarshal(raw, &nodeType); err != nil {
+			return errInvalid("type must be a string or null")
+		}
+		updated.Type = nodeType
+	}
+	parent := (*ndrclient.Node)(nil)
+	if node.ParentID != nil {
+		parent = s.nodes[*node.ParentID]
+	}
+	if raw, ok := body["parent_path"]; ok {
+		var parentPath *string
+		if err := json.Unmarshal(raw, &parentPath); err != nil {
+			return errInvalid("parent_path must be a string or null")
+		}
+		parent = nil
+		if parentPath != nil {
+			if parent, err = s.resolveParent(*parentPath); err != nil {
+				return err
+			}
+		}
+		if parent != nil && (parent.ID == id || strings.HasPrefix(parent.Path+"/", node.Path+"/")) {
+			return errStatus(http.StatusBadRequest, "cannot move node %d into its own subtree", id)
+		}
+	}
+	if parent != nil {
+		updated.ParentID = &parent.ID
+	} else {
+		updated.ParentID = nil
+	}
+	if parentKey(updated.ParentID) != parentKey(node.ParentID) {
+		updated.Position = 1
+		for _, sibling := range s.children(updated.ParentID, true) {
+			updated.Position = max(updated.Position, sibling.Position+1)
+		}
+	}
+	updated.Path = composePath(parent, updated.Slug)
+	if err := s.checkPathFree(updated.Path, id); err != nil {
+		return err
+	}
+
+	if updated.Path != node.Path {
+		for _, descendant := range s.subtree(id)[1:] {
+			child := s.nodes[descendant]
+			child.Path = updated.Path + strings.TrimPrefix(child.Path, node.Path)
+		}
+	}
+	updated.UpdatedBy = user
+	updated.UpdatedAt = now()
+	*node = updated
+	writeJSON(w, http.StatusOK, s.renderNode(node))
+	return nil
+}
+
+// deleteNode 软删除节点及其未删除的后代
+func (s *Server) deleteNode(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, err := s.liveNode(id); err != nil {
+		return err
+	}
+	ts := now()
+	for _, nodeID := range s.subtree(id) {
+		if node := s.nodes[nodeID]; node.DeletedAt == nil {
+			node.DeletedAt = &ts
+			node.UpdatedAt = ts
+			node.UpdatedBy = user
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// restoreNode 恢复节点以及与它同一次删除的后代
+func (s *Server) restoreNode(w http.ResponseWriter, r *http.Request, user string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	node, ok := s.nodes[id]
+	if !ok {
+		return errNotFound("node %d not found", id)
+	}
+	if node.DeletedAt != nil {
+		if node.ParentID != nil && s.nodes[*node.ParentID].DeletedAt != nil {
+			return errStatus(http.StatusConflict, "parent node %d is deleted", *node.ParentID)
+		}
+		if err := s.checkPathFree(node.Path, id); err != nil {
+			return err
+		}
+		deletedAt := *node.DeletedAt
+		ts := now()
+		for _, nodeID := range s.subtree(id) {
+			if child := s.nodes[nodeID]; child.DeletedAt != nil && child.DeletedAt.Equal(deletedAt) {
+				child.DeletedAt = nil
+				child.UpdatedAt = ts
+				child.UpdatedBy = user
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, s.renderNode(node))
+	return nil
+}
+
+// purgeNode 永久删除节点子树及其绑定关系，文档本身保留
+func (s *Server) purgeNode(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.nodes[id]; !ok {
+		return errNotFound("node %d not found", id)
+	}
+	for _, nodeID := range s.subtree(id) {
+		delete(s.nodes, nodeID)
+		delete(s.bindings, nodeID)
+		delete(s.sources, nodeID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listNodes(w http.ResponseWriter, r *http.Request, _ string) error {
+	includeDeleted := queryBool(r, "include_deleted", false)
+	items := make([]*ndrclient.Node, 0, len(s.nodes))
+	for _, id := range sortedKeys(s.nodes) {
+		if node := s.nodes[id]; includeDeleted || node.DeletedAt == nil {
+			items = append(items, node)
+		}
+	}
+	page, size := s.pageParams(r)
+	writeJSON(w, http.StatusOK, ndrclient.NodesPage{
+		Page:  page,
+		Size:  size,
+		Total: len(items),
+		Items: s.renderNodes(paginate(items, page, size)),
+	})
+	return nil
+}
+
+// listChildren 按 depth（默认 1）返回未删除的后代，先序排列
+func (s *Server) listChildren(w http.ResponseWriter, r *http.Request, _ string) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, err := s.liveNode(id); err != nil {
+		return err
+	}
+	depth, _ := strconv.Atoi(r.URL.Query().Get("depth"))
+	depth = max(depth, 1)
+
+	var items []*ndrclient.Node
+	var walk func(parentID int64, level int)
+	walk = func(parentID int64, level int) {
+		for _, child := range s.children(&parentID, false) {
+			items = append(items, child)
+			if level < depth {
+				walk(child.ID, level+1)
+			}
+		}
+	}
+	walk(id, 1)
+	writeJSON(w, http.StatusOK, s.renderNodes(items))
+	return nil
+}
+
+// reorderNodes 按 ordered_ids 重排同级节点，未列出的节点保持原顺序排在后面
+func (s *Server) reorderNodes(w http.ResponseWriter, r *http.Request, user string) error {
+	var body ndrclient.NodeReorderPayload
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	siblings := s.children(body.ParentID, false)
+	byID := make(map[int64]*ndrclient.Node, len(siblings))
+	for _, node := range siblings {
+		byID[node.ID] = node
+	}
+	ordered := make([]*ndrclient.Node, 0, len(siblings))
+	seen := make(map[int64]bool, len(body.OrderedIDs))
+	for _, id := range body.OrderedIDs {
+		node, ok := byID[id]
+		if !ok {
+			return errStatus(http.StatusBadRequest, "node %d is not a child of parent %d", id, parentKey(body.ParentID))
+		}
+		if seen[id] {
+			return errStatus(http.StatusBadRequest, "duplicate node id %d", id)
+		}
+		seen[id] = true
+		ordered = append(ordered, node)
+	}
+	for _, node := range siblings {
+		if !seen[node.ID] {
+			ordered = append(ordered, node)
+		}
+	}
+	ts := now()
+	for i, node := range ordered {
+		if node.Position != i+1 {
+			node.Position = i + 1
+			node.UpdatedAt = ts
+			node.UpdatedBy = user
+		}
+	}
+	writeJSON(w, http.StatusOK, s.renderNodes(ordered))
+	return nil
+}
+
+func (s *Server) bindingOf(nodeID, docID int64) (binding, bool) {
+	b, ok := s.bindings[nodeID][docID]
+	return b, ok
+}
+
+func (s *Server) bind(nodeID, docID int64, user string) (ndrclient.Relationship, error) {
+	if _, err := s.liveNode(nodeID); err != nil {
+		return ndrclient.Relationship{}, err
+	}
+	if _, err := s.liveDocument(docID); err != nil {
+		return ndrclient.Relationship{}, err
+	}
+	b, ok := s.bindingOf(nodeID, docID)
+	if !ok {
+		if s.bindings[nodeID] == nil {
+			s.bindings[nodeID] = make(map[int64]binding)
+		}
+		b = binding{CreatedBy: user, CreatedAt: now()}
+		s.bindings[nodeID][docID] = b
+	}
+	return ndrclient.Relationship{NodeID: nodeID, DocumentID: docID, CreatedBy: b.CreatedBy}, nil
+}
+
+func (s *Server) unbind(nodeID, docID int64) error {
+	if _, ok := s.bindingOf(nodeID, docID); !ok {
+		return errNotFound("document %d is not bound to node %d", docID, nodeID)
+	}
+	delete(s.bindings[nodeID], docID)
+	return nil
+}
+
+func (s *Server) bindDocument(w http.ResponseWriter, r *http.Request, user string) error {
+	nodeID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	docID, err := pathID(r, "doc")
+	if err != nil {
+		return err
+	}
+	rel, err := s.bind(nodeID, docID, user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, rel)
+	return nil
+}
+
+func (s *Server) unbindDocument(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	docID, err := pathID(r, "doc")
+	if err != nil {
+		return err
+	}
+	if err := s.unbind(nodeID, docID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func relationshipIDs(r *http.Request) (int64, int64, error) {
+	nodeID, err := queryID(r, "node_id")
+	if err != nil {
+		return 0, 0, err
+	}
+	docID, err := queryID(r, "document_id")
+	if err != nil {
+		return 0, 0, err
+	}
+	if nodeID == nil || docID == nil {
+		return 0, 0, errInvalid("node_id and document_id are required")
+	}
+	return *nodeID, *docID, nil
+}
+
+func (s *Server) createRelationship(w http.ResponseWriter, r *http.Request, user string) error {
+	nodeID, docID, err := relationshipIDs(r)
+	if err != nil {
+		return err
+	}
+	rel, err := s.bind(nodeID, docID, user)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, rel)
+	return nil
+}
+
+func (s *Server) deleteRelationship(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeID, docID, err := relationshipIDs(r)
+	if err != nil {
+		return err
+	}
+	if err := s.unbind(nodeID, docID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listRelationships(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeFilter, err := queryID(r, "node_id")
+	if err != nil {
+		return err
+	}
+	docFilter, err := queryID(r, "document_id")
+	if err != nil {
+		return err
+	}
+	rels := []ndrclient.Relationship{}
+	for _, nodeID := range sortedKeys(s.bindings) {
+		if nodeFilter != nil && *nodeFilter != nodeID {
+			continue
+		}
+		docs := s.bindings[nodeID]
+		for _, docID := range sortedKeys(docs) {
+			if docFilter != nil && *docFilter != docID {
+				continue
+			}
+			rels = append(rels, ndrclient.Relationship{NodeID: nodeID, DocumentID: docID, CreatedBy: docs[docID].CreatedBy})
+		}
+	}
+	writeJSON(w, http.StatusOK, rels)
+	return nil
+}
+
+func (s *Server) bindSource(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	docID, err := queryID(r, "document_id")
+	if err != nil {
+		return err
+	}
+	if docID == nil {
+		return errInvalid("document_id is required")
+	}
+	if _, err := s.liveNode(nodeID); err != nil {
+		return err
+	}
+	if _, err := s.liveDocument(*docID); err != nil {
+		return err
+	}
+	if s.sources[nodeID] == nil {
+		s.sources[nodeID] = make(map[int64]time.Time)
+	}
+	if _, ok := s.sources[nodeID][*docID]; !ok {
+		s.sources[nodeID][*docID] = now()
+	}
+	writeJSON(w, http.StatusCreated, ndrclient.SourceRelation{NodeID: nodeID, DocumentID: *docID, RelationType: "source"})
+	return nil
+}
+
+func (s *Server) unbindSource(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	docID, err := pathID(r, "doc")
+	if err != nil {
+		return err
+	}
+	if _, ok := s.sources[nodeID][docID]; !ok {
+		return errNotFound("document %d is not a source of node %d", docID, nodeID)
+	}
+	delete(s.sources[nodeID], docID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listSources(w http.ResponseWriter, r *http.Request, _ string) error {
+	nodeID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	if _, err := s.liveNode(nodeID); err != nil {
+		return err
+	}
+	items := []ndrclient.SourceDocument{}
+	for _, docID := range sortedKeys(s.sources[nodeID]) {
+		item := ndrclient.SourceDocument{NodeID: nodeID, DocumentID: docID, RelationType: "source"}
+		if doc, ok := s.documents[docID]; ok {
+			item.Document = &ndrclient.SourceDocInfo{ID: doc.ID, Title: doc.Title, Type: doc.Type}
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, items)
+	return nil
+}
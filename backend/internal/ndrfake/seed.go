@@ -0,0 +1,83 @@
+package ndrfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// Seed 初始数据：节点按顺序创建（父节点需在前），文档创建后绑定到 node_paths 指定的节点
+type Seed struct {
+	Nodes     []SeedNode     `json:"nodes"`
+	Documents []SeedDocument `json:"documents"`
+}
+
+// SeedNode 与 NDR 的创建节点请求一致
+type SeedNode struct {
+	Name       string  `json:"name"`
+	Slug       *string `json:"slug,omitempty"`
+	ParentPath *string `json:"parent_path,omitempty"`
+	Type       *string `json:"type,omitempty"`
+}
+
+// SeedDocument 文档及其绑定的节点路径（"/a/b" 或 "a.b"）
+type SeedDocument struct {
+	Title     string         `json:"title"`
+	Type      *string        `json:"type,omitempty"`
+	Content   map[string]any `json:"content,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	NodePaths []string       `json:"node_paths,omitempty"`
+}
+
+// LoadSeedFile 读取 JSON 格式的初始数据
+func LoadSeedFile(path string) (Seed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Seed{}, err
+	}
+	var seed Seed
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return Seed{}, fmt.Errorf("parse seed %s: %w", path, err)
+	}
+	return seed, nil
+}
+
+// Load 写入初始数据，创建者记为 system
+func (s *Server) Load(seed Seed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range seed.Nodes {
+		_, err := s.insertNode(ndrclient.NodeCreate{
+			Name:       item.Name,
+			Slug:       item.Slug,
+			ParentPath: item.ParentPath,
+			Type:       item.Type,
+		}, defaultUserID)
+		if err != nil {
+			return fmt.Errorf("seed node %q: %w", item.Name, err)
+		}
+	}
+	for _, item := range seed.Documents {
+		doc, err := s.insertDocument(ndrclient.DocumentCreate{
+			Title:    item.Title,
+			Type:     item.Type,
+			Content:  item.Content,
+			Metadata: item.Metadata,
+		}, defaultUserID)
+		if err != nil {
+			return fmt.Errorf("seed document %q: %w", item.Title, err)
+		}
+		for _, nodePath := range item.NodePaths {
+			node := s.nodeByPath(nodePath, false)
+			if node == nil {
+				return fmt.Errorf("seed document %q: node %s not found", item.Title, nodePath)
+			}
+			if _, err := s.bind(node.ID, doc.ID, defaultUserID); err != nil {
+				return fmt.Errorf("seed document %q: %w", item.Title, err)
+			}
+		}
+	}
+	return nil
+}
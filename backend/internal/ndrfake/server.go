@@ -0,0 +1,312 @@
+// Package ndrfake 提供内存版的 NDR HTTP 服务，覆盖 ndrclient 用到的全部接口
+// （节点与路径、文档、版本与差异、绑定、来源文档、资产分片上传），
+// 用于集成测试，也可通过 cmd/ndr-fake 单独运行，让本地开发不依赖真实 NDR。
+package ndrfake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+const (
+	defaultPageSize    = 20
+	defaultMaxPageSize = 100
+	defaultPartSize    = 5 << 20
+	defaultUserID      = "system"
+)
+
+// Options 配置假服务的行为
+type Options struct {
+	// APIKey 非空时要求请求携带相同的 x-api-key，否则返回 401
+	APIKey string
+	// MaxPageSize 单页最大条数，超出时按上限返回（与 NDR 一致），默认 100
+	MaxPageSize int
+	// PartSizeBytes 分片上传的分片大小，默认 5MB
+	PartSizeBytes int
+	// PublicURL 预签名 URL 使用的地址，为空时取请求的 Host
+	PublicURL string
+}
+
+// Server 内存版 NDR，可直接作为 http.Handler 使用，并发安全
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu          sync.Mutex
+	nextNodeID  int64
+	nextDocID   int64
+	nextAssetID int64
+	nodes       map[int64]*ndrclient.Node
+	documents   map[int64]*ndrclient.Document
+	versions    map[int64][]ndrclient.DocumentVersion
+	bindings    map[int64]map[int64]binding // node_id -> document_id
+	sources     map[int64]map[int64]time.Time
+	assets      map[int64]*asset
+}
+
+type binding struct {
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// New 创建空的假服务
+func New(opts Options) *Server {
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = defaultMaxPageSize
+	}
+	if opts.PartSizeBytes <= 0 {
+		opts.PartSizeBytes = defaultPartSize
+	}
+	s := &Server{
+		opts:      opts,
+		mux:       http.NewServeMux(),
+		nodes:     make(map[int64]*ndrclient.Node),
+		documents: make(map[int64]*ndrclient.Document),
+		versions:  make(map[int64][]ndrclient.DocumentVersion),
+		bindings:  make(map[int64]map[int64]binding),
+		sources:   make(map[int64]map[int64]time.Time),
+		assets:    make(map[int64]*asset),
+	}
+	s.routes()
+	return s
+}
+
+// NewTestServer 启动 httptest 服务并在测试结束时关闭，返回假服务与其地址
+func NewTestServer(tb testing.TB, opts Options) (*Server, string) {
+	tb.Helper()
+	s := New(opts)
+	srv := httptest.NewServer(s)
+	tb.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /ready", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	s.handle("POST /api/v1/nodes", s.createNode)
+	s.handle("GET /api/v1/nodes", s.listNodes)
+	s.handle("GET /api/v1/nodes/by-path", s.getNodeByPath)
+	s.handle("GET /api/v1/nodes/by-path/subtree-documents", s.listNodeDocumentsByPath)
+	s.handle("POST /api/v1/nodes/reorder", s.reorderNodes)
+	s.handle("GET /api/v1/nodes/{id}", s.getNode)
+	s.handle("PUT /api/v1/nodes/{id}", s.updateNode)
+	s.handle("DELETE /api/v1/nodes/{id}", s.deleteNode)
+	s.handle("POST /api/v1/nodes/{id}/restore", s.restoreNode)
+	s.handle("DELETE /api/v1/nodes/{id}/purge", s.purgeNode)
+	s.handle("GET /api/v1/nodes/{id}/children", s.listChildren)
+	s.handle("GET /api/v1/nodes/{id}/subtree-documents", s.listNodeDocuments)
+	s.handle("POST /api/v1/nodes/{id}/bind/{doc}", s.bindDocument)
+	s.handle("DELETE /api/v1/nodes/{id}/unbind/{doc}", s.unbindDocument)
+	s.handle("POST /api/v1/nodes/{id}/sources", s.bindSource)
+	s.handle("GET /api/v1/nodes/{id}/sources", s.listSources)
+	s.handle("DELETE /api/v1/nodes/{id}/sources/{doc}", s.unbindSource)
+
+	s.handle("GET /api/v1/documents", s.listDocuments)
+	s.handle("POST /api/v1/documents", s.createDocument)
+	s.handle("POST /api/v1/documents/reorder", s.reorderDocuments)
+	s.handle("GET /api/v1/documents/{id}", s.getDocument)
+	s.handle("PUT /api/v1/documents/{id}", s.updateDocument)
+	s.handle("DELETE /api/v1/documents/{id}", s.deleteDocument)
+	s.handle("POST /api/v1/documents/{id}/restore", s.restoreDocument)
+	s.handle("DELETE /api/v1/documents/{id}/purge", s.purgeDocument)
+	s.handle("GET /api/v1/documents/{id}/binding-status", s.bindingStatus)
+	s.handle("GET /api/v1/documents/{id}/bindings", s.documentBindings)
+	s.handle("GET /api/v1/documents/{id}/versions", s.listVersions)
+	s.handle("GET /api/v1/documents/{id}/versions/{version}", s.getVersion)
+	s.handle("GET /api/v1/documents/{id}/versions/{version}/diff", s.versionDiff)
+	s.handle("POST /api/v1/documents/{id}/versions/{version}/restore", s.restoreVersion)
+
+	s.handle("POST /api/v1/relationships", s.createRelationship)
+	s.handle("DELETE /api/v1/relationships", s.deleteRelationship)
+	s.handle("GET /api/v1/relationships", s.listRelationships)
+
+	s.handle("POST /api/v1/assets/multipart/init", s.initUpload)
+	s.handle("POST /api/v1/assets/{id}/multipart/part-urls", s.partURLs)
+	s.handle("POST /api/v1/assets/{id}/multipart/complete", s.completeUpload)
+	s.handle("POST /api/v1/assets/{id}/multipart/abort", s.abortUpload)
+	s.handle("GET /api/v1/assets/{id}", s.getAsset)
+	s.handle("GET /api/v1/assets/{id}/download-url", s.downloadURL)
+	s.handle("DELETE /api/v1/assets/{id}", s.deleteAsset)
+
+	// 预签名 URL 指向的对象存储接口，不校验 API Key，允许浏览器跨域直传
+	s.mux.HandleFunc("PUT /_storage/uploads/{upload}/{part}", withCORS(s.uploadPart))
+	s.mux.HandleFunc("GET /_storage/assets/{id}", withCORS(s.downloadObject))
+	s.mux.HandleFunc("OPTIONS /_storage/", withCORS(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handlerFunc 在持有锁的情况下处理请求，返回的 error 按 *httpError 转为 NDR 的错误响应
+type handlerFunc func(w http.ResponseWriter, r *http.Request, user string) error
+
+func (s *Server) handle(pattern string, fn handlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.APIKey != "" && r.Header.Get("x-api-key") != s.opts.APIKey {
+			writeError(w, errStatus(http.StatusUnauthorized, "invalid api key"))
+			return
+		}
+		user := r.Header.Get("x-user-id")
+		if user == "" {
+			user = defaultUserID
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := fn(w, r, user); err != nil {
+			writeError(w, err)
+		}
+	})
+}
+
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		next(w, r)
+	}
+}
+
+// httpError 以 {"detail": "..."} 形式返回，与 NDR 一致
+type httpError struct {
+	status int
+	detail string
+}
+
+func (e *httpError) Error() string { return e.detail }
+
+func errStatus(status int, format string, args ...any) error {
+	return &httpError{status: status, detail: fmt.Sprintf(format, args...)}
+}
+
+func errNotFound(format string, args ...any) error {
+	return errStatus(http.StatusNotFound, format, args...)
+}
+
+func errInvalid(format string, args ...any) error {
+	return errStatus(http.StatusUnprocessableEntity, format, args...)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var httpErr *httpError
+	if !errors.As(err, &httpErr) {
+		httpErr = &httpError{status: http.StatusInternalServerError, detail: err.Error()}
+	}
+	writeJSON(w, httpErr.status, map[string]string{"detail": httpErr.detail})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if payload != nil {
+		_ = json.NewEncoder(w).Encode(payload)
+	}
+}
+
+func decodeBody(r *http.Request, out any) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return errInvalid("request body is required")
+	}
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return errInvalid("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func pathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, errInvalid("invalid %s %q", name, r.PathValue(name))
+	}
+	return id, nil
+}
+
+func queryID(r *http.Request, name string) (*int64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, errInvalid("invalid %s %q", name, raw)
+	}
+	return &id, nil
+}
+
+func queryBool(r *http.Request, name string, fallback bool) bool {
+	switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get(name))) {
+	case "true", "1", "yes":
+		return true
+	case "false", "0", "no":
+		return false
+	}
+	return fallback
+}
+
+// pageParams 解析 page/size，size 超过 MaxPageSize 时截断
+func (s *Server) pageParams(r *http.Request) (page, size int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	size, _ = strconv.Atoi(r.URL.Query().Get("size"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	return page, min(size, s.opts.MaxPageSize)
+}
+
+func paginate[T any](items []T, page, size int) []T {
+	start := (page - 1) * size
+	if start >= len(items) {
+		return []T{}
+	}
+	return items[start:min(start+size, len(items))]
+}
+
+func sortedKeys[V any](m map[int64]V) []int64 {
+	keys := make([]int64, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// cloneMap 深拷贝 JSON 值，避免响应与存储共享 map
+func cloneMap(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return map[string]any{}
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil || out == nil {
+		return map[string]any{}
+	}
+	return out
+}
@@ -0,0 +1,325 @@
+package ndrfake_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
+)
+
+func newClient(t *testing.T, opts ndrfake.Options) (*ndrfake.Server, ndrclient.Client) {
+	t.Helper()
+	server, baseURL := ndrfake.NewTestServer(t, opts)
+	return server, ndrclient.NewClient(ndrclient.NDRConfig{BaseURL: baseURL, APIKey: opts.APIKey})
+}
+
+func strPtr(s string) *string { return &s }
+
+func expectStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var ndrErr *ndrclient.Error
+	if !errors.As(err, &ndrErr) || ndrErr.StatusCode != status {
+		t.Fatalf("expected status %d, got %v", status, err)
+	}
+	if ndrErr.Message == "" {
+		t.Fatalf("expected error detail in %v", err)
+	}
+}
+
+func TestNodesLifecycle(t *testing.T) {
+	_, client := newClient(t, ndrfake.Options{})
+	ctx := context.Background()
+	meta := ndrclient.RequestMeta{UserID: "alice"}
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	course, err := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Course", Slug: strPtr("course")})
+	if err != nil || course.Path != "/course" || course.CreatedBy != "alice" {
+		t.Fatalf("create root: %+v %v", course, err)
+	}
+	ch1, _ := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Chapter 1", Slug: strPtr("ch1"), ParentPath: &course.Path})
+	ch2, _ := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Chapter 2", Slug: strPtr("ch2"), ParentPath: &course.Path})
+	section, err := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Section", Slug: strPtr("s1"), ParentPath: &ch1.Path})
+	if err != nil || section.Path != "/course/ch1/s1" || *section.ParentID != ch1.ID {
+		t.Fatalf("create nested: %+v %v", section, err)
+	}
+	_, err = client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "dup", Slug: strPtr("ch1"), ParentPath: &course.Path})
+	expectStatus(t, err, http.StatusConflict)
+
+	// 按路径查询同时接受点分形式
+	if node, err := client.GetNodeByPath(ctx, meta, "course.ch1.s1", ndrclient.GetNodeOptions{}); err != nil || node.ID != section.ID {
+		t.Fatalf("get by path: %+v %v", node, err)
+	}
+	children, err := client.ListChildren(ctx, meta, course.ID, ndrclient.ListChildrenParams{Depth: 2})
+	if err != nil || len(children) != 3 || children[0].ID != ch1.ID || children[1].ID != section.ID {
+		t.Fatalf("list children depth 2: %+v %v", children, err)
+	}
+
+	// 移动节点时整个子树的路径随之更新
+	moved, err := client.UpdateNode(ctx, meta, ch1.ID, ndrclient.NodeUpdate{
+		Slug:       strPtr("intro"),
+		ParentPath: ndrclient.NewOptionalString(nil),
+	})
+	if err != nil || moved.Path != "/intro" || moved.ParentID != nil {
+		t.Fatalf("move to root: %+v %v", moved, err)
+	}
+	if node, _ := client.GetNode(ctx, meta, section.ID, ndrclient.GetNodeOptions{}); node.Path != "/intro/s1" {
+		t.Fatalf("descendant path not updated: %s", node.Path)
+	}
+	_, err = client.UpdateNode(ctx, meta, moved.ID, ndrclient.NodeUpdate{ParentPath: ndrclient.NewOptionalString(strPtr("/intro/s1"))})
+	expectStatus(t, err, http.StatusBadRequest)
+
+	ch3, _ := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Chapter 3", Slug: strPtr("ch3"), ParentPath: &course.Path})
+	ordered, err := client.ReorderNodes(ctx, meta, ndrclient.NodeReorderPayload{ParentID: &course.ID, OrderedIDs: []int64{ch3.ID}})
+	if err != nil || len(ordered) != 2 || ordered[0].ID != ch3.ID || ordered[1].ID != ch2.ID || ordered[1].Position != 2 {
+		t.Fatalf("reorder: %+v %v", ordered, err)
+	}
+
+	// 删除级联到后代，恢复时一并恢复
+	if err := client.DeleteNode(ctx, meta, moved.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_, err = client.GetNode(ctx, meta, section.ID, ndrclient.GetNodeOptions{})
+	expectStatus(t, err, http.StatusNotFound)
+	includeDeleted := true
+	if node, err := client.GetNode(ctx, meta, section.ID, ndrclient.GetNodeOptions{IncludeDeleted: &includeDeleted}); err != nil || node.DeletedAt == nil {
+		t.Fatalf("get deleted: %+v %v", node, err)
+	}
+	if _, err := client.RestoreNode(ctx, meta, moved.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := client.GetNode(ctx, meta, section.ID, ndrclient.GetNodeOptions{}); err != nil {
+		t.Fatalf("descendant should be restored: %v", err)
+	}
+	if err := client.PurgeNode(ctx, meta, moved.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	all, err := client.ListNodesAll(ctx, meta, ndrclient.ListNodesParams{IncludeDeleted: &includeDeleted})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 nodes after purge, got %d %v", len(all), err)
+	}
+}
+
+func TestDocumentsVersionsAndBindings(t *testing.T) {
+	_, client := newClient(t, ndrfake.Options{APIKey: "secret", MaxPageSize: 2})
+	ctx := context.Background()
+	meta := ndrclient.RequestMeta{}
+
+	course, _ := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Course", Slug: strPtr("course")})
+	chapter, _ := client.CreateNode(ctx, meta, ndrclient.NodeCreate{Name: "Chapter", Slug: strPtr("chapter"), ParentPath: &course.Path})
+	var docs []ndrclient.Document
+	for i, title := range []string{"Intro", "Lesson", "Quiz", "Appendix", "Notes"} {
+		doc, err := client.CreateDocument(ctx, meta, ndrclient.DocumentCreate{
+			Title:    title,
+			Type:     strPtr("markdown_v1"),
+			Content:  map[string]any{"format": "markdown", "data": "# " + title},
+			Metadata: map[string]any{"level": float64(i % 2)},
+		})
+		if err != nil || doc.Version == nil || *doc.Version != 1 {
+			t.Fatalf("create document: %+v %v", doc, err)
+		}
+		nodeID := chapter.ID
+		if i == 0 {
+			nodeID = course.ID
+		}
+		if err := client.BindDocument(ctx, meta, nodeID, doc.ID); err != nil {
+			t.Fatalf("bind: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	// 单页上限为 2，自动分页需要拉取多页
+	subtree, err := client.ListNodeDocumentsAll(ctx, meta, course.ID, url.Values{})
+	if err != nil || len(subtree) != 5 {
+		t.Fatalf("expected 5 subtree documents, got %d %v", len(subtree), err)
+	}
+	direct, err := client.ListNodeDocumentsAll(ctx, meta, course.ID, url.Values{"include_descendants": {"false"}})
+	if err != nil || len(direct) != 1 || direct[0].ID != docs[0].ID {
+		t.Fatalf("direct documents: %+v %v", direct, err)
+	}
+	filtered, err := client.ListDocumentsAll(ctx, meta, url.Values{"metadata.level": {"1"}})
+	if err != nil || len(filtered) != 2 {
+		t.Fatalf("metadata filter: %+v %v", filtered, err)
+	}
+	byPath, err := client.ListNodeDocumentsByPath(ctx, meta, "course.chapter", url.Values{"query": {"quiz"}})
+	if err != nil || byPath.Total != 1 || byPath.Items[0].Title != "Quiz" {
+		t.Fatalf("list by path: %+v %v", byPath, err)
+	}
+	if status, err := client.GetDocumentBindingStatus(ctx, meta, docs[1].ID); err != nil || status.TotalBindings != 1 || status.NodeIDs[0] != chapter.ID {
+		t.Fatalf("binding status: %+v %v", status, err)
+	}
+	if node, _ := client.GetNode(ctx, meta, course.ID, ndrclient.GetNodeOptions{}); node.SubtreeDocCount != 5 {
+		t.Fatalf("expected subtree_doc_count 5, got %d", node.SubtreeDocCount)
+	}
+
+	title := "Quiz v2"
+	updated, err := client.UpdateDocument(ctx, meta, docs[2].ID, ndrclient.DocumentUpdate{
+		Title:   &title,
+		Content: map[string]any{"format": "markdown", "data": "# Quiz v2"},
+	})
+	if err != nil || *updated.Version != 2 {
+		t.Fatalf("update: %+v %v", updated, err)
+	}
+	// If-Match 与当前版本不一致时拒绝写入
+	stale := 1
+	_, err = client.UpdateDocument(ctx, meta, docs[2].ID, ndrclient.DocumentUpdate{Title: strPtr("stale"), BaseVersion: &stale})
+	expectStatus(t, err, http.StatusPreconditionFailed)
+	diff, err := client.GetDocumentVersionDiff(ctx, meta, docs[2].ID, 1, 2)
+	if err != nil || diff.TitleDiff == nil || diff.TitleDiff.New != "Quiz v2" || diff.ContentDiff["data"] == nil || diff.ContentDiff["format"] != nil {
+		t.Fatalf("diff: %+v %v", diff, err)
+	}
+	restored, err := client.RestoreDocumentVersion(ctx, meta, docs[2].ID, 1)
+	if err != nil || restored.Title != "Quiz" || *restored.Version != 3 {
+		t.Fatalf("restore version: %+v %v", restored, err)
+	}
+	versions, err := client.ListDocumentVersions(ctx, meta, docs[2].ID, 1, 10)
+	if err != nil || versions.Total != 3 || versions.Versions[0].VersionNumber != 3 || versions.Versions[0].ChangeMessage == nil {
+		t.Fatalf("versions: %+v %v", versions, err)
+	}
+
+	if _, err := client.BindSourceDocument(ctx, meta, chapter.ID, docs[0].ID); err != nil {
+		t.Fatalf("bind source: %v", err)
+	}
+	sources, err := client.ListSourceDocuments(ctx, meta, chapter.ID)
+	if err != nil || len(sources) != 1 || sources[0].Document.Title != "Intro" {
+		t.Fatalf("sources: %+v %v", sources, err)
+	}
+
+	if err := client.DeleteDocument(ctx, meta, docs[3].ID); err != nil {
+		t.Fatalf("delete document: %v", err)
+	}
+	_, err = client.GetDocument(ctx, meta, docs[3].ID)
+	expectStatus(t, err, http.StatusNotFound)
+	if err := client.PurgeDocument(ctx, meta, docs[3].ID); err != nil {
+		t.Fatalf("purge document: %v", err)
+	}
+	rels, err := client.ListRelationships(ctx, meta, &chapter.ID, nil)
+	if err != nil || len(rels) != 3 {
+		t.Fatalf("purged document should be unbound, got %+v %v", rels, err)
+	}
+
+	_, err = client.GetDocument(ctx, ndrclient.RequestMeta{APIKey: "nope"}, docs[0].ID)
+	expectStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestDocumentMetadataMergePatch(t *testing.T) {
+	_, client := newClient(t, ndrfake.Options{})
+	ctx := context.Background()
+	meta := ndrclient.RequestMeta{}
+
+	doc, err := client.CreateDocument(ctx, meta, ndrclient.DocumentCreate{
+		Title:   "Lesson",
+		Type:    strPtr("markdown_v1"),
+		Content: map[string]any{"format": "markdown", "data": "# Lesson"},
+		Metadata: map[string]any{
+			"difficulty": "easy",
+			"references": []any{map[string]any{"document_id": float64(7)}},
+			"source":     map[string]any{"system": "legacy", "id": "a-1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create document: %v", err)
+	}
+
+	// null 删除键，嵌套对象逐层合并，未出现的键保持不变
+	updated, err := client.UpdateDocument(ctx, meta, doc.ID, ndrclient.DocumentUpdate{
+		Metadata: map[string]any{
+			"references": nil,
+			"source":     map[string]any{"id": "b-2", "system": nil},
+			"tags":       []any{"x"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	want := map[string]any{
+		"difficulty": "easy",
+		"source":     map[string]any{"id": "b-2"},
+		"tags":       []any{"x"},
+	}
+	if !reflect.DeepEqual(updated.Metadata, want) || *updated.Version != 2 {
+		t.Fatalf("unexpected merged metadata: %+v (version %d)", updated.Metadata, *updated.Version)
+	}
+
+	// 合并结果不变时不产生新版本
+	same, err := client.UpdateDocument(ctx, meta, doc.ID, ndrclient.DocumentUpdate{Metadata: map[string]any{"difficulty": "easy"}})
+	if err != nil || *same.Version != 2 {
+		t.Fatalf("no-op metadata update: %+v %v", same, err)
+	}
+}
+
+func TestAssetMultipartUpload(t *testing.T) {
+	_, client := newClient(t, ndrfake.Options{PartSizeBytes: 4})
+	ctx := context.Background()
+	meta := ndrclient.RequestMeta{}
+	data := []byte("hello, ndr-fake")
+
+	session, err := client.InitMultipartUpload(ctx, meta, ndrclient.AssetInitRequest{Filename: "a.txt", ContentType: "text/plain", SizeBytes: int64(len(data))})
+	if err != nil || session.PartSizeBytes != 4 || session.Asset.Status != "uploading" {
+		t.Fatalf("init: %+v %v", session, err)
+	}
+	numbers := []int{1, 2, 3, 4}
+	urls, err := client.GetAssetPartURLs(ctx, meta, session.Asset.ID, numbers)
+	if err != nil || len(urls.URLs) != len(numbers) {
+		t.Fatalf("part urls: %+v %v", urls, err)
+	}
+	var parts []ndrclient.AssetCompletedPart
+	for _, part := range urls.URLs {
+		start := (part.PartNumber - 1) * session.PartSizeBytes
+		chunk := data[start:min(start+session.PartSizeBytes, len(data))]
+		req, _ := http.NewRequest(http.MethodPut, part.URL, bytes.NewReader(chunk))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload part %d: %v", part.PartNumber, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, ndrclient.AssetCompletedPart{PartNumber: part.PartNumber, ETag: resp.Header.Get("ETag")})
+	}
+	asset, err := client.CompleteMultipartUpload(ctx, meta, session.Asset.ID, parts)
+	if err != nil || asset.Status != "ready" || asset.SizeBytes != int64(len(data)) {
+		t.Fatalf("complete: %+v %v", asset, err)
+	}
+
+	link, err := client.GetAssetDownloadURL(ctx, meta, asset.ID)
+	if err != nil {
+		t.Fatalf("download url: %v", err)
+	}
+	resp, err := http.Get(link.URL)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(body, data) || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected content %q (%s)", body, resp.Header.Get("Content-Type"))
+	}
+
+	if err := client.DeleteAsset(ctx, meta, asset.ID); err != nil {
+		t.Fatalf("delete asset: %v", err)
+	}
+	_, err = client.GetAsset(ctx, meta, asset.ID)
+	expectStatus(t, err, http.StatusNotFound)
+}
+
+func TestLoadSeedFile(t *testing.T) {
+	seed, err := ndrfake.LoadSeedFile("../../cmd/ndr-fake/seed.example.json")
+	if err != nil {
+		t.Fatalf("load seed: %v", err)
+	}
+	server, client := newClient(t, ndrfake.Options{})
+	if err := server.Load(seed); err != nil {
+		t.Fatalf("apply seed: %v", err)
+	}
+	page, err := client.ListNodeDocumentsByPath(context.Background(), ndrclient.RequestMeta{}, "demo-course", nil)
+	if err != nil || page.Total != len(seed.Documents) {
+		t.Fatalf("expected seeded documents, got %+v %v", page, err)
+	}
+}
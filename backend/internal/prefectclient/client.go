@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +13,9 @@ import (
 	"time"
 )
 
+// ErrFlowRunNotFound is returned when Prefect no longer knows the flow run (e.g. it was deleted).
+var ErrFlowRunNotFound = errors.New("prefect flow run not found")
+
 // Client is a Prefect API client.
 type Client struct {
 	baseURL    string
@@ -56,6 +60,8 @@ type FlowRunResponse struct {
 	Name      string         `json:"name"`
 	State     *StateResponse `json:"state,omitempty"`
 	StateType string         `json:"state_type,omitempty"`
+	StartTime *time.Time     `json:"start_time,omitempty"`
+	EndTime   *time.Time     `json:"end_time,omitempty"`
 }
 
 // StateResponse represents the state of a flow run.
@@ -190,6 +196,9 @@ func (c *Client) GetFlowRun(ctx context.Context, flowRunID string) (*FlowRunResp
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrFlowRunNotFound, flowRunID)
+	}
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("get flow run failed: status %d, body: %s", resp.StatusCode, string(bodyBytes))
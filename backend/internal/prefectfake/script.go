@@ -0,0 +1,194 @@
+package prefectfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+)
+
+// Script 描述 flow run 的模拟执行过程：
+// SCHEDULED --StartDelay--> RUNNING --RunDuration--> Outcome。
+// 参数包含 event_id 的视为同步 flow（只在结束时回调 /api/v1/sync/callback），
+// 其余带 callback_url 的视为工作流（RUNNING 时回调 running，结束时回调 success/failed）
+type Script struct {
+	StartDelay  time.Duration
+	RunDuration time.Duration
+	// Outcome 结束状态：COMPLETED（默认）、FAILED 或 CRASHED
+	Outcome string
+	// ErrorMessage 失败时回调中的错误信息
+	ErrorMessage string
+	// Result 工作流成功回调中的 result
+	Result map[string]any
+	// AffectedTables 同步回调中的 affected_tables
+	AffectedTables []AffectedTable
+	// DropCallbacks 只推进 flow run 状态、不发送回调，用于验证对账逻辑
+	DropCallbacks bool
+	// CreateStatus 非零时 create_flow_run 直接返回该状态码（如 503 触发客户端重试）
+	CreateStatus int
+}
+
+// AffectedTable 与 SyncCallbackRequest.affected_tables 的元素一致
+type AffectedTable struct {
+	Table        string `json:"table"`
+	AffectedRows int64  `json:"affected_rows"`
+	Operation    string `json:"operation"`
+}
+
+// Callback 已发送的回调；StatusCode 为 0 表示请求未送达
+type Callback struct {
+	FlowRunID  string          `json:"flow_run_id"`
+	URL        string          `json:"url"`
+	Body       json.RawMessage `json:"body"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Callbacks 按发送顺序返回全部回调
+func (s *Server) Callbacks() []Callback {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Callback(nil), s.callbacks...)
+}
+
+func (script Script) outcome() string {
+	switch script.Outcome {
+	case StateFailed, StateCrashed:
+		return script.Outcome
+	}
+	return StateCompleted
+}
+
+func (script Script) errorMessage() string {
+	if script.ErrorMessage != "" {
+		return script.ErrorMessage
+	}
+	return "scripted flow run failure"
+}
+
+// execute 按脚本推进 flow run；被取消（set_state 或 Close）后立即退出，不再回调
+func (s *Server) execute(run *flowRun, script Script) {
+	defer s.wg.Done()
+
+	if !pause(run, script.StartDelay) {
+		return
+	}
+	s.mu.Lock()
+	started := s.transition(run, StateRunning, "")
+	params := run.snapshot().Parameters
+	s.mu.Unlock()
+	if !started {
+		return
+	}
+
+	callbackURL, _ := params["callback_url"].(string)
+	_, isSync := params["event_id"]
+	notify := callbackURL != "" && !script.DropCallbacks
+	if notify && !isSync {
+		s.deliver(run.ID, callbackURL, map[string]any{"status": "running"})
+	}
+
+	if !pause(run, script.RunDuration) {
+		return
+	}
+	final := script.outcome()
+	message := ""
+	if final != StateCompleted {
+		message = script.errorMessage()
+	}
+	s.mu.Lock()
+	finished := s.transition(run, final, message)
+	s.mu.Unlock()
+	if !finished || !notify {
+		return
+	}
+
+	if isSync {
+		status := "success"
+		if final != StateCompleted {
+			status = "failed"
+		}
+		s.deliver(run.ID, callbackURL, map[string]any{
+			"event_id":        params["event_id"],
+			"doc_id":          params["doc_id"],
+			"doc_version":     params["doc_version"],
+			"status":          status,
+			"error":           message,
+			"affected_tables": script.AffectedTables,
+			"run_id":          run.ID,
+		})
+		return
+	}
+	if final == StateCompleted {
+		s.deliver(run.ID, callbackURL, map[string]any{"status": "success", "result": script.Result})
+		return
+	}
+	s.deliver(run.ID, callbackURL, map[string]any{"status": "failed", "error_message": message})
+}
+
+// pause 等待 d，期间 flow run 被终止则返回 false
+func pause(run *flowRun, d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-run.stop:
+			return false
+		default:
+			return true
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-run.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// deliver 发送回调并记录结果，配置了 WebhookSecret 时附带签名头
+func (s *Server) deliver(flowRunID, url string, payload map[string]any) {
+	record := Callback{FlowRunID: flowRunID, URL: url}
+	body, err := json.Marshal(payload)
+	record.Body = body
+	if err == nil {
+		record.StatusCode, err = s.post(url, body)
+	}
+	if err != nil {
+		record.Error = err.Error()
+		log.Printf("[prefect-fake] callback %s for flow run %s failed: %v", url, flowRunID, err)
+	}
+	s.mu.Lock()
+	s.callbacks = append(s.callbacks, record)
+	s.mu.Unlock()
+}
+
+func (s *Server) post(url string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.opts.WebhookSecret != "" {
+		ts := time.Now().Unix()
+		nonce := uuid.NewString()
+		req.Header.Set(auth.WebhookTimestampHeader, fmt.Sprint(ts))
+		req.Header.Set(auth.WebhookNonceHeader, nonce)
+		req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(s.opts.WebhookSecret, ts, nonce, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,443 @@
+// Package prefectfake 提供内存版的 Prefect HTTP 服务，覆盖 prefectclient 用到的接口
+// （deployment 查询与过滤、flow run 创建/查询/取消、健康检查），并按脚本模拟 flow 执行，
+// 向 callback_url 发送带签名的工作流回调与同步回调。可通过 cmd/prefect-fake 单独运行。
+package prefectfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+)
+
+// Prefect flow run 状态类型
+const (
+	StateScheduled  = "SCHEDULED"
+	StateRunning    = "RUNNING"
+	StateCompleted  = "COMPLETED"
+	StateFailed     = "FAILED"
+	StateCrashed    = "CRASHED"
+	StateCancelling = "CANCELLING"
+	StateCancelled  = "CANCELLED"
+)
+
+// Options 配置假服务的行为
+type Options struct {
+	// WebhookSecret 非空时回调携带 X-Webhook-* 签名头（与 YDMS_PREFECT_WEBHOOK_SECRET 一致）
+	WebhookSecret string
+	// HTTPClient 发送回调使用的客户端，默认 10 秒超时
+	HTTPClient *http.Client
+	// DefaultScript 未单独配置脚本的 deployment 使用的执行脚本
+	DefaultScript Script
+}
+
+// FlowRun flow run 的快照，包含创建时的 deployment 与参数
+type FlowRun struct {
+	prefectclient.FlowRunResponse
+	DeploymentID string         `json:"deployment_id"`
+	Parameters   map[string]any `json:"parameters"`
+}
+
+// Server 内存版 Prefect，可直接作为 http.Handler 使用，并发安全
+type Server struct {
+	opts   Options
+	mux    *http.ServeMux
+	client *http.Client
+
+	mu          sync.Mutex
+	deployments map[string]*prefectclient.DeploymentDetails
+	scripts     map[string]Script // deployment name -> script
+	runs        map[string]*flowRun
+	runOrder    []string
+	callbacks   []Callback
+	nextRun     int
+
+	wg sync.WaitGroup
+}
+
+type flowRun struct {
+	FlowRun
+	stop chan struct{}
+}
+
+// New 创建没有 deployment 的假服务
+func New(opts Options) *Server {
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	s := &Server{
+		opts:        opts,
+		mux:         http.NewServeMux(),
+		client:      client,
+		deployments: make(map[string]*prefectclient.DeploymentDetails),
+		scripts:     make(map[string]Script),
+		runs:        make(map[string]*flowRun),
+	}
+	s.routes()
+	return s
+}
+
+// NewTestServer 启动 httptest 服务并在测试结束时停止脚本、关闭服务，返回假服务与其地址
+func NewTestServer(tb testing.TB, opts Options) (*Server, string) {
+	tb.Helper()
+	s := New(opts)
+	srv := httptest.NewServer(s)
+	tb.Cleanup(func() {
+		s.Close()
+		srv.Close()
+	})
+	return s, srv.URL
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, true)
+	})
+	s.mux.HandleFunc("POST /api/deployments/filter", s.filterDeployments)
+	s.mux.HandleFunc("GET /api/deployments/{id}", s.getDeployment)
+	s.mux.HandleFunc("POST /api/deployments/{id}/create_flow_run", s.createFlowRun)
+	s.mux.HandleFunc("GET /api/flow_runs/{id}", s.getFlowRun)
+	s.mux.HandleFunc("POST /api/flow_runs/{id}/set_state", s.setFlowRunState)
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// AddDeployment 注册 deployment，ID 与 FlowID 为空时自动生成，同名 deployment 会被替换
+func (s *Server) AddDeployment(dep prefectclient.DeploymentDetails) prefectclient.DeploymentDetails {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, existing := range s.deployments {
+		if existing.Name == dep.Name {
+			delete(s.deployments, id)
+		}
+	}
+	if dep.ID == "" {
+		dep.ID = uuid.NewString()
+	}
+	if dep.FlowID == "" {
+		dep.FlowID = uuid.NewSHA1(uuid.NameSpaceURL, []byte("prefect-fake/flow/"+dep.Name)).String()
+	}
+	ts := now().Format(time.RFC3339Nano)
+	if dep.CreatedAt == "" {
+		dep.CreatedAt = ts
+	}
+	dep.UpdatedAt = ts
+	s.deployments[dep.ID] = &dep
+	return dep
+}
+
+// SetScript 为指定名称的 deployment 配置执行脚本，之后创建的 flow run 生效
+func (s *Server) SetScript(deploymentName string, script Script) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[deploymentName] = script
+}
+
+// FlowRun 返回 flow run 的当前快照
+func (s *Server) FlowRun(id string) (FlowRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return FlowRun{}, false
+	}
+	return run.snapshot(), true
+}
+
+// FlowRuns 按创建顺序返回全部 flow run
+func (s *Server) FlowRuns() []FlowRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]FlowRun, 0, len(s.runOrder))
+	for _, id := range s.runOrder {
+		out = append(out, s.runs[id].snapshot())
+	}
+	return out
+}
+
+// Wait 等待所有进行中的脚本执行完毕（含回调发送）
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// Close 取消尚未结束的脚本（不再发送回调）并等待其退出
+func (s *Server) Close() {
+	s.mu.Lock()
+	for _, run := range s.runs {
+		if !isTerminal(run.StateType) {
+			s.transition(run, StateCancelled, "server closed")
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// filterDeployments 支持 deployments.name.any_ 与 deployments.tags.any_（prefectclient 使用的两种过滤）
+func (s *Server) filterDeployments(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Deployments struct {
+			Name *anyFilter `json:"name"`
+			Tags *anyFilter `json:"tags"`
+		} `json:"deployments"`
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDetail(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	matched := make([]prefectclient.DeploymentDetails, 0, len(s.deployments))
+	for _, dep := range s.deployments {
+		if body.Deployments.Name.matches(dep.Name) && body.Deployments.Tags.matchesAny(dep.Tags) {
+			matched = append(matched, *dep)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+	start := min(max(body.Offset, 0), len(matched))
+	end := len(matched)
+	if body.Limit > 0 {
+		end = min(start+body.Limit, end)
+	}
+	writeJSON(w, http.StatusOK, matched[start:end])
+}
+
+func (s *Server) getDeployment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	dep, ok := s.deployments[r.PathValue("id")]
+	var out prefectclient.DeploymentDetails
+	if ok {
+		out = *dep
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "Deployment not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// createFlowRun 合并 deployment 默认参数后创建 SCHEDULED 状态的 flow run，并启动脚本
+func (s *Server) createFlowRun(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Parameters map[string]any `json:"parameters"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDetail(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dep, ok := s.deployments[r.PathValue("id")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "Deployment not found.")
+		return
+	}
+	script := s.scriptFor(dep.Name)
+	if script.CreateStatus != 0 {
+		writeDetail(w, script.CreateStatus, fmt.Sprintf("scripted create_flow_run failure for %s", dep.Name))
+		return
+	}
+
+	params := make(map[string]any, len(dep.Parameters)+len(body.Parameters))
+	for k, v := range dep.Parameters {
+		params[k] = v
+	}
+	for k, v := range body.Parameters {
+		params[k] = v
+	}
+	s.nextRun++
+	run := &flowRun{
+		FlowRun: FlowRun{
+			FlowRunResponse: prefectclient.FlowRunResponse{
+				ID:   uuid.NewString(),
+				Name: fmt.Sprintf("%s-run-%d", dep.Name, s.nextRun),
+			},
+			DeploymentID: dep.ID,
+			Parameters:   params,
+		},
+		stop: make(chan struct{}),
+	}
+	s.setState(run, StateScheduled, "")
+	s.runs[run.ID] = run
+	s.runOrder = append(s.runOrder, run.ID)
+
+	s.wg.Add(1)
+	go s.execute(run, script)
+
+	writeJSON(w, http.StatusCreated, run.snapshot().FlowRunResponse)
+}
+
+func (s *Server) getFlowRun(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	var out prefectclient.FlowRunResponse
+	if ok {
+		out = run.snapshot().FlowRunResponse
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "Flow run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// setFlowRunState CANCELLING 会直接落到 CANCELLED 并停止脚本；终态的 flow run 返回 409
+func (s *Server) setFlowRunState(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		State struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.State.Type == "" {
+		writeDetail(w, http.StatusUnprocessableEntity, "state.type is required")
+		return
+	}
+	stateType := strings.ToUpper(body.State.Type)
+	if stateType == StateCancelling {
+		stateType = StateCancelled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[r.PathValue("id")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "Flow run not found")
+		return
+	}
+	if !s.transition(run, stateType, body.State.Message) {
+		writeDetail(w, http.StatusConflict, fmt.Sprintf("flow run is already %s", run.StateType))
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"status": "ACCEPT",
+		"state":  run.State,
+	})
+}
+
+func (s *Server) scriptFor(deploymentName string) Script {
+	if script, ok := s.scripts[deploymentName]; ok {
+		return script
+	}
+	return s.opts.DefaultScript
+}
+
+// transition 在持有锁时切换状态；已处于终态时返回 false，进入终态时停止脚本
+func (s *Server) transition(run *flowRun, stateType, message string) bool {
+	if isTerminal(run.StateType) {
+		return false
+	}
+	s.setState(run, stateType, message)
+	if isTerminal(stateType) {
+		close(run.stop)
+	}
+	return true
+}
+
+func (s *Server) setState(run *flowRun, stateType, message string) {
+	ts := now()
+	run.StateType = stateType
+	run.State = &prefectclient.StateResponse{Type: stateType, Name: stateName(stateType), Message: message}
+	if stateType == StateRunning && run.StartTime == nil {
+		run.StartTime = &ts
+	}
+	if isTerminal(stateType) {
+		run.EndTime = &ts
+	}
+}
+
+func (r *flowRun) snapshot() FlowRun {
+	out := r.FlowRun
+	if r.State != nil {
+		state := *r.State
+		out.State = &state
+	}
+	out.Parameters = make(map[string]any, len(r.Parameters))
+	for k, v := range r.Parameters {
+		out.Parameters[k] = v
+	}
+	return out
+}
+
+func isTerminal(stateType string) bool {
+	switch stateType {
+	case StateCompleted, StateFailed, StateCrashed, StateCancelled:
+		return true
+	}
+	return false
+}
+
+// stateName 与 Prefect 一致：RUNNING -> Running
+func stateName(stateType string) string {
+	if stateType == "" {
+		return ""
+	}
+	return stateType[:1] + strings.ToLower(stateType[1:])
+}
+
+// anyFilter 对应 Prefect 过滤条件中的 {"any_": [...]}
+type anyFilter struct {
+	Any []string `json:"any_"`
+}
+
+func (f *anyFilter) matches(value string) bool {
+	if f == nil || f.Any == nil {
+		return true
+	}
+	for _, candidate := range f.Any {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *anyFilter) matchesAny(values []string) bool {
+	if f == nil || f.Any == nil {
+		return true
+	}
+	for _, value := range values {
+		if f.matches(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDetail 以 {"detail": "..."} 返回错误，与 Prefect 一致
+func writeDetail(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]string{"detail": detail})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
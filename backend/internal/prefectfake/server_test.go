@@ -0,0 +1,202 @@
+package prefectfake_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+	"github.com/yjxt/ydms/backend/internal/prefectfake"
+)
+
+// callbackSink 校验签名后记录收到的回调体
+type callbackSink struct {
+	mu     sync.Mutex
+	bodies map[string][]map[string]any // path -> bodies
+}
+
+func newCallbackSink(t *testing.T, secret string) (*callbackSink, string) {
+	t.Helper()
+	sink := &callbackSink{bodies: make(map[string][]map[string]any)}
+	verifier := auth.NewWebhookVerifier(time.Minute, secret)
+	srv := httptest.NewServer(auth.WebhookMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sink.mu.Lock()
+		sink.bodies[r.URL.Path] = append(sink.bodies[r.URL.Path], body)
+		sink.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})))
+	t.Cleanup(srv.Close)
+	return sink, srv.URL
+}
+
+func (s *callbackSink) received(path string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[path]
+}
+
+func TestDeploymentLookupAndFilter(t *testing.T) {
+	fake, baseURL := prefectfake.NewTestServer(t, prefectfake.Options{})
+	outline := fake.AddDeployment(prefectclient.DeploymentDetails{
+		Name:        "outline-deployment",
+		Description: "生成大纲",
+		Tags:        []string{"pdms:type=node", "pdms:key=outline"},
+	})
+	fake.AddDeployment(prefectclient.DeploymentDetails{Name: "sync_to_mysql-deployment"})
+
+	client := prefectclient.NewClient(baseURL, 5*time.Second)
+	ctx := context.Background()
+
+	if err := client.HealthCheck(ctx); err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+
+	found, err := client.GetDeploymentByName(ctx, "outline", "outline-deployment")
+	if err != nil || found.ID != outline.ID {
+		t.Fatalf("GetDeploymentByName = %+v, %v; want id %s", found, err, outline.ID)
+	}
+	if _, err := client.GetDeploymentByName(ctx, "missing", "missing-deployment"); err == nil {
+		t.Fatalf("expected error for unknown deployment")
+	}
+
+	all, err := client.ListDeployments(ctx, nil)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ListDeployments(nil) = %d deployments, %v; want 2", len(all), err)
+	}
+	tagged, err := client.ListDeployments(ctx, []string{"pdms:type=node"})
+	if err != nil || len(tagged) != 1 || tagged[0].Name != "outline-deployment" {
+		t.Fatalf("ListDeployments(tag) = %+v, %v", tagged, err)
+	}
+
+	details, err := client.GetDeployment(ctx, outline.ID)
+	if err != nil || details.Description != "生成大纲" || details.FlowID == "" {
+		t.Fatalf("GetDeployment = %+v, %v", details, err)
+	}
+}
+
+func TestWorkflowAndSyncCallbacks(t *testing.T) {
+	sink, sinkURL := newCallbackSink(t, "secret")
+	fake, baseURL := prefectfake.NewTestServer(t, prefectfake.Options{WebhookSecret: "secret"})
+	workflow := fake.AddDeployment(prefectclient.DeploymentDetails{Name: "outline-deployment"})
+	syncDep := fake.AddDeployment(prefectclient.DeploymentDetails{Name: "sync_to_mysql-deployment"})
+	fake.SetScript("outline-deployment", prefectfake.Script{Result: map[string]any{"documents": float64(2)}})
+	fake.SetScript("sync_to_mysql-deployment", prefectfake.Script{
+		Outcome:        prefectfake.StateFailed,
+		ErrorMessage:   "table locked",
+		AffectedTables: []prefectfake.AffectedTable{{Table: "questions", AffectedRows: 0, Operation: "update"}},
+	})
+
+	client := prefectclient.NewClient(baseURL, 5*time.Second)
+	ctx := context.Background()
+
+	wfRun, err := client.CreateFlowRun(ctx, workflow.ID, map[string]interface{}{
+		"run_id":       7,
+		"callback_url": sinkURL + "/api/v1/workflows/callback/7",
+	})
+	if err != nil {
+		t.Fatalf("create workflow flow run: %v", err)
+	}
+	if wfRun.StateType != prefectfake.StateScheduled {
+		t.Fatalf("new flow run state = %s, want SCHEDULED", wfRun.StateType)
+	}
+	syncRun, err := client.CreateFlowRun(ctx, syncDep.ID, map[string]interface{}{
+		"event_id":     "evt-1",
+		"doc_id":       42,
+		"doc_version":  3,
+		"callback_url": sinkURL + "/api/v1/sync/callback",
+	})
+	if err != nil {
+		t.Fatalf("create sync flow run: %v", err)
+	}
+	fake.Wait()
+
+	got, err := client.GetFlowRun(ctx, wfRun.ID)
+	if err != nil || got.StateType != prefectfake.StateCompleted || got.StartTime == nil || got.EndTime == nil {
+		t.Fatalf("workflow flow run = %+v, %v; want COMPLETED with times", got, err)
+	}
+	got, err = client.GetFlowRun(ctx, syncRun.ID)
+	if err != nil || got.StateType != prefectfake.StateFailed || got.State.Message != "table locked" {
+		t.Fatalf("sync flow run = %+v, %v; want FAILED", got, err)
+	}
+
+	wfBodies := sink.received("/api/v1/workflows/callback/7")
+	if len(wfBodies) != 2 || wfBodies[0]["status"] != "running" || wfBodies[1]["status"] != "success" {
+		t.Fatalf("workflow callbacks = %v, want running then success", wfBodies)
+	}
+	if result, _ := wfBodies[1]["result"].(map[string]any); result["documents"] != float64(2) {
+		t.Fatalf("workflow result = %v", wfBodies[1]["result"])
+	}
+
+	syncBodies := sink.received("/api/v1/sync/callback")
+	if len(syncBodies) != 1 {
+		t.Fatalf("sync callbacks = %v, want exactly one", syncBodies)
+	}
+	body := syncBodies[0]
+	if body["event_id"] != "evt-1" || body["doc_id"] != float64(42) || body["status"] != "failed" ||
+		body["error"] != "table locked" || body["run_id"] != syncRun.ID {
+		t.Fatalf("sync callback = %v", body)
+	}
+
+	for _, cb := range fake.Callbacks() {
+		if cb.StatusCode != http.StatusOK || cb.Error != "" {
+			t.Fatalf("callback %s delivered with status %d: %s", cb.URL, cb.StatusCode, cb.Error)
+		}
+	}
+}
+
+func TestCancelAndScriptedFailures(t *testing.T) {
+	sink, sinkURL := newCallbackSink(t, "")
+	fake, baseURL := prefectfake.NewTestServer(t, prefectfake.Options{
+		DefaultScript: prefectfake.Script{StartDelay: time.Hour},
+	})
+	slow := fake.AddDeployment(prefectclient.DeploymentDetails{Name: "slow-deployment"})
+	broken := fake.AddDeployment(prefectclient.DeploymentDetails{Name: "broken-deployment"})
+	fake.SetScript("broken-deployment", prefectfake.Script{CreateStatus: http.StatusInternalServerError})
+
+	client := prefectclient.NewClient(baseURL, 5*time.Second)
+	ctx := context.Background()
+
+	if _, err := client.CreateFlowRun(ctx, broken.ID, nil); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("create on broken deployment: err = %v, want status 500", err)
+	}
+	if _, err := client.CreateFlowRun(ctx, "no-such-deployment", nil); err == nil {
+		t.Fatalf("expected error for unknown deployment id")
+	}
+
+	run, err := client.CreateFlowRun(ctx, slow.ID, map[string]interface{}{
+		"run_id":       1,
+		"callback_url": sinkURL + "/api/v1/workflows/callback/1",
+	})
+	if err != nil {
+		t.Fatalf("create flow run: %v", err)
+	}
+	if err := client.CancelFlowRun(ctx, run.ID); err != nil {
+		t.Fatalf("cancel flow run: %v", err)
+	}
+	fake.Wait()
+
+	got, ok := fake.FlowRun(run.ID)
+	if !ok || got.StateType != prefectfake.StateCancelled || got.Parameters["run_id"] != float64(1) {
+		t.Fatalf("cancelled flow run = %+v", got)
+	}
+	if len(sink.received("/api/v1/workflows/callback/1")) != 0 {
+		t.Fatalf("cancelled flow run should not call back")
+	}
+	// 已终态再次取消：fake 返回 409，客户端视为幂等成功
+	if err := client.CancelFlowRun(ctx, run.ID); err != nil {
+		t.Fatalf("second cancel: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package search 提供全文检索所需的纯函数：从文档内容中抽取纯文本，
+// 以及面向中文内容的分词。
+//
+// 中文没有词边界，索引时对连续的 CJK 字符同时生成单字与相邻二字（bigram）词项，
+// 查询时单字查询匹配单字词项、多字查询拆为 bigram 后要求全部命中；
+// 拉丁字母与数字按整词（小写）索引。
+package search
+
+import (
+	"errors"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxTermLength 词项的最大字节数，超出部分截断（与 search_terms.term 列宽一致）
+const MaxTermLength = 64
+
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	stylePattern  = regexp.MustCompile(`(?is)<style\b.*?</style\s*>`)
+	tagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// ExtractText 把文档内容（content.format/content.data）转换为用于索引的纯文本
+// yaml/json 只取字符串标量的值（不含键名），html 去除标签，其余格式（markdown 等）按原文处理
+func ExtractText(format, data string) string {
+	switch strings.ToLower(format) {
+	case "yaml", "json":
+		return extractStructured(data)
+	default:
+		return StripHTML(data)
+	}
+}
+
+// StripHTML 去除 HTML 标签（script/style 连同内容一起去除）并解码实体，合并连续空白
+func StripHTML(s string) string {
+	if strings.ContainsRune(s, '<') {
+		s = scriptPattern.ReplaceAllString(s, " ")
+		s = stylePattern.ReplaceAllString(s, " ")
+		s = tagPattern.ReplaceAllString(s, " ")
+	}
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// extractStructured 逐个解析 YAML 文档（兼容 front matter 的多文档写法），收集字符串标量
+// 解析失败时退回到按原文处理，保证内容有误的文档仍可被检索
+func extractStructured(data string) string {
+	var parts []string
+	decoder := yaml.NewDecoder(strings.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if !errors.Is(err, io.EOF) && len(parts) == 0 {
+				return StripHTML(data)
+			}
+			break
+		}
+		collectScalars(&node, &parts)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func collectScalars(node *yaml.Node, parts *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			collectScalars(child, parts)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			collectScalars(node.Content[i], parts)
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return
+		}
+		if text := StripHTML(node.Value); text != "" {
+			*parts = append(*parts, text)
+		}
+	}
+}
+
+// IsCJK 判断字符是否属于需要按字切分的中日韩文字
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// Tokenize 生成索引词项（可能重复，由调用方计数）
+// CJK 连续片段产生单字与 bigram，其他字母数字片段产生小写整词
+func Tokenize(text string) []string {
+	var tokens []string
+	eachRun(text, func(run []rune, cjk bool) {
+		if !cjk {
+			tokens = append(tokens, truncateTerm(strings.ToLower(string(run))))
+			return
+		}
+		for i := range run {
+			tokens = append(tokens, string(run[i]))
+			if i+1 < len(run) {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	})
+	return tokens
+}
+
+// QueryTerms 把查询串拆为去重后的查询词项，文档需包含全部词项才算命中
+// 单个汉字查询单字词项，两个及以上汉字的片段拆为 bigram
+func QueryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	eachRun(query, func(run []rune, cjk bool) {
+		switch {
+		case !cjk:
+			add(truncateTerm(strings.ToLower(string(run))))
+		case len(run) == 1:
+			add(string(run))
+		default:
+			for i := 0; i+1 < len(run); i++ {
+				add(string(run[i : i+2]))
+			}
+		}
+	})
+	return terms
+}
+
+// eachRun 按字符类别切分文本：连续的 CJK 字符为一段，连续的其他字母/数字为一段，其余字符作为分隔
+func eachRun(text string, fn func(run []rune, cjk bool)) {
+	var run []rune
+	runCJK := false
+	flush := func() {
+		if len(run) > 0 {
+			fn(run, runCJK)
+			run = nil
+		}
+	}
+	for _, r := range text {
+		switch {
+		case IsCJK(r):
+			if !runCJK {
+				flush()
+			}
+			runCJK = true
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if runCJK {
+				flush()
+			}
+			runCJK = false
+			run = append(run, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+func truncateTerm(term string) string {
+	if len(term) <= MaxTermLength {
+		return term
+	}
+	term = term[:MaxTermLength]
+	for !utf8.ValidString(term) {
+		term = term[:len(term)-1]
+	}
+	return term
+}
+
+// Snippet 截取文本中第一个命中词项附近的片段，前后各保留 radius 个字符
+// 没有命中时返回开头部分
+func Snippet(text string, terms []string, radius int) string {
+	runes := []rune(text)
+	lower := strings.ToLower(text)
+	start := -1
+	for _, term := range terms {
+		if idx := strings.Index(lower, term); idx >= 0 {
+			pos := utf8.RuneCountInString(lower[:idx])
+			if start < 0 || pos < start {
+				start = pos
+			}
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	from := max(start-radius, 0)
+	to := min(start+radius, len(runes))
+	if start == 0 {
+		to = min(2*radius, len(runes))
+	}
+	snippet := string(runes[from:to])
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
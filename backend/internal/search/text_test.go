@@ -0,0 +1,57 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("光合作用 ATP合成, Step2")
+	want := []string{"光", "光合", "合", "合作", "作", "作用", "用", "atp", "合", "合成", "成", "step2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	cases := map[string][]string{
+		"光合作用":       {"光合", "合作", "作用"},
+		"光":          {"光"},
+		"ATP 光合 atp": {"atp", "光合"},
+		"  ,,, ":     nil,
+	}
+	for query, want := range cases {
+		if got := QueryTerms(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryTerms(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	yamlData := "---\nid: 3\ntags: [生物]\n---\n\ntitle: <p>细胞&amp;组织</p>\noptions:\n  - content: 线粒体\n    score: 2\n"
+	got := ExtractText("yaml", yamlData)
+	if got != "生物\n细胞&组织\n线粒体" {
+		t.Fatalf("ExtractText(yaml) = %q", got)
+	}
+
+	htmlData := "<html><style>p{color:red}</style><body><h1>标题</h1><script>alert(1)</script><p>正文&nbsp;内容</p></body></html>"
+	if got := ExtractText("html", htmlData); got != "标题 正文 内容" {
+		t.Fatalf("ExtractText(html) = %q", got)
+	}
+
+	if got := ExtractText("yaml", "title: [unclosed"); got != "title: [unclosed" {
+		t.Fatalf("expected invalid YAML to fall back to raw text, got %q", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("甲", 50) + "光合作用" + strings.Repeat("乙", 50)
+	got := Snippet(text, []string{"光合"}, 5)
+	if got != "…甲甲甲甲甲光合作用乙…" {
+		t.Fatalf("Snippet() = %q", got)
+	}
+	if got := Snippet("短文本", []string{"无"}, 5); got != "短文本" {
+		t.Fatalf("Snippet() without match = %q", got)
+	}
+}
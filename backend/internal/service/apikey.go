@@ -1,9 +1,12 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,12 +17,18 @@ import (
 
 // APIKeyService API Key 管理服务
 type APIKeyService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	audit *AuditService
 }
 
 // NewAPIKeyService 创建 API Key 服务实例
 func NewAPIKeyService(db *gorm.DB) *APIKeyService {
-	return &APIKeyService{db: db}
+	return &APIKeyService{db: db, audit: NewAuditService(db)}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (s *APIKeyService) Roles() *RoleService {
+	return NewRoleService(s.db)
 }
 
 // CreateAPIKeyRequest 创建 API Key 请求
@@ -40,7 +49,7 @@ type CreateAPIKeyResponse struct {
 }
 
 // CreateAPIKey 创建新的 API Key
-func (s *APIKeyService) CreateAPIKey(req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, meta RequestMeta, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
 	// 验证用户是否存在
 	var user database.User
 	if err := s.db.First(&user, req.UserID).Error; err != nil {
@@ -50,9 +59,9 @@ func (s *APIKeyService) CreateAPIKey(req CreateAPIKeyRequest) (*CreateAPIKeyResp
 		return nil, fmt.Errorf("failed to query user: %w", err)
 	}
 
-	// 检查用户是否为管理员角色
-	if user.Role != "super_admin" && user.Role != "course_admin" {
-		return nil, errors.New("API keys can only be created for admin users")
+	// 检查用户角色是否允许持有 API Key
+	if !s.Roles().HasPermission(ctx, user.Role, database.PermAPIKeyOwn) {
+		return nil, errors.New("API keys can only be created for users whose role has api_keys:own")
 	}
 
 	// 设置默认环境
@@ -74,13 +83,9 @@ func (s *APIKeyService) CreateAPIKey(req CreateAPIKeyRequest) (*CreateAPIKeyResp
 	keyPrefix := extractKeyPrefix(apiKey)
 
 	// 序列化 scopes
-	scopesJSON := ""
-	if len(req.Scopes) > 0 {
-		scopesBytes, err := json.Marshal(req.Scopes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to serialize scopes: %w", err)
-		}
-		scopesJSON = string(scopesBytes)
+	scopesJSON, err := encodeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建数据库记录
@@ -102,6 +107,12 @@ func (s *APIKeyService) CreateAPIKey(req CreateAPIKeyRequest) (*CreateAPIKeyResp
 	if err := s.db.Preload("User").Preload("CreatedBy").First(&dbKey, dbKey.ID).Error; err != nil {
 		return nil, fmt.Errorf("failed to reload API key: %w", err)
 	}
+	s.recordAPIKeyAudit(ctx, meta, "api_key.create", dbKey.ID, map[string]interface{}{
+		"name":       dbKey.Name,
+		"key_prefix": keyPrefix,
+		"user_id":    dbKey.UserID,
+		"scopes":     req.Scopes,
+	})
 
 	return &CreateAPIKeyResponse{
 		APIKey:    apiKey,
@@ -145,7 +156,7 @@ func (s *APIKeyService) GetAPIKey(id uint) (*database.APIKey, error) {
 }
 
 // RevokeAPIKey 撤销（软删除）API Key
-func (s *APIKeyService) RevokeAPIKey(id uint) error {
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, meta RequestMeta, id uint) error {
 	result := s.db.Delete(&database.APIKey{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to revoke API key: %w", result.Error)
@@ -153,11 +164,12 @@ func (s *APIKeyService) RevokeAPIKey(id uint) error {
 	if result.RowsAffected == 0 {
 		return errors.New("API key not found")
 	}
+	s.recordAPIKeyAudit(ctx, meta, "api_key.revoke", id, nil)
 	return nil
 }
 
 // DeleteAPIKey 永久删除 API Key
-func (s *APIKeyService) DeleteAPIKey(id uint) error {
+func (s *APIKeyService) DeleteAPIKey(ctx context.Context, meta RequestMeta, id uint) error {
 	result := s.db.Unscoped().Delete(&database.APIKey{}, id)
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete API key: %w", result.Error)
@@ -165,11 +177,12 @@ func (s *APIKeyService) DeleteAPIKey(id uint) error {
 	if result.RowsAffected == 0 {
 		return errors.New("API key not found")
 	}
+	s.recordAPIKeyAudit(ctx, meta, "api_key.delete", id, nil)
 	return nil
 }
 
 // UpdateAPIKey 更新 API Key 信息（名称、过期时间等）
-func (s *APIKeyService) UpdateAPIKey(id uint, updates map[string]interface{}) (*database.APIKey, error) {
+func (s *APIKeyService) UpdateAPIKey(ctx context.Context, meta RequestMeta, id uint, updates map[string]interface{}) (*database.APIKey, error) {
 	// 检查是否存在
 	var key database.APIKey
 	if err := s.db.First(&key, id).Error; err != nil {
@@ -179,6 +192,25 @@ func (s *APIKeyService) UpdateAPIKey(id uint, updates map[string]interface{}) (*
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
+	// scopes 以 JSON 数组字符串存储，需要校验并序列化
+	if rawScopes, ok := updates["scopes"]; ok {
+		scopes, err := toScopeList(rawScopes)
+		if err != nil {
+			return nil, err
+		}
+		scopesJSON, err := encodeScopes(scopes)
+		if err != nil {
+			return nil, err
+		}
+		updates["scopes"] = scopesJSON
+	}
+
+	fields := make([]string, 0, len(updates))
+	for field := range updates {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
 	// 更新
 	if err := s.db.Model(&key).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("failed to update API key: %w", err)
@@ -188,10 +220,21 @@ func (s *APIKeyService) UpdateAPIKey(id uint, updates map[string]interface{}) (*
 	if err := s.db.Preload("User").Preload("CreatedBy").First(&key, id).Error; err != nil {
 		return nil, fmt.Errorf("failed to reload API key: %w", err)
 	}
+	s.recordAPIKeyAudit(ctx, meta, "api_key.update", id, map[string]interface{}{"fields": fields})
 
 	return &key, nil
 }
 
+// recordAPIKeyAudit 记录针对 API Key 的审计事件
+func (s *APIKeyService) recordAPIKeyAudit(ctx context.Context, meta RequestMeta, action string, id uint, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: AuditResourceAPIKey,
+		ResourceID:   strconv.FormatUint(uint64(id), 10),
+		Details:      details,
+	})
+}
+
 // GetAPIKeyStats 获取 API Key 使用统计
 func (s *APIKeyService) GetAPIKeyStats(userID uint) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
@@ -245,6 +288,44 @@ func (s *APIKeyService) GetAPIKeyStats(userID uint) (map[string]interface{}, err
 	return stats, nil
 }
 
+// encodeScopes 校验 scopes 并序列化为 JSON 数组字符串
+// 空 scopes 序列化为空字符串，表示拥有关联用户的全部权限
+func encodeScopes(scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", nil
+	}
+	if err := auth.ValidateScopes(scopes); err != nil {
+		return "", err
+	}
+	scopesBytes, err := json.Marshal(scopes)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize scopes: %w", err)
+	}
+	return string(scopesBytes), nil
+}
+
+// toScopeList 将 JSON 解码得到的 scopes 字段转换为字符串列表
+func toScopeList(raw interface{}) ([]string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		scopes := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("scopes must be an array of strings")
+			}
+			scopes = append(scopes, s)
+		}
+		return scopes, nil
+	default:
+		return nil, errors.New("scopes must be an array of strings")
+	}
+}
+
 // extractKeyPrefix 提取 API Key 的显示前缀
 // 例如：ydms_prod_abc123... -> ydms_prod_abc1...
 func extractKeyPrefix(apiKey string) string {
@@ -38,5 +38,9 @@ func (s *Service) GetAssetDownloadURL(ctx context.Context, meta RequestMeta, ass
 
 // DeleteAsset soft-deletes an asset.
 func (s *Service) DeleteAsset(ctx context.Context, meta RequestMeta, assetID int64) error {
-	return s.ndr.DeleteAsset(ctx, toNDRMeta(meta), assetID)
+	if err := s.ndr.DeleteAsset(ctx, toNDRMeta(meta), assetID); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, meta, "asset.delete", AuditResourceAsset, assetID, nil)
+	return nil
 }
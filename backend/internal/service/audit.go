@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+// 审计目标类型
+const (
+	AuditResourceCategory         = "category"
+	AuditResourceDocument         = "document"
+	AuditResourceAsset            = "asset"
+	AuditResourceCourse           = "course"
+	AuditResourceUser             = "user"
+	AuditResourceCoursePermission = "course_permission"
+	AuditResourceAPIKey           = "api_key"
+	AuditResourceRole             = "role"
+	AuditResourceDocumentType     = "document_type"
+	AuditResourceWorkflowRun      = "workflow_run"
+	AuditResourceWorkflowBatch    = "workflow_batch"
+	AuditResourceSyncBatch        = "sync_batch"
+	AuditResourceMigrationBatch   = "migration_batch"
+)
+
+// 审计列表分页限制
+const (
+	defaultAuditListLimit = 50
+	maxAuditListLimit     = 500
+	auditExportLimit      = 50000
+)
+
+// AuditCourseResolver 解析审计目标所属的课程，无法归属时返回 0
+type AuditCourseResolver func(ctx context.Context, resourceType, resourceID string) int64
+
+// AuditService 审计日志服务
+// 审计写入是 best-effort 的：失败只记录日志，不影响业务操作
+type AuditService struct {
+	db       *gorm.DB
+	courseOf AuditCourseResolver
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (s *AuditService) Roles() *RoleService {
+	return NewRoleService(s.db)
+}
+
+// SetCourseResolver 设置目标课程解析器，记录时据此填写 root_node_id
+func (s *AuditService) SetCourseResolver(resolver AuditCourseResolver) {
+	s.courseOf = resolver
+}
+
+// CourseOf 按解析器返回目标所属课程；删除类操作需在目标从 NDR 消失前调用并写入 AuditEntry.RootNodeID
+func (s *AuditService) CourseOf(ctx context.Context, resourceType, resourceID string) int64 {
+	if s == nil || s.courseOf == nil {
+		return 0
+	}
+	return s.courseOf(ctx, resourceType, resourceID)
+}
+
+// AuditEntry 一条待记录的审计事件
+type AuditEntry struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	RootNodeID   int64 // 目标所属课程，为 0 时由解析器推断
+	Details      map[string]interface{}
+}
+
+// AuditFilter 审计事件查询条件
+type AuditFilter struct {
+	ActorID       *uint
+	Action        string
+	ResourceType  string
+	ResourceID    string
+	RequestID     string
+	From          *time.Time
+	To            *time.Time
+	ResourceTypes []string // 限定可见的目标类型（为空不限制）
+	RootNodeIDs   []int64  // 限定目标所属课程（为空不限制）
+	Limit         int
+	Offset        int
+}
+
+// AuditListResponse 审计事件列表
+type AuditListResponse struct {
+	Events  []database.AuditEvent `json:"events"`
+	Total   int64                 `json:"total"`
+	HasMore bool                  `json:"has_more"`
+}
+
+// Record 记录审计事件，执行者取自 meta
+func (s *AuditService) Record(ctx context.Context, meta RequestMeta, entry AuditEntry) {
+	if s == nil || s.db == nil {
+		return
+	}
+
+	event := database.AuditEvent{
+		ActorRole:    meta.UserRole,
+		ActorName:    meta.UserID,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		RequestID:    meta.RequestID,
+		Details:      database.JSONMap(entry.Details),
+	}
+	if event.Details == nil {
+		event.Details = database.JSONMap{}
+	}
+	rootNodeID := entry.RootNodeID
+	if rootNodeID == 0 && s.courseOf != nil {
+		rootNodeID = s.courseOf(ctx, entry.ResourceType, entry.ResourceID)
+	}
+	if rootNodeID != 0 {
+		event.RootNodeID = &rootNodeID
+	}
+	if meta.UserIDNumeric != 0 {
+		actorID := meta.UserIDNumeric
+		event.ActorID = &actorID
+		// meta.UserID 可能是 NDR 使用的默认用户标识，以用户名为准
+		var user database.User
+		if err := s.db.WithContext(ctx).Unscoped().Select("username", "role").First(&user, actorID).Error; err == nil {
+			event.ActorName = user.Username
+			if event.ActorRole == "" {
+				event.ActorRole = user.Role
+			}
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(&event).Error; err != nil {
+		log.Printf("[audit] failed to record %s %s/%s: %v", entry.Action, entry.ResourceType, entry.ResourceID, err)
+	}
+}
+
+// List 按条件查询审计事件（按时间倒序）
+func (s *AuditService) List(ctx context.Context, filter AuditFilter) (*AuditListResponse, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditListLimit
+	}
+	if limit > maxAuditListLimit {
+		limit = maxAuditListLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	if err := s.filterQuery(ctx, filter).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	var events []database.AuditEvent
+	if err := s.filterQuery(ctx, filter).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return &AuditListResponse{
+		Events:  events,
+		Total:   total,
+		HasMore: int64(offset+len(events)) < total,
+	}, nil
+}
+
+// ExportCSV 以 CSV 格式导出符合条件的审计事件
+func (s *AuditService) ExportCSV(ctx context.Context, filter AuditFilter, w io.Writer) error {
+	var events []database.AuditEvent
+	if err := s.filterQuery(ctx, filter).
+		Order("created_at DESC, id DESC").
+		Limit(auditExportLimit).
+		Find(&events).Error; err != nil {
+		return fmt.Errorf("failed to export audit events: %w", err)
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"id", "created_at", "actor_id", "actor_name", "actor_role", "action", "resource_type", "resource_id", "request_id", "details"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, e := range events {
+		actorID := ""
+		if e.ActorID != nil {
+			actorID = strconv.FormatUint(uint64(*e.ActorID), 10)
+		}
+		details := ""
+		if len(e.Details) > 0 {
+			raw, _ := json.Marshal(e.Details)
+			details = string(raw)
+		}
+		record := []string{
+			strconv.FormatUint(uint64(e.ID), 10),
+			e.CreatedAt.Format(time.RFC3339),
+			actorID,
+			e.ActorName,
+			e.ActorRole,
+			e.Action,
+			e.ResourceType,
+			e.ResourceID,
+			e.RequestID,
+			details,
+		}
+		for i := range record {
+			record[i] = escapeCSVCell(record[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escapeCSVCell 为可能被表格软件当作公式执行的单元格加前导单引号
+func escapeCSVCell(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
+
+func (s *AuditService) filterQuery(ctx context.Context, filter AuditFilter) *gorm.DB {
+	q := s.db.WithContext(ctx).Model(&database.AuditEvent{})
+	if filter.ActorID != nil {
+		q = q.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		q = q.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		q = q.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.RequestID != "" {
+		q = q.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		q = q.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("created_at < ?", *filter.To)
+	}
+	if len(filter.ResourceTypes) > 0 {
+		q = q.Where("resource_type IN ?", filter.ResourceTypes)
+	}
+	if len(filter.RootNodeIDs) > 0 {
+		q = q.Where("root_node_id IN ?", filter.RootNodeIDs)
+	}
+	return q
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
+)
+
+func setupAuditDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.UserSession{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+func TestAuditService_RecordAndList(t *testing.T) {
+	db := setupAuditDB(t)
+	admin := database.User{Username: "alice", PasswordHash: "x", Role: "super_admin"}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	audit := NewAuditService(db)
+	ctx := context.Background()
+	meta := RequestMeta{UserID: "default", UserIDNumeric: admin.ID, RequestID: "req-1"}
+	audit.Record(ctx, meta, AuditEntry{Action: "document.update", ResourceType: AuditResourceDocument, ResourceID: "42", Details: map[string]interface{}{"fields": []string{"title"}}})
+	audit.Record(ctx, meta, AuditEntry{Action: "api_key.create", ResourceType: AuditResourceAPIKey, ResourceID: "7"})
+	audit.Record(ctx, RequestMeta{RequestID: "req-2"}, AuditEntry{Action: "document.delete", ResourceType: AuditResourceDocument, ResourceID: "42"})
+
+	all, err := audit.List(ctx, AuditFilter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if all.Total != 3 || len(all.Events) != 3 || all.HasMore {
+		t.Fatalf("unexpected list result: total=%d len=%d has_more=%v", all.Total, len(all.Events), all.HasMore)
+	}
+	first := all.Events[len(all.Events)-1]
+	if first.ActorName != "alice" || first.ActorRole != "super_admin" || first.ActorID == nil || *first.ActorID != admin.ID {
+		t.Fatalf("expected actor to be resolved from user id, got %+v", first)
+	}
+
+	byResource, err := audit.List(ctx, AuditFilter{ResourceType: AuditResourceDocument, ResourceID: "42"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if byResource.Total != 2 {
+		t.Fatalf("expected 2 events for document 42, got %d", byResource.Total)
+	}
+
+	byActor, err := audit.List(ctx, AuditFilter{ActorID: &admin.ID, ResourceTypes: []string{AuditResourceDocument}})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if byActor.Total != 1 || byActor.Events[0].Action != "document.update" {
+		t.Fatalf("expected visible resource types to hide api_key events, got %+v", byActor.Events)
+	}
+
+	paged, err := audit.List(ctx, AuditFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(paged.Events) != 2 || !paged.HasMore {
+		t.Fatalf("expected a partial page, got len=%d has_more=%v", len(paged.Events), paged.HasMore)
+	}
+}
+
+func TestAuditService_ExportCSV(t *testing.T) {
+	db := setupAuditDB(t)
+	audit := NewAuditService(db)
+	ctx := context.Background()
+	audit.Record(ctx, RequestMeta{RequestID: "req-1"}, AuditEntry{Action: "category.create", ResourceType: AuditResourceCategory, ResourceID: "1", Details: map[string]interface{}{"name": "第一章, 概述"}})
+	audit.Record(ctx, RequestMeta{RequestID: "req-2"}, AuditEntry{Action: "category.delete", ResourceType: AuditResourceCategory, ResourceID: "1"})
+
+	var buf bytes.Buffer
+	if err := audit.ExportCSV(ctx, AuditFilter{Action: "category.create"}, &buf); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse exported csv: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one row, got %d records", len(records))
+	}
+	row := records[1]
+	if row[5] != "category.create" || row[8] != "req-1" || row[9] != `{"name":"第一章, 概述"}` {
+		t.Fatalf("unexpected csv row: %q", row)
+	}
+}
+
+func TestAuditService_ExportCSVEscapesFormulas(t *testing.T) {
+	db := setupAuditDB(t)
+	audit := NewAuditService(db)
+	ctx := context.Background()
+	audit.Record(ctx, RequestMeta{UserID: "=HYPERLINK(\"http://x\")", RequestID: "@req"}, AuditEntry{Action: "document.update", ResourceType: AuditResourceDocument, ResourceID: "-1"})
+
+	var buf bytes.Buffer
+	if err := audit.ExportCSV(ctx, AuditFilter{}, &buf); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse exported csv: %v", err)
+	}
+	row := records[1]
+	if row[3] != `'=HYPERLINK("http://x")` || row[7] != "'-1" || row[8] != "'@req" || row[5] != "document.update" {
+		t.Fatalf("expected formula cells to be escaped, got %q", row)
+	}
+}
+
+func TestAuditService_CourseScope(t *testing.T) {
+	db := setupAuditDB(t)
+	audit := NewAuditService(db)
+	audit.SetCourseResolver(func(ctx context.Context, resourceType, resourceID string) int64 {
+		if resourceType == AuditResourceDocument && resourceID == "42" {
+			return 1
+		}
+		return 0
+	})
+	ctx := context.Background()
+	audit.Record(ctx, RequestMeta{}, AuditEntry{Action: "document.update", ResourceType: AuditResourceDocument, ResourceID: "42"})
+	audit.Record(ctx, RequestMeta{}, AuditEntry{Action: "category.update", ResourceType: AuditResourceCategory, ResourceID: "7", RootNodeID: 2})
+	audit.Record(ctx, RequestMeta{}, AuditEntry{Action: "document.update", ResourceType: AuditResourceDocument, ResourceID: "43"})
+
+	scoped, err := audit.List(ctx, AuditFilter{RootNodeIDs: []int64{1}})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if scoped.Total != 1 || scoped.Events[0].ResourceID != "42" || *scoped.Events[0].RootNodeID != 1 {
+		t.Fatalf("expected only course 1 events, got %+v", scoped.Events)
+	}
+	both, err := audit.List(ctx, AuditFilter{RootNodeIDs: []int64{1, 2}})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if both.Total != 2 {
+		t.Fatalf("expected 2 events in courses 1 and 2, got %d", both.Total)
+	}
+}
+
+func TestUserService_RecordsAuditEvents(t *testing.T) {
+	db := setupAuditDB(t)
+	if err := db.AutoMigrate(&database.CoursePermission{}, &database.RefreshToken{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	users := NewUserService(db)
+	ctx := context.Background()
+
+	user, err := users.CreateUser(ctx, RequestMeta{RequestID: "req-1"}, "bob", "password123", "proofreader", nil)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 10, ""); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	// 撤销不存在的权限不记录
+	if err := users.RevokeCoursePermission(ctx, RequestMeta{}, user.ID, 99); err != nil {
+		t.Fatalf("RevokeCoursePermission() error = %v", err)
+	}
+	if err := users.DeleteUser(ctx, RequestMeta{}, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	var actions []string
+	db.Model(&database.AuditEvent{}).Order("id ASC").Pluck("action", &actions)
+	want := []string{"user.create", "course_permission.grant", "user.delete"}
+	if len(actions) != len(want) {
+		t.Fatalf("expected actions %v, got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("expected actions %v, got %v", want, actions)
+		}
+	}
+}
+
+func TestService_AuditsRemovalsInCourse(t *testing.T) {
+	db := setupAuditDB(t)
+	_, baseURL := ndrfake.NewTestServer(t, ndrfake.Options{})
+	ndr := ndrclient.NewClient(ndrclient.NDRConfig{BaseURL: baseURL})
+	audit := NewAuditService(db)
+	audit.SetCourseResolver(NewPermissionService(db, nil, ndr, nil).AuditCourse)
+	svc := NewService(cache.NewNoop(), ndr, nil)
+	svc.SetAuditService(audit)
+	ctx := context.Background()
+	meta := RequestMeta{UserID: "tester"}
+
+	course, err := svc.CreateCategory(ctx, meta, CategoryCreateRequest{Name: "Course"})
+	if err != nil {
+		t.Fatalf("CreateCategory() error = %v", err)
+	}
+	chapter, err := svc.CreateCategory(ctx, meta, CategoryCreateRequest{Name: "Chapter", ParentID: &course.ID})
+	if err != nil {
+		t.Fatalf("CreateCategory() error = %v", err)
+	}
+	doc, err := svc.CreateDocument(ctx, meta, DocumentCreateRequest{Title: "Doc", NodeID: &chapter.ID})
+	if err != nil {
+		t.Fatalf("CreateDocument() error = %v", err)
+	}
+
+	// 彻底删除后节点与文档绑定都已不存在，课程必须在删除前解析
+	if err := svc.DeleteDocument(ctx, meta, doc.ID); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+	if err := svc.PurgeDocument(ctx, meta, doc.ID); err != nil {
+		t.Fatalf("PurgeDocument() error = %v", err)
+	}
+	if err := svc.DeleteCategory(ctx, meta, chapter.ID, CategoryDeleteRequest{}); err != nil {
+		t.Fatalf("DeleteCategory() error = %v", err)
+	}
+	if err := svc.PurgeCategory(ctx, meta, chapter.ID); err != nil {
+		t.Fatalf("PurgeCategory() error = %v", err)
+	}
+
+	scoped, err := audit.List(ctx, AuditFilter{RootNodeIDs: []int64{course.ID}, Limit: 100})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, event := range scoped.Events {
+		seen[event.Action] = true
+	}
+	for _, action := range []string{"document.delete", "document.purge", "category.delete", "category.purge"} {
+		if !seen[action] {
+			t.Errorf("course-scoped audit list is missing %s; got %v", action, seen)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+)
+
+var (
+	// ErrBatchNotFound 批次不存在
+	ErrBatchNotFound = errors.New("batch not found")
+	// ErrBatchFinished 批次已结束，无法取消
+	ErrBatchFinished = errors.New("batch already finished")
+	// ErrBatchForbidden 无权操作该批次
+	ErrBatchForbidden = errors.New("only the batch creator or a role with batches:all can manage this batch")
+)
+
+const (
+	// batchItemHeartbeatInterval 执行中的项刷新心跳的间隔
+	batchItemHeartbeatInterval = 30 * time.Second
+	// batchItemLeaseTTL 心跳超过该时长未刷新的 running 项视为所属实例已退出
+	batchItemLeaseTTL = 2 * time.Minute
+)
+
+// batchInstanceID 当前服务实例的标识，写入认领项的 claimed_by
+var batchInstanceID = newBatchInstanceID()
+
+func newBatchInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+// batchItemOutcome 单个执行项的处理结果
+type batchItemOutcome struct {
+	Status string // success / failed / skipped
+	Reason string
+	Error  string
+	Result map[string]interface{}
+}
+
+// batchKind 描述一种批次（工作流 / 同步 / 类型迁移）在 batch_items 与批次表之间的对应关系
+type batchKind struct {
+	itemType   string
+	newModel   func() interface{}
+	resultsKey string // 状态详情中结果列表的字段名（前端沿用）
+	logPrefix  string
+}
+
+var (
+	workflowBatchKind = batchKind{
+		itemType:   database.BatchItemTypeWorkflow,
+		newModel:   func() interface{} { return &database.WorkflowBatch{} },
+		resultsKey: "node_results",
+		logPrefix:  "[batch_workflow]",
+	}
+	syncBatchKind = batchKind{
+		itemType:   database.BatchItemTypeSync,
+		newModel:   func() interface{} { return &database.SyncBatch{} },
+		resultsKey: "document_results",
+		logPrefix:  "[batch_sync]",
+	}
+	migrationBatchKind = batchKind{
+		itemType:   database.BatchItemTypeMigration,
+		newModel:   func() interface{} { return &database.MigrationBatch{} },
+		resultsKey: "document_results",
+		logPrefix:  "[batch_migration]",
+	}
+)
+
+// runBatchItems 执行批次中所有 pending 的项
+// 每项先以条件更新认领（pending -> running），取消操作会把 pending 项置为 cancelled，因此取消后不会再认领新项
+// 执行期间定期刷新本实例认领项的心跳，其他实例据此判断租约是否仍有效
+func runBatchItems(
+	ctx context.Context,
+	db *gorm.DB,
+	kind batchKind,
+	batchID uint,
+	concurrency int,
+	process func(ctx context.Context, item database.BatchItem) batchItemOutcome,
+) {
+	now := time.Now()
+	db.Model(kind.newModel()).
+		Where("id = ? AND status = ?", batchID, database.BatchStatusPending).
+		Updates(map[string]interface{}{"status": database.BatchStatusRunning, "started_at": &now})
+
+	var items []database.BatchItem
+	if err := db.Where("batch_type = ? AND batch_ref_id = ? AND status = ?", kind.itemType, batchID, database.BatchItemStatusPending).
+		Order("sequence ASC").Find(&items).Error; err != nil {
+		log.Printf("%s batch %d: failed to load items: %v", kind.logPrefix, batchID, err)
+		return
+	}
+
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	if concurrency > MaxBatchConcurrency {
+		concurrency = MaxBatchConcurrency
+	}
+
+	stopHeartbeat := startBatchItemHeartbeat(db, kind, batchID)
+	defer stopHeartbeat()
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, item := range items {
+		wg.Add(1)
+		semaphore <- struct{}{} // 获取信号量
+
+		go func(it database.BatchItem) {
+			defer wg.Done()
+			defer func() { <-semaphore }() // 释放信号量
+
+			if !claimBatchItem(db, it.ID) {
+				return
+			}
+
+			outcome := process(ctx, it)
+			finishedAt := time.Now()
+			result := outcome.Result
+			if result == nil {
+				result = map[string]interface{}{}
+			}
+			// 租约已被其他实例接管时不覆盖其结果
+			db.Model(&database.BatchItem{}).
+				Where("id = ? AND status = ? AND claimed_by = ?", it.ID, database.BatchItemStatusRunning, batchInstanceID).
+				Updates(map[string]interface{}{
+					"status":        outcome.Status,
+					"reason":        outcome.Reason,
+					"error_message": outcome.Error,
+					"result":        database.JSONMap(result),
+					"heartbeat_at":  &finishedAt,
+					"finished_at":   &finishedAt,
+				})
+			refreshBatchCounts(db, kind, batchID)
+		}(item)
+	}
+
+	wg.Wait()
+	finalizeBatch(db, kind, batchID)
+}
+
+// claimBatchItem 以当前实例认领一个 pending 项，返回是否认领成功
+func claimBatchItem(db *gorm.DB, itemID uint) bool {
+	now := time.Now()
+	result := db.Model(&database.BatchItem{}).
+		Where("id = ? AND status = ?", itemID, database.BatchItemStatusPending).
+		Updates(map[string]interface{}{
+			"status":       database.BatchItemStatusRunning,
+			"claimed_by":   batchInstanceID,
+			"heartbeat_at": &now,
+			"started_at":   &now,
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// startBatchItemHeartbeat 定期刷新本实例在批次中认领的 running 项的心跳，返回停止函数
+func startBatchItemHeartbeat(db *gorm.DB, kind batchKind, batchID uint) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(batchItemHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				if err := db.Model(&database.BatchItem{}).
+					Where("batch_type = ? AND batch_ref_id = ? AND status = ? AND claimed_by = ?",
+						kind.itemType, batchID, database.BatchItemStatusRunning, batchInstanceID).
+					Update("heartbeat_at", &now).Error; err != nil {
+					log.Printf("%s batch %d: failed to refresh heartbeat: %v", kind.logPrefix, batchID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// expiredLeaseScope 筛选租约已过期的项：从未记录心跳，或心跳早于 TTL
+func expiredLeaseScope(now time.Time) func(*gorm.DB) *gorm.DB {
+	cutoff := now.Add(-batchItemLeaseTTL)
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("heartbeat_at IS NULL OR heartbeat_at < ?", cutoff)
+	}
+}
+
+// batchOwnedByLiveInstance 判断批次是否仍由其他存活实例执行（存在其他实例认领且心跳未过期的项）
+func batchOwnedByLiveInstance(db *gorm.DB, kind batchKind, batchID uint) bool {
+	var count int64
+	db.Model(&database.BatchItem{}).
+		Where("batch_type = ? AND batch_ref_id = ? AND claimed_by <> '' AND claimed_by <> ?", kind.itemType, batchID, batchInstanceID).
+		Where("heartbeat_at >= ?", time.Now().Add(-batchItemLeaseTTL)).
+		Count(&count)
+	return count > 0
+}
+
+// batchItemCounts 按状态统计批次中的项
+func batchItemCounts(db *gorm.DB, kind batchKind, batchID uint) (map[string]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err := db.Model(&database.BatchItem{}).
+		Select("status, COUNT(*) AS count").
+		Where("batch_type = ? AND batch_ref_id = ?", kind.itemType, batchID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// refreshBatchCounts 把逐项结果汇总到批次表，供进度查询使用
+func refreshBatchCounts(db *gorm.DB, kind batchKind, batchID uint) {
+	counts, err := batchItemCounts(db, kind, batchID)
+	if err != nil {
+		log.Printf("%s batch %d: failed to count items: %v", kind.logPrefix, batchID, err)
+		return
+	}
+	db.Model(kind.newModel()).Where("id = ?", batchID).Updates(map[string]interface{}{
+		"success_count": counts[database.BatchItemStatusSuccess],
+		"failed_count":  counts[database.BatchItemStatusFailed],
+		"skipped_count": counts[database.BatchItemStatusSkipped],
+	})
+}
+
+// finalizeBatch 在没有 pending/running 项时结束批次
+func finalizeBatch(db *gorm.DB, kind batchKind, batchID uint) {
+	counts, err := batchItemCounts(db, kind, batchID)
+	if err != nil {
+		log.Printf("%s batch %d: failed to count items: %v", kind.logPrefix, batchID, err)
+		return
+	}
+	if counts[database.BatchItemStatusPending] > 0 || counts[database.BatchItemStatusRunning] > 0 {
+		return
+	}
+
+	successCount := counts[database.BatchItemStatusSuccess]
+	failedCount := counts[database.BatchItemStatusFailed]
+	skippedCount := counts[database.BatchItemStatusSkipped]
+
+	finalStatus := database.BatchStatusCompleted
+	if failedCount > 0 && successCount == 0 {
+		finalStatus = database.BatchStatusFailed
+	}
+
+	finishedAt := time.Now()
+	updates := map[string]interface{}{
+		"status":        finalStatus,
+		"success_count": successCount,
+		"failed_count":  failedCount,
+		"skipped_count": skippedCount,
+		"finished_at":   &finishedAt,
+	}
+	// 已取消的批次保持 cancelled 状态，只更新统计
+	result := db.Model(kind.newModel()).
+		Where("id = ? AND status IN ?", batchID, []string{database.BatchStatusPending, database.BatchStatusRunning}).
+		Updates(updates)
+	if result.Error == nil && result.RowsAffected == 0 {
+		delete(updates, "status")
+		db.Model(kind.newModel()).Where("id = ?", batchID).Updates(updates)
+	}
+
+	log.Printf("%s batch %d finished: success=%d, failed=%d, skipped=%d, cancelled=%d",
+		kind.logPrefix, batchID, successCount, failedCount, skippedCount, counts[database.BatchItemStatusCancelled])
+}
+
+// cancelBatch 取消批次：批次置为 cancelled，尚未开始的项置为 cancelled
+// 状态全部落库，服务重启后恢复逻辑不会再执行这些项；正在执行的项会正常结束
+func cancelBatch(db *gorm.DB, kind batchKind, batchID uint) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(kind.newModel()).
+			Where("id = ? AND status IN ?", batchID, []string{database.BatchStatusPending, database.BatchStatusRunning}).
+			Updates(map[string]interface{}{
+				"status":      database.BatchStatusCancelled,
+				"finished_at": &now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to cancel batch: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrBatchFinished
+		}
+		if err := tx.Model(&database.BatchItem{}).
+			Where("batch_type = ? AND batch_ref_id = ? AND status = ?", kind.itemType, batchID, database.BatchItemStatusPending).
+			Updates(map[string]interface{}{
+				"status":      database.BatchItemStatusCancelled,
+				"finished_at": &now,
+			}).Error; err != nil {
+			return fmt.Errorf("failed to cancel batch items: %w", err)
+		}
+		return nil
+	})
+}
+
+// recordBatchItemRun 在调用 Prefect 之前记下项触发的任务 ID，进程中断后据此对账而不是重复触发
+func recordBatchItemRun(db *gorm.DB, itemID uint, runID uint) error {
+	if err := db.Model(&database.BatchItem{}).Where("id = ?", itemID).
+		Update("result", database.JSONMap{"run_id": runID}).Error; err != nil {
+		return fmt.Errorf("failed to record workflow run on batch item: %w", err)
+	}
+	return nil
+}
+
+// batchItemRunID 返回项已记录的任务 ID，未记录时为 0
+func batchItemRunID(item database.BatchItem) uint {
+	switch v := item.Result["run_id"].(type) {
+	case float64:
+		return uint(v)
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	}
+	return 0
+}
+
+// requeueInterruptedItems 处理租约已过期（所属实例已退出）的 running 项：
+// 已记录任务 ID 的交给 resolve 按任务状态结束（resolve 为 nil 或返回 false 时重新执行），其余重新置为 pending
+// 心跳仍在刷新的项属于存活实例，保持不动
+func requeueInterruptedItems(
+	db *gorm.DB,
+	kind batchKind,
+	batchID uint,
+	resolve func(item database.BatchItem, runID uint) (batchItemOutcome, bool),
+) error {
+	now := time.Now()
+	var items []database.BatchItem
+	if err := db.Where("batch_type = ? AND batch_ref_id = ? AND status = ?", kind.itemType, batchID, database.BatchItemStatusRunning).
+		Scopes(expiredLeaseScope(now)).
+		Find(&items).Error; err != nil {
+		return err
+	}
+	requeue := make([]uint, 0, len(items))
+	for _, item := range items {
+		runID := batchItemRunID(item)
+		if runID == 0 || resolve == nil {
+			requeue = append(requeue, item.ID)
+			continue
+		}
+		outcome, ok := resolve(item, runID)
+		if !ok {
+			requeue = append(requeue, item.ID)
+			continue
+		}
+		finishedAt := time.Now()
+		if err := db.Model(&database.BatchItem{}).Where("id = ? AND status = ?", item.ID, database.BatchItemStatusRunning).
+			Scopes(expiredLeaseScope(now)).Updates(map[string]interface{}{
+			"status":        outcome.Status,
+			"reason":        outcome.Reason,
+			"error_message": outcome.Error,
+			"result":        database.JSONMap(outcome.Result),
+			"finished_at":   &finishedAt,
+		}).Error; err != nil {
+			return err
+		}
+		log.Printf("%s batch %d: item %d reconciled from workflow run %d: %s", kind.logPrefix, batchID, item.ID, runID, outcome.Status)
+	}
+	if len(requeue) > 0 {
+		if err := db.Model(&database.BatchItem{}).Where("id IN ? AND status = ?", requeue, database.BatchItemStatusRunning).
+			Scopes(expiredLeaseScope(now)).
+			Updates(map[string]interface{}{"status": database.BatchItemStatusPending, "claimed_by": "", "heartbeat_at": nil, "started_at": nil}).Error; err != nil {
+			return err
+		}
+	}
+	refreshBatchCounts(db, kind, batchID)
+	return nil
+}
+
+// interruptedRunOutcome 由中断项已记录的任务得出其结果，不重新触发；任务不存在时返回 false，项重新执行
+func interruptedRunOutcome(
+	ctx context.Context,
+	db *gorm.DB,
+	prefect *prefectclient.Client,
+	runID uint,
+	toResult func(run *database.WorkflowRun) map[string]interface{},
+) (batchItemOutcome, bool) {
+	run, err := reconcileInterruptedRun(ctx, db, prefect, runID)
+	if err != nil {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}, true
+	}
+	if run == nil {
+		return batchItemOutcome{}, false
+	}
+	if run.Status == WorkflowStatusFailed || run.Status == WorkflowStatusCancelled {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: run.ErrorMessage, Result: toResult(run)}, true
+	}
+	return batchItemOutcome{Status: database.BatchItemStatusSuccess, Result: toResult(run)}, true
+}
+
+// hasBatchItems 判断批次是否有逐项记录（引入 batch_items 之前创建的批次没有）
+func hasBatchItems(db *gorm.DB, kind batchKind, batchID uint) bool {
+	var count int64
+	db.Model(&database.BatchItem{}).Where("batch_type = ? AND batch_ref_id = ?", kind.itemType, batchID).Count(&count)
+	return count > 0
+}
+
+// failLegacyBatch 无法恢复的旧批次直接标记为失败，避免永久停留在 running
+func failLegacyBatch(db *gorm.DB, kind batchKind, batchID uint) {
+	finishedAt := time.Now()
+	db.Model(kind.newModel()).Where("id = ?", batchID).Updates(map[string]interface{}{
+		"status":        database.BatchStatusFailed,
+		"error_message": "interrupted by server restart",
+		"finished_at":   &finishedAt,
+	})
+	log.Printf("%s batch %d has no durable items, marked as failed", kind.logPrefix, batchID)
+}
+
+// batchResultDetails 由逐项记录生成状态详情（与旧版 Details 结构保持一致）
+func batchResultDetails(db *gorm.DB, kind batchKind, batchID uint, toResult func(item database.BatchItem) map[string]interface{}) (map[string]interface{}, bool) {
+	var items []database.BatchItem
+	if err := db.Where("batch_type = ? AND batch_ref_id = ?", kind.itemType, batchID).
+		Order("sequence ASC").Find(&items).Error; err != nil || len(items) == 0 {
+		return nil, false
+	}
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		result := toResult(item)
+		result["status"] = item.Status
+		if item.Reason != "" {
+			result["reason"] = item.Reason
+		}
+		if item.ErrorMessage != "" {
+			result["error"] = item.ErrorMessage
+		}
+		for k, v := range item.Result {
+			result[k] = v
+		}
+		results = append(results, result)
+	}
+	return map[string]interface{}{kind.resultsKey: results}, true
+}
+
+// batchRequestMeta 为恢复执行的批次重建请求上下文（使用创建者身份，NDR 使用默认 API Key）
+func batchRequestMeta(db *gorm.DB, createdByID *uint) RequestMeta {
+	meta := RequestMeta{}
+	if createdByID == nil || *createdByID == 0 {
+		return meta
+	}
+	var user database.User
+	if err := db.First(&user, *createdByID).Error; err != nil {
+		log.Printf("[batch] failed to load batch creator %d: %v", *createdByID, err)
+		return meta
+	}
+	meta.UserIDNumeric = user.ID
+	meta.UserID = user.Username
+	meta.UserRole = user.Role
+	return meta
+}
+
+// seesAllBatches 角色拥有 batches:all 时可以查看和管理他人创建的批次
+func seesAllBatches(ctx context.Context, db *gorm.DB, meta RequestMeta) bool {
+	return NewRoleService(db).HasPermission(ctx, meta.UserRole, database.PermBatchesAll)
+}
+
+// canManageBatch 判断用户是否可以管理（取消）批次：创建者本人或角色拥有 batches:all
+func canManageBatch(ctx context.Context, db *gorm.DB, meta RequestMeta, createdByID *uint) bool {
+	if createdByID != nil && meta.UserIDNumeric != 0 && *createdByID == meta.UserIDNumeric {
+		return true
+	}
+	return seesAllBatches(ctx, db, meta)
+}
+
+// encodeBatchOptions 把执行请求保存为 JSONMap
+func encodeBatchOptions(req interface{}) database.JSONMap {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return database.JSONMap{}
+	}
+	options := database.JSONMap{}
+	if err := json.Unmarshal(raw, &options); err != nil {
+		return database.JSONMap{}
+	}
+	return options
+}
+
+// decodeBatchOptions 从 JSONMap 还原执行请求
+func decodeBatchOptions(options database.JSONMap, dest interface{}) error {
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dest)
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+	"github.com/yjxt/ydms/backend/internal/prefectfake"
+)
+
+func setupBatchItemsDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.SyncBatch{}, &database.BatchItem{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+func createSyncBatchWithItems(t *testing.T, db *gorm.DB, statuses ...string) database.SyncBatch {
+	t.Helper()
+	batch := database.SyncBatch{BatchID: uuid.NewString(), RootNodeID: 1, Status: database.BatchStatusPending, TotalDocuments: len(statuses)}
+	if err := db.Create(&batch).Error; err != nil {
+		t.Fatalf("failed to create batch: %v", err)
+	}
+	for i, status := range statuses {
+		docID := int64(100 + i)
+		item := database.BatchItem{
+			BatchType:  database.BatchItemTypeSync,
+			BatchRefID: batch.ID,
+			Sequence:   i,
+			DocumentID: &docID,
+			Status:     status,
+		}
+		if err := db.Create(&item).Error; err != nil {
+			t.Fatalf("failed to create item: %v", err)
+		}
+	}
+	return batch
+}
+
+func TestRunBatchItems_ProcessesPendingItemsAndFinalizes(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	batch := createSyncBatchWithItems(t, db,
+		database.BatchItemStatusPending, database.BatchItemStatusPending, database.BatchItemStatusSkipped)
+
+	runBatchItems(context.Background(), db, syncBatchKind, batch.ID, 2,
+		func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+			if *item.DocumentID == 100 {
+				return batchItemOutcome{Status: database.BatchItemStatusSuccess, Result: map[string]interface{}{"run_id": "r1"}}
+			}
+			return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: "boom"}
+		})
+
+	var got database.SyncBatch
+	if err := db.First(&got, batch.ID).Error; err != nil {
+		t.Fatalf("failed to reload batch: %v", err)
+	}
+	if got.Status != database.BatchStatusCompleted {
+		t.Fatalf("expected completed, got %s", got.Status)
+	}
+	if got.SuccessCount != 1 || got.FailedCount != 1 || got.SkippedCount != 1 {
+		t.Fatalf("unexpected counts: success=%d failed=%d skipped=%d", got.SuccessCount, got.FailedCount, got.SkippedCount)
+	}
+	if got.FinishedAt == nil {
+		t.Fatalf("expected finished_at to be set")
+	}
+
+	var items []database.BatchItem
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence ASC").Find(&items)
+	if items[0].Attempts != 1 || items[0].Result["run_id"] != "r1" {
+		t.Fatalf("unexpected first item: %+v", items[0])
+	}
+	if items[1].ErrorMessage != "boom" {
+		t.Fatalf("expected error to be recorded, got %q", items[1].ErrorMessage)
+	}
+}
+
+func TestCancelBatch_StopsPendingItems(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	batch := createSyncBatchWithItems(t, db,
+		database.BatchItemStatusSuccess, database.BatchItemStatusPending, database.BatchItemStatusPending)
+
+	if err := cancelBatch(db, syncBatchKind, batch.ID); err != nil {
+		t.Fatalf("cancelBatch() error = %v", err)
+	}
+	if err := cancelBatch(db, syncBatchKind, batch.ID); err != ErrBatchFinished {
+		t.Fatalf("expected ErrBatchFinished on second cancel, got %v", err)
+	}
+
+	processed := 0
+	runBatchItems(context.Background(), db, syncBatchKind, batch.ID, 1,
+		func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+			processed++
+			return batchItemOutcome{Status: database.BatchItemStatusSuccess}
+		})
+	if processed != 0 {
+		t.Fatalf("expected no items to run after cancel, ran %d", processed)
+	}
+
+	var got database.SyncBatch
+	db.First(&got, batch.ID)
+	if got.Status != database.BatchStatusCancelled {
+		t.Fatalf("expected batch to stay cancelled, got %s", got.Status)
+	}
+	counts, err := batchItemCounts(db, syncBatchKind, batch.ID)
+	if err != nil {
+		t.Fatalf("batchItemCounts() error = %v", err)
+	}
+	if counts[database.BatchItemStatusCancelled] != 2 || counts[database.BatchItemStatusSuccess] != 1 {
+		t.Fatalf("unexpected item counts: %v", counts)
+	}
+}
+
+func TestRequeueInterruptedItems_ResumesRunningItems(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	batch := createSyncBatchWithItems(t, db,
+		database.BatchItemStatusSuccess, database.BatchItemStatusRunning)
+	db.Model(&database.SyncBatch{}).Where("id = ?", batch.ID).Update("status", database.BatchStatusRunning)
+
+	if err := requeueInterruptedItems(db, syncBatchKind, batch.ID, nil); err != nil {
+		t.Fatalf("requeueInterruptedItems() error = %v", err)
+	}
+
+	var resumed []int64
+	runBatchItems(context.Background(), db, syncBatchKind, batch.ID, 1,
+		func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+			resumed = append(resumed, *item.DocumentID)
+			return batchItemOutcome{Status: database.BatchItemStatusSuccess}
+		})
+	if len(resumed) != 1 || resumed[0] != 101 {
+		t.Fatalf("expected only the interrupted item to be resumed, got %v", resumed)
+	}
+
+	var got database.SyncBatch
+	db.First(&got, batch.ID)
+	if got.Status != database.BatchStatusCompleted || got.SuccessCount != 2 {
+		t.Fatalf("unexpected batch after resume: status=%s success=%d", got.Status, got.SuccessCount)
+	}
+}
+
+func TestRequeueInterruptedItems_KeepsItemsWithLiveLease(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	batch := createSyncBatchWithItems(t, db, database.BatchItemStatusRunning, database.BatchItemStatusRunning)
+	db.Model(&database.SyncBatch{}).Where("id = ?", batch.ID).Update("status", database.BatchStatusRunning)
+
+	var items []database.BatchItem
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence").Find(&items)
+	fresh := time.Now()
+	stale := fresh.Add(-2 * batchItemLeaseTTL)
+	db.Model(&items[0]).Updates(map[string]interface{}{"claimed_by": "other-instance", "heartbeat_at": &fresh})
+	db.Model(&items[1]).Updates(map[string]interface{}{"claimed_by": "dead-instance", "heartbeat_at": &stale})
+
+	if !batchOwnedByLiveInstance(db, syncBatchKind, batch.ID) {
+		t.Fatal("expected batch with a fresh lease to be owned by a live instance")
+	}
+	if err := requeueInterruptedItems(db, syncBatchKind, batch.ID, nil); err != nil {
+		t.Fatalf("requeueInterruptedItems() error = %v", err)
+	}
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence").Find(&items)
+	if items[0].Status != database.BatchItemStatusRunning || items[0].ClaimedBy != "other-instance" {
+		t.Fatalf("expected live item to stay running, got %+v", items[0])
+	}
+	if items[1].Status != database.BatchItemStatusPending || items[1].ClaimedBy != "" || items[1].HeartbeatAt != nil {
+		t.Fatalf("expected expired item to be requeued, got %+v", items[1])
+	}
+
+	// 过期的租约不再代表存活实例；本实例认领的项也不算
+	db.Model(&items[0]).Update("heartbeat_at", &stale)
+	if batchOwnedByLiveInstance(db, syncBatchKind, batch.ID) {
+		t.Fatal("expected batch with only expired leases to be resumable")
+	}
+	db.Model(&items[0]).Updates(map[string]interface{}{"claimed_by": batchInstanceID, "heartbeat_at": &fresh})
+	if batchOwnedByLiveInstance(db, syncBatchKind, batch.ID) {
+		t.Fatal("expected items claimed by this instance not to block resume")
+	}
+}
+
+func TestRunBatchItems_RecordsLeaseAndSkipsStolenItems(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	batch := createSyncBatchWithItems(t, db, database.BatchItemStatusPending, database.BatchItemStatusPending)
+
+	runBatchItems(context.Background(), db, syncBatchKind, batch.ID, 1,
+		func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+			var claimed database.BatchItem
+			db.First(&claimed, item.ID)
+			if claimed.ClaimedBy != batchInstanceID || claimed.HeartbeatAt == nil {
+				t.Errorf("expected item %d to be leased by this instance, got %+v", item.ID, claimed)
+			}
+			if *item.DocumentID == 101 {
+				// 模拟租约过期后被其他实例接管
+				db.Model(&database.BatchItem{}).Where("id = ?", item.ID).Update("claimed_by", "other-instance")
+			}
+			return batchItemOutcome{Status: database.BatchItemStatusSuccess}
+		})
+
+	var items []database.BatchItem
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence").Find(&items)
+	if items[0].Status != database.BatchItemStatusSuccess {
+		t.Fatalf("expected own item to finish, got %+v", items[0])
+	}
+	if items[1].Status != database.BatchItemStatusRunning {
+		t.Fatalf("expected stolen item to be left to its new owner, got %+v", items[1])
+	}
+}
+
+func TestRequeueInterruptedItems_ReconcilesRecordedRuns(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	if err := db.AutoMigrate(&database.WorkflowRun{}, &database.DocSyncStatus{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	fake, prefectURL := prefectfake.NewTestServer(t, prefectfake.Options{
+		DefaultScript: prefectfake.Script{StartDelay: time.Hour},
+	})
+	deployment := fake.AddDeployment(prefectclient.DeploymentDetails{Name: "sync_to_mysql-deployment"})
+	prefect := prefectclient.NewClient(prefectURL, 5*time.Second)
+	flowRun, err := prefect.CreateFlowRun(context.Background(), deployment.ID, nil)
+	if err != nil {
+		t.Fatalf("CreateFlowRun() error = %v", err)
+	}
+
+	// 101 中断前已拿到 flow run ID；102 只创建了任务，无法确认 Prefect 是否已创建 flow run；103 尚未触发
+	batch := createSyncBatchWithItems(t, db, database.BatchItemStatusSuccess,
+		database.BatchItemStatusRunning, database.BatchItemStatusRunning, database.BatchItemStatusRunning)
+	db.Model(&database.SyncBatch{}).Where("id = ?", batch.ID).Update("status", database.BatchStatusRunning)
+	confirmed := database.WorkflowRun{WorkflowKey: SyncWorkflowKey, Status: WorkflowStatusPending,
+		PrefectFlowRunID: flowRun.ID, Parameters: database.JSONMap{"event_id": "evt-101"}}
+	unconfirmed := database.WorkflowRun{WorkflowKey: SyncWorkflowKey, Status: WorkflowStatusPending,
+		Parameters: database.JSONMap{"event_id": "evt-102"}}
+	db.Create(&confirmed)
+	db.Create(&unconfirmed)
+	db.Create(&database.DocSyncStatus{DocumentID: 102, LastEventID: "evt-102", LastStatus: SyncStatusPending,
+		LastWorkflowRunID: &unconfirmed.ID})
+	var items []database.BatchItem
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence").Find(&items)
+	if err := recordBatchItemRun(db, items[1].ID, confirmed.ID); err != nil {
+		t.Fatalf("recordBatchItemRun() error = %v", err)
+	}
+	if err := recordBatchItemRun(db, items[2].ID, unconfirmed.ID); err != nil {
+		t.Fatalf("recordBatchItemRun() error = %v", err)
+	}
+
+	svc := NewBatchSyncService(db, nil, NewSyncService(db, prefect, nil, ""))
+	if err := requeueInterruptedItems(db, syncBatchKind, batch.ID, func(item database.BatchItem, runID uint) (batchItemOutcome, bool) {
+		return svc.resolveInterruptedItem(context.Background(), runID)
+	}); err != nil {
+		t.Fatalf("requeueInterruptedItems() error = %v", err)
+	}
+
+	var resumed []int64
+	runBatchItems(context.Background(), db, syncBatchKind, batch.ID, 1,
+		func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+			resumed = append(resumed, *item.DocumentID)
+			return batchItemOutcome{Status: database.BatchItemStatusSuccess}
+		})
+	if len(resumed) != 1 || resumed[0] != 103 {
+		t.Fatalf("expected only the untriggered item to run again, got %v", resumed)
+	}
+
+	db.Where("batch_ref_id = ?", batch.ID).Order("sequence").Find(&items)
+	if items[1].Status != database.BatchItemStatusSuccess || items[1].Result["prefect_flow_run_id"] != flowRun.ID ||
+		items[1].Result["event_id"] != "evt-101" {
+		t.Fatalf("unexpected reconciled item: %+v", items[1])
+	}
+	if items[2].Status != database.BatchItemStatusFailed || items[2].ErrorMessage != interruptedRunError {
+		t.Fatalf("unexpected unconfirmed item: %+v", items[2])
+	}
+	var status database.DocSyncStatus
+	db.Where("document_id = ?", 102).First(&status)
+	db.First(&unconfirmed, unconfirmed.ID)
+	if status.LastStatus != SyncStatusFailed || unconfirmed.Status != WorkflowStatusFailed {
+		t.Fatalf("expected unconfirmed run to fail, got sync=%s run=%s", status.LastStatus, unconfirmed.Status)
+	}
+	if runs := len(fake.FlowRuns()); runs != 1 {
+		t.Fatalf("expected no new flow runs, got %d", runs)
+	}
+}
+
+func TestBatchRequestMetaAndCanManageBatch(t *testing.T) {
+	db := setupBatchItemsDB(t)
+	if err := db.AutoMigrate(&database.Role{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	creator := database.User{Username: "alice", PasswordHash: "x", Role: database.RoleProofreader}
+	db.Create(&creator)
+
+	meta := batchRequestMeta(db, &creator.ID)
+	if meta.UserID != "alice" || meta.UserIDNumeric != creator.ID || meta.UserRole != database.RoleProofreader {
+		t.Fatalf("unexpected resumed meta: %+v", meta)
+	}
+
+	ctx := context.Background()
+	if !canManageBatch(ctx, db, meta, &creator.ID) {
+		t.Fatal("creator should manage own batch")
+	}
+	other := RequestMeta{UserIDNumeric: creator.ID + 1, UserRole: database.RoleProofreader}
+	if canManageBatch(ctx, db, other, &creator.ID) {
+		t.Fatal("proofreader should not manage others' batches")
+	}
+	if !canManageBatch(ctx, db, RequestMeta{UserIDNumeric: 99, UserRole: database.RoleSuperAdmin}, &creator.ID) {
+		t.Fatal("batches:all should manage others' batches")
+	}
+}
@@ -0,0 +1,486 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// BatchMigrationService 文档类型迁移服务
+// 把子树中 from_type 的文档按声明的迁移升级为 to_type，每个文档写入一个新的 NDR 版本，
+// 可通过 RestoreDocumentVersion 恢复到迁移前的版本
+type BatchMigrationService struct {
+	db    *gorm.DB
+	ndr   ndrclient.Client
+	svc   *Service
+	audit *AuditService
+}
+
+// NewBatchMigrationService 创建文档类型迁移服务
+func NewBatchMigrationService(db *gorm.DB, ndr ndrclient.Client, svc *Service) *BatchMigrationService {
+	return &BatchMigrationService{
+		db:    db,
+		ndr:   ndr,
+		svc:   svc,
+		audit: NewAuditService(db),
+	}
+}
+
+// BatchMigrationPreviewRequest 类型迁移预览请求
+type BatchMigrationPreviewRequest struct {
+	FromType           string `json:"from_type"`
+	ToType             string `json:"to_type"`
+	IncludeDescendants bool   `json:"include_descendants"`
+}
+
+// MigrationPreviewItem 类型迁移预览项
+type MigrationPreviewItem struct {
+	DocumentID   int64              `json:"document_id"`
+	DocumentName string             `json:"document_name"`
+	NodeID       int64              `json:"node_id"`
+	NodePath     string             `json:"node_path"`
+	Version      *int               `json:"version_number,omitempty"` // 迁移前的版本，可用于恢复
+	CanMigrate   bool               `json:"can_migrate"`
+	SkipReason   string             `json:"skip_reason,omitempty"`
+	Issues       []jsonschema.Error `json:"issues,omitempty"` // 迁移后内容不符合目标类型 schema 的字段
+}
+
+// BatchMigrationPreviewResponse 类型迁移预览响应
+type BatchMigrationPreviewResponse struct {
+	RootNodeID     int64                  `json:"root_node_id"`
+	FromType       string                 `json:"from_type"`
+	ToType         string                 `json:"to_type"`
+	TotalDocuments int                    `json:"total_documents"`
+	CanMigrate     int                    `json:"can_migrate"`
+	WillSkip       int                    `json:"will_skip"`
+	Documents      []MigrationPreviewItem `json:"documents"`
+}
+
+// BatchMigrationExecuteRequest 类型迁移执行请求
+type BatchMigrationExecuteRequest struct {
+	FromType           string `json:"from_type"`
+	ToType             string `json:"to_type"`
+	IncludeDescendants bool   `json:"include_descendants"`
+	Concurrency        int    `json:"concurrency,omitempty"` // 并发数，默认 3
+}
+
+// BatchMigrationExecuteResponse 类型迁移执行响应
+type BatchMigrationExecuteResponse struct {
+	BatchID        string `json:"batch_id"`
+	Status         string `json:"status"`
+	TotalDocuments int    `json:"total_documents"`
+	Message        string `json:"message,omitempty"`
+}
+
+// BatchMigrationStatusResponse 类型迁移状态响应
+type BatchMigrationStatusResponse struct {
+	BatchID        string                 `json:"batch_id"`
+	RootNodeID     int64                  `json:"root_node_id"`
+	FromType       string                 `json:"from_type"`
+	ToType         string                 `json:"to_type"`
+	Status         string                 `json:"status"`
+	TotalDocuments int                    `json:"total_documents"`
+	SuccessCount   int                    `json:"success_count"`
+	FailedCount    int                    `json:"failed_count"`
+	SkippedCount   int                    `json:"skipped_count"`
+	Progress       float64                `json:"progress"` // 0-100
+	Details        map[string]interface{} `json:"details,omitempty"`
+	ErrorMessage   string                 `json:"error_message,omitempty"`
+	StartedAt      *time.Time             `json:"started_at,omitempty"`
+	FinishedAt     *time.Time             `json:"finished_at,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// PreviewBatchMigration 预览类型迁移：对每个文档试运行迁移并按目标类型 schema 校验，不写入 NDR
+func (s *BatchMigrationService) PreviewBatchMigration(
+	ctx context.Context,
+	meta RequestMeta,
+	nodeID int64,
+	req BatchMigrationPreviewRequest,
+) (*BatchMigrationPreviewResponse, error) {
+	migration, err := lookupDocumentTypeMigration(DocumentType(req.FromType), DocumentType(req.ToType))
+	if err != nil {
+		return nil, err
+	}
+	documents, err := s.collectMigrationDocuments(ctx, meta, nodeID, req.FromType, req.IncludeDescendants)
+	if err != nil {
+		return nil, err
+	}
+
+	previewItems := make([]MigrationPreviewItem, 0, len(documents))
+	canMigrateCount := 0
+	for _, doc := range documents {
+		item := MigrationPreviewItem{
+			DocumentID:   doc.Document.ID,
+			DocumentName: doc.Document.Title,
+			NodeID:       doc.NodeID,
+			NodePath:     doc.NodePath,
+			Version:      doc.Document.Version,
+		}
+		if _, err := migrateDocument(migration, doc.Document); err != nil {
+			item.SkipReason = err.Error()
+			var contentErr *DocumentContentError
+			if errors.As(err, &contentErr) {
+				item.Issues = contentErr.Issues
+			}
+		} else {
+			item.CanMigrate = true
+			canMigrateCount++
+		}
+		previewItems = append(previewItems, item)
+	}
+
+	return &BatchMigrationPreviewResponse{
+		RootNodeID:     nodeID,
+		FromType:       req.FromType,
+		ToType:         req.ToType,
+		TotalDocuments: len(documents),
+		CanMigrate:     canMigrateCount,
+		WillSkip:       len(documents) - canMigrateCount,
+		Documents:      previewItems,
+	}, nil
+}
+
+// collectMigrationDocuments 收集子树中指定类型的文档
+func (s *BatchMigrationService) collectMigrationDocuments(
+	ctx context.Context,
+	meta RequestMeta,
+	nodeID int64,
+	fromType string,
+	includeDescendants bool,
+) ([]documentWithNode, error) {
+	documents, err := collectDocuments(ctx, s.ndr, meta, nodeID, includeDescendants, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect documents: %w", err)
+	}
+	result := make([]documentWithNode, 0, len(documents))
+	for _, doc := range documents {
+		if doc.Document.Type != nil && *doc.Document.Type == fromType {
+			result = append(result, doc)
+		}
+	}
+	return result, nil
+}
+
+// migrateDocument 迁移单个文档的内容并按目标类型 schema 校验
+func migrateDocument(migration DocumentTypeMigration, doc ndrclient.Document) (map[string]any, error) {
+	content, err := migration.migrateContent(doc.Content)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateDocumentData(string(migration.To), content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// ExecuteBatchMigration 执行类型迁移
+func (s *BatchMigrationService) ExecuteBatchMigration(
+	ctx context.Context,
+	meta RequestMeta,
+	nodeID int64,
+	req BatchMigrationExecuteRequest,
+) (*BatchMigrationExecuteResponse, error) {
+	if _, err := lookupDocumentTypeMigration(DocumentType(req.FromType), DocumentType(req.ToType)); err != nil {
+		return nil, err
+	}
+	documents, err := s.collectMigrationDocuments(ctx, meta, nodeID, req.FromType, req.IncludeDescendants)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, newValidationError("no %s documents to migrate", req.FromType)
+	}
+
+	batchID := uuid.New().String()
+	batch := database.MigrationBatch{
+		BatchID:        batchID,
+		RootNodeID:     nodeID,
+		FromType:       req.FromType,
+		ToType:         req.ToType,
+		Status:         database.BatchStatusPending,
+		TotalDocuments: len(documents),
+		Options:        encodeBatchOptions(req),
+		CreatedByID:    &meta.UserIDNumeric,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return fmt.Errorf("failed to create batch record: %w", err)
+		}
+		items := make([]database.BatchItem, 0, len(documents))
+		for i, doc := range documents {
+			docID := doc.Document.ID
+			items = append(items, database.BatchItem{
+				BatchType:     database.BatchItemTypeMigration,
+				BatchRefID:    batch.ID,
+				Sequence:      i,
+				NodeID:        doc.NodeID,
+				NodePath:      doc.NodePath,
+				DocumentID:    &docID,
+				DocumentTitle: doc.Document.Title,
+				DocumentType:  req.FromType,
+				Status:        database.BatchItemStatusPending,
+			})
+		}
+		if err := tx.CreateInBatches(&items, 200).Error; err != nil {
+			return fmt.Errorf("failed to create batch items: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshBatchCounts(s.db, migrationBatchKind, batch.ID)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "migration_batch.execute",
+		ResourceType: AuditResourceMigrationBatch,
+		ResourceID:   batchID,
+		Details: map[string]interface{}{
+			"root_node_id":        nodeID,
+			"from_type":           req.FromType,
+			"to_type":             req.ToType,
+			"total_documents":     len(documents),
+			"include_descendants": req.IncludeDescendants,
+		},
+	})
+
+	go s.executeBatchMigrationAsync(context.Background(), meta, batch.ID, req)
+
+	return &BatchMigrationExecuteResponse{
+		BatchID:        batchID,
+		Status:         database.BatchStatusRunning,
+		TotalDocuments: len(documents),
+		Message:        "类型迁移已启动",
+	}, nil
+}
+
+// executeBatchMigrationAsync 异步迁移批次中尚未执行的文档
+func (s *BatchMigrationService) executeBatchMigrationAsync(
+	ctx context.Context,
+	meta RequestMeta,
+	batchID uint,
+	req BatchMigrationExecuteRequest,
+) {
+	runBatchItems(ctx, s.db, migrationBatchKind, batchID, req.Concurrency, func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+		return s.executeMigrationDocument(ctx, meta, req, item)
+	})
+}
+
+// executeMigrationDocument 迁移单个文档
+// 执行时重新读取文档，预览之后被修改或已迁移的文档按最新内容处理
+func (s *BatchMigrationService) executeMigrationDocument(
+	ctx context.Context,
+	meta RequestMeta,
+	req BatchMigrationExecuteRequest,
+	item database.BatchItem,
+) batchItemOutcome {
+	if item.DocumentID == nil {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: "missing document id"}
+	}
+	docID := *item.DocumentID
+
+	migration, err := lookupDocumentTypeMigration(DocumentType(req.FromType), DocumentType(req.ToType))
+	if err != nil {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
+
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
+	if doc.DeletedAt != nil {
+		return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: "文档已删除"}
+	}
+	if doc.Type == nil || *doc.Type != req.FromType {
+		currentType := ""
+		if doc.Type != nil {
+			currentType = *doc.Type
+		}
+		return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: fmt.Sprintf("文档类型已变更为 %s", currentType)}
+	}
+
+	content, err := migrateDocument(migration, doc)
+	if err != nil {
+		log.Printf("[batch_migration] document %d failed: %v", docID, err)
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
+
+	// 以读取时的版本作为基线写入，迁移期间被他人修改的文档不会被旧内容覆盖
+	toType := req.ToType
+	updated, err := s.svc.UpdateDocument(ctx, meta, docID, DocumentUpdateRequest{
+		Content:     content,
+		Type:        &toType,
+		BaseVersion: doc.Version,
+	})
+	var conflict *DocumentVersionConflictError
+	if errors.As(err, &conflict) {
+		return batchItemOutcome{Status: database.BatchItemStatusSkipped,
+			Reason: fmt.Sprintf("文档在迁移期间被修改（版本 %d -> %d），未迁移", conflict.BaseVersion, conflict.CurrentVersion)}
+	}
+	if err != nil {
+		log.Printf("[batch_migration] document %d failed: %v", docID, err)
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
+
+	result := map[string]interface{}{}
+	if doc.Version != nil {
+		result["previous_version"] = *doc.Version
+	}
+	if updated.Version != nil {
+		result["version_number"] = *updated.Version
+	}
+	return batchItemOutcome{Status: database.BatchItemStatusSuccess, Result: result}
+}
+
+// ResumeUnfinishedBatches 恢复服务重启前未执行完的类型迁移
+// 仍由其他存活实例执行的批次不会恢复
+func (s *BatchMigrationService) ResumeUnfinishedBatches(ctx context.Context) {
+	var batches []database.MigrationBatch
+	if err := s.db.Where("status IN ?", []string{database.BatchStatusPending, database.BatchStatusRunning}).
+		Order("id ASC").Find(&batches).Error; err != nil {
+		log.Printf("[batch_migration] failed to load unfinished batches: %v", err)
+		return
+	}
+
+	for _, batch := range batches {
+		if batchOwnedByLiveInstance(s.db, migrationBatchKind, batch.ID) {
+			log.Printf("[batch_migration] batch %d is still owned by a live instance, skipped", batch.ID)
+			continue
+		}
+		if err := requeueInterruptedItems(s.db, migrationBatchKind, batch.ID, nil); err != nil {
+			log.Printf("[batch_migration] batch %d: failed to requeue items: %v", batch.ID, err)
+			continue
+		}
+
+		var req BatchMigrationExecuteRequest
+		if err := decodeBatchOptions(batch.Options, &req); err != nil {
+			log.Printf("[batch_migration] batch %d: invalid options: %v", batch.ID, err)
+			continue
+		}
+
+		log.Printf("[batch_migration] resuming batch %s (id=%d)", batch.BatchID, batch.ID)
+		go s.executeBatchMigrationAsync(ctx, batchRequestMeta(s.db, batch.CreatedByID), batch.ID, req)
+	}
+}
+
+// CancelBatchMigration 取消类型迁移，尚未开始的文档不再迁移
+func (s *BatchMigrationService) CancelBatchMigration(ctx context.Context, meta RequestMeta, batchID string) error {
+	batch, err := s.getBatch(batchID)
+	if err != nil {
+		return err
+	}
+	if !canManageBatch(ctx, s.db, meta, batch.CreatedByID) {
+		return ErrBatchForbidden
+	}
+	if err := cancelBatch(s.db, migrationBatchKind, batch.ID); err != nil {
+		return err
+	}
+	refreshBatchCounts(s.db, migrationBatchKind, batch.ID)
+	log.Printf("[batch_migration] batch %s cancelled by user %d", batchID, meta.UserIDNumeric)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "migration_batch.cancel",
+		ResourceType: AuditResourceMigrationBatch,
+		ResourceID:   batchID,
+	})
+	return nil
+}
+
+func (s *BatchMigrationService) getBatch(batchID string) (*database.MigrationBatch, error) {
+	var batch database.MigrationBatch
+	if err := s.db.Where("batch_id = ?", batchID).First(&batch).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+		}
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// GetBatchMigrationStatus 获取类型迁移状态，详情中包含每个文档迁移前后的版本号
+// 只有创建者或角色拥有 batches:all 的用户可以查看
+func (s *BatchMigrationService) GetBatchMigrationStatus(ctx context.Context, meta RequestMeta, batchID string) (*BatchMigrationStatusResponse, error) {
+	batch, err := s.getBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageBatch(ctx, s.db, meta, batch.CreatedByID) {
+		return nil, ErrBatchForbidden
+	}
+	status := migrationBatchStatus(*batch)
+	if details, ok := batchResultDetails(s.db, migrationBatchKind, batch.ID, syncBatchItemResult); ok {
+		status.Details = details
+	}
+	return &status, nil
+}
+
+// ListBatchMigrations 列出类型迁移批次
+func (s *BatchMigrationService) ListBatchMigrations(
+	ctx context.Context,
+	meta RequestMeta,
+	limit int,
+	offset int,
+) ([]BatchMigrationStatusResponse, int64, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var total int64
+	query := s.db.Model(&database.MigrationBatch{})
+
+	// 角色没有 batches:all 时只能看到自己创建的批次
+	if !seesAllBatches(ctx, s.db, meta) {
+		query = query.Where("created_by_id = ?", meta.UserIDNumeric)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var batches []database.MigrationBatch
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&batches).Error; err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]BatchMigrationStatusResponse, 0, len(batches))
+	for _, batch := range batches {
+		results = append(results, migrationBatchStatus(batch))
+	}
+	return results, total, nil
+}
+
+func migrationBatchStatus(batch database.MigrationBatch) BatchMigrationStatusResponse {
+	var progress float64
+	if batch.TotalDocuments > 0 {
+		completed := batch.SuccessCount + batch.FailedCount + batch.SkippedCount
+		progress = float64(completed) / float64(batch.TotalDocuments) * 100
+	}
+	return BatchMigrationStatusResponse{
+		BatchID:        batch.BatchID,
+		RootNodeID:     batch.RootNodeID,
+		FromType:       batch.FromType,
+		ToType:         batch.ToType,
+		Status:         batch.Status,
+		TotalDocuments: batch.TotalDocuments,
+		SuccessCount:   batch.SuccessCount,
+		FailedCount:    batch.FailedCount,
+		SkippedCount:   batch.SkippedCount,
+		Progress:       progress,
+		ErrorMessage:   batch.ErrorMessage,
+		StartedAt:      batch.StartedAt,
+		FinishedAt:     batch.FinishedAt,
+		CreatedAt:      batch.CreatedAt,
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// registerChoiceV2Migration 注册 comprehensive_choice_v2（选项的 content 改名为 text）及其迁移
+func registerChoiceV2Migration(t *testing.T) {
+	t.Helper()
+	v1 := documentTypeDefinitions["comprehensive_choice_v1"]
+	setCustomDocumentTypes([]DocumentTypeDefinition{{
+		ID:            "comprehensive_choice_v2",
+		Label:         "综合知识选择题(v2)",
+		ContentFormat: ContentFormatYAML,
+		Schema:        strings.ReplaceAll(v1.Schema, `"content"`, `"text"`),
+		Custom:        true,
+	}})
+
+	documentTypeMigrationsMu.Lock()
+	saved := documentTypeMigrations
+	documentTypeMigrationsMu.Unlock()
+	t.Cleanup(func() {
+		setCustomDocumentTypes(nil)
+		documentTypeMigrationsMu.Lock()
+		documentTypeMigrations = saved
+		documentTypeMigrationsMu.Unlock()
+	})
+
+	err := RegisterDocumentTypeMigration(DocumentTypeMigration{
+		From: "comprehensive_choice_v1",
+		To:   "comprehensive_choice_v2",
+		Steps: []MigrationStep{
+			{Op: MigrationOpRename, Path: "body.sub_questions[].options[].content", To: "text"},
+			{Op: MigrationOpSetDefault, Path: "meta.schema_version", Value: "2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterDocumentTypeMigration() error = %v", err)
+	}
+}
+
+func TestDocumentTypeMigration_MigrateContent(t *testing.T) {
+	registerChoiceV2Migration(t)
+
+	migration, err := lookupDocumentTypeMigration("comprehensive_choice_v1", "comprehensive_choice_v2")
+	if err != nil {
+		t.Fatalf("lookupDocumentTypeMigration() error = %v", err)
+	}
+	template := documentTypeDefinitions["comprehensive_choice_v1"].Template
+	content, err := migrateDocument(migration, ndrclient.Document{
+		Content: map[string]any{"format": "yaml", "data": template, "preview": "keep"},
+	})
+	if err != nil {
+		t.Fatalf("migrateDocument() error = %v", err)
+	}
+	data := content["data"].(string)
+	if !strings.HasPrefix(data, "---\nid: 0\n") || !strings.Contains(data, "schema_version: 2\n---\n") {
+		t.Fatalf("expected front matter to be kept and extended, got:\n%s", data)
+	}
+	if !strings.Contains(data, "text: 选项A") || strings.Contains(data, "content: 选项A") {
+		t.Fatalf("expected options to be renamed, got:\n%s", data)
+	}
+	if !strings.Contains(data, "title: |-\n") {
+		t.Fatalf("expected literal block style to be kept, got:\n%s", data)
+	}
+	if content["preview"] != "keep" {
+		t.Fatalf("expected other content keys to be kept, got %v", content)
+	}
+
+	if _, err := lookupDocumentTypeMigration("comprehensive_choice_v1", "case_analysis_v1"); !errors.Is(err, ErrDocumentTypeMigrationNotFound) {
+		t.Fatalf("expected ErrDocumentTypeMigrationNotFound, got %v", err)
+	}
+	if err := RegisterDocumentTypeMigration(DocumentTypeMigration{
+		From: "a_v1", To: "a_v2", Steps: []MigrationStep{{Op: "explode", Path: "body"}},
+	}); err == nil {
+		t.Fatal("expected unknown op to be rejected")
+	}
+}
+
+func TestMigrationStep_MapToList(t *testing.T) {
+	root, issue := parseDocumentData(ContentFormatYAML, "options:\n  A: 甲\n  B: 乙\nanswer: A\n")
+	if issue != nil {
+		t.Fatalf("parseDocumentData() issue = %v", issue)
+	}
+	step := MigrationStep{Op: MigrationOpMapToList, Path: "body.options", KeyField: "key", ValueField: "content"}
+	if err := step.apply(root); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	// 已经是列表时不再转换
+	if err := step.apply(root); err != nil {
+		t.Fatalf("second apply() error = %v", err)
+	}
+	data, err := encodeDocumentData(ContentFormatYAML, root)
+	if err != nil {
+		t.Fatalf("encodeDocumentData() error = %v", err)
+	}
+	want := "options:\n  - key: A\n    content: 甲\n  - key: B\n    content: 乙\nanswer: A\n"
+	if data != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestBatchMigrationService_PreviewAndExecute(t *testing.T) {
+	registerChoiceV2Migration(t)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.MigrationBatch{}, &database.BatchItem{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	v1, other := "comprehensive_choice_v1", "markdown_v1"
+	template := documentTypeDefinitions["comprehensive_choice_v1"].Template
+	version := 3
+	valid := ndrclient.Document{ID: 11, Title: "选择题", Type: &v1, Version: &version,
+		Content: map[string]any{"format": "yaml", "data": template}}
+	invalid := ndrclient.Document{ID: 13, Title: "缺少答案", Type: &v1,
+		Content: map[string]any{"format": "yaml", "data": "title: 题干\nsub_questions: []\n"}}
+
+	ndr := newFakeNDR()
+	ndr.getNodes[1] = ndrclient.Node{ID: 1, Path: "/course"}
+	ndr.nodeDocsResp = []ndrclient.Document{valid, {ID: 12, Type: &other}, invalid}
+	svc := NewBatchMigrationService(db, ndr, NewService(cache.NewNoop(), ndr, nil))
+	ctx := context.Background()
+
+	preview, err := svc.PreviewBatchMigration(ctx, RequestMeta{}, 1, BatchMigrationPreviewRequest{
+		FromType: "comprehensive_choice_v1", ToType: "comprehensive_choice_v2",
+	})
+	if err != nil {
+		t.Fatalf("PreviewBatchMigration() error = %v", err)
+	}
+	if preview.TotalDocuments != 2 || preview.CanMigrate != 1 || preview.WillSkip != 1 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	if skipped := preview.Documents[1]; skipped.CanMigrate || len(skipped.Issues) == 0 || skipped.Issues[0].Path != "body.sub_questions" {
+		t.Fatalf("expected schema issues for document 13, got %+v", skipped)
+	}
+	if len(ndr.updatedDocs) != 0 {
+		t.Fatal("preview must not write documents")
+	}
+
+	ndr.nodeDocsResp = []ndrclient.Document{valid}
+	ndr.getDocResp = valid
+	newVersion := 4
+	ndr.updateDocResp = ndrclient.Document{ID: 11, Version: &newVersion}
+	owner := RequestMeta{UserIDNumeric: 1, UserRole: "proofreader"}
+	resp, err := svc.ExecuteBatchMigration(ctx, owner, 1, BatchMigrationExecuteRequest{
+		FromType: "comprehensive_choice_v1", ToType: "comprehensive_choice_v2", Concurrency: 1,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteBatchMigration() error = %v", err)
+	}
+
+	var status *BatchMigrationStatusResponse
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		status, err = svc.GetBatchMigrationStatus(ctx, owner, resp.BatchID)
+		if err != nil {
+			t.Fatalf("GetBatchMigrationStatus() error = %v", err)
+		}
+		if status.Status == database.BatchStatusCompleted || status.Status == database.BatchStatusFailed {
+			break
+		}
+	}
+	if status.Status != database.BatchStatusCompleted || status.SuccessCount != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if _, err := svc.GetBatchMigrationStatus(ctx, RequestMeta{UserIDNumeric: 2, UserRole: "proofreader"}, resp.BatchID); !errors.Is(err, ErrBatchForbidden) {
+		t.Fatalf("expected ErrBatchForbidden for another user, got %v", err)
+	}
+	if _, err := svc.GetBatchMigrationStatus(ctx, RequestMeta{UserIDNumeric: 2, UserRole: "super_admin"}, resp.BatchID); err != nil {
+		t.Fatalf("expected batches:all to see the batch, got %v", err)
+	}
+	if _, total, err := svc.ListBatchMigrations(ctx, RequestMeta{UserIDNumeric: 2, UserRole: "proofreader"}, 10, 0); err != nil || total != 0 {
+		t.Fatalf("expected other users to see no batches, got total=%d err=%v", total, err)
+	}
+	result := status.Details["document_results"].([]map[string]interface{})[0]
+	if result["previous_version"] != float64(3) {
+		t.Fatalf("expected previous version to be recorded, got %v", result)
+	}
+
+	if len(ndr.updatedDocs) != 1 {
+		t.Fatalf("expected one document update, got %d", len(ndr.updatedDocs))
+	}
+	update := ndr.updatedDocs[0].Body
+	if update.Type == nil || *update.Type != "comprehensive_choice_v2" || !strings.Contains(update.Content["data"].(string), "text: 选项A") {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+	if update.BaseVersion == nil || *update.BaseVersion != 3 {
+		t.Fatalf("expected the update to be based on the version read, got %v", update.BaseVersion)
+	}
+
+	// 读取之后文档被他人修改：NDR 以 412 拒绝写入，该项跳过并记录原因
+	ndr.updateDocErr = &ndrclient.Error{StatusCode: http.StatusPreconditionFailed, Status: "412 Precondition Failed"}
+	docID := int64(11)
+	outcome := svc.executeMigrationDocument(ctx, owner, BatchMigrationExecuteRequest{
+		FromType: "comprehensive_choice_v1", ToType: "comprehensive_choice_v2",
+	}, database.BatchItem{DocumentID: &docID})
+	if outcome.Status != database.BatchItemStatusSkipped || !strings.Contains(outcome.Reason, "迁移期间被修改") {
+		t.Fatalf("expected a version conflict to skip the document, got %+v", outcome)
+	}
+	ndr.updateDocErr = nil
+
+	if _, err := svc.ExecuteBatchMigration(ctx, RequestMeta{}, 1, BatchMigrationExecuteRequest{
+		FromType: "case_analysis_v1", ToType: "comprehensive_choice_v2",
+	}); !errors.Is(err, ErrDocumentTypeMigrationNotFound) {
+		t.Fatalf("expected ErrDocumentTypeMigrationNotFound, got %v", err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"net/url"
 	"slices"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +14,7 @@ import (
 
 	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
 )
 
 // BatchSyncService 批量同步服务
@@ -22,6 +22,7 @@ type BatchSyncService struct {
 	db          *gorm.DB
 	ndr         ndrclient.Client
 	syncService *SyncService
+	audit       *AuditService
 }
 
 // NewBatchSyncService 创建批量同步服务
@@ -30,6 +31,7 @@ func NewBatchSyncService(db *gorm.DB, ndr ndrclient.Client, syncSvc *SyncService
 		db:          db,
 		ndr:         ndr,
 		syncService: syncSvc,
+		audit:       NewAuditService(db),
 	}
 }
 
@@ -107,7 +109,7 @@ func (s *BatchSyncService) PreviewBatchSync(
 	req BatchSyncPreviewRequest,
 ) (*BatchSyncPreviewResponse, error) {
 	// 收集所有目标文档
-	documents, err := s.collectDocuments(ctx, meta, nodeID, req.IncludeDescendants, req.SkipDocTypes)
+	documents, err := collectDocuments(ctx, s.ndr, meta, nodeID, req.IncludeDescendants, req.SkipDocTypes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to collect documents: %w", err)
 	}
@@ -159,9 +161,10 @@ func (s *BatchSyncService) PreviewBatchSync(
 	}, nil
 }
 
-// collectDocuments 递归收集节点下的所有文档（排除源文档）
-func (s *BatchSyncService) collectDocuments(
+// collectDocuments 递归收集节点下的所有文档（排除源文档），批量同步与类型迁移共用
+func collectDocuments(
 	ctx context.Context,
+	ndr ndrclient.Client,
 	meta RequestMeta,
 	nodeID int64,
 	includeDescendants bool,
@@ -170,17 +173,17 @@ func (s *BatchSyncService) collectDocuments(
 	result := make([]documentWithNode, 0)
 
 	// 获取当前节点信息
-	node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), nodeID, ndrclient.GetNodeOptions{})
+	node, err := ndr.GetNode(ctx, toNDRMeta(meta), nodeID, ndrclient.GetNodeOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("get node %d: %w", nodeID, err)
 	}
 
 	// 获取当前节点的源文档 ID 列表（用于排除）
 	sourceDocIDs := make(map[int64]bool)
-	sourceDocs, err := s.ndr.ListSourceDocuments(ctx, toNDRMeta(meta), nodeID)
+	sourceDocs, err := ndr.ListSourceDocuments(ctx, toNDRMeta(meta), nodeID)
 	if err != nil {
 		// 源文档获取失败不阻塞流程，仅记录日志
-		log.Printf("[batch_sync] warning: failed to get source documents for node %d: %v", nodeID, err)
+		log.Printf("[batch] warning: failed to get source documents for node %d: %v", nodeID, err)
 	} else {
 		for _, sd := range sourceDocs {
 			sourceDocIDs[sd.DocumentID] = true
@@ -188,7 +191,7 @@ func (s *BatchSyncService) collectDocuments(
 	}
 
 	// 获取当前节点的文档
-	docs, err := s.getNodeDocuments(ctx, meta, nodeID)
+	docs, err := getNodeDocuments(ctx, ndr, meta, nodeID)
 	if err != nil {
 		return nil, fmt.Errorf("get documents for node %d: %w", nodeID, err)
 	}
@@ -220,7 +223,7 @@ func (s *BatchSyncService) collectDocuments(
 	}
 
 	// 递归获取子节点的文档
-	children, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), nodeID, ndrclient.ListChildrenParams{})
+	children, err := ndr.ListChildren(ctx, toNDRMeta(meta), nodeID, ndrclient.ListChildrenParams{})
 	if err != nil {
 		return nil, fmt.Errorf("list children of %d: %w", nodeID, err)
 	}
@@ -229,7 +232,7 @@ func (s *BatchSyncService) collectDocuments(
 		if child.DeletedAt != nil {
 			continue // 跳过已删除的节点
 		}
-		childDocs, err := s.collectDocuments(ctx, meta, child.ID, true, skipDocTypes)
+		childDocs, err := collectDocuments(ctx, ndr, meta, child.ID, true, skipDocTypes)
 		if err != nil {
 			return nil, err
 		}
@@ -240,34 +243,15 @@ func (s *BatchSyncService) collectDocuments(
 }
 
 // getNodeDocuments 获取节点的文档（不包含子孙节点）
-func (s *BatchSyncService) getNodeDocuments(
+func getNodeDocuments(
 	ctx context.Context,
+	ndr ndrclient.Client,
 	meta RequestMeta,
 	nodeID int64,
 ) ([]ndrclient.Document, error) {
 	query := url.Values{}
 	query.Set("include_descendants", "false")
-	query.Set("size", "100")
-
-	page := 1
-	docs := make([]ndrclient.Document, 0)
-
-	for {
-		query.Set("page", fmt.Sprintf("%d", page))
-		docsPage, err := s.ndr.ListNodeDocuments(ctx, toNDRMeta(meta), nodeID, query)
-		if err != nil {
-			return nil, err
-		}
-
-		docs = append(docs, docsPage.Items...)
-
-		if len(docsPage.Items) < 100 || (docsPage.Total > 0 && len(docs) >= docsPage.Total) {
-			break
-		}
-		page++
-	}
-
-	return docs, nil
+	return ndr.ListNodeDocumentsAll(ctx, toNDRMeta(meta), nodeID, query)
 }
 
 // ExecuteBatchSync 执行批量同步
@@ -278,7 +262,7 @@ func (s *BatchSyncService) ExecuteBatchSync(
 	req BatchSyncExecuteRequest,
 ) (*BatchSyncExecuteResponse, error) {
 	// 收集所有目标文档
-	documents, err := s.collectDocuments(ctx, meta, nodeID, req.IncludeDescendants, req.SkipDocTypes)
+	documents, err := collectDocuments(ctx, s.ndr, meta, nodeID, req.IncludeDescendants, req.SkipDocTypes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to collect documents: %w", err)
 	}
@@ -287,22 +271,48 @@ func (s *BatchSyncService) ExecuteBatchSync(
 		return nil, fmt.Errorf("no documents to sync")
 	}
 
-	// 创建批次记录
+	// 创建批次记录及逐项记录
+	// sync_target 检查在此完成，未配置或配置错误的文档直接落库为 skipped / failed
 	batchID := uuid.New().String()
 	batch := database.SyncBatch{
 		BatchID:        batchID,
 		RootNodeID:     nodeID,
 		Status:         database.BatchStatusPending,
 		TotalDocuments: len(documents),
+		Options:        encodeBatchOptions(req),
 		CreatedByID:    &meta.UserIDNumeric,
 	}
 
-	if err := s.db.Create(&batch).Error; err != nil {
-		return nil, fmt.Errorf("failed to create batch record: %w", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return fmt.Errorf("failed to create batch record: %w", err)
+		}
+		items := make([]database.BatchItem, 0, len(documents))
+		for i, doc := range documents {
+			items = append(items, newSyncBatchItem(batch.ID, i, doc))
+		}
+		if err := tx.CreateInBatches(&items, 200).Error; err != nil {
+			return fmt.Errorf("failed to create batch items: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	refreshBatchCounts(s.db, syncBatchKind, batch.ID)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "sync_batch.execute",
+		ResourceType: AuditResourceSyncBatch,
+		ResourceID:   batchID,
+		Details: map[string]interface{}{
+			"root_node_id":        nodeID,
+			"total_documents":     len(documents),
+			"include_descendants": req.IncludeDescendants,
+		},
+	})
 
 	// 启动异步执行
-	go s.executeBatchSyncAsync(context.Background(), meta, batch.ID, documents, req)
+	go s.executeBatchSyncAsync(context.Background(), meta, batch.ID, req)
 
 	return &BatchSyncExecuteResponse{
 		BatchID:        batchID,
@@ -312,130 +322,165 @@ func (s *BatchSyncService) ExecuteBatchSync(
 	}, nil
 }
 
-// executeBatchSyncAsync 异步执行批量同步
+// newSyncBatchItem 为文档创建执行项，并根据 sync_target 预先判定是否跳过
+func newSyncBatchItem(batchID uint, sequence int, d documentWithNode) database.BatchItem {
+	docType := ""
+	if d.Document.Type != nil {
+		docType = *d.Document.Type
+	}
+	docID := d.Document.ID
+	item := database.BatchItem{
+		BatchType:     database.BatchItemTypeSync,
+		BatchRefID:    batchID,
+		Sequence:      sequence,
+		NodeID:        d.NodeID,
+		NodePath:      d.NodePath,
+		DocumentID:    &docID,
+		DocumentTitle: d.Document.Title,
+		DocumentType:  docType,
+		Status:        database.BatchItemStatusPending,
+	}
+
+	syncTarget, err := parseSyncTarget(d.Document.Metadata)
+	if err != nil {
+		now := time.Now()
+		item.Status = database.BatchItemStatusFailed
+		item.ErrorMessage = fmt.Sprintf("sync_target 配置错误: %v", err)
+		item.FinishedAt = &now
+	} else if syncTarget == nil {
+		now := time.Now()
+		item.Status = database.BatchItemStatusSkipped
+		item.Reason = "未配置 sync_target"
+		item.FinishedAt = &now
+	}
+	return item
+}
+
+// executeBatchSyncAsync 异步执行批量同步中尚未执行的文档
 func (s *BatchSyncService) executeBatchSyncAsync(
 	ctx context.Context,
 	meta RequestMeta,
 	batchID uint,
-	documents []documentWithNode,
 	req BatchSyncExecuteRequest,
 ) {
-	// 更新状态为运行中
-	now := time.Now()
-	s.db.Model(&database.SyncBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
-		"status":     database.BatchStatusRunning,
-		"started_at": &now,
+	runBatchItems(ctx, s.db, syncBatchKind, batchID, req.Concurrency, func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+		return s.executeBatchDocument(ctx, meta, item)
 	})
+}
 
-	// 设置并发数
-	concurrency := req.Concurrency
-	if concurrency <= 0 {
-		concurrency = DefaultBatchConcurrency
-	}
-	if concurrency > MaxBatchConcurrency {
-		concurrency = MaxBatchConcurrency
+// executeBatchDocument 同步单个文档
+func (s *BatchSyncService) executeBatchDocument(ctx context.Context, meta RequestMeta, item database.BatchItem) batchItemOutcome {
+	if item.DocumentID == nil {
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: "missing document id"}
 	}
+	docID := *item.DocumentID
 
-	// 使用信号量控制并发
-	semaphore := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	successCount := 0
-	failedCount := 0
-	skippedCount := 0
-	details := make(map[string]interface{})
-	docResults := make([]map[string]interface{}, 0, len(documents))
-
-	for _, doc := range documents {
-		wg.Add(1)
-		semaphore <- struct{}{} // 获取信号量
-
-		go func(d documentWithNode) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // 释放信号量
+	resp, err := s.syncService.triggerSync(ctx, meta, docID, func(runID uint) error {
+		return recordBatchItemRun(s.db, item.ID, runID)
+	})
+	if err != nil {
+		log.Printf("[batch_sync] document %d failed: %v", docID, err)
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
 
-			docType := ""
-			if d.Document.Type != nil {
-				docType = *d.Document.Type
-			}
+	log.Printf("[batch_sync] document %d success, event_id=%s", docID, resp.EventID)
+	return batchItemOutcome{
+		Status: database.BatchItemStatusSuccess,
+		Result: map[string]interface{}{
+			"event_id":            resp.EventID,
+			"prefect_flow_run_id": resp.PrefectFlowRunID,
+		},
+	}
+}
 
-			result := map[string]interface{}{
-				"document_id":   d.Document.ID,
-				"document_name": d.Document.Title,
-				"document_type": docType,
-				"node_id":       d.NodeID,
-				"node_path":     d.NodePath,
-			}
+// resolveInterruptedItem 中断前已创建任务的文档按任务在 Prefect 中的状态结束，不再重复触发
+func (s *BatchSyncService) resolveInterruptedItem(ctx context.Context, runID uint) (batchItemOutcome, bool) {
+	var prefect *prefectclient.Client
+	if s.syncService != nil && s.syncService.prefectEnabled {
+		prefect = s.syncService.prefect
+	}
+	return interruptedRunOutcome(ctx, s.db, prefect, runID, func(run *database.WorkflowRun) map[string]interface{} {
+		return map[string]interface{}{
+			"event_id":            run.Parameters["event_id"],
+			"prefect_flow_run_id": run.PrefectFlowRunID,
+		}
+	})
+}
 
-			// 检查 sync_target 配置
-			syncTarget, err := parseSyncTarget(d.Document.Metadata)
-			if err != nil {
-				mu.Lock()
-				failedCount++
-				result["status"] = "failed"
-				result["error"] = fmt.Sprintf("sync_target 配置错误: %v", err)
-				docResults = append(docResults, result)
-				mu.Unlock()
-				return
-			}
-			if syncTarget == nil {
-				mu.Lock()
-				skippedCount++
-				result["status"] = "skipped"
-				result["reason"] = "未配置 sync_target"
-				docResults = append(docResults, result)
-				mu.Unlock()
-				return
-			}
+// ResumeUnfinishedBatches 恢复服务重启前未执行完的批量同步
+// 租约已过期的中断项中，已创建任务的与 Prefect 对账，其余重新置为 pending；已取消或仍由其他存活实例执行的批次不会恢复
+func (s *BatchSyncService) ResumeUnfinishedBatches(ctx context.Context) {
+	var batches []database.SyncBatch
+	if err := s.db.Where("status IN ?", []string{database.BatchStatusPending, database.BatchStatusRunning}).
+		Order("id ASC").Find(&batches).Error; err != nil {
+		log.Printf("[batch_sync] failed to load unfinished batches: %v", err)
+		return
+	}
 
-			// 执行同步
-			resp, err := s.syncService.TriggerSync(ctx, meta, d.Document.ID)
-			if err != nil {
-				mu.Lock()
-				failedCount++
-				result["status"] = "failed"
-				result["error"] = err.Error()
-				docResults = append(docResults, result)
-				mu.Unlock()
-				log.Printf("[batch_sync] document %d failed: %v", d.Document.ID, err)
-				return
-			}
+	for _, batch := range batches {
+		if batchOwnedByLiveInstance(s.db, syncBatchKind, batch.ID) {
+			log.Printf("[batch_sync] batch %d is still owned by a live instance, skipped", batch.ID)
+			continue
+		}
+		if !hasBatchItems(s.db, syncBatchKind, batch.ID) {
+			failLegacyBatch(s.db, syncBatchKind, batch.ID)
+			continue
+		}
+		if err := requeueInterruptedItems(s.db, syncBatchKind, batch.ID, func(item database.BatchItem, runID uint) (batchItemOutcome, bool) {
+			return s.resolveInterruptedItem(ctx, runID)
+		}); err != nil {
+			log.Printf("[batch_sync] batch %d: failed to requeue items: %v", batch.ID, err)
+			continue
+		}
 
-			mu.Lock()
-			successCount++
-			result["status"] = "success"
-			result["event_id"] = resp.EventID
-			result["prefect_flow_run_id"] = resp.PrefectFlowRunID
-			docResults = append(docResults, result)
-			mu.Unlock()
+		var req BatchSyncExecuteRequest
+		if err := decodeBatchOptions(batch.Options, &req); err != nil {
+			log.Printf("[batch_sync] batch %d: invalid options: %v", batch.ID, err)
+			continue
+		}
 
-			log.Printf("[batch_sync] document %d success, event_id=%s", d.Document.ID, resp.EventID)
-		}(doc)
+		log.Printf("[batch_sync] resuming batch %s (id=%d)", batch.BatchID, batch.ID)
+		go s.executeBatchSyncAsync(ctx, batchRequestMeta(s.db, batch.CreatedByID), batch.ID, req)
 	}
+}
 
-	wg.Wait()
-
-	// 更新批次记录
-	finishedAt := time.Now()
-	finalStatus := database.BatchStatusCompleted
-	if failedCount > 0 && successCount == 0 {
-		finalStatus = database.BatchStatusFailed
+// CancelBatchSync 取消批量同步，尚未开始的文档不再同步
+func (s *BatchSyncService) CancelBatchSync(ctx context.Context, meta RequestMeta, batchID string) error {
+	var batch database.SyncBatch
+	if err := s.db.Where("batch_id = ?", batchID).First(&batch).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+		}
+		return fmt.Errorf("failed to get batch: %w", err)
 	}
-
-	details["document_results"] = docResults
-
-	s.db.Model(&database.SyncBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
-		"status":        finalStatus,
-		"success_count": successCount,
-		"failed_count":  failedCount,
-		"skipped_count": skippedCount,
-		"details":       database.JSONMap(details),
-		"finished_at":   &finishedAt,
+	if !canManageBatch(ctx, s.db, meta, batch.CreatedByID) {
+		return ErrBatchForbidden
+	}
+	if err := cancelBatch(s.db, syncBatchKind, batch.ID); err != nil {
+		return err
+	}
+	refreshBatchCounts(s.db, syncBatchKind, batch.ID)
+	log.Printf("[batch_sync] batch %s cancelled by user %d", batchID, meta.UserIDNumeric)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "sync_batch.cancel",
+		ResourceType: AuditResourceSyncBatch,
+		ResourceID:   batchID,
 	})
+	return nil
+}
 
-	log.Printf("[batch_sync] batch %d completed: success=%d, failed=%d, skipped=%d",
-		batchID, successCount, failedCount, skippedCount)
+// syncBatchItemResult 生成单个文档的结果（兼容旧版 document_results 结构）
+func syncBatchItemResult(item database.BatchItem) map[string]interface{} {
+	result := map[string]interface{}{
+		"document_name": item.DocumentTitle,
+		"document_type": item.DocumentType,
+		"node_id":       item.NodeID,
+		"node_path":     item.NodePath,
+	}
+	if item.DocumentID != nil {
+		result["document_id"] = *item.DocumentID
+	}
+	return result
 }
 
 // GetBatchSyncStatus 获取批量同步状态
@@ -446,11 +491,17 @@ func (s *BatchSyncService) GetBatchSyncStatus(
 	var batch database.SyncBatch
 	if err := s.db.Where("batch_id = ?", batchID).First(&batch).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("batch not found: %s", batchID)
+			return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
 		}
 		return nil, fmt.Errorf("failed to get batch: %w", err)
 	}
 
+	// 优先使用逐项记录生成详情，执行过程中即可看到每个文档的结果
+	details := map[string]interface{}(batch.Details)
+	if itemDetails, ok := batchResultDetails(s.db, syncBatchKind, batch.ID, syncBatchItemResult); ok {
+		details = itemDetails
+	}
+
 	// 计算进度
 	var progress float64
 	if batch.TotalDocuments > 0 {
@@ -467,7 +518,7 @@ func (s *BatchSyncService) GetBatchSyncStatus(
 		FailedCount:    batch.FailedCount,
 		SkippedCount:   batch.SkippedCount,
 		Progress:       progress,
-		Details:        batch.Details,
+		Details:        details,
 		ErrorMessage:   batch.ErrorMessage,
 		StartedAt:      batch.StartedAt,
 		FinishedAt:     batch.FinishedAt,
@@ -492,8 +543,8 @@ func (s *BatchSyncService) ListBatchSyncs(
 	var total int64
 	query := s.db.Model(&database.SyncBatch{})
 
-	// 非超级管理员只能看到自己创建的批次
-	if meta.UserRole != "super_admin" {
+	// 没有 batches:all 权限时只能看到自己创建的批次
+	if !seesAllBatches(ctx, s.db, meta) {
 		query = query.Where("created_by_id = ?", meta.UserIDNumeric)
 	}
 
@@ -3,13 +3,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"slices"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +16,7 @@ import (
 
 	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
 )
 
 const (
@@ -34,6 +34,7 @@ type BatchWorkflowService struct {
 	db              *gorm.DB
 	ndr             ndrclient.Client
 	workflowService *WorkflowService
+	audit           *AuditService
 }
 
 // NewBatchWorkflowService 创建批量工作流服务
@@ -42,6 +43,7 @@ func NewBatchWorkflowService(db *gorm.DB, ndr ndrclient.Client, workflowSvc *Wor
 		db:              db,
 		ndr:             ndr,
 		workflowService: workflowSvc,
+		audit:           NewAuditService(db),
 	}
 }
 
@@ -301,29 +303,15 @@ func (s *BatchWorkflowService) nodeHasOutputDocuments(
 		sourceSet[id] = struct{}{}
 	}
 
-	const pageSize = 100
-	page := 1
-	for {
-		query := url.Values{}
-		query.Set("include_descendants", "false")
-		query.Set("page", strconv.Itoa(page))
-		query.Set("size", strconv.Itoa(pageSize))
-
-		docsPage, err := s.ndr.ListNodeDocuments(ctx, toNDRMeta(meta), nodeID, query)
+	query := url.Values{}
+	query.Set("include_descendants", "false")
+	for doc, err := range s.ndr.StreamNodeDocuments(ctx, toNDRMeta(meta), nodeID, query) {
 		if err != nil {
 			return false, err
 		}
-		for _, doc := range docsPage.Items {
-			if _, ok := sourceSet[doc.ID]; !ok {
-				return true, nil // 找到非源文档
-			}
-		}
-
-		fetched := docsPage.Page * docsPage.Size
-		if len(docsPage.Items) == 0 || fetched >= docsPage.Total {
-			break
+		if _, ok := sourceSet[doc.ID]; !ok {
+			return true, nil // 找到非源文档
 		}
-		page++
 	}
 
 	return false, nil
@@ -336,11 +324,15 @@ func (s *BatchWorkflowService) ExecuteBatchWorkflow(
 	nodeID int64,
 	req BatchWorkflowExecuteRequest,
 ) (*BatchWorkflowExecuteResponse, error) {
-	// 1. 验证工作流存在
-	_, err := s.workflowService.GetWorkflowDefinition(ctx, req.WorkflowKey)
+	// 1. 验证工作流存在，并在启动前校验参数，避免每个节点都以同样的参数错误失败
+	def, err := s.workflowService.GetWorkflowDefinition(ctx, req.WorkflowKey)
 	if err != nil {
 		return nil, fmt.Errorf("invalid workflow: %w", err)
 	}
+	req.Parameters, err = normalizeWorkflowParameters(def, req.Parameters, nodeWorkflowReservedParams)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. 收集所有目标节点
 	nodes, err := s.collectNodes(ctx, meta, nodeID, req.IncludeDescendants, 0)
@@ -352,7 +344,7 @@ func (s *BatchWorkflowService) ExecuteBatchWorkflow(
 		return nil, fmt.Errorf("no nodes to execute")
 	}
 
-	// 3. 创建批次记录
+	// 3. 创建批次记录及逐项记录
 	batchID := uuid.New().String()
 	batch := database.WorkflowBatch{
 		BatchID:     batchID,
@@ -360,15 +352,49 @@ func (s *BatchWorkflowService) ExecuteBatchWorkflow(
 		RootNodeID:  nodeID,
 		Status:      database.BatchStatusPending,
 		TotalNodes:  len(nodes),
+		Options:     encodeBatchOptions(req),
 		CreatedByID: &meta.UserIDNumeric,
 	}
 
-	if err := s.db.Create(&batch).Error; err != nil {
-		return nil, fmt.Errorf("failed to create batch record: %w", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return fmt.Errorf("failed to create batch record: %w", err)
+		}
+		items := make([]database.BatchItem, 0, len(nodes))
+		for i, node := range nodes {
+			items = append(items, database.BatchItem{
+				BatchType:  database.BatchItemTypeWorkflow,
+				BatchRefID: batch.ID,
+				Sequence:   i,
+				NodeID:     node.ID,
+				NodeName:   node.Name,
+				NodePath:   node.Path,
+				Status:     database.BatchItemStatusPending,
+			})
+		}
+		if err := tx.CreateInBatches(&items, 200).Error; err != nil {
+			return fmt.Errorf("failed to create batch items: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "workflow_batch.execute",
+		ResourceType: AuditResourceWorkflowBatch,
+		ResourceID:   batchID,
+		Details: map[string]interface{}{
+			"workflow_key":        req.WorkflowKey,
+			"root_node_id":        nodeID,
+			"total_nodes":         len(nodes),
+			"include_descendants": req.IncludeDescendants,
+		},
+	})
+
 	// 4. 启动异步执行
-	go s.executeBatchAsync(context.Background(), meta, batch.ID, nodes, req)
+	go s.executeBatchAsync(context.Background(), meta, batch.ID, req)
 
 	return &BatchWorkflowExecuteResponse{
 		BatchID:    batchID,
@@ -378,21 +404,13 @@ func (s *BatchWorkflowService) ExecuteBatchWorkflow(
 	}, nil
 }
 
-// executeBatchAsync 异步执行批量工作流
+// executeBatchAsync 异步执行批量工作流中尚未执行的节点
 func (s *BatchWorkflowService) executeBatchAsync(
 	ctx context.Context,
 	meta RequestMeta,
 	batchID uint,
-	nodes []nodeWithDepth,
 	req BatchWorkflowExecuteRequest,
 ) {
-	// 更新状态为运行中
-	now := time.Now()
-	s.db.Model(&database.WorkflowBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
-		"status":     database.BatchStatusRunning,
-		"started_at": &now,
-	})
-
 	// 设置并发数
 	concurrency := req.Concurrency
 	if concurrency <= 0 {
@@ -403,179 +421,190 @@ func (s *BatchWorkflowService) executeBatchAsync(
 			concurrency = DefaultBatchConcurrency
 		}
 	}
-	if concurrency > MaxBatchConcurrency {
-		concurrency = MaxBatchConcurrency
-	}
-
-	// 使用信号量控制并发
-	semaphore := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
 
-	successCount := 0
-	failedCount := 0
-	skippedCount := 0
-	details := make(map[string]interface{})
-	nodeResults := make([]map[string]interface{}, 0, len(nodes))
-
-	for _, node := range nodes {
-		wg.Add(1)
-		semaphore <- struct{}{} // 获取信号量
-
-		go func(n nodeWithDepth) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // 释放信号量
-
-			result := map[string]interface{}{
-				"node_id":   n.ID,
-				"node_name": n.Name,
-				"node_path": n.Path,
-			}
+	runBatchItems(ctx, s.db, workflowBatchKind, batchID, concurrency, func(ctx context.Context, item database.BatchItem) batchItemOutcome {
+		return s.executeBatchNode(ctx, meta, item, req)
+	})
+}
 
-			// 检查节点名是否包含指定字符串（支持逗号分隔的多个关键词）
-			if req.SkipNameContains != "" {
-				matched := ""
-				for _, p := range strings.Split(req.SkipNameContains, ",") {
-					p = strings.TrimSpace(p)
-					if p != "" && strings.Contains(n.Name, p) {
-						matched = p
-						break
-					}
-				}
-				if matched != "" {
-					mu.Lock()
-					skippedCount++
-					result["status"] = "skipped"
-					result["reason"] = fmt.Sprintf("节点名包含「%s」", matched)
-					nodeResults = append(nodeResults, result)
-					mu.Unlock()
-					return
-				}
+// executeBatchNode 对单个节点执行跳过检查并触发工作流
+func (s *BatchWorkflowService) executeBatchNode(
+	ctx context.Context,
+	meta RequestMeta,
+	item database.BatchItem,
+	req BatchWorkflowExecuteRequest,
+) batchItemOutcome {
+	// 检查节点名是否包含指定字符串（支持逗号分隔的多个关键词）
+	if req.SkipNameContains != "" {
+		for _, p := range strings.Split(req.SkipNameContains, ",") {
+			p = strings.TrimSpace(p)
+			if p != "" && strings.Contains(item.NodeName, p) {
+				return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: fmt.Sprintf("节点名包含「%s」", p)}
 			}
+		}
+	}
 
-			// 检查是否需要跳过无源文档的节点
-			// SkipNoOutput 也需要源文档集合用于"产出文档"判断，因此这里统一预取源文档
-			var sourceDocIDs []int64
-			if req.SkipNoSource || req.SkipNoOutput || len(req.SkipDocTypes) > 0 {
-				sources, err := s.ndr.ListSourceDocuments(ctx, toNDRMeta(meta), n.ID)
-				if err != nil {
-					mu.Lock()
-					failedCount++
-					result["status"] = "failed"
-					result["error"] = fmt.Sprintf("获取源文档失败: %v", err)
-					nodeResults = append(nodeResults, result)
-					mu.Unlock()
-					return
-				}
-
-				// 过滤掉指定类型的源文档
-				if len(req.SkipDocTypes) > 0 {
-					filteredSources := make([]ndrclient.SourceDocument, 0, len(sources))
-					for _, src := range sources {
-						docType := ""
-						if src.Document != nil && src.Document.Type != nil {
-							docType = *src.Document.Type
-						}
-						if !slices.Contains(req.SkipDocTypes, docType) {
-							filteredSources = append(filteredSources, src)
-						}
-					}
-					sources = filteredSources
-				}
+	// 检查是否需要跳过无源文档的节点
+	// SkipNoOutput 也需要源文档集合用于"产出文档"判断，因此这里统一预取源文档
+	var sourceDocIDs []int64
+	if req.SkipNoSource || req.SkipNoOutput || len(req.SkipDocTypes) > 0 {
+		sources, err := s.ndr.ListSourceDocuments(ctx, toNDRMeta(meta), item.NodeID)
+		if err != nil {
+			return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: fmt.Sprintf("获取源文档失败: %v", err)}
+		}
 
-				if req.SkipNoSource && len(sources) == 0 {
-					mu.Lock()
-					skippedCount++
-					result["status"] = "skipped"
-					result["reason"] = "无源文档"
-					nodeResults = append(nodeResults, result)
-					mu.Unlock()
-					return
+		// 过滤掉指定类型的源文档
+		if len(req.SkipDocTypes) > 0 {
+			filteredSources := make([]ndrclient.SourceDocument, 0, len(sources))
+			for _, src := range sources {
+				docType := ""
+				if src.Document != nil && src.Document.Type != nil {
+					docType = *src.Document.Type
 				}
-				// 保存源文档 ID，传递给 TriggerWorkflow 避免重复查询
-				sourceDocIDs = make([]int64, len(sources))
-				for i, src := range sources {
-					sourceDocIDs[i] = src.DocumentID
+				if !slices.Contains(req.SkipDocTypes, docType) {
+					filteredSources = append(filteredSources, src)
 				}
 			}
+			sources = filteredSources
+		}
 
-			// 检查是否需要跳过无产出文档的节点
-			if req.SkipNoOutput {
-				hasOutput, err := s.nodeHasOutputDocuments(ctx, meta, n.ID, sourceDocIDs)
-				if err != nil {
-					mu.Lock()
-					failedCount++
-					result["status"] = "failed"
-					result["error"] = fmt.Sprintf("获取产出文档失败: %v", err)
-					nodeResults = append(nodeResults, result)
-					mu.Unlock()
-					return
-				}
-				if !hasOutput {
-					mu.Lock()
-					skippedCount++
-					result["status"] = "skipped"
-					result["reason"] = "无产出文档"
-					nodeResults = append(nodeResults, result)
-					mu.Unlock()
-					return
-				}
-			}
+		if req.SkipNoSource && len(sources) == 0 {
+			return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: "无源文档"}
+		}
+		// 保存源文档 ID，传递给 TriggerWorkflow 避免重复查询
+		sourceDocIDs = make([]int64, len(sources))
+		for i, src := range sources {
+			sourceDocIDs[i] = src.DocumentID
+		}
+	}
 
-			// 执行工作流
-			triggerReq := TriggerWorkflowRequest{
-				NodeID:       n.ID,
-				WorkflowKey:  req.WorkflowKey,
-				Parameters:   req.Parameters,
-				SourceDocIDs: sourceDocIDs,
-			}
+	// 检查是否需要跳过无产出文档的节点
+	if req.SkipNoOutput {
+		hasOutput, err := s.nodeHasOutputDocuments(ctx, meta, item.NodeID, sourceDocIDs)
+		if err != nil {
+			return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: fmt.Sprintf("获取产出文档失败: %v", err)}
+		}
+		if !hasOutput {
+			return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: "无产出文档"}
+		}
+	}
 
-			resp, err := s.workflowService.TriggerWorkflow(ctx, meta, triggerReq)
-			if err != nil {
-				mu.Lock()
-				failedCount++
-				result["status"] = "failed"
-				result["error"] = err.Error()
-				nodeResults = append(nodeResults, result)
-				mu.Unlock()
-				log.Printf("[batch_workflow] node %d failed: %v", n.ID, err)
-				return
-			}
+	// 执行工作流
+	triggerReq := TriggerWorkflowRequest{
+		NodeID:       item.NodeID,
+		WorkflowKey:  req.WorkflowKey,
+		Parameters:   req.Parameters,
+		SourceDocIDs: sourceDocIDs,
+		onRunCreated: func(runID uint) error {
+			return recordBatchItemRun(s.db, item.ID, runID)
+		},
+	}
 
-			mu.Lock()
-			successCount++
-			result["status"] = "success"
-			result["run_id"] = resp.RunID
-			result["prefect_flow_run_id"] = resp.PrefectFlowRunID
-			nodeResults = append(nodeResults, result)
-			mu.Unlock()
+	resp, err := s.workflowService.TriggerWorkflow(ctx, meta, triggerReq)
+	if errors.Is(err, ErrResourceLocked) {
+		return batchItemOutcome{Status: database.BatchItemStatusSkipped, Reason: "节点已被锁定: " + err.Error()}
+	}
+	if err != nil {
+		log.Printf("[batch_workflow] node %d failed: %v", item.NodeID, err)
+		return batchItemOutcome{Status: database.BatchItemStatusFailed, Error: err.Error()}
+	}
 
-			log.Printf("[batch_workflow] node %d success, run_id=%d", n.ID, resp.RunID)
-		}(node)
+	log.Printf("[batch_workflow] node %d success, run_id=%d", item.NodeID, resp.RunID)
+	return batchItemOutcome{
+		Status: database.BatchItemStatusSuccess,
+		Result: map[string]interface{}{
+			"run_id":              resp.RunID,
+			"prefect_flow_run_id": resp.PrefectFlowRunID,
+		},
 	}
+}
 
-	wg.Wait()
+// resolveInterruptedItem 中断前已创建任务的节点按任务在 Prefect 中的状态结束，不再重复触发
+func (s *BatchWorkflowService) resolveInterruptedItem(ctx context.Context, runID uint) (batchItemOutcome, bool) {
+	var prefect *prefectclient.Client
+	if s.workflowService != nil && s.workflowService.prefectEnabled {
+		prefect = s.workflowService.prefect
+	}
+	return interruptedRunOutcome(ctx, s.db, prefect, runID, func(run *database.WorkflowRun) map[string]interface{} {
+		return map[string]interface{}{
+			"run_id":              run.ID,
+			"prefect_flow_run_id": run.PrefectFlowRunID,
+		}
+	})
+}
 
-	// 更新批次记录
-	finishedAt := time.Now()
-	finalStatus := database.BatchStatusCompleted
-	if failedCount > 0 && successCount == 0 {
-		finalStatus = database.BatchStatusFailed
+// ResumeUnfinishedBatches 恢复服务重启前未执行完的批量工作流
+// 租约已过期的中断项中，已创建任务的与 Prefect 对账，其余重新置为 pending；已取消或仍由其他存活实例执行的批次不会恢复
+func (s *BatchWorkflowService) ResumeUnfinishedBatches(ctx context.Context) {
+	var batches []database.WorkflowBatch
+	if err := s.db.Where("status IN ?", []string{database.BatchStatusPending, database.BatchStatusRunning}).
+		Order("id ASC").Find(&batches).Error; err != nil {
+		log.Printf("[batch_workflow] failed to load unfinished batches: %v", err)
+		return
 	}
 
-	details["node_results"] = nodeResults
+	for _, batch := range batches {
+		if batchOwnedByLiveInstance(s.db, workflowBatchKind, batch.ID) {
+			log.Printf("[batch_workflow] batch %d is still owned by a live instance, skipped", batch.ID)
+			continue
+		}
+		if !hasBatchItems(s.db, workflowBatchKind, batch.ID) {
+			failLegacyBatch(s.db, workflowBatchKind, batch.ID)
+			continue
+		}
+		if err := requeueInterruptedItems(s.db, workflowBatchKind, batch.ID, func(item database.BatchItem, runID uint) (batchItemOutcome, bool) {
+			return s.resolveInterruptedItem(ctx, runID)
+		}); err != nil {
+			log.Printf("[batch_workflow] batch %d: failed to requeue items: %v", batch.ID, err)
+			continue
+		}
+
+		var req BatchWorkflowExecuteRequest
+		if err := decodeBatchOptions(batch.Options, &req); err != nil {
+			log.Printf("[batch_workflow] batch %d: invalid options: %v", batch.ID, err)
+			continue
+		}
+		if req.WorkflowKey == "" {
+			req.WorkflowKey = batch.WorkflowKey
+		}
+
+		log.Printf("[batch_workflow] resuming batch %s (id=%d)", batch.BatchID, batch.ID)
+		go s.executeBatchAsync(ctx, batchRequestMeta(s.db, batch.CreatedByID), batch.ID, req)
+	}
+}
 
-	s.db.Model(&database.WorkflowBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
-		"status":        finalStatus,
-		"success_count": successCount,
-		"failed_count":  failedCount,
-		"skipped_count": skippedCount,
-		"details":       database.JSONMap(details),
-		"finished_at":   &finishedAt,
+// CancelBatchWorkflow 取消批量工作流，尚未开始的节点不再执行
+func (s *BatchWorkflowService) CancelBatchWorkflow(ctx context.Context, meta RequestMeta, batchID string) error {
+	var batch database.WorkflowBatch
+	if err := s.db.Where("batch_id = ?", batchID).First(&batch).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+		}
+		return fmt.Errorf("failed to get batch: %w", err)
+	}
+	if !canManageBatch(ctx, s.db, meta, batch.CreatedByID) {
+		return ErrBatchForbidden
+	}
+	if err := cancelBatch(s.db, workflowBatchKind, batch.ID); err != nil {
+		return err
+	}
+	refreshBatchCounts(s.db, workflowBatchKind, batch.ID)
+	log.Printf("[batch_workflow] batch %s cancelled by user %d", batchID, meta.UserIDNumeric)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "workflow_batch.cancel",
+		ResourceType: AuditResourceWorkflowBatch,
+		ResourceID:   batchID,
 	})
+	return nil
+}
 
-	log.Printf("[batch_workflow] batch %d completed: success=%d, failed=%d, skipped=%d",
-		batchID, successCount, failedCount, skippedCount)
+// workflowBatchItemResult 生成单个节点的结果（兼容旧版 node_results 结构）
+func workflowBatchItemResult(item database.BatchItem) map[string]interface{} {
+	return map[string]interface{}{
+		"node_id":   item.NodeID,
+		"node_name": item.NodeName,
+		"node_path": item.NodePath,
+	}
 }
 
 // GetBatchWorkflowStatus 获取批量工作流状态
@@ -586,11 +615,17 @@ func (s *BatchWorkflowService) GetBatchWorkflowStatus(
 	var batch database.WorkflowBatch
 	if err := s.db.Where("batch_id = ?", batchID).First(&batch).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("batch not found: %s", batchID)
+			return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
 		}
 		return nil, fmt.Errorf("failed to get batch: %w", err)
 	}
 
+	// 优先使用逐项记录生成详情，执行过程中即可看到每个节点的结果
+	details := map[string]interface{}(batch.Details)
+	if itemDetails, ok := batchResultDetails(s.db, workflowBatchKind, batch.ID, workflowBatchItemResult); ok {
+		details = itemDetails
+	}
+
 	// 计算进度
 	var progress float64
 	if batch.TotalNodes > 0 {
@@ -608,7 +643,7 @@ func (s *BatchWorkflowService) GetBatchWorkflowStatus(
 		FailedCount:  batch.FailedCount,
 		SkippedCount: batch.SkippedCount,
 		Progress:     progress,
-		Details:      batch.Details,
+		Details:      details,
 		ErrorMessage: batch.ErrorMessage,
 		StartedAt:    batch.StartedAt,
 		FinishedAt:   batch.FinishedAt,
@@ -633,8 +668,8 @@ func (s *BatchWorkflowService) ListBatchWorkflows(
 	var total int64
 	query := s.db.Model(&database.WorkflowBatch{})
 
-	// 非超级管理员只能看到自己创建的批次
-	if meta.UserRole != "super_admin" {
+	// 没有 batches:all 权限时只能看到自己创建的批次
+	if !seesAllBatches(ctx, s.db, meta) {
 		query = query.Where("created_by_id = ?", meta.UserIDNumeric)
 	}
 
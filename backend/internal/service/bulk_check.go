@@ -57,7 +57,7 @@ func (s *Service) CheckCategoryDependencies(ctx context.Context, meta RequestMet
 			return CategoryCheckResponse{}, err
 		}
 
-		page, err := s.ndr.ListNodeDocuments(ctx, ndrMeta, id, query)
+		docs, err := s.ndr.ListNodeDocumentsAll(ctx, ndrMeta, id, query)
 		if err != nil {
 			return CategoryCheckResponse{}, err
 		}
@@ -67,7 +67,7 @@ func (s *Service) CheckCategoryDependencies(ctx context.Context, meta RequestMet
 			Name:               node.Name,
 			Path:               node.Path,
 			HasChildren:        hasChildren,
-			DocumentCount:      len(page.Items),
+			DocumentCount:      len(docs),
 			IncludeDescendants: req.IncludeDescendants,
 		}
 
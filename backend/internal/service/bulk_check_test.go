@@ -40,6 +40,10 @@ func (f *bulkCheckFakeNDR) ListNodeDocuments(ctx context.Context, meta ndrclient
 	}, nil
 }
 
+func (f *bulkCheckFakeNDR) ListNodeDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamNodeDocuments(ctx, f, meta, id, query))
+}
+
 func TestCheckCategoryDependencies(t *testing.T) {
 	fake := newBulkCheckFakeNDR()
 	now := sampleTime()
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
 )
 
@@ -132,6 +133,7 @@ type CategoryBulkCopyRequest struct {
 	TargetParentID *int64  `json:"target_parent_id"`
 	InsertBeforeID *int64  `json:"insert_before_id,omitempty"`
 	InsertAfterID  *int64  `json:"insert_after_id,omitempty"`
+	DryRun         bool    `json:"dry_run,omitempty"` // 只返回预期的树变化，不执行
 }
 
 // CategoryBulkMoveRequest describes moving multiple nodes to a new parent with optional anchor.
@@ -140,6 +142,7 @@ type CategoryBulkMoveRequest struct {
 	TargetParentID *int64  `json:"target_parent_id"`
 	InsertBeforeID *int64  `json:"insert_before_id,omitempty"`
 	InsertAfterID  *int64  `json:"insert_after_id,omitempty"`
+	DryRun         bool    `json:"dry_run,omitempty"` // 只返回预期的树变化，不执行
 }
 
 // CategoryRepositionRequest describes moving + reordering in a single call.
@@ -191,13 +194,19 @@ type ListCategoriesParams struct {
 
 // GetCategory returns a single node by ID.
 func (s *Service) GetCategory(ctx context.Context, meta RequestMeta, id int64, includeDeleted bool) (Category, error) {
-	var opts ndrclient.GetNodeOptions
-	if includeDeleted {
-		opts.IncludeDeleted = ptr(true)
-	}
-	node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), id, opts)
-	if err != nil {
-		return Category{}, fmt.Errorf("get node: %w", err)
+	cacheKey := nodeCacheKey(nodesGeneration(ctx, s.cache), id, includeDeleted)
+	var node ndrclient.Node
+	if !cacheGetJSON(ctx, s.cache, cacheKey, &node) {
+		var opts ndrclient.GetNodeOptions
+		if includeDeleted {
+			opts.IncludeDeleted = ptr(true)
+		}
+		var err error
+		node, err = s.ndr.GetNode(ctx, toNDRMeta(meta), id, opts)
+		if err != nil {
+			return Category{}, fmt.Errorf("get node: %w", err)
+		}
+		cacheSetJSON(ctx, s.cache, cacheKey, node)
 	}
 	category := mapNode(node, nil)
 	return *category, nil
@@ -248,9 +257,15 @@ func (s *Service) CreateCategory(ctx context.Context, meta RequestMeta, req Cate
 		log.Printf("[category] create node failed name=%q err=%v", req.Name, err)
 		return Category{}, fmt.Errorf("create node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
 
 	category := mapNode(node, req.ParentID)
 	log.Printf("[category] created node id=%d path=%s position=%d", category.ID, category.Path, category.Position)
+	s.recordAudit(ctx, meta, "category.create", AuditResourceCategory, category.ID, map[string]interface{}{
+		"name":      req.Name,
+		"parent_id": req.ParentID,
+		"path":      category.Path,
+	})
 	return *category, nil
 }
 
@@ -287,9 +302,18 @@ func (s *Service) UpdateCategory(ctx context.Context, meta RequestMeta, id int64
 		log.Printf("[category] update node failed id=%d err=%v", id, err)
 		return Category{}, fmt.Errorf("update node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
 
 	category := mapNode(node, nil)
 	log.Printf("[category] updated node id=%d path=%s position=%d", category.ID, category.Path, category.Position)
+	details := map[string]interface{}{"path": category.Path}
+	if hasName {
+		details["name"] = *req.Name
+	}
+	if req.TypeSpecified {
+		details["type"] = req.Type
+	}
+	s.recordAudit(ctx, meta, "category.update", AuditResourceCategory, id, details)
 	return *category, nil
 }
 
@@ -300,10 +324,15 @@ func (s *Service) DeleteCategory(ctx context.Context, meta RequestMeta, id int64
 	log.Printf("[category] delete id=%d force=%v", id, force)
 
 	if force {
-		if err := s.verifyAdminPassword(meta, *req.AdminPassword); err != nil {
+		if err := s.verifyAdminPassword(ctx, meta, *req.AdminPassword); err != nil {
+			return err
+		}
+		rootNodeID := s.auditCourse(ctx, AuditResourceCategory, id)
+		if err := s.deleteCategoryRecursive(ctx, meta, id); err != nil {
 			return err
 		}
-		return s.deleteCategoryRecursive(ctx, meta, id)
+		s.recordAuditInCourse(ctx, meta, "category.delete", AuditResourceCategory, id, rootNodeID, map[string]interface{}{"force": true})
+		return nil
 	}
 
 	hasChildren, err := s.ndr.HasChildren(ctx, toNDRMeta(meta), id)
@@ -314,19 +343,22 @@ func (s *Service) DeleteCategory(ctx context.Context, meta RequestMeta, id int64
 	if hasChildren {
 		return ErrCategoryHasChildren
 	}
+	rootNodeID := s.auditCourse(ctx, AuditResourceCategory, id)
 	if err := s.ndr.DeleteNode(ctx, toNDRMeta(meta), id); err != nil {
 		log.Printf("[category] delete node failed id=%d err=%v", id, err)
 		return fmt.Errorf("delete node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
+	s.recordAuditInCourse(ctx, meta, "category.delete", AuditResourceCategory, id, rootNodeID, nil)
 	return nil
 }
 
 // verifyAdminPassword checks if the provided password matches the current user's password.
-func (s *Service) verifyAdminPassword(meta RequestMeta, password string) error {
+func (s *Service) verifyAdminPassword(ctx context.Context, meta RequestMeta, password string) error {
 	if strings.TrimSpace(password) == "" {
 		return ErrInvalidAdminPassword
 	}
-	if meta.UserRole != "super_admin" || meta.UserIDNumeric == 0 {
+	if meta.UserIDNumeric == 0 || !s.roleHasPermission(ctx, meta, database.PermNodePurge) {
 		return ErrForceDeleteForbidden
 	}
 	if s.userService == nil {
@@ -361,6 +393,7 @@ func (s *Service) deleteCategoryRecursive(ctx context.Context, meta RequestMeta,
 		log.Printf("[category] delete node failed id=%d err=%v", id, err)
 		return fmt.Errorf("delete node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
 	log.Printf("[category] deleted node id=%d", id)
 	return nil
 }
@@ -379,35 +412,29 @@ func (s *Service) deleteCategoryDocuments(ctx context.Context, meta RequestMeta,
 		sourceDocIDs[src.DocumentID] = struct{}{}
 	}
 
+	// 先取全部文档再删除，边翻页边删除会让后续页的文档前移而被跳过
 	query := url.Values{}
 	query.Set("include_descendants", "false")
-	page := 1
-	pageSize := 100
-	skippedSourceDocs := 0
+	docs, err := s.ndr.ListNodeDocumentsAll(ctx, toNDRMeta(meta), nodeID, query)
+	if err != nil {
+		log.Printf("[category] list node documents failed id=%d err=%v", nodeID, err)
+		return fmt.Errorf("list node documents: %w", err)
+	}
 
-	for {
-		query.Set("page", fmt.Sprintf("%d", page))
-		query.Set("size", fmt.Sprintf("%d", pageSize))
-		docsPage, err := s.ndr.ListNodeDocuments(ctx, toNDRMeta(meta), nodeID, query)
-		if err != nil {
-			log.Printf("[category] list node documents failed id=%d err=%v", nodeID, err)
-			return fmt.Errorf("list node documents: %w", err)
-		}
-		for _, doc := range docsPage.Items {
-			if _, isSource := sourceDocIDs[doc.ID]; isSource {
-				skippedSourceDocs++
-				continue
-			}
-			if err := s.ndr.DeleteDocument(ctx, toNDRMeta(meta), doc.ID); err != nil {
-				log.Printf("[category] delete document failed node=%d doc=%d err=%v", nodeID, doc.ID, err)
-				return fmt.Errorf("delete document %d: %w", doc.ID, err)
-			}
-			log.Printf("[category] deleted document id=%d from node=%d", doc.ID, nodeID)
+	skippedSourceDocs := 0
+	for _, doc := range docs {
+		if _, isSource := sourceDocIDs[doc.ID]; isSource {
+			skippedSourceDocs++
+			continue
 		}
-		if len(docsPage.Items) == 0 || len(docsPage.Items) < pageSize {
-			break
+		if err := s.ndr.DeleteDocument(ctx, toNDRMeta(meta), doc.ID); err != nil {
+			log.Printf("[category] delete document failed node=%d doc=%d err=%v", nodeID, doc.ID, err)
+			return fmt.Errorf("delete document %d: %w", doc.ID, err)
 		}
-		page++
+		invalidateDocuments(ctx, s.cache, doc.ID)
+		s.reindexDocuments(doc.ID)
+		s.markDocumentRemoved(ctx, doc.ID, database.ReferenceTargetDeleted)
+		log.Printf("[category] deleted document id=%d from node=%d", doc.ID, nodeID)
 	}
 
 	if skippedSourceDocs > 0 {
@@ -416,6 +443,35 @@ func (s *Service) deleteCategoryDocuments(ctx context.Context, meta RequestMeta,
 	return nil
 }
 
+// reindexSubtree 节点移动、恢复或复制后子树内文档所属的课程可能改变，通知检索索引刷新
+// 失败时只记录日志，由检索的定期对账补齐
+func (s *Service) reindexSubtree(ctx context.Context, meta RequestMeta, nodeID int64) {
+	if s.indexer == nil {
+		return
+	}
+	docIDs, err := s.subtreeDocumentIDs(ctx, meta, nodeID)
+	if err != nil {
+		log.Printf("[category] list subtree documents for reindex failed node=%d err=%v", nodeID, err)
+		return
+	}
+	s.reindexDocuments(docIDs...)
+}
+
+// subtreeDocumentIDs 返回节点及其全部后代节点上绑定的文档 ID
+func (s *Service) subtreeDocumentIDs(ctx context.Context, meta RequestMeta, nodeID int64) ([]int64, error) {
+	query := url.Values{}
+	query.Set("include_descendants", "true")
+	docs, err := s.ndr.ListNodeDocumentsAll(ctx, toNDRMeta(meta), nodeID, query)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
 // RestoreCategory reactivates a soft-deleted node.
 func (s *Service) RestoreCategory(ctx context.Context, meta RequestMeta, id int64) (Category, error) {
 	log.Printf("[category] restore id=%d", id)
@@ -424,8 +480,11 @@ func (s *Service) RestoreCategory(ctx context.Context, meta RequestMeta, id int6
 		log.Printf("[category] restore node failed id=%d err=%v", id, err)
 		return Category{}, fmt.Errorf("restore node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
+	s.reindexSubtree(ctx, meta, id)
 	category := mapNode(node, nil)
 	log.Printf("[category] restored node id=%d path=%s", category.ID, category.Path)
+	s.recordAudit(ctx, meta, "category.restore", AuditResourceCategory, id, map[string]interface{}{"path": category.Path})
 	return *category, nil
 }
 
@@ -456,9 +515,15 @@ func (s *Service) MoveCategory(ctx context.Context, meta RequestMeta, id int64,
 		log.Printf("[category] move node failed id=%d err=%v", id, err)
 		return Category{}, fmt.Errorf("move node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
+	s.reindexSubtree(ctx, meta, id)
 
 	category := mapNode(node, req.NewParentID)
 	log.Printf("[category] moved node id=%d new_parent=%v position=%d", category.ID, category.ParentID, category.Position)
+	s.recordAudit(ctx, meta, "category.move", AuditResourceCategory, id, map[string]interface{}{
+		"new_parent_id": req.NewParentID,
+		"path":          category.Path,
+	})
 	return *category, nil
 }
 
@@ -471,34 +536,23 @@ func (s *Service) GetCategoryTree(ctx context.Context, meta RequestMeta, include
 	}
 
 	nodes := make([]ndrclient.Node, 0)
-	total := 0
 
-	for {
-		page, err := s.ndr.ListNodes(ctx, toNDRMeta(meta), params)
+	// 缓存的是未经权限过滤的完整节点列表，过滤在下方按用户进行
+	cacheKey := categoryTreeCacheKey(nodesGeneration(ctx, s.cache), includeDeleted)
+	if !cacheGetJSON(ctx, s.cache, cacheKey, &nodes) {
+		var err error
+		nodes, err = s.ndr.ListNodesAll(ctx, toNDRMeta(meta), params)
 		if err != nil {
-			log.Printf("[category] list nodes failed page=%d err=%v", params.Page, err)
+			log.Printf("[category] list nodes failed err=%v", err)
 			return nil, fmt.Errorf("list nodes: %w", err)
 		}
-		if total == 0 {
-			total = page.Total
-		}
-		nodes = append(nodes, page.Items...)
-
-		pageSize := page.Size
-		if pageSize == 0 {
-			pageSize = params.Size
-		}
-
-		if (total != 0 && len(nodes) >= total) || len(page.Items) == 0 || len(page.Items) < pageSize {
-			break
-		}
-		params.Page++
+		cacheSetJSON(ctx, s.cache, cacheKey, nodes)
 	}
 
 	tree := buildTree(nodes)
 
-	// 课程管理员和校对员权限过滤：只显示其被授权的课程（根节点）及其子节点
-	if (meta.UserRole == "course_admin" || meta.UserRole == "proofreader") && meta.UserIDNumeric > 0 {
+	// 按课程授权的角色：只显示其被授权的课程（根节点）及其子节点
+	if courseScoped(ctx, s.roles(), meta) {
 		authorizedRootNodes, err := s.userService.GetUserCourses(meta.UserIDNumeric)
 		if err != nil {
 			log.Printf("[category] failed to get user courses: %v", err)
@@ -525,7 +579,7 @@ func (s *Service) GetCategoryTree(ctx context.Context, meta RequestMeta, include
 		return filteredTree, nil
 	}
 
-	log.Printf("[category] tree aggregated total=%d fetched=%d roots=%d", total, len(nodes), len(tree))
+	log.Printf("[category] tree aggregated fetched=%d roots=%d", len(nodes), len(tree))
 	return tree, nil
 }
 
@@ -536,36 +590,21 @@ func (s *Service) GetDeletedCategories(ctx context.Context, meta RequestMeta) ([
 	deleted := make([]Category, 0)
 	total := 0
 
-	for {
-		page, err := s.ndr.ListNodes(ctx, toNDRMeta(meta), params)
+	for node, err := range s.ndr.StreamNodes(ctx, toNDRMeta(meta), params) {
 		if err != nil {
-			log.Printf("[category] trash list nodes failed page=%d err=%v", params.Page, err)
+			log.Printf("[category] trash list nodes failed err=%v", err)
 			return nil, fmt.Errorf("list nodes: %w", err)
 		}
-		if total == 0 {
-			total = page.Total
-		}
-		for i := range page.Items {
-			node := page.Items[i]
-			if node.DeletedAt == nil {
-				continue
-			}
-			cat := mapNode(node, node.ParentID)
-			deleted = append(deleted, *cat)
-		}
-
-		pageSize := page.Size
-		if pageSize == 0 {
-			pageSize = params.Size
-		}
-		if (total != 0 && params.Page*pageSize >= total) || len(page.Items) == 0 || len(page.Items) < pageSize {
-			break
+		total++
+		if node.DeletedAt == nil {
+			continue
 		}
-		params.Page++
+		cat := mapNode(node, node.ParentID)
+		deleted = append(deleted, *cat)
 	}
 
-	// 课程管理员和校对员权限过滤：只显示其被授权的课程下的已删除节点
-	if (meta.UserRole == "course_admin" || meta.UserRole == "proofreader") && meta.UserIDNumeric > 0 {
+	// 按课程授权的角色：只显示其被授权的课程下的已删除节点
+	if courseScoped(ctx, s.roles(), meta) {
 		authorizedRootNodes, err := s.userService.GetUserCourses(meta.UserIDNumeric)
 		if err != nil {
 			log.Printf("[category] failed to get user courses for trash: %v", err)
@@ -608,10 +647,19 @@ func (s *Service) GetDeletedCategories(ctx context.Context, meta RequestMeta) ([
 // PurgeCategory permanently deletes a node in NDR.
 func (s *Service) PurgeCategory(ctx context.Context, meta RequestMeta, id int64) error {
 	log.Printf("[category] purge id=%d", id)
+	// 彻底删除后无法再按节点查询文档，先记下子树内的文档
+	var docIDs []int64
+	if s.indexer != nil {
+		docIDs, _ = s.subtreeDocumentIDs(ctx, meta, id)
+	}
+	rootNodeID := s.auditCourse(ctx, AuditResourceCategory, id)
 	if err := s.ndr.PurgeNode(ctx, toNDRMeta(meta), id); err != nil {
 		log.Printf("[category] purge node failed id=%d err=%v", id, err)
 		return fmt.Errorf("purge node: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
+	s.reindexDocuments(docIDs...)
+	s.recordAuditInCourse(ctx, meta, "category.purge", AuditResourceCategory, id, rootNodeID, nil)
 	return nil
 }
 
@@ -631,6 +679,7 @@ func (s *Service) ReorderCategories(ctx context.Context, meta RequestMeta, req C
 		log.Printf("[category] reorder failed parent=%v err=%v", req.ParentID, err)
 		return nil, fmt.Errorf("reorder nodes: %w", err)
 	}
+	invalidateNodes(ctx, s.cache)
 
 	categories := make([]Category, 0, len(nodes))
 	for i := range nodes {
@@ -638,6 +687,12 @@ func (s *Service) ReorderCategories(ctx context.Context, meta RequestMeta, req C
 		categories = append(categories, *cat)
 	}
 	log.Printf("[category] reorder success parent=%v count=%d", req.ParentID, len(categories))
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "category.reorder",
+		ResourceType: AuditResourceCategory,
+		ResourceID:   optionalIDString(req.ParentID),
+		Details:      map[string]interface{}{"ordered_ids": req.OrderedIDs},
+	})
 	return categories, nil
 }
 
@@ -663,8 +718,8 @@ func (s *Service) RepositionCategory(ctx context.Context, meta RequestMeta, id i
 		return CategoryRepositionResult{}, err
 	}
 
-	// 课程管理员权限检查
-	if meta.UserRole == "course_admin" {
+	// 按课程授权的角色不能调整根层级
+	if courseScoped(ctx, s.roles(), meta) {
 		// 不能将子节点移到根层级
 		if req.ParentSpecified && req.NewParentID == nil {
 			return CategoryRepositionResult{}, errors.New("course administrators cannot move nodes to root level")
@@ -755,69 +810,75 @@ func (s *Service) BulkPurgeCategories(ctx context.Context, meta RequestMeta, ids
 }
 
 func (s *Service) BulkCopyCategories(ctx context.Context, meta RequestMeta, req CategoryBulkCopyRequest) ([]Category, error) {
-	if len(req.SourceIDs) == 0 {
-		return nil, errors.New("source_ids is required")
+	if err := validateBulkAnchor(req.SourceIDs, req.InsertBeforeID, req.InsertAfterID); err != nil {
+		return nil, err
+	}
+
+	items := make([]database.BatchItem, len(req.SourceIDs))
+	for i, id := range req.SourceIDs {
+		items[i] = database.BatchItem{Sequence: i, NodeID: id, Status: database.BatchItemStatusPending, Result: database.JSONMap{}}
 	}
-	if req.InsertBeforeID != nil && req.InsertAfterID != nil {
-		return nil, errors.New("insert_before_id and insert_after_id cannot both be set")
+	op, err := s.bulkJournal.begin(ctx, meta, CategoryBulkCopy, req, items)
+	if err != nil {
+		return nil, err
 	}
+	return s.runBulkCopy(ctx, meta, op, req, items)
+}
 
+// runBulkCopy 依次复制尚未完成的项，失败时删除本次操作创建的节点
+func (s *Service) runBulkCopy(ctx context.Context, meta RequestMeta, op *database.CategoryBulkOperation, req CategoryBulkCopyRequest, items []database.BatchItem) ([]Category, error) {
 	cache := make(map[int64]map[string]struct{})
-	created := make([]Category, 0, len(req.SourceIDs))
-	createdIDs := make([]int64, 0, len(req.SourceIDs))
+	created := make([]Category, 0, len(items))
+	createdIDs := make([]int64, 0, len(items))
 
 	// 复制节点，如果失败则回滚已创建的节点
-	for _, id := range req.SourceIDs {
-		copied, err := s.copyCategoryRecursive(ctx, meta, id, req.TargetParentID, cache)
+	for i := range items {
+		item := &items[i]
+		if item.Status == database.BatchItemStatusSuccess {
+			createdIDs = append(createdIDs, bulkItemID(item.Result, "created_id"))
+			continue
+		}
+		var copied *Category
+		var err error
+		if createdID := bulkItemID(item.Result, "created_id"); createdID != 0 && item.Status != database.BatchItemStatusReverted {
+			// 上次复制到一半中断：复用已创建的根节点，重新复制其子树
+			copied, err = s.resumeCategoryCopy(ctx, meta, item.NodeID, createdID, cache)
+		} else {
+			// 根节点创建后立即记录 created_id，中断后撤销或继续都能找到它
+			copied, err = s.copyCategoryRecursive(ctx, meta, item.NodeID, req.TargetParentID, cache, func(id int64) {
+				item.Result["created_id"] = id
+				s.bulkJournal.recordResult(ctx, item)
+			})
+		}
 		if err != nil {
-			// 回滚：删除已创建的节点
-			s.rollbackCreatedCategories(ctx, meta, createdIDs)
-			return nil, fmt.Errorf("copy category %d failed, rolled back %d created nodes: %w", id, len(createdIDs), err)
+			s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusFailed, err.Error())
+			reverted, rbErr := s.revertBulkCopy(ctx, meta, items)
+			return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("copy category %d failed, rolled back %d created nodes: %w", item.NodeID, reverted, err), rbErr)
 		}
+		item.Result["created_id"] = copied.ID
+		s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusSuccess, "")
+		s.reindexSubtree(ctx, meta, copied.ID)
 		created = append(created, *copied)
 		createdIDs = append(createdIDs, copied.ID)
 	}
 
 	if req.InsertBeforeID != nil || req.InsertAfterID != nil {
-		if req.InsertBeforeID != nil && containsInt(req.SourceIDs, *req.InsertBeforeID) {
-			s.rollbackCreatedCategories(ctx, meta, createdIDs)
-			return nil, errors.New("anchor cannot be part of source_ids")
-		}
-		if req.InsertAfterID != nil && containsInt(req.SourceIDs, *req.InsertAfterID) {
-			s.rollbackCreatedCategories(ctx, meta, createdIDs)
-			return nil, errors.New("anchor cannot be part of source_ids")
-		}
-
 		siblings, err := s.fetchSiblingIDs(ctx, meta, req.TargetParentID)
 		if err != nil {
-			s.rollbackCreatedCategories(ctx, meta, createdIDs)
-			return nil, fmt.Errorf("fetch siblings failed, rolled back: %w", err)
+			reverted, rbErr := s.revertBulkCopy(ctx, meta, items)
+			return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("fetch siblings failed, rolled back %d created nodes: %w", reverted, err), rbErr)
 		}
-		siblings = removeIDs(siblings, createdIDs)
-
-		var ordered []int64
-		if req.InsertBeforeID != nil {
-			idx := indexOf(siblings, *req.InsertBeforeID)
-			if idx == -1 {
-				s.rollbackCreatedCategories(ctx, meta, createdIDs)
-				return nil, fmt.Errorf("anchor id %d not found", *req.InsertBeforeID)
-			}
-			ordered = append(siblings[:idx], append(createdIDs, siblings[idx:]...)...)
-		} else if req.InsertAfterID != nil {
-			idx := indexOf(siblings, *req.InsertAfterID)
-			if idx == -1 {
-				s.rollbackCreatedCategories(ctx, meta, createdIDs)
-				return nil, fmt.Errorf("anchor id %d not found", *req.InsertAfterID)
-			}
-			anchorIdx := idx + 1
-			ordered = append(siblings[:anchorIdx], append(createdIDs, siblings[anchorIdx:]...)...)
+		ordered, err := placeAtAnchor(removeIDs(siblings, createdIDs), createdIDs, req.InsertBeforeID, req.InsertAfterID)
+		if err != nil {
+			reverted, rbErr := s.revertBulkCopy(ctx, meta, items)
+			return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("%w, rolled back %d created nodes", err, reverted), rbErr)
 		}
 
 		siblingsCats, err := s.ReorderCategories(ctx, meta, CategoryReorderRequest{ParentID: req.TargetParentID, OrderedIDs: ordered})
 		if err != nil {
 			// 重排失败不回滚，节点已创建但顺序可能不符合预期
-			// 用户可以手动重新排序或删除
-			return nil, fmt.Errorf("reorder failed, %d nodes created but not in expected order: %w", len(createdIDs), err)
+			// 用户可以手动重新排序或删除，也可以通过操作日志继续或撤销
+			return nil, s.bulkJournal.fail(ctx, op, fmt.Errorf("reorder failed, %d nodes created but not in expected order: %w", len(createdIDs), err))
 		}
 		updated := make(map[int64]Category)
 		for _, cat := range siblingsCats {
@@ -835,19 +896,64 @@ func (s *Service) BulkCopyCategories(ctx context.Context, meta RequestMeta, req
 			}
 		}
 	}
+	s.bulkJournal.complete(ctx, op)
 
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "category.copy",
+		ResourceType: AuditResourceCategory,
+		ResourceID:   optionalIDString(req.TargetParentID),
+		Details: map[string]interface{}{
+			"source_ids":  req.SourceIDs,
+			"created_ids": createdIDs,
+		},
+	})
 	return created, nil
 }
 
-// rollbackCreatedCategories 删除批量操作中创建的节点（尽力而为，忽略错误）
-func (s *Service) rollbackCreatedCategories(ctx context.Context, meta RequestMeta, createdIDs []int64) {
-	for _, id := range createdIDs {
-		// 使用软删除进行回滚，忽略错误继续
-		_ = s.DeleteCategory(ctx, meta, id, CategoryDeleteRequest{})
+// revertBulkCopy 删除批量复制中已创建的节点（含复制到一半的子树），返回删除的数量
+// 单个节点删除失败时继续处理其余节点，最后汇总错误
+func (s *Service) revertBulkCopy(ctx context.Context, meta RequestMeta, items []database.BatchItem) (int, error) {
+	reverted := 0
+	var errs []error
+	for i := len(items) - 1; i >= 0; i-- {
+		item := &items[i]
+		createdID := bulkItemID(item.Result, "created_id")
+		if createdID == 0 || item.Status == database.BatchItemStatusReverted {
+			continue
+		}
+		if err := s.deleteCategoryRecursive(ctx, meta, createdID); err != nil {
+			errs = append(errs, fmt.Errorf("delete copied category %d: %w", createdID, err))
+			continue
+		}
+		s.recordAudit(ctx, meta, "category.delete", AuditResourceCategory, createdID, map[string]interface{}{"bulk_undo": true})
+		s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusReverted, "")
+		reverted++
+	}
+	return reverted, errors.Join(errs...)
+}
+
+// resumeCategoryCopy 复用中断时已创建的根节点：彻底删除其下复制到一半的子节点后重新复制子树
+// 子节点都是本次复制新建的，彻底删除以免软删除的节点占用路径
+func (s *Service) resumeCategoryCopy(ctx context.Context, meta RequestMeta, sourceID, createdID int64, cache map[int64]map[string]struct{}) (*Category, error) {
+	created, err := s.GetCategory(ctx, meta, createdID, false)
+	if err != nil {
+		return nil, fmt.Errorf("get copied category %d: %w", createdID, err)
 	}
+	partial, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), createdID, ndrclient.ListChildrenParams{})
+	if err != nil {
+		return nil, fmt.Errorf("list children for %d: %w", createdID, err)
+	}
+	for _, child := range partial {
+		if err := s.ndr.PurgeNode(ctx, toNDRMeta(meta), child.ID); err != nil {
+			return nil, fmt.Errorf("purge partial copy %d: %w", child.ID, err)
+		}
+	}
+	invalidateNodes(ctx, s.cache)
+	return s.copyCategoryChildren(ctx, meta, sourceID, created, cache)
 }
 
-func (s *Service) copyCategoryRecursive(ctx context.Context, meta RequestMeta, sourceID int64, targetParentID *int64, cache map[int64]map[string]struct{}) (*Category, error) {
+// copyCategoryRecursive 复制节点及其子树；onCreated 非空时在根节点创建后、复制子树前调用
+func (s *Service) copyCategoryRecursive(ctx context.Context, meta RequestMeta, sourceID int64, targetParentID *int64, cache map[int64]map[string]struct{}, onCreated func(id int64)) (*Category, error) {
 	srcNode, err := s.ndr.GetNode(ctx, toNDRMeta(meta), sourceID, ndrclient.GetNodeOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("fetch source node %d: %w", sourceID, err)
@@ -862,12 +968,18 @@ func (s *Service) copyCategoryRecursive(ctx context.Context, meta RequestMeta, s
 		return nil, err
 	}
 
-	createdValue, err := s.CreateCategory(ctx, meta, CategoryCreateRequest{Name: name, ParentID: targetParentID})
+	created, err := s.CreateCategory(ctx, meta, CategoryCreateRequest{Name: name, ParentID: targetParentID})
 	if err != nil {
 		return nil, err
 	}
+	if onCreated != nil {
+		onCreated(created.ID)
+	}
+	return s.copyCategoryChildren(ctx, meta, sourceID, created, cache)
+}
 
-	created := createdValue
+// copyCategoryChildren 把源节点的子树复制到已创建的节点下
+func (s *Service) copyCategoryChildren(ctx context.Context, meta RequestMeta, sourceID int64, created Category, cache map[int64]map[string]struct{}) (*Category, error) {
 	created.Children = nil
 
 	children, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), sourceID, ndrclient.ListChildrenParams{})
@@ -879,7 +991,7 @@ func (s *Service) copyCategoryRecursive(ctx context.Context, meta RequestMeta, s
 	})
 
 	for _, child := range children {
-		copiedChild, err := s.copyCategoryRecursive(ctx, meta, child.ID, ptr(created.ID), cache)
+		copiedChild, err := s.copyCategoryRecursive(ctx, meta, child.ID, ptr(created.ID), cache, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -917,21 +1029,13 @@ func (s *Service) ensureUniqueCategoryName(ctx context.Context, meta RequestMeta
 func (s *Service) fetchSiblingNames(ctx context.Context, meta RequestMeta, parentID *int64) (map[string]struct{}, error) {
 	result := make(map[string]struct{})
 	if parentID == nil {
-		params := ndrclient.ListNodesParams{Page: 1, Size: 200}
-		for {
-			page, err := s.ndr.ListNodes(ctx, toNDRMeta(meta), params)
+		for node, err := range s.ndr.StreamNodes(ctx, toNDRMeta(meta), ndrclient.ListNodesParams{Size: 200}) {
 			if err != nil {
 				return nil, err
 			}
-			for _, node := range page.Items {
-				if node.ParentID == nil && node.DeletedAt == nil {
-					result[node.Name] = struct{}{}
-				}
-			}
-			if len(page.Items) < params.Size || (page.Total > 0 && params.Page*params.Size >= page.Total) {
-				break
+			if node.ParentID == nil && node.DeletedAt == nil {
+				result[node.Name] = struct{}{}
 			}
-			params.Page++
 		}
 		return result, nil
 	}
@@ -981,52 +1085,76 @@ func removeIDs(list []int64, removeIDs []int64) []int64 {
 	return filtered
 }
 
-// moveRecord 记录批量移动操作中的节点原始父节点，用于回滚
-type moveRecord struct {
-	id             int64
-	originalParent *int64
-}
-
 func (s *Service) BulkMoveCategories(ctx context.Context, meta RequestMeta, req CategoryBulkMoveRequest) ([]Category, error) {
-	if len(req.SourceIDs) == 0 {
-		return nil, errors.New("source_ids is required")
-	}
-	if req.InsertBeforeID != nil && req.InsertAfterID != nil {
-		return nil, errors.New("insert_before_id and insert_after_id cannot both be set")
+	if err := validateBulkAnchor(req.SourceIDs, req.InsertBeforeID, req.InsertAfterID); err != nil {
+		return nil, err
 	}
 
-	// 记录原始父节点用于回滚
-	moveRecords := make([]moveRecord, 0, len(req.SourceIDs))
-
-	// 先获取所有节点的原始父节点
-	for _, id := range req.SourceIDs {
+	// 先获取所有节点的原始父节点与在兄弟节点中的下标，记录到操作日志中用于回滚
+	items := make([]database.BatchItem, 0, len(req.SourceIDs))
+	siblingCache := make(map[int64][]int64)
+	for i, id := range req.SourceIDs {
 		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), id, ndrclient.GetNodeOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("get node %d for move: %w", id, err)
 		}
-		moveRecords = append(moveRecords, moveRecord{
-			id:             id,
-			originalParent: node.ParentID,
+		key := int64(0)
+		if node.ParentID != nil {
+			key = *node.ParentID
+		}
+		siblings, ok := siblingCache[key]
+		if !ok {
+			if siblings, err = s.fetchSiblingIDs(ctx, meta, node.ParentID); err != nil {
+				return nil, fmt.Errorf("fetch siblings of node %d: %w", id, err)
+			}
+			siblingCache[key] = siblings
+		}
+		result := database.JSONMap{"original_index": indexOf(siblings, id)}
+		if node.ParentID != nil {
+			result["original_parent_id"] = *node.ParentID
+		}
+		items = append(items, database.BatchItem{
+			Sequence: i,
+			NodeID:   id,
+			NodeName: node.Name,
+			NodePath: node.Path,
+			Status:   database.BatchItemStatusPending,
+			Result:   result,
 		})
 	}
+	op, err := s.bulkJournal.begin(ctx, meta, CategoryBulkMove, req, items)
+	if err != nil {
+		return nil, err
+	}
+	return s.runBulkMove(ctx, meta, op, req, items)
+}
 
-	// 执行移动操作
+// runBulkMove 依次移动尚未完成的项并在目标父节点下排序，失败时把已移动的节点移回原位置
+func (s *Service) runBulkMove(ctx context.Context, meta RequestMeta, op *database.CategoryBulkOperation, req CategoryBulkMoveRequest, items []database.BatchItem) ([]Category, error) {
 	targetParentID := req.TargetParentID
-	movedSet := make(map[int64]struct{}, len(req.SourceIDs))
-	for i, record := range moveRecords {
-		movedSet[record.id] = struct{}{}
-		_, err := s.MoveCategory(ctx, meta, record.id, MoveCategoryRequest{NewParentID: targetParentID, ParentSpecified: true})
+	movedSet := make(map[int64]struct{}, len(items))
+	moved := 0
+	for i := range items {
+		item := &items[i]
+		movedSet[item.NodeID] = struct{}{}
+		if item.Status == database.BatchItemStatusSuccess {
+			moved++
+			continue
+		}
+		_, err := s.MoveCategory(ctx, meta, item.NodeID, MoveCategoryRequest{NewParentID: targetParentID, ParentSpecified: true})
 		if err != nil {
-			// 回滚：将已移动的节点移回原位置
-			s.rollbackMovedCategories(ctx, meta, moveRecords[:i])
-			return nil, fmt.Errorf("move category %d failed, rolled back %d moved nodes: %w", record.id, i, err)
+			s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusFailed, err.Error())
+			reverted, rbErr := s.revertBulkMove(ctx, meta, items)
+			return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("move category %d failed, rolled back %d moved nodes: %w", item.NodeID, reverted, err), rbErr)
 		}
+		s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusSuccess, "")
+		moved++
 	}
 
 	siblings, err := s.fetchSiblingIDs(ctx, meta, targetParentID)
 	if err != nil {
-		s.rollbackMovedCategories(ctx, meta, moveRecords)
-		return nil, fmt.Errorf("fetch siblings failed, rolled back: %w", err)
+		reverted, rbErr := s.revertBulkMove(ctx, meta, items)
+		return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("fetch siblings failed, rolled back %d moved nodes: %w", reverted, err), rbErr)
 	}
 
 	ordered := make([]int64, 0, len(siblings))
@@ -1035,107 +1163,182 @@ func (s *Service) BulkMoveCategories(ctx context.Context, meta RequestMeta, req
 			ordered = append(ordered, id)
 		}
 	}
-
-	var anchorIndex int
-	insert := req.SourceIDs
-	if req.InsertBeforeID != nil {
-		if _, ok := movedSet[*req.InsertBeforeID]; ok {
-			s.rollbackMovedCategories(ctx, meta, moveRecords)
-			return nil, errors.New("anchor cannot be part of source_ids")
-		}
-		idx := indexOf(ordered, *req.InsertBeforeID)
-		if idx == -1 {
-			s.rollbackMovedCategories(ctx, meta, moveRecords)
-			return nil, fmt.Errorf("anchor id %d not found among siblings", *req.InsertBeforeID)
-		}
-		anchorIndex = idx
-		ordered = append(ordered[:anchorIndex], append(insert, ordered[anchorIndex:]...)...)
-	} else if req.InsertAfterID != nil {
-		if _, ok := movedSet[*req.InsertAfterID]; ok {
-			s.rollbackMovedCategories(ctx, meta, moveRecords)
-			return nil, errors.New("anchor cannot be part of source_ids")
-		}
-		idx := indexOf(ordered, *req.InsertAfterID)
-		if idx == -1 {
-			s.rollbackMovedCategories(ctx, meta, moveRecords)
-			return nil, fmt.Errorf("anchor id %d not found among siblings", *req.InsertAfterID)
-		}
-		anchorIndex = idx + 1
-		ordered = append(ordered[:anchorIndex], append(insert, ordered[anchorIndex:]...)...)
-	} else {
-		ordered = append(ordered, insert...)
+	ordered, err = placeAtAnchor(ordered, req.SourceIDs, req.InsertBeforeID, req.InsertAfterID)
+	if err != nil {
+		reverted, rbErr := s.revertBulkMove(ctx, meta, items)
+		return nil, s.bulkJournal.rolledBack(ctx, op, fmt.Errorf("%w, rolled back %d moved nodes", err, reverted), rbErr)
 	}
 
 	siblingsCats, err := s.ReorderCategories(ctx, meta, CategoryReorderRequest{ParentID: targetParentID, OrderedIDs: ordered})
 	if err != nil {
 		// 重排失败不回滚，节点已移动但顺序可能不符合预期
-		return nil, fmt.Errorf("reorder failed, %d nodes moved but not in expected order: %w", len(req.SourceIDs), err)
+		return nil, s.bulkJournal.fail(ctx, op, fmt.Errorf("reorder failed, %d nodes moved but not in expected order: %w", moved, err))
 	}
+	s.bulkJournal.complete(ctx, op)
 
-	moved := make([]Category, 0, len(req.SourceIDs))
+	result := make([]Category, 0, len(items))
 	for _, cat := range siblingsCats {
 		if _, ok := movedSet[cat.ID]; ok {
-			moved = append(moved, cat)
+			result = append(result, cat)
 		}
 	}
-	sort.SliceStable(moved, func(i, j int) bool {
-		posI := indexOf(ordered, moved[i].ID)
-		posJ := indexOf(ordered, moved[j].ID)
+	sort.SliceStable(result, func(i, j int) bool {
+		posI := indexOf(ordered, result[i].ID)
+		posJ := indexOf(ordered, result[j].ID)
 		return posI < posJ
 	})
 
-	return moved, nil
+	return result, nil
 }
 
-// rollbackMovedCategories 回滚批量移动操作（尽力而为，忽略错误）
-func (s *Service) rollbackMovedCategories(ctx context.Context, meta RequestMeta, records []moveRecord) {
-	for _, record := range records {
-		// 将节点移回原父节点，忽略错误继续
-		_, _ = s.MoveCategory(ctx, meta, record.id, MoveCategoryRequest{
-			NewParentID:     record.originalParent,
-			ParentSpecified: true,
-		})
+// revertBulkMove 将批量移动中已离开原父节点的节点移回，并按原位置恢复原父节点下的顺序
+// 以 NDR 中节点的当前父节点为准，因此进程中断时未记录完成的项也能被撤销
+func (s *Service) revertBulkMove(ctx context.Context, meta RequestMeta, items []database.BatchItem) (int, error) {
+	var errs []error
+	restored := make(map[int64][]*database.BatchItem) // 原父节点 -> 移回的项，根层级为 0
+	for i := len(items) - 1; i >= 0; i-- {
+		item := &items[i]
+		if item.Status == database.BatchItemStatusReverted {
+			continue
+		}
+		originalParent := bulkItemOptionalID(item.Result, "original_parent_id")
+		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), item.NodeID, ndrclient.GetNodeOptions{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get node %d: %w", item.NodeID, err))
+			continue
+		}
+		if !sameParentID(node.ParentID, originalParent) {
+			if _, err := s.MoveCategory(ctx, meta, item.NodeID, MoveCategoryRequest{NewParentID: originalParent, ParentSpecified: true}); err != nil {
+				errs = append(errs, fmt.Errorf("move category %d back: %w", item.NodeID, err))
+				continue
+			}
+			key := int64(0)
+			if originalParent != nil {
+				key = *originalParent
+			}
+			restored[key] = append(restored[key], item)
+		}
+		s.bulkJournal.updateItem(ctx, item, database.BatchItemStatusReverted, "")
+	}
+
+	reverted := 0
+	for _, group := range restored {
+		reverted += len(group)
+		if err := s.restoreSiblingOrder(ctx, meta, group); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return reverted, errors.Join(errs...)
+}
+
+// restoreSiblingOrder 按原下标把移回原父节点的节点放回各自的原位置
+func (s *Service) restoreSiblingOrder(ctx context.Context, meta RequestMeta, group []*database.BatchItem) error {
+	parentID := bulkItemOptionalID(group[0].Result, "original_parent_id")
+	siblings, err := s.fetchSiblingIDs(ctx, meta, parentID)
+	if err != nil {
+		return fmt.Errorf("fetch siblings of %s: %w", optionalIDString(parentID), err)
+	}
+	ids := make([]int64, len(group))
+	for i, item := range group {
+		ids[i] = item.NodeID
 	}
+	ordered := removeIDs(siblings, ids)
+	sort.SliceStable(group, func(i, j int) bool {
+		return bulkItemID(group[i].Result, "original_index") < bulkItemID(group[j].Result, "original_index")
+	})
+	for _, item := range group {
+		pos := int(bulkItemID(item.Result, "original_index"))
+		if pos < 0 {
+			pos = 0
+		}
+		if pos > len(ordered) {
+			pos = len(ordered)
+		}
+		ordered = append(ordered[:pos], append([]int64{item.NodeID}, ordered[pos:]...)...)
+	}
+	if _, err := s.ReorderCategories(ctx, meta, CategoryReorderRequest{ParentID: parentID, OrderedIDs: ordered}); err != nil {
+		return fmt.Errorf("restore order under %s: %w", optionalIDString(parentID), err)
+	}
+	return nil
+}
+
+// validateBulkAnchor 校验批量移动/复制的源节点与插入锚点
+func validateBulkAnchor(sourceIDs []int64, insertBeforeID, insertAfterID *int64) error {
+	if len(sourceIDs) == 0 {
+		return errors.New("source_ids is required")
+	}
+	if insertBeforeID != nil && insertAfterID != nil {
+		return errors.New("insert_before_id and insert_after_id cannot both be set")
+	}
+	if (insertBeforeID != nil && containsInt(sourceIDs, *insertBeforeID)) || (insertAfterID != nil && containsInt(sourceIDs, *insertAfterID)) {
+		return errors.New("anchor cannot be part of source_ids")
+	}
+	return nil
+}
+
+// placeAtAnchor 把 insert 插入到锚点之前/之后，未指定锚点时追加到末尾
+func placeAtAnchor(siblings, insert []int64, insertBeforeID, insertAfterID *int64) ([]int64, error) {
+	ordered := append([]int64{}, siblings...)
+	anchorIndex := len(ordered)
+	if insertBeforeID != nil {
+		anchorIndex = indexOf(ordered, *insertBeforeID)
+		if anchorIndex == -1 {
+			return nil, fmt.Errorf("anchor id %d not found among siblings", *insertBeforeID)
+		}
+	} else if insertAfterID != nil {
+		idx := indexOf(ordered, *insertAfterID)
+		if idx == -1 {
+			return nil, fmt.Errorf("anchor id %d not found among siblings", *insertAfterID)
+		}
+		anchorIndex = idx + 1
+	}
+	return append(ordered[:anchorIndex], append(append([]int64{}, insert...), ordered[anchorIndex:]...)...), nil
+}
+
+func sameParentID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
 
 func (s *Service) fetchSiblingIDs(ctx context.Context, meta RequestMeta, parentID *int64) ([]int64, error) {
+	nodes, err := s.fetchSiblings(ctx, meta, parentID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids, nil
+}
+
+// fetchSiblings 返回父节点下未删除的子节点（按 position 排序），parentID 为 nil 时返回根层级
+func (s *Service) fetchSiblings(ctx context.Context, meta RequestMeta, parentID *int64) ([]ndrclient.Node, error) {
+	nodes := make([]ndrclient.Node, 0)
 	if parentID == nil {
-		params := ndrclient.ListNodesParams{Page: 1, Size: 200}
-		nodes := make([]ndrclient.Node, 0)
-		for {
-			page, err := s.ndr.ListNodes(ctx, toNDRMeta(meta), params)
+		for node, err := range s.ndr.StreamNodes(ctx, toNDRMeta(meta), ndrclient.ListNodesParams{Size: 200}) {
 			if err != nil {
 				return nil, err
 			}
-			for _, node := range page.Items {
-				if node.DeletedAt == nil && node.ParentID == nil {
-					nodes = append(nodes, node)
-				}
-			}
-			if len(page.Items) < params.Size || (page.Total > 0 && params.Page*params.Size >= page.Total) {
-				break
+			if node.DeletedAt == nil && node.ParentID == nil {
+				nodes = append(nodes, node)
 			}
-			params.Page++
 		}
-		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Position < nodes[j].Position })
-		ids := make([]int64, 0, len(nodes))
-		for _, node := range nodes {
-			ids = append(ids, node.ID)
+	} else {
+		children, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), *parentID, ndrclient.ListChildrenParams{})
+		if err != nil {
+			return nil, err
 		}
-		return ids, nil
-	}
-	children, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), *parentID, ndrclient.ListChildrenParams{})
-	if err != nil {
-		return nil, err
-	}
-	sort.Slice(children, func(i, j int) bool { return children[i].Position < children[j].Position })
-	ids := make([]int64, 0, len(children))
-	for _, node := range children {
-		if node.DeletedAt == nil {
-			ids = append(ids, node.ID)
+		for _, node := range children {
+			if node.DeletedAt == nil {
+				nodes = append(nodes, node)
+			}
 		}
 	}
-	return ids, nil
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Position < nodes[j].Position })
+	return nodes, nil
 }
 
 func indexOf(list []int64, id int64) int {
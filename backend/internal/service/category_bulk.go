@@ -0,0 +1,560 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// 分类批量操作类型
+const (
+	CategoryBulkMove = "move"
+	CategoryBulkCopy = "copy"
+)
+
+// categoryBulkStaleAfter 状态仍为 running 但超过该时长未更新的操作视为已中断，可继续或撤销
+const categoryBulkStaleAfter = 10 * time.Minute
+
+var (
+	// ErrCategoryBulkJournalDisabled 未配置操作日志
+	ErrCategoryBulkJournalDisabled = errors.New("category bulk operation journal is not configured")
+	// ErrCategoryBulkOperationNotFound 操作不存在
+	ErrCategoryBulkOperationNotFound = errors.New("bulk operation not found")
+	// ErrCategoryBulkOperationForbidden 无权操作
+	ErrCategoryBulkOperationForbidden = errors.New("only the operation creator or a role with batches:all can manage this bulk operation")
+	// ErrCategoryBulkOperationNotRecoverable 操作未失败也未中断，不能继续或撤销
+	ErrCategoryBulkOperationNotRecoverable = errors.New("bulk operation is neither failed nor interrupted")
+)
+
+// CategoryBulkJournal 分类批量移动/复制的持久化操作日志
+type CategoryBulkJournal struct {
+	db *gorm.DB
+}
+
+// NewCategoryBulkJournal 创建操作日志
+func NewCategoryBulkJournal(db *gorm.DB) *CategoryBulkJournal {
+	return &CategoryBulkJournal{db: db}
+}
+
+// CategoryBulkOperationDetail 操作及其逐项记录
+type CategoryBulkOperationDetail struct {
+	database.CategoryBulkOperation
+	Items []database.BatchItem `json:"items"`
+}
+
+// CategoryBulkOperationFilter 操作列表过滤条件
+type CategoryBulkOperationFilter struct {
+	Kind   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+func categoryBulkItemType(kind string) string {
+	if kind == CategoryBulkCopy {
+		return database.BatchItemTypeCategoryCopy
+	}
+	return database.BatchItemTypeCategoryMove
+}
+
+// begin 在执行前记录操作与逐项状态；未配置日志时只返回内存中的操作
+func (j *CategoryBulkJournal) begin(ctx context.Context, meta RequestMeta, kind string, req interface{}, items []database.BatchItem) (*database.CategoryBulkOperation, error) {
+	op := &database.CategoryBulkOperation{Kind: kind, Status: database.BatchStatusRunning, Options: encodeBatchOptions(req)}
+	if meta.UserIDNumeric != 0 {
+		userID := meta.UserIDNumeric
+		op.CreatedByID = &userID
+	}
+	if j == nil {
+		return op, nil
+	}
+	err := j.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(op).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BatchType = categoryBulkItemType(kind)
+			items[i].BatchRefID = op.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create bulk operation journal: %w", err)
+	}
+	return op, nil
+}
+
+// updateItem 更新单项状态，同时刷新操作的 updated_at 以免被误判为中断
+func (j *CategoryBulkJournal) updateItem(ctx context.Context, item *database.BatchItem, status, errMsg string) {
+	now := time.Now()
+	item.Status = status
+	item.ErrorMessage = errMsg
+	item.FinishedAt = &now
+	if j == nil || item.ID == 0 {
+		return
+	}
+	if err := j.db.WithContext(ctx).Model(&database.BatchItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"status":        status,
+		"error_message": errMsg,
+		"result":        item.Result,
+		"finished_at":   &now,
+	}).Error; err != nil {
+		log.Printf("[category] bulk operation %d: failed to record item %d: %v", item.BatchRefID, item.ID, err)
+	}
+	j.db.WithContext(ctx).Model(&database.CategoryBulkOperation{}).Where("id = ?", item.BatchRefID).Update("updated_at", now)
+}
+
+// recordResult 在项完成前保存其中间结果（如复制已创建的根节点 ID）
+func (j *CategoryBulkJournal) recordResult(ctx context.Context, item *database.BatchItem) {
+	if j == nil || item.ID == 0 {
+		return
+	}
+	now := time.Now()
+	if err := j.db.WithContext(ctx).Model(&database.BatchItem{}).Where("id = ?", item.ID).Update("result", item.Result).Error; err != nil {
+		log.Printf("[category] bulk operation %d: failed to record result of item %d: %v", item.BatchRefID, item.ID, err)
+	}
+	j.db.WithContext(ctx).Model(&database.CategoryBulkOperation{}).Where("id = ?", item.BatchRefID).Update("updated_at", now)
+}
+
+func (j *CategoryBulkJournal) finish(ctx context.Context, op *database.CategoryBulkOperation, status, errMsg string) {
+	now := time.Now()
+	op.Status = status
+	op.ErrorMessage = errMsg
+	op.FinishedAt = &now
+	if status == database.BatchStatusCompleted {
+		op.Reordered = true
+	}
+	if j == nil || op.ID == 0 {
+		return
+	}
+	if err := j.db.WithContext(ctx).Model(op).Updates(map[string]interface{}{
+		"status":        status,
+		"error_message": errMsg,
+		"reordered":     op.Reordered,
+		"finished_at":   &now,
+	}).Error; err != nil {
+		log.Printf("[category] bulk operation %d: failed to record status %s: %v", op.ID, status, err)
+	}
+}
+
+func (j *CategoryBulkJournal) complete(ctx context.Context, op *database.CategoryBulkOperation) {
+	j.finish(ctx, op, database.BatchStatusCompleted, "")
+}
+
+// fail 把操作标记为失败（可继续或撤销），返回附带操作 ID 的错误
+func (j *CategoryBulkJournal) fail(ctx context.Context, op *database.CategoryBulkOperation, err error) error {
+	return j.end(ctx, op, database.BatchStatusFailed, err)
+}
+
+// rolledBack 记录自动回滚的结果：回滚完成时标记为 rolled_back，否则标记为失败以便继续撤销
+func (j *CategoryBulkJournal) rolledBack(ctx context.Context, op *database.CategoryBulkOperation, err, rbErr error) error {
+	if rbErr != nil {
+		return j.end(ctx, op, database.BatchStatusFailed, fmt.Errorf("%w; rollback incomplete: %v", err, rbErr))
+	}
+	return j.end(ctx, op, database.BatchStatusRolledBack, err)
+}
+
+func (j *CategoryBulkJournal) end(ctx context.Context, op *database.CategoryBulkOperation, status string, err error) error {
+	j.finish(ctx, op, status, err.Error())
+	if j == nil || op.ID == 0 {
+		return err
+	}
+	return fmt.Errorf("%w (bulk operation %d)", err, op.ID)
+}
+
+// claim 认领一个失败或已中断的操作用于继续/撤销，并加载其逐项记录
+func (j *CategoryBulkJournal) claim(ctx context.Context, meta RequestMeta, id uint) (*database.CategoryBulkOperation, []database.BatchItem, error) {
+	if j == nil {
+		return nil, nil, ErrCategoryBulkJournalDisabled
+	}
+	var op database.CategoryBulkOperation
+	if err := j.db.WithContext(ctx).First(&op, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrCategoryBulkOperationNotFound
+		}
+		return nil, nil, err
+	}
+	if !canManageBatch(ctx, j.db, meta, op.CreatedByID) {
+		return nil, nil, ErrCategoryBulkOperationForbidden
+	}
+	result := j.db.WithContext(ctx).Model(&database.CategoryBulkOperation{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))",
+			id, database.BatchStatusFailed, database.BatchStatusRunning, time.Now().Add(-categoryBulkStaleAfter)).
+		Updates(map[string]interface{}{"status": database.BatchStatusRunning, "finished_at": nil})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, nil, ErrCategoryBulkOperationNotRecoverable
+	}
+	op.Status = database.BatchStatusRunning
+
+	var items []database.BatchItem
+	if err := j.db.WithContext(ctx).
+		Where("batch_type = ? AND batch_ref_id = ?", categoryBulkItemType(op.Kind), op.ID).
+		Order("sequence ASC").Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	for i := range items {
+		if items[i].Result == nil {
+			items[i].Result = database.JSONMap{}
+		}
+	}
+	return &op, items, nil
+}
+
+// SetCategoryBulkJournal 配置分类批量移动/复制的操作日志，未配置时仅在内存中尽力回滚
+func (s *Service) SetCategoryBulkJournal(journal *CategoryBulkJournal) {
+	s.bulkJournal = journal
+}
+
+// ListCategoryBulkOperations 列出批量操作；角色没有 batches:all 时只能看到自己发起的操作
+func (s *Service) ListCategoryBulkOperations(ctx context.Context, meta RequestMeta, filter CategoryBulkOperationFilter) ([]database.CategoryBulkOperation, int64, error) {
+	if s.bulkJournal == nil {
+		return nil, 0, ErrCategoryBulkJournalDisabled
+	}
+	query := s.bulkJournal.db.WithContext(ctx).Model(&database.CategoryBulkOperation{})
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if !seesAllBatches(ctx, s.bulkJournal.db, meta) {
+		query = query.Where("created_by_id = ?", meta.UserIDNumeric)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	var ops []database.CategoryBulkOperation
+	if err := query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&ops).Error; err != nil {
+		return nil, 0, err
+	}
+	return ops, total, nil
+}
+
+// GetCategoryBulkOperation 返回批量操作及其逐项记录
+func (s *Service) GetCategoryBulkOperation(ctx context.Context, meta RequestMeta, id uint) (*CategoryBulkOperationDetail, error) {
+	if s.bulkJournal == nil {
+		return nil, ErrCategoryBulkJournalDisabled
+	}
+	db := s.bulkJournal.db.WithContext(ctx)
+	var detail CategoryBulkOperationDetail
+	if err := db.First(&detail.CategoryBulkOperation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryBulkOperationNotFound
+		}
+		return nil, err
+	}
+	if !canManageBatch(ctx, s.bulkJournal.db, meta, detail.CreatedByID) {
+		return nil, ErrCategoryBulkOperationForbidden
+	}
+	if err := db.Where("batch_type = ? AND batch_ref_id = ?", categoryBulkItemType(detail.Kind), detail.ID).
+		Order("sequence ASC").Find(&detail.Items).Error; err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// ResumeCategoryBulkOperation 继续执行失败或中断的批量操作：跳过已完成的项，重试其余项并重新排序
+func (s *Service) ResumeCategoryBulkOperation(ctx context.Context, meta RequestMeta, id uint) (*CategoryBulkOperationDetail, error) {
+	op, items, err := s.bulkJournal.claim(ctx, meta, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var runErr error
+	switch op.Kind {
+	case CategoryBulkCopy:
+		var req CategoryBulkCopyRequest
+		if err := decodeBatchOptions(op.Options, &req); err != nil {
+			runErr = s.bulkJournal.fail(ctx, op, fmt.Errorf("decode request: %w", err))
+			break
+		}
+		_, runErr = s.runBulkCopy(ctx, meta, op, req, items)
+	default:
+		var req CategoryBulkMoveRequest
+		if err := decodeBatchOptions(op.Options, &req); err != nil {
+			runErr = s.bulkJournal.fail(ctx, op, fmt.Errorf("decode request: %w", err))
+			break
+		}
+		_, runErr = s.runBulkMove(ctx, meta, op, req, items)
+	}
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "category.bulk_resume",
+		ResourceType: AuditResourceCategory,
+		ResourceID:   fmt.Sprintf("bulk-%d", op.ID),
+		Details:      map[string]interface{}{"kind": op.Kind, "status": op.Status},
+	})
+
+	detail, err := s.GetCategoryBulkOperation(ctx, meta, id)
+	if err != nil {
+		return nil, err
+	}
+	return detail, runErr
+}
+
+// UndoCategoryBulkOperation 撤销失败或中断的批量操作：移动的节点移回原位置，复制出的节点被删除
+func (s *Service) UndoCategoryBulkOperation(ctx context.Context, meta RequestMeta, id uint) (*CategoryBulkOperationDetail, error) {
+	op, items, err := s.bulkJournal.claim(ctx, meta, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted int
+	var rbErr error
+	if op.Kind == CategoryBulkCopy {
+		reverted, rbErr = s.revertBulkCopy(ctx, meta, items)
+	} else {
+		reverted, rbErr = s.revertBulkMove(ctx, meta, items)
+	}
+	var undoErr error
+	if rbErr != nil {
+		undoErr = s.bulkJournal.fail(ctx, op, fmt.Errorf("undo incomplete after reverting %d nodes: %w", reverted, rbErr))
+	} else {
+		s.bulkJournal.finish(ctx, op, database.BatchStatusRolledBack, op.ErrorMessage)
+	}
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "category.bulk_undo",
+		ResourceType: AuditResourceCategory,
+		ResourceID:   fmt.Sprintf("bulk-%d", op.ID),
+		Details:      map[string]interface{}{"kind": op.Kind, "reverted": reverted, "status": op.Status},
+	})
+
+	detail, err := s.GetCategoryBulkOperation(ctx, meta, id)
+	if err != nil {
+		return nil, err
+	}
+	return detail, undoErr
+}
+
+// CategoryBulkPlan dry_run 返回的预期树变化
+type CategoryBulkPlan struct {
+	Kind           string               `json:"kind"`
+	TargetParentID *int64               `json:"target_parent_id"`
+	Changes        []CategoryBulkChange `json:"changes"`
+	Before         []CategoryPlanNode   `json:"before"` // 目标父节点下执行前的子节点顺序
+	After          []CategoryPlanNode   `json:"after"`  // 目标父节点下执行后的子节点顺序
+}
+
+// CategoryBulkChange 单个源节点的变化
+type CategoryBulkChange struct {
+	Action       string `json:"action"` // move / copy
+	SourceID     int64  `json:"source_id"`
+	Name         string `json:"name"` // 复制时为去重后的新名称
+	FromParentID *int64 `json:"from_parent_id"`
+	FromPath     string `json:"from_path"`
+	ToParentID   *int64 `json:"to_parent_id"`
+	Nodes        int    `json:"nodes"` // 受影响的节点数（含子孙）
+	// Subtree 受影响的全部节点（源节点及其未删除的子孙，先序）
+	Subtree []CategoryPlanSubtreeNode `json:"subtree"`
+}
+
+// CategoryPlanSubtreeNode 源节点子树中的一个节点
+type CategoryPlanSubtreeNode struct {
+	ID       int64  `json:"id"`
+	ParentID *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Depth    int    `json:"depth"` // 相对源节点的深度，源节点为 0
+}
+
+// CategoryPlanNode 目标父节点下的一个子节点
+type CategoryPlanNode struct {
+	ID       int64  `json:"id,omitempty"`        // 复制产生的新节点没有 ID
+	SourceID int64  `json:"source_id,omitempty"` // 复制产生的新节点对应的源节点
+	Name     string `json:"name"`
+	Change   string `json:"change,omitempty"` // moved / created，未变化时为空
+}
+
+// PlanBulkMoveCategories 计算批量移动的结果而不执行
+func (s *Service) PlanBulkMoveCategories(ctx context.Context, meta RequestMeta, req CategoryBulkMoveRequest) (*CategoryBulkPlan, error) {
+	plan, siblings, err := s.newBulkPlan(ctx, meta, CategoryBulkMove, req.SourceIDs, req.TargetParentID, req.InsertBeforeID, req.InsertAfterID)
+	if err != nil {
+		return nil, err
+	}
+	targetPath := ""
+	if req.TargetParentID != nil {
+		target, err := s.ndr.GetNode(ctx, toNDRMeta(meta), *req.TargetParentID, ndrclient.GetNodeOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get target parent %d: %w", *req.TargetParentID, err)
+		}
+		targetPath = target.Path
+	}
+
+	names := make(map[int64]string, len(siblings))
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), change.SourceID, ndrclient.GetNodeOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get node %d: %w", change.SourceID, err)
+		}
+		if node.DeletedAt != nil {
+			return nil, newValidationError("source node %d is deleted", node.ID)
+		}
+		if targetPath != "" && (targetPath == node.Path || strings.HasPrefix(targetPath, node.Path+"/")) {
+			return nil, newValidationError("cannot move category %d into its own subtree", node.ID)
+		}
+		names[node.ID] = node.Name
+		change.Name = node.Name
+		change.FromParentID = node.ParentID
+		change.FromPath = node.Path
+		if change.Subtree, err = s.planSubtree(ctx, meta, node, 0); err != nil {
+			return nil, err
+		}
+		change.Nodes = len(change.Subtree)
+	}
+
+	remaining := make([]int64, 0, len(siblings))
+	for _, node := range siblings {
+		names[node.ID] = node.Name
+		if !containsInt(req.SourceIDs, node.ID) {
+			remaining = append(remaining, node.ID)
+		}
+	}
+	ordered, err := placeAtAnchor(remaining, req.SourceIDs, req.InsertBeforeID, req.InsertAfterID)
+	if err != nil {
+		return nil, newValidationError("%v", err)
+	}
+	for _, id := range ordered {
+		entry := CategoryPlanNode{ID: id, Name: names[id]}
+		if containsInt(req.SourceIDs, id) {
+			entry.Change = "moved"
+		}
+		plan.After = append(plan.After, entry)
+	}
+	return plan, nil
+}
+
+// PlanBulkCopyCategories 计算批量复制的结果而不执行
+func (s *Service) PlanBulkCopyCategories(ctx context.Context, meta RequestMeta, req CategoryBulkCopyRequest) (*CategoryBulkPlan, error) {
+	plan, siblings, err := s.newBulkPlan(ctx, meta, CategoryBulkCopy, req.SourceIDs, req.TargetParentID, req.InsertBeforeID, req.InsertAfterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 新节点尚无 ID，用负数占位参与排序
+	cache := make(map[int64]map[string]struct{})
+	placeholders := make([]int64, len(plan.Changes))
+	newNodes := make(map[int64]CategoryPlanNode, len(plan.Changes))
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), change.SourceID, ndrclient.GetNodeOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get node %d: %w", change.SourceID, err)
+		}
+		if node.DeletedAt != nil {
+			return nil, newValidationError("source node %d is deleted", node.ID)
+		}
+		name, err := s.ensureUniqueCategoryName(ctx, meta, req.TargetParentID, node.Name, cache)
+		if err != nil {
+			return nil, err
+		}
+		change.Name = name
+		change.FromParentID = node.ParentID
+		change.FromPath = node.Path
+		if change.Subtree, err = s.planSubtree(ctx, meta, node, 0); err != nil {
+			return nil, err
+		}
+		change.Nodes = len(change.Subtree)
+		placeholders[i] = -int64(i + 1)
+		newNodes[placeholders[i]] = CategoryPlanNode{SourceID: node.ID, Name: name, Change: "created"}
+	}
+
+	ids := make([]int64, 0, len(siblings))
+	names := make(map[int64]string, len(siblings))
+	for _, node := range siblings {
+		ids = append(ids, node.ID)
+		names[node.ID] = node.Name
+	}
+	ordered, err := placeAtAnchor(ids, placeholders, req.InsertBeforeID, req.InsertAfterID)
+	if err != nil {
+		return nil, newValidationError("%v", err)
+	}
+	for _, id := range ordered {
+		if entry, ok := newNodes[id]; ok {
+			plan.After = append(plan.After, entry)
+			continue
+		}
+		plan.After = append(plan.After, CategoryPlanNode{ID: id, Name: names[id]})
+	}
+	return plan, nil
+}
+
+// newBulkPlan 校验请求并填充计划的公共部分（执行前的子节点顺序、每个源节点一项变化）
+func (s *Service) newBulkPlan(ctx context.Context, meta RequestMeta, kind string, sourceIDs []int64, targetParentID, insertBeforeID, insertAfterID *int64) (*CategoryBulkPlan, []ndrclient.Node, error) {
+	if err := validateBulkAnchor(sourceIDs, insertBeforeID, insertAfterID); err != nil {
+		return nil, nil, newValidationError("%v", err)
+	}
+	siblings, err := s.fetchSiblings(ctx, meta, targetParentID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch siblings: %w", err)
+	}
+	plan := &CategoryBulkPlan{Kind: kind, TargetParentID: targetParentID, Before: []CategoryPlanNode{}}
+	for _, node := range siblings {
+		plan.Before = append(plan.Before, CategoryPlanNode{ID: node.ID, Name: node.Name})
+	}
+	for _, id := range sourceIDs {
+		plan.Changes = append(plan.Changes, CategoryBulkChange{Action: kind, SourceID: id, ToParentID: targetParentID})
+	}
+	return plan, siblings, nil
+}
+
+// planSubtree 按同级顺序先序列出节点及其未删除的子孙
+func (s *Service) planSubtree(ctx context.Context, meta RequestMeta, node ndrclient.Node, depth int) ([]CategoryPlanSubtreeNode, error) {
+	nodes := []CategoryPlanSubtreeNode{{ID: node.ID, ParentID: node.ParentID, Name: node.Name, Path: node.Path, Depth: depth}}
+	children, err := s.ndr.ListChildren(ctx, toNDRMeta(meta), node.ID, ndrclient.ListChildrenParams{})
+	if err != nil {
+		return nil, fmt.Errorf("list children for %d: %w", node.ID, err)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Position < children[j].Position })
+	for _, child := range children {
+		if child.DeletedAt != nil {
+			continue
+		}
+		sub, err := s.planSubtree(ctx, meta, child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, sub...)
+	}
+	return nodes, nil
+}
+
+// bulkItemID 读取 BatchItem.Result 中的整数字段（落库后为 float64）
+func bulkItemID(result database.JSONMap, key string) int64 {
+	switch v := result[key].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	}
+	return 0
+}
+
+// bulkItemOptionalID 读取可为空的 ID 字段，缺失表示根层级
+func bulkItemOptionalID(result database.JSONMap, key string) *int64 {
+	if _, ok := result[key]; !ok || result[key] == nil {
+		return nil
+	}
+	id := bulkItemID(result, key)
+	return &id
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"net/url"
 	"testing"
 	"time"
@@ -338,6 +339,30 @@ func (f *fakeNDR) GetNodeByPath(_ context.Context, _ ndrclient.RequestMeta, path
 	return ndrclient.Node{}, errors.New("node not found by path")
 }
 
+func (f *fakeNDR) ListNodesAll(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) ([]ndrclient.Node, error) {
+	return ndrclient.Collect(ndrclient.StreamNodes(ctx, f, meta, params))
+}
+
+func (f *fakeNDR) ListDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamDocuments(ctx, f, meta, query))
+}
+
+func (f *fakeNDR) ListNodeDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamNodeDocuments(ctx, f, meta, id, query))
+}
+
+func (f *fakeNDR) StreamNodes(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) iter.Seq2[ndrclient.Node, error] {
+	return ndrclient.StreamNodes(ctx, f, meta, params)
+}
+
+func (f *fakeNDR) StreamDocuments(ctx context.Context, meta ndrclient.RequestMeta, query url.Values) iter.Seq2[ndrclient.Document, error] {
+	return ndrclient.StreamDocuments(ctx, f, meta, query)
+}
+
+func (f *fakeNDR) StreamNodeDocuments(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) iter.Seq2[ndrclient.Document, error] {
+	return ndrclient.StreamNodeDocuments(ctx, f, meta, id, query)
+}
+
 func (f *fakeNDR) ListNodeDocumentsByPath(_ context.Context, _ ndrclient.RequestMeta, path string, query url.Values) (ndrclient.DocumentsPage, error) {
 	// 简单实现：返回模拟的文档列表
 	if f.nodeDocsErr != nil {
@@ -506,7 +531,10 @@ func TestMoveCategory(t *testing.T) {
 	child := sampleNode(11, "Child", "/child", ptr[int64](10), 2, now, now)
 	fake.getNodes[10] = parent
 	fake.updateResp = child
+	fake.nodeDocsResp = []ndrclient.Document{{ID: 501}, {ID: 502}}
 	svc := NewService(cache.NewNoop(), fake, nil)
+	indexer := &recordingIndexer{}
+	svc.SetDocumentIndexer(indexer)
 
 	cat, err := svc.MoveCategory(context.Background(), RequestMeta{}, 11, MoveCategoryRequest{NewParentID: ptr[int64](10), ParentSpecified: true})
 	if err != nil {
@@ -515,6 +543,19 @@ func TestMoveCategory(t *testing.T) {
 	if cat.ParentID == nil || *cat.ParentID != 10 {
 		t.Fatalf("expected parent id 10")
 	}
+	// 子树内的文档可能换了课程，需要重建检索索引
+	if len(indexer.ids) != 2 || indexer.ids[0] != 501 || indexer.ids[1] != 502 {
+		t.Fatalf("expected subtree documents to be reindexed, got %v", indexer.ids)
+	}
+}
+
+// recordingIndexer 记录入队重建索引的文档 ID
+type recordingIndexer struct {
+	ids []int64
+}
+
+func (r *recordingIndexer) EnqueueDocuments(docIDs ...int64) {
+	r.ids = append(r.ids, docIDs...)
 }
 
 func TestGetCategoryTree(t *testing.T) {
@@ -3,7 +3,11 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/yjxt/ydms/backend/internal/cache"
 	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
 	"gorm.io/gorm"
@@ -14,17 +18,46 @@ type CourseService struct {
 	db          *gorm.DB
 	ndr         ndrclient.Client
 	userService *UserService
+	cache       cache.Provider
+	audit       *AuditService
+	indexer     DocumentIndexer // 全文检索索引（可选）
+	refs        *ReferenceIndex // 文档引用索引（可选）
+	documents   *Service        // 导入时经其创建文档（校验、索引与审计）
+	transfer    *http.Client    // 资产上传/下载使用的 HTTP 客户端
 }
 
 // NewCourseService 创建课程服务
-func NewCourseService(db *gorm.DB, ndr ndrclient.Client, userService *UserService) *CourseService {
+func NewCourseService(db *gorm.DB, ndr ndrclient.Client, userService *UserService, cache cache.Provider) *CourseService {
 	return &CourseService{
 		db:          db,
 		ndr:         ndr,
 		userService: userService,
+		cache:       cache,
+		audit:       NewAuditService(db),
+		transfer:    &http.Client{Timeout: 5 * time.Minute},
 	}
 }
 
+// Roles 返回用于权限判断的角色服务
+func (s *CourseService) Roles() *RoleService {
+	return NewRoleService(s.db)
+}
+
+// SetDocumentIndexer 设置导入文档后使用的全文检索索引
+func (s *CourseService) SetDocumentIndexer(indexer DocumentIndexer) {
+	s.indexer = indexer
+}
+
+// SetDocumentService 设置导入课程时创建文档使用的文档服务
+func (s *CourseService) SetDocumentService(documents *Service) {
+	s.documents = documents
+}
+
+// SetReferenceIndex 设置导入文档后同步的引用索引
+func (s *CourseService) SetReferenceIndex(refs *ReferenceIndex) {
+	s.refs = refs
+}
+
 // CourseCreateRequest 创建课程请求
 type CourseCreateRequest struct {
 	Name string `json:"name"`
@@ -55,34 +88,53 @@ func (s *CourseService) CreateCourse(ctx context.Context, meta RequestMeta, req
 	if err != nil {
 		return nil, err
 	}
+	invalidateNodes(ctx, s.cache)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "course.create",
+		ResourceType: AuditResourceCourse,
+		ResourceID:   strconv.FormatInt(node.ID, 10),
+		Details:      map[string]interface{}{"name": node.Name, "slug": slug},
+	})
 
 	return &node, nil
 }
 
-// ListCourses 列出课程（根据用户权限过滤）
-func (s *CourseService) ListCourses(ctx context.Context, meta RequestMeta, userID uint, role string) ([]*ndrclient.Node, error) {
-	// 如果是超级管理员，返回所有课程
-	if role == "super_admin" {
-		// TODO: 实现获取所有根节点的逻辑
-		// 目前 NDR API 可能没有直接的"获取所有根节点"接口
-		// 可以通过 ListNodes 配合过滤实现
-		return []*ndrclient.Node{}, nil
+// CourseWithRole 课程节点及调用者在该课程中的角色
+type CourseWithRole struct {
+	ndrclient.Node
+	Role string `json:"role"`
+}
+
+// ListCourses 列出课程（根据用户权限过滤），并附带用户在各课程中的生效角色
+func (s *CourseService) ListCourses(ctx context.Context, meta RequestMeta, userID uint, role string) ([]*CourseWithRole, error) {
+	// 拥有 courses:all 的角色可以访问所有课程：返回全部根节点，生效角色即全局角色
+	if s.Roles().HasPermission(ctx, role, database.PermCoursesAll) {
+		courses := []*CourseWithRole{}
+		for node, err := range s.ndr.StreamNodes(ctx, toNDRMeta(meta), ndrclient.ListNodesParams{Size: 200}) {
+			if err != nil {
+				return nil, err
+			}
+			if node.ParentID == nil {
+				courses = append(courses, &CourseWithRole{Node: node, Role: role})
+			}
+		}
+		return courses, nil
 	}
 
 	// 其他角色：只返回有权限的课程
-	userCourses, err := s.userService.GetUserCourses(userID)
+	userCourses, err := s.userService.GetUserCourseRoles(userID)
 	if err != nil {
 		return nil, err
 	}
 
 	// 根据课程 ID 列表获取节点详情
-	courses := []*ndrclient.Node{}
-	for _, courseID := range userCourses {
-		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), courseID, ndrclient.GetNodeOptions{})
+	courses := []*CourseWithRole{}
+	for _, course := range userCourses {
+		node, err := s.ndr.GetNode(ctx, toNDRMeta(meta), course.RootNodeID, ndrclient.GetNodeOptions{})
 		if err != nil {
 			continue // 跳过错误的节点
 		}
-		courses = append(courses, &node)
+		courses = append(courses, &CourseWithRole{Node: node, Role: course.Role})
 	}
 
 	return courses, nil
@@ -95,10 +147,16 @@ func (s *CourseService) DeleteCourse(ctx context.Context, meta RequestMeta, cour
 	if err != nil {
 		return err
 	}
+	invalidateNodes(ctx, s.cache)
 
 	// 清理 YDMS 数据库中相关的权限记录
 	// 删除所有与该课程相关的权限
 	s.db.Where("root_node_id = ?", courseID).Delete(&database.CoursePermission{})
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "course.delete",
+		ResourceType: AuditResourceCourse,
+		ResourceID:   strconv.FormatInt(courseID, 10),
+	})
 
 	return nil
 }
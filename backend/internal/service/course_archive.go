@@ -0,0 +1,714 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// CourseArchiveVersion 课程归档格式版本，导入时不兼容的版本会被拒绝
+const CourseArchiveVersion = 1
+
+const courseArchiveManifest = "manifest.json"
+
+// 导入时归档条目解压后的大小上限（单个条目 / 全部条目），防止压缩炸弹
+var (
+	courseArchiveMaxEntryBytes int64 = 256 << 20
+	courseArchiveMaxTotalBytes int64 = 2 << 30
+)
+
+// assetPathPattern 匹配文档中引用的 NDR 资产路径，例如 /ndr-assets/assets/12/image.png
+var assetPathPattern = regexp.MustCompile(`/assets/(\d+)/`)
+
+// CourseArchiveManifest 课程归档的清单（manifest.json）
+// 所有 ID 均为导出实例中的原始 ID，导入时重新映射
+type CourseArchiveManifest struct {
+	Version    int                     `json:"version"`
+	ExportedAt time.Time               `json:"exported_at"`
+	Root       CourseArchiveNode       `json:"root"`
+	Documents  []CourseArchiveDocument `json:"documents"`
+	Assets     []CourseArchiveAsset    `json:"assets"`
+}
+
+// CourseArchiveNode 分类树中的一个节点，子节点按 position 排序
+type CourseArchiveNode struct {
+	ID       int64               `json:"id"`
+	Name     string              `json:"name"`
+	Slug     string              `json:"slug"`
+	Type     *string             `json:"type,omitempty"`
+	Position int                 `json:"position"`
+	Children []CourseArchiveNode `json:"children,omitempty"`
+}
+
+// CourseArchiveDocument 文档内容及其在分类树中的绑定
+type CourseArchiveDocument struct {
+	ID       int64          `json:"id"`
+	Title    string         `json:"title"`
+	Type     *string        `json:"type,omitempty"`
+	Position int            `json:"position"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Content  map[string]any `json:"content,omitempty"`
+	Bindings []int64        `json:"bindings"`
+	Sources  []int64        `json:"sources,omitempty"`
+}
+
+// CourseArchiveAsset 文档引用的资产，文件内容保存在归档的 File 条目中
+type CourseArchiveAsset struct {
+	ID          int64   `json:"id"`
+	Filename    string  `json:"filename"`
+	ContentType *string `json:"content_type,omitempty"`
+	SizeBytes   int64   `json:"size_bytes"`
+	Path        string  `json:"path"`
+	File        string  `json:"file"`
+}
+
+// CourseImportRequest 导入时可覆盖的课程名称与 slug
+type CourseImportRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CourseImportResult 导入结果及新旧 ID 映射
+type CourseImportResult struct {
+	Course            ndrclient.Node  `json:"course"`
+	Nodes             int             `json:"nodes"`
+	Documents         int             `json:"documents"`
+	Assets            int             `json:"assets"`
+	DroppedReferences int             `json:"dropped_references"`
+	NodeIDMap         map[int64]int64 `json:"node_id_map"`
+	DocumentIDMap     map[int64]int64 `json:"document_id_map"`
+}
+
+// ExportCourse 将课程（根节点及其子树）导出为 zip 归档写入 w
+func (s *CourseService) ExportCourse(ctx context.Context, meta RequestMeta, rootID int64, w io.Writer) (*CourseArchiveManifest, error) {
+	manifest, err := s.PrepareCourseExport(ctx, meta, rootID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.WriteCourseArchive(ctx, meta, manifest, w); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// PrepareCourseExport 收集课程的分类树与文档；资产在 WriteCourseArchive 写出归档时才下载
+func (s *CourseService) PrepareCourseExport(ctx context.Context, meta RequestMeta, rootID int64) (*CourseArchiveManifest, error) {
+	ndrMeta := toNDRMeta(meta)
+	root, err := s.ndr.GetNode(ctx, ndrMeta, rootID, ndrclient.GetNodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if root.ParentID != nil {
+		return nil, newValidationError("node %d is not a course root", rootID)
+	}
+
+	manifest := &CourseArchiveManifest{Version: CourseArchiveVersion, ExportedAt: time.Now().UTC()}
+	docs := make(map[int64]*CourseArchiveDocument)
+	var order []int64
+	addDoc := func(id int64) (*CourseArchiveDocument, error) {
+		if doc, ok := docs[id]; ok {
+			return doc, nil
+		}
+		full, err := s.ndr.GetDocument(ctx, ndrMeta, id)
+		if err != nil {
+			return nil, fmt.Errorf("get document %d: %w", id, err)
+		}
+		doc := &CourseArchiveDocument{
+			ID:       full.ID,
+			Title:    full.Title,
+			Type:     full.Type,
+			Position: full.Position,
+			Metadata: full.Metadata,
+			Content:  full.Content,
+			Bindings: []int64{},
+		}
+		docs[id] = doc
+		order = append(order, id)
+		return doc, nil
+	}
+
+	var walk func(node ndrclient.Node) (CourseArchiveNode, error)
+	walk = func(node ndrclient.Node) (CourseArchiveNode, error) {
+		out := CourseArchiveNode{ID: node.ID, Name: node.Name, Slug: node.Slug, Type: node.Type, Position: node.Position}
+
+		sources, err := s.ndr.ListSourceDocuments(ctx, ndrMeta, node.ID)
+		if err != nil {
+			return out, fmt.Errorf("list source documents of node %d: %w", node.ID, err)
+		}
+		isSource := make(map[int64]bool, len(sources))
+		for _, src := range sources {
+			isSource[src.DocumentID] = true
+			doc, err := addDoc(src.DocumentID)
+			if err != nil {
+				return out, err
+			}
+			doc.Sources = append(doc.Sources, node.ID)
+		}
+
+		nodeDocs, err := getNodeDocuments(ctx, s.ndr, meta, node.ID)
+		if err != nil {
+			return out, fmt.Errorf("list documents of node %d: %w", node.ID, err)
+		}
+		for _, item := range nodeDocs {
+			if isSource[item.ID] || item.DeletedAt != nil {
+				continue
+			}
+			doc, err := addDoc(item.ID)
+			if err != nil {
+				return out, err
+			}
+			doc.Bindings = append(doc.Bindings, node.ID)
+		}
+
+		children, err := s.ndr.ListChildren(ctx, ndrMeta, node.ID, ndrclient.ListChildrenParams{})
+		if err != nil {
+			return out, fmt.Errorf("list children of node %d: %w", node.ID, err)
+		}
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Position < children[j].Position
+		})
+		for _, child := range children {
+			if child.DeletedAt != nil {
+				continue
+			}
+			exported, err := walk(child)
+			if err != nil {
+				return out, err
+			}
+			out.Children = append(out.Children, exported)
+		}
+		return out, nil
+	}
+
+	if manifest.Root, err = walk(root); err != nil {
+		return nil, err
+	}
+
+	for _, id := range order {
+		manifest.Documents = append(manifest.Documents, *docs[id])
+	}
+	return manifest, nil
+}
+
+// WriteCourseArchive 把资产与清单以流的方式写入 zip 归档，已不存在的资产会被跳过
+// 写出过程中失败时 w 中可能已有部分内容，调用方无法再返回错误响应
+func (s *CourseService) WriteCourseArchive(ctx context.Context, meta RequestMeta, manifest *CourseArchiveManifest, w io.Writer) error {
+	ndrMeta := toNDRMeta(meta)
+	assetIDs := make(map[int64]bool)
+	for _, doc := range manifest.Documents {
+		collectAssetIDs(doc.Content, assetIDs)
+		collectAssetIDs(doc.Metadata, assetIDs)
+	}
+
+	zw := zip.NewWriter(w)
+	manifest.Assets = nil
+	for _, id := range sortedIDs(assetIDs) {
+		entry, err := s.writeArchiveAsset(ctx, ndrMeta, zw, id)
+		if err != nil {
+			var ndrErr *ndrclient.Error
+			if errors.As(err, &ndrErr) && ndrErr.StatusCode == http.StatusNotFound {
+				log.Printf("[course] export skipped missing asset id=%d course=%d", id, manifest.Root.ID)
+				continue
+			}
+			return fmt.Errorf("export asset %d: %w", id, err)
+		}
+		manifest.Assets = append(manifest.Assets, entry)
+	}
+
+	fw, err := zw.Create(courseArchiveManifest)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "course.export",
+		ResourceType: AuditResourceCourse,
+		ResourceID:   strconv.FormatInt(manifest.Root.ID, 10),
+		Details: map[string]interface{}{
+			"documents": len(manifest.Documents),
+			"assets":    len(manifest.Assets),
+		},
+	})
+	return nil
+}
+
+// courseImportCreated 导入过程中已创建的文档与资产，失败时据此清理
+type courseImportCreated struct {
+	documents []int64
+	assets    []int64
+}
+
+// ImportCourse 从 zip 归档在新的根节点下重建课程
+// 节点、文档与资产均重新创建，metadata.references 按新 ID 重写，指向归档外文档的引用会被丢弃
+func (s *CourseService) ImportCourse(ctx context.Context, meta RequestMeta, r io.ReaderAt, size int64, req CourseImportRequest) (*CourseImportResult, error) {
+	if s.documents == nil {
+		return nil, errors.New("document service is not configured")
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, newValidationError("invalid course archive: %v", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	var declared uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > uint64(courseArchiveMaxEntryBytes) {
+			return nil, newValidationError("archive entry %s exceeds %d bytes", f.Name, courseArchiveMaxEntryBytes)
+		}
+		declared += f.UncompressedSize64
+		files[f.Name] = f
+	}
+	if declared > uint64(courseArchiveMaxTotalBytes) {
+		return nil, newValidationError("course archive exceeds %d bytes uncompressed", courseArchiveMaxTotalBytes)
+	}
+	// 条目头中的大小可被伪造，读取时按实际解压字节再次限制
+	budget := courseArchiveMaxTotalBytes
+	manifestFile, ok := files[courseArchiveManifest]
+	if !ok {
+		return nil, newValidationError("course archive has no %s", courseArchiveManifest)
+	}
+	var manifest CourseArchiveManifest
+	if err := readArchiveJSON(manifestFile, &budget, &manifest); err != nil {
+		return nil, newValidationError("invalid %s: %v", courseArchiveManifest, err)
+	}
+	if manifest.Version != CourseArchiveVersion {
+		return nil, newValidationError("unsupported course archive version %d", manifest.Version)
+	}
+	for _, asset := range manifest.Assets {
+		if _, ok := files[asset.File]; !ok {
+			return nil, newValidationError("course archive is missing asset file %s", asset.File)
+		}
+	}
+	// 创建任何内容前先校验全部文档，避免导入到一半才因数据不合法失败
+	for _, doc := range manifest.Documents {
+		if err := validateDocumentCreate(DocumentCreateRequest{Title: doc.Title, Type: doc.Type, Content: doc.Content, Metadata: doc.Metadata}); err != nil {
+			return nil, newValidationError("document %d (%s): %v", doc.ID, doc.Title, err)
+		}
+	}
+
+	ndrMeta := toNDRMeta(meta)
+	result := &CourseImportResult{
+		NodeIDMap:     make(map[int64]int64),
+		DocumentIDMap: make(map[int64]int64),
+	}
+
+	name := req.Name
+	if name == "" {
+		name = manifest.Root.Name
+	}
+	slug := req.Slug
+	if slug == "" {
+		slug = manifest.Root.Slug
+	}
+	if slug == "" {
+		slug = slugify(name)
+	}
+	root, err := s.ndr.CreateNode(ctx, ndrMeta, ndrclient.NodeCreate{Name: name, Slug: &slug, Type: manifest.Root.Type})
+	if err != nil {
+		return nil, err
+	}
+	invalidateNodes(ctx, s.cache)
+	result.Course = root
+	result.NodeIDMap[manifest.Root.ID] = root.ID
+
+	created := &courseImportCreated{}
+	if err := s.importArchiveContent(ctx, meta, &manifest, files, &budget, root, result, created); err != nil {
+		log.Printf("[course] import failed, removing partial course id=%d err=%v", root.ID, err)
+		if cleanupErr := s.removePartialImport(ctx, ndrMeta, root.ID, created); cleanupErr != nil {
+			return nil, fmt.Errorf("import course: %w (partial course %d could not be fully removed: %v)", err, root.ID, cleanupErr)
+		}
+		return nil, fmt.Errorf("import course: %w", err)
+	}
+
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "course.import",
+		ResourceType: AuditResourceCourse,
+		ResourceID:   strconv.FormatInt(root.ID, 10),
+		Details: map[string]interface{}{
+			"name":               root.Name,
+			"source_course_id":   manifest.Root.ID,
+			"nodes":              result.Nodes,
+			"documents":          result.Documents,
+			"assets":             result.Assets,
+			"dropped_references": result.DroppedReferences,
+		},
+	})
+	return result, nil
+}
+
+// removePartialImport 彻底删除导入失败时已创建的文档、资产与课程子树，继续清理其余内容并汇总错误
+func (s *CourseService) removePartialImport(ctx context.Context, ndrMeta ndrclient.RequestMeta, rootID int64, created *courseImportCreated) error {
+	var errs []error
+	for _, id := range created.documents {
+		if err := s.ndr.PurgeDocument(ctx, ndrMeta, id); err != nil {
+			errs = append(errs, fmt.Errorf("purge document %d: %w", id, err))
+			continue
+		}
+		if s.refs != nil {
+			if err := s.refs.RemoveSource(ctx, id); err != nil {
+				log.Printf("[course] remove references failed doc=%d err=%v", id, err)
+			}
+		}
+	}
+	for _, id := range created.assets {
+		if err := s.ndr.DeleteAsset(ctx, ndrMeta, id); err != nil {
+			errs = append(errs, fmt.Errorf("delete asset %d: %w", id, err))
+		}
+	}
+	if err := s.ndr.PurgeNode(ctx, ndrMeta, rootID); err != nil {
+		errs = append(errs, fmt.Errorf("purge node %d: %w", rootID, err))
+	}
+	invalidateNodes(ctx, s.cache)
+	invalidateDocuments(ctx, s.cache, created.documents...)
+	if s.indexer != nil {
+		s.indexer.EnqueueDocuments(created.documents...)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *CourseService) importArchiveContent(
+	ctx context.Context,
+	meta RequestMeta,
+	manifest *CourseArchiveManifest,
+	files map[string]*zip.File,
+	budget *int64,
+	root ndrclient.Node,
+	result *CourseImportResult,
+	created *courseImportCreated,
+) error {
+	ndrMeta := toNDRMeta(meta)
+	var createChildren func(parent ndrclient.Node, children []CourseArchiveNode) error
+	createChildren = func(parent ndrclient.Node, children []CourseArchiveNode) error {
+		for _, child := range children {
+			slug := child.Slug
+			node, err := s.ndr.CreateNode(ctx, ndrMeta, ndrclient.NodeCreate{
+				Name:       child.Name,
+				Slug:       &slug,
+				ParentPath: &parent.Path,
+				Type:       child.Type,
+			})
+			if err != nil {
+				return fmt.Errorf("create node %q: %w", child.Name, err)
+			}
+			result.NodeIDMap[child.ID] = node.ID
+			result.Nodes++
+			if err := createChildren(node, child.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := createChildren(root, manifest.Root.Children); err != nil {
+		return err
+	}
+	invalidateNodes(ctx, s.cache)
+
+	// 重新上传资产，并记录旧路径到新路径的替换
+	var replacements []string
+	for _, asset := range manifest.Assets {
+		data, err := readArchiveFile(files[asset.File], budget)
+		if err != nil {
+			return fmt.Errorf("read asset %s: %w", asset.File, err)
+		}
+		uploaded, err := s.uploadAsset(ctx, ndrMeta, asset, data)
+		if err != nil {
+			return fmt.Errorf("upload asset %s: %w", asset.Filename, err)
+		}
+		created.assets = append(created.assets, uploaded.ID)
+		replacements = append(replacements, asset.Path, "/"+uploaded.Bucket+"/"+uploaded.ObjectKey)
+		result.Assets++
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	// 先创建全部文档，再按新 ID 回写引用
+	pendingRefs := make(map[int64][]any)
+	for _, doc := range manifest.Documents {
+		metadata, _ := rewriteArchiveStrings(doc.Metadata, replacer).(map[string]any)
+		content, _ := rewriteArchiveStrings(doc.Content, replacer).(map[string]any)
+		if refs, ok := metadata["references"].([]any); ok {
+			pendingRefs[doc.ID] = refs
+			delete(metadata, "references")
+		}
+		position := doc.Position
+		newDoc, err := s.documents.CreateDocument(ctx, meta, DocumentCreateRequest{
+			Title:    doc.Title,
+			Metadata: metadata,
+			Content:  content,
+			Type:     doc.Type,
+			Position: &position,
+		})
+		if err != nil {
+			return fmt.Errorf("create document %q: %w", doc.Title, err)
+		}
+		result.DocumentIDMap[doc.ID] = newDoc.ID
+		created.documents = append(created.documents, newDoc.ID)
+		result.Documents++
+
+		for _, oldNodeID := range doc.Bindings {
+			nodeID, ok := result.NodeIDMap[oldNodeID]
+			if !ok {
+				continue
+			}
+			if err := s.ndr.BindDocument(ctx, ndrMeta, nodeID, newDoc.ID); err != nil {
+				return fmt.Errorf("bind document %d to node %d: %w", newDoc.ID, nodeID, err)
+			}
+		}
+		for _, oldNodeID := range doc.Sources {
+			nodeID, ok := result.NodeIDMap[oldNodeID]
+			if !ok {
+				continue
+			}
+			if _, err := s.ndr.BindSourceDocument(ctx, ndrMeta, nodeID, newDoc.ID); err != nil {
+				return fmt.Errorf("bind source document %d to node %d: %w", newDoc.ID, nodeID, err)
+			}
+		}
+	}
+
+	for _, doc := range manifest.Documents {
+		refs, ok := pendingRefs[doc.ID]
+		if !ok {
+			continue
+		}
+		remapped := make([]any, 0, len(refs))
+		for _, raw := range refs {
+			ref, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			newID, ok := result.DocumentIDMap[referenceDocumentID(ref)]
+			if !ok {
+				result.DroppedReferences++
+				continue
+			}
+			ref["document_id"] = newID
+			remapped = append(remapped, ref)
+		}
+		if len(remapped) == 0 {
+			continue
+		}
+		updated, err := s.ndr.UpdateDocument(ctx, ndrMeta, result.DocumentIDMap[doc.ID], ndrclient.DocumentUpdate{
+			Metadata: map[string]any{"references": remapped},
+		})
+		if err != nil {
+			return fmt.Errorf("update references of document %d: %w", result.DocumentIDMap[doc.ID], err)
+		}
+		if s.refs != nil {
+			if err := s.refs.SyncDocument(ctx, updated); err != nil {
+				log.Printf("[course] sync references failed doc=%d err=%v", updated.ID, err)
+			}
+		}
+	}
+
+	invalidateDocuments(ctx, s.cache, created.documents...)
+	if s.indexer != nil {
+		s.indexer.EnqueueDocuments(created.documents...)
+	}
+	return nil
+}
+
+// writeArchiveAsset 读取资产元数据，并把预签名 URL 下载的内容直接写入归档条目
+func (s *CourseService) writeArchiveAsset(ctx context.Context, ndrMeta ndrclient.RequestMeta, zw *zip.Writer, id int64) (CourseArchiveAsset, error) {
+	asset, err := s.ndr.GetAsset(ctx, ndrMeta, id)
+	if err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	link, err := s.ndr.GetAssetDownloadURL(ctx, ndrMeta, id)
+	if err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, link.URL, nil)
+	if err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	resp, err := s.transfer.Do(httpReq)
+	if err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return CourseArchiveAsset{}, fmt.Errorf("download asset: unexpected status %s", resp.Status)
+	}
+	entry := CourseArchiveAsset{
+		ID:          asset.ID,
+		Filename:    asset.Filename,
+		ContentType: asset.ContentType,
+		Path:        "/" + asset.Bucket + "/" + asset.ObjectKey,
+		File:        fmt.Sprintf("assets/%d/%s", asset.ID, path.Base(asset.Filename)),
+	}
+	fw, err := zw.Create(entry.File)
+	if err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	if entry.SizeBytes, err = io.Copy(fw, resp.Body); err != nil {
+		return CourseArchiveAsset{}, err
+	}
+	return entry, nil
+}
+
+// uploadAsset 按 NDR 的分片上传流程上传资产内容，失败时中止上传
+func (s *CourseService) uploadAsset(ctx context.Context, ndrMeta ndrclient.RequestMeta, asset CourseArchiveAsset, data []byte) (ndrclient.Asset, error) {
+	initReq := ndrclient.AssetInitRequest{Filename: asset.Filename, SizeBytes: int64(len(data))}
+	if asset.ContentType != nil {
+		initReq.ContentType = *asset.ContentType
+	}
+	session, err := s.ndr.InitMultipartUpload(ctx, ndrMeta, initReq)
+	if err != nil {
+		return ndrclient.Asset{}, err
+	}
+	assetID := session.Asset.ID
+
+	uploaded, err := s.uploadAssetParts(ctx, ndrMeta, assetID, session.PartSizeBytes, data)
+	if err != nil {
+		if abortErr := s.ndr.AbortMultipartUpload(ctx, ndrMeta, assetID); abortErr != nil {
+			log.Printf("[course] abort asset upload failed id=%d err=%v", assetID, abortErr)
+		}
+		return ndrclient.Asset{}, err
+	}
+	return uploaded, nil
+}
+
+func (s *CourseService) uploadAssetParts(ctx context.Context, ndrMeta ndrclient.RequestMeta, assetID int64, partSize int, data []byte) (ndrclient.Asset, error) {
+	if partSize <= 0 || partSize > len(data) {
+		partSize = len(data)
+	}
+	count := 1
+	if partSize > 0 {
+		count = (len(data) + partSize - 1) / partSize
+	}
+	numbers := make([]int, count)
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	urls, err := s.ndr.GetAssetPartURLs(ctx, ndrMeta, assetID, numbers)
+	if err != nil {
+		return ndrclient.Asset{}, err
+	}
+
+	parts := make([]ndrclient.AssetCompletedPart, 0, len(urls.URLs))
+	for _, part := range urls.URLs {
+		start := (part.PartNumber - 1) * partSize
+		end := start + partSize
+		if start < 0 || start > len(data) {
+			return ndrclient.Asset{}, fmt.Errorf("unexpected part number %d", part.PartNumber)
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, part.URL, bytes.NewReader(data[start:end]))
+		if err != nil {
+			return ndrclient.Asset{}, err
+		}
+		resp, err := s.transfer.Do(httpReq)
+		if err != nil {
+			return ndrclient.Asset{}, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return ndrclient.Asset{}, fmt.Errorf("upload part %d: unexpected status %s", part.PartNumber, resp.Status)
+		}
+		parts = append(parts, ndrclient.AssetCompletedPart{PartNumber: part.PartNumber, ETag: resp.Header.Get("ETag")})
+	}
+	return s.ndr.CompleteMultipartUpload(ctx, ndrMeta, assetID, parts)
+}
+
+// collectAssetIDs 递归收集字符串中引用的资产 ID
+func collectAssetIDs(value any, ids map[int64]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, match := range assetPathPattern.FindAllStringSubmatch(v, -1) {
+			if id, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+				ids[id] = true
+			}
+		}
+	case map[string]any:
+		for _, item := range v {
+			collectAssetIDs(item, ids)
+		}
+	case []any:
+		for _, item := range v {
+			collectAssetIDs(item, ids)
+		}
+	}
+}
+
+// rewriteArchiveStrings 返回对所有字符串应用替换后的副本
+func rewriteArchiveStrings(value any, replacer *strings.Replacer) any {
+	switch v := value.(type) {
+	case string:
+		return replacer.Replace(v)
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = rewriteArchiveStrings(item, replacer)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = rewriteArchiveStrings(item, replacer)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// readArchiveFile 读取归档条目，解压后超过单条目上限或剩余总量 budget 时返回校验错误
+func readArchiveFile(f *zip.File, budget *int64) ([]byte, error) {
+	limit := min(courseArchiveMaxEntryBytes, *budget)
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, newValidationError("archive entry %s exceeds the uncompressed size limit", f.Name)
+	}
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+func readArchiveJSON(f *zip.File, budget *int64, v any) error {
+	data, err := readArchiveFile(f, budget)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
+)
+
+// archiveNDR 在内存中保存节点、文档、绑定与资产的 fakeNDR
+type archiveNDR struct {
+	*fakeNDR
+	nextID   int64
+	docs     map[int64]ndrclient.Document
+	nodeDocs map[int64][]int64
+	sources  map[int64][]int64
+	assets   map[int64]ndrclient.Asset
+	blobs    map[int64][]byte
+	server   *httptest.Server
+
+	failBindSource bool
+	deletedAssets  []int64
+}
+
+func newArchiveNDR() *archiveNDR {
+	f := &archiveNDR{
+		fakeNDR:  newFakeNDR(),
+		nextID:   100,
+		docs:     make(map[int64]ndrclient.Document),
+		nodeDocs: make(map[int64][]int64),
+		sources:  make(map[int64][]int64),
+		assets:   make(map[int64]ndrclient.Asset),
+		blobs:    make(map[int64][]byte),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int64
+		var part int
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/download/"):
+			fmt.Sscanf(r.URL.Path, "/download/%d", &id)
+			w.Write(f.blobs[id])
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/"):
+			fmt.Sscanf(r.URL.Path, "/upload/%d/%d", &id, &part)
+			data, _ := io.ReadAll(r.Body)
+			f.blobs[id] = append(f.blobs[id], data...)
+			w.Header().Set("ETag", fmt.Sprintf("etag-%d", part))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return f
+}
+
+func (f *archiveNDR) id() int64 {
+	f.nextID++
+	return f.nextID
+}
+
+func (f *archiveNDR) CreateNode(_ context.Context, _ ndrclient.RequestMeta, body ndrclient.NodeCreate) (ndrclient.Node, error) {
+	node := ndrclient.Node{ID: f.id(), Name: body.Name, Slug: *body.Slug, Type: body.Type, Path: "/" + *body.Slug}
+	if body.ParentPath != nil {
+		for _, parent := range f.getNodes {
+			if parent.Path == *body.ParentPath {
+				node.ParentID = &parent.ID
+				node.Path = parent.Path + node.Path
+			}
+		}
+	}
+	f.getNodes[node.ID] = node
+	return node, nil
+}
+
+func (f *archiveNDR) ListChildren(_ context.Context, _ ndrclient.RequestMeta, id int64, _ ndrclient.ListChildrenParams) ([]ndrclient.Node, error) {
+	var children []ndrclient.Node
+	for _, node := range f.getNodes {
+		if node.ParentID != nil && *node.ParentID == id {
+			children = append(children, node)
+		}
+	}
+	return children, nil
+}
+
+func (f *archiveNDR) ListNodeDocuments(_ context.Context, _ ndrclient.RequestMeta, id int64, _ url.Values) (ndrclient.DocumentsPage, error) {
+	var items []ndrclient.Document
+	for _, docID := range append(f.nodeDocs[id], f.sources[id]...) {
+		items = append(items, f.docs[docID])
+	}
+	return ndrclient.DocumentsPage{Page: 1, Size: 100, Total: len(items), Items: items}, nil
+}
+
+func (f *archiveNDR) ListNodeDocumentsAll(ctx context.Context, meta ndrclient.RequestMeta, id int64, query url.Values) ([]ndrclient.Document, error) {
+	return ndrclient.Collect(ndrclient.StreamNodeDocuments(ctx, f, meta, id, query))
+}
+
+func (f *archiveNDR) ListSourceDocuments(_ context.Context, _ ndrclient.RequestMeta, id int64) ([]ndrclient.SourceDocument, error) {
+	var items []ndrclient.SourceDocument
+	for _, docID := range f.sources[id] {
+		items = append(items, ndrclient.SourceDocument{NodeID: id, DocumentID: docID, RelationType: "source"})
+	}
+	return items, nil
+}
+
+func (f *archiveNDR) GetDocument(_ context.Context, _ ndrclient.RequestMeta, id int64) (ndrclient.Document, error) {
+	return f.docs[id], nil
+}
+
+func (f *archiveNDR) CreateDocument(_ context.Context, _ ndrclient.RequestMeta, body ndrclient.DocumentCreate) (ndrclient.Document, error) {
+	doc := ndrclient.Document{ID: f.id(), Title: body.Title, Type: body.Type, Position: *body.Position, Content: body.Content, Metadata: body.Metadata}
+	f.docs[doc.ID] = doc
+	return doc, nil
+}
+
+func (f *archiveNDR) UpdateDocument(_ context.Context, _ ndrclient.RequestMeta, id int64, body ndrclient.DocumentUpdate) (ndrclient.Document, error) {
+	doc := f.docs[id]
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]any{}
+	}
+	for key, value := range body.Metadata {
+		doc.Metadata[key] = value
+	}
+	f.docs[id] = doc
+	return doc, nil
+}
+
+func (f *archiveNDR) BindDocument(_ context.Context, _ ndrclient.RequestMeta, nodeID, docID int64) error {
+	f.nodeDocs[nodeID] = append(f.nodeDocs[nodeID], docID)
+	return nil
+}
+
+func (f *archiveNDR) BindSourceDocument(_ context.Context, _ ndrclient.RequestMeta, nodeID, docID int64) (ndrclient.SourceRelation, error) {
+	if f.failBindSource {
+		return ndrclient.SourceRelation{}, &ndrclient.Error{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+	}
+	f.sources[nodeID] = append(f.sources[nodeID], docID)
+	return ndrclient.SourceRelation{NodeID: nodeID, DocumentID: docID, RelationType: "source"}, nil
+}
+
+func (f *archiveNDR) GetAsset(_ context.Context, _ ndrclient.RequestMeta, id int64) (ndrclient.Asset, error) {
+	asset, ok := f.assets[id]
+	if !ok {
+		return asset, &ndrclient.Error{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	return asset, nil
+}
+
+func (f *archiveNDR) GetAssetDownloadURL(_ context.Context, _ ndrclient.RequestMeta, id int64) (ndrclient.AssetDownloadURLResponse, error) {
+	return ndrclient.AssetDownloadURLResponse{URL: fmt.Sprintf("%s/download/%d", f.server.URL, id)}, nil
+}
+
+func (f *archiveNDR) InitMultipartUpload(_ context.Context, _ ndrclient.RequestMeta, req ndrclient.AssetInitRequest) (ndrclient.AssetInitResponse, error) {
+	id := f.id()
+	asset := ndrclient.Asset{ID: id, Filename: req.Filename, Bucket: "ndr-assets", ObjectKey: fmt.Sprintf("assets/%d/%s", id, req.Filename)}
+	f.assets[id] = asset
+	return ndrclient.AssetInitResponse{Asset: asset, PartSizeBytes: 4}, nil
+}
+
+func (f *archiveNDR) GetAssetPartURLs(_ context.Context, _ ndrclient.RequestMeta, id int64, parts []int) (ndrclient.AssetPartURLsResponse, error) {
+	var resp ndrclient.AssetPartURLsResponse
+	for _, part := range parts {
+		resp.URLs = append(resp.URLs, ndrclient.AssetPartURL{PartNumber: part, URL: fmt.Sprintf("%s/upload/%d/%d", f.server.URL, id, part)})
+	}
+	return resp, nil
+}
+
+func (f *archiveNDR) DeleteAsset(_ context.Context, _ ndrclient.RequestMeta, id int64) error {
+	f.deletedAssets = append(f.deletedAssets, id)
+	return nil
+}
+
+func (f *archiveNDR) CompleteMultipartUpload(_ context.Context, _ ndrclient.RequestMeta, id int64, parts []ndrclient.AssetCompletedPart) (ndrclient.Asset, error) {
+	return f.assets[id], nil
+}
+
+// newArchiveTestService 返回带有一门示例课程（节点 1–4、文档 10–12、资产 7）的课程服务
+func newArchiveTestService(t *testing.T) (*CourseService, *archiveNDR) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	ndr := newArchiveNDR()
+	t.Cleanup(ndr.server.Close)
+	root, chapter := int64(1), int64(2)
+	ndr.getNodes[1] = ndrclient.Node{ID: 1, Name: "生物", Slug: "bio", Path: "/bio"}
+	ndr.getNodes[2] = ndrclient.Node{ID: 2, Name: "第一章", Slug: "ch1", Path: "/bio/ch1", ParentID: &root, Position: 1}
+	ndr.getNodes[3] = ndrclient.Node{ID: 3, Name: "第一节", Slug: "s1", Path: "/bio/ch1/s1", ParentID: &chapter}
+	ndr.getNodes[4] = ndrclient.Node{ID: 4, Name: "导论", Slug: "intro", Path: "/bio/intro", ParentID: &root, Position: 0}
+	ndr.assets[7] = ndrclient.Asset{ID: 7, Filename: "cell.png", Bucket: "ndr-assets", ObjectKey: "assets/7/cell.png"}
+	ndr.blobs[7] = []byte("PNG-cell-image")
+	markdown := "markdown_v1"
+	ndr.docs[10] = ndrclient.Document{ID: 10, Title: "细胞", Type: &markdown, Position: 2,
+		Content: map[string]any{"format": "markdown", "data": "![cell](/ndr-assets/assets/7/cell.png) ![gone](/ndr-assets/assets/8/x.png)"},
+		Metadata: map[string]any{"references": []any{
+			map[string]any{"document_id": float64(11), "title": "细胞膜", "added_at": "2024-01-01T00:00:00Z"},
+			map[string]any{"document_id": float64(999), "title": "外部文档", "added_at": "2024-01-01T00:00:00Z"},
+		}}}
+	ndr.docs[11] = ndrclient.Document{ID: 11, Title: "细胞膜", Type: &markdown, Metadata: map[string]any{}}
+	ndr.docs[12] = ndrclient.Document{ID: 12, Title: "教材原文", Type: &markdown}
+	ndr.nodeDocs[2] = []int64{10, 11}
+	ndr.nodeDocs[3] = []int64{11}
+	ndr.sources[2] = []int64{12}
+
+	svc := NewCourseService(db, ndr, NewUserService(db), cache.NewNoop())
+	svc.SetDocumentService(NewService(cache.NewNoop(), ndr, nil))
+	svc.transfer = ndr.server.Client()
+	return svc, ndr
+}
+
+func TestCourseService_ExportImportRoundTrip(t *testing.T) {
+	svc, ndr := newArchiveTestService(t)
+	ctx := context.Background()
+
+	var archive bytes.Buffer
+	manifest, err := svc.ExportCourse(ctx, RequestMeta{}, 1, &archive)
+	if err != nil {
+		t.Fatalf("ExportCourse() error = %v", err)
+	}
+	if len(manifest.Root.Children) != 2 || manifest.Root.Children[0].Slug != "intro" || len(manifest.Root.Children[1].Children) != 1 {
+		t.Fatalf("unexpected exported tree: %+v", manifest.Root)
+	}
+	if len(manifest.Documents) != 3 || len(manifest.Assets) != 1 || manifest.Assets[0].Path != "/ndr-assets/assets/7/cell.png" {
+		t.Fatalf("unexpected manifest: %d documents, assets %+v", len(manifest.Documents), manifest.Assets)
+	}
+
+	if _, err := svc.ExportCourse(ctx, RequestMeta{}, 2, io.Discard); err == nil {
+		t.Fatal("expected error when exporting a non-root node")
+	}
+
+	result, err := svc.ImportCourse(ctx, RequestMeta{}, bytes.NewReader(archive.Bytes()), int64(archive.Len()), CourseImportRequest{Name: "生物（生产）", Slug: "bio-prod"})
+	if err != nil {
+		t.Fatalf("ImportCourse() error = %v", err)
+	}
+	if result.Course.Path != "/bio-prod" || result.Nodes != 3 || result.Documents != 3 || result.Assets != 1 || result.DroppedReferences != 1 {
+		t.Fatalf("unexpected import result: %+v", result)
+	}
+
+	newSection := ndr.getNodes[result.NodeIDMap[3]]
+	if newSection.Path != "/bio-prod/ch1/s1" {
+		t.Fatalf("expected section to be recreated under the new root, got %+v", newSection)
+	}
+	newChapter := result.NodeIDMap[2]
+	if bound := ndr.nodeDocs[newChapter]; len(bound) != 2 || bound[0] != result.DocumentIDMap[10] {
+		t.Fatalf("unexpected chapter bindings: %v", bound)
+	}
+	if src := ndr.sources[newChapter]; len(src) != 1 || src[0] != result.DocumentIDMap[12] {
+		t.Fatalf("unexpected source bindings: %v", src)
+	}
+
+	imported := ndr.docs[result.DocumentIDMap[10]]
+	if imported.Position != 2 {
+		t.Fatalf("expected position to be preserved, got %d", imported.Position)
+	}
+	var newAsset ndrclient.Asset
+	for id, asset := range ndr.assets {
+		if id != 7 {
+			newAsset = asset
+		}
+	}
+	if string(ndr.blobs[newAsset.ID]) != "PNG-cell-image" {
+		t.Fatalf("expected asset content to be uploaded in parts, got %q", ndr.blobs[newAsset.ID])
+	}
+	data := imported.Content["data"].(string)
+	if !strings.Contains(data, "/ndr-assets/"+newAsset.ObjectKey) || strings.Contains(data, "/assets/7/") {
+		t.Fatalf("expected asset path to be rewritten, got %q", data)
+	}
+	refs := parseDocumentReferences(imported.Metadata)
+	if len(refs) != 1 || refs[0].DocumentID != result.DocumentIDMap[11] {
+		t.Fatalf("expected references to be remapped, got %+v", refs)
+	}
+
+	if _, err := svc.ImportCourse(ctx, RequestMeta{}, strings.NewReader("not a zip"), 9, CourseImportRequest{}); err == nil {
+		t.Fatal("expected validation error for invalid archive")
+	}
+}
+
+// 在 ndrfake 上导出并导入课程：引用改写只提交 references，其余 metadata 必须保留
+func TestCourseService_ImportKeepsMetadataOnNDRFake(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	fake, ndrURL := ndrfake.NewTestServer(t, ndrfake.Options{})
+	slug := func(s string) *string { return &s }
+	if err := fake.Load(ndrfake.Seed{Nodes: []ndrfake.SeedNode{
+		{Name: "生物", Slug: slug("bio")},
+		{Name: "第一章", Slug: slug("ch1"), ParentPath: slug("/bio")},
+	}}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	ndr := ndrclient.NewClient(ndrclient.NDRConfig{BaseURL: ndrURL})
+	ctx := context.Background()
+	meta := ndrclient.RequestMeta{}
+
+	course, err := ndr.GetNodeByPath(ctx, meta, "/bio", ndrclient.GetNodeOptions{})
+	if err != nil {
+		t.Fatalf("get course: %v", err)
+	}
+	chapter, err := ndr.GetNodeByPath(ctx, meta, "/bio/ch1", ndrclient.GetNodeOptions{})
+	if err != nil {
+		t.Fatalf("get chapter: %v", err)
+	}
+	markdown := "markdown_v1"
+	target, err := ndr.CreateDocument(ctx, meta, ndrclient.DocumentCreate{Title: "细胞膜", Type: &markdown,
+		Content: map[string]any{"format": "markdown", "data": "# 细胞膜"}})
+	if err != nil {
+		t.Fatalf("create target: %v", err)
+	}
+	source, err := ndr.CreateDocument(ctx, meta, ndrclient.DocumentCreate{Title: "细胞", Type: &markdown,
+		Content: map[string]any{"format": "markdown", "data": "# 细胞"},
+		Metadata: map[string]any{
+			"difficulty": float64(3),
+			"tags":       []any{"cell"},
+			"references": []any{map[string]any{"document_id": float64(target.ID), "title": "细胞膜", "added_at": "2024-01-01T00:00:00Z"}},
+		}})
+	if err != nil {
+		t.Fatalf("create source: %v", err)
+	}
+	for _, id := range []int64{source.ID, target.ID} {
+		if err := ndr.BindDocument(ctx, meta, chapter.ID, id); err != nil {
+			t.Fatalf("bind: %v", err)
+		}
+	}
+
+	svc := NewCourseService(db, ndr, NewUserService(db), cache.NewNoop())
+	svc.SetDocumentService(NewService(cache.NewNoop(), ndr, nil))
+
+	var archive bytes.Buffer
+	if _, err := svc.ExportCourse(ctx, RequestMeta{}, course.ID, &archive); err != nil {
+		t.Fatalf("ExportCourse() error = %v", err)
+	}
+	result, err := svc.ImportCourse(ctx, RequestMeta{}, bytes.NewReader(archive.Bytes()), int64(archive.Len()), CourseImportRequest{Slug: "bio-prod"})
+	if err != nil {
+		t.Fatalf("ImportCourse() error = %v", err)
+	}
+
+	imported, err := ndr.GetDocument(ctx, meta, result.DocumentIDMap[source.ID])
+	if err != nil {
+		t.Fatalf("get imported document: %v", err)
+	}
+	refs := parseDocumentReferences(imported.Metadata)
+	if len(refs) != 1 || refs[0].DocumentID != result.DocumentIDMap[target.ID] {
+		t.Fatalf("expected references to be remapped, got %+v", imported.Metadata)
+	}
+	if imported.Metadata["difficulty"] != float64(3) || len(imported.Metadata["tags"].([]any)) != 1 {
+		t.Fatalf("expected non-reference metadata to survive the remap, got %+v", imported.Metadata)
+	}
+}
+
+func TestCourseService_ImportLimitsAndCleanup(t *testing.T) {
+	svc, ndr := newArchiveTestService(t)
+	ctx := context.Background()
+
+	var archive bytes.Buffer
+	if _, err := svc.ExportCourse(ctx, RequestMeta{}, 1, &archive); err != nil {
+		t.Fatalf("ExportCourse() error = %v", err)
+	}
+	importArchive := func() (*CourseImportResult, error) {
+		return svc.ImportCourse(ctx, RequestMeta{}, bytes.NewReader(archive.Bytes()), int64(archive.Len()), CourseImportRequest{Slug: "bio-copy"})
+	}
+
+	// 条目解压后超过上限：创建任何内容前即拒绝
+	saved := courseArchiveMaxEntryBytes
+	courseArchiveMaxEntryBytes = 8
+	_, err := importArchive()
+	courseArchiveMaxEntryBytes = saved
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error for oversized entry, got %v", err)
+	}
+	nodes := len(ndr.getNodes)
+
+	// 导入中途失败：文档、资产与课程子树都被彻底删除
+	ndr.failBindSource = true
+	if _, err := importArchive(); err == nil {
+		t.Fatal("expected import to fail")
+	}
+	if len(ndr.getNodes) != nodes+4 || len(ndr.purgedNodes) != 1 {
+		t.Fatalf("expected the new root to be purged, purged=%v", ndr.purgedNodes)
+	}
+	if len(ndr.purgedDocIDs) != 1 || len(ndr.deletedAssets) != 1 {
+		t.Fatalf("expected created documents and assets to be removed, docs=%v assets=%v", ndr.purgedDocIDs, ndr.deletedAssets)
+	}
+	root := ndr.getNodes[ndr.purgedNodes[0]]
+	if root.ParentID != nil || root.Slug != "bio-copy" {
+		t.Fatalf("expected the imported root to be purged, got %+v", root)
+	}
+}
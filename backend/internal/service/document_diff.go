@@ -0,0 +1,760 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diff modes of the version diff endpoint.
+const (
+	// DiffModeText passes NDR's line diff of content.data through unchanged.
+	DiffModeText = "text"
+	// DiffModeStructured parses both versions according to their content format.
+	DiffModeStructured = "structured"
+)
+
+// maxDiffAlignCells bounds the LCS table used to align list items and blocks.
+const maxDiffAlignCells = 1 << 20
+
+// Structured diff operations.
+const (
+	DiffOpAdded   = "added"
+	DiffOpRemoved = "removed"
+	DiffOpChanged = "changed"
+)
+
+// ContentChange is one entry of a structured diff.
+//
+// Path addresses the change in the terms of the content format:
+//   - YAML/JSON content and metadata: dot separated keys with 0-based list indexes,
+//     over the same {"meta", "body"} tree the type schema validates, e.g. "body.questions[2].answer".
+//   - HTML: element steps with 1-based indexes among same-tag siblings, e.g. "ol[1]/li[3]/text()";
+//     attributes are addressed as ".../@class".
+//   - Markdown: "blocks[i]", the i-th block (heading, paragraph, list item, code, ...).
+//
+// Indexes and Line refer to the new version, except for removed entries which refer to the old one.
+type ContentChange struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Kind string `json:"kind,omitempty"` // HTML tag or markdown block kind
+	Line int    `json:"line,omitempty"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// StructuredVersionDiff is the result of comparing two versions in structured mode.
+type StructuredVersionDiff struct {
+	FromVersion int           `json:"from_version"`
+	ToVersion   int           `json:"to_version"`
+	Mode        string        `json:"mode"`
+	Format      ContentFormat `json:"format"`
+	// Fallback explains why content was compared as plain text blocks instead, e.g. a parse error.
+	Fallback        string          `json:"fallback,omitempty"`
+	TitleDiff       *DiffDetail     `json:"title_diff,omitempty"`
+	TypeDiff        *DiffDetail     `json:"type_diff,omitempty"`
+	ContentChanges  []ContentChange `json:"content_changes"`
+	MetadataChanges []ContentChange `json:"metadata_changes"`
+}
+
+// GetDocumentStructuredDiff compares two versions by parsing content.data according to its format:
+// YAML/JSON as a tree, HTML as a DOM and markdown as a list of blocks.
+func (s *Service) GetDocumentStructuredDiff(ctx context.Context, meta RequestMeta, docID int64, fromVersion, toVersion int) (StructuredVersionDiff, error) {
+	from, err := s.ndr.GetDocumentVersion(ctx, toNDRMeta(meta), docID, fromVersion)
+	if err != nil {
+		return StructuredVersionDiff{}, err
+	}
+	to, err := s.ndr.GetDocumentVersion(ctx, toNDRMeta(meta), docID, toVersion)
+	if err != nil {
+		return StructuredVersionDiff{}, err
+	}
+
+	diff := StructuredVersionDiff{
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Mode:        DiffModeStructured,
+	}
+	if from.Title != to.Title {
+		diff.TitleDiff = &DiffDetail{Old: from.Title, New: to.Title}
+	}
+	var fromType, toType string
+	if from.Type != nil {
+		fromType = *from.Type
+	}
+	if to.Type != nil {
+		toType = *to.Type
+	}
+	if fromType != toType {
+		diff.TypeDiff = &DiffDetail{Old: fromType, New: toType}
+	}
+
+	fromFormat, toFormat := versionContentFormat(from.Content, fromType), versionContentFormat(to.Content, toType)
+	fromData, _ := from.Content["data"].(string)
+	toData, _ := to.Content["data"].(string)
+	diff.Format = toFormat
+	if fromFormat != toFormat {
+		diff.Fallback = fmt.Sprintf("content format changed from %s to %s", fromFormat, toFormat)
+		diff.ContentChanges = diffMarkdownBlocks(fromData, toData)
+	} else {
+		diff.ContentChanges, diff.Fallback = diffDocumentData(toFormat, fromData, toData)
+	}
+
+	diff.MetadataChanges, err = diffMetadata(from.Metadata, to.Metadata)
+	if err != nil {
+		return StructuredVersionDiff{}, err
+	}
+	return diff, nil
+}
+
+// versionContentFormat prefers the format stored with the content and falls back to the type's format.
+func versionContentFormat(content map[string]any, docType string) ContentFormat {
+	if format, ok := content["format"].(string); ok && format != "" {
+		return ContentFormat(strings.ToLower(format))
+	}
+	return GetContentFormat(DocumentType(docType))
+}
+
+// diffDocumentData diffs content.data of one format. When either side cannot be parsed
+// the data is compared as plain text blocks and the reason is returned.
+func diffDocumentData(format ContentFormat, oldData, newData string) ([]ContentChange, string) {
+	switch format {
+	case ContentFormatYAML, ContentFormatJSON:
+		oldRoot, err := parseDiffData(format, oldData)
+		if err != nil {
+			return diffMarkdownBlocks(oldData, newData), fmt.Sprintf("parse old version: %v", err)
+		}
+		newRoot, err := parseDiffData(format, newData)
+		if err != nil {
+			return diffMarkdownBlocks(oldData, newData), fmt.Sprintf("parse new version: %v", err)
+		}
+		changes := []ContentChange{}
+		diffYAMLNodes(&changes, "", oldRoot, newRoot)
+		return changes, ""
+	case ContentFormatHTML:
+		oldTree, err := parseHTMLTree(oldData)
+		if err != nil {
+			return diffMarkdownBlocks(oldData, newData), fmt.Sprintf("parse old version: %v", err)
+		}
+		newTree, err := parseHTMLTree(newData)
+		if err != nil {
+			return diffMarkdownBlocks(oldData, newData), fmt.Sprintf("parse new version: %v", err)
+		}
+		changes := []ContentChange{}
+		diffHTMLChildren(&changes, "", oldTree.Children, newTree.Children)
+		return changes, ""
+	default:
+		return diffMarkdownBlocks(oldData, newData), ""
+	}
+}
+
+func parseDiffData(format ContentFormat, data string) (*yaml.Node, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	root, issue := parseDocumentData(format, data)
+	if issue != nil {
+		return nil, errors.New(issue.String())
+	}
+	return root, nil
+}
+
+// diffMetadata diffs metadata with the YAML engine; lines are meaningless there and dropped.
+func diffMetadata(oldMeta, newMeta map[string]any) ([]ContentChange, error) {
+	toNode := func(m map[string]any) (*yaml.Node, error) {
+		if m == nil {
+			m = map[string]any{}
+		}
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("encode metadata: %w", err)
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(raw, &node); err != nil {
+			return nil, fmt.Errorf("decode metadata: %w", err)
+		}
+		return &node, nil
+	}
+	oldNode, err := toNode(oldMeta)
+	if err != nil {
+		return nil, err
+	}
+	newNode, err := toNode(newMeta)
+	if err != nil {
+		return nil, err
+	}
+	changes := []ContentChange{}
+	diffYAMLNodes(&changes, "", oldNode, newNode)
+	for i := range changes {
+		changes[i].Line = 0
+	}
+	return changes, nil
+}
+
+// alignSequences matches equal elements of two sequences along a longest common
+// subsequence; runs of unmatched elements in between are passed to gap in order.
+// Very long sequences that differ throughout are compared position by position.
+func alignSequences(oldKeys, newKeys []string, gap func(oldIdx, newIdx []int)) {
+	// Common prefix and suffix are matched without building the LCS table.
+	prefix := 0
+	for prefix < len(oldKeys) && prefix < len(newKeys) && oldKeys[prefix] == newKeys[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldKeys)-prefix && suffix < len(newKeys)-prefix &&
+		oldKeys[len(oldKeys)-1-suffix] == newKeys[len(newKeys)-1-suffix] {
+		suffix++
+	}
+	oldMid, newMid := oldKeys[prefix:len(oldKeys)-suffix], newKeys[prefix:len(newKeys)-suffix]
+	n, m := len(oldMid), len(newMid)
+	if n == 0 && m == 0 {
+		return
+	}
+	indexes := func(from, count int) []int {
+		out := make([]int, count)
+		for i := range out {
+			out[i] = from + i
+		}
+		return out
+	}
+	if n == 0 || m == 0 || n*m > maxDiffAlignCells {
+		gap(indexes(prefix, n), indexes(prefix, m))
+		return
+	}
+
+	// lcs[i][j] is the LCS length of oldMid[i:] and newMid[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldMid[i] == newMid[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var oldGap, newGap []int
+	flush := func() {
+		if len(oldGap) > 0 || len(newGap) > 0 {
+			gap(oldGap, newGap)
+		}
+		oldGap, newGap = nil, nil
+	}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case oldMid[i] == newMid[j]:
+			flush()
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			oldGap = append(oldGap, prefix+i)
+			i++
+		default:
+			newGap = append(newGap, prefix+j)
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		oldGap = append(oldGap, prefix+i)
+	}
+	for ; j < m; j++ {
+		newGap = append(newGap, prefix+j)
+	}
+	flush()
+}
+
+// diffGap pairs up unmatched elements by position; pairs that pair accepts are diffed
+// further, everything else is reported as removed or added.
+func diffGap(oldIdx, newIdx []int, pair func(o, n int) bool, removed func(o int), added func(n int)) {
+	var leftOld, leftNew []int
+	for k := 0; k < len(oldIdx) || k < len(newIdx); k++ {
+		switch {
+		case k < len(oldIdx) && k < len(newIdx):
+			if !pair(oldIdx[k], newIdx[k]) {
+				leftOld = append(leftOld, oldIdx[k])
+				leftNew = append(leftNew, newIdx[k])
+			}
+		case k < len(oldIdx):
+			leftOld = append(leftOld, oldIdx[k])
+		default:
+			leftNew = append(leftNew, newIdx[k])
+		}
+	}
+	for _, o := range leftOld {
+		removed(o)
+	}
+	for _, n := range leftNew {
+		added(n)
+	}
+}
+
+// ---- YAML / JSON ----
+
+func diffYAMLNodes(changes *[]ContentChange, path string, oldNode, newNode *yaml.Node) {
+	oldNode, newNode = unwrapYAMLNode(oldNode), unwrapYAMLNode(newNode)
+	switch {
+	case oldNode == nil && newNode == nil:
+		return
+	case oldNode == nil:
+		*changes = append(*changes, ContentChange{Op: DiffOpAdded, Path: path, Line: newNode.Line, New: yamlNodeValue(newNode)})
+		return
+	case newNode == nil:
+		*changes = append(*changes, ContentChange{Op: DiffOpRemoved, Path: path, Line: oldNode.Line, Old: yamlNodeValue(oldNode)})
+		return
+	}
+
+	if oldNode.Kind == yaml.MappingNode && newNode.Kind == yaml.MappingNode {
+		diffYAMLMappings(changes, path, oldNode, newNode)
+		return
+	}
+	if oldNode.Kind == yaml.SequenceNode && newNode.Kind == yaml.SequenceNode {
+		diffYAMLSequences(changes, path, oldNode, newNode)
+		return
+	}
+	if yamlNodeKey(oldNode) != yamlNodeKey(newNode) {
+		*changes = append(*changes, ContentChange{
+			Op:   DiffOpChanged,
+			Path: path,
+			Line: newNode.Line,
+			Old:  yamlNodeValue(oldNode),
+			New:  yamlNodeValue(newNode),
+		})
+	}
+}
+
+// diffYAMLMappings reports keys in the order of the new version, followed by removed keys.
+func diffYAMLMappings(changes *[]ContentChange, path string, oldNode, newNode *yaml.Node) {
+	oldValues := make(map[string]*yaml.Node, len(oldNode.Content)/2)
+	for i := 0; i+1 < len(oldNode.Content); i += 2 {
+		oldValues[oldNode.Content[i].Value] = oldNode.Content[i+1]
+	}
+	seen := make(map[string]struct{}, len(newNode.Content)/2)
+	for i := 0; i+1 < len(newNode.Content); i += 2 {
+		key, value := newNode.Content[i].Value, newNode.Content[i+1]
+		seen[key] = struct{}{}
+		childPath := joinDiffPath(path, key)
+		if old, ok := oldValues[key]; ok {
+			diffYAMLNodes(changes, childPath, old, value)
+			continue
+		}
+		*changes = append(*changes, ContentChange{Op: DiffOpAdded, Path: childPath, Line: newNode.Content[i].Line, New: yamlNodeValue(value)})
+	}
+	for i := 0; i+1 < len(oldNode.Content); i += 2 {
+		key := oldNode.Content[i].Value
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		*changes = append(*changes, ContentChange{Op: DiffOpRemoved, Path: joinDiffPath(path, key), Line: oldNode.Content[i].Line, Old: yamlNodeValue(oldNode.Content[i+1])})
+	}
+}
+
+// diffYAMLSequences keeps inserted or deleted list items from shifting every later
+// item into a "changed" entry; unmatched items of the same kind are diffed field by field.
+func diffYAMLSequences(changes *[]ContentChange, path string, oldNode, newNode *yaml.Node) {
+	keys := func(items []*yaml.Node) []string {
+		out := make([]string, len(items))
+		for i, item := range items {
+			out[i] = yamlNodeKey(item)
+		}
+		return out
+	}
+	oldItems, newItems := oldNode.Content, newNode.Content
+	itemPath := func(i int) string { return fmt.Sprintf("%s[%d]", path, i) }
+	alignSequences(keys(oldItems), keys(newItems), func(oldIdx, newIdx []int) {
+		diffGap(oldIdx, newIdx,
+			func(o, n int) bool {
+				if unwrapYAMLNode(oldItems[o]).Kind != unwrapYAMLNode(newItems[n]).Kind {
+					return false
+				}
+				diffYAMLNodes(changes, itemPath(n), oldItems[o], newItems[n])
+				return true
+			},
+			func(o int) {
+				*changes = append(*changes, ContentChange{Op: DiffOpRemoved, Path: itemPath(o), Line: oldItems[o].Line, Old: yamlNodeValue(oldItems[o])})
+			},
+			func(n int) {
+				*changes = append(*changes, ContentChange{Op: DiffOpAdded, Path: itemPath(n), Line: newItems[n].Line, New: yamlNodeValue(newItems[n])})
+			},
+		)
+	})
+}
+
+func unwrapYAMLNode(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch {
+		case node.Kind == yaml.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yaml.AliasNode && node.Alias != nil:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+func yamlNodeValue(node *yaml.Node) any {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	return value
+}
+
+// yamlNodeKey is a canonical form of the node's value used to match list items.
+func yamlNodeKey(node *yaml.Node) string {
+	node = unwrapYAMLNode(node)
+	if node == nil {
+		return ""
+	}
+	if raw, err := json.Marshal(yamlNodeValue(node)); err == nil {
+		return string(raw)
+	}
+	raw, _ := yaml.Marshal(node)
+	return string(raw)
+}
+
+func joinDiffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ---- HTML ----
+
+type htmlNode struct {
+	Tag      string // empty for text nodes
+	Attrs    []xml.Attr
+	Text     string
+	Line     int
+	Children []*htmlNode
+	key      string
+}
+
+// parseHTMLTree parses an HTML fragment leniently: void elements close themselves,
+// mismatched end tags close the open element, and whitespace is collapsed.
+func parseHTMLTree(data string) (*htmlNode, error) {
+	dec := xml.NewDecoder(strings.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	root := &htmlNode{Tag: "#root"}
+	stack := []*htmlNode{root}
+	for {
+		line, _ := dec.InputPos()
+		tok, err := dec.Token()
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.Is(err, io.EOF) || (errors.As(err, &syntaxErr) && syntaxErr.Msg == "unexpected EOF") {
+				break
+			}
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &htmlNode{Tag: strings.ToLower(t.Name.Local), Attrs: t.Attr, Line: line}
+			parent.Children = append(parent.Children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			text := strings.Join(strings.Fields(string(t)), " ")
+			if text == "" {
+				continue
+			}
+			if n := len(parent.Children); n > 0 && parent.Children[n-1].Tag == "" {
+				parent.Children[n-1].Text += " " + text
+				continue
+			}
+			parent.Children = append(parent.Children, &htmlNode{Text: text, Line: line})
+		}
+	}
+	root.computeKey()
+	return root, nil
+}
+
+func (n *htmlNode) computeKey() string {
+	if n.Tag == "" {
+		n.key = "#text:" + n.Text
+		return n.key
+	}
+	var b strings.Builder
+	b.WriteString("<" + n.Tag)
+	for _, attr := range sortedHTMLAttrs(n.Attrs) {
+		fmt.Fprintf(&b, " %s=%q", attr.Name.Local, attr.Value)
+	}
+	b.WriteString(">")
+	for _, child := range n.Children {
+		b.WriteString(child.computeKey())
+	}
+	b.WriteString("</" + n.Tag + ">")
+	n.key = b.String()
+	return n.key
+}
+
+func sortedHTMLAttrs(attrs []xml.Attr) []xml.Attr {
+	sorted := append([]xml.Attr(nil), attrs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name.Local < sorted[j].Name.Local })
+	return sorted
+}
+
+// summary is the node's visible text; elements without text (img, hr) are shown as their tag.
+func (n *htmlNode) summary() string {
+	if n.Tag == "" {
+		return n.Text
+	}
+	var parts []string
+	var walk func(node *htmlNode)
+	walk = func(node *htmlNode) {
+		if node.Tag == "" {
+			parts = append(parts, node.Text)
+			return
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(n)
+	if len(parts) > 0 {
+		return strings.Join(parts, " ")
+	}
+	var b strings.Builder
+	b.WriteString("<" + n.Tag)
+	for _, attr := range n.Attrs {
+		fmt.Fprintf(&b, " %s=%q", attr.Name.Local, attr.Value)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func (n *htmlNode) kind() string {
+	if n.Tag == "" {
+		return "text"
+	}
+	return n.Tag
+}
+
+// htmlStepPaths returns the path of every child, XPath style: "p[2]", "text()[1]".
+func htmlStepPaths(parentPath string, children []*htmlNode) []string {
+	counts := make(map[string]int)
+	paths := make([]string, len(children))
+	for i, child := range children {
+		step := child.Tag
+		if step == "" {
+			step = "text()"
+		}
+		counts[step]++
+		paths[i] = fmt.Sprintf("%s[%d]", step, counts[step])
+		if parentPath != "" {
+			paths[i] = parentPath + "/" + paths[i]
+		}
+	}
+	return paths
+}
+
+func diffHTMLChildren(changes *[]ContentChange, parentPath string, oldChildren, newChildren []*htmlNode) {
+	keys := func(nodes []*htmlNode) []string {
+		out := make([]string, len(nodes))
+		for i, node := range nodes {
+			out[i] = node.key
+		}
+		return out
+	}
+	oldPaths, newPaths := htmlStepPaths(parentPath, oldChildren), htmlStepPaths(parentPath, newChildren)
+	alignSequences(keys(oldChildren), keys(newChildren), func(oldIdx, newIdx []int) {
+		diffGap(oldIdx, newIdx,
+			func(o, n int) bool {
+				if oldChildren[o].Tag != newChildren[n].Tag {
+					return false
+				}
+				diffHTMLNode(changes, newPaths[n], oldChildren[o], newChildren[n])
+				return true
+			},
+			func(o int) {
+				node := oldChildren[o]
+				*changes = append(*changes, ContentChange{Op: DiffOpRemoved, Path: oldPaths[o], Kind: node.kind(), Line: node.Line, Old: node.summary()})
+			},
+			func(n int) {
+				node := newChildren[n]
+				*changes = append(*changes, ContentChange{Op: DiffOpAdded, Path: newPaths[n], Kind: node.kind(), Line: node.Line, New: node.summary()})
+			},
+		)
+	})
+}
+
+func diffHTMLNode(changes *[]ContentChange, path string, oldNode, newNode *htmlNode) {
+	if oldNode.Tag == "" {
+		if oldNode.Text != newNode.Text {
+			*changes = append(*changes, ContentChange{Op: DiffOpChanged, Path: path, Kind: "text", Line: newNode.Line, Old: oldNode.Text, New: newNode.Text})
+		}
+		return
+	}
+
+	oldAttrs := make(map[string]string, len(oldNode.Attrs))
+	for _, attr := range oldNode.Attrs {
+		oldAttrs[attr.Name.Local] = attr.Value
+	}
+	newAttrs := make(map[string]string, len(newNode.Attrs))
+	for _, attr := range sortedHTMLAttrs(newNode.Attrs) {
+		name := attr.Name.Local
+		newAttrs[name] = attr.Value
+		old, ok := oldAttrs[name]
+		switch {
+		case !ok:
+			*changes = append(*changes, ContentChange{Op: DiffOpAdded, Path: path + "/@" + name, Kind: newNode.Tag, Line: newNode.Line, New: attr.Value})
+		case old != attr.Value:
+			*changes = append(*changes, ContentChange{Op: DiffOpChanged, Path: path + "/@" + name, Kind: newNode.Tag, Line: newNode.Line, Old: old, New: attr.Value})
+		}
+	}
+	for _, attr := range sortedHTMLAttrs(oldNode.Attrs) {
+		if _, ok := newAttrs[attr.Name.Local]; !ok {
+			*changes = append(*changes, ContentChange{Op: DiffOpRemoved, Path: path + "/@" + attr.Name.Local, Kind: oldNode.Tag, Line: oldNode.Line, Old: attr.Value})
+		}
+	}
+
+	diffHTMLChildren(changes, path, oldNode.Children, newNode.Children)
+}
+
+// ---- Markdown ----
+
+// Markdown block kinds.
+const (
+	markdownFrontMatter = "front_matter"
+	markdownHeading     = "heading"
+	markdownParagraph   = "paragraph"
+	markdownListItem    = "list_item"
+	markdownQuote       = "quote"
+	markdownTable       = "table"
+	markdownCode        = "code"
+	markdownRule        = "rule"
+)
+
+var (
+	markdownListItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+	markdownRulePattern     = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+)
+
+type markdownBlock struct {
+	Kind string
+	Text string
+	Line int
+}
+
+// parseMarkdownBlocks splits markdown into top-level blocks. It is deliberately
+// simple: list items, headings, fenced code, quotes and tables each form a block,
+// other lines are grouped into paragraphs separated by blank lines.
+func parseMarkdownBlocks(data string) []markdownBlock {
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	var blocks []markdownBlock
+	var current *markdownBlock
+	var fence string
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimRight(current.Text, "\n ")
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+	start := func(kind string, i int, line string) {
+		flush()
+		current = &markdownBlock{Kind: kind, Text: line, Line: i + 1}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if current != nil && (current.Kind == markdownCode || current.Kind == markdownFrontMatter) {
+			current.Text += "\n" + line
+			if (fence != "" && strings.HasPrefix(trimmed, fence)) || (fence == "" && trimmed == "---") {
+				flush()
+			}
+			continue
+		}
+		switch {
+		case i == 0 && trimmed == "---":
+			start(markdownFrontMatter, i, line)
+			fence = ""
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			start(markdownCode, i, line)
+			fence = trimmed[:3]
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			start(markdownHeading, i, trimmed)
+			flush()
+		case markdownRulePattern.MatchString(trimmed):
+			start(markdownRule, i, trimmed)
+			flush()
+		case markdownListItemPattern.MatchString(line):
+			start(markdownListItem, i, line)
+		case strings.HasPrefix(trimmed, ">"):
+			if current == nil || current.Kind != markdownQuote {
+				start(markdownQuote, i, line)
+				continue
+			}
+			current.Text += "\n" + line
+		case strings.HasPrefix(trimmed, "|"):
+			if current == nil || current.Kind != markdownTable {
+				start(markdownTable, i, line)
+				continue
+			}
+			current.Text += "\n" + line
+		default:
+			if current == nil || (current.Kind != markdownParagraph && current.Kind != markdownListItem) {
+				start(markdownParagraph, i, line)
+				continue
+			}
+			current.Text += "\n" + line
+		}
+	}
+	flush()
+	return blocks
+}
+
+// diffMarkdownBlocks diffs markdown block by block; it also serves as the plain text fallback.
+func diffMarkdownBlocks(oldData, newData string) []ContentChange {
+	oldBlocks, newBlocks := parseMarkdownBlocks(oldData), parseMarkdownBlocks(newData)
+	keys := func(blocks []markdownBlock) []string {
+		out := make([]string, len(blocks))
+		for i, block := range blocks {
+			out[i] = block.Kind + "\x00" + block.Text
+		}
+		return out
+	}
+	blockPath := func(i int) string { return fmt.Sprintf("blocks[%d]", i) }
+	changes := []ContentChange{}
+	alignSequences(keys(oldBlocks), keys(newBlocks), func(oldIdx, newIdx []int) {
+		diffGap(oldIdx, newIdx,
+			func(o, n int) bool {
+				if oldBlocks[o].Kind != newBlocks[n].Kind {
+					return false
+				}
+				changes = append(changes, ContentChange{Op: DiffOpChanged, Path: blockPath(n), Kind: newBlocks[n].Kind, Line: newBlocks[n].Line, Old: oldBlocks[o].Text, New: newBlocks[n].Text})
+				return true
+			},
+			func(o int) {
+				changes = append(changes, ContentChange{Op: DiffOpRemoved, Path: blockPath(o), Kind: oldBlocks[o].Kind, Line: oldBlocks[o].Line, Old: oldBlocks[o].Text})
+			},
+			func(n int) {
+				changes = append(changes, ContentChange{Op: DiffOpAdded, Path: blockPath(n), Kind: newBlocks[n].Kind, Line: newBlocks[n].Line, New: newBlocks[n].Text})
+			},
+		)
+	})
+	return changes
+}
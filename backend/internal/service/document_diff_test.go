@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// versionsNDR 按版本号返回预置的文档版本
+type versionsNDR struct {
+	*fakeNDR
+	versions map[int]ndrclient.DocumentVersion
+}
+
+func (f *versionsNDR) GetDocumentVersion(_ context.Context, _ ndrclient.RequestMeta, docID int64, versionNumber int) (ndrclient.DocumentVersion, error) {
+	v, ok := f.versions[versionNumber]
+	if !ok {
+		return ndrclient.DocumentVersion{}, fmt.Errorf("version %d not found", versionNumber)
+	}
+	v.DocumentID = docID
+	v.VersionNumber = versionNumber
+	return v, nil
+}
+
+func changeSummary(changes []ContentChange) []string {
+	out := make([]string, len(changes))
+	for i, c := range changes {
+		out[i] = fmt.Sprintf("%s %s %v -> %v", c.Op, c.Path, c.Old, c.New)
+	}
+	return out
+}
+
+func assertChanges(t *testing.T, changes []ContentChange, want ...string) {
+	t.Helper()
+	got := changeSummary(changes)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("unexpected changes:\n got  %q\n want %q", got, want)
+	}
+}
+
+func TestDiffDocumentDataYAML(t *testing.T) {
+	oldData := `---
+title: 单元测验
+---
+questions:
+  - stem: 第一题
+    answer: A
+  - stem: 第二题
+    answer: D
+  - stem: 第三题
+    answer: B
+`
+	newData := `---
+title: 单元测验
+---
+questions:
+  - stem: 新增题
+    answer: C
+  - stem: 第一题
+    answer: A
+  - stem: 第二题
+    answer: D
+  - stem: 第三题
+    answer: C
+    analysis: 见教材
+`
+	changes, fallback := diffDocumentData(ContentFormatYAML, oldData, newData)
+	if fallback != "" {
+		t.Fatalf("unexpected fallback: %s", fallback)
+	}
+	assertChanges(t, changes,
+		"added body.questions[0] <nil> -> map[answer:C stem:新增题]",
+		"changed body.questions[3].answer B -> C",
+		"added body.questions[3].analysis <nil> -> 见教材",
+	)
+	if changes[1].Line != 12 {
+		t.Fatalf("expected answer change on line 12 of the new version, got %d", changes[1].Line)
+	}
+
+	changes, fallback = diffDocumentData(ContentFormatYAML, oldData, "questions: [unclosed")
+	if fallback == "" || len(changes) == 0 {
+		t.Fatalf("expected text fallback for invalid YAML, got %q %v", fallback, changes)
+	}
+}
+
+func TestDiffDocumentDataHTML(t *testing.T) {
+	oldData := `<h1>标题</h1><ol><li>甲</li><li>乙</li></ol><p class="note">说明<br>第二行</p>`
+	newData := `<h1>标题</h1>
+<ol>
+  <li>甲</li>
+  <li>丙</li>
+</ol>
+<p class="tip">说明<br>第二行</p>
+<img src="/a.png">`
+
+	changes, fallback := diffDocumentData(ContentFormatHTML, oldData, newData)
+	if fallback != "" {
+		t.Fatalf("unexpected fallback: %s", fallback)
+	}
+	assertChanges(t, changes,
+		"changed ol[1]/li[2]/text()[1] 乙 -> 丙",
+		"changed p[1]/@class note -> tip",
+		`added img[1] <nil> -> <img src="/a.png">`,
+	)
+}
+
+func TestDiffDocumentDataMarkdown(t *testing.T) {
+	oldData := "# 标题\n\n第一段\n\n- 要点一\n- 要点二\n\n```go\nfmt.Println(1)\n```\n"
+	newData := "# 标题\n\n第一段（修订）\n\n- 要点一\n- 要点二\n- 要点三\n\n```go\nfmt.Println(1)\n```\n"
+
+	changes, _ := diffDocumentData(ContentFormatMarkdown, oldData, newData)
+	assertChanges(t, changes,
+		"changed blocks[1] 第一段 -> 第一段（修订）",
+		"added blocks[4] <nil> -> - 要点三",
+	)
+	if changes[0].Kind != markdownParagraph || changes[1].Kind != markdownListItem {
+		t.Fatalf("unexpected block kinds: %+v", changes)
+	}
+}
+
+func TestGetDocumentStructuredDiff(t *testing.T) {
+	docType := "comprehensive_choice_v1"
+	ndr := &versionsNDR{
+		fakeNDR: &fakeNDR{},
+		versions: map[int]ndrclient.DocumentVersion{
+			1: {
+				Title:    "旧标题",
+				Type:     &docType,
+				Content:  map[string]any{"format": "yaml", "data": "questions:\n  - answer: B\n"},
+				Metadata: map[string]any{"difficulty": 2, "tags": []any{"a"}},
+			},
+			2: {
+				Title:    "新标题",
+				Type:     &docType,
+				Content:  map[string]any{"format": "yaml", "data": "questions:\n  - answer: C\n"},
+				Metadata: map[string]any{"difficulty": 3, "tags": []any{"a", "b"}},
+			},
+		},
+	}
+	svc := NewService(cache.NewNoop(), ndr, nil)
+
+	diff, err := svc.GetDocumentStructuredDiff(context.Background(), RequestMeta{}, 7, 1, 2)
+	if err != nil {
+		t.Fatalf("structured diff: %v", err)
+	}
+	if diff.Format != ContentFormatYAML || diff.Mode != DiffModeStructured || diff.TypeDiff != nil {
+		t.Fatalf("unexpected diff header: %+v", diff)
+	}
+	if diff.TitleDiff == nil || diff.TitleDiff.New != "新标题" {
+		t.Fatalf("expected title diff, got %+v", diff.TitleDiff)
+	}
+	assertChanges(t, diff.ContentChanges, "changed body.questions[0].answer B -> C")
+	assertChanges(t, diff.MetadataChanges,
+		"changed difficulty 2 -> 3",
+		"added tags[1] <nil> -> b",
+	)
+	if diff.MetadataChanges[0].Line != 0 {
+		t.Fatalf("metadata changes should not carry line numbers")
+	}
+
+	if _, err := svc.GetDocumentStructuredDiff(context.Background(), RequestMeta{}, 7, 1, 9); err == nil {
+		t.Fatalf("expected error for missing version")
+	}
+}
@@ -0,0 +1,448 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// ErrDocumentVersionConflict is returned when an update is based on an outdated version.
+var ErrDocumentVersionConflict = errors.New("document version conflict")
+
+// DocumentVersionConflictError carries the version that the update conflicted with.
+type DocumentVersionConflictError struct {
+	DocumentID     int64
+	BaseVersion    int
+	CurrentVersion int
+	Current        ndrclient.Document
+}
+
+func (e *DocumentVersionConflictError) Error() string {
+	return fmt.Sprintf("document %d was updated to version %d since version %d", e.DocumentID, e.CurrentVersion, e.BaseVersion)
+}
+
+func (e *DocumentVersionConflictError) Unwrap() error {
+	return ErrDocumentVersionConflict
+}
+
+// checkDocumentVersion reads the document from NDR, bypassing the cache, and fails if
+// its version is not baseVersion. Documents without a version number are not checked.
+func (s *Service) checkDocumentVersion(ctx context.Context, meta RequestMeta, docID int64, baseVersion int) (ndrclient.Document, error) {
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return doc, err
+	}
+	if doc.Version != nil && *doc.Version != baseVersion {
+		return doc, &DocumentVersionConflictError{
+			DocumentID:     docID,
+			BaseVersion:    baseVersion,
+			CurrentVersion: *doc.Version,
+			Current:        doc,
+		}
+	}
+	return doc, nil
+}
+
+// versionConflictFromNDR turns NDR's 412 answer to an If-Match update into a
+// DocumentVersionConflictError carrying the document's current state.
+func (s *Service) versionConflictFromNDR(ctx context.Context, meta RequestMeta, docID int64, baseVersion int, err error) error {
+	var ndrErr *ndrclient.Error
+	if !errors.As(err, &ndrErr) || ndrErr.StatusCode != http.StatusPreconditionFailed {
+		return err
+	}
+	conflict := &DocumentVersionConflictError{DocumentID: docID, BaseVersion: baseVersion}
+	if current, getErr := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID); getErr == nil {
+		conflict.Current = current
+		if current.Version != nil {
+			conflict.CurrentVersion = *current.Version
+		}
+	}
+	return conflict
+}
+
+// Merge strategies reported in DocumentMergeResult.
+const (
+	MergeStrategyTree = "tree" // YAML/JSON merged key by key and list item by list item
+	MergeStrategyText = "text" // line based merge with conflict markers
+)
+
+// DocumentMergeRequest asks to merge the caller's content ("ours"), edited from BaseVersion,
+// with the document's current content ("theirs").
+type DocumentMergeRequest struct {
+	BaseVersion int            `json:"base_version"`
+	Content     map[string]any `json:"content"`
+	// Apply saves the merged content when there are no conflicts.
+	Apply bool `json:"apply,omitempty"`
+}
+
+// MergeConflict is a part of the content both sides changed differently.
+type MergeConflict struct {
+	Path   string `json:"path,omitempty"` // tree merges: path as in ContentChange
+	Line   int    `json:"line,omitempty"` // text merges: line of the conflict marker in the merged data
+	Base   any    `json:"base"`
+	Theirs any    `json:"theirs"`
+	Ours   any    `json:"ours"`
+}
+
+// DocumentMergeResult is the outcome of a three-way merge.
+//
+// Tree merges keep "ours" at every conflict; text merges write conflict markers
+// (<<<<<<< theirs / ======= / >>>>>>> ours) into the merged data.
+type DocumentMergeResult struct {
+	DocumentID     int64           `json:"document_id"`
+	BaseVersion    int             `json:"base_version"`
+	CurrentVersion int             `json:"current_version"`
+	Format         ContentFormat   `json:"format"`
+	Strategy       string          `json:"strategy"`
+	Content        map[string]any  `json:"content"`
+	Clean          bool            `json:"clean"`
+	Conflicts      []MergeConflict `json:"conflicts"`
+	// Document is the saved document when Apply was requested and the merge was clean.
+	Document *ndrclient.Document `json:"document,omitempty"`
+}
+
+// MergeDocument three-way merges content.data of the caller's edit with the current document,
+// using the stored BaseVersion as the common ancestor.
+func (s *Service) MergeDocument(ctx context.Context, meta RequestMeta, docID int64, req DocumentMergeRequest) (*DocumentMergeResult, error) {
+	if req.BaseVersion <= 0 {
+		return nil, newValidationError("base_version is required")
+	}
+	ourData, ok := req.Content["data"].(string)
+	if !ok {
+		return nil, newValidationError("content.data is required")
+	}
+
+	current, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.ndr.GetDocumentVersion(ctx, toNDRMeta(meta), docID, req.BaseVersion)
+	if err != nil {
+		return nil, fmt.Errorf("get base version %d: %w", req.BaseVersion, err)
+	}
+
+	var docType string
+	if current.Type != nil {
+		docType = *current.Type
+	}
+	format := versionContentFormat(current.Content, docType)
+	baseData, _ := base.Content["data"].(string)
+	theirData, _ := current.Content["data"].(string)
+
+	result := &DocumentMergeResult{
+		DocumentID:     docID,
+		BaseVersion:    req.BaseVersion,
+		CurrentVersion: req.BaseVersion,
+		Format:         format,
+	}
+	if current.Version != nil {
+		result.CurrentVersion = *current.Version
+	}
+	merged, conflicts, strategy := mergeDocumentData(format, baseData, theirData, ourData)
+	result.Strategy = strategy
+	result.Conflicts = conflicts
+	result.Clean = len(conflicts) == 0
+	result.Content = make(map[string]any, len(req.Content))
+	for k, v := range req.Content {
+		result.Content[k] = v
+	}
+	result.Content["format"] = string(format)
+	result.Content["data"] = merged
+
+	if req.Apply && result.Clean {
+		currentVersion := result.CurrentVersion
+		doc, err := s.UpdateDocument(ctx, meta, docID, DocumentUpdateRequest{Content: result.Content, BaseVersion: &currentVersion})
+		if err != nil {
+			return result, err
+		}
+		result.Document = &doc
+	}
+	return result, nil
+}
+
+// mergeDocumentData merges YAML/JSON as trees and everything else, including
+// YAML that does not parse, line by line.
+func mergeDocumentData(format ContentFormat, base, theirs, ours string) (string, []MergeConflict, string) {
+	if format == ContentFormatYAML || format == ContentFormatJSON {
+		if merged, conflicts, err := mergeDocumentTrees(format, base, theirs, ours); err == nil {
+			return merged, conflicts, MergeStrategyTree
+		}
+	}
+	merged, conflicts := mergeTextLines(base, theirs, ours)
+	return merged, conflicts, MergeStrategyText
+}
+
+func mergeDocumentTrees(format ContentFormat, base, theirs, ours string) (string, []MergeConflict, error) {
+	var roots [3]*yaml.Node
+	for i, data := range []string{base, theirs, ours} {
+		root, err := parseDiffData(format, data)
+		if err != nil {
+			return "", nil, err
+		}
+		if root == nil {
+			return "", nil, errors.New("content is empty")
+		}
+		roots[i] = root
+	}
+	conflicts := []MergeConflict{}
+	merged := mergeYAMLNodes(&conflicts, "", roots[0], roots[1], roots[2])
+	data, err := encodeDocumentData(format, merged)
+	if err != nil {
+		return "", nil, err
+	}
+	return data, conflicts, nil
+}
+
+// mergeYAMLNodes returns the merged node; nil means the value was deleted.
+func mergeYAMLNodes(conflicts *[]MergeConflict, path string, base, theirs, ours *yaml.Node) *yaml.Node {
+	baseKey, theirKey, ourKey := yamlNodeKey(base), yamlNodeKey(theirs), yamlNodeKey(ours)
+	switch {
+	case theirKey == ourKey || baseKey == ourKey:
+		return theirs
+	case baseKey == theirKey:
+		return ours
+	}
+
+	b, t, o := unwrapYAMLNode(base), unwrapYAMLNode(theirs), unwrapYAMLNode(ours)
+	if b != nil && t != nil && o != nil && b.Kind == t.Kind && t.Kind == o.Kind {
+		switch b.Kind {
+		case yaml.MappingNode:
+			return mergeYAMLMappings(conflicts, path, b, t, o)
+		case yaml.SequenceNode:
+			return mergeYAMLSequences(conflicts, path, b, t, o)
+		}
+	}
+	*conflicts = append(*conflicts, MergeConflict{Path: path, Base: optionalYAMLValue(base), Theirs: optionalYAMLValue(theirs), Ours: optionalYAMLValue(ours)})
+	return ours
+}
+
+// mergeYAMLMappings keeps the key order of theirs, followed by keys only ours added.
+func mergeYAMLMappings(conflicts *[]MergeConflict, path string, base, theirs, ours *yaml.Node) *yaml.Node {
+	type entry struct{ key, value *yaml.Node }
+	index := func(node *yaml.Node) (map[string]entry, []string) {
+		entries := make(map[string]entry, len(node.Content)/2)
+		order := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			entries[key] = entry{node.Content[i], node.Content[i+1]}
+			order = append(order, key)
+		}
+		return entries, order
+	}
+	baseEntries, _ := index(base)
+	theirEntries, theirOrder := index(theirs)
+	ourEntries, ourOrder := index(ours)
+
+	keys := theirOrder
+	for _, key := range ourOrder {
+		if _, ok := theirEntries[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: ours.Tag, Style: ours.Style, Line: ours.Line, Column: ours.Column}
+	for _, key := range keys {
+		value := mergeYAMLNodes(conflicts, joinDiffPath(path, key),
+			baseEntries[key].value, theirEntries[key].value, ourEntries[key].value)
+		if value == nil {
+			continue
+		}
+		keyNode := ourEntries[key].key
+		if keyNode == nil {
+			keyNode = theirEntries[key].key
+		}
+		merged.Content = append(merged.Content, keyNode, value)
+	}
+	return merged
+}
+
+// mergeYAMLSequences merges list items like lines of text: items inserted by both sides at the
+// same place are all kept (theirs first), and items both sides edited are merged field by field.
+func mergeYAMLSequences(conflicts *[]MergeConflict, path string, base, theirs, ours *yaml.Node) *yaml.Node {
+	keys := func(items []*yaml.Node) []string {
+		out := make([]string, len(items))
+		for i, item := range items {
+			out[i] = yamlNodeKey(item)
+		}
+		return out
+	}
+	baseKeys, theirKeys, ourKeys := keys(base.Content), keys(theirs.Content), keys(ours.Content)
+	pick := func(items []*yaml.Node, idx []int) []*yaml.Node {
+		out := make([]*yaml.Node, len(idx))
+		for i, j := range idx {
+			out[i] = items[j]
+		}
+		return out
+	}
+
+	merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: ours.Tag, Style: ours.Style, Line: ours.Line, Column: ours.Column}
+	for _, chunk := range diff3Chunks(baseKeys, theirKeys, ourKeys) {
+		b, t, o := pick(base.Content, chunk.base), pick(theirs.Content, chunk.theirs), pick(ours.Content, chunk.ours)
+		switch {
+		case chunk.stable || sameKeys(theirKeys, chunk.theirs, ourKeys, chunk.ours) || sameKeys(baseKeys, chunk.base, ourKeys, chunk.ours):
+			merged.Content = append(merged.Content, t...)
+		case sameKeys(baseKeys, chunk.base, theirKeys, chunk.theirs):
+			merged.Content = append(merged.Content, o...)
+		case len(b) == 0:
+			merged.Content = append(merged.Content, append(t, o...)...)
+		case len(b) == len(t) && len(t) == len(o):
+			for i := range b {
+				item := mergeYAMLNodes(conflicts, fmt.Sprintf("%s[%d]", path, len(merged.Content)), b[i], t[i], o[i])
+				if item != nil {
+					merged.Content = append(merged.Content, item)
+				}
+			}
+		default:
+			*conflicts = append(*conflicts, MergeConflict{
+				Path:   fmt.Sprintf("%s[%d]", path, len(merged.Content)),
+				Base:   yamlNodeValues(b),
+				Theirs: yamlNodeValues(t),
+				Ours:   yamlNodeValues(o),
+			})
+			merged.Content = append(merged.Content, o...)
+		}
+	}
+	return merged
+}
+
+func optionalYAMLValue(node *yaml.Node) any {
+	if node = unwrapYAMLNode(node); node == nil {
+		return nil
+	}
+	return yamlNodeValue(node)
+}
+
+func yamlNodeValues(nodes []*yaml.Node) []any {
+	out := make([]any, len(nodes))
+	for i, node := range nodes {
+		out[i] = yamlNodeValue(node)
+	}
+	return out
+}
+
+// mergeTextLines is a diff3 style line merge.
+func mergeTextLines(base, theirs, ours string) (string, []MergeConflict) {
+	baseLines, theirLines, ourLines := strings.Split(base, "\n"), strings.Split(theirs, "\n"), strings.Split(ours, "\n")
+	pick := func(lines []string, idx []int) []string {
+		out := make([]string, len(idx))
+		for i, j := range idx {
+			out[i] = lines[j]
+		}
+		return out
+	}
+
+	var out []string
+	conflicts := []MergeConflict{}
+	for _, chunk := range diff3Chunks(baseLines, theirLines, ourLines) {
+		b, t, o := pick(baseLines, chunk.base), pick(theirLines, chunk.theirs), pick(ourLines, chunk.ours)
+		switch {
+		case chunk.stable || sameKeys(theirLines, chunk.theirs, ourLines, chunk.ours) || sameKeys(baseLines, chunk.base, ourLines, chunk.ours):
+			out = append(out, t...)
+		case sameKeys(baseLines, chunk.base, theirLines, chunk.theirs):
+			out = append(out, o...)
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				Line:   len(out) + 1,
+				Base:   strings.Join(b, "\n"),
+				Theirs: strings.Join(t, "\n"),
+				Ours:   strings.Join(o, "\n"),
+			})
+			out = append(out, "<<<<<<< theirs")
+			out = append(out, t...)
+			out = append(out, "=======")
+			out = append(out, o...)
+			out = append(out, ">>>>>>> ours")
+		}
+	}
+	return strings.Join(out, "\n"), conflicts
+}
+
+// mergeChunk is a stable region where base, theirs and ours agree, or the
+// differing regions between two stable ones. Fields hold indexes into each sequence.
+type mergeChunk struct {
+	stable             bool
+	base, theirs, ours []int
+}
+
+// diff3Chunks splits three sequences into stable and unstable chunks, using
+// base elements matched in both theirs and ours as anchors.
+func diff3Chunks(baseKeys, theirKeys, ourKeys []string) []mergeChunk {
+	theirMatch, ourMatch := matchSequences(baseKeys, theirKeys), matchSequences(baseKeys, ourKeys)
+	span := func(from, to int) []int {
+		out := make([]int, 0, to-from)
+		for i := from; i < to; i++ {
+			out = append(out, i)
+		}
+		return out
+	}
+
+	var chunks []mergeChunk
+	nextBase, nextTheirs, nextOurs := 0, 0, 0
+	for k := 0; k <= len(baseKeys); k++ {
+		if k < len(baseKeys) && (theirMatch[k] < 0 || ourMatch[k] < 0) {
+			continue
+		}
+		theirEnd, ourEnd := len(theirKeys), len(ourKeys)
+		if k < len(baseKeys) {
+			theirEnd, ourEnd = theirMatch[k], ourMatch[k]
+		}
+		if nextBase < k || nextTheirs < theirEnd || nextOurs < ourEnd {
+			chunks = append(chunks, mergeChunk{
+				base:   span(nextBase, k),
+				theirs: span(nextTheirs, theirEnd),
+				ours:   span(nextOurs, ourEnd),
+			})
+		}
+		if k < len(baseKeys) {
+			chunks = append(chunks, mergeChunk{stable: true, base: []int{k}, theirs: []int{theirEnd}, ours: []int{ourEnd}})
+			nextBase, nextTheirs, nextOurs = k+1, theirEnd+1, ourEnd+1
+		}
+	}
+	return chunks
+}
+
+// matchSequences maps every element of oldKeys to the index of its LCS partner in newKeys, or -1.
+func matchSequences(oldKeys, newKeys []string) []int {
+	unmatchedOld := make(map[int]bool)
+	unmatchedNew := make(map[int]bool)
+	alignSequences(oldKeys, newKeys, func(oldIdx, newIdx []int) {
+		for _, i := range oldIdx {
+			unmatchedOld[i] = true
+		}
+		for _, j := range newIdx {
+			unmatchedNew[j] = true
+		}
+	})
+	match := make([]int, len(oldKeys))
+	j := 0
+	for i := range oldKeys {
+		match[i] = -1
+		if unmatchedOld[i] {
+			continue
+		}
+		for unmatchedNew[j] {
+			j++
+		}
+		match[i] = j
+		j++
+	}
+	return match
+}
+
+func sameKeys(aKeys []string, aIdx []int, bKeys []string, bIdx []int) bool {
+	if len(aIdx) != len(bIdx) {
+		return false
+	}
+	for i := range aIdx {
+		if aKeys[aIdx[i]] != bKeys[bIdx[i]] {
+			return false
+		}
+	}
+	return true
+}
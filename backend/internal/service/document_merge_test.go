@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+const mergeBaseYAML = `questions:
+  - stem: 第一题
+    answer: A
+  - stem: 第二题
+    answer: B
+`
+
+func TestMergeDocumentTreesYAML(t *testing.T) {
+	theirs := strings.Replace(mergeBaseYAML, "answer: A", "answer: C", 1)
+	ours := strings.Replace(mergeBaseYAML, "stem: 第二题", "stem: 第二题（修订）", 1)
+
+	merged, conflicts, strategy := mergeDocumentData(ContentFormatYAML, mergeBaseYAML, theirs, ours)
+	if strategy != MergeStrategyTree || len(conflicts) != 0 {
+		t.Fatalf("expected clean tree merge, got %s %+v", strategy, conflicts)
+	}
+	if !strings.Contains(merged, "answer: C") || !strings.Contains(merged, "第二题（修订）") {
+		t.Fatalf("merged content lost a change:\n%s", merged)
+	}
+
+	// 双方在末尾各追加一题：两题都保留
+	theirs = mergeBaseYAML + "  - stem: 他们的题\n    answer: D\n"
+	ours = mergeBaseYAML + "  - stem: 我们的题\n    answer: E\n"
+	merged, conflicts, _ = mergeDocumentData(ContentFormatYAML, mergeBaseYAML, theirs, ours)
+	if len(conflicts) != 0 || !strings.Contains(merged, "他们的题") || !strings.Contains(merged, "我们的题") {
+		t.Fatalf("expected both appended items, got %+v\n%s", conflicts, merged)
+	}
+
+	// 双方修改同一答案：冲突，保留我们的值
+	theirs = strings.Replace(mergeBaseYAML, "answer: B", "answer: C", 1)
+	ours = strings.Replace(mergeBaseYAML, "answer: B", "answer: D", 1)
+	merged, conflicts, _ = mergeDocumentData(ContentFormatYAML, mergeBaseYAML, theirs, ours)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %+v", conflicts)
+	}
+	c := conflicts[0]
+	if c.Path != "body.questions[1].answer" || c.Base != "B" || c.Theirs != "C" || c.Ours != "D" {
+		t.Fatalf("unexpected conflict: %+v", c)
+	}
+	if !strings.Contains(merged, "answer: D") {
+		t.Fatalf("expected ours to be kept on conflict:\n%s", merged)
+	}
+}
+
+func TestMergeTextLines(t *testing.T) {
+	base := "<h1>标题</h1>\n<p>第一段</p>\n<p>第二段</p>\n"
+	theirs := "<h1>新标题</h1>\n<p>第一段</p>\n<p>第二段</p>\n"
+	ours := "<h1>标题</h1>\n<p>第一段</p>\n<p>第二段（修订）</p>\n"
+
+	merged, conflicts, strategy := mergeDocumentData(ContentFormatHTML, base, theirs, ours)
+	if strategy != MergeStrategyText || len(conflicts) != 0 {
+		t.Fatalf("expected clean text merge, got %s %+v", strategy, conflicts)
+	}
+	if merged != "<h1>新标题</h1>\n<p>第一段</p>\n<p>第二段（修订）</p>\n" {
+		t.Fatalf("unexpected merge result:\n%s", merged)
+	}
+
+	ours = "<h1>另一个标题</h1>\n<p>第一段</p>\n<p>第二段</p>\n"
+	merged, conflicts, _ = mergeDocumentData(ContentFormatHTML, base, theirs, ours)
+	if len(conflicts) != 1 || conflicts[0].Line != 1 {
+		t.Fatalf("expected one conflict on line 1, got %+v", conflicts)
+	}
+	if !strings.HasPrefix(merged, "<<<<<<< theirs\n<h1>新标题</h1>\n=======\n<h1>另一个标题</h1>\n>>>>>>> ours\n") {
+		t.Fatalf("unexpected conflict markers:\n%s", merged)
+	}
+}
+
+func TestUpdateDocumentBaseVersion(t *testing.T) {
+	docType := "comprehensive_choice_v1"
+	current := 5
+	fake := &fakeNDR{getDocResp: ndrclient.Document{ID: 9, Version: &current, Type: &docType}}
+	svc := NewService(cache.NewNoop(), fake, nil)
+	title := "新标题"
+
+	stale := 4
+	_, err := svc.UpdateDocument(context.Background(), RequestMeta{}, 9, DocumentUpdateRequest{Title: &title, BaseVersion: &stale})
+	var conflict *DocumentVersionConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrDocumentVersionConflict) {
+		t.Fatalf("expected version conflict, got %v", err)
+	}
+	if conflict.CurrentVersion != 5 || conflict.BaseVersion != 4 || len(fake.updatedDocs) != 0 {
+		t.Fatalf("unexpected conflict %+v, updates=%d", conflict, len(fake.updatedDocs))
+	}
+
+	if _, err := svc.UpdateDocument(context.Background(), RequestMeta{}, 9, DocumentUpdateRequest{Title: &title, BaseVersion: &current}); err != nil {
+		t.Fatalf("update with current version: %v", err)
+	}
+	if len(fake.updatedDocs) != 1 || fake.updatedDocs[0].Body.BaseVersion == nil || *fake.updatedDocs[0].Body.BaseVersion != 5 {
+		t.Fatalf("expected the update to reach NDR conditioned on version 5, got %+v", fake.updatedDocs)
+	}
+
+	// 预检通过后被他人抢先写入：NDR 按 If-Match 返回 412
+	fake.updateDocErr = &ndrclient.Error{StatusCode: http.StatusPreconditionFailed}
+	_, err = svc.UpdateDocument(context.Background(), RequestMeta{}, 9, DocumentUpdateRequest{Title: &title, BaseVersion: &current})
+	if !errors.As(err, &conflict) || conflict.BaseVersion != 5 || conflict.Current.ID != 9 {
+		t.Fatalf("expected NDR precondition failure as version conflict, got %v", err)
+	}
+}
+
+func TestMergeDocument(t *testing.T) {
+	current := 3
+	ndr := &versionsNDR{
+		fakeNDR: &fakeNDR{getDocResp: ndrclient.Document{
+			ID:      9,
+			Version: &current,
+			Content: map[string]any{"format": "yaml", "data": strings.Replace(mergeBaseYAML, "answer: A", "answer: C", 1)},
+		}},
+		versions: map[int]ndrclient.DocumentVersion{
+			2: {Content: map[string]any{"format": "yaml", "data": mergeBaseYAML}},
+		},
+	}
+	ndr.updateDocResp = ndrclient.Document{ID: 9}
+	svc := NewService(cache.NewNoop(), ndr, nil)
+
+	ours := strings.Replace(mergeBaseYAML, "answer: B", "answer: D", 1)
+	result, err := svc.MergeDocument(context.Background(), RequestMeta{}, 9, DocumentMergeRequest{
+		BaseVersion: 2,
+		Content:     map[string]any{"format": "yaml", "data": ours},
+		Apply:       true,
+	})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if !result.Clean || result.CurrentVersion != 3 || result.Strategy != MergeStrategyTree || result.Document == nil {
+		t.Fatalf("unexpected merge result: %+v", result)
+	}
+	data := result.Content["data"].(string)
+	if !strings.Contains(data, "answer: C") || !strings.Contains(data, "answer: D") {
+		t.Fatalf("merged content lost a change:\n%s", data)
+	}
+	if len(ndr.updatedDocs) != 1 || ndr.updatedDocs[0].Body.Content["data"] != data {
+		t.Fatalf("expected merged content to be saved, got %+v", ndr.updatedDocs)
+	}
+
+	if _, err := svc.MergeDocument(context.Background(), RequestMeta{}, 9, DocumentMergeRequest{Content: map[string]any{"data": ours}}); err == nil {
+		t.Fatalf("expected validation error without base_version")
+	}
+}
@@ -0,0 +1,420 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migration step operations.
+const (
+	// MigrationOpRename renames the field at Path to To.
+	MigrationOpRename = "rename"
+	// MigrationOpRemove deletes the field at Path.
+	MigrationOpRemove = "remove"
+	// MigrationOpSetDefault adds the field at Path with Value (YAML text) when it is missing.
+	MigrationOpSetDefault = "set_default"
+	// MigrationOpMapToList turns a mapping such as {A: x, B: y} at Path into
+	// [{<KeyField>: A, <ValueField>: x}, ...]; fields that are already lists are left alone.
+	MigrationOpMapToList = "map_to_list"
+)
+
+// MigrationStep is one declarative change applied to parsed content.data.
+//
+// Path is dot separated and addresses the same tree the type schema validates:
+// YAML content is {"meta": <front matter>, "body": <document>}. A segment ending
+// in "[]" visits every element of a list, e.g. "body.sub_questions[].options".
+// Documents that do not contain the path are left unchanged.
+type MigrationStep struct {
+	Op         string `json:"op" yaml:"op"`
+	Path       string `json:"path" yaml:"path"`
+	To         string `json:"to,omitempty" yaml:"to,omitempty"`
+	Value      string `json:"value,omitempty" yaml:"value,omitempty"`
+	KeyField   string `json:"key_field,omitempty" yaml:"key_field,omitempty"`
+	ValueField string `json:"value_field,omitempty" yaml:"value_field,omitempty"`
+}
+
+// DocumentTypeMigration declares how documents of one type are upgraded to another.
+// Steps run in order, then Transform (if set) for changes that cannot be expressed declaratively.
+type DocumentTypeMigration struct {
+	From        DocumentType
+	To          DocumentType
+	Description string
+	Steps       []MigrationStep
+	Transform   func(root *yaml.Node) error
+}
+
+// DocumentTypeMigrationInfo is the API representation of a declared migration.
+type DocumentTypeMigrationInfo struct {
+	From        DocumentType    `json:"from"`
+	To          DocumentType    `json:"to"`
+	Description string          `json:"description,omitempty"`
+	Steps       []MigrationStep `json:"steps"`
+	Custom      bool            `json:"custom"` // has a Transform in addition to the declarative steps
+}
+
+// ErrDocumentTypeMigrationNotFound is returned when no migration is declared between two types.
+var ErrDocumentTypeMigrationNotFound = errors.New("document type migration not found")
+
+var (
+	// Migrations declared in doc-types/config.yaml (generated by docgen) or registered from Go code.
+	documentTypeMigrationsMu sync.RWMutex
+	documentTypeMigrations   []DocumentTypeMigration
+)
+
+// RegisterDocumentTypeMigration declares a migration; it is usually called from an init function.
+func RegisterDocumentTypeMigration(m DocumentTypeMigration) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	documentTypeMigrationsMu.Lock()
+	defer documentTypeMigrationsMu.Unlock()
+	for _, existing := range documentTypeMigrations {
+		if existing.From == m.From && existing.To == m.To {
+			return fmt.Errorf("migration %s -> %s already registered", m.From, m.To)
+		}
+	}
+	documentTypeMigrations = append(documentTypeMigrations, m)
+	return nil
+}
+
+// DocumentTypeMigrations lists declared migrations ordered by source and target type.
+func DocumentTypeMigrations() []DocumentTypeMigrationInfo {
+	documentTypeMigrationsMu.RLock()
+	defer documentTypeMigrationsMu.RUnlock()
+	infos := make([]DocumentTypeMigrationInfo, 0, len(documentTypeMigrations))
+	for _, m := range documentTypeMigrations {
+		steps := m.Steps
+		if steps == nil {
+			steps = []MigrationStep{}
+		}
+		infos = append(infos, DocumentTypeMigrationInfo{
+			From:        m.From,
+			To:          m.To,
+			Description: m.Description,
+			Steps:       steps,
+			Custom:      m.Transform != nil,
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].From != infos[j].From {
+			return infos[i].From < infos[j].From
+		}
+		return infos[i].To < infos[j].To
+	})
+	return infos
+}
+
+// lookupDocumentTypeMigration finds the migration from one type to another and checks
+// that both types are currently registered with the same structured content format.
+func lookupDocumentTypeMigration(from, to DocumentType) (DocumentTypeMigration, error) {
+	documentTypeMigrationsMu.RLock()
+	var found *DocumentTypeMigration
+	for i := range documentTypeMigrations {
+		if documentTypeMigrations[i].From == from && documentTypeMigrations[i].To == to {
+			m := documentTypeMigrations[i]
+			found = &m
+			break
+		}
+	}
+	documentTypeMigrationsMu.RUnlock()
+	if found == nil {
+		return DocumentTypeMigration{}, fmt.Errorf("%w: %s -> %s", ErrDocumentTypeMigrationNotFound, from, to)
+	}
+
+	fromDef, ok := lookupDocumentType(from)
+	if !ok {
+		return DocumentTypeMigration{}, newValidationError("unknown source document type '%s'", from)
+	}
+	toDef, ok := lookupDocumentType(to)
+	if !ok {
+		return DocumentTypeMigration{}, newValidationError("unknown target document type '%s'", to)
+	}
+	if fromDef.ContentFormat != toDef.ContentFormat {
+		return DocumentTypeMigration{}, newValidationError("cannot migrate %s content to %s content", fromDef.ContentFormat, toDef.ContentFormat)
+	}
+	if fromDef.ContentFormat != ContentFormatYAML && fromDef.ContentFormat != ContentFormatJSON {
+		return DocumentTypeMigration{}, newValidationError("migrations are only supported for yaml and json content, not %s", fromDef.ContentFormat)
+	}
+	return *found, nil
+}
+
+func (m DocumentTypeMigration) validate() error {
+	if m.From == "" || m.To == "" {
+		return fmt.Errorf("migration requires both from and to types")
+	}
+	if m.From == m.To {
+		return fmt.Errorf("migration %s -> %s: source and target types must differ", m.From, m.To)
+	}
+	if len(m.Steps) == 0 && m.Transform == nil {
+		return fmt.Errorf("migration %s -> %s: no steps", m.From, m.To)
+	}
+	for i, step := range m.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("migration %s -> %s: step %d: %w", m.From, m.To, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s MigrationStep) validate() error {
+	segments, err := parseMigrationPath(s.Path)
+	if err != nil {
+		return err
+	}
+	if segments[len(segments)-1].each {
+		return fmt.Errorf("path %q must end with a field name", s.Path)
+	}
+	switch s.Op {
+	case MigrationOpRename:
+		if s.To == "" {
+			return fmt.Errorf("rename requires to")
+		}
+	case MigrationOpRemove:
+	case MigrationOpSetDefault:
+		if _, err := parseMigrationValue(s.Value); err != nil {
+			return fmt.Errorf("set_default value: %w", err)
+		}
+	case MigrationOpMapToList:
+		if s.KeyField == "" || s.ValueField == "" {
+			return fmt.Errorf("map_to_list requires key_field and value_field")
+		}
+	default:
+		return fmt.Errorf("unknown op %q", s.Op)
+	}
+	return nil
+}
+
+// migrateContent applies the migration to a document's content and returns the new content.
+// Keys other than format/data are kept as is.
+func (m DocumentTypeMigration) migrateContent(content map[string]any) (map[string]any, error) {
+	format := GetContentFormat(m.From)
+	data, _ := content["data"].(string)
+	root, issue := parseDocumentData(format, data)
+	if issue != nil {
+		return nil, fmt.Errorf("parse content: %s", issue.String())
+	}
+	if root == nil {
+		return nil, fmt.Errorf("content is empty")
+	}
+	for i, step := range m.Steps {
+		if err := step.apply(root); err != nil {
+			return nil, fmt.Errorf("step %d (%s %s): %w", i+1, step.Op, step.Path, err)
+		}
+	}
+	if m.Transform != nil {
+		if err := m.Transform(root); err != nil {
+			return nil, fmt.Errorf("transform: %w", err)
+		}
+	}
+	migrated, err := encodeDocumentData(format, root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]any, len(content))
+	for k, v := range content {
+		result[k] = v
+	}
+	result["format"] = string(GetContentFormat(m.To))
+	result["data"] = migrated
+	return result, nil
+}
+
+// encodeDocumentData is the inverse of parseDocumentData. YAML keeps the original
+// node styles and comments; front matter is written back only when meta is present.
+func encodeDocumentData(format ContentFormat, root *yaml.Node) (string, error) {
+	if format == ContentFormatJSON {
+		var value any
+		if err := root.Decode(&value); err != nil {
+			return "", fmt.Errorf("decode migrated content: %w", err)
+		}
+		raw, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("encode migrated content: %w", err)
+		}
+		return string(raw), nil
+	}
+
+	var buf bytes.Buffer
+	encode := func(node *yaml.Node) error {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return fmt.Errorf("encode migrated content: %w", err)
+		}
+		return enc.Close()
+	}
+	meta, body := mappingValue(root, "meta"), mappingValue(root, "body")
+	if meta != nil && !isNullNode(meta) {
+		buf.WriteString("---\n")
+		if err := encode(meta); err != nil {
+			return "", err
+		}
+		buf.WriteString("---\n\n")
+	}
+	if body != nil {
+		if err := encode(body); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+type migrationPathSegment struct {
+	name string
+	each bool
+}
+
+func parseMigrationPath(path string) ([]migrationPathSegment, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	parts := strings.Split(path, ".")
+	segments := make([]migrationPathSegment, 0, len(parts))
+	for _, part := range parts {
+		name, each := strings.CutSuffix(part, "[]")
+		if name == "" || strings.ContainsAny(name, "[]") {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+		segments = append(segments, migrationPathSegment{name: name, each: each})
+	}
+	return segments, nil
+}
+
+func parseMigrationValue(text string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// parents returns the mappings that hold the last path segment, together with its name.
+func (s MigrationStep) parents(root *yaml.Node) ([]*yaml.Node, string) {
+	segments, _ := parseMigrationPath(s.Path)
+	nodes := []*yaml.Node{root}
+	for _, seg := range segments[:len(segments)-1] {
+		var next []*yaml.Node
+		for _, node := range nodes {
+			value := mappingValue(node, seg.name)
+			if value == nil {
+				continue
+			}
+			if !seg.each {
+				next = append(next, value)
+				continue
+			}
+			if value.Kind == yaml.SequenceNode {
+				next = append(next, value.Content...)
+			}
+		}
+		nodes = next
+	}
+	mappings := nodes[:0]
+	for _, node := range nodes {
+		if node.Kind == yaml.MappingNode {
+			mappings = append(mappings, node)
+		}
+	}
+	return mappings, segments[len(segments)-1].name
+}
+
+func (s MigrationStep) apply(root *yaml.Node) error {
+	parents, field := s.parents(root)
+	for _, parent := range parents {
+		idx := mappingKeyIndex(parent, field)
+		switch s.Op {
+		case MigrationOpRename:
+			if idx < 0 {
+				continue
+			}
+			if mappingKeyIndex(parent, s.To) >= 0 {
+				return fmt.Errorf("field %q already exists", s.To)
+			}
+			parent.Content[idx].Value = s.To
+		case MigrationOpRemove:
+			if idx < 0 {
+				continue
+			}
+			parent.Content = append(parent.Content[:idx], parent.Content[idx+2:]...)
+		case MigrationOpSetDefault:
+			if idx >= 0 {
+				continue
+			}
+			value, err := parseMigrationValue(s.Value)
+			if err != nil {
+				return err
+			}
+			parent.Content = append(parent.Content, stringNode(field), value)
+		case MigrationOpMapToList:
+			if idx < 0 {
+				continue
+			}
+			value := parent.Content[idx+1]
+			switch value.Kind {
+			case yaml.SequenceNode:
+				continue
+			case yaml.MappingNode:
+				list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+				for i := 0; i+1 < len(value.Content); i += 2 {
+					list.Content = append(list.Content, &yaml.Node{
+						Kind: yaml.MappingNode,
+						Tag:  "!!map",
+						Content: []*yaml.Node{
+							stringNode(s.KeyField), value.Content[i],
+							stringNode(s.ValueField), value.Content[i+1],
+						},
+					})
+				}
+				parent.Content[idx+1] = list
+			default:
+				return fmt.Errorf("field %q is not a mapping", field)
+			}
+		default:
+			return fmt.Errorf("unknown op %q", s.Op)
+		}
+	}
+	return nil
+}
+
+func mappingKeyIndex(mapping *yaml.Node, key string) int {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	idx := mappingKeyIndex(mapping, key)
+	if idx < 0 {
+		return nil
+	}
+	value := mapping.Content[idx+1]
+	if value.Kind == yaml.AliasNode {
+		value = value.Alias
+	}
+	return value
+}
+
+func isNullNode(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
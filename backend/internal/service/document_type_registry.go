@@ -0,0 +1,348 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// 文档类型注册错误
+var (
+	ErrDocumentTypeNotFound = errors.New("document type not found")
+	ErrDocumentTypeExists   = errors.New("document type already exists")
+	ErrDocumentTypeBuiltIn  = errors.New("built-in document types are managed by doc-types/config.yaml")
+	ErrDocumentTypeInUse    = errors.New("document type is still used by existing documents")
+)
+
+// documentTypeIDPattern 类型 ID：小写字母开头，以版本后缀 _vN 结尾，如 comprehensive_choice_v2
+var documentTypeIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,56}_v[0-9]{1,4}$`)
+
+// DocumentTypeInfo 文档类型的对外视图（含模板与主题）
+type DocumentTypeInfo struct {
+	ID            DocumentType        `json:"id"`
+	Label         string              `json:"label"`
+	ContentFormat ContentFormat       `json:"content_format"`
+	Template      string              `json:"template"`
+	Schema        json.RawMessage     `json:"schema,omitempty"`
+	Themes        []DocumentTypeTheme `json:"themes"`
+	BuiltIn       bool                `json:"built_in"`
+}
+
+// DocumentTypeRequest 注册/更新文档类型请求
+type DocumentTypeRequest struct {
+	ID            string              `json:"id"`
+	Label         string              `json:"label"`
+	ContentFormat string              `json:"content_format"`
+	Template      string              `json:"template"`
+	Schema        json.RawMessage     `json:"schema,omitempty"`
+	Themes        []DocumentTypeTheme `json:"themes,omitempty"`
+}
+
+// DocumentTypeService 文档类型注册表服务
+// 内置类型由 docgen 生成；运行时注册的类型保存在 document_types 表，
+// 加载后与内置类型一起供 IsValidDocumentType / GetContentFormat 等函数查询
+type DocumentTypeService struct {
+	db    *gorm.DB
+	ndr   ndrclient.Client
+	audit *AuditService
+}
+
+// NewDocumentTypeService 创建文档类型注册表服务；ndr 用于删除前检查该类型是否仍有文档
+func NewDocumentTypeService(db *gorm.DB, ndr ndrclient.Client) *DocumentTypeService {
+	return &DocumentTypeService{db: db, ndr: ndr, audit: NewAuditService(db)}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (s *DocumentTypeService) Roles() *RoleService {
+	return NewRoleService(s.db)
+}
+
+// Load 从数据库加载运行时注册的类型，替换当前注册表
+func (s *DocumentTypeService) Load(ctx context.Context) error {
+	var records []database.DocumentTypeRecord
+	if err := s.db.WithContext(ctx).Order("id ASC").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load document types: %w", err)
+	}
+	defs := make([]DocumentTypeDefinition, 0, len(records))
+	for _, record := range records {
+		defs = append(defs, definitionFromRecord(record))
+	}
+	setCustomDocumentTypes(defs)
+	return nil
+}
+
+// StartRefresh 定期重新加载注册表，使多实例部署能看到其他实例注册的类型
+func (s *DocumentTypeService) StartRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Load(ctx); err != nil {
+					log.Printf("[document_types] refresh failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// List 列出所有文档类型（内置在前）
+func (s *DocumentTypeService) List(ctx context.Context) []DocumentTypeInfo {
+	types := ValidDocumentTypes()
+	out := make([]DocumentTypeInfo, 0, len(types))
+	for _, id := range types {
+		if def, ok := lookupDocumentType(id); ok {
+			out = append(out, documentTypeInfo(def))
+		}
+	}
+	return out
+}
+
+// Get 获取单个文档类型
+func (s *DocumentTypeService) Get(ctx context.Context, id string) (*DocumentTypeInfo, error) {
+	def, ok := lookupDocumentType(DocumentType(id))
+	if !ok {
+		return nil, ErrDocumentTypeNotFound
+	}
+	info := documentTypeInfo(def)
+	return &info, nil
+}
+
+// Register 注册新的文档类型
+func (s *DocumentTypeService) Register(ctx context.Context, meta RequestMeta, req DocumentTypeRequest) (*DocumentTypeInfo, error) {
+	id := strings.TrimSpace(req.ID)
+	if !documentTypeIDPattern.MatchString(id) {
+		return nil, newValidationError("类型 ID 只能包含小写字母、数字和下划线，以字母开头并以版本后缀结尾（如 essay_v2）")
+	}
+	record := database.DocumentTypeRecord{TypeID: id, CreatedBy: meta.UserID}
+	if err := applyDocumentTypeRequest(&record, req); err != nil {
+		return nil, err
+	}
+	if IsValidDocumentType(id) {
+		return nil, ErrDocumentTypeExists
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&database.DocumentTypeRecord{}).Where("type_id = ?", id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check document type: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrDocumentTypeExists
+	}
+
+	if err := s.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to create document type: %w", err)
+	}
+	if err := s.Load(ctx); err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, meta, "document_type.create", id, map[string]interface{}{
+		"label":          record.Label,
+		"content_format": record.ContentFormat,
+	})
+	return s.Get(ctx, id)
+}
+
+// Update 更新运行时注册的类型（内容格式不可修改）
+func (s *DocumentTypeService) Update(ctx context.Context, meta RequestMeta, id string, req DocumentTypeRequest) (*DocumentTypeInfo, error) {
+	record, err := s.findRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContentFormat == "" {
+		req.ContentFormat = record.ContentFormat
+	}
+	if req.ContentFormat != record.ContentFormat {
+		return nil, newValidationError("已有类型的内容格式不可修改（当前为 %s）", record.ContentFormat)
+	}
+	if err := applyDocumentTypeRequest(record, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to update document type: %w", err)
+	}
+	if err := s.Load(ctx); err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, meta, "document_type.update", id, map[string]interface{}{"label": record.Label})
+	return s.Get(ctx, id)
+}
+
+// Delete 删除运行时注册的类型；仍有该类型的文档（含回收站中的）时拒绝删除
+func (s *DocumentTypeService) Delete(ctx context.Context, meta RequestMeta, id string) error {
+	record, err := s.findRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+	inUse, err := s.hasDocuments(ctx, meta, id)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrDocumentTypeInUse
+	}
+	if err := s.db.WithContext(ctx).Delete(record).Error; err != nil {
+		return fmt.Errorf("failed to delete document type: %w", err)
+	}
+	if err := s.Load(ctx); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, meta, "document_type.delete", id, nil)
+	return nil
+}
+
+// hasDocuments 查询 NDR 中是否存在该类型的文档（包括已软删除、仍可恢复的）
+func (s *DocumentTypeService) hasDocuments(ctx context.Context, meta RequestMeta, id string) (bool, error) {
+	query := url.Values{}
+	query.Set("type", id)
+	query.Set("include_deleted", "true")
+	query.Set("page", "1")
+	query.Set("size", "1")
+	page, err := s.ndr.ListDocuments(ctx, toNDRMeta(meta), query)
+	if err != nil {
+		return false, fmt.Errorf("failed to check documents of type %s: %w", id, err)
+	}
+	return page.Total > 0 || len(page.Items) > 0, nil
+}
+
+func (s *DocumentTypeService) findRecord(ctx context.Context, id string) (*database.DocumentTypeRecord, error) {
+	if _, builtin := documentTypeDefinitions[DocumentType(id)]; builtin {
+		return nil, ErrDocumentTypeBuiltIn
+	}
+	var record database.DocumentTypeRecord
+	if err := s.db.WithContext(ctx).Where("type_id = ?", id).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDocumentTypeNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *DocumentTypeService) recordAudit(ctx context.Context, meta RequestMeta, action, id string, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: AuditResourceDocumentType,
+		ResourceID:   id,
+		Details:      details,
+	})
+}
+
+// applyDocumentTypeRequest 按 docgen 的规则校验请求并写入记录
+func applyDocumentTypeRequest(record *database.DocumentTypeRecord, req DocumentTypeRequest) error {
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		return newValidationError("类型名称不能为空")
+	}
+	format := ContentFormat(strings.ToLower(strings.TrimSpace(req.ContentFormat)))
+	switch format {
+	case ContentFormatHTML, ContentFormatYAML, ContentFormatMarkdown, ContentFormatJSON:
+	default:
+		return newValidationError("不支持的内容格式 %q（可选 html、yaml、markdown、json）", req.ContentFormat)
+	}
+	if strings.TrimSpace(req.Template) == "" {
+		return newValidationError("模板内容不能为空")
+	}
+
+	schema := ""
+	if raw := bytes.TrimSpace(req.Schema); len(raw) > 0 && !bytes.Equal(raw, []byte("null")) {
+		if format != ContentFormatYAML && format != ContentFormatJSON {
+			return newValidationError("只有 yaml 和 json 格式的类型可以声明 schema")
+		}
+		compiled, err := jsonschema.Compile(raw)
+		if err != nil {
+			return newValidationError("schema 无效: %v", err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return newValidationError("schema 无效: %v", err)
+		}
+		root, issue := parseDocumentData(format, req.Template)
+		if issue != nil {
+			return newValidationError("模板解析失败: %s", issue.String())
+		}
+		if err := compiled.ValidateNode(root); err != nil {
+			return newValidationError("模板不符合 schema: %v", err)
+		}
+		schema = compact.String()
+	}
+
+	themes := make(database.DocumentTypeThemes, 0, len(req.Themes))
+	seen := make(map[string]bool, len(req.Themes))
+	for _, theme := range req.Themes {
+		theme.ID = strings.TrimSpace(theme.ID)
+		if theme.ID == "" {
+			return newValidationError("主题缺少 id")
+		}
+		if seen[theme.ID] {
+			return newValidationError("主题 id 重复: %s", theme.ID)
+		}
+		if strings.TrimSpace(theme.CSS) == "" {
+			return newValidationError("主题 %s 缺少 css", theme.ID)
+		}
+		if theme.Label == "" {
+			theme.Label = theme.ID
+		}
+		seen[theme.ID] = true
+		themes = append(themes, database.DocumentTypeTheme(theme))
+	}
+
+	record.Label = label
+	record.ContentFormat = string(format)
+	record.Template = req.Template
+	record.Schema = schema
+	record.Themes = themes
+	return nil
+}
+
+func definitionFromRecord(record database.DocumentTypeRecord) DocumentTypeDefinition {
+	themes := make([]DocumentTypeTheme, len(record.Themes))
+	for i, theme := range record.Themes {
+		themes[i] = DocumentTypeTheme(theme)
+	}
+	return DocumentTypeDefinition{
+		ID:            DocumentType(record.TypeID),
+		Label:         record.Label,
+		ContentFormat: ContentFormat(record.ContentFormat),
+		Template:      record.Template,
+		Schema:        record.Schema,
+		Themes:        themes,
+		Custom:        true,
+	}
+}
+
+func documentTypeInfo(def DocumentTypeDefinition) DocumentTypeInfo {
+	info := DocumentTypeInfo{
+		ID:            def.ID,
+		Label:         def.Label,
+		ContentFormat: def.ContentFormat,
+		Template:      def.Template,
+		Themes:        def.Themes,
+		BuiltIn:       !def.Custom,
+	}
+	if def.Schema != "" {
+		info.Schema = json.RawMessage(def.Schema)
+	}
+	if info.Themes == nil {
+		info.Themes = []DocumentTypeTheme{}
+	}
+	return info
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+func setupDocumentTypeDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.DocumentTypeRecord{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	t.Cleanup(func() { setCustomDocumentTypes(nil) })
+	return db
+}
+
+func TestDocumentTypeService_RegisterCustomType(t *testing.T) {
+	db := setupDocumentTypeDB(t)
+	ndr := newFakeNDR()
+	svc := NewDocumentTypeService(db, ndr)
+	ctx := context.Background()
+
+	info, err := svc.Register(ctx, RequestMeta{UserID: "admin"}, DocumentTypeRequest{
+		ID:            "short_answer_v1",
+		Label:         "简答题(v1)",
+		ContentFormat: "yaml",
+		Template:      "---\nid: 0\n---\ntitle: 题干\nanswer: 答案\n",
+		Schema:        json.RawMessage(`{"type":"object","required":["body"],"properties":{"body":{"type":"object","required":["answer"]}}}`),
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if info.BuiltIn || len(info.Schema) == 0 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if !IsValidDocumentType("short_answer_v1") || GetContentFormat("short_answer_v1") != ContentFormatYAML {
+		t.Fatal("expected registered type to be visible in the registry")
+	}
+	err = ValidateDocumentData("short_answer_v1", map[string]any{"format": "yaml", "data": "title: 题干\n"})
+	var contentErr *DocumentContentError
+	if !errors.As(err, &contentErr) || contentErr.Issues[0].Path != "body.answer" {
+		t.Fatalf("expected schema of custom type to apply, got %v", err)
+	}
+
+	// 新服务实例从数据库加载
+	setCustomDocumentTypes(nil)
+	if IsValidDocumentType("short_answer_v1") {
+		t.Fatal("expected registry to be cleared")
+	}
+	if err := NewDocumentTypeService(db, ndr).Load(ctx); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	types := ValidDocumentTypes()
+	if types[len(types)-1] != "short_answer_v1" {
+		t.Fatalf("expected custom type after built-ins, got %v", types)
+	}
+
+	if _, err := svc.Update(ctx, RequestMeta{}, "short_answer_v1", DocumentTypeRequest{Label: "简答题", ContentFormat: "html", Template: "<p></p>"}); err == nil {
+		t.Fatal("expected content format change to be rejected")
+	}
+	if _, err := svc.Register(ctx, RequestMeta{}, DocumentTypeRequest{ID: "short_answer_v1", Label: "x", ContentFormat: "yaml", Template: "a: 1"}); !errors.Is(err, ErrDocumentTypeExists) {
+		t.Fatalf("expected ErrDocumentTypeExists, got %v", err)
+	}
+
+	// 更新 schema 后立即按新 schema 校验，不复用旧的编译结果
+	if _, err := svc.Update(ctx, RequestMeta{}, "short_answer_v1", DocumentTypeRequest{
+		Label:    "简答题",
+		Template: "---\nid: 0\n---\ntitle: 题干\n",
+		Schema:   json.RawMessage(`{"type":"object","required":["body"],"properties":{"body":{"type":"object","required":["title"]}}}`),
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := ValidateDocumentData("short_answer_v1", map[string]any{"format": "yaml", "data": "title: 题干\n"}); err != nil {
+		t.Fatalf("expected updated schema to apply, got %v", err)
+	}
+
+	ndr.docsListResp = ndrclient.DocumentsPage{Total: 1, Items: []ndrclient.Document{{ID: 7}}}
+	if err := svc.Delete(ctx, RequestMeta{}, "short_answer_v1"); !errors.Is(err, ErrDocumentTypeInUse) {
+		t.Fatalf("expected ErrDocumentTypeInUse while documents exist, got %v", err)
+	}
+	if !IsValidDocumentType("short_answer_v1") {
+		t.Fatal("expected type in use to stay registered")
+	}
+	ndr.docsListResp = ndrclient.DocumentsPage{}
+	if err := svc.Delete(ctx, RequestMeta{}, "short_answer_v1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if IsValidDocumentType("short_answer_v1") {
+		t.Fatal("expected deleted type to be removed from the registry")
+	}
+}
+
+func TestDocumentTypeService_ValidatesLikeDocgen(t *testing.T) {
+	db := setupDocumentTypeDB(t)
+	svc := NewDocumentTypeService(db, newFakeNDR())
+	ctx := context.Background()
+
+	cases := []DocumentTypeRequest{
+		{ID: "Bad-ID", Label: "x", ContentFormat: "yaml", Template: "a: 1"},
+		{ID: "notes_v1", Label: "x", ContentFormat: "docx", Template: "a"},
+		{ID: "notes_v1", Label: "x", ContentFormat: "yaml", Template: " "},
+		{ID: "notes_v1", Label: "x", ContentFormat: "html", Template: "<p></p>", Schema: json.RawMessage(`{"type":"object"}`)},
+		{ID: "notes_v1", Label: "x", ContentFormat: "yaml", Template: "a: 1", Schema: json.RawMessage(`{"required":["body"],"properties":{"body":{"required":["b"]}}}`)},
+		{ID: "notes_v1", Label: "x", ContentFormat: "html", Template: "<p></p>", Themes: []DocumentTypeTheme{{ID: "dark"}}},
+	}
+	for i, req := range cases {
+		var vErr *ValidationError
+		if _, err := svc.Register(ctx, RequestMeta{}, req); !errors.As(err, &vErr) {
+			t.Fatalf("case %d: expected validation error, got %v", i, err)
+		}
+	}
+
+	if _, err := svc.Register(ctx, RequestMeta{}, DocumentTypeRequest{ID: "markdown_v1", Label: "x", ContentFormat: "markdown", Template: "#"}); !errors.Is(err, ErrDocumentTypeExists) {
+		t.Fatalf("expected built-in id to be taken, got %v", err)
+	}
+	if err := svc.Delete(ctx, RequestMeta{}, "markdown_v1"); !errors.Is(err, ErrDocumentTypeBuiltIn) {
+		t.Fatalf("expected built-in type to be read-only, got %v", err)
+	}
+	builtin, err := svc.Get(ctx, "knowledge_overview_v1")
+	if err != nil || !builtin.BuiltIn || builtin.Template == "" || len(builtin.Themes) == 0 || builtin.Themes[0].CSS == "" {
+		t.Fatalf("expected built-in type with template and themes, got %+v %v", builtin, err)
+	}
+}
@@ -3,8 +3,19 @@
 package service
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
 )
 
 // DocumentType defines the type of document content.
@@ -32,15 +43,79 @@ type DocumentTypeDefinition struct {
 	Label         string
 	ContentFormat ContentFormat
 	TemplatePath  string
+	// Template is the default content body for new documents.
+	Template string
+	// Schema is an optional JSON Schema for the parsed content.data.
+	// YAML content is validated as {"meta": <front matter>, "body": <document>}.
+	Schema string
+	Themes []DocumentTypeTheme
+	// Custom marks types registered at runtime rather than generated by docgen.
+	Custom bool
+}
+
+// DocumentTypeTheme is a selectable stylesheet for HTML document types.
+type DocumentTypeTheme struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	CSS         string `json:"css"`
 }
 
 var (
+	// Built-in types, generated by docgen from doc-types/config.yaml.
 	documentTypeDefinitions map[DocumentType]DocumentTypeDefinition
 	documentTypeOrder       []DocumentType
+
+	// Types registered at runtime, loaded from the database by DocumentTypeService.
+	customDocumentTypesMu    sync.RWMutex
+	customDocumentTypes      map[DocumentType]DocumentTypeDefinition
+	customDocumentTypesOrder []DocumentType
 )
 
-// ValidDocumentTypes returns all valid document types in configuration order.
+// setCustomDocumentTypes replaces the runtime-registered types; built-in IDs are ignored.
+func setCustomDocumentTypes(defs []DocumentTypeDefinition) {
+	byID := make(map[DocumentType]DocumentTypeDefinition, len(defs))
+	order := make([]DocumentType, 0, len(defs))
+	for _, def := range defs {
+		if _, builtin := documentTypeDefinitions[def.ID]; builtin {
+			continue
+		}
+		def.Custom = true
+		byID[def.ID] = def
+		order = append(order, def.ID)
+	}
+
+	customDocumentTypesMu.Lock()
+	customDocumentTypes = byID
+	customDocumentTypesOrder = order
+	customDocumentTypesMu.Unlock()
+}
+
+// lookupDocumentType finds a built-in or runtime-registered type.
+func lookupDocumentType(docType DocumentType) (DocumentTypeDefinition, bool) {
+	if def, ok := documentTypeDefinitions[docType]; ok {
+		return def, true
+	}
+	customDocumentTypesMu.RLock()
+	defer customDocumentTypesMu.RUnlock()
+	def, ok := customDocumentTypes[docType]
+	return def, ok
+}
+
+// ValidDocumentTypes returns all valid document types: built-ins in configuration order,
+// followed by runtime-registered types in registration order.
 func ValidDocumentTypes() []DocumentType {
+	out := builtinDocumentTypes()
+	customDocumentTypesMu.RLock()
+	out = append(out, customDocumentTypesOrder...)
+	customDocumentTypesMu.RUnlock()
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func builtinDocumentTypes() []DocumentType {
 	if len(documentTypeOrder) > 0 {
 		out := make([]DocumentType, len(documentTypeOrder))
 		copy(out, documentTypeOrder)
@@ -61,10 +136,15 @@ func ValidDocumentTypes() []DocumentType {
 
 // DocumentTypeDefinitions exposes a copy of configured document types keyed by ID.
 func DocumentTypeDefinitions() map[DocumentType]DocumentTypeDefinition {
-	if len(documentTypeDefinitions) == 0 {
+	customDocumentTypesMu.RLock()
+	defer customDocumentTypesMu.RUnlock()
+	if len(documentTypeDefinitions) == 0 && len(customDocumentTypes) == 0 {
 		return nil
 	}
-	out := make(map[DocumentType]DocumentTypeDefinition, len(documentTypeDefinitions))
+	out := make(map[DocumentType]DocumentTypeDefinition, len(documentTypeDefinitions)+len(customDocumentTypes))
+	for id, def := range customDocumentTypes {
+		out[id] = def
+	}
 	for id, def := range documentTypeDefinitions {
 		out[id] = def
 	}
@@ -73,19 +153,13 @@ func DocumentTypeDefinitions() map[DocumentType]DocumentTypeDefinition {
 
 // IsValidDocumentType checks if a document type is valid.
 func IsValidDocumentType(t string) bool {
-	if len(documentTypeDefinitions) == 0 {
-		return false
-	}
-	_, ok := documentTypeDefinitions[DocumentType(t)]
+	_, ok := lookupDocumentType(DocumentType(t))
 	return ok
 }
 
 // GetContentFormat returns the expected content format for a document type.
 func GetContentFormat(docType DocumentType) ContentFormat {
-	if len(documentTypeDefinitions) == 0 {
-		return ContentFormatYAML
-	}
-	if def, ok := documentTypeDefinitions[docType]; ok {
+	if def, ok := lookupDocumentType(docType); ok {
 		return def.ContentFormat
 	}
 	return ContentFormatYAML
@@ -249,3 +323,114 @@ func ValidateDocumentMetadata(metadata map[string]any) error {
 
 	return nil
 }
+
+// DocumentContentError reports field-level schema violations in content.data.
+type DocumentContentError struct {
+	DocType string
+	Issues  []jsonschema.Error
+}
+
+func (e *DocumentContentError) Error() string {
+	parts := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		parts = append(parts, issue.String())
+	}
+	return fmt.Sprintf("content does not match schema for type '%s': %s", e.DocType, strings.Join(parts, "; "))
+}
+
+// compiledDocumentSchemas is keyed by the schema's SHA-256, so a type whose schema is
+// updated (or deleted and re-registered) is never validated against a stale compilation.
+var compiledDocumentSchemas sync.Map // [sha256.Size]byte -> *jsonschema.Schema
+
+// documentSchema returns the compiled schema for a document type, or nil if none is declared.
+func documentSchema(docType DocumentType) (*jsonschema.Schema, error) {
+	def, ok := lookupDocumentType(docType)
+	if !ok || def.Schema == "" {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(def.Schema))
+	if cached, ok := compiledDocumentSchemas.Load(key); ok {
+		return cached.(*jsonschema.Schema), nil
+	}
+	schema, err := jsonschema.Compile([]byte(def.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("schema for document type %s: %w", docType, err)
+	}
+	compiledDocumentSchemas.Store(key, schema)
+	return schema, nil
+}
+
+// ValidateDocumentData parses content.data and validates it against the type's schema.
+// Types without a schema and empty data are accepted; violations are returned as *DocumentContentError.
+func ValidateDocumentData(docType string, content map[string]any) error {
+	schema, err := documentSchema(DocumentType(docType))
+	if err != nil || schema == nil {
+		return err
+	}
+	data, _ := content["data"].(string)
+	if strings.TrimSpace(data) == "" {
+		return nil
+	}
+
+	root, issue := parseDocumentData(GetContentFormat(DocumentType(docType)), data)
+	if issue != nil {
+		return &DocumentContentError{DocType: docType, Issues: []jsonschema.Error{*issue}}
+	}
+	if err := schema.ValidateNode(root); err != nil {
+		var vErr *jsonschema.ValidationError
+		if errors.As(err, &vErr) {
+			return &DocumentContentError{DocType: docType, Issues: vErr.Errors}
+		}
+		return err
+	}
+	return nil
+}
+
+// parseDocumentData parses content.data into a YAML node tree that keeps source positions.
+// YAML with front matter becomes {"meta": <first document>, "body": <second document>},
+// a single YAML document becomes {"body": <document>}; JSON is used as is.
+func parseDocumentData(format ContentFormat, data string) (*yaml.Node, *jsonschema.Error) {
+	decoder := yaml.NewDecoder(strings.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, yamlSyntaxIssue(err)
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, &doc)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	if format != ContentFormatYAML {
+		return docs[0], nil
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	addField := func(name string, doc *yaml.Node) {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, doc.Content[0])
+	}
+	if len(docs) > 1 {
+		addField("meta", docs[0])
+		addField("body", docs[1])
+	} else {
+		addField("body", docs[0])
+	}
+	return root, nil
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func yamlSyntaxIssue(err error) *jsonschema.Error {
+	issue := &jsonschema.Error{Message: err.Error()}
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		issue.Line, _ = strconv.Atoi(m[1])
+		issue.Message = m[2]
+	}
+	return issue
+}
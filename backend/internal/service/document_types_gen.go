@@ -8,42 +8,58 @@ func init() {
 			Label: "Markdown文档(v1)",
 			ContentFormat: ContentFormatMarkdown,
 			TemplatePath: "../../../doc-types/markdown_v1/template.md",
+			Template: "# 标题\n\n## 简介\n\n这是一个 Markdown 文档模板，你可以使用标准的 Markdown 语法进行编辑。\n\n## 主要内容\n\n### 文本格式\n\n- **粗体文本**\n- *斜体文本*\n- ~~删除线~~\n- `行内代码`\n\n### 列表\n\n1. 有序列表项 1\n2. 有序列表项 2\n3. 有序列表项 3\n\n### 代码块\n\n```javascript\n// 示例代码\nfunction hello() {\n  console.log(\"Hello, World!\");\n}\n```\n\n### 引用\n\n> 这是一段引用文本\n> 可以包含多行\n\n### 表格\n\n| 列1 | 列2 | 列3 |\n|-----|-----|-----|\n| 数据1 | 数据2 | 数据3 |\n| 数据4 | 数据5 | 数据6 |\n\n### 链接和图片\n\n[链接文本](https://example.com)\n\n![图片描述](https://via.placeholder.com/150)\n\n## 总结\n\n在此添加总结内容。\n",
 		},
 		DocumentType("comprehensive_choice_v1"): {
 			ID: DocumentType("comprehensive_choice_v1"),
 			Label: "综合知识选择题(v1)",
 			ContentFormat: ContentFormatYAML,
 			TemplatePath: "../../../doc-types/comprehensive_choice_v1/template.yaml",
+			Template: "---\nid: 0\ndoc_type: yaml\ndata_type: question\nsource:\n  - \"来源说明\"\n---\n\ntitle: |-\n  <p>在此填写题干 HTML，示例：系统进行资源分配和调度的基本单位是 (1)，其物理实体由 (2) 三部分组成。</p>\nanalysis: |-\n  <p>在此填写解析，支持 HTML。</p>\nsub_questions:\n  - options:\n      - key: A\n        content: 选项A\n      - key: B\n        content: 选项B\n      - key: C\n        content: 选项C\n      - key: D\n        content: 选项D\n    answer: A\n",
+			Schema: "{\"$schema\":\"https://json-schema.org/draft/2020-12/schema\",\"title\":\"综合知识选择题(v1)\",\"description\":\"校验解析后的 YAML：meta 为前置元数据文档，body 为题目正文文档\",\"type\":\"object\",\"required\":[\"body\"],\"properties\":{\"meta\":{\"type\":[\"object\",\"null\"],\"properties\":{\"id\":{\"type\":\"integer\",\"minimum\":0},\"doc_type\":{\"type\":\"string\"},\"data_type\":{\"type\":\"string\"},\"source\":{\"type\":[\"array\",\"null\"],\"items\":{\"type\":[\"string\",\"null\"]}}}},\"body\":{\"type\":\"object\",\"required\":[\"title\",\"sub_questions\"],\"properties\":{\"title\":{\"type\":\"string\",\"minLength\":1},\"analysis\":{\"type\":[\"string\",\"null\"]},\"sub_questions\":{\"type\":\"array\",\"minItems\":1,\"items\":{\"type\":\"object\",\"required\":[\"options\",\"answer\"],\"properties\":{\"options\":{\"type\":\"array\",\"minItems\":2,\"items\":{\"type\":\"object\",\"required\":[\"key\",\"content\"],\"properties\":{\"key\":{\"type\":\"string\",\"minLength\":1},\"content\":{\"type\":[\"string\",\"number\"]}}}},\"answer\":{\"type\":\"string\",\"minLength\":1,\"x-sibling-key\":\"options[].key\"}}}}}}}}",
 		},
 		DocumentType("case_analysis_v1"): {
 			ID: DocumentType("case_analysis_v1"),
 			Label: "案例分析题(v1)",
 			ContentFormat: ContentFormatYAML,
 			TemplatePath: "../../../doc-types/case_analysis_v1/template.yaml",
+			Template: "---\nid: 0\ndoc_type: yaml\ndata_type: case-analyze\nsource:\n  - \"来源说明\"\n---\n\ntitle: |-\n  <p>在此填写案例背景与题干描述，示例：阅读以下软件系统设计方案，完成问题1至问题N。</p>\nanalysis: |-\n  <p>在此填写解析部分，可包含段落、列表等 HTML 内容。</p>\n\ndetails:\n  - no: 1\n    question: |-\n      <p>问题1：在此输入题目正文，可使用 HTML。</p>\n    answer: |-\n      <p>参考答案示例。</p>\n    score: 10\n    type: text\n  - no: 2\n    question: |-\n      <p>问题2：可根据需要设置不同的题型。</p>\n    answer: |-\n      <p>该处填写问题2的答案。</p>\n    score: 10\n    type: text\n",
+			Schema: "{\"$schema\":\"https://json-schema.org/draft/2020-12/schema\",\"title\":\"案例分析题(v1)\",\"description\":\"校验解析后的 YAML：meta 为前置元数据文档，body 为题目正文文档\",\"type\":\"object\",\"required\":[\"body\"],\"properties\":{\"meta\":{\"type\":[\"object\",\"null\"],\"properties\":{\"id\":{\"type\":\"integer\",\"minimum\":0},\"doc_type\":{\"type\":\"string\"},\"data_type\":{\"type\":\"string\"},\"source\":{\"type\":[\"array\",\"null\"],\"items\":{\"type\":[\"string\",\"null\"]}}}},\"body\":{\"type\":\"object\",\"required\":[\"title\",\"details\"],\"properties\":{\"title\":{\"type\":\"string\",\"minLength\":1},\"analysis\":{\"type\":[\"string\",\"null\"]},\"details\":{\"type\":\"array\",\"minItems\":1,\"items\":{\"type\":\"object\",\"required\":[\"no\",\"question\",\"answer\"],\"properties\":{\"no\":{\"type\":\"integer\",\"minimum\":1},\"question\":{\"type\":\"string\",\"minLength\":1},\"answer\":{\"type\":\"string\",\"minLength\":1},\"score\":{\"type\":\"number\",\"minimum\":0},\"type\":{\"type\":\"string\"}}}}}}}}",
 		},
 		DocumentType("essay_v1"): {
 			ID: DocumentType("essay_v1"),
 			Label: "论文题(v1)",
 			ContentFormat: ContentFormatYAML,
 			TemplatePath: "../../../doc-types/essay_v1/template.yaml",
+			Template: "---\nid: 0\ndoc_type: yaml\ndata_type: article\nsource:\n  - \"来源说明\"\n---\n\ntitle: 示例论文题标题\ncontent: |-\n  <p>在此撰写论文题干，明确论题，并提出需要讨论的要点。</p>\nanalysis: |-\n  <p>在此撰写参考解析，可包含分段或列表。</p>\ndigest: |-\n  <p>要点总结。</p>\nsample: |-\n  <p>范文示例或段落。</p>\ntheme_id: 0\n",
 		},
 		DocumentType("dictation_v1"): {
 			ID: DocumentType("dictation_v1"),
 			Label: "默写(v1)",
 			ContentFormat: ContentFormatYAML,
 			TemplatePath: "../../../doc-types/dictation_v1/template.yaml",
+			Template: "---\nid: 0\ndoc_type: yaml\nquestion_type: 填空题\nsource:\n  - \"来源说明\"\n---\n\nstudy_time:\ndetails:\n  - no: 1\n    question:\n    answer: \"____答案示例____。\"\n    type: fill_blank\n    km_point: 关键知识点\n",
 		},
 		DocumentType("knowledge_overview_v1"): {
 			ID: DocumentType("knowledge_overview_v1"),
 			Label: "知识点概览(v1)",
 			ContentFormat: ContentFormatHTML,
 			TemplatePath: "../../../doc-types/knowledge_overview_v1/template.html",
+			Template: "---\nid: 0\ndoc_type: html\ndata_type: knowledge-overview\n---\n\n<div class=\"yjxt-learning-point-page\">\n  <div class=\"yjxt-point-info\">\n    <div class=\"yjxt-point-header\">\n      <div class=\"yjxt-point-number\">知识点 1/1</div>\n      <div class=\"yjxt-point-title\">示例知识点标题</div>\n    </div>\n    <div class=\"yjxt-point-meta\">\n      <div class=\"yjxt-importance\">\n        <span class=\"yjxt-meta-label\">重要等级：</span>\n        <span class=\"yjxt-star active\"></span>\n        <span class=\"yjxt-star\"></span>\n        <span class=\"yjxt-star\"></span>\n        <span class=\"yjxt-level-text\">（示例）</span>\n      </div>\n      <div class=\"yjxt-study-duration\">\n        <span class=\"yjxt-meta-label\">建议学习时长：</span>\n        <span>60分钟</span>\n      </div>\n    </div>\n  </div>\n\n  <div class=\"yjxt-content-card\">\n    <h3 class=\"yjxt-card-title\">试题示例</h3>\n    <p>在此补充知识点内容与论述要求。</p>\n  </div>\n</div>\n",
+			Themes: []DocumentTypeTheme{
+				{ID: "classic", Label: "经典蓝", Description: "", CSS: ".overview-theme-classic .html-preview-content {\n  background: #ffffff;\n  border-radius: 12px;\n  padding: 24px;\n  box-shadow: 0 10px 30px rgba(24, 144, 255, 0.08);\n}\n.overview-theme-classic .html-preview-content .yjxt-content-card {\n  border: 1px solid rgba(24, 144, 255, 0.12);\n  box-shadow: 0 4px 16px rgba(24, 144, 255, 0.08);\n}\n.overview-theme-classic .html-preview-content .yjxt-card-title {\n  color: #177ddc;\n  border-bottom: 2px solid rgba(23, 125, 220, 0.2);\n}\n.overview-theme-classic .html-preview-content .yjxt-section-title {\n  border-bottom: 2px solid rgba(23, 125, 220, 0.2);\n  color: #0c66c2;\n}\n"},
+				{ID: "warm", Label: "暖色晨曦", Description: "", CSS: ".overview-theme-warm .html-preview-content {\n  background: linear-gradient(135deg, #fff7f0, #fffefd);\n  border-radius: 16px;\n  padding: 28px;\n  box-shadow: 0 12px 32px rgba(255, 125, 0, 0.08);\n}\n.overview-theme-warm .html-preview-content .yjxt-content-card {\n  background: rgba(255, 255, 255, 0.88);\n  border: 1px solid rgba(255, 140, 0, 0.15);\n  box-shadow: 0 4px 18px rgba(255, 140, 0, 0.12);\n}\n.overview-theme-warm .html-preview-content .yjxt-card-title {\n  color: #d46b08;\n  border-bottom: 2px solid rgba(212, 107, 8, 0.2);\n}\n.overview-theme-warm .html-preview-content .yjxt-section-title {\n  color: #ad4e00;\n  border-bottom: 2px solid rgba(173, 78, 0, 0.2);\n}\n.overview-theme-warm .html-preview-content .yjxt-point-title,\n.overview-theme-warm .html-preview-content h2 {\n  color: #ad4e00;\n}\n"},
+				{ID: "night", Label: "夜间沉浸", Description: "", CSS: ".overview-theme-night .html-preview-content {\n  background: linear-gradient(135deg, #20293a, #101522);\n  border-radius: 16px;\n  padding: 28px;\n  color: #f5f7fa;\n  box-shadow: 0 16px 36px rgba(15, 23, 42, 0.45);\n}\n.overview-theme-night .html-preview-content .yjxt-content-card {\n  background: rgba(15, 23, 42, 0.9);\n  border: 1px solid rgba(148, 163, 184, 0.2);\n  box-shadow: 0 6px 20px rgba(15, 23, 42, 0.45);\n}\n.overview-theme-night .html-preview-content .yjxt-card-title,\n.overview-theme-night .html-preview-content .yjxt-section-title,\n.overview-theme-night .html-preview-content .yjxt-point-title {\n  color: #60a5fa;\n  border-bottom: 1px solid rgba(96, 165, 250, 0.3);\n}\n.overview-theme-night .html-preview-content .yjxt-summary-list li::before,\n.overview-theme-night .html-preview-content .yjxt-advice-content li::before,\n.overview-theme-night .html-preview-content .yjxt-bullet-list li::before {\n  background: #60a5fa;\n}\n.overview-theme-night .html-preview-content p,\n.overview-theme-night .html-preview-content li {\n  color: #e2e8f0;\n}\n"},
+				{ID: "glass", Label: "玻璃拟态", Description: "半透明蓝紫色，强调高光与模糊", CSS: "/* 玻璃拟态主题：柔和蓝紫色调，配合高斯模糊 */\n.overview-theme-glass {\n  background: linear-gradient(135deg, rgba(59, 130, 246, 0.08), rgba(147, 51, 234, 0.08));\n  padding: 12px;\n  border-radius: 20px;\n  backdrop-filter: blur(18px);\n}\n\n.overview-theme-glass .html-preview-content {\n  background: rgba(255, 255, 255, 0.65);\n  backdrop-filter: blur(24px);\n  border-radius: 18px;\n  padding: 28px;\n  box-shadow: 0 20px 45px rgba(79, 70, 229, 0.18);\n  border: 1px solid rgba(79, 70, 229, 0.15);\n}\n\n.overview-theme-glass .html-preview-content .yjxt-content-card {\n  border-radius: 16px;\n  border: 1px solid rgba(59, 130, 246, 0.18);\n  background: rgba(255, 255, 255, 0.85);\n  box-shadow: 0 12px 28px rgba(59, 130, 246, 0.12);\n}\n\n.overview-theme-glass .html-preview-content .yjxt-card-title {\n  color: #3b82f6;\n  border-bottom: 2px solid rgba(59, 130, 246, 0.3);\n}\n\n.overview-theme-glass .html-preview-content .yjxt-section-title {\n  color: #6d28d9;\n  border-bottom: 2px solid rgba(109, 40, 217, 0.25);\n}\n\n.overview-theme-glass .html-preview-content p,\n.overview-theme-glass .html-preview-content li {\n  color: rgba(31, 41, 55, 0.86);\n}\n"},
+				{ID: "forest", Label: "竹林墨韵", Description: "墨绿色调，适合国风内容", CSS: "/* 森林墨绿主题：偏国风的竹林墨韵 */\n.overview-theme-forest {\n  background: linear-gradient(135deg, rgba(15, 118, 110, 0.12), rgba(22, 163, 74, 0.12));\n  padding: 16px;\n  border-radius: 18px;\n}\n\n.overview-theme-forest .html-preview-content {\n  background: #f8fdf8;\n  border-radius: 14px;\n  padding: 26px;\n  border: 1px solid rgba(22, 163, 74, 0.25);\n  box-shadow: 0 16px 32px rgba(22, 101, 52, 0.12);\n}\n\n.overview-theme-forest .html-preview-content .yjxt-content-card {\n  border-radius: 12px;\n  border: 1px solid rgba(22, 101, 52, 0.18);\n  background: rgba(255, 255, 255, 0.95);\n  box-shadow: 0 8px 20px rgba(15, 118, 110, 0.1);\n}\n\n.overview-theme-forest .html-preview-content .yjxt-card-title {\n  color: #256f43;\n  border-bottom: 2px solid rgba(37, 111, 67, 0.28);\n}\n\n.overview-theme-forest .html-preview-content .yjxt-section-title {\n  color: #14532d;\n  border-bottom: 2px solid rgba(20, 83, 45, 0.22);\n}\n\n.overview-theme-forest .html-preview-content p,\n.overview-theme-forest .html-preview-content li {\n  color: rgba(22, 83, 55, 0.9);\n}\n\n.overview-theme-forest .html-preview-content .yjxt-bullet-list li::before,\n.overview-theme-forest .html-preview-content .yjxt-advice-content li::before {\n  background: #16a34a;\n}\n"},
+			},
 		},
 		DocumentType("xiaohongshu_cards_v1"): {
 			ID: DocumentType("xiaohongshu_cards_v1"),
 			Label: "小红书卡片(v1)",
 			ContentFormat: ContentFormatHTML,
 			TemplatePath: "../../../doc-types/xiaohongshu_cards_v1/template.html",
+			Template: "<!DOCTYPE html>\n<html lang=\"zh-CN\">\n<head>\n    <meta charset=\"UTF-8\">\n    <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\">\n    <title>小红书卡片</title>\n    <style>\n        * { margin: 0; padding: 0; box-sizing: border-box; }\n        body { font-family: 'Noto Sans SC', sans-serif; background: #0F0F0F; }\n        .card {\n            width: 600px;\n            height: 800px;\n            background: #0F0F0F;\n            padding: 40px;\n            page-break-after: always;\n        }\n    </style>\n</head>\n<body>\n    <div class=\"card\">\n        <h1 style=\"color: #fff;\">小红书卡片模板</h1>\n        <p style=\"color: #a0a0a0;\">请使用工作流生成内容</p>\n    </div>\n</body>\n</html>\n",
 		},
 	}
 	documentTypeOrder = []DocumentType{
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
 )
 
@@ -28,6 +29,8 @@ type DocumentCreateRequest struct {
 	Content  map[string]any `json:"content,omitempty"`
 	Type     *string        `json:"type,omitempty"`
 	Position *int           `json:"position,omitempty"`
+	// NodeID 创建后绑定到该节点；权限按节点所属课程判断
+	NodeID *int64 `json:"node_id,omitempty"`
 }
 
 // ListDocuments fetches a paginated list of documents from NDR.
@@ -114,16 +117,8 @@ func (s *Service) ListDeletedDocuments(ctx context.Context, meta RequestMeta, qu
 
 // CreateDocument creates a new document upstream.
 func (s *Service) CreateDocument(ctx context.Context, meta RequestMeta, payload DocumentCreateRequest) (ndrclient.Document, error) {
-	// Validate content structure if type is provided
-	if payload.Type != nil && payload.Content != nil {
-		if err := ValidateDocumentContentStructure(payload.Content); err != nil {
-			return ndrclient.Document{}, fmt.Errorf("invalid content: %w", err)
-		}
-	}
-
-	// Validate metadata
-	if err := ValidateDocumentMetadata(payload.Metadata); err != nil {
-		return ndrclient.Document{}, fmt.Errorf("invalid metadata: %w", err)
+	if err := validateDocumentCreate(payload); err != nil {
+		return ndrclient.Document{}, err
 	}
 
 	body := ndrclient.DocumentCreate{
@@ -135,27 +130,95 @@ func (s *Service) CreateDocument(ctx context.Context, meta RequestMeta, payload
 	}
 
 	// If no position is specified, NDR will assign the next available position automatically
-	return s.ndr.CreateDocument(ctx, toNDRMeta(meta), body)
+	doc, err := s.ndr.CreateDocument(ctx, toNDRMeta(meta), body)
+	if err != nil {
+		return doc, err
+	}
+	s.reindexDocuments(doc.ID)
+	s.syncReferences(ctx, doc)
+	s.recordAudit(ctx, meta, "document.create", AuditResourceDocument, doc.ID, map[string]interface{}{
+		"title": doc.Title,
+		"type":  payload.Type,
+	})
+	if payload.NodeID != nil {
+		if err := s.BindDocument(ctx, meta, *payload.NodeID, doc.ID); err != nil {
+			if cleanupErr := s.discardCreatedDocument(ctx, meta, doc.ID); cleanupErr != nil {
+				log.Printf("[WARN] CreateDocument: failed to cleanup orphan document %d after bind failure: %v", doc.ID, cleanupErr)
+			}
+			return ndrclient.Document{}, fmt.Errorf("failed to bind document to node: %w", err)
+		}
+	}
+	return doc, nil
+}
+
+// discardCreatedDocument purges a document that was just created but could not be bound,
+// so it neither lands in the trash nor records a delete audit event.
+func (s *Service) discardCreatedDocument(ctx context.Context, meta RequestMeta, docID int64) error {
+	if err := s.ndr.PurgeDocument(ctx, toNDRMeta(meta), docID); err != nil {
+		return err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	s.markDocumentRemoved(ctx, docID, database.ReferenceTargetMissing)
+	return nil
+}
+
+// validateDocumentCreate checks the content structure (when a type is given) and metadata of a new document.
+func validateDocumentCreate(payload DocumentCreateRequest) error {
+	if payload.Type != nil && payload.Content != nil {
+		if err := ValidateDocumentContentStructure(payload.Content); err != nil {
+			return fmt.Errorf("invalid content: %w", err)
+		}
+		if err := ValidateDocumentData(*payload.Type, payload.Content); err != nil {
+			return fmt.Errorf("invalid content: %w", err)
+		}
+	}
+	if err := ValidateDocumentMetadata(payload.Metadata); err != nil {
+		return fmt.Errorf("invalid metadata: %w", err)
+	}
+	return nil
 }
 
 // BindDocument associates a document with a specific node.
 func (s *Service) BindDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) error {
-	return s.ndr.BindDocument(ctx, toNDRMeta(meta), nodeID, docID)
+	if err := s.ndr.BindDocument(ctx, toNDRMeta(meta), nodeID, docID); err != nil {
+		return err
+	}
+	invalidateNodes(ctx, s.cache)
+	s.reindexDocuments(docID)
+	s.recordAudit(ctx, meta, "document.bind", AuditResourceDocument, docID, map[string]interface{}{"node_id": nodeID})
+	return nil
 }
 
 // UnbindDocument removes the binding between a node and a document.
 func (s *Service) UnbindDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) error {
-	return s.ndr.UnbindDocument(ctx, toNDRMeta(meta), nodeID, docID)
+	if err := s.ndr.UnbindDocument(ctx, toNDRMeta(meta), nodeID, docID); err != nil {
+		return err
+	}
+	invalidateNodes(ctx, s.cache)
+	s.reindexDocuments(docID)
+	s.recordAudit(ctx, meta, "document.unbind", AuditResourceDocument, docID, map[string]interface{}{"node_id": nodeID})
+	return nil
 }
 
 // BindSourceDocument associates a document as a source document to a node (workflow input).
 func (s *Service) BindSourceDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) (ndrclient.SourceRelation, error) {
-	return s.ndr.BindSourceDocument(ctx, toNDRMeta(meta), nodeID, docID)
+	relation, err := s.ndr.BindSourceDocument(ctx, toNDRMeta(meta), nodeID, docID)
+	if err != nil {
+		return relation, err
+	}
+	invalidateNodes(ctx, s.cache)
+	s.recordAudit(ctx, meta, "document.bind_source", AuditResourceDocument, docID, map[string]interface{}{"node_id": nodeID})
+	return relation, nil
 }
 
 // UnbindSourceDocument removes a source document from a node.
 func (s *Service) UnbindSourceDocument(ctx context.Context, meta RequestMeta, nodeID, docID int64) error {
-	return s.ndr.UnbindSourceDocument(ctx, toNDRMeta(meta), nodeID, docID)
+	if err := s.ndr.UnbindSourceDocument(ctx, toNDRMeta(meta), nodeID, docID); err != nil {
+		return err
+	}
+	invalidateNodes(ctx, s.cache)
+	s.recordAudit(ctx, meta, "document.unbind_source", AuditResourceDocument, docID, map[string]interface{}{"node_id": nodeID})
+	return nil
 }
 
 // ListSourceDocuments lists all source documents for a node.
@@ -163,24 +226,109 @@ func (s *Service) ListSourceDocuments(ctx context.Context, meta RequestMeta, nod
 	return s.ndr.ListSourceDocuments(ctx, toNDRMeta(meta), nodeID)
 }
 
-// GetDocument fetches a single document by ID.
+// GetDocument fetches a single document by ID, reading through the cache.
 func (s *Service) GetDocument(ctx context.Context, meta RequestMeta, docID int64) (ndrclient.Document, error) {
-	return s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
+	cacheKey := documentCacheKey(documentsGeneration(ctx, s.cache), docID)
+	var doc ndrclient.Document
+	if cacheGetJSON(ctx, s.cache, cacheKey, &doc) {
+		return doc, nil
+	}
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return doc, err
+	}
+	cacheSetJSON(ctx, s.cache, cacheKey, doc)
+	return doc, nil
 }
 
 // DeleteDocument performs a soft delete on the document.
 func (s *Service) DeleteDocument(ctx context.Context, meta RequestMeta, docID int64) error {
-	return s.ndr.DeleteDocument(ctx, toNDRMeta(meta), docID)
+	_, err := s.DeleteDocumentWithOptions(ctx, meta, docID, DocumentRemovalOptions{})
+	return err
+}
+
+// DeleteDocumentWithOptions soft deletes the document after checking whether
+// other documents still reference it. References are kept (the document can be
+// restored) but reported as dangling until then.
+func (s *Service) DeleteDocumentWithOptions(ctx context.Context, meta RequestMeta, docID int64, opts DocumentRemovalOptions) (*DocumentRemovalResult, error) {
+	result, err := s.checkReferences(ctx, docID, opts)
+	if err != nil {
+		return nil, err
+	}
+	rootNodeID := s.auditCourse(ctx, AuditResourceDocument, docID)
+	if err := s.ndr.DeleteDocument(ctx, toNDRMeta(meta), docID); err != nil {
+		return nil, err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	s.markDocumentRemoved(ctx, docID, database.ReferenceTargetDeleted)
+	s.recordAuditInCourse(ctx, meta, "document.delete", AuditResourceDocument, docID, rootNodeID, referenceAuditDetails(result))
+	return result, nil
 }
 
 // RestoreDocument restores a previously soft-deleted document.
 func (s *Service) RestoreDocument(ctx context.Context, meta RequestMeta, docID int64) (ndrclient.Document, error) {
-	return s.ndr.RestoreDocument(ctx, toNDRMeta(meta), docID)
+	doc, err := s.ndr.RestoreDocument(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return doc, err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	if s.refs != nil {
+		if err := s.refs.SetTargetStatus(ctx, docID, database.ReferenceTargetActive); err != nil {
+			log.Printf("[references] mark document id=%d active failed: %v", docID, err)
+		}
+	}
+	s.syncReferences(ctx, doc)
+	s.recordAudit(ctx, meta, "document.restore", AuditResourceDocument, docID, map[string]interface{}{"title": doc.Title})
+	return doc, nil
 }
 
 // PurgeDocument permanently removes a document.
 func (s *Service) PurgeDocument(ctx context.Context, meta RequestMeta, docID int64) error {
-	return s.ndr.PurgeDocument(ctx, toNDRMeta(meta), docID)
+	_, err := s.PurgeDocumentWithOptions(ctx, meta, docID, DocumentRemovalOptions{})
+	return err
+}
+
+// PurgeDocumentWithOptions permanently removes a document and then removes the
+// references to it from the referencing documents' metadata.
+func (s *Service) PurgeDocumentWithOptions(ctx context.Context, meta RequestMeta, docID int64, opts DocumentRemovalOptions) (*DocumentRemovalResult, error) {
+	result, err := s.checkReferences(ctx, docID, opts)
+	if err != nil {
+		return nil, err
+	}
+	rootNodeID := s.auditCourse(ctx, AuditResourceDocument, docID)
+	if err := s.ndr.PurgeDocument(ctx, toNDRMeta(meta), docID); err != nil {
+		return nil, err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	s.markDocumentRemoved(ctx, docID, database.ReferenceTargetMissing)
+	result.PendingReferenceUpdates = s.cascadeReferenceRemoval(ctx, meta, docID, result.ReferencedBy)
+	s.recordAuditInCourse(ctx, meta, "document.purge", AuditResourceDocument, docID, rootNodeID, referenceAuditDetails(result))
+	return result, nil
+}
+
+// referenceAuditDetails records which documents still referenced the removed one.
+func referenceAuditDetails(result *DocumentRemovalResult) map[string]interface{} {
+	if len(result.ReferencedBy) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(result.ReferencedBy))
+	for i, referrer := range result.ReferencedBy {
+		ids[i] = referrer.DocumentID
+	}
+	details := map[string]interface{}{"referenced_by": ids}
+	if len(result.PendingReferenceUpdates) > 0 {
+		details["pending_reference_updates"] = result.PendingReferenceUpdates
+	}
+	return details
+}
+
+// invalidateDocumentWrite drops the cached documents and the node caches,
+// since trees embed document counts and summaries that any write can change,
+// and schedules the search index refresh.
+func (s *Service) invalidateDocumentWrite(ctx context.Context, docIDs ...int64) {
+	invalidateDocuments(ctx, s.cache, docIDs...)
+	invalidateNodes(ctx, s.cache)
+	s.reindexDocuments(docIDs...)
 }
 
 // GetDocumentBindingStatus returns the binding status of a document.
@@ -200,10 +348,27 @@ type DocumentUpdateRequest struct {
 	Metadata map[string]any `json:"metadata,omitempty"`
 	Type     *string        `json:"type,omitempty"`
 	Position *int           `json:"position,omitempty"`
+	// BaseVersion is the version the caller's edit is based on (also accepted as If-Match).
+	// When set, the update is rejected with a DocumentVersionConflictError if the document has moved on.
+	BaseVersion *int `json:"base_version,omitempty"`
 }
 
 // UpdateDocument updates an existing document upstream.
 func (s *Service) UpdateDocument(ctx context.Context, meta RequestMeta, docID int64, payload DocumentUpdateRequest) (ndrclient.Document, error) {
+	if err := s.checkDocumentLocks(ctx, meta, docID); err != nil {
+		return ndrclient.Document{}, err
+	}
+
+	var current *ndrclient.Document
+	if payload.BaseVersion != nil {
+		// Fail early on a stale base; the write itself is conditional (If-Match) at NDR.
+		doc, err := s.checkDocumentVersion(ctx, meta, docID, *payload.BaseVersion)
+		if err != nil {
+			return ndrclient.Document{}, err
+		}
+		current = &doc
+	}
+
 	// Validate content structure if both type and content are provided
 	if payload.Type != nil && payload.Content != nil {
 		if err := ValidateDocumentContentStructure(payload.Content); err != nil {
@@ -211,6 +376,25 @@ func (s *Service) UpdateDocument(ctx context.Context, meta RequestMeta, docID in
 		}
 	}
 
+	// Validate content against the type schema; the type comes from the existing document if not changed
+	if payload.Content != nil {
+		docType := payload.Type
+		if docType == nil && current != nil {
+			docType = current.Type
+		} else if docType == nil {
+			current, err := s.GetDocument(ctx, meta, docID)
+			if err != nil {
+				return ndrclient.Document{}, err
+			}
+			docType = current.Type
+		}
+		if docType != nil {
+			if err := ValidateDocumentData(*docType, payload.Content); err != nil {
+				return ndrclient.Document{}, fmt.Errorf("invalid content: %w", err)
+			}
+		}
+	}
+
 	// Validate metadata if provided
 	if payload.Metadata != nil {
 		if err := ValidateDocumentMetadata(payload.Metadata); err != nil {
@@ -219,13 +403,52 @@ func (s *Service) UpdateDocument(ctx context.Context, meta RequestMeta, docID in
 	}
 
 	body := ndrclient.DocumentUpdate{
-		Title:    payload.Title,
-		Content:  payload.Content,
-		Metadata: payload.Metadata,
-		Type:     payload.Type,
-		Position: payload.Position,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		Metadata:    payload.Metadata,
+		Type:        payload.Type,
+		Position:    payload.Position,
+		BaseVersion: payload.BaseVersion,
+	}
+	doc, err := s.ndr.UpdateDocument(ctx, toNDRMeta(meta), docID, body)
+	if err != nil {
+		if payload.BaseVersion != nil {
+			err = s.versionConflictFromNDR(ctx, meta, docID, *payload.BaseVersion, err)
+		}
+		return doc, err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	s.syncReferences(ctx, doc)
+	if payload.Title != nil {
+		s.refreshReferenceTitles(ctx, meta, docID, doc.Title)
+	}
+	s.recordAudit(ctx, meta, "document.update", AuditResourceDocument, docID, documentUpdateAuditDetails(payload, doc))
+	return doc, nil
+}
+
+// documentUpdateAuditDetails 只记录被修改的字段名，不记录正文内容
+func documentUpdateAuditDetails(payload DocumentUpdateRequest, doc ndrclient.Document) map[string]interface{} {
+	fields := make([]string, 0, 5)
+	if payload.Title != nil {
+		fields = append(fields, "title")
+	}
+	if payload.Content != nil {
+		fields = append(fields, "content")
+	}
+	if payload.Metadata != nil {
+		fields = append(fields, "metadata")
+	}
+	if payload.Type != nil {
+		fields = append(fields, "type")
+	}
+	if payload.Position != nil {
+		fields = append(fields, "position")
+	}
+	return map[string]interface{}{
+		"fields":  fields,
+		"title":   doc.Title,
+		"version": doc.Version,
 	}
-	return s.ndr.UpdateDocument(ctx, toNDRMeta(meta), docID, body)
 }
 
 // ErrInvalidDocumentReorder indicates the reorder payload is invalid.
@@ -297,7 +520,18 @@ func (s *Service) ReorderDocuments(ctx context.Context, meta RequestMeta, req Do
 		payload.Type = outboundType
 	}
 
-	return s.ndr.ReorderDocuments(ctx, toNDRMeta(meta), payload)
+	docs, err := s.ndr.ReorderDocuments(ctx, toNDRMeta(meta), payload)
+	if err != nil {
+		return nil, err
+	}
+	invalidateDocuments(ctx, s.cache, req.OrderedIDs...)
+	invalidateNodes(ctx, s.cache)
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "document.reorder",
+		ResourceType: AuditResourceDocument,
+		Details:      map[string]interface{}{"ordered_ids": req.OrderedIDs},
+	})
+	return docs, nil
 }
 
 func extractIDFilter(query url.Values) map[int64]struct{} {
@@ -448,7 +682,21 @@ func (s *Service) GetDocumentVersionDiff(ctx context.Context, meta RequestMeta,
 
 // RestoreDocumentVersion restores a document to a specific version.
 func (s *Service) RestoreDocumentVersion(ctx context.Context, meta RequestMeta, docID int64, versionNumber int) (ndrclient.Document, error) {
-	return s.ndr.RestoreDocumentVersion(ctx, toNDRMeta(meta), docID, versionNumber)
+	if err := s.checkDocumentLocks(ctx, meta, docID); err != nil {
+		return ndrclient.Document{}, err
+	}
+	doc, err := s.ndr.RestoreDocumentVersion(ctx, toNDRMeta(meta), docID, versionNumber)
+	if err != nil {
+		return doc, err
+	}
+	s.invalidateDocumentWrite(ctx, docID)
+	s.syncReferences(ctx, doc)
+	s.refreshReferenceTitles(ctx, meta, docID, doc.Title)
+	s.recordAudit(ctx, meta, "document.restore_version", AuditResourceDocument, docID, map[string]interface{}{
+		"version":     versionNumber,
+		"new_version": doc.Version,
+	})
+	return doc, nil
 }
 
 // DocumentReference represents a reference to another document stored in metadata.
@@ -458,6 +706,38 @@ type DocumentReference struct {
 	AddedAt    string `json:"added_at"`
 }
 
+// parseDocumentReferences reads metadata.references, skipping malformed entries.
+func parseDocumentReferences(metadata map[string]any) []DocumentReference {
+	refsArray, ok := metadata["references"].([]any)
+	if !ok {
+		return nil
+	}
+	references := make([]DocumentReference, 0, len(refsArray))
+	for _, refRaw := range refsArray {
+		refMap, ok := refRaw.(map[string]any)
+		if !ok {
+			continue
+		}
+		ref := DocumentReference{}
+		switch id := refMap["document_id"].(type) {
+		case float64:
+			ref.DocumentID = int64(id)
+		case int64:
+			ref.DocumentID = id
+		case int:
+			ref.DocumentID = int64(id)
+		}
+		if title, ok := refMap["title"].(string); ok {
+			ref.Title = title
+		}
+		if addedAt, ok := refMap["added_at"].(string); ok {
+			ref.AddedAt = addedAt
+		}
+		references = append(references, ref)
+	}
+	return references
+}
+
 // AddDocumentReference adds a reference to another document in the source document's metadata.
 // It prevents self-references and ensures the referenced document exists.
 func (s *Service) AddDocumentReference(ctx context.Context, meta RequestMeta, docID int64, refDocID int64) (ndrclient.Document, error) {
@@ -466,8 +746,8 @@ func (s *Service) AddDocumentReference(ctx context.Context, meta RequestMeta, do
 		return ndrclient.Document{}, fmt.Errorf("cannot add self-reference")
 	}
 
-	// Get the source document
-	doc, err := s.GetDocument(ctx, meta, docID)
+	// Get the source document (bypass the cache, metadata is rewritten below)
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
 	if err != nil {
 		return ndrclient.Document{}, fmt.Errorf("failed to get source document: %w", err)
 	}
@@ -484,27 +764,7 @@ func (s *Service) AddDocumentReference(ctx context.Context, meta RequestMeta, do
 		metadata = make(map[string]any)
 	}
 
-	var references []DocumentReference
-	if refsRaw, ok := metadata["references"]; ok {
-		// Parse existing references
-		if refsArray, ok := refsRaw.([]any); ok {
-			for _, refRaw := range refsArray {
-				if refMap, ok := refRaw.(map[string]any); ok {
-					ref := DocumentReference{}
-					if id, ok := refMap["document_id"].(float64); ok {
-						ref.DocumentID = int64(id)
-					}
-					if title, ok := refMap["title"].(string); ok {
-						ref.Title = title
-					}
-					if addedAt, ok := refMap["added_at"].(string); ok {
-						ref.AddedAt = addedAt
-					}
-					references = append(references, ref)
-				}
-			}
-		}
-	}
+	references := parseDocumentReferences(metadata)
 
 	// Check if reference already exists
 	for _, ref := range references {
@@ -541,8 +801,8 @@ func (s *Service) AddDocumentReference(ctx context.Context, meta RequestMeta, do
 
 // RemoveDocumentReference removes a reference from a document's metadata.
 func (s *Service) RemoveDocumentReference(ctx context.Context, meta RequestMeta, docID int64, refDocID int64) (ndrclient.Document, error) {
-	// Get the source document
-	doc, err := s.GetDocument(ctx, meta, docID)
+	// Get the source document (bypass the cache, metadata is rewritten below)
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
 	if err != nil {
 		return ndrclient.Document{}, fmt.Errorf("failed to get source document: %w", err)
 	}
@@ -693,7 +953,7 @@ func (s *Service) CopyDocument(ctx context.Context, meta RequestMeta, docID int6
 	// 7. Bind to target node
 	if err := s.BindDocument(ctx, meta, targetNodeID, newDoc.ID); err != nil {
 		// Try to clean up the created document
-		if cleanupErr := s.DeleteDocument(ctx, meta, newDoc.ID); cleanupErr != nil {
+		if cleanupErr := s.discardCreatedDocument(ctx, meta, newDoc.ID); cleanupErr != nil {
 			log.Printf("[WARN] CopyDocument: failed to cleanup orphan document %d after bind failure: %v", newDoc.ID, cleanupErr)
 		}
 		return nil, fmt.Errorf("failed to bind document to node: %w", err)
@@ -709,6 +969,10 @@ func (s *Service) CopyDocument(ctx context.Context, meta RequestMeta, docID int6
 // GetReferencingDocuments finds all documents that reference the given document.
 // This performs a reverse lookup by searching through all documents' metadata.
 func (s *Service) GetReferencingDocuments(ctx context.Context, meta RequestMeta, docID int64, query url.Values) ([]ndrclient.Document, error) {
+	if s.refs != nil {
+		return s.getIndexedReferencingDocuments(ctx, meta, docID)
+	}
+
 	// Get all documents (with pagination handled by caller via query params)
 	page, err := s.ListDocuments(ctx, meta, query)
 	if err != nil {
@@ -751,3 +1015,20 @@ func (s *Service) GetReferencingDocuments(ctx context.Context, meta RequestMeta,
 
 	return referencingDocs, nil
 }
+
+// getIndexedReferencingDocuments resolves back-references through the reference index.
+func (s *Service) getIndexedReferencingDocuments(ctx context.Context, meta RequestMeta, docID int64) ([]ndrclient.Document, error) {
+	referrers, err := s.refs.Referrers(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]ndrclient.Document, 0, len(referrers))
+	for _, referrer := range referrers {
+		doc, err := s.GetDocument(ctx, meta, referrer.DocumentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get referencing document %d: %w", referrer.DocumentID, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
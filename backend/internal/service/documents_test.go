@@ -3,12 +3,16 @@ package service
 import (
 	"context"
 	"errors"
+	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/yjxt/ydms/backend/internal/cache"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/ndrfake"
 )
 
 func TestCreateDocument(t *testing.T) {
@@ -40,6 +44,50 @@ func TestCreateDocument(t *testing.T) {
 	}
 }
 
+func TestCreateAndCopyDocumentPurgeOnBindFailure(t *testing.T) {
+	db := setupAuditDB(t)
+	_, baseURL := ndrfake.NewTestServer(t, ndrfake.Options{})
+	ndr := ndrclient.NewClient(ndrclient.NDRConfig{BaseURL: baseURL})
+	audit := NewAuditService(db)
+	svc := NewService(cache.NewNoop(), ndr, nil)
+	svc.SetAuditService(audit)
+	ctx := context.Background()
+	meta := RequestMeta{UserID: "tester"}
+
+	source, err := svc.CreateDocument(ctx, meta, DocumentCreateRequest{Title: "Source"})
+	if err != nil {
+		t.Fatalf("CreateDocument() error = %v", err)
+	}
+	missingNode := int64(9999)
+	if _, err := svc.CreateDocument(ctx, meta, DocumentCreateRequest{Title: "Orphan", NodeID: &missingNode}); err == nil {
+		t.Fatal("expected bind failure")
+	}
+	if _, err := svc.CopyDocument(ctx, meta, source.ID, DocumentCopyRequest{NodeID: &missingNode}); err == nil {
+		t.Fatal("expected bind failure on copy")
+	}
+
+	// 未能绑定的文档被彻底删除：不在回收站中，也没有删除审计
+	created, err := audit.List(ctx, AuditFilter{Action: "document.create", Limit: 10})
+	if err != nil || len(created.Events) != 3 {
+		t.Fatalf("expected 3 created documents, got %+v %v", created, err)
+	}
+	for _, event := range created.Events {
+		if event.ResourceID == strconv.FormatInt(source.ID, 10) {
+			continue
+		}
+		id, _ := strconv.ParseInt(event.ResourceID, 10, 64)
+		_, err := ndr.RestoreDocument(ctx, ndrclient.RequestMeta{}, id)
+		var ndrErr *ndrclient.Error
+		if !errors.As(err, &ndrErr) || ndrErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected document %d to be purged, restore returned %v", id, err)
+		}
+	}
+	deleted, err := audit.List(ctx, AuditFilter{Action: "document.delete", Limit: 10})
+	if err != nil || len(deleted.Events) != 0 {
+		t.Fatalf("expected no delete audit events, got %+v %v", deleted, err)
+	}
+}
+
 func TestCreateDocumentWithoutType(t *testing.T) {
 	fake := newFakeNDR()
 	now := time.Now().UTC()
@@ -583,3 +631,65 @@ func (f *fakeNDRWithGetDocCounter) GetDocument(_ context.Context, _ ndrclient.Re
 	*f.counter++
 	return doc, nil
 }
+
+func TestDocumentTemplatesMatchSchemas(t *testing.T) {
+	for id, def := range DocumentTypeDefinitions() {
+		if def.Schema == "" {
+			continue
+		}
+		raw, err := os.ReadFile(def.TemplatePath)
+		if err != nil {
+			t.Fatalf("read template for %s: %v", id, err)
+		}
+		content := map[string]any{"format": string(def.ContentFormat), "data": string(raw)}
+		if err := ValidateDocumentData(string(id), content); err != nil {
+			t.Fatalf("template for %s does not match its schema: %v", id, err)
+		}
+	}
+}
+
+func TestCreateDocumentRejectsSchemaViolations(t *testing.T) {
+	fake := newFakeNDR()
+	svc := NewService(cache.NewNoop(), fake, nil)
+	docType := "comprehensive_choice_v1"
+	data := "---\nid: 1\n---\ntitle: <p>题干</p>\nsub_questions:\n  - options:\n      - key: A\n        content: 甲\n      - key: B\n        content: 乙\n    answer: E\n"
+
+	_, err := svc.CreateDocument(context.Background(), RequestMeta{}, DocumentCreateRequest{
+		Title:   "Choice",
+		Type:    &docType,
+		Content: map[string]any{"format": "yaml", "data": data},
+	})
+	var contentErr *DocumentContentError
+	if !errors.As(err, &contentErr) {
+		t.Fatalf("expected DocumentContentError, got %v", err)
+	}
+	if len(contentErr.Issues) != 1 || contentErr.Issues[0].Path != "body.sub_questions[0].answer" || contentErr.Issues[0].Line != 11 {
+		t.Fatalf("unexpected issues %+v", contentErr.Issues)
+	}
+	if len(fake.createdDocs) != 0 {
+		t.Fatal("expected invalid document not to be sent upstream")
+	}
+
+	_, err = svc.CreateDocument(context.Background(), RequestMeta{}, DocumentCreateRequest{
+		Title:   "Broken",
+		Type:    &docType,
+		Content: map[string]any{"format": "yaml", "data": "title: a\nsub_questions:\n  - options: [\n"},
+	})
+	if !errors.As(err, &contentErr) || contentErr.Issues[0].Line == 0 {
+		t.Fatalf("expected YAML syntax error with line number, got %v", err)
+	}
+}
+
+func TestUpdateDocumentValidatesAgainstExistingType(t *testing.T) {
+	fake := newFakeNDR()
+	fake.getDocResp = sampleDocument(5, "Case", "case_analysis_v1", 1, time.Now(), time.Now())
+	svc := NewService(cache.NewNoop(), fake, nil)
+
+	_, err := svc.UpdateDocument(context.Background(), RequestMeta{}, 5, DocumentUpdateRequest{
+		Content: map[string]any{"format": "yaml", "data": "title: t\ndetails:\n  - no: 1\n    question: q\n"},
+	})
+	var contentErr *DocumentContentError
+	if !errors.As(err, &contentErr) || contentErr.Issues[0].Path != "body.details[0].answer" || contentErr.Issues[0].Line != 3 {
+		t.Fatalf("expected missing answer error, got %v", err)
+	}
+}
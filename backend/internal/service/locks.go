@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+// 锁的有效期：签出时可指定，持有者需在到期前发送心跳续期
+const (
+	defaultLockTTL = 5 * time.Minute
+	minLockTTL     = 30 * time.Second
+	maxLockTTL     = time.Hour
+)
+
+// 锁列表分页限制
+const (
+	defaultLockListLimit = 50
+	maxLockListLimit     = 500
+)
+
+var (
+	// ErrResourceLocked 资源已被他人锁定
+	ErrResourceLocked = errors.New("resource is locked by another user")
+	// ErrLockNotHeld 调用者未持有该锁（可能已过期或被管理员解除）
+	ErrLockNotHeld = errors.New("lock is not held by the caller")
+	// ErrLockNotFound 锁不存在
+	ErrLockNotFound = errors.New("lock not found")
+	// ErrLockForbidden 解除他人的锁需要 lock:break 权限
+	ErrLockForbidden = errors.New("breaking locks held by others requires the lock:break permission")
+)
+
+// ResourceLockedError 资源被他人锁定，附带当前持有的锁
+type ResourceLockedError struct {
+	Lock database.ResourceLock
+}
+
+func (e *ResourceLockedError) Error() string {
+	return fmt.Sprintf("%s %d is locked by %s until %s", e.Lock.ResourceType, e.Lock.ResourceID,
+		lockOwnerName(e.Lock), e.Lock.ExpiresAt.Format(time.RFC3339))
+}
+
+func (e *ResourceLockedError) Unwrap() error { return ErrResourceLocked }
+
+// LockService 文档/节点的建议性锁，保存在数据库中以便多实例共享
+type LockService struct {
+	db    *gorm.DB
+	audit *AuditService
+	roles *RoleService
+	now   func() time.Time
+}
+
+// NewLockService 创建锁服务
+func NewLockService(db *gorm.DB) *LockService {
+	return &LockService{db: db, audit: NewAuditService(db), roles: NewRoleService(db), now: time.Now}
+}
+
+// LockRequest 签出请求
+type LockRequest struct {
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // 默认 300 秒，范围 30~3600
+	Reason     string `json:"reason,omitempty"`
+}
+
+// LockInfo 管理视图中的锁及其状态
+type LockInfo struct {
+	database.ResourceLock
+	Expired     bool  `json:"expired"`
+	Stale       bool  `json:"stale"`        // 已过期或超过半个有效期未收到心跳
+	IdleSeconds int64 `json:"idle_seconds"` // 距上次心跳的秒数
+}
+
+// LockFilter 锁列表过滤条件
+type LockFilter struct {
+	ResourceType string
+	OwnerID      *uint
+	StaleOnly    bool
+	Limit        int
+	Offset       int
+}
+
+// Acquire 签出资源；调用者已持有时刷新有效期，被他人持有时返回 ResourceLockedError
+func (l *LockService) Acquire(ctx context.Context, meta RequestMeta, resourceType string, resourceID int64, req LockRequest) (*database.ResourceLock, error) {
+	if err := validateLockResource(resourceType, resourceID); err != nil {
+		return nil, err
+	}
+	ttl, err := lockTTL(req.TTLSeconds)
+	if err != nil {
+		return nil, err
+	}
+	if meta.UserIDNumeric == 0 && meta.UserID == "" {
+		return nil, newValidationError("lock owner is required")
+	}
+
+	now := l.now()
+	lock := database.ResourceLock{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		OwnerID:      meta.UserIDNumeric,
+		Owner:        meta.UserID,
+		Reason:       req.Reason,
+		TTLSeconds:   int(ttl / time.Second),
+		HeartbeatAt:  now,
+		ExpiresAt:    now.Add(ttl),
+		StaleAt:      now.Add(ttl / 2),
+	}
+	err = l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 过期的锁视为已释放
+		if err := tx.Where("resource_type = ? AND resource_id = ? AND expires_at <= ?", resourceType, resourceID, now).
+			Delete(&database.ResourceLock{}).Error; err != nil {
+			return err
+		}
+		// 唯一索引保证并发签出只有一方成功
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+
+		var existing database.ResourceLock
+		if err := tx.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).First(&existing).Error; err != nil {
+			return err
+		}
+		if !lockOwnedBy(existing, meta) {
+			return &ResourceLockedError{Lock: existing}
+		}
+		existing.Reason = lock.Reason
+		existing.TTLSeconds = lock.TTLSeconds
+		existing.HeartbeatAt = lock.HeartbeatAt
+		existing.ExpiresAt = lock.ExpiresAt
+		existing.StaleAt = lock.StaleAt
+		lock = existing
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"reason":       existing.Reason,
+			"ttl_seconds":  existing.TTLSeconds,
+			"heartbeat_at": existing.HeartbeatAt,
+			"expires_at":   existing.ExpiresAt,
+			"stale_at":     existing.StaleAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// Heartbeat 持有者续期；锁已过期、已被解除或属于他人时返回 ErrLockNotHeld
+func (l *LockService) Heartbeat(ctx context.Context, meta RequestMeta, resourceType string, resourceID int64) (*database.ResourceLock, error) {
+	if err := validateLockResource(resourceType, resourceID); err != nil {
+		return nil, err
+	}
+	lock, err := l.active(ctx, resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil || !lockOwnedBy(*lock, meta) {
+		return nil, ErrLockNotHeld
+	}
+
+	now := l.now()
+	ttl := time.Duration(lock.TTLSeconds) * time.Second
+	expiresAt := now.Add(ttl)
+	staleAt := now.Add(ttl / 2)
+	result := l.db.WithContext(ctx).Model(&database.ResourceLock{}).
+		Where("id = ? AND expires_at > ?", lock.ID, now).
+		Updates(map[string]interface{}{"heartbeat_at": now, "expires_at": expiresAt, "stale_at": staleAt})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLockNotHeld
+	}
+	lock.HeartbeatAt = now
+	lock.ExpiresAt = expiresAt
+	lock.StaleAt = staleAt
+	return lock, nil
+}
+
+// Release 持有者释放锁；资源未锁定时直接返回，被他人持有时返回 ResourceLockedError
+func (l *LockService) Release(ctx context.Context, meta RequestMeta, resourceType string, resourceID int64) error {
+	if err := validateLockResource(resourceType, resourceID); err != nil {
+		return err
+	}
+	lock, err := l.active(ctx, resourceType, resourceID)
+	if err != nil || lock == nil {
+		return err
+	}
+	if !lockOwnedBy(*lock, meta) {
+		return &ResourceLockedError{Lock: *lock}
+	}
+	return l.db.WithContext(ctx).Delete(&database.ResourceLock{}, lock.ID).Error
+}
+
+// Get 返回资源当前生效的锁，未锁定时返回 nil
+func (l *LockService) Get(ctx context.Context, resourceType string, resourceID int64) (*database.ResourceLock, error) {
+	if err := validateLockResource(resourceType, resourceID); err != nil {
+		return nil, err
+	}
+	return l.active(ctx, resourceType, resourceID)
+}
+
+// Check 任一资源被调用者以外的人锁定时返回 ResourceLockedError；未配置锁服务时不检查
+func (l *LockService) Check(ctx context.Context, meta RequestMeta, resourceType string, resourceIDs ...int64) error {
+	held, err := l.heldByOthers(ctx, meta, resourceType, resourceIDs...)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return &ResourceLockedError{Lock: held[0]}
+	}
+	return nil
+}
+
+// Override 与 Check 相同，但 force 时允许拥有 lock:break 权限的调用者无视他人的锁，
+// 被无视的每个锁都记录 lock.override 审计
+func (l *LockService) Override(ctx context.Context, meta RequestMeta, force bool, resourceType string, resourceIDs ...int64) error {
+	held, err := l.heldByOthers(ctx, meta, resourceType, resourceIDs...)
+	if err != nil || len(held) == 0 {
+		return err
+	}
+	if !force {
+		return &ResourceLockedError{Lock: held[0]}
+	}
+	if !l.canBreak(ctx, meta) {
+		return ErrLockForbidden
+	}
+	for _, lock := range held {
+		l.recordBreak(ctx, meta, "lock.override", lock)
+	}
+	return nil
+}
+
+// heldByOthers 返回被调用者以外的人持有的生效锁；未配置锁服务时为空
+func (l *LockService) heldByOthers(ctx context.Context, meta RequestMeta, resourceType string, resourceIDs ...int64) ([]database.ResourceLock, error) {
+	if l == nil || len(resourceIDs) == 0 {
+		return nil, nil
+	}
+	var locks []database.ResourceLock
+	if err := l.db.WithContext(ctx).
+		Where("resource_type = ? AND resource_id IN ? AND expires_at > ?", resourceType, resourceIDs, l.now()).
+		Order("id ASC").Find(&locks).Error; err != nil {
+		return nil, fmt.Errorf("check %s locks: %w", resourceType, err)
+	}
+	held := make([]database.ResourceLock, 0, len(locks))
+	for _, lock := range locks {
+		if !lockOwnedBy(lock, meta) {
+			held = append(held, lock)
+		}
+	}
+	return held, nil
+}
+
+// hasActive 是否存在该类型的生效锁，用于跳过不必要的绑定查询
+func (l *LockService) hasActive(ctx context.Context, resourceType string) (bool, error) {
+	if l == nil {
+		return false, nil
+	}
+	var count int64
+	if err := l.db.WithContext(ctx).Model(&database.ResourceLock{}).
+		Where("resource_type = ? AND expires_at > ?", resourceType, l.now()).
+		Limit(1).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("check %s locks: %w", resourceType, err)
+	}
+	return count > 0, nil
+}
+
+// List 管理视图：列出锁（含已过期未清理的），按签出时间倒序
+func (l *LockService) List(ctx context.Context, filter LockFilter) ([]LockInfo, int64, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLockListLimit
+	}
+	if limit > maxLockListLimit {
+		limit = maxLockListLimit
+	}
+
+	now := l.now()
+	query := l.db.WithContext(ctx).Model(&database.ResourceLock{})
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.OwnerID != nil {
+		query = query.Where("owner_id = ?", *filter.OwnerID)
+	}
+	if filter.StaleOnly {
+		query = query.Where("expires_at <= ? OR stale_at < ?", now, now)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("count locks: %w", err)
+	}
+	var locks []database.ResourceLock
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(filter.Offset).Find(&locks).Error; err != nil {
+		return nil, 0, fmt.Errorf("list locks: %w", err)
+	}
+
+	infos := make([]LockInfo, 0, len(locks))
+	for _, lock := range locks {
+		infos = append(infos, lockInfo(lock, now))
+	}
+	return infos, total, nil
+}
+
+// Break 强制解除锁：持有者本人或拥有 lock:break 权限的角色
+func (l *LockService) Break(ctx context.Context, meta RequestMeta, id uint) (*database.ResourceLock, error) {
+	var lock database.ResourceLock
+	if err := l.db.WithContext(ctx).First(&lock, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLockNotFound
+		}
+		return nil, err
+	}
+	if !lockOwnedBy(lock, meta) && !l.canBreak(ctx, meta) {
+		return nil, ErrLockForbidden
+	}
+	if err := l.db.WithContext(ctx).Delete(&database.ResourceLock{}, lock.ID).Error; err != nil {
+		return nil, err
+	}
+	l.recordBreak(ctx, meta, "lock.break", lock)
+	return &lock, nil
+}
+
+// BreakStale 解除所有已过期或失去心跳的锁（需要 lock:break 权限）
+func (l *LockService) BreakStale(ctx context.Context, meta RequestMeta) ([]database.ResourceLock, error) {
+	if !l.canBreak(ctx, meta) {
+		return nil, ErrLockForbidden
+	}
+	now := l.now()
+	var locks []database.ResourceLock
+	if err := l.db.WithContext(ctx).Where("expires_at <= ? OR stale_at < ?", now, now).
+		Order("id ASC").Find(&locks).Error; err != nil {
+		return nil, fmt.Errorf("list locks: %w", err)
+	}
+	broken := make([]database.ResourceLock, 0)
+	for _, lock := range locks {
+		// 删除时再次按条件判断，避免误删刚收到心跳的锁
+		result := l.db.WithContext(ctx).
+			Where("id = ? AND (expires_at <= ? OR stale_at < ?)", lock.ID, now, now).
+			Delete(&database.ResourceLock{})
+		if result.Error != nil {
+			return broken, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if lock.ExpiresAt.After(now) {
+			l.recordBreak(ctx, meta, "lock.break_stale", lock)
+		}
+		broken = append(broken, lock)
+	}
+	return broken, nil
+}
+
+// canBreak 调用者的角色是否可以解除他人的锁
+func (l *LockService) canBreak(ctx context.Context, meta RequestMeta) bool {
+	return l.roles.HasPermission(ctx, meta.UserRole, database.PermLockBreak)
+}
+
+func (l *LockService) recordBreak(ctx context.Context, meta RequestMeta, action string, lock database.ResourceLock) {
+	l.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: lock.ResourceType,
+		ResourceID:   strconv.FormatInt(lock.ResourceID, 10),
+		Details: map[string]interface{}{
+			"lock_id":      lock.ID,
+			"owner_id":     lock.OwnerID,
+			"owner":        lock.Owner,
+			"heartbeat_at": lock.HeartbeatAt,
+			"expires_at":   lock.ExpiresAt,
+		},
+	})
+}
+
+// active 返回资源当前未过期的锁
+func (l *LockService) active(ctx context.Context, resourceType string, resourceID int64) (*database.ResourceLock, error) {
+	var lock database.ResourceLock
+	err := l.db.WithContext(ctx).
+		Where("resource_type = ? AND resource_id = ? AND expires_at > ?", resourceType, resourceID, l.now()).
+		First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func lockInfo(lock database.ResourceLock, now time.Time) LockInfo {
+	info := LockInfo{
+		ResourceLock: lock,
+		Expired:      !lock.ExpiresAt.After(now),
+		IdleSeconds:  int64(now.Sub(lock.HeartbeatAt) / time.Second),
+	}
+	info.Stale = info.Expired || now.Sub(lock.HeartbeatAt) > lockStaleAfter(lock)
+	return info
+}
+
+// lockStaleAfter 超过半个有效期未收到心跳即视为持有者已离开
+func lockStaleAfter(lock database.ResourceLock) time.Duration {
+	return time.Duration(lock.TTLSeconds) * time.Second / 2
+}
+
+// lockOwnedBy 登录用户按用户 ID 判断，未登录的请求按 x-user-id 判断
+func lockOwnedBy(lock database.ResourceLock, meta RequestMeta) bool {
+	if meta.UserIDNumeric != 0 {
+		return lock.OwnerID == meta.UserIDNumeric
+	}
+	return lock.OwnerID == 0 && meta.UserID != "" && lock.Owner == meta.UserID
+}
+
+func lockOwnerName(lock database.ResourceLock) string {
+	if lock.Owner != "" {
+		return lock.Owner
+	}
+	return "user " + strconv.FormatUint(uint64(lock.OwnerID), 10)
+}
+
+func lockTTL(seconds int) (time.Duration, error) {
+	if seconds == 0 {
+		return defaultLockTTL, nil
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if ttl < minLockTTL || ttl > maxLockTTL {
+		return 0, newValidationError("ttl_seconds must be between %d and %d", int(minLockTTL/time.Second), int(maxLockTTL/time.Second))
+	}
+	return ttl, nil
+}
+
+func validateLockResource(resourceType string, resourceID int64) error {
+	if resourceType != database.LockResourceDocument && resourceType != database.LockResourceNode {
+		return newValidationError("unsupported lock resource type: %s", resourceType)
+	}
+	if resourceID <= 0 {
+		return newValidationError("invalid %s id", resourceType)
+	}
+	return nil
+}
+
+// SetLockService 配置文档/节点锁，未配置时更新文档不检查锁
+func (s *Service) SetLockService(locks *LockService) {
+	s.locks = locks
+}
+
+// checkDocumentLocks 文档本身或其所在节点被他人锁定时拒绝写入
+func (s *Service) checkDocumentLocks(ctx context.Context, meta RequestMeta, docID int64) error {
+	if err := s.locks.Check(ctx, meta, database.LockResourceDocument, docID); err != nil {
+		return err
+	}
+	// 没有生效的节点锁时跳过绑定查询
+	active, err := s.locks.hasActive(ctx, database.LockResourceNode)
+	if err != nil || !active {
+		return err
+	}
+	bindings, err := s.ndr.GetDocumentBindings(ctx, toNDRMeta(meta), docID)
+	if err != nil {
+		return fmt.Errorf("get document bindings: %w", err)
+	}
+	nodeIDs := make([]int64, len(bindings))
+	for i, binding := range bindings {
+		nodeIDs[i] = binding.NodeID
+	}
+	return s.locks.Check(ctx, meta, database.LockResourceNode, nodeIDs...)
+}
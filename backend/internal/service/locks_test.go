@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+func setupLockService(t *testing.T) (*LockService, *time.Time) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.ResourceLock{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	locks := NewLockService(db)
+	locks.now = func() time.Time { return now }
+	return locks, &now
+}
+
+func TestLockServiceAcquireAndExpire(t *testing.T) {
+	locks, now := setupLockService(t)
+	ctx := context.Background()
+	alice := RequestMeta{UserID: "1", UserIDNumeric: 1}
+	bob := RequestMeta{UserID: "2", UserIDNumeric: 2}
+
+	lock, err := locks.Acquire(ctx, alice, database.LockResourceNode, 10, LockRequest{TTLSeconds: 60, Reason: "校对"})
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if lock.OwnerID != 1 || !lock.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected lock: %+v", lock)
+	}
+
+	_, err = locks.Acquire(ctx, bob, database.LockResourceNode, 10, LockRequest{})
+	var locked *ResourceLockedError
+	if !errors.As(err, &locked) || locked.Lock.OwnerID != 1 {
+		t.Fatalf("expected lock held by alice, got %v", err)
+	}
+	if err := locks.Check(ctx, bob, database.LockResourceNode, 9, 10); !errors.Is(err, ErrResourceLocked) {
+		t.Fatalf("expected check to fail for bob, got %v", err)
+	}
+	if err := locks.Check(ctx, alice, database.LockResourceNode, 10); err != nil {
+		t.Fatalf("owner should pass the check: %v", err)
+	}
+
+	// 持有者重复签出即续期
+	*now = now.Add(50 * time.Second)
+	lock, err = locks.Acquire(ctx, alice, database.LockResourceNode, 10, LockRequest{TTLSeconds: 60})
+	if err != nil || !lock.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("re-acquire should refresh the lock: %+v %v", lock, err)
+	}
+	if _, err := locks.Heartbeat(ctx, bob, database.LockResourceNode, 10); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected bob's heartbeat to fail, got %v", err)
+	}
+
+	// 过期后他人可以签出，原持有者的心跳失败
+	*now = now.Add(2 * time.Minute)
+	if _, err := locks.Heartbeat(ctx, alice, database.LockResourceNode, 10); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected heartbeat on expired lock to fail, got %v", err)
+	}
+	if _, err := locks.Acquire(ctx, bob, database.LockResourceNode, 10, LockRequest{}); err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	if err := locks.Release(ctx, alice, database.LockResourceNode, 10); !errors.Is(err, ErrResourceLocked) {
+		t.Fatalf("alice should not release bob's lock, got %v", err)
+	}
+	if err := locks.Release(ctx, bob, database.LockResourceNode, 10); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if current, err := locks.Get(ctx, database.LockResourceNode, 10); err != nil || current != nil {
+		t.Fatalf("expected no lock after release, got %+v %v", current, err)
+	}
+
+	if _, err := locks.Acquire(ctx, alice, "course", 10, LockRequest{}); err == nil {
+		t.Fatalf("expected validation error for unsupported resource type")
+	}
+	if _, err := locks.Acquire(ctx, alice, database.LockResourceDocument, 10, LockRequest{TTLSeconds: 5}); err == nil {
+		t.Fatalf("expected validation error for a too short ttl")
+	}
+}
+
+func TestLockServiceBreakStale(t *testing.T) {
+	locks, now := setupLockService(t)
+	ctx := context.Background()
+	alice := RequestMeta{UserID: "1", UserIDNumeric: 1}
+	bob := RequestMeta{UserID: "2", UserIDNumeric: 2}
+	admin := RequestMeta{UserID: "9", UserIDNumeric: 9, UserRole: database.RoleSuperAdmin}
+
+	if _, err := locks.Acquire(ctx, alice, database.LockResourceDocument, 1, LockRequest{TTLSeconds: 600}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	*now = now.Add(4 * time.Minute)
+	if _, err := locks.Acquire(ctx, bob, database.LockResourceDocument, 2, LockRequest{TTLSeconds: 600}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	// alice 已超过半个有效期没有心跳
+	*now = now.Add(2 * time.Minute)
+
+	infos, total, err := locks.List(ctx, LockFilter{StaleOnly: true})
+	if err != nil || total != 1 || infos[0].ResourceID != 1 || infos[0].Expired || infos[0].IdleSeconds != 360 {
+		t.Fatalf("unexpected stale locks: %+v total=%d err=%v", infos, total, err)
+	}
+
+	if _, err := locks.BreakStale(ctx, bob); !errors.Is(err, ErrLockForbidden) {
+		t.Fatalf("expected breaking stale locks to require lock:break, got %v", err)
+	}
+	broken, err := locks.BreakStale(ctx, admin)
+	if err != nil || len(broken) != 1 || broken[0].OwnerID != 1 {
+		t.Fatalf("unexpected broken locks: %+v %v", broken, err)
+	}
+	if _, err := locks.Heartbeat(ctx, alice, database.LockResourceDocument, 1); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected heartbeat after break to fail, got %v", err)
+	}
+
+	remaining, _, _ := locks.List(ctx, LockFilter{})
+	if len(remaining) != 1 || remaining[0].OwnerID != 2 {
+		t.Fatalf("expected bob's lock to remain, got %+v", remaining)
+	}
+	if page, total, err := locks.List(ctx, LockFilter{Offset: 1}); err != nil || total != 1 || len(page) != 0 {
+		t.Fatalf("expected empty second page with total 1, got %+v total=%d err=%v", page, total, err)
+	}
+	if _, err := locks.Break(ctx, alice, remaining[0].ID); !errors.Is(err, ErrLockForbidden) {
+		t.Fatalf("expected alice to be forbidden from breaking bob's lock, got %v", err)
+	}
+	if _, err := locks.Break(ctx, admin, remaining[0].ID); err != nil {
+		t.Fatalf("break: %v", err)
+	}
+}
+
+func TestUpdateDocumentHonoursLocks(t *testing.T) {
+	locks, _ := setupLockService(t)
+	ctx := context.Background()
+	alice := RequestMeta{UserID: "1", UserIDNumeric: 1}
+	bob := RequestMeta{UserID: "2", UserIDNumeric: 2}
+
+	fake := newFakeNDR()
+	fake.getDocResp = ndrclient.Document{ID: 5}
+	fake.docBindings[5] = map[int64]struct{}{10: {}}
+	svc := NewService(cache.NewNoop(), fake, nil)
+	svc.SetLockService(locks)
+	title := "新标题"
+
+	if _, err := locks.Acquire(ctx, alice, database.LockResourceNode, 10, LockRequest{}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := svc.UpdateDocument(ctx, bob, 5, DocumentUpdateRequest{Title: &title}); !errors.Is(err, ErrResourceLocked) {
+		t.Fatalf("expected node lock to block bob, got %v", err)
+	}
+	if _, err := svc.UpdateDocument(ctx, alice, 5, DocumentUpdateRequest{Title: &title}); err != nil {
+		t.Fatalf("lock owner should be able to update: %v", err)
+	}
+
+	if err := locks.Release(ctx, alice, database.LockResourceNode, 10); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := locks.Acquire(ctx, alice, database.LockResourceDocument, 5, LockRequest{}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := svc.UpdateDocument(ctx, bob, 5, DocumentUpdateRequest{Title: &title}); !errors.Is(err, ErrResourceLocked) {
+		t.Fatalf("expected document lock to block bob, got %v", err)
+	}
+	if len(fake.updatedDocs) != 1 {
+		t.Fatalf("expected only the owner's update to reach NDR, got %d", len(fake.updatedDocs))
+	}
+}
+
+func TestTriggerWorkflowRefusesLockedNode(t *testing.T) {
+	locks, _ := setupLockService(t)
+	ctx := context.Background()
+	if err := locks.db.AutoMigrate(&database.WorkflowDefinition{}, &database.WorkflowRun{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	def := database.WorkflowDefinition{WorkflowKey: "proofread_node", Name: "校对", WorkflowType: "node", Enabled: true}
+	if err := locks.db.Create(&def).Error; err != nil {
+		t.Fatalf("create workflow definition: %v", err)
+	}
+	workflows := NewWorkflowService(locks.db, nil, newFakeNDR(), "http://localhost")
+	workflows.SetLockService(locks)
+
+	proofreader := RequestMeta{UserID: "1", UserIDNumeric: 1}
+	operator := RequestMeta{UserID: "2", UserIDNumeric: 2}
+	if _, err := locks.Acquire(ctx, proofreader, database.LockResourceNode, 10, LockRequest{}); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	req := TriggerWorkflowRequest{NodeID: 10, WorkflowKey: "proofread_node"}
+	if _, err := workflows.TriggerWorkflow(ctx, operator, req); !errors.Is(err, ErrResourceLocked) {
+		t.Fatalf("expected locked node to be refused, got %v", err)
+	}
+	if _, err := workflows.TriggerWorkflow(ctx, proofreader, req); err != nil {
+		t.Fatalf("lock owner should be able to trigger: %v", err)
+	}
+	req.Force = true
+	if _, err := workflows.TriggerWorkflow(ctx, operator, req); !errors.Is(err, ErrLockForbidden) {
+		t.Fatalf("expected force without lock:break to be refused, got %v", err)
+	}
+	operator.UserRole = database.RoleSuperAdmin
+	if _, err := workflows.TriggerWorkflow(ctx, operator, req); err != nil {
+		t.Fatalf("forced trigger: %v", err)
+	}
+	var overrides int64
+	locks.db.Model(&database.AuditEvent{}).Where("action = ?", "lock.override").Count(&overrides)
+	if overrides != 1 {
+		t.Fatalf("expected forced trigger to be audited once, got %d", overrides)
+	}
+}
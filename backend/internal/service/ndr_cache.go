@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+)
+
+// NDR 读缓存的 key 设计：
+//   - 节点相关的 key（分类树、单个节点、根节点映射）都带上节点代数（generation），
+//     任何改动节点、文档绑定或树中文档的操作只需递增代数，旧 key 自然失效并随 TTL 过期；
+//   - 单个文档按文档代数 + ID 缓存，已知 ID 的变更直接删除对应 key；
+//     工作流等绕过本服务直接写 NDR 的场景无法得知具体文档，递增文档代数整体失效。
+const (
+	nodeGenerationKey     = "ndr:nodes:gen"
+	documentGenerationKey = "ndr:docs:gen"
+)
+
+// nodesGeneration 返回当前节点代数，不存在时初始化
+func nodesGeneration(ctx context.Context, p cache.Provider) string {
+	return cacheGeneration(ctx, p, nodeGenerationKey)
+}
+
+// documentsGeneration 返回当前文档代数，不存在时初始化
+func documentsGeneration(ctx context.Context, p cache.Provider) string {
+	return cacheGeneration(ctx, p, documentGenerationKey)
+}
+
+func cacheGeneration(ctx context.Context, p cache.Provider, key string) string {
+	if p == nil {
+		return ""
+	}
+	gen, ok, err := p.Get(ctx, key)
+	if err != nil {
+		log.Printf("[cache] get generation key=%s failed: %v", key, err)
+	}
+	if ok && gen != "" {
+		return gen
+	}
+	gen = newCacheGeneration()
+	if err := p.Set(ctx, key, gen, 0); err != nil {
+		log.Printf("[cache] init generation key=%s failed: %v", key, err)
+	}
+	return gen
+}
+
+func bumpCacheGeneration(ctx context.Context, p cache.Provider, key string) {
+	if p == nil {
+		return
+	}
+	if err := p.Set(ctx, key, newCacheGeneration(), 0); err != nil {
+		log.Printf("[cache] bump generation key=%s failed: %v", key, err)
+	}
+}
+
+// invalidateNodes 递增节点代数，使所有节点相关缓存失效
+func invalidateNodes(ctx context.Context, p cache.Provider) {
+	bumpCacheGeneration(ctx, p, nodeGenerationKey)
+}
+
+// invalidateDocuments 删除指定文档的缓存
+func invalidateDocuments(ctx context.Context, p cache.Provider, docIDs ...int64) {
+	if p == nil || len(docIDs) == 0 {
+		return
+	}
+	gen := documentsGeneration(ctx, p)
+	for _, id := range docIDs {
+		if err := p.Delete(ctx, documentCacheKey(gen, id)); err != nil {
+			log.Printf("[cache] delete document id=%d failed: %v", id, err)
+		}
+	}
+}
+
+// invalidateAllDocuments 递增文档代数，使所有单文档缓存失效
+func invalidateAllDocuments(ctx context.Context, p cache.Provider) {
+	bumpCacheGeneration(ctx, p, documentGenerationKey)
+}
+
+func categoryTreeCacheKey(gen string, includeDeleted bool) string {
+	return fmt.Sprintf("ndr:tree:%s:%t", gen, includeDeleted)
+}
+
+func nodeCacheKey(gen string, id int64, includeDeleted bool) string {
+	return fmt.Sprintf("ndr:node:%s:%d:%t", gen, id, includeDeleted)
+}
+
+func rootNodeCacheKey(gen string, id int64) string {
+	return fmt.Sprintf("ndr:root:%s:%d", gen, id)
+}
+
+func documentCacheKey(gen string, id int64) string {
+	return fmt.Sprintf("ndr:doc:%s:%d", gen, id)
+}
+
+// cacheGetJSON 读取并反序列化缓存；缓存出错时视为未命中
+func cacheGetJSON(ctx context.Context, p cache.Provider, key string, dest any) bool {
+	if p == nil {
+		return false
+	}
+	raw, ok, err := p.Get(ctx, key)
+	if err != nil {
+		log.Printf("[cache] get key=%s failed: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal([]byte(raw), dest); err != nil {
+		log.Printf("[cache] decode key=%s failed: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// cacheSetJSON 序列化并写入缓存，使用 Provider 的默认 TTL
+func cacheSetJSON(ctx context.Context, p cache.Provider, key string, value any) {
+	if p == nil {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("[cache] encode key=%s failed: %v", key, err)
+		return
+	}
+	if err := p.Set(ctx, key, string(raw), 0); err != nil {
+		log.Printf("[cache] set key=%s failed: %v", key, err)
+	}
+}
+
+func newCacheGeneration() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
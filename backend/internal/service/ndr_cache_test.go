@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+type countingNDR struct {
+	*fakeNDR
+	listNodesCalls   int
+	getNodeCalls     int
+	getDocumentCalls int
+}
+
+func (c *countingNDR) ListNodes(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) (ndrclient.NodesPage, error) {
+	c.listNodesCalls++
+	return c.fakeNDR.ListNodes(ctx, meta, params)
+}
+
+func (c *countingNDR) ListNodesAll(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) ([]ndrclient.Node, error) {
+	return ndrclient.Collect(ndrclient.StreamNodes(ctx, c, meta, params))
+}
+
+func (c *countingNDR) GetNode(ctx context.Context, meta ndrclient.RequestMeta, id int64, opts ndrclient.GetNodeOptions) (ndrclient.Node, error) {
+	c.getNodeCalls++
+	return c.fakeNDR.GetNode(ctx, meta, id, opts)
+}
+
+func (c *countingNDR) GetDocument(ctx context.Context, meta ndrclient.RequestMeta, id int64) (ndrclient.Document, error) {
+	c.getDocumentCalls++
+	return c.fakeNDR.GetDocument(ctx, meta, id)
+}
+
+func TestGetCategoryTreeReadsThroughCache(t *testing.T) {
+	fake := &countingNDR{fakeNDR: &fakeNDR{
+		listResponse: ndrclient.NodesPage{
+			Page: 1, Size: 100, Total: 1,
+			Items: []ndrclient.Node{{ID: 1, Name: "Root", Path: "root"}},
+		},
+		createResp: ndrclient.Node{ID: 2, Name: "New", Path: "new"},
+	}}
+	svc := NewService(cache.NewMemory(100, time.Minute), fake, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		tree, err := svc.GetCategoryTree(ctx, RequestMeta{}, false)
+		if err != nil {
+			t.Fatalf("GetCategoryTree() error = %v", err)
+		}
+		if len(tree) != 1 || tree[0].ID != 1 {
+			t.Fatalf("unexpected tree: %+v", tree)
+		}
+	}
+	if fake.listNodesCalls != 1 {
+		t.Fatalf("expected 1 ListNodes call, got %d", fake.listNodesCalls)
+	}
+
+	if _, err := svc.CreateCategory(ctx, RequestMeta{}, CategoryCreateRequest{Name: "New"}); err != nil {
+		t.Fatalf("CreateCategory() error = %v", err)
+	}
+	if _, err := svc.GetCategoryTree(ctx, RequestMeta{}, false); err != nil {
+		t.Fatalf("GetCategoryTree() error = %v", err)
+	}
+	if fake.listNodesCalls != 2 {
+		t.Fatalf("expected tree cache to be invalidated after create, got %d ListNodes calls", fake.listNodesCalls)
+	}
+}
+
+func TestGetDocumentReadsThroughCache(t *testing.T) {
+	fake := &countingNDR{fakeNDR: &fakeNDR{
+		getDocResp:    ndrclient.Document{ID: 7, Title: "Doc"},
+		updateDocResp: ndrclient.Document{ID: 7, Title: "Doc v2"},
+	}}
+	svc := NewService(cache.NewMemory(100, time.Minute), fake, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		doc, err := svc.GetDocument(ctx, RequestMeta{}, 7)
+		if err != nil {
+			t.Fatalf("GetDocument() error = %v", err)
+		}
+		if doc.Title != "Doc" {
+			t.Fatalf("unexpected document: %+v", doc)
+		}
+	}
+	if fake.getDocumentCalls != 1 {
+		t.Fatalf("expected 1 GetDocument call, got %d", fake.getDocumentCalls)
+	}
+
+	title := "Doc v2"
+	if _, err := svc.UpdateDocument(ctx, RequestMeta{}, 7, DocumentUpdateRequest{Title: &title}); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if _, err := svc.GetDocument(ctx, RequestMeta{}, 7); err != nil {
+		t.Fatalf("GetDocument() error = %v", err)
+	}
+	if fake.getDocumentCalls != 2 {
+		t.Fatalf("expected document cache to be invalidated after update, got %d calls", fake.getDocumentCalls)
+	}
+}
+
+func TestUpdateDocumentInvalidatesTree(t *testing.T) {
+	fake := &countingNDR{fakeNDR: &fakeNDR{
+		listResponse: ndrclient.NodesPage{
+			Page: 1, Size: 100, Total: 1,
+			Items: []ndrclient.Node{{ID: 1, Name: "Root", Path: "root"}},
+		},
+		updateDocResp: ndrclient.Document{ID: 7, Title: "Doc v2"},
+	}}
+	svc := NewService(cache.NewMemory(100, time.Minute), fake, nil)
+	ctx := context.Background()
+
+	if _, err := svc.GetCategoryTree(ctx, RequestMeta{}, false); err != nil {
+		t.Fatalf("GetCategoryTree() error = %v", err)
+	}
+	title := "Doc v2"
+	if _, err := svc.UpdateDocument(ctx, RequestMeta{}, 7, DocumentUpdateRequest{Title: &title}); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	if _, err := svc.GetCategoryTree(ctx, RequestMeta{}, false); err != nil {
+		t.Fatalf("GetCategoryTree() error = %v", err)
+	}
+	if fake.listNodesCalls != 2 {
+		t.Fatalf("expected tree cache to be invalidated after document update, got %d ListNodes calls", fake.listNodesCalls)
+	}
+}
+
+func TestWorkflowCallbackInvalidatesDocuments(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.WorkflowRun{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	fake := &countingNDR{fakeNDR: &fakeNDR{getDocResp: ndrclient.Document{ID: 7, Title: "Doc"}}}
+	provider := cache.NewMemory(100, time.Minute)
+	svc := NewService(provider, fake, nil)
+	workflows := NewWorkflowService(db, nil, fake, "")
+	workflows.SetCache(provider)
+	ctx := context.Background()
+
+	docID, nodeID := int64(7), int64(3)
+	docRun := database.WorkflowRun{WorkflowKey: "summarize", Status: WorkflowStatusRunning, DocumentID: &docID}
+	nodeRun := database.WorkflowRun{WorkflowKey: "generate_node_documents", Status: WorkflowStatusRunning, NodeID: &nodeID}
+	if err := db.Create(&docRun).Error; err != nil {
+		t.Fatalf("failed to create workflow run: %v", err)
+	}
+	if err := db.Create(&nodeRun).Error; err != nil {
+		t.Fatalf("failed to create workflow run: %v", err)
+	}
+
+	// 每次回调后 GetDocument 都应回源 NDR：文档工作流删除该文档，节点工作流递增文档代数
+	for i, run := range []database.WorkflowRun{docRun, nodeRun} {
+		if _, err := svc.GetDocument(ctx, RequestMeta{}, docID); err != nil {
+			t.Fatalf("GetDocument() error = %v", err)
+		}
+		if err := workflows.HandleCallback(ctx, run.ID, WorkflowCallbackRequest{Status: WorkflowStatusSuccess}); err != nil {
+			t.Fatalf("HandleCallback() error = %v", err)
+		}
+		if _, err := svc.GetDocument(ctx, RequestMeta{}, docID); err != nil {
+			t.Fatalf("GetDocument() error = %v", err)
+		}
+		if want := 2 + i; fake.getDocumentCalls != want {
+			t.Fatalf("run %d: expected %d GetDocument calls after callback, got %d", run.ID, want, fake.getDocumentCalls)
+		}
+	}
+}
+
+func TestGetRootNodeIDReadsThroughCache(t *testing.T) {
+	parentID := int64(1)
+	fake := &countingNDR{fakeNDR: &fakeNDR{
+		getNodes: map[int64]ndrclient.Node{
+			1: {ID: 1, Name: "Root"},
+			5: {ID: 5, Name: "Child", ParentID: &parentID},
+		},
+	}}
+	provider := cache.NewMemory(100, time.Minute)
+	perm := NewPermissionService(nil, nil, fake, provider)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		rootID, err := perm.getRootNodeID(ctx, 5)
+		if err != nil {
+			t.Fatalf("getRootNodeID() error = %v", err)
+		}
+		if rootID != 1 {
+			t.Fatalf("expected root 1, got %d", rootID)
+		}
+	}
+	if fake.getNodeCalls != 2 {
+		t.Fatalf("expected 2 GetNode calls for the first lookup only, got %d", fake.getNodeCalls)
+	}
+
+	invalidateNodes(ctx, provider)
+	if _, err := perm.getRootNodeID(ctx, 5); err != nil {
+		t.Fatalf("getRootNodeID() error = %v", err)
+	}
+	if fake.getNodeCalls != 4 {
+		t.Fatalf("expected lookup to hit NDR after invalidation, got %d GetNode calls", fake.getNodeCalls)
+	}
+}
@@ -3,7 +3,11 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
 	"gorm.io/gorm"
 )
@@ -13,17 +17,29 @@ type PermissionService struct {
 	db          *gorm.DB
 	userService *UserService
 	ndr         ndrclient.Client
+	cache       cache.Provider
+	roles       *RoleService
 }
 
 // NewPermissionService 创建权限服务
-func NewPermissionService(db *gorm.DB, userService *UserService, ndr ndrclient.Client) *PermissionService {
+func NewPermissionService(db *gorm.DB, userService *UserService, ndr ndrclient.Client, cache cache.Provider) *PermissionService {
 	return &PermissionService{
 		db:          db,
 		userService: userService,
 		ndr:         ndr,
+		cache:       cache,
+		roles:       NewRoleService(db),
 	}
 }
 
+// Roles 返回用于权限判断的角色服务（nil 接收者时回退到内置角色）
+func (s *PermissionService) Roles() *RoleService {
+	if s == nil {
+		return nil
+	}
+	return s.roles
+}
+
 // DocumentPermission 文档权限
 type DocumentPermission struct {
 	CanView          bool
@@ -48,48 +64,17 @@ type NodePermission struct {
 func (s *PermissionService) GetDocumentPermission(ctx context.Context, userID uint, role string, nodeID int64) (*DocumentPermission, error) {
 	perm := &DocumentPermission{}
 
-	// 超级管理员：全部权限
-	if role == "super_admin" {
-		perm.CanView = true
-		perm.CanCreate = true
-		perm.CanEdit = true
-		perm.CanDelete = true
-		perm.CanPurge = true
-		perm.CanRestoreVersion = true
-		return perm, nil
-	}
-
-	// 获取节点所属的根节点
-	rootNodeID, err := s.getRootNodeID(ctx, nodeID)
-	if err != nil {
+	granted, err := s.courseScopedPermissions(ctx, userID, role, nodeID)
+	if err != nil || granted == nil {
 		return perm, err
 	}
 
-	// 检查用户是否有该课程权限
-	hasPermission, err := s.userService.HasCoursePermission(userID, rootNodeID)
-	if err != nil || !hasPermission {
-		return perm, nil // 无权限，返回全 false
-	}
-
-	// 根据角色设置权限
-	switch role {
-	case "course_admin":
-		perm.CanView = true
-		perm.CanCreate = true
-		perm.CanEdit = true
-		perm.CanDelete = true
-		perm.CanPurge = false // 课程管理员不能永久删除
-		perm.CanRestoreVersion = true
-
-	case "proofreader":
-		perm.CanView = true
-		perm.CanCreate = false // ⚠️ 校对员不能创建文档
-		perm.CanEdit = true    // ✅ 可以编辑
-		perm.CanDelete = false
-		perm.CanPurge = false
-		perm.CanRestoreVersion = true // ✅ 可以恢复历史版本
-	}
-
+	perm.CanView = containsPermission(granted, database.PermDocumentView)
+	perm.CanCreate = containsPermission(granted, database.PermDocumentCreate)
+	perm.CanEdit = containsPermission(granted, database.PermDocumentEdit)
+	perm.CanDelete = containsPermission(granted, database.PermDocumentDelete)
+	perm.CanPurge = containsPermission(granted, database.PermDocumentPurge)
+	perm.CanRestoreVersion = containsPermission(granted, database.PermDocumentRestoreVersion)
 	return perm, nil
 }
 
@@ -97,73 +82,118 @@ func (s *PermissionService) GetDocumentPermission(ctx context.Context, userID ui
 func (s *PermissionService) GetNodePermission(ctx context.Context, userID uint, role string, nodeID int64) (*NodePermission, error) {
 	perm := &NodePermission{}
 
-	// 超级管理员：全部权限
-	if role == "super_admin" {
-		perm.CanView = true
-		perm.CanCreate = true
-		perm.CanEdit = true
-		perm.CanDelete = true
-		perm.CanPurge = true
-		perm.CanMove = true
-		return perm, nil
+	granted, err := s.courseScopedPermissions(ctx, userID, role, nodeID)
+	if err != nil || granted == nil {
+		return perm, err
+	}
+
+	perm.CanView = containsPermission(granted, database.PermNodeView)
+	perm.CanCreate = containsPermission(granted, database.PermNodeCreate)
+	perm.CanEdit = containsPermission(granted, database.PermNodeEdit)
+	perm.CanDelete = containsPermission(granted, database.PermNodeDelete)
+	perm.CanPurge = containsPermission(granted, database.PermNodePurge)
+	perm.CanMove = containsPermission(granted, database.PermNodeMove)
+	return perm, nil
+}
+
+// courseScopedPermissions 返回用户在节点所属课程内生效的权限
+// 全局角色拥有 courses:all 时不检查课程授权；否则按课程授权上的角色取权限，未获授权时返回 nil
+func (s *PermissionService) courseScopedPermissions(ctx context.Context, userID uint, role string, nodeID int64) ([]string, error) {
+	granted := s.roles.Permissions(ctx, role)
+	if containsPermission(granted, database.PermCoursesAll) {
+		return granted, nil
 	}
 
 	// 获取节点所属的根节点
 	rootNodeID, err := s.getRootNodeID(ctx, nodeID)
 	if err != nil {
-		return perm, err
+		return nil, err
 	}
 
-	// 检查用户是否有该课程权限
-	hasPermission, err := s.userService.HasCoursePermission(userID, rootNodeID)
-	if err != nil || !hasPermission {
-		return perm, nil // 无权限，返回全 false
+	// 解析用户在该课程中的角色
+	courseRole, err := s.userService.EffectiveCourseRole(ctx, userID, rootNodeID)
+	if err != nil || courseRole == "" {
+		return nil, nil // 无权限，返回全 false
 	}
+	return s.roles.Permissions(ctx, courseRole), nil
+}
 
-	// 根据角色设置权限
-	switch role {
-	case "course_admin":
-		perm.CanView = true
-		perm.CanCreate = true
-		perm.CanEdit = true
-		perm.CanDelete = true
-		perm.CanPurge = false // 课程管理员不能永久删除
-		perm.CanMove = true
+// HasPermissionInAnyCourse 检查用户的全局角色或任一课程角色是否拥有指定权限
+// 用于不定位到具体课程的粗粒度检查
+func (s *PermissionService) HasPermissionInAnyCourse(ctx context.Context, user *database.User, permission string) bool {
+	if s.Roles().HasPermission(ctx, user.Role, permission) {
+		return true
+	}
+	if s == nil || s.userService == nil {
+		return false
+	}
+	courses, err := s.userService.GetUserCourseRoles(user.ID)
+	if err != nil {
+		return false
+	}
+	for _, course := range courses {
+		if s.roles.HasPermission(ctx, course.Role, permission) {
+			return true
+		}
+	}
+	return false
+}
 
-	case "proofreader":
-		perm.CanView = true     // ✅ 可以查看
-		perm.CanCreate = false  // ❌ 不能创建节点
-		perm.CanEdit = false    // ❌ 不能编辑节点
-		perm.CanDelete = false  // ❌ 不能删除节点
-		perm.CanPurge = false
-		perm.CanMove = false
+// HasNodePermission 检查用户在节点所属课程内是否拥有指定权限
+func (s *PermissionService) HasNodePermission(ctx context.Context, userID uint, role string, nodeID int64, permission string) (bool, error) {
+	granted, err := s.courseScopedPermissions(ctx, userID, role, nodeID)
+	if err != nil {
+		return false, err
 	}
+	return containsPermission(granted, permission), nil
+}
 
-	return perm, nil
+// HasDocumentPermission 检查用户在文档绑定的每个课程内都拥有指定权限
+// 未绑定任何节点的文档不属于课程，按全局角色判断
+func (s *PermissionService) HasDocumentPermission(ctx context.Context, userID uint, role string, docID int64, permission string) (bool, error) {
+	bindings, err := s.ndr.GetDocumentBindings(ctx, toNDRMeta(RequestMeta{}), docID)
+	if err != nil {
+		return false, fmt.Errorf("get document bindings: %w", err)
+	}
+	if len(bindings) == 0 {
+		return s.roles.HasPermission(ctx, role, permission), nil
+	}
+	for _, binding := range bindings {
+		ok, err := s.HasNodePermission(ctx, userID, role, binding.NodeID, permission)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
 }
 
-// CanRestoreDocumentVersion 检查用户是否可以恢复文档版本
+// CanRestoreDocumentVersion 检查用户是否可以在文档所属课程内恢复文档版本
 func (s *PermissionService) CanRestoreDocumentVersion(ctx context.Context, userID uint, role string, docID int64) (bool, error) {
-	// 超级管理员和课程管理员：直接允许
-	if role == "super_admin" || role == "course_admin" {
-		return true, nil
-	}
+	return s.HasDocumentPermission(ctx, userID, role, docID, database.PermDocumentRestoreVersion)
+}
 
-	// 校对员：检查是否有该文档的编辑权限
-	if role == "proofreader" {
-		// 获取文档绑定的节点（暂时简化，假设文档有 node_id）
-		// 实际应该通过 NDR API 查询关系
-		// TODO: 实现完整的文档-节点关系查询
-		return true, nil // 暂时允许，后续完善
+// getRootNodeID 获取节点所属的根节点 ID（结果按节点代数缓存）
+func (s *PermissionService) getRootNodeID(ctx context.Context, nodeID int64) (int64, error) {
+	cacheKey := rootNodeCacheKey(nodesGeneration(ctx, s.cache), nodeID)
+	var rootID int64
+	if cacheGetJSON(ctx, s.cache, cacheKey, &rootID) {
+		return rootID, nil
 	}
 
-	return false, nil
+	rootID, err := s.lookupRootNodeID(ctx, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	cacheSetJSON(ctx, s.cache, cacheKey, rootID)
+	return rootID, nil
 }
 
-// getRootNodeID 获取节点所属的根节点 ID
-func (s *PermissionService) getRootNodeID(ctx context.Context, nodeID int64) (int64, error) {
+// lookupRootNodeID 沿父节点链向上查询 NDR，找到根节点 ID；
+// 包含已删除节点，回收站中的节点仍按原课程判断权限
+func (s *PermissionService) lookupRootNodeID(ctx context.Context, nodeID int64) (int64, error) {
+	opts := ndrclient.GetNodeOptions{IncludeDeleted: ptr(true)}
 	// 调用 NDR API 获取节点信息
-	node, err := s.ndr.GetNode(ctx, toNDRMeta(RequestMeta{}), nodeID, ndrclient.GetNodeOptions{})
+	node, err := s.ndr.GetNode(ctx, toNDRMeta(RequestMeta{}), nodeID, opts)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get node: %w", err)
 	}
@@ -178,7 +208,7 @@ func (s *PermissionService) getRootNodeID(ctx context.Context, nodeID int64) (in
 	// TODO: 优化为一次性获取完整路径
 	currentID := *node.ParentID
 	for {
-		parent, err := s.ndr.GetNode(ctx, toNDRMeta(RequestMeta{}), currentID, ndrclient.GetNodeOptions{})
+		parent, err := s.ndr.GetNode(ctx, toNDRMeta(RequestMeta{}), currentID, opts)
 		if err != nil {
 			return 0, fmt.Errorf("failed to get parent node: %w", err)
 		}
@@ -192,8 +222,8 @@ func (s *PermissionService) getRootNodeID(ctx context.Context, nodeID int64) (in
 
 // FilterUserCourses 过滤用户有权限的课程（根节点）
 func (s *PermissionService) FilterUserCourses(ctx context.Context, userID uint, role string, allCourses []int64) ([]int64, error) {
-	// 超级管理员可以看到所有课程
-	if role == "super_admin" {
+	// 拥有 courses:all 的角色可以看到所有课程
+	if s.roles.HasPermission(ctx, role, database.PermCoursesAll) {
 		return allCourses, nil
 	}
 
@@ -220,7 +250,92 @@ func (s *PermissionService) FilterUserCourses(ctx context.Context, userID uint,
 	return filtered, nil
 }
 
+// CoursesWithPermission 返回用户按课程授权上的生效角色拥有指定权限的课程
+func (s *PermissionService) CoursesWithPermission(ctx context.Context, userID uint, permission string) ([]int64, error) {
+	if s == nil || s.userService == nil {
+		return nil, nil
+	}
+	courses, err := s.userService.GetUserCourseRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	var granted []int64
+	for _, course := range courses {
+		if s.roles.HasPermission(ctx, course.Role, permission) {
+			granted = append(granted, course.RootNodeID)
+		}
+	}
+	return granted, nil
+}
+
+// documentRootNodeID 返回文档首个绑定节点所属的课程，未绑定时为 0
+func (s *PermissionService) documentRootNodeID(ctx context.Context, docID int64) (int64, error) {
+	bindings, err := s.ndr.GetDocumentBindings(ctx, toNDRMeta(RequestMeta{}), docID)
+	if err != nil {
+		return 0, fmt.Errorf("get document bindings: %w", err)
+	}
+	if len(bindings) == 0 {
+		return 0, nil
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].NodeID < bindings[j].NodeID })
+	rootID, err := s.getRootNodeID(ctx, bindings[0].NodeID)
+	if err != nil {
+		return 0, fmt.Errorf("resolve course: %w", err)
+	}
+	return rootID, nil
+}
+
+// AuditCourse 解析审计目标所属的课程，供 AuditService 写入 root_node_id；无法归属课程时返回 0
+func (s *PermissionService) AuditCourse(ctx context.Context, resourceType, resourceID string) int64 {
+	id, err := strconv.ParseInt(resourceID, 10, 64)
+	var nodeID int64
+	switch resourceType {
+	case AuditResourceCourse:
+		if err == nil {
+			return id
+		}
+	case AuditResourceCategory:
+		nodeID = id
+	case AuditResourceDocument:
+		if err == nil {
+			rootID, _ := s.documentRootNodeID(ctx, id)
+			return rootID
+		}
+	case AuditResourceWorkflowRun:
+		var run database.WorkflowRun
+		if err != nil || s.db.WithContext(ctx).Select("node_id", "document_id").First(&run, id).Error != nil {
+			return 0
+		}
+		if run.NodeID != nil {
+			nodeID = *run.NodeID
+		} else if run.DocumentID != nil {
+			rootID, _ := s.documentRootNodeID(ctx, *run.DocumentID)
+			return rootID
+		}
+	case AuditResourceWorkflowBatch:
+		s.db.WithContext(ctx).Model(&database.WorkflowBatch{}).Where("batch_id = ?", resourceID).Limit(1).Pluck("root_node_id", &nodeID)
+	case AuditResourceSyncBatch:
+		s.db.WithContext(ctx).Model(&database.SyncBatch{}).Where("batch_id = ?", resourceID).Limit(1).Pluck("root_node_id", &nodeID)
+	case AuditResourceMigrationBatch:
+		s.db.WithContext(ctx).Model(&database.MigrationBatch{}).Where("batch_id = ?", resourceID).Limit(1).Pluck("root_node_id", &nodeID)
+	}
+	if nodeID == 0 {
+		return 0
+	}
+	rootID, err := s.getRootNodeID(ctx, nodeID)
+	if err != nil {
+		return 0
+	}
+	return rootID
+}
+
 // HasCoursePermission 检查用户是否有某个课程的权限（包装 UserService 的方法）
 func (s *PermissionService) HasCoursePermission(userID uint, rootNodeID int64) (bool, error) {
 	return s.userService.HasCoursePermission(userID, rootNodeID)
 }
+
+// courseScoped 只有登录用户且角色没有 courses:all 时才需要按课程过滤
+func courseScoped(ctx context.Context, roles *RoleService, meta RequestMeta) bool {
+	return meta.UserRole != "" && meta.UserIDNumeric > 0 &&
+		!roles.HasPermission(ctx, meta.UserRole, database.PermCoursesAll)
+}
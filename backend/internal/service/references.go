@@ -0,0 +1,602 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// referenceRebuildPageSize 重建引用索引时每页从 NDR 拉取的文档数
+const referenceRebuildPageSize = 100
+
+// referenceRetryBatchSize 每轮重试的待改写引用数
+const referenceRetryBatchSize = 100
+
+// referenceSystemActor 维护引用方 metadata 时使用的系统身份：
+// 引用方可能在调用者无权编辑的课程中，改写不以调用者身份进行，审计中另记触发者
+const referenceSystemActor = "system"
+
+// ErrDocumentReferenced 文档仍被其他文档引用
+var ErrDocumentReferenced = errors.New("document is still referenced")
+
+// DocumentReferrer 引用了某文档的文档
+type DocumentReferrer struct {
+	DocumentID int64  `json:"document_id"`
+	RootNodeID int64  `json:"root_node_id"`
+	Title      string `json:"reference_title"` // 引用方保存的被引用文档标题
+}
+
+// DocumentReferencedError 删除被引用文档且要求阻止时返回
+type DocumentReferencedError struct {
+	DocumentID   int64
+	ReferencedBy []DocumentReferrer
+}
+
+func (e *DocumentReferencedError) Error() string {
+	return fmt.Sprintf("document %d is still referenced by %d document(s)", e.DocumentID, len(e.ReferencedBy))
+}
+
+func (e *DocumentReferencedError) Unwrap() error {
+	return ErrDocumentReferenced
+}
+
+// ReferenceIndex 文档引用的反向索引
+// 由 Service 的写操作增量维护，Rebuild 从 NDR 全量重建以修正直接在 NDR 上的修改
+type ReferenceIndex struct {
+	db          *gorm.DB
+	ndr         ndrclient.Client
+	permissions *PermissionService
+}
+
+// NewReferenceIndex 创建引用索引
+func NewReferenceIndex(db *gorm.DB, ndr ndrclient.Client, permissions *PermissionService) *ReferenceIndex {
+	return &ReferenceIndex{db: db, ndr: ndr, permissions: permissions}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (r *ReferenceIndex) Roles() *RoleService {
+	return r.permissions.Roles()
+}
+
+// ReferenceRebuildResult 全量重建的统计
+type ReferenceRebuildResult struct {
+	Scanned    int `json:"scanned"`    // NDR 中的文档数（含已删除）
+	References int `json:"references"` // 重建后的引用数
+	Dangling   int `json:"dangling"`   // 指向已删除或不存在文档的引用数
+}
+
+// DanglingReference 悬空引用
+type DanglingReference struct {
+	SourceDocumentID int64  `json:"source_document_id"`
+	TargetDocumentID int64  `json:"target_document_id"`
+	Title            string `json:"title"`
+	TargetStatus     string `json:"target_status"`
+}
+
+// DanglingReferenceCourse 按课程汇总的悬空引用
+type DanglingReferenceCourse struct {
+	RootNodeID int64               `json:"root_node_id"`
+	Total      int                 `json:"total"`
+	References []DanglingReference `json:"references"`
+}
+
+// SyncDocument 按文档当前的 metadata.references 重写其作为引用方的索引
+// 已删除的文档不再作为引用方出现在索引中
+func (r *ReferenceIndex) SyncDocument(ctx context.Context, doc ndrclient.Document) error {
+	refs := parseDocumentReferences(doc.Metadata)
+	links := make([]database.DocumentReferenceLink, 0, len(refs))
+	if doc.DeletedAt == nil && len(refs) > 0 {
+		rootID, err := r.documentRootNodeID(ctx, doc.ID)
+		if err != nil {
+			return err
+		}
+		seen := make(map[int64]bool, len(refs))
+		for _, ref := range refs {
+			if ref.DocumentID == 0 || seen[ref.DocumentID] {
+				continue
+			}
+			seen[ref.DocumentID] = true
+			links = append(links, database.DocumentReferenceLink{
+				SourceDocumentID: doc.ID,
+				TargetDocumentID: ref.DocumentID,
+				Title:            ref.Title,
+				SourceRootNodeID: rootID,
+				TargetStatus:     database.ReferenceTargetActive,
+			})
+		}
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 待改写标记只在改写成功后清除，引用方的普通编辑不影响
+		var pending []int64
+		if err := tx.Model(&database.DocumentReferenceLink{}).
+			Where("source_document_id = ? AND pending_update = ?", doc.ID, true).
+			Pluck("target_document_id", &pending).Error; err != nil {
+			return fmt.Errorf("load pending references: %w", err)
+		}
+		pendingTargets := make(map[int64]bool, len(pending))
+		for _, id := range pending {
+			pendingTargets[id] = true
+		}
+
+		// 被引用文档的状态与引用方无关，沿用已知的状态
+		var known []database.DocumentReferenceLink
+		if len(links) > 0 {
+			targets := make([]int64, len(links))
+			for i, link := range links {
+				targets[i] = link.TargetDocumentID
+			}
+			if err := tx.Select("target_document_id", "target_status").
+				Where("target_document_id IN ?", targets).Find(&known).Error; err != nil {
+				return fmt.Errorf("load reference status: %w", err)
+			}
+		}
+		status := make(map[int64]string, len(known))
+		for _, link := range known {
+			status[link.TargetDocumentID] = link.TargetStatus
+		}
+
+		if err := tx.Where("source_document_id = ?", doc.ID).Delete(&database.DocumentReferenceLink{}).Error; err != nil {
+			return fmt.Errorf("delete references: %w", err)
+		}
+		for i := range links {
+			if s, ok := status[links[i].TargetDocumentID]; ok {
+				links[i].TargetStatus = s
+			}
+			links[i].PendingUpdate = pendingTargets[links[i].TargetDocumentID]
+		}
+		if len(links) > 0 {
+			if err := tx.Create(&links).Error; err != nil {
+				return fmt.Errorf("save references: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveSource 移除文档作为引用方的全部索引
+func (r *ReferenceIndex) RemoveSource(ctx context.Context, docID int64) error {
+	return r.db.WithContext(ctx).Where("source_document_id = ?", docID).Delete(&database.DocumentReferenceLink{}).Error
+}
+
+// RemoveTarget 移除指向某文档的全部索引
+func (r *ReferenceIndex) RemoveTarget(ctx context.Context, docID int64) error {
+	return r.db.WithContext(ctx).Where("target_document_id = ?", docID).Delete(&database.DocumentReferenceLink{}).Error
+}
+
+// SetTargetStatus 更新指向某文档的引用的状态
+func (r *ReferenceIndex) SetTargetStatus(ctx context.Context, docID int64, status string) error {
+	return r.db.WithContext(ctx).Model(&database.DocumentReferenceLink{}).
+		Where("target_document_id = ?", docID).Update("target_status", status).Error
+}
+
+// MarkPending 标记引用方 metadata 待改写，由 RetryPendingReferenceUpdates 重试
+func (r *ReferenceIndex) MarkPending(ctx context.Context, sourceID, targetID int64) error {
+	return r.db.WithContext(ctx).Model(&database.DocumentReferenceLink{}).
+		Where("source_document_id = ? AND target_document_id = ?", sourceID, targetID).
+		Update("pending_update", true).Error
+}
+
+// ClearPending 引用方 metadata 改写成功后清除待改写标记
+func (r *ReferenceIndex) ClearPending(ctx context.Context, sourceID, targetID int64) error {
+	return r.db.WithContext(ctx).Model(&database.DocumentReferenceLink{}).
+		Where("source_document_id = ? AND target_document_id = ? AND pending_update = ?", sourceID, targetID, true).
+		Update("pending_update", false).Error
+}
+
+// PendingUpdates 返回待改写的引用，按更新时间先后排列
+func (r *ReferenceIndex) PendingUpdates(ctx context.Context, limit int) ([]database.DocumentReferenceLink, error) {
+	var links []database.DocumentReferenceLink
+	if err := r.db.WithContext(ctx).Where("pending_update = ?", true).
+		Order("updated_at, id").Limit(limit).Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("list pending references: %w", err)
+	}
+	return links, nil
+}
+
+// Referrers 返回引用了指定文档的文档
+func (r *ReferenceIndex) Referrers(ctx context.Context, docID int64) ([]DocumentReferrer, error) {
+	var links []database.DocumentReferenceLink
+	if err := r.db.WithContext(ctx).Where("target_document_id = ?", docID).
+		Order("source_document_id").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("list referrers: %w", err)
+	}
+	referrers := make([]DocumentReferrer, len(links))
+	for i, link := range links {
+		referrers[i] = DocumentReferrer{DocumentID: link.SourceDocumentID, RootNodeID: link.SourceRootNodeID, Title: link.Title}
+	}
+	return referrers, nil
+}
+
+// DanglingReferences 按课程汇总悬空引用；rootNodeID > 0 时只返回该课程
+// 课程受限的用户只能看到其课程内的引用，课程范围经 PermissionService.FilterUserCourses 过滤
+func (r *ReferenceIndex) DanglingReferences(ctx context.Context, meta RequestMeta, rootNodeID int64) ([]DanglingReferenceCourse, error) {
+	query := r.db.WithContext(ctx).Where("target_status <> ?", database.ReferenceTargetActive)
+	if rootNodeID > 0 {
+		query = query.Where("source_root_node_id = ?", rootNodeID)
+	}
+	if courseScoped(ctx, r.permissions.Roles(), meta) {
+		var roots []int64
+		if err := query.Session(&gorm.Session{}).Model(&database.DocumentReferenceLink{}).
+			Distinct().Pluck("source_root_node_id", &roots).Error; err != nil {
+			return nil, fmt.Errorf("list courses: %w", err)
+		}
+		courses, err := r.permissions.FilterUserCourses(ctx, meta.UserIDNumeric, meta.UserRole, roots)
+		if err != nil {
+			return nil, err
+		}
+		if len(courses) == 0 {
+			return []DanglingReferenceCourse{}, nil
+		}
+		query = query.Where("source_root_node_id IN ?", courses)
+	}
+	var links []database.DocumentReferenceLink
+	if err := query.Order("source_root_node_id, source_document_id, target_document_id").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("list dangling references: %w", err)
+	}
+
+	report := []DanglingReferenceCourse{}
+	for _, link := range links {
+		if len(report) == 0 || report[len(report)-1].RootNodeID != link.SourceRootNodeID {
+			report = append(report, DanglingReferenceCourse{RootNodeID: link.SourceRootNodeID})
+		}
+		course := &report[len(report)-1]
+		course.Total++
+		course.References = append(course.References, DanglingReference{
+			SourceDocumentID: link.SourceDocumentID,
+			TargetDocumentID: link.TargetDocumentID,
+			Title:            link.Title,
+			TargetStatus:     link.TargetStatus,
+		})
+	}
+	return report, nil
+}
+
+// Rebuild 从 NDR 全量重建引用索引，并按被引用文档的实际状态标记悬空引用
+func (r *ReferenceIndex) Rebuild(ctx context.Context) (*ReferenceRebuildResult, error) {
+	result := &ReferenceRebuildResult{}
+	status := make(map[int64]string)
+	var sources []ndrclient.Document
+	query := url.Values{}
+	query.Set("size", strconv.Itoa(referenceRebuildPageSize))
+	query.Set("include_deleted", "true")
+	for doc, err := range r.ndr.StreamDocuments(ctx, toNDRMeta(RequestMeta{}), query) {
+		if err != nil {
+			return nil, fmt.Errorf("list documents: %w", err)
+		}
+		result.Scanned++
+		if doc.DeletedAt != nil {
+			status[doc.ID] = database.ReferenceTargetDeleted
+			continue
+		}
+		status[doc.ID] = database.ReferenceTargetActive
+		if len(parseDocumentReferences(doc.Metadata)) > 0 {
+			sources = append(sources, doc)
+		}
+	}
+
+	// 全量重建不丢弃尚未完成的改写
+	type referencePair struct{ source, target int64 }
+	var pendingLinks []database.DocumentReferenceLink
+	if err := r.db.WithContext(ctx).Select("source_document_id", "target_document_id").
+		Where("pending_update = ?", true).Find(&pendingLinks).Error; err != nil {
+		return nil, fmt.Errorf("load pending references: %w", err)
+	}
+	pending := make(map[referencePair]bool, len(pendingLinks))
+	for _, link := range pendingLinks {
+		pending[referencePair{link.SourceDocumentID, link.TargetDocumentID}] = true
+	}
+
+	var links []database.DocumentReferenceLink
+	for _, doc := range sources {
+		rootID, err := r.documentRootNodeID(ctx, doc.ID)
+		if err != nil {
+			log.Printf("[references] resolve course of document id=%d failed: %v", doc.ID, err)
+		}
+		seen := make(map[int64]bool)
+		for _, ref := range parseDocumentReferences(doc.Metadata) {
+			if ref.DocumentID == 0 || seen[ref.DocumentID] {
+				continue
+			}
+			seen[ref.DocumentID] = true
+			targetStatus, ok := status[ref.DocumentID]
+			if !ok {
+				targetStatus = database.ReferenceTargetMissing
+			}
+			if targetStatus != database.ReferenceTargetActive {
+				result.Dangling++
+			}
+			links = append(links, database.DocumentReferenceLink{
+				SourceDocumentID: doc.ID,
+				TargetDocumentID: ref.DocumentID,
+				Title:            ref.Title,
+				SourceRootNodeID: rootID,
+				TargetStatus:     targetStatus,
+				PendingUpdate:    pending[referencePair{doc.ID, ref.DocumentID}],
+			})
+		}
+	}
+	result.References = len(links)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&database.DocumentReferenceLink{}).Error; err != nil {
+			return fmt.Errorf("clear references: %w", err)
+		}
+		if len(links) > 0 {
+			if err := tx.CreateInBatches(links, 500).Error; err != nil {
+				return fmt.Errorf("save references: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// documentRootNodeID 返回文档首个绑定节点所属的课程，未绑定时为 0
+func (r *ReferenceIndex) documentRootNodeID(ctx context.Context, docID int64) (int64, error) {
+	return r.permissions.documentRootNodeID(ctx, docID)
+}
+
+// DocumentRemovalOptions 删除/彻底删除文档时的引用检查选项
+type DocumentRemovalOptions struct {
+	// BlockIfReferenced 文档仍被引用时拒绝删除（返回 DocumentReferencedError），默认只给出警告
+	BlockIfReferenced bool
+}
+
+// DocumentRemovalResult 删除结果；ReferencedBy 非空时调用方应提示用户存在引用
+type DocumentRemovalResult struct {
+	ReferencedBy []DocumentReferrer `json:"referenced_by,omitempty"`
+	// PendingReferenceUpdates 彻底删除后未能移除引用、已排队重试的引用方文档
+	PendingReferenceUpdates []int64 `json:"pending_reference_updates,omitempty"`
+}
+
+// checkReferences 删除前检查文档是否仍被引用
+func (s *Service) checkReferences(ctx context.Context, docID int64, opts DocumentRemovalOptions) (*DocumentRemovalResult, error) {
+	result := &DocumentRemovalResult{}
+	if s.refs == nil {
+		return result, nil
+	}
+	referrers, err := s.refs.Referrers(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if len(referrers) > 0 && opts.BlockIfReferenced {
+		return nil, &DocumentReferencedError{DocumentID: docID, ReferencedBy: referrers}
+	}
+	result.ReferencedBy = referrers
+	return result, nil
+}
+
+// syncReferences 文档写入后刷新其作为引用方的索引
+func (s *Service) syncReferences(ctx context.Context, doc ndrclient.Document) {
+	if s.refs == nil {
+		return
+	}
+	if err := s.refs.SyncDocument(ctx, doc); err != nil {
+		log.Printf("[references] sync document id=%d failed: %v", doc.ID, err)
+	}
+}
+
+// markDocumentRemoved 文档被删除后：指向它的引用标记为悬空，它自身的引用移出索引
+func (s *Service) markDocumentRemoved(ctx context.Context, docID int64, status string) {
+	if s.refs == nil {
+		return
+	}
+	if err := s.refs.SetTargetStatus(ctx, docID, status); err != nil {
+		log.Printf("[references] mark document id=%d %s failed: %v", docID, status, err)
+	}
+	if err := s.refs.RemoveSource(ctx, docID); err != nil {
+		log.Printf("[references] remove references of document id=%d failed: %v", docID, err)
+	}
+}
+
+// refreshReferenceTitles 文档改名后，更新引用方 metadata.references 中保存的标题
+// 改写失败的引用方标记为待改写，由 RetryPendingReferenceUpdates 重试
+func (s *Service) refreshReferenceTitles(ctx context.Context, meta RequestMeta, docID int64, title string) {
+	if s.refs == nil {
+		return
+	}
+	referrers, err := s.refs.Referrers(ctx, docID)
+	if err != nil {
+		log.Printf("[references] list referrers of document id=%d failed: %v", docID, err)
+		return
+	}
+	for _, referrer := range referrers {
+		if referrer.Title == title {
+			continue
+		}
+		if err := s.updateReferenceTitle(ctx, meta, referrer.DocumentID, docID, title); err != nil {
+			s.queueReferenceUpdate(ctx, referrer.DocumentID, docID, err)
+		}
+	}
+}
+
+// cascadeReferenceRemoval 文档被彻底删除后，从引用方的 metadata.references 中移除对它的引用
+// 返回改写失败、已排队重试的引用方
+func (s *Service) cascadeReferenceRemoval(ctx context.Context, meta RequestMeta, docID int64, referrers []DocumentReferrer) []int64 {
+	var queued []int64
+	for _, referrer := range referrers {
+		if err := s.removeReference(ctx, meta, referrer.DocumentID, docID); err != nil {
+			s.queueReferenceUpdate(ctx, referrer.DocumentID, docID, err)
+			queued = append(queued, referrer.DocumentID)
+		}
+	}
+	return queued
+}
+
+// queueReferenceUpdate 记录改写失败并把引用标记为待改写
+func (s *Service) queueReferenceUpdate(ctx context.Context, sourceID, targetID int64, cause error) {
+	log.Printf("[references] update reference to %d in document id=%d failed, queued for retry: %v", targetID, sourceID, cause)
+	if s.refs == nil {
+		return
+	}
+	if err := s.refs.MarkPending(ctx, sourceID, targetID); err != nil {
+		log.Printf("[references] queue reference to %d in document id=%d failed: %v", targetID, sourceID, err)
+	}
+}
+
+// RetryPendingReferenceUpdates 重试待改写的引用：被引用文档已彻底删除的移除引用，其余同步为当前标题
+// 返回本轮完成的条数
+func (s *Service) RetryPendingReferenceUpdates(ctx context.Context) (int, error) {
+	if s.refs == nil {
+		return 0, nil
+	}
+	links, err := s.refs.PendingUpdates(ctx, referenceRetryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	for _, link := range links {
+		if link.TargetStatus == database.ReferenceTargetMissing {
+			err = s.removeReference(ctx, RequestMeta{}, link.SourceDocumentID, link.TargetDocumentID)
+		} else {
+			var target ndrclient.Document
+			target, err = s.ndr.GetDocument(ctx, toNDRMeta(RequestMeta{}), link.TargetDocumentID)
+			if err == nil {
+				err = s.updateReferenceTitle(ctx, RequestMeta{}, link.SourceDocumentID, target.ID, target.Title)
+			}
+		}
+		if err != nil {
+			log.Printf("[references] retry reference to %d in document id=%d failed: %v",
+				link.TargetDocumentID, link.SourceDocumentID, err)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+// StartReferenceRepair 定期重试待改写的引用，ctx 取消后停止
+func (s *Service) StartReferenceRepair(ctx context.Context, interval time.Duration) {
+	if s.refs == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			done, err := s.RetryPendingReferenceUpdates(ctx)
+			if err != nil {
+				log.Printf("[references] retry pending references failed: %v", err)
+			} else if done > 0 {
+				log.Printf("[references] retried pending references: done=%d", done)
+			}
+		}
+	}()
+}
+
+func (s *Service) updateReferenceTitle(ctx context.Context, trigger RequestMeta, sourceID, targetID int64, title string) error {
+	return s.rewriteReferences(ctx, trigger, "title", sourceID, targetID, func(ref map[string]any) map[string]any {
+		ref["title"] = title
+		return ref
+	})
+}
+
+func (s *Service) removeReference(ctx context.Context, trigger RequestMeta, sourceID, targetID int64) error {
+	return s.rewriteReferences(ctx, trigger, "remove", sourceID, targetID, func(map[string]any) map[string]any {
+		return nil
+	})
+}
+
+// rewriteReferences 以系统身份改写引用方 metadata.references 中指向 targetID 的条目，edit 返回 nil 时删除该条目
+// 引用方被他人锁定时返回 ResourceLockedError；每次改写记录 document.reference_update 审计，附带触发者
+func (s *Service) rewriteReferences(ctx context.Context, trigger RequestMeta, change string, sourceID, targetID int64, edit func(map[string]any) map[string]any) error {
+	system := RequestMeta{UserID: referenceSystemActor, RequestID: trigger.RequestID}
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(system), sourceID)
+	if err != nil {
+		var ndrErr *ndrclient.Error
+		if errors.As(err, &ndrErr) && ndrErr.StatusCode == http.StatusNotFound {
+			// 引用方已不存在，没有可改写的内容
+			if s.refs != nil {
+				return s.refs.RemoveSource(ctx, sourceID)
+			}
+			return nil
+		}
+		return fmt.Errorf("get document: %w", err)
+	}
+
+	if metadata, changed := editReferences(doc.Metadata, targetID, edit); changed {
+		if err := ValidateDocumentMetadata(metadata); err != nil {
+			return fmt.Errorf("invalid metadata: %w", err)
+		}
+		if err := s.checkDocumentLocks(ctx, system, sourceID); err != nil {
+			return err
+		}
+		doc, err = s.ndr.UpdateDocument(ctx, toNDRMeta(system), sourceID, ndrclient.DocumentUpdate{Metadata: metadata})
+		if err != nil {
+			return fmt.Errorf("update document: %w", err)
+		}
+		s.invalidateDocumentWrite(ctx, sourceID)
+		s.recordAudit(ctx, system, "document.reference_update", AuditResourceDocument, sourceID, map[string]interface{}{
+			"target_document_id": targetID,
+			"change":             change,
+			"triggered_by":       trigger.UserID,
+		})
+	}
+	if s.refs == nil {
+		return nil
+	}
+	if err := s.refs.SyncDocument(ctx, doc); err != nil {
+		return err
+	}
+	return s.refs.ClearPending(ctx, sourceID, targetID)
+}
+
+// editReferences 返回改写后的 metadata；没有指向 targetID 的条目时 changed 为 false
+func editReferences(metadata map[string]any, targetID int64, edit func(map[string]any) map[string]any) (map[string]any, bool) {
+	refsArray, ok := metadata["references"].([]any)
+	if !ok {
+		return metadata, false
+	}
+	var newRefs []any
+	changed := false
+	for _, refRaw := range refsArray {
+		refMap, ok := refRaw.(map[string]any)
+		if !ok || referenceDocumentID(refMap) != targetID {
+			newRefs = append(newRefs, refRaw)
+			continue
+		}
+		changed = true
+		if edited := edit(maps.Clone(refMap)); edited != nil {
+			newRefs = append(newRefs, edited)
+		}
+	}
+	if !changed {
+		return metadata, false
+	}
+	edited := maps.Clone(metadata)
+	if len(newRefs) == 0 {
+		// JSON Merge Patch：置为 nil 以删除字段
+		edited["references"] = nil
+	} else {
+		edited["references"] = newRefs
+	}
+	return edited, true
+}
+
+func referenceDocumentID(ref map[string]any) int64 {
+	refs := parseDocumentReferences(map[string]any{"references": []any{ref}})
+	if len(refs) == 0 {
+		return 0
+	}
+	return refs[0].DocumentID
+}
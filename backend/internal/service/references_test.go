@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// UpdateDocument 把标题与 metadata 写回内存中的文档（JSON Merge Patch 语义）
+func (f *searchNDR) UpdateDocument(_ context.Context, _ ndrclient.RequestMeta, id int64, body ndrclient.DocumentUpdate) (ndrclient.Document, error) {
+	f.updatedDocs = append(f.updatedDocs, struct {
+		ID   int64
+		Body ndrclient.DocumentUpdate
+	}{ID: id, Body: body})
+	if err := f.failUpdates[id]; err != nil {
+		return ndrclient.Document{}, err
+	}
+	doc := f.docs[id]
+	if body.Title != nil {
+		doc.Title = *body.Title
+	}
+	for key, value := range body.Metadata {
+		if value == nil {
+			delete(doc.Metadata, key)
+			continue
+		}
+		doc.Metadata[key] = value
+	}
+	f.docs[id] = doc
+	return doc, nil
+}
+
+func (f *searchNDR) DeleteDocument(_ context.Context, _ ndrclient.RequestMeta, id int64) error {
+	f.deletedDocIDs = append(f.deletedDocIDs, id)
+	doc := f.docs[id]
+	now := time.Now()
+	doc.DeletedAt = &now
+	f.docs[id] = doc
+	return nil
+}
+
+func (f *searchNDR) PurgeDocument(_ context.Context, _ ndrclient.RequestMeta, id int64) error {
+	f.purgedDocIDs = append(f.purgedDocIDs, id)
+	delete(f.docs, id)
+	return nil
+}
+
+func referencesTo(ids ...int64) map[string]any {
+	refs := make([]any, len(ids))
+	for i, id := range ids {
+		refs[i] = map[string]any{"document_id": float64(id), "title": "目标", "added_at": "2026-01-01T00:00:00Z"}
+	}
+	return map[string]any{"references": refs}
+}
+
+func TestReferenceIndex_Lifecycle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.CoursePermission{}, &database.Role{},
+		&database.DocumentReferenceLink{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	root1, root2 := int64(1), int64(2)
+	ndr := &searchNDR{fakeNDR: newFakeNDR(), docs: map[int64]ndrclient.Document{
+		10: {ID: 10, Title: "目标", Metadata: map[string]any{}},
+		11: {ID: 11, Title: "引用方A", Metadata: referencesTo(10)},
+		12: {ID: 12, Title: "引用方B", Metadata: referencesTo(10, 99)},
+	}}
+	ndr.getNodes[1] = ndrclient.Node{ID: 1}
+	ndr.getNodes[2] = ndrclient.Node{ID: 2}
+	ndr.getNodes[5] = ndrclient.Node{ID: 5, ParentID: &root1}
+	ndr.getNodes[6] = ndrclient.Node{ID: 6, ParentID: &root2}
+	ndr.BindDocument(context.Background(), ndrclient.RequestMeta{}, 5, 11)
+	ndr.BindDocument(context.Background(), ndrclient.RequestMeta{}, 6, 12)
+
+	refs := NewReferenceIndex(db, ndr, NewPermissionService(db, NewUserService(db), ndr, cache.NewNoop()))
+	svc := NewService(cache.NewNoop(), ndr, nil)
+	svc.SetReferenceIndex(refs)
+	ctx := context.Background()
+
+	result, err := refs.Rebuild(ctx)
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if result.References != 3 || result.Dangling != 1 {
+		t.Fatalf("unexpected rebuild result: %+v", result)
+	}
+
+	referencing, err := svc.GetReferencingDocuments(ctx, RequestMeta{}, 10, nil)
+	if err != nil || len(referencing) != 2 {
+		t.Fatalf("expected 2 referencing documents, got %d err=%v", len(referencing), err)
+	}
+
+	// 改名后刷新引用方保存的标题
+	title := "新标题"
+	if _, err := svc.UpdateDocument(ctx, RequestMeta{}, 10, DocumentUpdateRequest{Title: &title}); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	for _, id := range []int64{11, 12} {
+		if got := parseDocumentReferences(ndr.docs[id].Metadata)[0].Title; got != title {
+			t.Fatalf("expected title in document %d to be refreshed, got %q", id, got)
+		}
+	}
+	referrers, err := refs.Referrers(ctx, 10)
+	if err != nil || len(referrers) != 2 || referrers[0].Title != title {
+		t.Fatalf("unexpected referrers: %+v err=%v", referrers, err)
+	}
+
+	// 要求阻止时不删除
+	_, err = svc.DeleteDocumentWithOptions(ctx, RequestMeta{}, 10, DocumentRemovalOptions{BlockIfReferenced: true})
+	var refErr *DocumentReferencedError
+	if !errors.As(err, &refErr) || len(refErr.ReferencedBy) != 2 || len(ndr.deletedDocIDs) != 0 {
+		t.Fatalf("expected DocumentReferencedError, got %v", err)
+	}
+
+	// 默认删除并给出警告，引用被标记为悬空
+	removal, err := svc.DeleteDocumentWithOptions(ctx, RequestMeta{}, 10, DocumentRemovalOptions{})
+	if err != nil || len(removal.ReferencedBy) != 2 {
+		t.Fatalf("expected warning with 2 referrers, got %+v err=%v", removal, err)
+	}
+	report, err := refs.DanglingReferences(ctx, RequestMeta{UserIDNumeric: 1, UserRole: database.RoleSuperAdmin}, 0)
+	if err != nil || len(report) != 2 || report[0].Total != 1 || report[1].Total != 2 {
+		t.Fatalf("unexpected dangling report: %+v err=%v", report, err)
+	}
+	if err := db.Create(&database.CoursePermission{UserID: 7, RootNodeID: 2}).Error; err != nil {
+		t.Fatalf("failed to grant course: %v", err)
+	}
+	report, err = refs.DanglingReferences(ctx, RequestMeta{UserIDNumeric: 7, UserRole: database.RoleProofreader}, 0)
+	if err != nil || len(report) != 1 || report[0].RootNodeID != 2 || report[0].References[1].TargetStatus != database.ReferenceTargetMissing {
+		t.Fatalf("expected only course 2 to be reported, got %+v err=%v", report, err)
+	}
+
+	// 彻底删除后从引用方移除引用
+	if _, err := svc.PurgeDocumentWithOptions(ctx, RequestMeta{}, 10, DocumentRemovalOptions{}); err != nil {
+		t.Fatalf("PurgeDocumentWithOptions() error = %v", err)
+	}
+	if _, ok := ndr.docs[11].Metadata["references"]; ok {
+		t.Fatalf("expected references of document 11 to be removed, got %v", ndr.docs[11].Metadata)
+	}
+	if remaining := parseDocumentReferences(ndr.docs[12].Metadata); len(remaining) != 1 || remaining[0].DocumentID != 99 {
+		t.Fatalf("expected only the reference to 99 to remain, got %+v", remaining)
+	}
+	if referrers, _ := refs.Referrers(ctx, 10); len(referrers) != 0 {
+		t.Fatalf("expected no referrers after purge, got %+v", referrers)
+	}
+}
+
+func TestReferenceUpdates_SystemActorAndRetry(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.CoursePermission{}, &database.Role{},
+		&database.DocumentReferenceLink{}, &database.AuditEvent{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	ndr := &searchNDR{fakeNDR: newFakeNDR(), docs: map[int64]ndrclient.Document{
+		10: {ID: 10, Title: "目标", Metadata: map[string]any{}},
+		11: {ID: 11, Title: "引用方A", Metadata: referencesTo(10)},
+		12: {ID: 12, Title: "引用方B", Metadata: referencesTo(10)},
+	}, failUpdates: map[int64]error{12: errors.New("upstream unavailable")}}
+
+	refs := NewReferenceIndex(db, ndr, NewPermissionService(db, NewUserService(db), ndr, cache.NewNoop()))
+	svc := NewService(cache.NewNoop(), ndr, nil)
+	svc.SetReferenceIndex(refs)
+	svc.SetAuditService(NewAuditService(db))
+	ctx := context.Background()
+	if _, err := refs.Rebuild(ctx); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+
+	// 引用方以系统身份改写，审计中记录触发者；失败的引用方排队等待重试
+	title := "新标题"
+	caller := RequestMeta{UserID: "alice", UserRole: database.RoleProofreader}
+	if _, err := svc.UpdateDocument(ctx, caller, 10, DocumentUpdateRequest{Title: &title}); err != nil {
+		t.Fatalf("UpdateDocument() error = %v", err)
+	}
+	var events []database.AuditEvent
+	if err := db.Where("action = ?", "document.reference_update").Find(&events).Error; err != nil {
+		t.Fatalf("failed to load audit events: %v", err)
+	}
+	if len(events) != 1 || events[0].ResourceID != "11" || events[0].ActorName != referenceSystemActor ||
+		events[0].Details["triggered_by"] != "alice" {
+		t.Fatalf("unexpected reference audit events: %+v", events)
+	}
+	pending, err := refs.PendingUpdates(ctx, 10)
+	if err != nil || len(pending) != 1 || pending[0].SourceDocumentID != 12 {
+		t.Fatalf("expected document 12 to be queued, got %+v err=%v", pending, err)
+	}
+	// 引用方的普通编辑不清除待改写标记
+	svc.syncReferences(ctx, ndr.docs[12])
+	if pending, _ := refs.PendingUpdates(ctx, 10); len(pending) != 1 {
+		t.Fatalf("expected pending flag to survive a sync, got %+v", pending)
+	}
+
+	delete(ndr.failUpdates, 12)
+	if done, err := svc.RetryPendingReferenceUpdates(ctx); err != nil || done != 1 {
+		t.Fatalf("RetryPendingReferenceUpdates() = %d, %v; want 1", done, err)
+	}
+	if got := parseDocumentReferences(ndr.docs[12].Metadata)[0].Title; got != title {
+		t.Fatalf("expected retried title refresh, got %q", got)
+	}
+
+	// 彻底删除时移除失败的引用方在结果中返回，并在重试后移除
+	ndr.failUpdates[11] = errors.New("upstream unavailable")
+	removal, err := svc.PurgeDocumentWithOptions(ctx, caller, 10, DocumentRemovalOptions{})
+	if err != nil || len(removal.PendingReferenceUpdates) != 1 || removal.PendingReferenceUpdates[0] != 11 {
+		t.Fatalf("expected document 11 to be queued, got %+v err=%v", removal, err)
+	}
+	if referrers, _ := refs.Referrers(ctx, 10); len(referrers) != 1 || referrers[0].DocumentID != 11 {
+		t.Fatalf("expected the failed referrer to stay indexed, got %+v", referrers)
+	}
+	delete(ndr.failUpdates, 11)
+	if done, err := svc.RetryPendingReferenceUpdates(ctx); err != nil || done != 1 {
+		t.Fatalf("RetryPendingReferenceUpdates() = %d, %v; want 1", done, err)
+	}
+	if _, ok := ndr.docs[11].Metadata["references"]; ok {
+		t.Fatalf("expected references of document 11 to be removed, got %v", ndr.docs[11].Metadata)
+	}
+	if referrers, _ := refs.Referrers(ctx, 10); len(referrers) != 0 {
+		t.Fatalf("expected no referrers after retry, got %+v", referrers)
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+// refreshTokenLength 刷新令牌随机部分长度（字节）
+const refreshTokenLength = 32
+
+// DefaultRefreshGracePeriod 已轮换的 refresh token 在此期间内再次出现时返回同一个后继令牌对，
+// 兼容多个标签页同时刷新、响应丢失后重试等情况，不视为重放
+const DefaultRefreshGracePeriod = 30 * time.Second
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, all sessions of this login have been revoked")
+)
+
+// TokenPair 登录或刷新时签发的令牌对
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresIn        int64     `json:"expires_in"` // access token 有效期（秒）
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// IssueTokenPair 为用户签发新的 access token 和 refresh token（开启一个新的 token family）
+func (s *UserService) IssueTokenPair(user *database.User, secret string, accessExpiry, refreshExpiry time.Duration) (*TokenPair, error) {
+	return s.issueTokenPair(user, secret, accessExpiry, refreshExpiry, uuid.NewString(), nil)
+}
+
+// SetRefreshGracePeriod 设置轮换宽限期，<= 0 时关闭（已使用的令牌再次出现立即吊销 family）
+func (s *UserService) SetRefreshGracePeriod(grace time.Duration) {
+	s.refreshGrace = grace
+}
+
+// RefreshTokenPair 使用 refresh token 换取新的令牌对
+// 旧 refresh token 被标记为已使用；宽限期内再次使用返回同一个后继令牌对，
+// 宽限期外或后继已被使用时视为重放，吊销整个 family
+func (s *UserService) RefreshTokenPair(rawToken, secret string, accessExpiry, refreshExpiry time.Duration) (*TokenPair, *database.User, error) {
+	if rawToken == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var current database.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashRefreshToken(rawToken)).First(&current).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if current.RevokedAt != nil {
+		return nil, nil, s.refreshTokenReused(current.FamilyID)
+	}
+	if current.UsedAt != nil {
+		return s.replayRotation(&current, rawToken)
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// 先签发后继令牌，再用条件更新标记旧令牌并保存加密的后继；并发刷新时只有一个请求能更新成功
+	pair, err := s.issueTokenPair(user, secret, accessExpiry, refreshExpiry, current.FamilyID, &current.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, err := sealSuccessor(rawToken, pair, time.Now().Add(accessExpiry))
+	if err != nil {
+		return nil, nil, err
+	}
+	result := s.db.Model(&database.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+		Updates(map[string]interface{}{"used_at": time.Now(), "successor_sealed": sealed})
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// 并发请求已先完成轮换：丢弃本次签发的令牌，按宽限期规则返回对方的后继
+		if err := s.discardTokenPair(pair); err != nil {
+			return nil, nil, err
+		}
+		if err := s.db.First(&current, current.ID).Error; err != nil {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		if current.RevokedAt != nil || current.UsedAt == nil {
+			return nil, nil, s.refreshTokenReused(current.FamilyID)
+		}
+		return s.replayRotation(&current, rawToken)
+	}
+	return pair, user, nil
+}
+
+// replayRotation 处理已轮换的令牌再次出现：宽限期内且后继尚未被使用时返回同一个后继，否则吊销 family
+func (s *UserService) replayRotation(current *database.RefreshToken, rawToken string) (*TokenPair, *database.User, error) {
+	if s.refreshGrace <= 0 || current.SuccessorSealed == "" || time.Since(*current.UsedAt) > s.refreshGrace {
+		return nil, nil, s.refreshTokenReused(current.FamilyID)
+	}
+	pair, err := openSuccessor(rawToken, current.SuccessorSealed)
+	if err != nil {
+		return nil, nil, s.refreshTokenReused(current.FamilyID)
+	}
+	// 后继已被使用或吊销，说明旧令牌不再由合法客户端持有
+	var successor database.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashRefreshToken(pair.RefreshToken)).First(&successor).Error; err != nil ||
+		successor.UsedAt != nil || successor.RevokedAt != nil {
+		return nil, nil, s.refreshTokenReused(current.FamilyID)
+	}
+	user, err := s.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	return pair, user, nil
+}
+
+// refreshTokenReused 吊销 family 并返回 ErrRefreshTokenReused
+func (s *UserService) refreshTokenReused(familyID string) error {
+	if err := s.revokeTokenFamily(familyID, database.SessionRevokedTokenReuse); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// discardTokenPair 删除并发轮换中落败一方签发的、从未返回给客户端的令牌
+func (s *UserService) discardTokenPair(pair *TokenPair) error {
+	var refresh database.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashRefreshToken(pair.RefreshToken)).First(&refresh).Error; err != nil {
+		return fmt.Errorf("failed to load discarded refresh token: %w", err)
+	}
+	if err := s.db.Where("token_id = ?", refresh.SessionTokenID).Delete(&database.UserSession{}).Error; err != nil {
+		return fmt.Errorf("failed to discard session: %w", err)
+	}
+	if err := s.db.Delete(&refresh).Error; err != nil {
+		return fmt.Errorf("failed to discard refresh token: %w", err)
+	}
+	return nil
+}
+
+// sealedSuccessor 加密保存的后继令牌对
+type sealedSuccessor struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// successorCipher 以旧令牌派生 AES-GCM 密钥：数据库中只有哈希，没有旧令牌原文就无法解出后继
+func successorCipher(rawToken string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("ydms-refresh-successor:" + rawToken))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealSuccessor(rawToken string, pair *TokenPair, accessExpiresAt time.Time) (string, error) {
+	plaintext, err := json.Marshal(sealedSuccessor{
+		AccessToken:      pair.AccessToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresAt: pair.RefreshExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	aead, err := successorCipher(rawToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal refresh token successor: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to seal refresh token successor: %w", err)
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func openSuccessor(rawToken, sealed string) (*TokenPair, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := successorCipher(rawToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("sealed successor is too short")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	var successor sealedSuccessor
+	if err := json.Unmarshal(plaintext, &successor); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      successor.AccessToken,
+		RefreshToken:     successor.RefreshToken,
+		ExpiresIn:        int64(max(time.Until(successor.AccessExpiresAt), 0).Seconds()),
+		RefreshExpiresAt: successor.RefreshExpiresAt,
+	}, nil
+}
+
+// issueTokenPair 签发 access token 并在指定 family 中创建 refresh token
+func (s *UserService) issueTokenPair(user *database.User, secret string, accessExpiry, refreshExpiry time.Duration, familyID string, parentID *uint) (*TokenPair, error) {
+	accessToken, tokenID, err := s.issueAccessToken(user, secret, accessExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	rawToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := &database.RefreshToken{
+		UserID:         user.ID,
+		TokenHash:      hashRefreshToken(rawToken),
+		FamilyID:       familyID,
+		ParentID:       parentID,
+		SessionTokenID: tokenID,
+		ExpiresAt:      time.Now().Add(refreshExpiry),
+	}
+	if err := s.db.Create(refresh).Error; err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     rawToken,
+		ExpiresIn:        int64(accessExpiry.Seconds()),
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// revokeTokenFamily 吊销 family 中所有 refresh token 及其签发的 access token 会话
+func (s *UserService) revokeTokenFamily(familyID, reason string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"revoked_at": now, "revoked_reason": reason}
+
+	sessionIDs := s.db.Model(&database.RefreshToken{}).
+		Select("session_token_id").
+		Where("family_id = ?", familyID)
+	if err := s.db.Model(&database.UserSession{}).
+		Where("token_id IN (?) AND revoked_at IS NULL", sessionIDs).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to revoke family sessions: %w", err)
+	}
+
+	if err := s.db.Model(&database.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// familyOfSession 返回签发某个 access token 时所在的 token family（不存在时返回空字符串）
+func (s *UserService) familyOfSession(tokenID string) (string, error) {
+	if tokenID == "" {
+		return "", nil
+	}
+	var refresh database.RefreshToken
+	err := s.db.Where("session_token_id = ?", tokenID).Limit(1).Find(&refresh).Error
+	if err != nil {
+		return "", err
+	}
+	return refresh.FamilyID, nil
+}
+
+// generateRefreshToken 生成随机的不透明刷新令牌
+func generateRefreshToken() (string, error) {
+	randomBytes := make([]byte, refreshTokenLength)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// hashRefreshToken 计算刷新令牌的存储哈希
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", sum)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/auth"
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+func setupUserServiceDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.UserSession{}, &database.RefreshToken{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+func TestRefreshTokenPair_RotationAndReuse(t *testing.T) {
+	db := setupUserServiceDB(t)
+	svc := NewUserService(db)
+	const secret = "test-secret"
+
+	user := &database.User{Username: "proofreader1", PasswordHash: "hash", Role: "proofreader"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	first, err := svc.IssueTokenPair(user, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueTokenPair() error = %v", err)
+	}
+
+	second, _, err := svc.RefreshTokenPair(first.RefreshToken, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("RefreshTokenPair() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+
+	claims, err := auth.ValidateToken(second.AccessToken, secret)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if err := auth.CheckSession(db, claims); err != nil {
+		t.Fatalf("new access token should have an active session: %v", err)
+	}
+
+	// 宽限期内再次使用旧令牌（如另一个标签页同时刷新）：返回同一个后继
+	again, _, err := svc.RefreshTokenPair(first.RefreshToken, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("refresh within grace period: %v", err)
+	}
+	if again.RefreshToken != second.RefreshToken || again.AccessToken != second.AccessToken {
+		t.Fatalf("grace period refresh should return the same successor")
+	}
+
+	// 超过宽限期后重放已轮换的 refresh token：整个 family 被吊销
+	if err := db.Model(&database.RefreshToken{}).Where("used_at IS NOT NULL").
+		Update("used_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("failed to age rotated token: %v", err)
+	}
+	if _, _, err := svc.RefreshTokenPair(first.RefreshToken, secret, time.Minute, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if err := auth.CheckSession(db, claims); err == nil {
+		t.Errorf("access token of the reused family should be revoked")
+	}
+	if _, _, err := svc.RefreshTokenPair(second.RefreshToken, secret, time.Minute, time.Hour); err == nil {
+		t.Errorf("latest refresh token of the reused family should be revoked")
+	}
+}
+
+func TestRefreshTokenPair_GraceEndsWhenSuccessorUsed(t *testing.T) {
+	db := setupUserServiceDB(t)
+	svc := NewUserService(db)
+	const secret = "test-secret"
+
+	user := &database.User{Username: "proofreader2", PasswordHash: "hash", Role: "proofreader"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	first, err := svc.IssueTokenPair(user, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueTokenPair() error = %v", err)
+	}
+	second, _, err := svc.RefreshTokenPair(first.RefreshToken, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("RefreshTokenPair() error = %v", err)
+	}
+	if _, _, err := svc.RefreshTokenPair(second.RefreshToken, secret, time.Minute, time.Hour); err != nil {
+		t.Fatalf("RefreshTokenPair() error = %v", err)
+	}
+
+	// 后继已被轮换，旧令牌即使仍在宽限期内也视为重放
+	if _, _, err := svc.RefreshTokenPair(first.RefreshToken, secret, time.Minute, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	svc.SetRefreshGracePeriod(0)
+	third, err := svc.IssueTokenPair(user, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueTokenPair() error = %v", err)
+	}
+	if _, _, err := svc.RefreshTokenPair(third.RefreshToken, secret, time.Minute, time.Hour); err != nil {
+		t.Fatalf("RefreshTokenPair() error = %v", err)
+	}
+	if _, _, err := svc.RefreshTokenPair(third.RefreshToken, secret, time.Minute, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("without a grace period any reuse should revoke, got %v", err)
+	}
+}
+
+func TestRefreshTokenPair_Invalid(t *testing.T) {
+	db := setupUserServiceDB(t)
+	svc := NewUserService(db)
+
+	if _, _, err := svc.RefreshTokenPair("not-a-token", "secret", time.Minute, time.Hour); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestRevokeUserSessions_KeepsCurrentFamily(t *testing.T) {
+	db := setupUserServiceDB(t)
+	svc := NewUserService(db)
+	const secret = "test-secret"
+
+	user := &database.User{Username: "admin1", PasswordHash: "hash", Role: "course_admin"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	current, err := svc.IssueTokenPair(user, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueTokenPair() error = %v", err)
+	}
+	other, err := svc.IssueTokenPair(user, secret, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueTokenPair() error = %v", err)
+	}
+
+	claims, err := auth.ValidateToken(current.AccessToken, secret)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if _, err := svc.RevokeUserSessions(user.ID, claims.ID, database.SessionRevokedPasswordChanged); err != nil {
+		t.Fatalf("RevokeUserSessions() error = %v", err)
+	}
+
+	if _, _, err := svc.RefreshTokenPair(current.RefreshToken, secret, time.Minute, time.Hour); err != nil {
+		t.Errorf("current login should survive password change: %v", err)
+	}
+	if _, _, err := svc.RefreshTokenPair(other.RefreshToken, secret, time.Minute, time.Hour); err == nil {
+		t.Errorf("other login should be revoked after password change")
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+// 角色管理错误
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleBuiltIn  = errors.New("built-in roles cannot be modified")
+	ErrRoleInUse    = errors.New("role is assigned to users")
+	ErrInvalidRole  = errors.New("invalid role")
+)
+
+// roleNamePattern 角色名：小写字母开头，仅含小写字母、数字和下划线
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,63}$`)
+
+// RoleService 角色服务
+// 角色定义保存在 roles 表；数据库不可用时（如单元测试）回退到内置角色定义
+type RoleService struct {
+	db    *gorm.DB
+	audit *AuditService
+}
+
+// NewRoleService 创建角色服务，db 可以为 nil
+func NewRoleService(db *gorm.DB) *RoleService {
+	return &RoleService{db: db, audit: NewAuditService(db)}
+}
+
+// RoleRequest 创建/更新角色请求
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// rolePermissionTTL 角色权限缓存有效期：本实例修改角色时立即失效，其他实例的修改最多延迟一个周期生效
+const rolePermissionTTL = 30 * time.Second
+
+type cachedPermissions struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// rolePermissionCache 进程内共享的角色 -> 权限缓存，按数据库实例隔离；
+// 各服务各自 NewRoleService 也共用同一份缓存，角色修改后统一失效
+var rolePermissionCache = struct {
+	sync.Mutex
+	entries map[*gorm.Config]map[string]cachedPermissions
+}{entries: make(map[*gorm.Config]map[string]cachedPermissions)}
+
+// Permissions 返回角色拥有的权限，未知角色返回空
+func (s *RoleService) Permissions(ctx context.Context, role string) []string {
+	if role == "" {
+		return nil
+	}
+	if s != nil && s.db != nil {
+		if permissions, ok := s.cachedPermissions(role); ok {
+			return permissions
+		}
+		var r database.Role
+		err := s.db.WithContext(ctx).Select("permissions").Where("name = ?", role).First(&r).Error
+		switch {
+		case err == nil:
+			s.storePermissions(role, r.Permissions)
+			return r.Permissions
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			permissions := builtinPermissions(role)
+			s.storePermissions(role, permissions)
+			return permissions
+		}
+	}
+	return builtinPermissions(role)
+}
+
+func builtinPermissions(role string) []string {
+	if builtin, ok := database.BuiltinRole(role); ok {
+		return builtin.Permissions
+	}
+	return nil
+}
+
+func (s *RoleService) cachedPermissions(role string) ([]string, bool) {
+	rolePermissionCache.Lock()
+	defer rolePermissionCache.Unlock()
+	entry, ok := rolePermissionCache.entries[s.db.Config][role]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.permissions, true
+}
+
+func (s *RoleService) storePermissions(role string, permissions []string) {
+	rolePermissionCache.Lock()
+	defer rolePermissionCache.Unlock()
+	entries := rolePermissionCache.entries[s.db.Config]
+	if entries == nil {
+		entries = make(map[string]cachedPermissions)
+		rolePermissionCache.entries[s.db.Config] = entries
+	}
+	entries[role] = cachedPermissions{permissions: permissions, expiresAt: time.Now().Add(rolePermissionTTL)}
+}
+
+// invalidatePermissions 角色增删改后清空本数据库的权限缓存
+func (s *RoleService) invalidatePermissions() {
+	rolePermissionCache.Lock()
+	defer rolePermissionCache.Unlock()
+	delete(rolePermissionCache.entries, s.db.Config)
+}
+
+// HasPermission 检查角色是否拥有指定权限
+func (s *RoleService) HasPermission(ctx context.Context, role, permission string) bool {
+	return containsPermission(s.Permissions(ctx, role), permission)
+}
+
+// RoleExists 检查角色是否已定义
+func (s *RoleService) RoleExists(ctx context.Context, name string) bool {
+	if s != nil && s.db != nil {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&database.Role{}).Where("name = ?", name).Count(&count).Error; err == nil {
+			return count > 0
+		}
+	}
+	_, ok := database.BuiltinRole(name)
+	return ok
+}
+
+// ListRoles 列出所有角色
+func (s *RoleService) ListRoles(ctx context.Context) ([]database.Role, error) {
+	var roles []database.Role
+	if err := s.db.WithContext(ctx).Order("built_in DESC, name ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+// GetRole 按名称获取角色
+func (s *RoleService) GetRole(ctx context.Context, name string) (*database.Role, error) {
+	var role database.Role
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole 创建自定义角色
+func (s *RoleService) CreateRole(ctx context.Context, meta RequestMeta, req RoleRequest) (*database.Role, error) {
+	name := strings.TrimSpace(req.Name)
+	if !roleNamePattern.MatchString(name) {
+		return nil, newValidationError("角色名只能包含小写字母、数字和下划线，且以字母开头（2-64 个字符）")
+	}
+	permissions, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if s.RoleExists(ctx, name) {
+		return nil, ErrRoleExists
+	}
+
+	role := database.Role{
+		Name:        name,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Description: strings.TrimSpace(req.Description),
+		Permissions: permissions,
+	}
+	if role.DisplayName == "" {
+		role.DisplayName = name
+	}
+	if err := s.db.WithContext(ctx).Create(&role).Error; err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	s.invalidatePermissions()
+	s.recordRoleAudit(ctx, meta, "role.create", name, map[string]interface{}{"permissions": permissions})
+	return &role, nil
+}
+
+// UpdateRole 更新自定义角色的显示名、说明和权限（角色名不可修改）
+func (s *RoleService) UpdateRole(ctx context.Context, meta RequestMeta, name string, req RoleRequest) (*database.Role, error) {
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role.BuiltIn {
+		return nil, ErrRoleBuiltIn
+	}
+	permissions, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"permissions": permissions}
+	if v := strings.TrimSpace(req.DisplayName); v != "" {
+		updates["display_name"] = v
+	}
+	updates["description"] = strings.TrimSpace(req.Description)
+	if err := s.db.WithContext(ctx).Model(role).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	s.invalidatePermissions()
+	s.recordRoleAudit(ctx, meta, "role.update", name, map[string]interface{}{
+		"previous_permissions": role.Permissions,
+		"permissions":          permissions,
+	})
+	return s.GetRole(ctx, name)
+}
+
+// DeleteRole 删除自定义角色，仍有用户或课程授权使用时拒绝删除
+func (s *RoleService) DeleteRole(ctx context.Context, meta RequestMeta, name string) error {
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+	var users int64
+	if err := s.db.WithContext(ctx).Model(&database.User{}).Where("role = ?", name).Count(&users).Error; err != nil {
+		return fmt.Errorf("failed to count role users: %w", err)
+	}
+	if users > 0 {
+		return fmt.Errorf("%w: %d user(s)", ErrRoleInUse, users)
+	}
+	var grants int64
+	if err := s.db.WithContext(ctx).Model(&database.CoursePermission{}).Where("role = ?", name).Count(&grants).Error; err != nil {
+		return fmt.Errorf("failed to count role course permissions: %w", err)
+	}
+	if grants > 0 {
+		return fmt.Errorf("%w: %d course permission(s)", ErrRoleInUse, grants)
+	}
+	if err := s.db.WithContext(ctx).Delete(role).Error; err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	s.invalidatePermissions()
+	s.recordRoleAudit(ctx, meta, "role.delete", name, nil)
+	return nil
+}
+
+func (s *RoleService) recordRoleAudit(ctx context.Context, meta RequestMeta, action, name string, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: AuditResourceRole,
+		ResourceID:   name,
+		Details:      details,
+	})
+}
+
+// normalizePermissions 校验并去重权限列表
+func normalizePermissions(permissions []string) (database.StringList, error) {
+	result := make(database.StringList, 0, len(permissions))
+	seen := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if seen[p] {
+			continue
+		}
+		if !containsPermission(database.AllPermissions, p) {
+			return nil, newValidationError("未知权限: %s", p)
+		}
+		seen[p] = true
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+func setupRolesDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
+
+func TestRoleMigration_CreatesRolesForExistingUsers(t *testing.T) {
+	db := setupRolesDB(t)
+	if err := db.Create(&database.User{Username: "legacy", PasswordHash: "x", Role: "editor"}).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to re-run migration: %v", err)
+	}
+
+	roles := NewRoleService(db)
+	ctx := context.Background()
+	if !roles.RoleExists(ctx, "editor") {
+		t.Fatal("expected role to be created for existing user")
+	}
+	if perms := roles.Permissions(ctx, "editor"); len(perms) != 0 {
+		t.Fatalf("expected migrated role to have no permissions, got %v", perms)
+	}
+	if !roles.HasPermission(ctx, database.RoleSuperAdmin, database.PermCoursesAll) {
+		t.Fatal("expected built-in super_admin role to be seeded")
+	}
+}
+
+func TestRoleService_CustomRole(t *testing.T) {
+	db := setupRolesDB(t)
+	roles := NewRoleService(db)
+	ctx := context.Background()
+
+	_, err := roles.CreateRole(ctx, RequestMeta{}, RoleRequest{
+		Name:        "reviewer",
+		Permissions: []string{database.PermDocumentView, database.PermDocumentRestoreVersion, database.PermDocumentView},
+	})
+	if err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if !roles.HasPermission(ctx, "reviewer", database.PermDocumentRestoreVersion) || roles.HasPermission(ctx, "reviewer", database.PermDocumentDelete) {
+		t.Fatalf("unexpected reviewer permissions: %v", roles.Permissions(ctx, "reviewer"))
+	}
+
+	var vErr *ValidationError
+	if _, err := roles.CreateRole(ctx, RequestMeta{}, RoleRequest{Name: "bad", Permissions: []string{"document:fly"}}); !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error for unknown permission, got %v", err)
+	}
+	if _, err := roles.CreateRole(ctx, RequestMeta{}, RoleRequest{Name: "reviewer"}); !errors.Is(err, ErrRoleExists) {
+		t.Fatalf("expected ErrRoleExists, got %v", err)
+	}
+	if _, err := roles.UpdateRole(ctx, RequestMeta{}, database.RoleProofreader, RoleRequest{}); !errors.Is(err, ErrRoleBuiltIn) {
+		t.Fatalf("expected built-in role to be read-only, got %v", err)
+	}
+
+	users := NewUserService(db)
+	if _, err := users.CreateUser(ctx, RequestMeta{}, "rita", "password123", "reviewer", nil); err != nil {
+		t.Fatalf("CreateUser() with custom role error = %v", err)
+	}
+	if _, err := users.CreateUser(ctx, RequestMeta{}, "nobody", "password123", "ghost", nil); err == nil {
+		t.Fatal("expected unknown role to be rejected")
+	}
+	if err := roles.DeleteRole(ctx, RequestMeta{}, "reviewer"); !errors.Is(err, ErrRoleInUse) {
+		t.Fatalf("expected ErrRoleInUse, got %v", err)
+	}
+
+	perm := NewPermissionService(db, users, newFakeNDR(), nil)
+	canRestore, err := perm.CanRestoreDocumentVersion(ctx, 0, "reviewer", 1)
+	if err != nil || !canRestore {
+		t.Fatalf("expected reviewer to restore versions, got %v %v", canRestore, err)
+	}
+}
+
+func TestRoleService_PermissionCacheInvalidatedOnEdit(t *testing.T) {
+	db := setupRolesDB(t)
+	ctx := context.Background()
+	editor, reader := NewRoleService(db), NewRoleService(db)
+
+	if _, err := editor.CreateRole(ctx, RequestMeta{}, RoleRequest{Name: "auditor", Permissions: []string{database.PermAuditView}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if !reader.HasPermission(ctx, "auditor", database.PermAuditView) {
+		t.Fatal("expected auditor to view audit events")
+	}
+	if _, err := editor.UpdateRole(ctx, RequestMeta{}, "auditor", RoleRequest{Permissions: []string{database.PermDocumentView}}); err != nil {
+		t.Fatalf("UpdateRole() error = %v", err)
+	}
+	if reader.HasPermission(ctx, "auditor", database.PermAuditView) || !reader.HasPermission(ctx, "auditor", database.PermDocumentView) {
+		t.Fatalf("expected cached permissions to be refreshed, got %v", reader.Permissions(ctx, "auditor"))
+	}
+	if err := editor.DeleteRole(ctx, RequestMeta{}, "auditor"); err != nil {
+		t.Fatalf("DeleteRole() error = %v", err)
+	}
+	if reader.HasPermission(ctx, "auditor", database.PermDocumentView) {
+		t.Fatal("expected deleted role to lose its permissions")
+	}
+}
+
+func TestRoleService_FallsBackToBuiltinsWithoutDB(t *testing.T) {
+	var roles *RoleService
+	ctx := context.Background()
+	if !roles.HasPermission(ctx, database.RoleProofreader, database.PermDocumentEdit) {
+		t.Fatal("expected proofreader to edit documents")
+	}
+	if roles.HasPermission(ctx, database.RoleProofreader, database.PermDocumentCreate) {
+		t.Fatal("expected proofreader not to create documents")
+	}
+	if roles.HasPermission(ctx, "unknown", database.PermDocumentView) {
+		t.Fatal("expected unknown role to have no permissions")
+	}
+}
+
+func TestPermissionService_PerCourseRoles(t *testing.T) {
+	db := setupRolesDB(t)
+	ctx := context.Background()
+	users := NewUserService(db)
+	user, err := users.CreateUser(ctx, RequestMeta{}, "carol", "password123", database.RoleProofreader, nil)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 1, database.RoleCourseAdmin); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 2, ""); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 3, "ghost"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+
+	parent := int64(2)
+	ndr := newFakeNDR()
+	ndr.getNodes[1] = ndrclient.Node{ID: 1, Name: "课程一"}
+	ndr.getNodes[2] = ndrclient.Node{ID: 2, Name: "课程二"}
+	ndr.getNodes[20] = ndrclient.Node{ID: 20, ParentID: &parent}
+	ndr.getNodes[3] = ndrclient.Node{ID: 3, Name: "课程三"}
+	perm := NewPermissionService(db, users, ndr, nil)
+
+	inAdminCourse, err := perm.GetNodePermission(ctx, user.ID, user.Role, 1)
+	if err != nil || !inAdminCourse.CanCreate || !inAdminCourse.CanDelete || inAdminCourse.CanPurge {
+		t.Fatalf("expected course_admin permissions in course 1, got %+v %v", inAdminCourse, err)
+	}
+	inheritedDoc, err := perm.GetDocumentPermission(ctx, user.ID, user.Role, 20)
+	if err != nil || !inheritedDoc.CanEdit || inheritedDoc.CanCreate {
+		t.Fatalf("expected inherited proofreader permissions in course 2, got %+v %v", inheritedDoc, err)
+	}
+	noAccess, err := perm.GetNodePermission(ctx, user.ID, user.Role, 3)
+	if err != nil || noAccess.CanView {
+		t.Fatalf("expected no access to course 3, got %+v %v", noAccess, err)
+	}
+	if !perm.HasPermissionInAnyCourse(ctx, user, database.PermNodeCreate) || perm.HasPermissionInAnyCourse(ctx, user, database.PermNodePurge) {
+		t.Fatal("unexpected coarse permission result")
+	}
+	if ok, err := perm.HasNodePermission(ctx, user.ID, user.Role, 20, database.PermNodeCreate); err != nil || ok {
+		t.Fatalf("expected no node:create in course 2, got %v %v", ok, err)
+	}
+
+	// 文档权限按绑定节点所属课程判断
+	_ = ndr.BindDocument(ctx, ndrclient.RequestMeta{}, 1, 100)
+	_ = ndr.BindDocument(ctx, ndrclient.RequestMeta{}, 3, 300)
+	if ok, err := perm.CanRestoreDocumentVersion(ctx, user.ID, user.Role, 100); err != nil || !ok {
+		t.Fatalf("expected course_admin to restore versions in course 1, got %v %v", ok, err)
+	}
+	if ok, err := perm.CanRestoreDocumentVersion(ctx, user.ID, user.Role, 300); err != nil || ok {
+		t.Fatalf("expected no version restore in course 3, got %v %v", ok, err)
+	}
+	_ = ndr.BindDocument(ctx, ndrclient.RequestMeta{}, 3, 100)
+	if ok, err := perm.HasDocumentPermission(ctx, user.ID, user.Role, 100, database.PermDocumentEdit); err != nil || ok {
+		t.Fatalf("expected document also bound to course 3 to be read-only, got %v %v", ok, err)
+	}
+
+	// 已有授权再次授予时更新角色
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 1, database.RoleProofreader); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+	if role, _ := users.EffectiveCourseRole(ctx, user.ID, 1); role != database.RoleProofreader {
+		t.Fatalf("expected course role to be updated, got %q", role)
+	}
+	if err := users.GrantCoursePermission(ctx, RequestMeta{}, user.ID, 1, database.RoleCourseAdmin); err != nil {
+		t.Fatalf("GrantCoursePermission() error = %v", err)
+	}
+
+	courses, err := NewCourseService(db, ndr, users, nil).ListCourses(ctx, RequestMeta{}, user.ID, user.Role)
+	if err != nil {
+		t.Fatalf("ListCourses() error = %v", err)
+	}
+	if len(courses) != 2 || courses[0].ID != 1 || courses[0].Role != database.RoleCourseAdmin || courses[1].Role != database.RoleProofreader {
+		t.Fatalf("unexpected courses: %+v", courses)
+	}
+}
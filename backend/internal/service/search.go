@@ -0,0 +1,477 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+	"github.com/yjxt/ydms/backend/internal/search"
+)
+
+const (
+	searchQueueSize         = 1024 // 写操作触发的待索引文档队列长度
+	searchReconcilePageSize = 100  // 对账时每页从 NDR 拉取的文档数
+	searchSnippetRadius     = 40   // 摘要在命中位置前后保留的字符数
+	searchMaxQueryTerms     = 32   // 单次查询最多使用的词项数
+)
+
+// 词项权重：同一词项在标题、标签、正文中出现时分别累加
+const (
+	searchWeightTitle   = 5
+	searchWeightTags    = 3
+	searchWeightContent = 1
+)
+
+// DocumentIndexer 接收文档写操作的通知，用于刷新检索索引
+type DocumentIndexer interface {
+	EnqueueDocuments(docIDs ...int64)
+}
+
+// SearchService 全文检索服务
+// 索引保存在本地数据库（search_documents / search_terms），写操作通过队列增量刷新，
+// 并定期与 NDR 对账以兜底遗漏的变更（如直接在 NDR 上的修改）
+type SearchService struct {
+	db          *gorm.DB
+	ndr         ndrclient.Client
+	permissions *PermissionService
+	queue       chan int64
+}
+
+// NewSearchService 创建全文检索服务
+func NewSearchService(db *gorm.DB, ndr ndrclient.Client, permissions *PermissionService) *SearchService {
+	return &SearchService{
+		db:          db,
+		ndr:         ndr,
+		permissions: permissions,
+		queue:       make(chan int64, searchQueueSize),
+	}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (s *SearchService) Roles() *RoleService {
+	return s.permissions.Roles()
+}
+
+// SearchRequest 检索参数
+type SearchRequest struct {
+	Query string
+	Type  string // 可选，按文档类型过滤
+	Page  int
+	Size  int
+}
+
+// SearchHit 检索结果
+type SearchHit struct {
+	DocumentID int64     `json:"document_id"`
+	Title      string    `json:"title"`
+	DocType    string    `json:"doc_type,omitempty"`
+	RootNodeID int64     `json:"root_node_id"`
+	NodeID     int64     `json:"node_id,omitempty"`
+	NodePath   string    `json:"node_path,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Snippet    string    `json:"snippet"`
+	Score      int       `json:"score"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SearchResponse 检索结果分页
+type SearchResponse struct {
+	Query string      `json:"query"`
+	Terms []string    `json:"terms"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+	Total int64       `json:"total"`
+	Items []SearchHit `json:"items"`
+}
+
+// SearchReconcileResult 一轮索引对账的统计
+type SearchReconcileResult struct {
+	Scanned int `json:"scanned"` // NDR 中的文档数
+	Indexed int `json:"indexed"` // 重建索引的文档数
+	Removed int `json:"removed"` // 从索引中移除的文档数
+	Errors  int `json:"errors"`  // 重建失败的文档数
+}
+
+// EnqueueDocuments 把文档加入待索引队列，不阻塞调用方
+// 队列已满时丢弃，由下一轮对账补齐
+func (s *SearchService) EnqueueDocuments(docIDs ...int64) {
+	for _, id := range docIDs {
+		select {
+		case s.queue <- id:
+		default:
+			log.Printf("[search] index queue full, dropping document id=%d", id)
+		}
+	}
+}
+
+// Start 启动后台索引协程，直到 ctx 取消：
+// 消费写操作产生的队列；interval > 0 时启动后立即对账一次，之后按 interval 定期对账
+func (s *SearchService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case id := <-s.queue:
+				if err := s.IndexDocument(ctx, id); err != nil {
+					log.Printf("[search] index document id=%d failed: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	if interval <= 0 {
+		return
+	}
+	log.Printf("[search] reconciler started, interval=%s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			result, err := s.Reconcile(ctx, false)
+			if err != nil {
+				log.Printf("[search] reconcile failed: %v", err)
+			} else if result.Indexed > 0 || result.Removed > 0 || result.Errors > 0 {
+				log.Printf("[search] reconcile scanned=%d indexed=%d removed=%d errors=%d",
+					result.Scanned, result.Indexed, result.Removed, result.Errors)
+			}
+			select {
+			case <-ctx.Done():
+				log.Printf("[search] reconciler stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// IndexDocument 从 NDR 读取文档并重建其索引；文档已删除或不存在时从索引中移除
+func (s *SearchService) IndexDocument(ctx context.Context, docID int64) error {
+	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(RequestMeta{}), docID)
+	if err != nil {
+		var ndrErr *ndrclient.Error
+		if errors.As(err, &ndrErr) && ndrErr.StatusCode == http.StatusNotFound {
+			return s.RemoveDocument(ctx, docID)
+		}
+		return fmt.Errorf("get document: %w", err)
+	}
+	if doc.DeletedAt != nil {
+		return s.RemoveDocument(ctx, docID)
+	}
+
+	record := database.SearchDocument{
+		DocumentID:      doc.ID,
+		Title:           doc.Title,
+		Tags:            strings.Join(documentTags(doc), " "),
+		Content:         documentPlainText(doc),
+		SourceUpdatedAt: doc.UpdatedAt.Truncate(time.Microsecond),
+		IndexedAt:       time.Now(),
+	}
+	if doc.Type != nil {
+		record.DocType = *doc.Type
+	}
+	bindings, err := s.ndr.GetDocumentBindings(ctx, toNDRMeta(RequestMeta{}), docID)
+	if err != nil {
+		return fmt.Errorf("get document bindings: %w", err)
+	}
+	if len(bindings) > 0 {
+		record.NodeID = bindings[0].NodeID
+		record.NodePath = bindings[0].NodePath
+		rootID, err := s.permissions.getRootNodeID(ctx, record.NodeID)
+		if err != nil {
+			return fmt.Errorf("resolve course: %w", err)
+		}
+		record.RootNodeID = rootID
+	}
+
+	terms := documentTerms(record)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteSearchIndex(tx, docID); err != nil {
+			return err
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("save search document: %w", err)
+		}
+		if len(terms) > 0 {
+			if err := tx.CreateInBatches(terms, 500).Error; err != nil {
+				return fmt.Errorf("save search terms: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveDocument 从索引中移除文档
+func (s *SearchService) RemoveDocument(ctx context.Context, docID int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteSearchIndex(tx, docID)
+	})
+}
+
+func deleteSearchIndex(tx *gorm.DB, docIDs ...int64) error {
+	if err := tx.Where("document_id IN ?", docIDs).Delete(&database.SearchTerm{}).Error; err != nil {
+		return fmt.Errorf("delete search terms: %w", err)
+	}
+	if err := tx.Where("document_id IN ?", docIDs).Delete(&database.SearchDocument{}).Error; err != nil {
+		return fmt.Errorf("delete search document: %w", err)
+	}
+	return nil
+}
+
+// Reconcile 与 NDR 对账一轮：重建 updated_at 变化、所属课程变化（节点被移动）或尚未索引的文档，
+// 移除 NDR 中已不存在的文档
+// full 为 true 时重建全部文档（用于绑定关系变化、分词规则调整后的全量重建）
+func (s *SearchService) Reconcile(ctx context.Context, full bool) (*SearchReconcileResult, error) {
+	indexed := make(map[int64]database.SearchDocument)
+	var rows []database.SearchDocument
+	if err := s.db.WithContext(ctx).Select("document_id", "node_id", "root_node_id", "source_updated_at").
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("load search index: %w", err)
+	}
+	bound := false
+	for _, row := range rows {
+		indexed[row.DocumentID] = row
+		bound = bound || row.NodeID != 0
+	}
+	var roots map[int64]int64
+	if bound {
+		var err error
+		if roots, err = s.courseRoots(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &SearchReconcileResult{}
+	seen := make(map[int64]bool)
+	query := url.Values{}
+	query.Set("size", strconv.Itoa(searchReconcilePageSize))
+	for doc, err := range s.ndr.StreamDocuments(ctx, toNDRMeta(RequestMeta{}), query) {
+		if err != nil {
+			return result, fmt.Errorf("list documents: %w", err)
+		}
+		if doc.DeletedAt != nil {
+			continue
+		}
+		result.Scanned++
+		seen[doc.ID] = true
+		if row, ok := indexed[doc.ID]; ok && !full && row.SourceUpdatedAt.Equal(doc.UpdatedAt.Truncate(time.Microsecond)) {
+			if row.NodeID == 0 {
+				continue
+			}
+			// 节点移动不会改变文档的 updated_at，需对比节点当前所属的课程
+			if root, found := roots[row.NodeID]; found && root == row.RootNodeID {
+				continue
+			}
+		}
+		if err := s.IndexDocument(ctx, doc.ID); err != nil {
+			log.Printf("[search] reconcile index document id=%d failed: %v", doc.ID, err)
+			result.Errors++
+			continue
+		}
+		result.Indexed++
+	}
+
+	var stale []int64
+	for id := range indexed {
+		if !seen[id] {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return deleteSearchIndex(tx, stale...)
+		}); err != nil {
+			return result, err
+		}
+		result.Removed = len(stale)
+	}
+	return result, nil
+}
+
+// courseRoots 拉取全部节点（含已删除），返回节点 ID 到其课程根节点 ID 的映射
+func (s *SearchService) courseRoots(ctx context.Context) (map[int64]int64, error) {
+	includeDeleted := true
+	parents := make(map[int64]*int64)
+	params := ndrclient.ListNodesParams{Size: searchReconcilePageSize, IncludeDeleted: &includeDeleted}
+	for node, err := range s.ndr.StreamNodes(ctx, toNDRMeta(RequestMeta{}), params) {
+		if err != nil {
+			return nil, fmt.Errorf("list nodes: %w", err)
+		}
+		parents[node.ID] = node.ParentID
+	}
+
+	roots := make(map[int64]int64, len(parents))
+	for id := range parents {
+		root := id
+		// 深度上限防止异常数据成环
+		for depth := 0; depth < len(parents); depth++ {
+			parent, ok := parents[root]
+			if !ok || parent == nil {
+				break
+			}
+			root = *parent
+		}
+		roots[id] = root
+	}
+	return roots, nil
+}
+
+// Search 检索文档：文档需包含查询的全部词项，按权重之和排序
+// 课程受限的用户只能检索其课程内的文档，课程范围经 PermissionService.FilterUserCourses 过滤
+func (s *SearchService) Search(ctx context.Context, meta RequestMeta, req SearchRequest) (*SearchResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, newValidationError("query is required")
+	}
+	terms := search.QueryTerms(query)
+	if len(terms) == 0 {
+		return nil, newValidationError("query %q contains no searchable text", query)
+	}
+	if len(terms) > searchMaxQueryTerms {
+		terms = terms[:searchMaxQueryTerms]
+	}
+	page, size := req.Page, req.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+	resp := &SearchResponse{Query: query, Terms: terms, Page: page, Size: size, Items: []SearchHit{}}
+
+	matches := s.db.WithContext(ctx).Table("search_terms AS t").
+		Select("t.document_id AS document_id, SUM(t.weight) AS score").
+		Joins("JOIN search_documents AS d ON d.document_id = t.document_id").
+		Where("t.term IN ?", terms)
+	if courseScoped(ctx, s.permissions.Roles(), meta) {
+		courses, err := s.allowedCourses(ctx, meta)
+		if err != nil {
+			return nil, err
+		}
+		if len(courses) == 0 {
+			return resp, nil
+		}
+		matches = matches.Where("d.root_node_id IN ?", courses)
+	}
+	if docType := strings.TrimSpace(req.Type); docType != "" {
+		matches = matches.Where("d.doc_type = ?", docType)
+	}
+	matches = matches.Group("t.document_id").Having("COUNT(DISTINCT t.term) = ?", len(terms))
+
+	if err := s.db.WithContext(ctx).Table("(?) AS m", matches).Count(&resp.Total).Error; err != nil {
+		return nil, fmt.Errorf("count search results: %w", err)
+	}
+	if resp.Total == 0 {
+		return resp, nil
+	}
+
+	var scored []struct {
+		DocumentID int64
+		Score      int
+	}
+	if err := matches.Order("score DESC").Order("t.document_id DESC").
+		Limit(size).Offset((page - 1) * size).Scan(&scored).Error; err != nil {
+		return nil, fmt.Errorf("search documents: %w", err)
+	}
+	ids := make([]int64, len(scored))
+	for i, row := range scored {
+		ids[i] = row.DocumentID
+	}
+	var docs []database.SearchDocument
+	if err := s.db.WithContext(ctx).Where("document_id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("load search documents: %w", err)
+	}
+	byID := make(map[int64]database.SearchDocument, len(docs))
+	for _, doc := range docs {
+		byID[doc.DocumentID] = doc
+	}
+
+	for _, row := range scored {
+		doc, ok := byID[row.DocumentID]
+		if !ok {
+			continue
+		}
+		snippetSource := doc.Content
+		if snippetSource == "" {
+			snippetSource = doc.Title
+		}
+		resp.Items = append(resp.Items, SearchHit{
+			DocumentID: doc.DocumentID,
+			Title:      doc.Title,
+			DocType:    doc.DocType,
+			RootNodeID: doc.RootNodeID,
+			NodeID:     doc.NodeID,
+			NodePath:   doc.NodePath,
+			Tags:       strings.Fields(doc.Tags),
+			Snippet:    search.Snippet(snippetSource, terms, searchSnippetRadius),
+			Score:      row.Score,
+			UpdatedAt:  doc.SourceUpdatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// allowedCourses 返回索引中出现过、且用户有权限的课程
+func (s *SearchService) allowedCourses(ctx context.Context, meta RequestMeta) ([]int64, error) {
+	var roots []int64
+	if err := s.db.WithContext(ctx).Model(&database.SearchDocument{}).
+		Where("root_node_id <> 0").Distinct().Pluck("root_node_id", &roots).Error; err != nil {
+		return nil, fmt.Errorf("list indexed courses: %w", err)
+	}
+	return s.permissions.FilterUserCourses(ctx, meta.UserIDNumeric, meta.UserRole, roots)
+}
+
+// documentTags 读取 metadata.tags 中的字符串标签
+func documentTags(doc ndrclient.Document) []string {
+	raw, _ := doc.Metadata["tags"].([]interface{})
+	tags := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if tag, ok := item.(string); ok && strings.TrimSpace(tag) != "" {
+			tags = append(tags, strings.TrimSpace(tag))
+		}
+	}
+	return tags
+}
+
+// documentPlainText 把 content.data 按 content.format 转为纯文本
+func documentPlainText(doc ndrclient.Document) string {
+	format, _ := doc.Content["format"].(string)
+	data, _ := doc.Content["data"].(string)
+	if strings.TrimSpace(data) == "" {
+		return ""
+	}
+	return search.ExtractText(format, data)
+}
+
+// documentTerms 汇总标题、标签、正文的词项及权重
+func documentTerms(doc database.SearchDocument) []database.SearchTerm {
+	weights := make(map[string]int)
+	var order []string
+	add := func(text string, weight int) {
+		for _, token := range search.Tokenize(text) {
+			if _, ok := weights[token]; !ok {
+				order = append(order, token)
+			}
+			weights[token] += weight
+		}
+	}
+	add(doc.Title, searchWeightTitle)
+	add(doc.Tags, searchWeightTags)
+	add(doc.Content, searchWeightContent)
+
+	terms := make([]database.SearchTerm, 0, len(order))
+	for _, token := range order {
+		terms = append(terms, database.SearchTerm{Term: token, DocumentID: doc.DocumentID, Weight: weights[token]})
+	}
+	return terms
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/ndrclient"
+)
+
+// searchNDR 按 ID 返回不同文档的 fakeNDR
+type searchNDR struct {
+	*fakeNDR
+	docs        map[int64]ndrclient.Document
+	failUpdates map[int64]error // 按文档注入 UpdateDocument 的错误
+}
+
+func (f *searchNDR) GetDocument(_ context.Context, _ ndrclient.RequestMeta, id int64) (ndrclient.Document, error) {
+	doc, ok := f.docs[id]
+	if !ok {
+		return ndrclient.Document{}, &ndrclient.Error{StatusCode: 404, Status: "404 Not Found"}
+	}
+	return doc, nil
+}
+
+func (f *searchNDR) ListDocuments(context.Context, ndrclient.RequestMeta, url.Values) (ndrclient.DocumentsPage, error) {
+	items := make([]ndrclient.Document, 0, len(f.docs))
+	for _, doc := range f.docs {
+		items = append(items, doc)
+	}
+	return ndrclient.DocumentsPage{Page: 1, Size: len(items), Total: len(items), Items: items}, nil
+}
+
+// 嵌入的 fakeNDR 会用自己的 ListDocuments 分页，这里改为基于本类型
+func (f *searchNDR) StreamDocuments(ctx context.Context, meta ndrclient.RequestMeta, query url.Values) iter.Seq2[ndrclient.Document, error] {
+	return ndrclient.StreamDocuments(ctx, f, meta, query)
+}
+
+// ListNodes 以 getNodes 作为全部节点，供对账计算节点所属课程
+func (f *searchNDR) ListNodes(context.Context, ndrclient.RequestMeta, ndrclient.ListNodesParams) (ndrclient.NodesPage, error) {
+	items := make([]ndrclient.Node, 0, len(f.getNodes))
+	for _, node := range f.getNodes {
+		items = append(items, node)
+	}
+	return ndrclient.NodesPage{Page: 1, Size: len(items), Total: len(items), Items: items}, nil
+}
+
+func (f *searchNDR) StreamNodes(ctx context.Context, meta ndrclient.RequestMeta, params ndrclient.ListNodesParams) iter.Seq2[ndrclient.Node, error] {
+	return ndrclient.StreamNodes(ctx, f, meta, params)
+}
+
+func TestSearchService_IndexAndSearch(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.CoursePermission{}, &database.Role{},
+		&database.SearchDocument{}, &database.SearchTerm{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	parent := int64(1)
+	other := int64(2)
+	markdown, yamlType := "markdown_v1", "comprehensive_choice_v1"
+	now := time.Now()
+	ndr := &searchNDR{fakeNDR: newFakeNDR(), docs: map[int64]ndrclient.Document{
+		10: {ID: 10, Title: "光合作用", Type: &markdown, UpdatedAt: now,
+			Content:  map[string]any{"format": "markdown", "data": "# 光合作用\n\n植物利用<b>光能</b>合成有机物。"},
+			Metadata: map[string]any{"tags": []interface{}{"生物"}}},
+		11: {ID: 11, Title: "细胞呼吸", Type: &yamlType, UpdatedAt: now,
+			Content: map[string]any{"format": "yaml", "data": "title: 呼吸作用与光合作用的关系\nanswer: A\n"}},
+		12: {ID: 12, Title: "光合作用习题", Type: &markdown, UpdatedAt: now,
+			Content: map[string]any{"format": "markdown", "data": "其他课程"}},
+	}}
+	ndr.getNodes[1] = ndrclient.Node{ID: 1}
+	ndr.getNodes[5] = ndrclient.Node{ID: 5, ParentID: &parent}
+	ndr.getNodes[2] = ndrclient.Node{ID: 2}
+	ndr.getNodes[6] = ndrclient.Node{ID: 6, ParentID: &other}
+	ndr.BindDocument(context.Background(), ndrclient.RequestMeta{}, 5, 10)
+	ndr.BindDocument(context.Background(), ndrclient.RequestMeta{}, 5, 11)
+	ndr.BindDocument(context.Background(), ndrclient.RequestMeta{}, 6, 12)
+
+	userService := NewUserService(db)
+	perms := NewPermissionService(db, userService, ndr, cache.NewNoop())
+	svc := NewSearchService(db, ndr, perms)
+	ctx := context.Background()
+
+	result, err := svc.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Scanned != 3 || result.Indexed != 3 {
+		t.Fatalf("unexpected reconcile result: %+v", result)
+	}
+
+	// 拥有全部课程权限：标题命中的文档排在正文命中之前
+	admin := RequestMeta{UserIDNumeric: 1, UserRole: database.RoleSuperAdmin}
+	resp, err := svc.Search(ctx, admin, SearchRequest{Query: "光合作用"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if resp.Total != 3 || resp.Items[0].DocumentID != 10 || resp.Items[2].DocumentID != 11 {
+		t.Fatalf("unexpected results: %+v", resp.Items)
+	}
+	if resp.Items[0].RootNodeID != 1 || resp.Items[0].Tags[0] != "生物" {
+		t.Fatalf("unexpected hit: %+v", resp.Items[0])
+	}
+	if resp.Items[2].Snippet != "呼吸作用与光合作用的关系\nA" {
+		t.Fatalf("unexpected snippet: %q", resp.Items[2].Snippet)
+	}
+
+	// 课程受限的用户只能看到其课程内的文档
+	if err := db.Create(&database.CoursePermission{UserID: 7, RootNodeID: 1}).Error; err != nil {
+		t.Fatalf("failed to grant course: %v", err)
+	}
+	editor := RequestMeta{UserIDNumeric: 7, UserRole: database.RoleProofreader}
+	resp, err = svc.Search(ctx, editor, SearchRequest{Query: "光合", Type: markdown})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if resp.Total != 1 || resp.Items[0].DocumentID != 10 {
+		t.Fatalf("expected only document 10, got %+v", resp.Items)
+	}
+	resp, err = svc.Search(ctx, RequestMeta{UserIDNumeric: 8, UserRole: database.RoleProofreader}, SearchRequest{Query: "光合"})
+	if err != nil || resp.Total != 0 {
+		t.Fatalf("expected no results without course permissions, got %+v err=%v", resp, err)
+	}
+
+	// 文档被删除后，对账会将其移出索引
+	delete(ndr.docs, 12)
+	result, err = svc.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Indexed != 0 || result.Removed != 1 {
+		t.Fatalf("unexpected reconcile result: %+v", result)
+	}
+
+	// 节点 5 移到课程 2 下：文档 updated_at 不变，对账仍按新课程重建索引
+	ndr.getNodes[5] = ndrclient.Node{ID: 5, ParentID: &other}
+	result, err = svc.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Indexed != 2 {
+		t.Fatalf("expected moved documents to be reindexed, got %+v", result)
+	}
+	resp, err = svc.Search(ctx, editor, SearchRequest{Query: "光合"})
+	if err != nil || resp.Total != 0 {
+		t.Fatalf("expected moved documents to leave course 1, got %+v err=%v", resp, err)
+	}
+	ndr.getNodes[5] = ndrclient.Node{ID: 5, ParentID: &parent}
+	if _, err := svc.Reconcile(ctx, false); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := svc.IndexDocument(ctx, 11); err != nil {
+		t.Fatalf("IndexDocument() error = %v", err)
+	}
+	resp, err = svc.Search(ctx, admin, SearchRequest{Query: "光合作用"})
+	if err != nil || resp.Total != 2 {
+		t.Fatalf("expected 2 results after removal, got %+v err=%v", resp, err)
+	}
+
+	if _, err := svc.Search(ctx, admin, SearchRequest{Query: " ,, "}); err == nil {
+		t.Fatal("expected validation error for empty query")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -16,7 +17,12 @@ import (
 type Service struct {
 	cache       cache.Provider
 	ndr         ndrclient.Client
-	userService *UserService // 用于查询用户权限
+	userService *UserService         // 用于查询用户权限
+	audit       *AuditService        // 审计日志（可选）
+	indexer     DocumentIndexer      // 全文检索索引（可选）
+	refs        *ReferenceIndex      // 文档引用索引（可选）
+	bulkJournal *CategoryBulkJournal // 分类批量操作日志（可选）
+	locks       *LockService         // 文档/节点锁（可选）
 }
 
 // RequestMeta propagates authentication info to downstream services.
@@ -47,6 +53,87 @@ func NewService(cache cache.Provider, ndr ndrclient.Client, userService *UserSer
 	}
 }
 
+// SetAuditService 配置审计日志服务，未配置时不记录审计事件
+func (s *Service) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// SetDocumentIndexer 配置文档写操作后的检索索引刷新，未配置时不刷新
+func (s *Service) SetDocumentIndexer(indexer DocumentIndexer) {
+	s.indexer = indexer
+}
+
+// SetReferenceIndex 配置文档引用索引，未配置时引用查询回退为扫描文档列表，删除前不检查引用
+func (s *Service) SetReferenceIndex(refs *ReferenceIndex) {
+	s.refs = refs
+}
+
+// reindexDocuments 通知检索索引刷新指定文档
+func (s *Service) reindexDocuments(docIDs ...int64) {
+	if s.indexer != nil {
+		s.indexer.EnqueueDocuments(docIDs...)
+	}
+}
+
+// recordAudit 记录针对 NDR 节点/文档的写操作
+func (s *Service) recordAudit(ctx context.Context, meta RequestMeta, action, resourceType string, id int64, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatInt(id, 10),
+		Details:      details,
+	})
+}
+
+// auditCourse 在删除前解析目标所属课程，删除后节点或文档绑定已不可查
+func (s *Service) auditCourse(ctx context.Context, resourceType string, id int64) int64 {
+	return s.audit.CourseOf(ctx, resourceType, strconv.FormatInt(id, 10))
+}
+
+// recordAuditInCourse 与 recordAudit 相同，但使用事先解析的课程
+func (s *Service) recordAuditInCourse(ctx context.Context, meta RequestMeta, action, resourceType string, id, rootNodeID int64, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   strconv.FormatInt(id, 10),
+		RootNodeID:   rootNodeID,
+		Details:      details,
+	})
+}
+
+// roles 返回用于权限判断的角色服务；未注入用户服务时为 nil，按内置角色判断
+func (s *Service) roles() *RoleService {
+	if s.userService == nil {
+		return nil
+	}
+	return s.userService.roles
+}
+
+// roleHasPermission 检查请求者的角色是否拥有指定权限
+func (s *Service) roleHasPermission(ctx context.Context, meta RequestMeta, permission string) bool {
+	return s.roles().HasPermission(ctx, meta.UserRole, permission)
+}
+
+// optionalIDString 把可选的父节点 ID 转为 resource_id，根层级为空串
+func optionalIDString(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+// UpstreamStatus 返回 NDR 客户端的熔断状态；客户端不支持时第二个返回值为 false
+func (s *Service) UpstreamStatus() (ndrclient.BreakerStatus, bool) {
+	if s == nil {
+		return ndrclient.BreakerStatus{}, false
+	}
+	reporter, ok := s.ndr.(ndrclient.HealthReporter)
+	if !ok {
+		return ndrclient.BreakerStatus{}, false
+	}
+	return reporter.BreakerStatus(), true
+}
+
 // Hello returns a friendly greeting, placeholder for future domain logic.
 func (s *Service) Hello(ctx context.Context) (string, error) {
 	if err := s.ndr.Ping(ctx); err != nil {
@@ -46,6 +46,7 @@ type SyncService struct {
 	ndr            ndrclient.Client
 	pdmsBaseURL    string // PDMS base URL for callback
 	prefectEnabled bool   // Whether Prefect integration is enabled
+	roles          *RoleService
 }
 
 // NewSyncService 创建新的 SyncService
@@ -56,9 +57,15 @@ func NewSyncService(db *gorm.DB, prefect *prefectclient.Client, ndr ndrclient.Cl
 		ndr:            ndr,
 		pdmsBaseURL:    pdmsBaseURL,
 		prefectEnabled: prefect != nil,
+		roles:          NewRoleService(db),
 	}
 }
 
+// CanTriggerSync 检查角色是否允许触发同步
+func (s *SyncService) CanTriggerSync(ctx context.Context, role string) bool {
+	return s.roles.HasPermission(ctx, role, database.PermSyncTrigger)
+}
+
 // SyncTarget 同步目标配置（存储在文档 metadata.sync_target 中）
 type SyncTarget struct {
 	Table      string `json:"table,omitempty"`      // 可选：默认处理器需要，自定义处理器可能不需要
@@ -126,6 +133,16 @@ func (s *SyncService) TriggerSync(
 	ctx context.Context,
 	meta RequestMeta,
 	docID int64,
+) (*TriggerSyncResponse, error) {
+	return s.triggerSync(ctx, meta, docID, nil)
+}
+
+// triggerSync 与 TriggerSync 相同；onRunCreated 在任务记录创建后、调用 Prefect 之前执行，返回错误时不再触发
+func (s *SyncService) triggerSync(
+	ctx context.Context,
+	meta RequestMeta,
+	docID int64,
+	onRunCreated func(runID uint) error,
 ) (*TriggerSyncResponse, error) {
 	// 1. 获取文档信息
 	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), docID)
@@ -239,6 +256,19 @@ func (s *SyncService) TriggerSync(
 	if err != nil {
 		return nil, fmt.Errorf("failed to update sync status: %w", err)
 	}
+	if onRunCreated != nil {
+		if err := onRunCreated(workflowRunID); err != nil {
+			_ = s.updateSyncStatusWithCondition(ctx, docID, eventID, SyncStatusFailed, err.Error(), "")
+			s.db.WithContext(ctx).Model(&database.WorkflowRun{}).
+				Where("id = ?", workflowRunID).
+				Updates(map[string]interface{}{
+					"status":        WorkflowStatusFailed,
+					"error_message": err.Error(),
+					"finished_at":   time.Now(),
+				})
+			return nil, err
+		}
+	}
 
 	// 7. 如果 Prefect 未启用，返回 pending 状态
 	if !s.prefectEnabled {
@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/yjxt/ydms/backend/internal/auth"
 	"github.com/yjxt/ydms/backend/internal/database"
 	"gorm.io/gorm"
@@ -11,12 +16,20 @@ import (
 
 // UserService 用户服务
 type UserService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	audit        *AuditService
+	roles        *RoleService
+	refreshGrace time.Duration // refresh token 轮换宽限期
 }
 
 // NewUserService 创建用户服务
 func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+	return &UserService{db: db, audit: NewAuditService(db), roles: NewRoleService(db), refreshGrace: DefaultRefreshGracePeriod}
+}
+
+// Roles 返回用于权限判断的角色服务
+func (s *UserService) Roles() *RoleService {
+	return s.roles
 }
 
 // Authenticate 用户认证（登录）
@@ -59,10 +72,10 @@ func (s *UserService) GetUserByUsername(username string) (*database.User, error)
 }
 
 // CreateUser 创建用户
-func (s *UserService) CreateUser(username, password, role string, createdByID *uint) (*database.User, error) {
+func (s *UserService) CreateUser(ctx context.Context, meta RequestMeta, username, password, role string, createdByID *uint) (*database.User, error) {
 	// 验证角色
-	if role != "super_admin" && role != "course_admin" && role != "proofreader" {
-		return nil, errors.New("invalid role")
+	if !s.roles.RoleExists(ctx, role) {
+		return nil, ErrInvalidRole
 	}
 
 	// 验证密码强度
@@ -94,6 +107,11 @@ func (s *UserService) CreateUser(username, password, role string, createdByID *u
 			if err := s.db.Unscoped().Save(&softDeleted).Error; err != nil {
 				return nil, err
 			}
+			s.recordUserAudit(ctx, meta, "user.create", softDeleted.ID, map[string]interface{}{
+				"username": username,
+				"role":     role,
+				"restored": true,
+			})
 			return &softDeleted, nil
 		}
 	}
@@ -110,12 +128,16 @@ func (s *UserService) CreateUser(username, password, role string, createdByID *u
 	if err != nil {
 		return nil, err
 	}
+	s.recordUserAudit(ctx, meta, "user.create", user.ID, map[string]interface{}{
+		"username": username,
+		"role":     role,
+	})
 
 	return user, nil
 }
 
 // UpdatePassword 修改密码
-func (s *UserService) UpdatePassword(userID uint, newPassword string) error {
+func (s *UserService) UpdatePassword(ctx context.Context, meta RequestMeta, userID uint, newPassword string) error {
 	// 验证密码强度
 	if len(newPassword) < 8 {
 		return errors.New("password must be at least 8 characters")
@@ -128,13 +150,24 @@ func (s *UserService) UpdatePassword(userID uint, newPassword string) error {
 	}
 
 	// 更新密码
-	err = s.db.Model(&database.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error
-	return err
+	if err := s.db.Model(&database.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error; err != nil {
+		return err
+	}
+	s.recordUserAudit(ctx, meta, "user.password_change", userID, nil)
+	return nil
 }
 
-// DeleteUser 删除用户（软删除）
-func (s *UserService) DeleteUser(userID uint) error {
-	return s.db.Delete(&database.User{}, userID).Error
+// DeleteUser 删除用户（软删除），并吊销该用户的所有会话
+func (s *UserService) DeleteUser(ctx context.Context, meta RequestMeta, userID uint) error {
+	if err := s.db.Delete(&database.User{}, userID).Error; err != nil {
+		return err
+	}
+	revoked, err := s.RevokeUserSessions(userID, "", database.SessionRevokedUserDeleted)
+	if err != nil {
+		return err
+	}
+	s.recordUserAudit(ctx, meta, "user.delete", userID, map[string]interface{}{"revoked_sessions": revoked})
+	return nil
 }
 
 // ListUsers 列出用户
@@ -155,36 +188,92 @@ func (s *UserService) ListUsers(role string) ([]*database.User, error) {
 }
 
 // GrantCoursePermission 授予课程权限
-func (s *UserService) GrantCoursePermission(userID uint, rootNodeID int64) error {
+// role 为用户在该课程中的角色，为空时沿用用户的全局角色；权限已存在时更新其角色
+func (s *UserService) GrantCoursePermission(ctx context.Context, meta RequestMeta, userID uint, rootNodeID int64, role string) error {
 	// 检查用户是否存在
 	_, err := s.GetUserByID(userID)
 	if err != nil {
 		return err
 	}
+	if role != "" && !s.roles.RoleExists(ctx, role) {
+		return ErrInvalidRole
+	}
 
-	// 检查权限是否已存在
-	var count int64
-	s.db.Model(&database.CoursePermission{}).
-		Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).
-		Count(&count)
+	details := map[string]interface{}{"user_id": userID, "root_node_id": rootNodeID, "role": role}
 
-	if count > 0 {
-		return nil // 已存在，不需要重复添加
+	// 检查权限是否已存在
+	var existing database.CoursePermission
+	err = s.db.Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).First(&existing).Error
+	if err == nil {
+		if existing.Role == role {
+			return nil // 已存在，不需要重复添加
+		}
+		if err := s.db.Model(&existing).Update("role", role).Error; err != nil {
+			return err
+		}
+		details["previous_role"] = existing.Role
+		s.audit.Record(ctx, meta, AuditEntry{
+			Action:       "course_permission.update",
+			ResourceType: AuditResourceCoursePermission,
+			ResourceID:   coursePermissionAuditID(userID, rootNodeID),
+			Details:      details,
+		})
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
 
 	// 创建权限
 	permission := &database.CoursePermission{
 		UserID:     userID,
 		RootNodeID: rootNodeID,
+		Role:       role,
 	}
 
-	return s.db.Create(permission).Error
+	if err := s.db.Create(permission).Error; err != nil {
+		return err
+	}
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       "course_permission.grant",
+		ResourceType: AuditResourceCoursePermission,
+		ResourceID:   coursePermissionAuditID(userID, rootNodeID),
+		Details:      details,
+	})
+	return nil
 }
 
 // RevokeCoursePermission 撤销课程权限
-func (s *UserService) RevokeCoursePermission(userID uint, rootNodeID int64) error {
-	return s.db.Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).
-		Delete(&database.CoursePermission{}).Error
+func (s *UserService) RevokeCoursePermission(ctx context.Context, meta RequestMeta, userID uint, rootNodeID int64) error {
+	res := s.db.Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).
+		Delete(&database.CoursePermission{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		s.audit.Record(ctx, meta, AuditEntry{
+			Action:       "course_permission.revoke",
+			ResourceType: AuditResourceCoursePermission,
+			ResourceID:   coursePermissionAuditID(userID, rootNodeID),
+			Details:      map[string]interface{}{"user_id": userID, "root_node_id": rootNodeID},
+		})
+	}
+	return nil
+}
+
+// recordUserAudit 记录针对用户的审计事件
+func (s *UserService) recordUserAudit(ctx context.Context, meta RequestMeta, action string, userID uint, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: AuditResourceUser,
+		ResourceID:   strconv.FormatUint(uint64(userID), 10),
+		Details:      details,
+	})
+}
+
+// coursePermissionAuditID 课程权限的审计目标 ID，格式为 "{user_id}:{root_node_id}"
+func coursePermissionAuditID(userID uint, rootNodeID int64) string {
+	return fmt.Sprintf("%d:%d", userID, rootNodeID)
 }
 
 // GetUserCourses 获取用户的课程权限列表
@@ -203,33 +292,155 @@ func (s *UserService) GetUserCourses(userID uint) ([]int64, error) {
 	return rootNodeIDs, nil
 }
 
+// CourseRole 用户在某个课程中的生效角色
+type CourseRole struct {
+	RootNodeID int64  `json:"root_node_id"`
+	Role       string `json:"role"`
+}
+
+// GetUserCourseRoles 获取用户已授权课程及其在各课程中的生效角色
+func (s *UserService) GetUserCourseRoles(userID uint) ([]CourseRole, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []database.CoursePermission
+	if err := s.db.Where("user_id = ?", userID).Order("root_node_id ASC").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	courses := make([]CourseRole, len(permissions))
+	for i, p := range permissions {
+		courses[i] = CourseRole{RootNodeID: p.RootNodeID, Role: effectiveCourseRole(user, &p)}
+	}
+	return courses, nil
+}
+
+// EffectiveCourseRole 解析用户在课程中的生效角色
+// 全局角色拥有 courses:all 时直接使用全局角色；否则使用课程授权上的角色，未获授权时返回空字符串
+func (s *UserService) EffectiveCourseRole(ctx context.Context, userID uint, rootNodeID int64) (string, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if s.roles.HasPermission(ctx, user.Role, database.PermCoursesAll) {
+		return user.Role, nil
+	}
+
+	var permission database.CoursePermission
+	err = s.db.Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).First(&permission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return effectiveCourseRole(user, &permission), nil
+}
+
+// effectiveCourseRole 课程授权未指定角色时沿用用户的全局角色
+func effectiveCourseRole(user *database.User, permission *database.CoursePermission) string {
+	if permission.Role != "" {
+		return permission.Role
+	}
+	return user.Role
+}
+
 // HasCoursePermission 检查用户是否有某个课程的权限
 func (s *UserService) HasCoursePermission(userID uint, rootNodeID int64) (bool, error) {
-	// 先获取用户信息
-	user, err := s.GetUserByID(userID)
+	role, err := s.EffectiveCourseRole(context.Background(), userID, rootNodeID)
 	if err != nil {
 		return false, err
 	}
+	return role != "", nil
+}
+
+// GenerateToken 为用户生成 JWT token，并记录对应的会话以便服务端吊销
+func (s *UserService) GenerateToken(user *database.User, secret string, expiry time.Duration) (string, error) {
+	token, _, err := s.issueAccessToken(user, secret, expiry)
+	return token, err
+}
 
-	// 超级管理员有所有课程权限
-	if user.Role == "super_admin" {
-		return true, nil
+// issueAccessToken 创建会话并签发 access token，返回 token 及其 jti
+func (s *UserService) issueAccessToken(user *database.User, secret string, expiry time.Duration) (string, string, error) {
+	session := &database.UserSession{
+		UserID:    user.ID,
+		TokenID:   uuid.NewString(),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return "", "", fmt.Errorf("failed to create session: %w", err)
 	}
 
-	// 检查数据库中的权限记录
-	var count int64
-	err = s.db.Model(&database.CoursePermission{}).
-		Where("user_id = ? AND root_node_id = ?", userID, rootNodeID).
-		Count(&count).Error
+	token, err := auth.GenerateTokenWithID(user.ID, user.Username, user.Role, session.TokenID, secret, expiry)
+	if err != nil {
+		return "", "", err
+	}
+	return token, session.TokenID, nil
+}
+
+// RevokeSession 吊销单个会话（按 JWT jti）
+// 若该会话由 refresh token 签发，同一 family 的 refresh token 一并吊销
+func (s *UserService) RevokeSession(tokenID, reason string) error {
+	if tokenID == "" {
+		return nil
+	}
 
+	familyID, err := s.familyOfSession(tokenID)
 	if err != nil {
-		return false, err
+		return err
+	}
+	if familyID != "" {
+		return s.revokeTokenFamily(familyID, reason)
 	}
 
-	return count > 0, nil
+	now := time.Now()
+	return s.db.Model(&database.UserSession{}).
+		Where("token_id = ? AND revoked_at IS NULL", tokenID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error
 }
 
-// GenerateToken 为用户生成 JWT token
-func (s *UserService) GenerateToken(user *database.User, secret string, expiry time.Duration) (string, error) {
-	return auth.GenerateToken(user.ID, user.Username, user.Role, secret, expiry)
+// RevokeUserSessions 吊销用户的所有有效会话及 refresh token
+// exceptTokenID 不为空时保留该会话及其 token family（例如修改密码时保留当前登录）
+// 返回被吊销的会话数量
+func (s *UserService) RevokeUserSessions(userID uint, exceptTokenID, reason string) (int64, error) {
+	now := time.Now()
+
+	exceptFamily, err := s.familyOfSession(exceptTokenID)
+	if err != nil {
+		return 0, err
+	}
+	refreshQuery := s.db.Model(&database.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptFamily != "" {
+		refreshQuery = refreshQuery.Where("family_id <> ?", exceptFamily)
+	}
+	if err := refreshQuery.Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason}).Error; err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	query := s.db.Model(&database.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now)
+	if exceptTokenID != "" {
+		query = query.Where("token_id <> ?", exceptTokenID)
+	}
+
+	result := query.Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ListActiveSessions 列出用户未吊销且未过期的会话
+func (s *UserService) ListActiveSessions(userID uint) ([]database.UserSession, error) {
+	var sessions []database.UserSession
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
 }
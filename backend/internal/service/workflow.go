@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
 
+	"github.com/yjxt/ydms/backend/internal/cache"
 	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
 	"github.com/yjxt/ydms/backend/internal/ndrclient"
 	"github.com/yjxt/ydms/backend/internal/prefectclient"
 )
@@ -33,8 +36,10 @@ const ZombieTaskTimeout = 30 * time.Minute
 var ErrWorkflowRunNotFound = errors.New("workflow run not found")
 
 // ValidationError is used for request validation failures that should map to HTTP 400.
+// Fields carries per-field issues when the failure comes from a JSON Schema check.
 type ValidationError struct {
 	Message string
+	Fields  []jsonschema.Error
 }
 
 func (e *ValidationError) Error() string { return e.Message }
@@ -50,6 +55,9 @@ type WorkflowService struct {
 	ndr            ndrclient.Client
 	pdmsBaseURL    string
 	prefectEnabled bool
+	audit          *AuditService
+	locks          *LockService   // 文档/节点锁（可选）
+	cache          cache.Provider // NDR 读缓存（可选），工作流结束时失效
 }
 
 // NewWorkflowService creates a new WorkflowService.
@@ -60,9 +68,34 @@ func NewWorkflowService(db *gorm.DB, prefect *prefectclient.Client, ndr ndrclien
 		ndr:            ndr,
 		pdmsBaseURL:    pdmsBaseURL,
 		prefectEnabled: prefect != nil,
+		audit:          NewAuditService(db),
 	}
 }
 
+// SetLockService 配置文档/节点锁，未配置时触发工作流不检查锁
+func (s *WorkflowService) SetLockService(locks *LockService) {
+	s.locks = locks
+}
+
+// SetCache 配置与 Service 共用的 NDR 读缓存；工作流在 Prefect 中直接写 NDR，结束时需失效缓存
+func (s *WorkflowService) SetCache(p cache.Provider) {
+	s.cache = p
+}
+
+// invalidateRunOutputs 工作流结束后失效其可能写过的 NDR 缓存：
+// 文档工作流只删除该文档，节点工作流生成的文档 ID 未知，整体递增文档代数
+func (s *WorkflowService) invalidateRunOutputs(ctx context.Context, run database.WorkflowRun) {
+	if s.cache == nil {
+		return
+	}
+	invalidateNodes(ctx, s.cache)
+	if run.DocumentID != nil {
+		invalidateDocuments(ctx, s.cache, *run.DocumentID)
+		return
+	}
+	invalidateAllDocuments(ctx, s.cache)
+}
+
 // WorkflowDefinitionInfo represents workflow definition for API responses.
 type WorkflowDefinitionInfo struct {
 	ID              uint                   `json:"id"`
@@ -139,6 +172,9 @@ type TriggerWorkflowRequest struct {
 	Parameters   map[string]interface{} `json:"parameters,omitempty"`
 	SourceDocIDs []int64                `json:"-"`                      // 预获取的源文档 ID（内部使用，跳过重复查询）
 	RetryOfID    *uint                  `json:"retry_of_id,omitempty"`  // 重试来源任务 ID
+	Force        bool                   `json:"force,omitempty"`        // 忽略节点及其文档上他人持有的锁（需要 lock:break 权限）
+	// onRunCreated 在任务记录创建后、调用 Prefect 之前执行（批量执行用来先记下任务 ID），返回错误时不再触发
+	onRunCreated func(runID uint) error
 }
 
 // TriggerDocumentWorkflowRequest represents a request to trigger a workflow on a document.
@@ -147,6 +183,7 @@ type TriggerDocumentWorkflowRequest struct {
 	WorkflowKey string                 `json:"workflow_key"`
 	Parameters  map[string]interface{} `json:"parameters,omitempty"`
 	RetryOfID   *uint                  `json:"retry_of_id,omitempty"` // 重试来源任务 ID
+	Force       bool                   `json:"force,omitempty"`       // 忽略文档上他人持有的锁（需要 lock:break 权限）
 }
 
 // TriggerWorkflowResponse represents the response after triggering a workflow.
@@ -218,6 +255,17 @@ func (s *WorkflowService) TriggerWorkflow(
 		return nil, err
 	}
 
+	// 按 parameter_schema 补默认值、转换类型并校验
+	req.Parameters, err = normalizeWorkflowParameters(def, req.Parameters, nodeWorkflowReservedParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// 节点被他人锁定（如正在校对）时拒绝触发；force 需要 lock:break 权限
+	if err := s.locks.Override(ctx, meta, req.Force, database.LockResourceNode, req.NodeID); err != nil {
+		return nil, err
+	}
+
 	// 2. Verify node exists and get source documents
 	var sourceDocIDs []int64
 
@@ -242,8 +290,7 @@ func (s *WorkflowService) TriggerWorkflow(
 	if needsTargetDocs {
 		query := url.Values{}
 		query.Set("include_descendants", "false")
-		query.Set("size", "100")
-		docsPage, err := s.ndr.ListNodeDocuments(ctx, toNDRMeta(meta), req.NodeID, query)
+		nodeDocs, err := s.ndr.ListNodeDocumentsAll(ctx, toNDRMeta(meta), req.NodeID, query)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get target documents: %w", err)
 		}
@@ -255,7 +302,8 @@ func (s *WorkflowService) TriggerWorkflow(
 		}
 
 		// Filter out source documents from target docs
-		for _, doc := range docsPage.Items {
+		var targetDocIDs []int64
+		for _, doc := range nodeDocs {
 			// Skip source documents - they are inputs, not outputs
 			if sourceDocIDSet[doc.ID] {
 				continue
@@ -269,6 +317,12 @@ func (s *WorkflowService) TriggerWorkflow(
 				"title":       doc.Title,
 				"type":        docType,
 			})
+			targetDocIDs = append(targetDocIDs, doc.ID)
+		}
+
+		// 工作流会覆写这些文档，其中有被他人锁定的文档时同样拒绝
+		if err := s.locks.Override(ctx, meta, req.Force, database.LockResourceDocument, targetDocIDs...); err != nil {
+			return nil, err
 		}
 	}
 
@@ -291,6 +345,16 @@ func (s *WorkflowService) TriggerWorkflow(
 	if err := s.db.Create(&run).Error; err != nil {
 		return nil, fmt.Errorf("failed to create workflow run: %w", err)
 	}
+	if req.onRunCreated != nil {
+		if err := req.onRunCreated(run.ID); err != nil {
+			s.db.Model(&run).Updates(map[string]interface{}{
+				"status":        WorkflowStatusFailed,
+				"error_message": err.Error(),
+				"finished_at":   time.Now(),
+			})
+			return nil, err
+		}
+	}
 
 	// 5. If Prefect is not enabled, return pending status
 	if !s.prefectEnabled {
@@ -330,17 +394,8 @@ func (s *WorkflowService) TriggerWorkflow(
 	}
 
 	// Add user parameters (with reserved key protection)
-	reservedKeys := map[string]bool{
-		"run_id":         true,
-		"node_id":        true,
-		"workflow_key":   true,
-		"source_doc_ids": true,
-		"callback_url":   true,
-		"pdms_base_url":  true,
-		"target_docs":    true,
-	}
 	for k, v := range req.Parameters {
-		if !reservedKeys[k] {
+		if !nodeWorkflowReservedParams[k] {
 			flowParams[k] = v
 		}
 	}
@@ -360,6 +415,12 @@ func (s *WorkflowService) TriggerWorkflow(
 	s.db.Model(&run).Updates(map[string]interface{}{
 		"prefect_flow_run_id": flowRun.ID,
 	})
+	s.recordRunAudit(ctx, meta, "workflow.trigger", run.ID, map[string]interface{}{
+		"workflow_key": req.WorkflowKey,
+		"node_id":      req.NodeID,
+		"retry_of_id":  req.RetryOfID,
+		"force":        req.Force,
+	})
 
 	return &TriggerWorkflowResponse{
 		RunID:            run.ID,
@@ -396,6 +457,17 @@ func (s *WorkflowService) TriggerDocumentWorkflow(
 		return nil, err
 	}
 
+	// 按 parameter_schema 补默认值、转换类型并校验
+	req.Parameters, err = normalizeWorkflowParameters(def, req.Parameters, documentWorkflowReservedParams)
+	if err != nil {
+		return nil, err
+	}
+
+	// 文档被他人锁定时拒绝触发；force 需要 lock:break 权限
+	if err := s.locks.Override(ctx, meta, req.Force, database.LockResourceDocument, req.DocumentID); err != nil {
+		return nil, err
+	}
+
 	// 2. Get document info from NDR
 	doc, err := s.ndr.GetDocument(ctx, toNDRMeta(meta), req.DocumentID)
 	if err != nil {
@@ -455,16 +527,8 @@ func (s *WorkflowService) TriggerDocumentWorkflow(
 	}
 
 	// Add user parameters (with reserved key protection)
-	reservedKeys := map[string]bool{
-		"run_id":        true,
-		"document_id":   true,
-		"document_type": true,
-		"workflow_key":  true,
-		"callback_url":  true,
-		"pdms_base_url": true,
-	}
 	for k, v := range req.Parameters {
-		if !reservedKeys[k] {
+		if !documentWorkflowReservedParams[k] {
 			flowParams[k] = v
 		}
 	}
@@ -484,6 +548,12 @@ func (s *WorkflowService) TriggerDocumentWorkflow(
 	s.db.Model(&run).Updates(map[string]interface{}{
 		"prefect_flow_run_id": flowRun.ID,
 	})
+	s.recordRunAudit(ctx, meta, "workflow.trigger", run.ID, map[string]interface{}{
+		"workflow_key": req.WorkflowKey,
+		"document_id":  req.DocumentID,
+		"retry_of_id":  req.RetryOfID,
+		"force":        req.Force,
+	})
 
 	return &TriggerWorkflowResponse{
 		RunID:            run.ID,
@@ -534,7 +604,7 @@ func (s *WorkflowService) cancelPrefectFlowRunsByQuery(ctx context.Context, quer
 }
 
 // CancelWorkflowRun cancels a workflow run (marks it as cancelled).
-func (s *WorkflowService) CancelWorkflowRun(ctx context.Context, runID uint) error {
+func (s *WorkflowService) CancelWorkflowRun(ctx context.Context, meta RequestMeta, runID uint) error {
 	now := time.Now()
 	updates := map[string]interface{}{
 		"status":      WorkflowStatusCancelled,
@@ -551,6 +621,7 @@ func (s *WorkflowService) CancelWorkflowRun(ctx context.Context, runID uint) err
 	if res.RowsAffected > 0 {
 		// best-effort 取消 Prefect flow run
 		s.cancelPrefectFlowRun(ctx, runID)
+		s.recordRunAudit(ctx, meta, "workflow_run.cancel", runID, nil)
 		return nil
 	}
 
@@ -566,7 +637,7 @@ func (s *WorkflowService) CancelWorkflowRun(ctx context.Context, runID uint) err
 }
 
 // ForceTerminateWorkflowRun 强制终止僵尸任务（运行超过 30 分钟的任务）
-func (s *WorkflowService) ForceTerminateWorkflowRun(ctx context.Context, runID uint) error {
+func (s *WorkflowService) ForceTerminateWorkflowRun(ctx context.Context, meta RequestMeta, runID uint) error {
 	now := time.Now()
 	updates := map[string]interface{}{
 		"status":        WorkflowStatusFailed,
@@ -585,6 +656,7 @@ func (s *WorkflowService) ForceTerminateWorkflowRun(ctx context.Context, runID u
 	if res.RowsAffected > 0 {
 		// best-effort 取消 Prefect flow run
 		s.cancelPrefectFlowRun(ctx, runID)
+		s.recordRunAudit(ctx, meta, "workflow_run.force_terminate", runID, nil)
 		return nil
 	}
 
@@ -812,6 +884,14 @@ func (s *WorkflowService) HandleCallback(ctx context.Context, runID uint, callba
 		return res.Error
 	}
 	// RowsAffected == 0 表示已是终态或不存在，静默忽略
+	if res.RowsAffected > 0 && callback.Status != WorkflowStatusRunning {
+		var run database.WorkflowRun
+		if err := s.db.WithContext(ctx).Select("id", "node_id", "document_id").First(&run, runID).Error; err != nil {
+			log.Printf("[workflow] load run %d for cache invalidation failed: %v", runID, err)
+			run = database.WorkflowRun{}
+		}
+		s.invalidateRunOutputs(ctx, run)
+	}
 	return nil
 }
 
@@ -1011,7 +1091,7 @@ type CleanupWorkflowRunsResponse struct {
 // 只清理已完成的任务（success, failed, cancelled），不清理 pending 和 running
 // 如果 IncludeZombie=true，也会清理运行超过 30 分钟的僵尸任务
 // 如果 ForceCleanupActive=true，会清理所有 pending/running 任务（不仅仅是僵尸任务）
-func (s *WorkflowService) CleanupWorkflowRuns(ctx context.Context, params CleanupWorkflowRunsParams) (*CleanupWorkflowRunsResponse, error) {
+func (s *WorkflowService) CleanupWorkflowRuns(ctx context.Context, meta RequestMeta, params CleanupWorkflowRunsParams) (*CleanupWorkflowRunsResponse, error) {
 	// 默认只清理终态记录
 	allowedStatuses := params.Status
 	if len(allowedStatuses) == 0 {
@@ -1146,9 +1226,37 @@ func (s *WorkflowService) CleanupWorkflowRuns(ctx context.Context, params Cleanu
 		totalDeleted = zombieDeleted + forceDeleted
 	}
 
+	if !params.DryRun {
+		s.audit.Record(ctx, meta, AuditEntry{
+			Action:       "workflow_run.cleanup",
+			ResourceType: AuditResourceWorkflowRun,
+			Details: map[string]interface{}{
+				"deleted_count":        totalDeleted,
+				"zombie_count":         zombieDeleted,
+				"status":               params.Status,
+				"workflow_key":         params.WorkflowKey,
+				"node_id":              params.NodeID,
+				"document_id":          params.DocumentID,
+				"before_date":          params.BeforeDate,
+				"include_zombie":       params.IncludeZombie,
+				"force_cleanup_active": params.ForceCleanupActive,
+			},
+		})
+	}
+
 	return &CleanupWorkflowRunsResponse{
 		DeletedCount: totalDeleted,
 		ZombieCount:  zombieDeleted,
 		DryRun:       params.DryRun,
 	}, nil
 }
+
+// recordRunAudit 记录针对单个工作流任务的审计事件
+func (s *WorkflowService) recordRunAudit(ctx context.Context, meta RequestMeta, action string, runID uint, details map[string]interface{}) {
+	s.audit.Record(ctx, meta, AuditEntry{
+		Action:       action,
+		ResourceType: AuditResourceWorkflowRun,
+		ResourceID:   strconv.FormatUint(uint64(runID), 10),
+		Details:      details,
+	})
+}
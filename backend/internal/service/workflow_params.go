@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/jsonschema"
+)
+
+// nodeWorkflowReservedParams 节点工作流由系统填充的参数，用户传入的同名参数会被忽略
+var nodeWorkflowReservedParams = map[string]bool{
+	"run_id":         true,
+	"node_id":        true,
+	"workflow_key":   true,
+	"source_doc_ids": true,
+	"callback_url":   true,
+	"pdms_base_url":  true,
+	"target_docs":    true,
+}
+
+// documentWorkflowReservedParams 文档工作流由系统填充的参数
+var documentWorkflowReservedParams = map[string]bool{
+	"run_id":        true,
+	"document_id":   true,
+	"document_type": true,
+	"workflow_key":  true,
+	"callback_url":  true,
+	"pdms_base_url": true,
+}
+
+// normalizeWorkflowParameters 按工作流定义的 parameter_schema 处理用户参数：
+// 去掉保留参数，补默认值，把 "5" 之类可无损转换的值转为声明的类型，再逐字段校验。
+// Prefect 同步的 schema 中包含系统参数，校验前从 properties 与 required 中剔除。
+// 未配置 schema 或 schema 无法解析时原样放行（后者记录日志），不影响触发
+func normalizeWorkflowParameters(def *database.WorkflowDefinition, params map[string]interface{}, reserved map[string]bool) (map[string]interface{}, error) {
+	input := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if !reserved[k] {
+			input[k] = v
+		}
+	}
+	if len(def.ParameterSchema) == 0 {
+		return input, nil
+	}
+
+	schema, err := compileParameterSchema(def.ParameterSchema, reserved)
+	if err != nil {
+		log.Printf("[workflow] skip parameter validation for %s: %v", def.WorkflowKey, err)
+		return input, nil
+	}
+	normalized, err := schema.Normalize(input)
+	if err != nil {
+		var schemaErr *jsonschema.ValidationError
+		if errors.As(err, &schemaErr) {
+			return nil, &ValidationError{
+				Message: fmt.Sprintf("工作流 %s 的参数不合法: %s", def.WorkflowKey, schemaErr.Error()),
+				Fields:  prefixFieldPaths(schemaErr.Errors, "parameters"),
+			}
+		}
+		return nil, newValidationError("工作流 %s 的参数无法解析: %v", def.WorkflowKey, err)
+	}
+	out, ok := normalized.(map[string]interface{})
+	if !ok {
+		return nil, newValidationError("工作流 %s 的参数应为对象", def.WorkflowKey)
+	}
+	return out, nil
+}
+
+func compileParameterSchema(raw database.JSONMap, reserved map[string]bool) (*jsonschema.Schema, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if props, ok := doc["properties"].(map[string]interface{}); ok {
+		for key := range reserved {
+			delete(props, key)
+		}
+	}
+	if required, ok := doc["required"].([]interface{}); ok {
+		kept := make([]interface{}, 0, len(required))
+		for _, name := range required {
+			if key, _ := name.(string); !reserved[key] {
+				kept = append(kept, name)
+			}
+		}
+		doc["required"] = kept
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	return jsonschema.Compile(data)
+}
+
+// prefixFieldPaths 把字段路径挂到请求体中的 parameters 下，如 count -> parameters.count
+func prefixFieldPaths(issues []jsonschema.Error, prefix string) []jsonschema.Error {
+	out := make([]jsonschema.Error, len(issues))
+	for i, issue := range issues {
+		if issue.Path == "" {
+			issue.Path = prefix
+		} else {
+			issue.Path = prefix + "." + issue.Path
+		}
+		out[i] = issue
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+)
+
+func TestTriggerWorkflowNormalizesParameters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.WorkflowDefinition{}, &database.WorkflowRun{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	// 与 Prefect 同步的 schema 一样包含系统参数
+	def := database.WorkflowDefinition{
+		WorkflowKey:  "generate_exercises",
+		Name:         "生成练习题",
+		WorkflowType: "node",
+		Enabled:      true,
+		ParameterSchema: database.JSONMap{
+			"type":     "object",
+			"required": []interface{}{"run_id", "node_id", "course_name"},
+			"properties": map[string]interface{}{
+				"run_id":      map[string]interface{}{"type": "integer"},
+				"node_id":     map[string]interface{}{"type": "integer"},
+				"course_name": map[string]interface{}{"type": "string", "minLength": 1},
+				"count":       map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 20, "default": 5},
+				"difficulty":  map[string]interface{}{"type": "string", "enum": []interface{}{"easy", "medium", "hard"}, "default": "medium"},
+			},
+		},
+	}
+	if err := db.Create(&def).Error; err != nil {
+		t.Fatalf("create workflow definition: %v", err)
+	}
+	workflows := NewWorkflowService(db, nil, newFakeNDR(), "http://localhost")
+	ctx := context.Background()
+	meta := RequestMeta{UserID: "1", UserIDNumeric: 1}
+
+	resp, err := workflows.TriggerWorkflow(ctx, meta, TriggerWorkflowRequest{
+		NodeID:      10,
+		WorkflowKey: "generate_exercises",
+		Parameters:  map[string]interface{}{"course_name": "系统架构", "count": "8", "node_id": 99},
+	})
+	if err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	var run database.WorkflowRun
+	if err := db.First(&run, resp.RunID).Error; err != nil {
+		t.Fatalf("load run: %v", err)
+	}
+	if run.Parameters["count"] != float64(8) || run.Parameters["difficulty"] != "medium" {
+		t.Fatalf("expected coerced count and default difficulty, got %#v", run.Parameters)
+	}
+	if _, ok := run.Parameters["node_id"]; ok {
+		t.Fatalf("reserved parameters should be dropped, got %#v", run.Parameters)
+	}
+
+	_, err = workflows.TriggerWorkflow(ctx, meta, TriggerWorkflowRequest{
+		NodeID:      10,
+		WorkflowKey: "generate_exercises",
+		Parameters:  map[string]interface{}{"count": 50, "difficulty": "insane"},
+	})
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, f := range vErr.Fields {
+		fields[f.Path] = true
+	}
+	for _, path := range []string{"parameters.course_name", "parameters.count", "parameters.difficulty"} {
+		if !fields[path] {
+			t.Fatalf("expected an error for %s, got %+v", path, vErr.Fields)
+		}
+	}
+	var count int64
+	db.Model(&database.WorkflowRun{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("invalid parameters should not create a run, got %d runs", count)
+	}
+}
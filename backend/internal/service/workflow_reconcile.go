@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+)
+
+// DefaultReconcileInterval 默认对账间隔
+const DefaultReconcileInterval = 30 * time.Second
+
+// reconcileGracePeriod 对账宽限期：Prefect 进入终态后，在此时间内仍等待回调，
+// 避免对账抢先结束任务导致回调携带的 result 被丢弃
+const reconcileGracePeriod = time.Minute
+
+// reconcileBatchSize 每次从数据库读取的活跃任务数，一轮对账按 ID 分页遍历全部活跃任务
+const reconcileBatchSize = 200
+
+// Prefect flow run 状态类型
+const (
+	prefectStateRunning   = "RUNNING"
+	prefectStateCompleted = "COMPLETED"
+	prefectStateFailed    = "FAILED"
+	prefectStateCrashed   = "CRASHED"
+	prefectStateCancelled = "CANCELLED"
+)
+
+// ReconcileResult 一轮对账的统计
+type ReconcileResult struct {
+	Checked int `json:"checked"` // 查询 Prefect 的任务数
+	Updated int `json:"updated"` // 状态发生变化的任务数
+	Errors  int `json:"errors"`  // 查询 Prefect 失败的任务数
+}
+
+// StartReconciler 启动后台对账协程，定期向 Prefect 查询活跃任务的真实状态，直到 ctx 取消
+// 用于兜底回调丢失的情况；未启用 Prefect 或 interval <= 0 时不启动
+func (s *WorkflowService) StartReconciler(ctx context.Context, interval time.Duration) {
+	if !s.prefectEnabled || interval <= 0 {
+		return
+	}
+	log.Printf("[workflow_reconcile] started, interval=%s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("[workflow_reconcile] stopped")
+				return
+			case <-ticker.C:
+				result, err := s.ReconcileActiveRuns(ctx)
+				if err != nil {
+					log.Printf("[workflow_reconcile] reconcile failed: %v", err)
+					continue
+				}
+				if result.Updated > 0 || result.Errors > 0 {
+					log.Printf("[workflow_reconcile] checked=%d updated=%d errors=%d", result.Checked, result.Updated, result.Errors)
+				}
+			}
+		}
+	}()
+}
+
+// ReconcileActiveRuns 对账一轮：查询所有带 Prefect flow run ID 的 pending/running 任务，
+// 按 Prefect 状态更新 workflow_runs 及关联的 doc_sync_statuses
+func (s *WorkflowService) ReconcileActiveRuns(ctx context.Context) (*ReconcileResult, error) {
+	result := &ReconcileResult{}
+	if !s.prefectEnabled {
+		return result, nil
+	}
+
+	now := time.Now()
+	var lastID uint
+	for ctx.Err() == nil {
+		var runs []database.WorkflowRun
+		if err := s.db.WithContext(ctx).
+			Select("id, status, prefect_flow_run_id, started_at, node_id, document_id").
+			Where("status IN ? AND prefect_flow_run_id != '' AND id > ?", []string{WorkflowStatusPending, WorkflowStatusRunning}, lastID).
+			Order("id ASC").
+			Limit(reconcileBatchSize).
+			Find(&runs).Error; err != nil {
+			return nil, fmt.Errorf("failed to list active workflow runs: %w", err)
+		}
+
+		for _, run := range runs {
+			if ctx.Err() != nil {
+				break
+			}
+			s.reconcileRun(ctx, run, now, result)
+		}
+		if len(runs) < reconcileBatchSize {
+			break
+		}
+		lastID = runs[len(runs)-1].ID
+	}
+	return result, nil
+}
+
+// reconcileRun 按 Prefect 状态更新单个任务并计入 result
+func (s *WorkflowService) reconcileRun(ctx context.Context, run database.WorkflowRun, now time.Time, result *ReconcileResult) {
+	result.Checked++
+	flowRun, err := getFlowRunForReconcile(ctx, s.prefect, run.PrefectFlowRunID)
+	if err != nil {
+		result.Errors++
+		log.Printf("[workflow_reconcile] get flow run %s (run %d) failed: %v", run.PrefectFlowRunID, run.ID, err)
+		return
+	}
+	updated, err := applyFlowRunState(ctx, s.db, run, flowRun, now)
+	if err != nil {
+		result.Errors++
+		log.Printf("[workflow_reconcile] update run %d failed: %v", run.ID, err)
+		return
+	}
+	if updated {
+		result.Updated++
+		s.invalidateRunOutputs(ctx, run)
+	}
+}
+
+// getFlowRunForReconcile 查询 flow run；Prefect 中已不存在（404）时视为失败的终态，避免任务永远停留在活跃状态
+func getFlowRunForReconcile(ctx context.Context, prefect *prefectclient.Client, flowRunID string) (*prefectclient.FlowRunResponse, error) {
+	flowRun, err := prefect.GetFlowRun(ctx, flowRunID)
+	if errors.Is(err, prefectclient.ErrFlowRunNotFound) {
+		return &prefectclient.FlowRunResponse{
+			ID:    flowRunID,
+			State: &prefectclient.StateResponse{Type: prefectStateFailed, Message: "flow run not found in Prefect"},
+		}, nil
+	}
+	return flowRun, err
+}
+
+// interruptedRunError 进程在拿到 flow run ID 之前退出时写入任务的错误信息
+const interruptedRunError = "interrupted before the Prefect flow run was confirmed; check Prefect before retrying"
+
+// reconcileInterruptedRun 对账进程中断前已创建的任务，供批量执行恢复时使用（不会重新触发）：
+// 已记录 flow run ID 的按 Prefect 状态更新；未记录的无法确认 Prefect 是否已创建 flow run，标记为失败
+// 任务不存在时返回 nil
+func reconcileInterruptedRun(ctx context.Context, db *gorm.DB, prefect *prefectclient.Client, runID uint) (*database.WorkflowRun, error) {
+	var run database.WorkflowRun
+	if err := db.WithContext(ctx).First(&run, runID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	if prefect == nil || (run.Status != WorkflowStatusPending && run.Status != WorkflowStatusRunning) {
+		return &run, nil
+	}
+
+	now := time.Now()
+	if run.PrefectFlowRunID == "" {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&database.WorkflowRun{}).
+				Where("id = ? AND status IN ?", run.ID, []string{WorkflowStatusPending, WorkflowStatusRunning}).
+				Updates(map[string]interface{}{
+					"status":        WorkflowStatusFailed,
+					"error_message": interruptedRunError,
+					"finished_at":   &now,
+				}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&database.DocSyncStatus{}).
+				Where("last_workflow_run_id = ? AND last_status = ?", run.ID, SyncStatusPending).
+				Updates(map[string]interface{}{"last_status": SyncStatusFailed, "last_error": interruptedRunError}).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fail interrupted run: %w", err)
+		}
+	} else if flowRun, err := getFlowRunForReconcile(ctx, prefect, run.PrefectFlowRunID); err != nil {
+		// 查询失败时保持原状态，由定期对账继续处理
+		log.Printf("[workflow_reconcile] get flow run %s (run %d) failed: %v", run.PrefectFlowRunID, run.ID, err)
+	} else if _, err := applyFlowRunState(ctx, db, run, flowRun, now); err != nil {
+		log.Printf("[workflow_reconcile] update run %d failed: %v", run.ID, err)
+	}
+
+	if err := db.WithContext(ctx).First(&run, runID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	return &run, nil
+}
+
+// applyFlowRunState 把 Prefect 状态映射到本地任务状态，返回是否有更新
+func applyFlowRunState(ctx context.Context, db *gorm.DB, run database.WorkflowRun, flowRun *prefectclient.FlowRunResponse, now time.Time) (bool, error) {
+	stateType, message := flowRunState(flowRun)
+	status := mapPrefectState(stateType)
+	if status == "" || status == run.Status {
+		return false, nil
+	}
+
+	if status == WorkflowStatusRunning {
+		startedAt := now
+		if flowRun.StartTime != nil {
+			startedAt = *flowRun.StartTime
+		}
+		res := db.WithContext(ctx).Model(&database.WorkflowRun{}).
+			Where("id = ? AND status = ?", run.ID, WorkflowStatusPending).
+			Updates(map[string]interface{}{
+				"status":     WorkflowStatusRunning,
+				"started_at": gorm.Expr("COALESCE(started_at, ?)", startedAt),
+			})
+		return res.RowsAffected > 0, res.Error
+	}
+
+	// 终态：宽限期内继续等待回调
+	finishedAt := now
+	if flowRun.EndTime != nil {
+		finishedAt = *flowRun.EndTime
+		if now.Sub(finishedAt) < reconcileGracePeriod {
+			return false, nil
+		}
+	}
+
+	errorMessage := ""
+	if status != WorkflowStatusSuccess {
+		errorMessage = fmt.Sprintf("reconciled from Prefect state %s", stateType)
+		if message != "" {
+			errorMessage += ": " + message
+		}
+	}
+
+	updated := false
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":        status,
+			"error_message": errorMessage,
+			"finished_at":   &finishedAt,
+			"started_at":    gorm.Expr("COALESCE(started_at, ?)", finishedAt),
+		}
+		res := tx.Model(&database.WorkflowRun{}).
+			Where("id = ? AND status IN ?", run.ID, []string{WorkflowStatusPending, WorkflowStatusRunning}).
+			Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // 回调已先一步处理
+		}
+		updated = true
+
+		// 关联的同步状态一并结束，避免 TriggerSync 等到 SyncPendingTimeout
+		syncUpdates := map[string]interface{}{
+			"last_status": SyncStatusFailed,
+			"last_error":  errorMessage,
+			"last_run_id": flowRun.ID,
+		}
+		if status == WorkflowStatusSuccess {
+			syncUpdates["last_status"] = SyncStatusSuccess
+			syncUpdates["last_synced_at"] = &finishedAt
+		}
+		return tx.Model(&database.DocSyncStatus{}).
+			Where("last_workflow_run_id = ? AND last_status = ?", run.ID, SyncStatusPending).
+			Updates(syncUpdates).Error
+	})
+	return updated, err
+}
+
+// flowRunState 取 flow run 的状态类型与说明
+func flowRunState(flowRun *prefectclient.FlowRunResponse) (string, string) {
+	if flowRun == nil {
+		return "", ""
+	}
+	if flowRun.State != nil && flowRun.State.Type != "" {
+		return strings.ToUpper(flowRun.State.Type), flowRun.State.Message
+	}
+	return strings.ToUpper(flowRun.StateType), ""
+}
+
+// mapPrefectState 把 Prefect 状态类型映射为 WorkflowRun 状态，无需变更时返回空串
+func mapPrefectState(stateType string) string {
+	switch stateType {
+	case prefectStateRunning:
+		return WorkflowStatusRunning
+	case prefectStateCompleted:
+		return WorkflowStatusSuccess
+	case prefectStateFailed, prefectStateCrashed:
+		return WorkflowStatusFailed
+	case prefectStateCancelled:
+		return WorkflowStatusCancelled
+	default:
+		// SCHEDULED / PENDING / PAUSED / CANCELLING 等中间状态保持不变
+		return ""
+	}
+}
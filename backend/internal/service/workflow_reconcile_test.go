@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/yjxt/ydms/backend/internal/cache"
+	"github.com/yjxt/ydms/backend/internal/database"
+	"github.com/yjxt/ydms/backend/internal/prefectclient"
+)
+
+func setupReconcileTest(t *testing.T, flowRuns map[string]prefectclient.FlowRunResponse) (*WorkflowService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&database.User{}, &database.WorkflowRun{}, &database.DocSyncStatus{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/flow_runs/")
+		if id == "fr-broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		flowRun, ok := flowRuns[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(flowRun)
+	}))
+	t.Cleanup(server.Close)
+
+	svc := NewWorkflowService(db, prefectclient.NewClient(server.URL, 5*time.Second), nil, "")
+	return svc, db
+}
+
+func createReconcileRun(t *testing.T, db *gorm.DB, status, flowRunID string) database.WorkflowRun {
+	t.Helper()
+	run := database.WorkflowRun{WorkflowKey: SyncWorkflowKey, Status: status, PrefectFlowRunID: flowRunID}
+	if err := db.Create(&run).Error; err != nil {
+		t.Fatalf("failed to create workflow run: %v", err)
+	}
+	return run
+}
+
+func TestReconcileActiveRuns_MapsPrefectStates(t *testing.T) {
+	longAgo := time.Now().Add(-10 * time.Minute)
+	justNow := time.Now()
+	svc, db := setupReconcileTest(t, map[string]prefectclient.FlowRunResponse{
+		"fr-running":   {ID: "fr-running", State: &prefectclient.StateResponse{Type: "RUNNING"}},
+		"fr-completed": {ID: "fr-completed", State: &prefectclient.StateResponse{Type: "COMPLETED"}, EndTime: &longAgo},
+		"fr-crashed":   {ID: "fr-crashed", State: &prefectclient.StateResponse{Type: "CRASHED", Message: "worker died"}, EndTime: &longAgo},
+		"fr-fresh":     {ID: "fr-fresh", State: &prefectclient.StateResponse{Type: "COMPLETED"}, EndTime: &justNow},
+		"fr-scheduled": {ID: "fr-scheduled", State: &prefectclient.StateResponse{Type: "SCHEDULED"}},
+	})
+
+	running := createReconcileRun(t, db, WorkflowStatusPending, "fr-running")
+	completed := createReconcileRun(t, db, WorkflowStatusRunning, "fr-completed")
+	crashed := createReconcileRun(t, db, WorkflowStatusRunning, "fr-crashed")
+	fresh := createReconcileRun(t, db, WorkflowStatusRunning, "fr-fresh")
+	scheduled := createReconcileRun(t, db, WorkflowStatusPending, "fr-scheduled")
+	missing := createReconcileRun(t, db, WorkflowStatusRunning, "fr-missing")
+	broken := createReconcileRun(t, db, WorkflowStatusRunning, "fr-broken")
+
+	syncStatus := database.DocSyncStatus{DocumentID: 42, LastEventID: "evt", LastStatus: SyncStatusPending, LastWorkflowRunID: &completed.ID}
+	if err := db.Create(&syncStatus).Error; err != nil {
+		t.Fatalf("failed to create sync status: %v", err)
+	}
+
+	result, err := svc.ReconcileActiveRuns(context.Background())
+	if err != nil {
+		t.Fatalf("ReconcileActiveRuns() error = %v", err)
+	}
+	if result.Checked != 7 || result.Updated != 4 || result.Errors != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	expect := map[uint]string{
+		running.ID:   WorkflowStatusRunning,
+		completed.ID: WorkflowStatusSuccess,
+		crashed.ID:   WorkflowStatusFailed,
+		fresh.ID:     WorkflowStatusRunning,
+		scheduled.ID: WorkflowStatusPending,
+		missing.ID:   WorkflowStatusFailed, // Prefect 返回 404：flow run 已不存在
+		broken.ID:    WorkflowStatusRunning,
+	}
+	for id, want := range expect {
+		var run database.WorkflowRun
+		db.First(&run, id)
+		if run.Status != want {
+			t.Errorf("run %d (%s): expected %s, got %s", id, run.PrefectFlowRunID, want, run.Status)
+		}
+	}
+
+	var crashedRun database.WorkflowRun
+	db.First(&crashedRun, crashed.ID)
+	if !strings.Contains(crashedRun.ErrorMessage, "worker died") || crashedRun.FinishedAt == nil {
+		t.Errorf("expected crashed run to record error and finished_at, got %+v", crashedRun)
+	}
+
+	var gotSync database.DocSyncStatus
+	db.First(&gotSync, syncStatus.ID)
+	if gotSync.LastStatus != SyncStatusSuccess || gotSync.LastSyncedAt == nil {
+		t.Errorf("expected linked sync status to succeed, got %+v", gotSync)
+	}
+}
+
+func TestReconcileActiveRuns_DoesNotOverrideCallback(t *testing.T) {
+	longAgo := time.Now().Add(-10 * time.Minute)
+	svc, db := setupReconcileTest(t, map[string]prefectclient.FlowRunResponse{
+		"fr-1": {ID: "fr-1", State: &prefectclient.StateResponse{Type: "FAILED"}, EndTime: &longAgo},
+	})
+	run := createReconcileRun(t, db, WorkflowStatusRunning, "fr-1")
+
+	// 回调在对账之前已经到达
+	if err := svc.HandleCallback(context.Background(), run.ID, WorkflowCallbackRequest{Status: "success"}); err != nil {
+		t.Fatalf("HandleCallback() error = %v", err)
+	}
+
+	result, err := svc.ReconcileActiveRuns(context.Background())
+	if err != nil {
+		t.Fatalf("ReconcileActiveRuns() error = %v", err)
+	}
+	if result.Checked != 0 {
+		t.Fatalf("expected finished runs to be skipped, got %+v", result)
+	}
+	var got database.WorkflowRun
+	db.First(&got, run.ID)
+	if got.Status != WorkflowStatusSuccess {
+		t.Fatalf("expected callback status to be kept, got %s", got.Status)
+	}
+}
+
+func TestReconcileActiveRuns_PagesThroughAllRunsAndScopesInvalidation(t *testing.T) {
+	longAgo := time.Now().Add(-10 * time.Minute)
+	completed := &prefectclient.StateResponse{Type: "COMPLETED"}
+	svc, db := setupReconcileTest(t, map[string]prefectclient.FlowRunResponse{
+		"fr-doc":  {ID: "fr-doc", State: completed, EndTime: &longAgo},
+		"fr-last": {ID: "fr-last", State: completed, EndTime: &longAgo},
+	})
+	provider := cache.NewMemory(100, time.Minute)
+	svc.SetCache(provider)
+	ctx := context.Background()
+
+	// 文档任务结束后只删除该文档的缓存，文档代数不变
+	docID := int64(7)
+	docRun := database.WorkflowRun{WorkflowKey: "summarize", Status: WorkflowStatusRunning, PrefectFlowRunID: "fr-doc", DocumentID: &docID}
+	if err := db.Create(&docRun).Error; err != nil {
+		t.Fatalf("failed to create workflow run: %v", err)
+	}
+	docsGen := documentsGeneration(ctx, provider)
+	if result, err := svc.ReconcileActiveRuns(ctx); err != nil || result.Updated != 1 {
+		t.Fatalf("ReconcileActiveRuns() = %+v, %v", result, err)
+	}
+	if got := documentsGeneration(ctx, provider); got != docsGen {
+		t.Fatalf("document run should only invalidate its own document, generation %s -> %s", docsGen, got)
+	}
+
+	// 超过一页的活跃任务：排在最后的任务也要对账
+	for i := 0; i < reconcileBatchSize+5; i++ {
+		createReconcileRun(t, db, WorkflowStatusRunning, fmt.Sprintf("fr-gone-%d", i))
+	}
+	last := createReconcileRun(t, db, WorkflowStatusRunning, "fr-last")
+	result, err := svc.ReconcileActiveRuns(ctx)
+	if err != nil {
+		t.Fatalf("ReconcileActiveRuns() error = %v", err)
+	}
+	if result.Checked != reconcileBatchSize+6 || result.Updated != reconcileBatchSize+6 || result.Errors != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	db.First(&last, last.ID)
+	if last.Status != WorkflowStatusSuccess {
+		t.Fatalf("expected the run on the second page to be reconciled, got %s", last.Status)
+	}
+}
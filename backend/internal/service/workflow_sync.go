@@ -41,6 +41,11 @@ func NewWorkflowSyncService(db *gorm.DB, prefectClient *prefectclient.Client, pr
 	}
 }
 
+// Roles returns the role service used for permission checks.
+func (s *WorkflowSyncService) Roles() *RoleService {
+	return NewRoleService(s.db)
+}
+
 // SyncStatus represents the current sync status.
 type SyncStatus struct {
 	LastSyncTime   *time.Time `json:"last_sync_time,omitempty"`
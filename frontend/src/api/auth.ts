@@ -29,6 +29,9 @@ export interface LoginRequest {
  */
 export interface LoginResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
+  refresh_expires_at: string;
   user: User;
 }
 
@@ -59,6 +59,7 @@ export interface DocumentCreatePayload {
   position?: number;
   metadata?: Record<string, unknown>;
   content?: Record<string, unknown>;
+  node_id?: number;
 }
 
 export interface DocumentUpdatePayload {
@@ -67,6 +68,8 @@ export interface DocumentUpdatePayload {
   position?: number;
   metadata?: Record<string, unknown>;
   content?: Record<string, unknown>;
+  // 编辑所基于的版本号，文档已被他人更新时后端返回 409
+  base_version?: number;
 }
 
 export interface DocumentsPage {
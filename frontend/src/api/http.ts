@@ -14,7 +14,17 @@ const apiBaseUrl = cleanedBase;
 /**
  * Token 存储键名
  */
-const TOKEN_KEY = "ydms_auth_token";
+export const TOKEN_KEY = "ydms_auth_token";
+
+/**
+ * Refresh token 存储键名
+ */
+export const REFRESH_TOKEN_KEY = "ydms_refresh_token";
+
+/**
+ * 不触发自动刷新的认证端点
+ */
+const NO_REFRESH_PATHS = ["/api/v1/auth/login", "/api/v1/auth/refresh"];
 
 /**
  * 获取存储的 token
@@ -24,9 +34,55 @@ function getToken(): string | null {
   return localStorage.getItem(TOKEN_KEY);
 }
 
+/**
+ * 正在进行的刷新请求（并发 401 共享同一次刷新）
+ */
+let refreshPromise: Promise<boolean> | null = null;
+
+/**
+ * 使用 refresh token 换取新的 access token
+ * refresh token 每次使用后轮换，成功时同时保存新的 refresh token
+ */
+async function refreshAccessToken(): Promise<boolean> {
+  if (typeof window === "undefined") return false;
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (!refreshToken) return false;
+
+  if (!refreshPromise) {
+    const url = apiBaseUrl
+      ? `${apiBaseUrl}/api/v1/auth/refresh`
+      : "/api/v1/auth/refresh";
+    refreshPromise = fetch(url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    })
+      .then(async (response) => {
+        if (!response.ok) {
+          localStorage.removeItem(REFRESH_TOKEN_KEY);
+          return false;
+        }
+        const data = (await response.json()) as {
+          token: string;
+          refresh_token: string;
+        };
+        localStorage.setItem(TOKEN_KEY, data.token);
+        localStorage.setItem(REFRESH_TOKEN_KEY, data.refresh_token);
+        return true;
+      })
+      .catch(() => false)
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+
+  return refreshPromise;
+}
+
 export async function http<T>(
   path: string,
   options: RequestInit = {},
+  retried = false,
 ): Promise<T> {
   const url = apiBaseUrl ? `${apiBaseUrl}${path}` : path;
 
@@ -46,6 +102,16 @@ export async function http<T>(
     headers,
   });
 
+  // access token 过期时先尝试刷新，成功后重试一次原请求
+  if (
+    response.status === 401 &&
+    !retried &&
+    !NO_REFRESH_PATHS.includes(path) &&
+    (await refreshAccessToken())
+  ) {
+    return http<T>(path, options, true);
+  }
+
   if (!response.ok) {
     const text = await response.text();
 
@@ -54,6 +120,7 @@ export async function http<T>(
       // 清除过期的 token
       if (typeof window !== "undefined") {
         localStorage.removeItem(TOKEN_KEY);
+        localStorage.removeItem(REFRESH_TOKEN_KEY);
       }
       // 触发全局事件，让 AuthContext 处理
       if (typeof window !== "undefined") {
@@ -13,11 +13,7 @@ import {
   getCurrentUser,
   LoginRequest,
 } from "../api/auth";
-
-/**
- * Token 存储键名
- */
-const TOKEN_KEY = "ydms_auth_token";
+import { REFRESH_TOKEN_KEY, TOKEN_KEY } from "../api/http";
 
 /**
  * AuthContext 状态
@@ -72,6 +68,7 @@ export function AuthProvider({ children }: AuthProviderProps) {
       // Token 可能已过期，清除
       setToken(null);
       localStorage.removeItem(TOKEN_KEY);
+      localStorage.removeItem(REFRESH_TOKEN_KEY);
       setUser(null);
     } finally {
       setLoading(false);
@@ -87,6 +84,7 @@ export function AuthProvider({ children }: AuthProviderProps) {
       setToken(response.token);
       setUser(response.user);
       localStorage.setItem(TOKEN_KEY, response.token);
+      localStorage.setItem(REFRESH_TOKEN_KEY, response.refresh_token);
     } catch (error) {
       console.error("Login failed:", error);
       throw error;
@@ -105,6 +103,7 @@ export function AuthProvider({ children }: AuthProviderProps) {
       setToken(null);
       setUser(null);
       localStorage.removeItem(TOKEN_KEY);
+      localStorage.removeItem(REFRESH_TOKEN_KEY);
     }
   };
 
@@ -123,6 +122,7 @@ export function AuthProvider({ children }: AuthProviderProps) {
       setToken(null);
       setUser(null);
       localStorage.removeItem(TOKEN_KEY);
+      localStorage.removeItem(REFRESH_TOKEN_KEY);
     };
 
     window.addEventListener("auth:unauthorized", handleUnauthorized);
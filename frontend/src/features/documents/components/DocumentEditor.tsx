@@ -46,7 +46,6 @@ import { HTMLPreview } from "./HTMLPreview";
 import { YAMLPreview } from "./YAMLPreview";
 import { useDocumentTagCache } from "../hooks/useDocumentTagCache";
 import {
-  bindDocument,
   createDocument,
   getDocumentDetail,
   getDocumentBindings,
@@ -218,6 +217,8 @@ export const DocumentEditor: FC<DocumentEditorProps> = ({ mode, docId: docIdProp
   const [title, setTitle] = useState("");
   const [documentType, setDocumentType] = useState<string>(defaultDocumentType);
   const [position, setPosition] = useState<number | undefined>();
+  // 正在编辑的内容所基于的版本号，保存时作为 base_version 发送
+  const [baseVersion, setBaseVersion] = useState<number | undefined>();
   const [content, setContent] = useState("");
   const [metadataDifficulty, setMetadataDifficulty] = useState<number | null>(null);
   const [metadataTags, setMetadataTags] = useState<string[]>([]);
@@ -280,6 +281,7 @@ export const DocumentEditor: FC<DocumentEditorProps> = ({ mode, docId: docIdProp
       return;
     }
     setTitle(existingDoc.title ?? "");
+    setBaseVersion(existingDoc.version_number ?? undefined);
     const nextType =
       existingDoc.type && getDocumentTypeDefinition(existingDoc.type)
         ? existingDoc.type
@@ -703,9 +705,9 @@ export const DocumentEditor: FC<DocumentEditorProps> = ({ mode, docId: docIdProp
         payload.metadata = metadataPayload;
       }
 
-      const doc = await createDocument(payload);
-      await bindDocument(effectiveNodeId, doc.id);
-      return doc;
+      // 由后端在创建时绑定节点，权限按节点所属课程判断
+      payload.node_id = effectiveNodeId;
+      return createDocument(payload);
     },
     onSuccess: async (_doc) => {
       message.success("文档创建成功");
@@ -742,11 +744,15 @@ export const DocumentEditor: FC<DocumentEditorProps> = ({ mode, docId: docIdProp
           : undefined,
         metadata: buildMetadataPayload(),
       };
+      if (typeof baseVersion === "number") {
+        payload.base_version = baseVersion;
+      }
 
       return updateDocument(effectiveDocId, payload);
     },
-    onSuccess: async () => {
+    onSuccess: async (updatedDoc) => {
       message.success("文档更新成功");
+      setBaseVersion(updatedDoc.version_number ?? undefined);
       if (effectiveDocId) {
         await queryClient.invalidateQueries({ queryKey: ["document-detail", effectiveDocId] });
       }
@@ -759,6 +765,10 @@ export const DocumentEditor: FC<DocumentEditorProps> = ({ mode, docId: docIdProp
       // 保存后不关闭窗口，方便继续编辑或执行同步操作
     },
     onError: (error: Error) => {
+      if (error.message.includes("[409]") && error.message.includes("current_version")) {
+        message.error("文档已被其他人修改，请复制当前内容后重新打开文档再保存");
+        return;
+      }
       message.error(error.message || "更新失败");
     },
   });